@@ -0,0 +1,86 @@
+package adsync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var ldapServer, bindUser, bindPassword, baseDNs, ldapFilter, mappingFile, externalDataSet, outputFileName string
+var insecure, startTLS, noUMWL, noDNS, cleanup, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	ADSyncCmd.Flags().StringVarP(&ldapServer, "ldap-server", "s", "", "ldap url of a domain controller (e.g., ldaps://dc1.corp.local:636 or ldap://dc1.corp.local:389).")
+	ADSyncCmd.Flags().StringVarP(&bindUser, "bind-user", "u", "", "bind user (e.g., svc-workloader@corp.local). default is the AD_BIND_USER environment variable.")
+	ADSyncCmd.Flags().StringVarP(&bindPassword, "bind-pwd", "p", "", "bind password. default is the AD_BIND_PASSWORD environment variable.")
+	ADSyncCmd.Flags().BoolVar(&startTLS, "start-tls", false, "upgrade an ldap:// connection with starttls.")
+	ADSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the domain controller certificate.")
+	ADSyncCmd.Flags().StringVarP(&baseDNs, "ous", "o", "", "semicolon-separated list of ou distinguished names to search (e.g., \"OU=Servers,DC=corp,DC=local;OU=DMZ,DC=corp,DC=local\").")
+	ADSyncCmd.Flags().StringVarP(&ldapFilter, "ldap-filter", "f", "(&(objectCategory=computer)(!(userAccountControl:1.2.840.113556.1.4.803:=2)))", "ldap filter. default is enabled computer objects.")
+	ADSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: ldap attribute or ad: pseudo attribute and illumio label key.")
+	ADSyncCmd.Flags().BoolVar(&noUMWL, "no-umwl", false, "only label matching workloads. do not create unmanaged workloads for unmatched computers.")
+	ADSyncCmd.Flags().BoolVar(&noDNS, "no-dns", false, "do not resolve the dns hostname of unmatched computers. computers without ip addresses are skipped for unmanaged workloads.")
+	ADSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "ad-sync", "external data set used to identify unmanaged workloads managed by ad-sync.")
+	ADSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for computers that are disabled, removed, or moved out of the ous.")
+	ADSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	ADSyncCmd.Flags().SortFlags = false
+}
+
+// ADSyncCmd runs the ad-sync command
+var ADSyncCmd = &cobra.Command{
+	Use:         "ad-sync",
+	Short:       "Label workloads from Active Directory computer objects and create unmanaged workloads for unmatched computers.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Label workloads from Active Directory computer objects and create unmanaged workloads for unmatched computers.
+
+Computer objects are searched with LDAP under each ou in --ous. The mapping file is a csv with an ldap attribute (e.g., operatingSystem, location, description, extensionAttribute1) or pseudo attribute in the first column and the illumio label key in the second column. A header of attribute,label_key is optional.
+
+Pseudo attributes from the ou path (for CN=web01,OU=Web,OU=Prod,OU=Servers,DC=corp,DC=local):
+- ad:ou1 is the ou containing the computer (Web). ad:ou2 is its parent (Prod).
+- ad:ou-top1 is the top level ou (Servers). ad:ou-top2 is the next level (Prod).
+- ad:ou-path is the full ou path (Servers/Prod/Web).
+- ad:domain is the domain (corp.local).
+
+Example mapping file:
++-------------------+-----------+
+|     attribute     | label_key |
++-------------------+-----------+
+| ad:ou1            | app       |
+| ad:ou2            | env       |
+| location          | loc       |
++-------------------+-----------+
+
+Computers are matched to PCE workloads by dNSHostName or cn (full or short name, case insensitive) and labeled using wkld-import. Computers that do not match are unmanaged workloads with the objectGUID as the external data reference unless --no-umwl is set. Unmanaged workload ip addresses are resolved from the dNSHostName.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if ldapServer == "" || baseDNs == "" {
+			utils.LogError("--ldap-server and --ous are required")
+		}
+		if bindUser == "" {
+			bindUser = os.Getenv("AD_BIND_USER")
+		}
+		if bindPassword == "" {
+			bindPassword = os.Getenv("AD_BIND_PASSWORD")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		adSync()
+	},
+}
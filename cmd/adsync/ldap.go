@@ -0,0 +1,91 @@
+package adsync
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// computer is an active directory computer object
+type computer struct {
+	dn          string
+	cn          string
+	dnsHostName string
+	guid        string
+	attributes  map[string]string // LDAP attributes and ad: pseudo attributes
+}
+
+// searchComputers binds to the directory and returns the computer objects under each base dn
+func searchComputers(baseDNs []string, attributes []string) ([]computer, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	conn, err := ldap.DialURL(ldapServer, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if startTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Bind(bindUser, bindPassword); err != nil {
+		return nil, err
+	}
+
+	attributes = append([]string{"cn", "dNSHostName", "objectGUID"}, attributes...)
+	computers := []computer{}
+	for _, baseDN := range baseDNs {
+		req := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, ldapFilter, attributes, nil)
+		result, err := conn.SearchWithPaging(req, 500)
+		if err != nil {
+			return nil, fmt.Errorf("searching %s - %s", baseDN, err)
+		}
+		for _, e := range result.Entries {
+			c := computer{dn: e.DN, cn: e.GetAttributeValue("cn"), dnsHostName: e.GetAttributeValue("dNSHostName"), guid: hex.EncodeToString(e.GetRawAttributeValue("objectGUID")), attributes: make(map[string]string)}
+			for _, a := range e.Attributes {
+				if a.Name != "objectGUID" && len(a.Values) > 0 {
+					c.attributes[a.Name] = strings.Join(a.Values, ";")
+				}
+			}
+			for k, v := range ouAttributes(e.DN) {
+				c.attributes[k] = v
+			}
+			computers = append(computers, c)
+		}
+	}
+	return computers, nil
+}
+
+// ouAttributes returns the ad: pseudo attributes from the ou path of a dn.
+// ad:ou1 is the ou containing the object, ad:ou2 is its parent, etc. ad:ou-top1 is the top level ou, ad:ou-top2 is the next, etc.
+// ad:ou-path is the ou path from the top (e.g., Servers/Prod/Web) and ad:domain is the dns domain from the dc components.
+func ouAttributes(dn string) map[string]string {
+	attr := make(map[string]string)
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return attr
+	}
+	ous, dcs := []string{}, []string{}
+	for _, rdn := range parsed.RDNs {
+		for _, a := range rdn.Attributes {
+			switch strings.ToUpper(a.Type) {
+			case "OU":
+				ous = append(ous, a.Value)
+			case "DC":
+				dcs = append(dcs, a.Value)
+			}
+		}
+	}
+	path := []string{}
+	for i, ou := range ous {
+		attr[fmt.Sprintf("ad:ou%d", i+1)] = ou
+		attr[fmt.Sprintf("ad:ou-top%d", i+1)] = ous[len(ous)-1-i]
+		path = append([]string{ou}, path...)
+	}
+	attr["ad:ou-path"] = strings.Join(path, "/")
+	attr["ad:domain"] = strings.Join(dcs, ".")
+	return attr
+}
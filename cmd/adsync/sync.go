@@ -0,0 +1,155 @@
+package adsync
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+func adSync() {
+
+	utils.LogStartCommand("ad-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Search for the computers requesting the ldap attributes in the mapping file
+	ldapAttributes := []string{}
+	for a := range mapping {
+		if !strings.HasPrefix(a, "ad:") {
+			ldapAttributes = append(ldapAttributes, a)
+		}
+	}
+	ous := []string{}
+	for _, ou := range strings.Split(baseDNs, ";") {
+		if strings.TrimSpace(ou) != "" {
+			ous = append(ous, strings.TrimSpace(ou))
+		}
+	}
+	computers, err := searchComputers(ous, ldapAttributes)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d computer objects found in %d ous", len(computers), len(ous)), true)
+
+	// Get the workloads
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	wkldMap := make(map[string]string)
+	for _, w := range wklds {
+		// Skip unmanaged workloads ad-sync creates so they are not labeled by hostname
+		if utils.PtrToStr(w.ExternalDataSet) == externalDataSet {
+			continue
+		}
+		wkldMap[strings.ToLower(w.Hostname)] = w.Hostname
+		wkldMap[strings.ToLower(strings.Split(w.Hostname, ".")[0])] = w.Hostname
+	}
+
+	// Get the label keys in the mapping for the wkld-import header
+	labelKeys := []string{}
+	for _, k := range mapping {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	labelData := [][]string{append([]string{wkldexport.HeaderHostname}, labelKeys...)}
+
+	workloads := []umwlsync.Workload{}
+	for _, c := range computers {
+		labels := umwlsync.MapLabels(c.attributes, mapping)
+
+		// Label the workload if it exists
+		pceHostname, matched := "", false
+		for _, h := range []string{c.dnsHostName, c.cn} {
+			if h == "" {
+				continue
+			}
+			if pceHostname, matched = wkldMap[strings.ToLower(h)]; matched {
+				break
+			}
+			if pceHostname, matched = wkldMap[strings.ToLower(strings.Split(h, ".")[0])]; matched {
+				break
+			}
+		}
+		if matched {
+			row := []string{pceHostname}
+			for _, k := range labelKeys {
+				row = append(row, labels[k])
+			}
+			labelData = append(labelData, row)
+			continue
+		}
+		if noUMWL {
+			continue
+		}
+
+		// Build the unmanaged workload
+		hostname := c.dnsHostName
+		if hostname == "" {
+			hostname = c.cn
+		}
+		w := umwlsync.Workload{Hostname: hostname, Name: c.cn, Description: "active directory computer " + c.dn, Labels: labels, ExternalDataReference: c.guid}
+		if !noDNS {
+			ips, err := net.LookupIP(hostname)
+			if err != nil {
+				utils.LogInfo(fmt.Sprintf("%s - dns lookup failed - %s", hostname, err), false)
+			}
+			for n, ip := range ips {
+				if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+					continue
+				}
+				w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s", n, ip.String()))
+			}
+		}
+		workloads = append(workloads, w)
+	}
+
+	// Label the matching workloads
+	utils.LogInfo(fmt.Sprintf("%d computers match workloads", len(labelData)-1), true)
+	if len(labelData) > 1 && len(labelKeys) > 0 {
+		labelFile := fmt.Sprintf("workloader-ad-sync-labels-%s.csv", time.Now().Format("20060102_150405"))
+		if outputFileName != "" {
+			labelFile = "labels-" + outputFileName
+		}
+		utils.WriteOutput(labelData, labelData, labelFile)
+		wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+			PCE:             pce,
+			Data:            labelData,
+			MatchString:     "hostname",
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			UpdateWorkloads: true,
+		})
+	}
+
+	// Sync the unmanaged workloads
+	if !noUMWL {
+		umwlsync.Sync(umwlsync.Input{
+			PCE:             pce,
+			Command:         "ad-sync",
+			ExternalDataSet: externalDataSet,
+			Workloads:       workloads,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			OutputFileName:  outputFileName,
+		})
+	}
+
+	utils.LogEndCommand("ad-sync")
+}
@@ -18,6 +18,8 @@ workloader all-pces wkld-import file.csv --update-pce --no-prompt --umwl
 
 # Example to import ip lists to all PCEs
 workloader all-pces ipl-import iplists.csv --update-pce --no-prompt --provision
+
+Use the --all-pces or --pce-group flag on any command to run it on the PCEs concurrently with the output prefixed by PCE and a summary of the results.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Just a place holder function for help menu
@@ -46,3 +48,40 @@ workloader target-pces pces.csv ipl-import iplists.csv --update-pce --no-prompt
 		// Logic is processed in main.go
 	},
 }
+
+// AllOrgsCmd runs a command on all orgs of a PCE
+var AllOrgsCmd = &cobra.Command{
+	Use:   "all-orgs",
+	Short: "Run a workloader command on all orgs of a PCE in your pce.yaml file.",
+	Long: `
+Run a workloader command on all orgs of a PCE in your pce.yaml file.
+
+Prepend the all-orgs command to any workloader command to run it on each org in the orgs list of the PCE entry. Use the --pce flag in the command to target a PCE other than the default.
+
+Orgs are defined in the PCE entry in pce.yaml. The name is optional and can be used with the --org flag instead of the org id. A blank user or key uses the user and key of the PCE entry:
+
+mssp-pce:
+  fqdn: pce.example.com
+  port: 8443
+  org: 1
+  user: api_xxxxx
+  key: xxxxxx
+  orgs:
+    - org: 2
+      name: customer-a
+    - org: 3
+      name: customer-b
+      user: api_yyyyy
+      key: yyyyyy
+
+# Example to export workloads from every org of the default PCE:
+workloader all-orgs wkld-export
+
+# Example to run a command on a single org:
+workloader wkld-export --pce mssp-pce --org customer-a
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Just a place holder function for help menu
+		// Logic is processed in main.go
+	},
+}
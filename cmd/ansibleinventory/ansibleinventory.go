@@ -0,0 +1,247 @@
+package ansibleinventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+// Declare local global variables
+var pce illumioapi.PCE
+var err error
+var labelKeys, host, outputFileName string
+var list, managedOnly, onlineOnly bool
+
+func init() {
+	AnsibleInventoryCmd.Flags().BoolVar(&list, "list", false, "write the inventory to stdout. used when workloader is called by an ansible inventory script.")
+	AnsibleInventoryCmd.Flags().StringVar(&host, "host", "", "write the host variables for one host to stdout. used when workloader is called by an ansible inventory script.")
+	AnsibleInventoryCmd.Flags().StringVar(&labelKeys, "label-keys", "", "comma-separated list of label keys to create groups for. default is all label keys.")
+	AnsibleInventoryCmd.Flags().BoolVarP(&managedOnly, "managed-only", "m", false, "only include managed workloads.")
+	AnsibleInventoryCmd.Flags().BoolVarP(&onlineOnly, "online-only", "o", false, "only include online workloads.")
+	AnsibleInventoryCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	AnsibleInventoryCmd.Flags().SortFlags = false
+}
+
+// AnsibleInventoryCmd runs the ansible-inventory command
+var AnsibleInventoryCmd = &cobra.Command{
+	Use:   "ansible-inventory",
+	Short: "Create an Ansible dynamic inventory with workloads grouped by label.",
+	Long: `
+Create an Ansible dynamic inventory with workloads grouped by label.
+
+Each label is a group named key_value (e.g., app_payments and env_prod) and each label key is a parent group of its values (e.g., app has children app_payments and app_erp). Workloads are also in the illumio_managed or illumio_unmanaged group. Characters that are not valid in ansible group names are replaced with an underscore.
+
+Workloads are identified by hostname (or name if there is no hostname). Host variables:
+- ansible_host: the ip address on the interface with the default gateway or the first interface
+- illumio_href, illumio_labels, illumio_interfaces, illumio_managed, illumio_online
+- illumio_enforcement_mode, illumio_visibility_level, illumio_ven_version, illumio_ven_status
+
+By default the inventory is written to a json file that can be used as a static inventory. To use workloader as a dynamic inventory, create an executable script that runs workloader ansible-inventory "$@" and pass it to ansible with -i. Ansible calls the script with --list or --host.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		ansibleInventory()
+	},
+}
+
+// inventory is the ansible dynamic inventory format
+type inventory map[string]interface{}
+
+type group struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+type iface struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	CIDR    *int   `json:"cidr_block,omitempty"`
+}
+
+var invalidGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// groupName returns a valid ansible group name
+func groupName(parts ...string) string {
+	return invalidGroupChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+func ansibleInventory() {
+
+	// Log command execution
+	utils.LogStartCommand("ansible-inventory")
+
+	// Get the workloads
+	qp := map[string]string{}
+	if managedOnly {
+		qp["managed"] = "true"
+	}
+	if onlineOnly {
+		qp["online"] = "true"
+	}
+	wklds, api, err := pce.GetWklds(qp)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	keyFilter := make(map[string]bool)
+	if labelKeys != "" {
+		for _, k := range strings.Split(strings.ReplaceAll(labelKeys, " ", ""), ",") {
+			keyFilter[k] = true
+		}
+	}
+
+	groups := make(map[string]*group)
+	addHost := func(g, h string) {
+		if _, ok := groups[g]; !ok {
+			groups[g] = &group{}
+		}
+		groups[g].Hosts = append(groups[g].Hosts, h)
+	}
+	children := make(map[string]map[string]bool)
+	hostVars := make(map[string]map[string]interface{})
+
+	for _, w := range wklds {
+		h := w.Hostname
+		if h == "" {
+			h = w.Name
+		}
+		if h == "" {
+			utils.LogWarning(fmt.Sprintf("%s does not have a hostname or name. skipping.", w.Href), false)
+			continue
+		}
+		if _, ok := hostVars[h]; ok {
+			utils.LogWarning(fmt.Sprintf("%s is a duplicate hostname. skipping %s.", h, w.Href), false)
+			continue
+		}
+
+		// Labels
+		labels := make(map[string]string)
+		if w.Labels != nil {
+			for _, l := range *w.Labels {
+				label := pce.Labels[l.Href]
+				labels[label.Key] = label.Value
+				if len(keyFilter) > 0 && !keyFilter[label.Key] {
+					continue
+				}
+				g := groupName(label.Key, label.Value)
+				addHost(g, h)
+				if children[groupName(label.Key)] == nil {
+					children[groupName(label.Key)] = make(map[string]bool)
+				}
+				children[groupName(label.Key)][g] = true
+			}
+		}
+
+		// Host vars
+		interfaces := []iface{}
+		for _, i := range w.Interfaces {
+			interfaces = append(interfaces, iface{Name: i.Name, Address: i.Address, CIDR: i.CidrBlock})
+		}
+		ansibleHost := w.GetIPWithDefaultGW()
+		if (ansibleHost == "" || ansibleHost == "NA") && len(w.Interfaces) > 0 {
+			ansibleHost = w.Interfaces[0].Address
+		}
+		managed := w.GetMode() != "unmanaged"
+		vars := map[string]interface{}{
+			"illumio_href":             w.Href,
+			"illumio_labels":           labels,
+			"illumio_interfaces":       interfaces,
+			"illumio_managed":          managed,
+			"illumio_online":           w.Online,
+			"illumio_enforcement_mode": w.EnforcementMode,
+			"illumio_visibility_level": w.GetVisibilityLevel(),
+		}
+		if ansibleHost != "" && ansibleHost != "NA" {
+			vars["ansible_host"] = ansibleHost
+		}
+		if w.Agent != nil && w.Agent.Status != nil {
+			vars["illumio_ven_version"] = w.Agent.Status.AgentVersion
+			vars["illumio_ven_status"] = w.Agent.Status.Status
+		}
+		hostVars[h] = vars
+		if managed {
+			addHost("illumio_managed", h)
+		} else {
+			addHost("illumio_unmanaged", h)
+		}
+	}
+
+	// Host mode returns the host vars for one host
+	if host != "" {
+		vars, ok := hostVars[host]
+		if !ok {
+			vars = map[string]interface{}{}
+		}
+		writeJSON(vars, "")
+		return
+	}
+
+	// Build the inventory
+	inv := inventory{"_meta": map[string]interface{}{"hostvars": hostVars}}
+	for parent, c := range children {
+		for child := range c {
+			groups[parent] = addChild(groups[parent], child)
+		}
+	}
+	allChildren := []string{}
+	for name, g := range groups {
+		sort.Strings(g.Hosts)
+		sort.Strings(g.Children)
+		inv[name] = g
+		allChildren = append(allChildren, name)
+	}
+	sort.Strings(allChildren)
+	inv["all"] = group{Children: allChildren}
+
+	if list {
+		writeJSON(inv, "")
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-ansible-inventory-%s.json", time.Now().Format("20060102_150405"))
+	}
+	writeJSON(inv, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d hosts in %d groups written to %s", len(hostVars), len(groups), outputFileName), true)
+	utils.LogEndCommand("ansible-inventory")
+}
+
+// addChild adds a child group to a group, creating it if needed
+func addChild(g *group, child string) *group {
+	if g == nil {
+		g = &group{}
+	}
+	g.Children = append(g.Children, child)
+	return g
+}
+
+// writeJSON writes the data to the file or stdout if the file name is blank.
+// Nothing else is written to stdout so ansible can parse the output.
+func writeJSON(data interface{}, fileName string) {
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if fileName == "" {
+		fmt.Println(string(out))
+		utils.LogInfo("ansible-inventory completed", false)
+		return
+	}
+	if err := os.WriteFile(fileName, out, 0644); err != nil {
+		utils.LogError(err.Error())
+	}
+}
@@ -0,0 +1,232 @@
+package apply
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/iplimport"
+	"github.com/brian1917/workloader/cmd/labelimport"
+	"github.com/brian1917/workloader/cmd/ruleimport"
+	"github.com/brian1917/workloader/cmd/rulesetimport"
+	"github.com/brian1917/workloader/cmd/svcimport"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var directory, provisionComment string
+var provision, updatePCE, noPrompt bool
+var err error
+
+// objectTypes are applied in order so dependencies exist before the objects that reference them
+var objectTypes = []string{"labels", "services", "iplists", "rulesets", "rules"}
+
+func init() {
+	ApplyCmd.Flags().StringVarP(&directory, "dir", "d", "", "directory with the desired state object definitions.")
+	ApplyCmd.Flags().BoolVar(&provision, "provision", false, "provision changes to services, ip lists, rulesets, and rules.")
+	ApplyCmd.Flags().StringVar(&provisionComment, "provision-comment", "workloader apply", "comment for when provisioning changes.")
+	ApplyCmd.MarkFlagRequired("dir")
+	ApplyCmd.Flags().SortFlags = false
+}
+
+// ApplyCmd runs the apply command
+var ApplyCmd = &cobra.Command{
+	Use:         "apply",
+	Short:       "Apply a directory of policy object definitions to the PCE as desired state.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapObjectWrite + ";" + utils.CapRulesetWrite},
+	Long: `
+Apply a directory of policy object definitions to the PCE as desired state.
+
+The directory is typically a git repository so policy changes go through pull request review and a CI pipeline runs workloader apply. Run without --update-pce in the pull request to log the diff against the PCE and with --update-pce --no-prompt after merge to apply it.
+
+Files are matched by name. The base name must be the object type or end with a period and the object type (e.g., rules.csv or erp.rules.yaml). Object types are applied in the order below and files of the same type are applied in alphabetical order:
+- labels (same format as label-import)
+- services (same format as svc-import)
+- iplists (same format as ipl-import)
+- rulesets (same format as ruleset-import)
+- rules (same format as rule-import)
+
+CSV files use the headers of the import command for the object type. The export commands produce files in the same format.
+
+YAML files are a list of objects with the same keys as the CSV headers. List values are joined with semi-colons. For example:
+- key: app
+  value: erp
+- key: env
+  value: prod
+
+Objects are matched to existing PCE objects by href or name by the import commands. Only changed objects are updated. Labels referenced in rulesets and rules are created if they do not exist. Objects in the PCE that are not in the directory are not deleted.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		// Get the viper values
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		apply()
+	},
+}
+
+func apply() {
+
+	utils.LogStartCommand("apply")
+
+	files, err := findFiles(directory)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Create a temporary directory for yaml files converted to csv
+	tmpDir, err := os.MkdirTemp("", "workloader-apply")
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	defer os.RemoveAll(tmpDir)
+
+	count := 0
+	for _, objectType := range objectTypes {
+		for _, f := range files[objectType] {
+			count++
+			fmt.Printf("\r\n------------------------------------------ %s ------------------------------------------\r\n", strings.ToUpper(objectType))
+			utils.LogInfo(fmt.Sprintf("applying %s", f), true)
+
+			// Convert yaml to csv
+			csvFile := f
+			if ext := strings.ToLower(filepath.Ext(f)); ext == ".yaml" || ext == ".yml" {
+				csvFile = filepath.Join(tmpDir, strconv.Itoa(count)+".csv")
+				if err := yamlToCSV(f, csvFile); err != nil {
+					utils.LogError(fmt.Sprintf("%s - %s", f, err.Error()))
+				}
+			}
+
+			switch objectType {
+			case "labels":
+				labelimport.ImportLabels(pce, csvFile, updatePCE, noPrompt)
+			case "services":
+				data, err := utils.ParseCSV(csvFile)
+				if err != nil {
+					utils.LogError(err.Error())
+				}
+				svcimport.ImportServices(svcimport.Input{PCE: pce, Data: data, UpdatePCE: updatePCE, NoPrompt: noPrompt, Provision: provision, UpdateOnName: true})
+			case "iplists":
+				iplimport.ImportIPLists(pce, csvFile, updatePCE, noPrompt, false, provision)
+			case "rulesets":
+				rulesetimport.ImportRuleSetsFromCSV(rulesetimport.Input{PCE: pce, ImportFile: csvFile, UpdatePCE: updatePCE, NoPrompt: noPrompt, Provision: provision, ProvisionComment: provisionComment, CreateLabels: true})
+			case "rules":
+				ruleimport.ImportRulesFromCSV(ruleimport.Input{PCE: pce, ImportFile: csvFile, UpdatePCE: updatePCE, NoPrompt: noPrompt, Provision: provision, ProvisionComment: provisionComment, CreateLabels: true})
+			}
+		}
+	}
+
+	if count == 0 {
+		utils.LogInfo(fmt.Sprintf("no object definition files found in %s", directory), true)
+	} else {
+		utils.LogInfo(fmt.Sprintf("processed %d object definition files from %s", count, directory), true)
+	}
+
+	utils.LogEndCommand("apply")
+}
+
+// findFiles returns the object definition files in a directory keyed by object type
+func findFiles(dir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if ext != ".csv" && ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		base := strings.ToLower(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
+		for _, t := range objectTypes {
+			if base == t || strings.HasSuffix(base, "."+t) {
+				files[t] = append(files[t], filepath.Join(dir, e.Name()))
+				break
+			}
+		}
+	}
+	for t := range files {
+		sort.Strings(files[t])
+	}
+	return files, nil
+}
+
+// yamlToCSV converts a yaml list of objects to a csv file. The headers are the union of the object keys.
+func yamlToCSV(yamlFile, csvFile string) error {
+	b, err := os.ReadFile(yamlFile)
+	if err != nil {
+		return err
+	}
+	objects := []map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &objects); err != nil {
+		return err
+	}
+
+	headers := []string{}
+	headerMap := make(map[string]bool)
+	for _, o := range objects {
+		for k := range o {
+			if !headerMap[k] {
+				headerMap[k] = true
+				headers = append(headers, k)
+			}
+		}
+	}
+	sort.Strings(headers)
+
+	data := [][]string{headers}
+	for _, o := range objects {
+		row := []string{}
+		for _, h := range headers {
+			row = append(row, yamlValue(o[h]))
+		}
+		data = append(data, row)
+	}
+
+	f, err := os.Create(csvFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// yamlValue converts a yaml value to a csv cell. Lists are joined with semi-colons.
+func yamlValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		s := []string{}
+		for _, i := range t {
+			s = append(s, yamlValue(i))
+		}
+		return strings.Join(s, ";")
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var approvalFile string
+
+// approvalFlags are removed from the arguments for the review run
+var approvalFlags = []string{"update-pce", "no-prompt", "approval-file", "snow-ticket", "snow-wait-approval", "snow-approval-timeout", "result-file", "email-to", "notify", "output-file", "output-dir"}
+
+// startApprovalReview makes the command a review run that writes the approval file. The command takes the --update-pce path without a prompt
+// and every change is simulated so the approval file has the exact requests a run with --update-pce will send.
+func startApprovalReview(cmd *cobra.Command) {
+	if cmd.Annotations[utils.AnnotationSimulate] != "true" {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--approval-file cannot be used with %s", cmd.Name()))
+	}
+	if utils.ReadOnly() {
+		utils.LogErrorCode(utils.ExitValidation, "--approval-file cannot be used in read-only mode")
+	}
+	viper.Set("update_pce", true)
+	viper.Set("no_prompt", true)
+	viper.Set("simulate", true)
+	viper.Set("approval_review", true)
+	utils.LogInfo(fmt.Sprintf("reviewing the changes of %s for %s. changes are simulated and not sent to the pce.", cmd.Name(), approvalFile), true)
+}
+
+// checkApproval runs the command as a review run and continues without a prompt if the changes match the approval file.
+// The command stops if the changes are different.
+func checkApproval(cmd *cobra.Command, args []string) {
+	approved, err := utils.ReadApprovalFile(approvalFile)
+	if err != nil {
+		utils.LogErrorCode(utils.ExitValidation, err.Error())
+	}
+	if approved.Command != cmd.Name() {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is an approval for %s, not %s", approvalFile, approved.Command, cmd.Name()))
+	}
+
+	utils.LogInfo(fmt.Sprintf("reviewing the changes of %s to compare to %s", cmd.Name(), approvalFile), true)
+	current, err := reviewChanges(cmd, args)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("review run failed - %s", err))
+	}
+	if current.PCE != approved.PCE {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is an approval for the %s pce, not %s", approvalFile, approved.PCE, current.PCE))
+	}
+	if current.Hash != approved.Hash {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("the changes do not match %s. %d changes were approved and %d changes are planned now. review the changes again with --approval-file and without --update-pce.", approvalFile, len(approved.Changes), len(current.Changes)))
+	}
+	utils.LogInfo(fmt.Sprintf("the %d changes match %s (%s). running without a prompt.", len(current.Changes), approvalFile, current.Hash), true)
+	viper.Set("no_prompt", true)
+}
+
+// reviewChanges runs the command without --update-pce and with a temporary approval file and returns the approval
+func reviewChanges(cmd *cobra.Command, cmdArgs []string) (utils.Approval, error) {
+	dir, err := os.MkdirTemp("", "workloader-approval-")
+	if err != nil {
+		return utils.Approval{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	reviewFile := filepath.Join(dir, "approval.json")
+	args := rerunArgs(cmd, cmdArgs, approvalFlags, "--approval-file", reviewFile)
+
+	// The review run writes its output files and log in the temporary directory and reads input files from the working directory
+	c, cleanup, err := utils.WorkloaderCommand(context.Background(), dir, map[string]interface{}{"output_dir": dir}, args...)
+	if err != nil {
+		return utils.Approval{}, err
+	}
+	defer cleanup()
+	if c.Dir, err = os.Getwd(); err != nil {
+		return utils.Approval{}, err
+	}
+	c.Env = append(c.Env, utils.OutputEncryptionEnv()...)
+	if output, err := c.CombinedOutput(); err != nil && !utils.PartialFailure(err) {
+		return utils.Approval{}, fmt.Errorf("%s - %s", err, strings.TrimSpace(string(output)))
+	}
+	return utils.ReadApprovalFile(reviewFile)
+}
+
+// rerunArgs returns the arguments to run a command again in a new process without the skipped flags and with the added flags. The arguments
+// are built from the parsed flags so short names and values in the next argument are removed with their flags.
+func rerunArgs(cmd *cobra.Command, args, skip []string, flags ...string) []string {
+	skipped := make(map[string]bool)
+	for _, s := range skip {
+		skipped[s] = true
+	}
+	rerun := strings.Fields(cmd.CommandPath())[1:]
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if skipped[f.Name] {
+			return
+		}
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range s.GetSlice() {
+				rerun = append(rerun, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		rerun = append(rerun, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	rerun = append(rerun, flags...)
+	if len(args) == 0 {
+		return rerun
+	}
+	return append(append(rerun, "--"), args...)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestRerunArgs checks skipped flags are removed in their short, separate value, and equals forms and the other flags and args are kept
+func TestRerunArgs(t *testing.T) {
+	root := &cobra.Command{Use: "workloader"}
+	root.PersistentFlags().Bool("update-pce", false, "")
+	root.PersistentFlags().Bool("no-prompt", false, "")
+	root.PersistentFlags().String("approval-file", "", "")
+	root.PersistentFlags().String("pce", "", "")
+	var gotArgs []string
+	var got *cobra.Command
+	c := &cobra.Command{Use: "wkld-import", Run: func(cmd *cobra.Command, args []string) { got, gotArgs = cmd, args }}
+	c.Flags().StringP("output-file", "o", "", "")
+	c.Flags().BoolP("umwl", "u", false, "")
+	root.AddCommand(c)
+
+	root.SetArgs([]string{"wkld-import", "wklds.csv", "--update-pce", "--approval-file", "approved.json", "-o", "out.csv", "-u", "--pce=prod", "--no-prompt"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	args := rerunArgs(got, gotArgs, []string{"update-pce", "no-prompt", "approval-file", "output-file"}, "--approval-file", "review.json")
+	want := []string{"wkld-import", "--pce=prod", "--umwl=true", "--approval-file", "review.json", "--", "wklds.csv"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args are %v, want %v", args, want)
+	}
+}
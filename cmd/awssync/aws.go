@@ -0,0 +1,276 @@
+package awssync
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// awsCreds are the credentials used to sign requests
+type awsCreds struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ec2Instance is the subset of the DescribeInstances response used by aws-sync
+type ec2Instance struct {
+	InstanceID       string `xml:"instanceId"`
+	State            string `xml:"instanceState>name"`
+	PrivateDNSName   string `xml:"privateDnsName"`
+	PrivateIPAddress string `xml:"privateIpAddress"`
+	PublicIPAddress  string `xml:"ipAddress"`
+	VpcID            string `xml:"vpcId"`
+	SubnetID         string `xml:"subnetId"`
+	AvailabilityZone string `xml:"placement>availabilityZone"`
+	Platform         string `xml:"platformDetails"`
+	Tags             []struct {
+		Key   string `xml:"key"`
+		Value string `xml:"value"`
+	} `xml:"tagSet>item"`
+	NetworkInterfaces []struct {
+		DeviceIndex        int `xml:"attachment>deviceIndex"`
+		PrivateIPAddresses []struct {
+			PrivateIPAddress string `xml:"privateIpAddress"`
+		} `xml:"privateIpAddressesSet>item"`
+		Ipv6Addresses []struct {
+			Ipv6Address string `xml:"ipv6Address"`
+		} `xml:"ipv6AddressesSet>item"`
+	} `xml:"networkInterfaceSet>item"`
+	OwnerID string `xml:"-"`
+	Region  string `xml:"-"`
+}
+
+type describeInstancesResponse struct {
+	Reservations []struct {
+		OwnerID   string        `xml:"ownerId"`
+		Instances []ec2Instance `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string `xml:"AccessKeyId"`
+		SecretAccessKey string `xml:"SecretAccessKey"`
+		SessionToken    string `xml:"SessionToken"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+type awsErrorResponse struct {
+	Code    string `xml:"Errors>Error>Code"`
+	Message string `xml:"Errors>Error>Message"`
+}
+
+type stsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// loadCreds gets credentials from the environment variables or the shared credentials file
+func loadCreds(profile string) (awsCreds, error) {
+	if profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return awsCreds{AccessKeyID: os.Getenv("AWS_ACCESS_KEY_ID"), SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	credsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCreds{}, err
+		}
+		credsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(credsFile)
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("no aws credentials in environment variables and cannot open %s - %s", credsFile, err)
+	}
+	defer f.Close()
+
+	creds := awsCreds{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(kv[1])
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(kv[1])
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(kv[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCreds{}, err
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCreds{}, fmt.Errorf("profile %s not found or incomplete in %s", profile, credsFile)
+	}
+	return creds, nil
+}
+
+// query sends a signature version 4 signed GET request to an AWS query API and returns the body
+func (c awsCreds) query(service, region, host string, params url.Values) ([]byte, error) {
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	// Canonical query string must use %20 for spaces
+	canonicalQuery := strings.ReplaceAll(params.Encode(), "+", "%20")
+
+	headerNames := []string{"host", "x-amz-date"}
+	headerValues := map[string]string{"host": host, "x-amz-date": amzDate}
+	if c.SessionToken != "" {
+		headerNames = append(headerNames, "x-amz-security-token")
+		headerValues["x-amz-security-token"] = c.SessionToken
+	}
+	canonicalHeaders := ""
+	for _, h := range headerNames {
+		canonicalHeaders = canonicalHeaders + h + ":" + headerValues[h] + "\n"
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+	payloadHash := sha256Hex([]byte{})
+
+	canonicalRequest := strings.Join([]string{"GET", "/", canonicalQuery, canonicalHeaders, signedHeaders, payloadHash}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", dateStamp, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s/?%s", host, canonicalQuery), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-amz-date", amzDate)
+	if c.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.SessionToken)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.AccessKeyID, scope, signedHeaders, signature))
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e awsErrorResponse
+		if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("%s %s - %d - %s - %s", service, region, resp.StatusCode, e.Code, e.Message)
+		}
+		var s stsErrorResponse
+		if xml.Unmarshal(body, &s) == nil && s.Code != "" {
+			return nil, fmt.Errorf("%s %s - %d - %s - %s", service, region, resp.StatusCode, s.Code, s.Message)
+		}
+		return nil, fmt.Errorf("%s %s - %d - %s", service, region, resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+// assumeRole returns temporary credentials for the role
+func (c awsCreds) assumeRole(roleArn, externalID string) (awsCreds, error) {
+	params := url.Values{}
+	params.Set("Action", "AssumeRole")
+	params.Set("Version", "2011-06-15")
+	params.Set("RoleArn", roleArn)
+	params.Set("RoleSessionName", fmt.Sprintf("workloader-aws-sync-%d", time.Now().Unix()))
+	if externalID != "" {
+		params.Set("ExternalId", externalID)
+	}
+	body, err := c.query("sts", "us-east-1", "sts.amazonaws.com", params)
+	if err != nil {
+		return awsCreds{}, err
+	}
+	var resp assumeRoleResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return awsCreds{}, err
+	}
+	return awsCreds{AccessKeyID: resp.Credentials.AccessKeyID, SecretAccessKey: resp.Credentials.SecretAccessKey, SessionToken: resp.Credentials.SessionToken}, nil
+}
+
+// describeInstances returns all instances in a region
+func (c awsCreds) describeInstances(region string) ([]ec2Instance, error) {
+	instances := []ec2Instance{}
+	nextToken := ""
+	for {
+		params := url.Values{}
+		params.Set("Action", "DescribeInstances")
+		params.Set("Version", "2016-11-15")
+		params.Set("MaxResults", "1000")
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		body, err := c.query("ec2", region, fmt.Sprintf("ec2.%s.amazonaws.com", region), params)
+		if err != nil {
+			return nil, err
+		}
+		var resp describeInstancesResponse
+		if err := xml.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Reservations {
+			for _, i := range r.Instances {
+				i.OwnerID = r.OwnerID
+				i.Region = region
+				instances = append(instances, i)
+			}
+		}
+		if resp.NextToken == "" {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return instances, nil
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
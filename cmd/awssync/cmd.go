@@ -0,0 +1,77 @@
+package awssync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var regions, roleArns, externalID, awsProfile, mappingFile, externalDataSet, outputFileName string
+var cleanup, includeStopped, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	AWSSyncCmd.Flags().StringVar(&regions, "regions", "", "comma-separated list of aws regions. default is the AWS_REGION environment variable or us-east-1.")
+	AWSSyncCmd.Flags().StringVar(&roleArns, "role-arns", "", "comma-separated list of iam role arns to assume for multi-account discovery. if blank, only the account of the base credentials is used.")
+	AWSSyncCmd.Flags().StringVar(&externalID, "external-id", "", "external id used when assuming roles.")
+	AWSSyncCmd.Flags().StringVar(&awsProfile, "aws-profile", "", "profile in the aws shared credentials file. default is environment variable credentials, then AWS_PROFILE, then the default profile.")
+	AWSSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: aws tag and label key. see help for pseudo tags.")
+	AWSSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "aws-sync", "external data set used to identify unmanaged workloads managed by aws-sync.")
+	AWSSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set that are no longer in aws.")
+	AWSSyncCmd.Flags().BoolVar(&includeStopped, "include-stopped", false, "include stopped instances. by default only running and pending instances are synced.")
+	AWSSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	AWSSyncCmd.Flags().SortFlags = false
+}
+
+// AWSSyncCmd runs the aws-sync command
+var AWSSyncCmd = &cobra.Command{
+	Use:         "aws-sync",
+	Short:       "Create, update, and delete unmanaged workloads for AWS EC2 instances.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete unmanaged workloads for AWS EC2 instances.
+
+Instances are discovered in each region listed in --regions. For multiple accounts, provide the role arns to assume with --role-arns. The base credentials are also used unless role arns are provided.
+
+Credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables or from the aws shared credentials file.
+
+Each unmanaged workload uses the instance id as the external data reference. The hostname is the Name tag, then the private dns name, then the instance id. All private IPv4 and IPv6 addresses are added as interfaces.
+
+The mapping file is a csv with the aws tag in the first column and the label key in the second column. A header of aws_tag,label_key is optional. In addition to tags, the following pseudo tags can be used: aws:account-id, aws:region, aws:availability-zone, aws:vpc-id, aws:subnet-id, aws:platform.
+
+Example mapping file:
++-----------------------+-----------+
+|        aws_tag        | label_key |
++-----------------------+-----------+
+| Application           | app       |
+| Environment           | env       |
+| aws:region            | loc       |
++-----------------------+-----------+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if regions == "" {
+			regions = os.Getenv("AWS_REGION")
+		}
+		if regions == "" {
+			regions = "us-east-1"
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		awsSync()
+	},
+}
@@ -0,0 +1,123 @@
+package awssync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func awsSync() {
+
+	utils.LogStartCommand("aws-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Get the credentials
+	baseCreds, err := loadCreds(awsProfile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	accountCreds := []awsCreds{baseCreds}
+	if roleArns != "" {
+		accountCreds = []awsCreds{}
+		for _, arn := range strings.Split(strings.ReplaceAll(roleArns, " ", ""), ",") {
+			c, err := baseCreds.assumeRole(arn, externalID)
+			if err != nil {
+				utils.LogError(fmt.Sprintf("assuming role %s - %s", arn, err))
+			}
+			utils.LogInfo(fmt.Sprintf("assumed role %s", arn), false)
+			accountCreds = append(accountCreds, c)
+		}
+	}
+
+	// Discover the instances
+	workloads := []umwlsync.Workload{}
+	for _, c := range accountCreds {
+		for _, region := range strings.Split(strings.ReplaceAll(regions, " ", ""), ",") {
+			instances, err := c.describeInstances(region)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			utils.LogInfo(fmt.Sprintf("%d instances discovered in %s", len(instances), region), true)
+			for _, i := range instances {
+				if i.State != "running" && i.State != "pending" && !includeStopped {
+					utils.LogInfo(fmt.Sprintf("%s is %s. skipping.", i.InstanceID, i.State), false)
+					continue
+				}
+				if i.State == "terminated" || i.State == "shutting-down" {
+					continue
+				}
+				workloads = append(workloads, instanceToWorkload(i, mapping))
+			}
+		}
+	}
+
+	umwlsync.Sync(umwlsync.Input{
+		PCE:             pce,
+		Command:         "aws-sync",
+		ExternalDataSet: externalDataSet,
+		Workloads:       workloads,
+		Cleanup:         cleanup,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		OutputFileName:  outputFileName,
+	})
+
+	utils.LogEndCommand("aws-sync")
+}
+
+// instanceToWorkload converts an ec2 instance to an unmanaged workload
+func instanceToWorkload(i ec2Instance, mapping map[string]string) umwlsync.Workload {
+
+	attributes := map[string]string{
+		"aws:account-id":        i.OwnerID,
+		"aws:region":            i.Region,
+		"aws:availability-zone": i.AvailabilityZone,
+		"aws:vpc-id":            i.VpcID,
+		"aws:subnet-id":         i.SubnetID,
+		"aws:platform":          i.Platform,
+	}
+	for _, t := range i.Tags {
+		attributes[t.Key] = t.Value
+	}
+
+	w := umwlsync.Workload{
+		Hostname:              attributes["Name"],
+		Name:                  attributes["Name"],
+		PublicIP:              i.PublicIPAddress,
+		Description:           fmt.Sprintf("aws instance %s in account %s", i.InstanceID, i.OwnerID),
+		DataCenter:            i.AvailabilityZone,
+		Labels:                umwlsync.MapLabels(attributes, mapping),
+		ExternalDataReference: i.InstanceID,
+	}
+	if w.Hostname == "" {
+		w.Hostname = i.PrivateDNSName
+	}
+	if w.Hostname == "" {
+		w.Hostname = i.InstanceID
+	}
+
+	// Interfaces - use the device index as the interface name
+	for _, n := range i.NetworkInterfaces {
+		for _, ip := range n.PrivateIPAddresses {
+			w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s", n.DeviceIndex, ip.PrivateIPAddress))
+		}
+		for _, ip := range n.Ipv6Addresses {
+			w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s", n.DeviceIndex, ip.Ipv6Address))
+		}
+	}
+	if len(w.Interfaces) == 0 && i.PrivateIPAddress != "" {
+		w.Interfaces = append(w.Interfaces, "eth0:"+i.PrivateIPAddress)
+	}
+
+	return w
+}
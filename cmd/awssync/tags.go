@@ -0,0 +1,67 @@
+package awssync
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TagWriter creates tags on ec2 instances with the aws-sync credentials. It is used by label-writeback.
+type TagWriter struct {
+	base  awsCreds
+	roles map[string]awsCreds // account id to assumed role credentials
+}
+
+// NewTagWriter loads the credentials and assumes each role in the comma-separated role arns.
+func NewTagWriter(profile, roleArns, externalID string) (*TagWriter, error) {
+	base, err := loadCreds(profile)
+	if err != nil {
+		return nil, err
+	}
+	t := &TagWriter{base: base, roles: make(map[string]awsCreds)}
+	for _, arn := range strings.Split(strings.ReplaceAll(roleArns, " ", ""), ",") {
+		if arn == "" {
+			continue
+		}
+		// arn:aws:iam::123456789012:role/name
+		parts := strings.Split(arn, ":")
+		if len(parts) < 6 {
+			return nil, fmt.Errorf("%s is not a valid role arn", arn)
+		}
+		c, err := base.assumeRole(arn, externalID)
+		if err != nil {
+			return nil, fmt.Errorf("assuming role %s - %s", arn, err)
+		}
+		t.roles[parts[4]] = c
+	}
+	return t, nil
+}
+
+// CreateTags adds or overwrites tags on an instance. The assumed role for the account is used if role arns were provided.
+func (t *TagWriter) CreateTags(account, region, instanceID string, tags map[string]string) error {
+	creds := t.base
+	if len(t.roles) > 0 {
+		c, ok := t.roles[account]
+		if !ok {
+			return fmt.Errorf("no role arn provided for account %s", account)
+		}
+		creds = c
+	}
+
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := url.Values{}
+	params.Set("Action", "CreateTags")
+	params.Set("Version", "2016-11-15")
+	params.Set("ResourceId.1", instanceID)
+	for i, k := range keys {
+		params.Set(fmt.Sprintf("Tag.%d.Key", i+1), k)
+		params.Set(fmt.Sprintf("Tag.%d.Value", i+1), tags[k])
+	}
+	_, err := creds.query("ec2", region, fmt.Sprintf("ec2.%s.amazonaws.com", region), params)
+	return err
+}
@@ -0,0 +1,177 @@
+package azuresync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const armURL = "https://management.azure.com"
+
+// azureClient calls the Azure Resource Manager API with a bearer token
+type azureClient struct {
+	token  string
+	client *http.Client
+}
+
+// azureVM is the subset of a virtual machine or scale set virtual machine used by azure-sync
+type azureVM struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags"`
+	Properties struct {
+		VMID      string `json:"vmId"`
+		OsProfile struct {
+			ComputerName string `json:"computerName"`
+		} `json:"osProfile"`
+		StorageProfile struct {
+			OsDisk struct {
+				OsType string `json:"osType"`
+			} `json:"osDisk"`
+		} `json:"storageProfile"`
+		InstanceView struct {
+			Statuses []struct {
+				Code string `json:"code"`
+			} `json:"statuses"`
+		} `json:"instanceView"`
+	} `json:"properties"`
+}
+
+// azureVMSS is the subset of a virtual machine scale set used by azure-sync
+type azureVMSS struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// azureNIC is the subset of a network interface used by azure-sync
+type azureNIC struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Primary        bool `json:"primary"`
+		VirtualMachine struct {
+			ID string `json:"id"`
+		} `json:"virtualMachine"`
+		IPConfigurations []struct {
+			Properties struct {
+				PrivateIPAddress string `json:"privateIPAddress"`
+				PublicIPAddress  struct {
+					ID string `json:"id"`
+				} `json:"publicIPAddress"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+// azurePublicIP is the subset of a public ip address used by azure-sync
+type azurePublicIP struct {
+	ID         string `json:"id"`
+	Properties struct {
+		IPAddress string `json:"ipAddress"`
+	} `json:"properties"`
+}
+
+type azureSubscription struct {
+	SubscriptionID string `json:"subscriptionId"`
+	DisplayName    string `json:"displayName"`
+	State          string `json:"state"`
+}
+
+// newAzureClient gets a token using the client credentials flow
+func newAzureClient(tenantID, clientID, clientSecret string) (azureClient, error) {
+	c := azureClient{client: &http.Client{Timeout: 60 * time.Second}}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("scope", armURL+"/.default")
+	resp, err := c.client.PostForm(fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenantID)), form)
+	if err != nil {
+		return c, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return c, fmt.Errorf("getting azure token - %d - %s", resp.StatusCode, string(body))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return c, err
+	}
+	c.token = token.AccessToken
+	return c, nil
+}
+
+// getAll gets all pages of a list endpoint and unmarshals each item into the slice pointed to by v
+func (c azureClient) getAll(path string, v interface{}) error {
+	items := []json.RawMessage{}
+	next := armURL + path
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s - %d - %s", strings.Split(next, "?")[0], resp.StatusCode, string(body))
+		}
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		items = append(items, page.Value...)
+		next = page.NextLink
+	}
+
+	all, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(all, v)
+}
+
+// powerState returns the power state of the vm from the instance view (e.g., running, deallocated)
+func (vm azureVM) powerState() string {
+	for _, s := range vm.Properties.InstanceView.Statuses {
+		if strings.HasPrefix(s.Code, "PowerState/") {
+			return strings.TrimPrefix(s.Code, "PowerState/")
+		}
+	}
+	return ""
+}
+
+// resourceGroup returns the resource group from an azure resource id
+func resourceGroup(id string) string {
+	parts := strings.Split(id, "/")
+	for i, p := range parts {
+		if strings.EqualFold(p, "resourceGroups") && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package azuresync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var tenantID, clientID, clientSecret, subscriptions, mappingFile, externalDataSet, outputFileName string
+var cleanup, noVMSS, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	AzureSyncCmd.Flags().StringVar(&tenantID, "tenant-id", "", "azure ad tenant id. default is the AZURE_TENANT_ID environment variable.")
+	AzureSyncCmd.Flags().StringVar(&clientID, "client-id", "", "service principal client id. default is the AZURE_CLIENT_ID environment variable.")
+	AzureSyncCmd.Flags().StringVar(&clientSecret, "client-secret", "", "service principal client secret. default is the AZURE_CLIENT_SECRET environment variable.")
+	AzureSyncCmd.Flags().StringVar(&subscriptions, "subscriptions", "", "comma-separated list of subscription ids. if blank, all enabled subscriptions the service principal can access are used.")
+	AzureSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: azure tag and label key. see help for pseudo tags.")
+	AzureSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "azure-sync", "external data set used to identify unmanaged workloads managed by azure-sync.")
+	AzureSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for vms that are deallocated or no longer exist.")
+	AzureSyncCmd.Flags().BoolVar(&noVMSS, "no-vmss", false, "do not include virtual machine scale set instances.")
+	AzureSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	AzureSyncCmd.Flags().SortFlags = false
+}
+
+// AzureSyncCmd runs the azure-sync command
+var AzureSyncCmd = &cobra.Command{
+	Use:         "azure-sync",
+	Short:       "Create, update, and delete unmanaged workloads for Azure VMs and scale set instances.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete unmanaged workloads for Azure VMs and scale set instances.
+
+Authentication uses a service principal with the Reader role on the subscriptions. The tenant id, client id, and client secret can be provided with flags or the AZURE_TENANT_ID, AZURE_CLIENT_ID, and AZURE_CLIENT_SECRET environment variables.
+
+Each unmanaged workload uses the azure vm id as the external data reference. The hostname is the computer name, then the vm name. The private IPs of all attached NICs are added as interfaces.
+
+VMs that are deallocated or stopped are not synced. With --cleanup (default), their unmanaged workloads are deleted along with those of deleted VMs.
+
+The mapping file is a csv with the azure tag in the first column and the label key in the second column. A header of azure_tag,label_key is optional. Scale set instances use the scale set's tags, overridden by instance tags. In addition to tags, the following pseudo tags can be used: azure:subscription-id, azure:resource-group, azure:location, azure:os-type, azure:vmss.
+
+Example mapping file:
++-----------------------+-----------+
+|       azure_tag       | label_key |
++-----------------------+-----------+
+| application           | app       |
+| environment           | env       |
+| azure:location        | loc       |
++-----------------------+-----------+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		// Use environment variables for blank flags
+		for _, v := range []struct {
+			target *string
+			env    string
+		}{{&tenantID, "AZURE_TENANT_ID"}, {&clientID, "AZURE_CLIENT_ID"}, {&clientSecret, "AZURE_CLIENT_SECRET"}} {
+			if *v.target == "" {
+				*v.target = os.Getenv(v.env)
+			}
+			if *v.target == "" {
+				utils.LogError(v.env + " or its flag must be set")
+			}
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		azureSync()
+	},
+}
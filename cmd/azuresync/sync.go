@@ -0,0 +1,196 @@
+package azuresync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func azureSync() {
+
+	utils.LogStartCommand("azure-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	client, err := newAzureClient(tenantID, clientID, clientSecret)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the subscriptions
+	subIDs := []string{}
+	if subscriptions != "" {
+		subIDs = strings.Split(strings.ReplaceAll(subscriptions, " ", ""), ",")
+	} else {
+		subs := []azureSubscription{}
+		if err := client.getAll("/subscriptions?api-version=2020-01-01", &subs); err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, s := range subs {
+			if s.State == "Enabled" {
+				subIDs = append(subIDs, s.SubscriptionID)
+			}
+		}
+	}
+	utils.LogInfo(fmt.Sprintf("syncing %d subscriptions", len(subIDs)), true)
+
+	workloads := []umwlsync.Workload{}
+	for _, sub := range subIDs {
+		w, err := subscriptionWorkloads(client, sub, mapping)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("subscription %s - %s", sub, err))
+		}
+		workloads = append(workloads, w...)
+	}
+
+	umwlsync.Sync(umwlsync.Input{
+		PCE:             pce,
+		Command:         "azure-sync",
+		ExternalDataSet: externalDataSet,
+		Workloads:       workloads,
+		Cleanup:         cleanup,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		OutputFileName:  outputFileName,
+	})
+
+	utils.LogEndCommand("azure-sync")
+}
+
+// subscriptionWorkloads returns the workloads for the running vms and scale set instances in a subscription
+func subscriptionWorkloads(client azureClient, sub string, mapping map[string]string) ([]umwlsync.Workload, error) {
+
+	// Get the public IPs
+	publicIPs := []azurePublicIP{}
+	if err := client.getAll(fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Network/publicIPAddresses?api-version=2023-05-01", sub), &publicIPs); err != nil {
+		return nil, err
+	}
+	publicIPMap := make(map[string]string)
+	for _, p := range publicIPs {
+		publicIPMap[strings.ToLower(p.ID)] = p.Properties.IPAddress
+	}
+
+	// Get the NICs and map them to vm ids
+	nics := []azureNIC{}
+	if err := client.getAll(fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Network/networkInterfaces?api-version=2023-05-01", sub), &nics); err != nil {
+		return nil, err
+	}
+	nicMap := make(map[string][]azureNIC)
+	for _, n := range nics {
+		nicMap[strings.ToLower(n.Properties.VirtualMachine.ID)] = append(nicMap[strings.ToLower(n.Properties.VirtualMachine.ID)], n)
+	}
+
+	workloads := []umwlsync.Workload{}
+
+	// Virtual machines
+	vms := []azureVM{}
+	if err := client.getAll(fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Compute/virtualMachines?api-version=2023-03-01&statusOnly=true", sub), &vms); err != nil {
+		return nil, err
+	}
+	utils.LogInfo(fmt.Sprintf("%d vms discovered in subscription %s", len(vms), sub), true)
+	for _, vm := range vms {
+		if state := vm.powerState(); state != "running" && state != "starting" {
+			utils.LogInfo(fmt.Sprintf("%s is %s. skipping.", vm.Name, state), false)
+			continue
+		}
+		workloads = append(workloads, vmToWorkload(vm, sub, "", nil, nicMap[strings.ToLower(vm.ID)], publicIPMap, mapping))
+	}
+
+	if noVMSS {
+		return workloads, nil
+	}
+
+	// Scale set instances
+	scaleSets := []azureVMSS{}
+	if err := client.getAll(fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Compute/virtualMachineScaleSets?api-version=2023-03-01", sub), &scaleSets); err != nil {
+		return nil, err
+	}
+	for _, ss := range scaleSets {
+		ssNICs := []azureNIC{}
+		if err := client.getAll(fmt.Sprintf("%s/networkInterfaces?api-version=2018-10-01", ss.ID), &ssNICs); err != nil {
+			return nil, err
+		}
+		ssNICMap := make(map[string][]azureNIC)
+		for _, n := range ssNICs {
+			ssNICMap[strings.ToLower(n.Properties.VirtualMachine.ID)] = append(ssNICMap[strings.ToLower(n.Properties.VirtualMachine.ID)], n)
+		}
+		instances := []azureVM{}
+		if err := client.getAll(fmt.Sprintf("%s/virtualMachines?api-version=2023-03-01&$expand=instanceView", ss.ID), &instances); err != nil {
+			return nil, err
+		}
+		utils.LogInfo(fmt.Sprintf("%d instances discovered in scale set %s", len(instances), ss.Name), true)
+		for _, vm := range instances {
+			if state := vm.powerState(); state != "running" && state != "starting" {
+				utils.LogInfo(fmt.Sprintf("%s is %s. skipping.", vm.Name, state), false)
+				continue
+			}
+			if vm.Location == "" {
+				vm.Location = ss.Location
+			}
+			workloads = append(workloads, vmToWorkload(vm, sub, ss.Name, ss.Tags, ssNICMap[strings.ToLower(vm.ID)], publicIPMap, mapping))
+		}
+	}
+
+	return workloads, nil
+}
+
+// vmToWorkload converts an azure vm to an unmanaged workload
+func vmToWorkload(vm azureVM, sub, vmss string, parentTags map[string]string, nics []azureNIC, publicIPMap map[string]string, mapping map[string]string) umwlsync.Workload {
+
+	attributes := map[string]string{
+		"azure:subscription-id": sub,
+		"azure:resource-group":  resourceGroup(vm.ID),
+		"azure:location":        vm.Location,
+		"azure:os-type":         vm.Properties.StorageProfile.OsDisk.OsType,
+		"azure:vmss":            vmss,
+	}
+	for k, v := range parentTags {
+		attributes[k] = v
+	}
+	for k, v := range vm.Tags {
+		attributes[k] = v
+	}
+
+	w := umwlsync.Workload{
+		Hostname:              vm.Properties.OsProfile.ComputerName,
+		Name:                  vm.Name,
+		Description:           fmt.Sprintf("azure vm %s", vm.ID),
+		DataCenter:            vm.Location,
+		Labels:                umwlsync.MapLabels(attributes, mapping),
+		ExternalDataReference: vm.Properties.VMID,
+	}
+	if w.Hostname == "" {
+		w.Hostname = vm.Name
+	}
+	if w.ExternalDataReference == "" {
+		w.ExternalDataReference = strings.ToLower(vm.ID)
+	}
+
+	// Put the primary nic first
+	for i, n := range nics {
+		if n.Properties.Primary && i != 0 {
+			nics[0], nics[i] = nics[i], nics[0]
+		}
+	}
+	for i, n := range nics {
+		for _, ipConfig := range n.Properties.IPConfigurations {
+			if ipConfig.Properties.PrivateIPAddress != "" {
+				w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s", i, ipConfig.Properties.PrivateIPAddress))
+			}
+			if pub := publicIPMap[strings.ToLower(ipConfig.Properties.PublicIPAddress.ID)]; pub != "" && w.PublicIP == "" {
+				w.PublicIP = pub
+			}
+		}
+	}
+
+	return w
+}
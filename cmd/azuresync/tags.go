@@ -0,0 +1,51 @@
+package azuresync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// TagWriter merges tags on azure resources. It is used by label-writeback and requires the Tag Contributor role.
+type TagWriter struct {
+	c azureClient
+}
+
+// NewTagWriter gets a token using the client credentials flow.
+func NewTagWriter(tenantID, clientID, clientSecret string) (*TagWriter, error) {
+	c, err := newAzureClient(tenantID, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &TagWriter{c: c}, nil
+}
+
+// MergeTags adds or overwrites tags on a resource and keeps its other tags.
+func (t *TagWriter) MergeTags(resourceID string, tags map[string]string) error {
+	payload, err := json.Marshal(map[string]interface{}{"operation": "Merge", "properties": map[string]interface{}{"tags": tags}})
+	if err != nil {
+		return err
+	}
+	reqURL := fmt.Sprintf("%s%s/providers/Microsoft.Resources/tags/default?api-version=2021-04-01", armURL, resourceID)
+	req, err := http.NewRequest("PATCH", reqURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s tags - %d - %s", resourceID, resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package broadrules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Flags
+const (
+	flagAllWorkloadsConsumer = "all_workloads_consumer"
+	flagAllWorkloadsProvider = "all_workloads_provider"
+	flagAnyIPConsumer        = "any_ip_consumer"
+	flagAllServices          = "all_services"
+	flagLargeLabelGroup      = "large_label_group"
+)
+
+// labelSet is label hrefs by key. A workload matches if it has one of the labels of every key.
+type labelSet map[string]map[string]bool
+
+// broadRule is a flagged rule with the workloads it allows
+type broadRule struct {
+	ruleSet              illumioapi.RuleSet
+	rule                 *illumioapi.Rule
+	flags, details       []string
+	consumers, providers int
+	pairs                int
+}
+
+func broadRules() {
+
+	// Load the PCE
+	provisionStatus := "active"
+	if draft {
+		provisionStatus = "draft"
+	}
+	apiResps, err := pce.Load(illumioapi.LoadInput{ProvisionStatus: provisionStatus, Labels: true, LabelGroups: true, IPLists: true, Services: true, RuleSets: true, Workloads: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	workloads := []illumioapi.Workload{}
+	for _, w := range pce.WorkloadsSlice {
+		if w.Deleted == nil || !*w.Deleted {
+			workloads = append(workloads, w)
+		}
+	}
+
+	// Count the workloads of each label group once
+	groupCounts := make(map[string]int)
+	groupCount := func(href string) int {
+		if c, ok := groupCounts[href]; ok {
+			return c
+		}
+		set := make(labelSet)
+		set.addGroup(href)
+		groupCounts[href] = 0
+		for _, w := range workloads {
+			if len(set) == 0 {
+				break
+			}
+			if set.matches(w) {
+				groupCounts[href]++
+			}
+		}
+		return groupCounts[href]
+	}
+
+	// Check the enabled rules of enabled rulesets. The ruleset map is keyed by href and name so only hrefs are used.
+	rules := []broadRule{}
+	checked := 0
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href || rs.Enabled != nil && !*rs.Enabled {
+			continue
+		}
+		for _, r := range rs.Rules {
+			if r == nil || r.Enabled != nil && !*r.Enabled {
+				continue
+			}
+			checked++
+			br := broadRule{ruleSet: rs, rule: r}
+			flag := func(f, detail string) {
+				if !contains(br.flags, f) {
+					br.flags = append(br.flags, f)
+				}
+				br.details = append(br.details, detail)
+			}
+
+			// Flags
+			for _, c := range r.Consumers {
+				if c == nil {
+					continue
+				}
+				if c.Actors == "ams" {
+					flag(flagAllWorkloadsConsumer, "all workloads consumer")
+				}
+				if c.IPList != nil && utils.AnyIP(pce.IPLists[c.IPList.Href]) {
+					flag(flagAnyIPConsumer, fmt.Sprintf("any ip consumer %s", pce.IPLists[c.IPList.Href].Name))
+				}
+				if c.LabelGroup != nil && groupCount(c.LabelGroup.Href) > maxWorkloads {
+					flag(flagLargeLabelGroup, fmt.Sprintf("consumer label group %s has %d workloads", pce.LabelGroups[c.LabelGroup.Href].Name, groupCount(c.LabelGroup.Href)))
+				}
+			}
+			for _, p := range r.Providers {
+				if p == nil {
+					continue
+				}
+				if p.Actors == "ams" {
+					flag(flagAllWorkloadsProvider, "all workloads provider")
+				}
+				if p.LabelGroup != nil && groupCount(p.LabelGroup.Href) > maxWorkloads {
+					flag(flagLargeLabelGroup, fmt.Sprintf("provider label group %s has %d workloads", pce.LabelGroups[p.LabelGroup.Href].Name, groupCount(p.LabelGroup.Href)))
+				}
+			}
+			for _, scope := range rs.Scopes {
+				for _, s := range scope {
+					if s != nil && s.LabelGroup != nil && groupCount(s.LabelGroup.Href) > maxWorkloads {
+						flag(flagLargeLabelGroup, fmt.Sprintf("scope label group %s has %d workloads", pce.LabelGroups[s.LabelGroup.Href].Name, groupCount(s.LabelGroup.Href)))
+					}
+				}
+			}
+			if allServices(r) {
+				flag(flagAllServices, "all services")
+			}
+			if len(br.flags) == 0 {
+				continue
+			}
+
+			// Workload pairs
+			unscoped := r.UnscopedConsumers != nil && *r.UnscopedConsumers
+			consumers, providers := newActors(), newActors()
+			for _, c := range r.Consumers {
+				if c != nil {
+					consumers.add(c.Actors, c.Label, c.LabelGroup, c.Workload)
+				}
+			}
+			for _, p := range r.Providers {
+				if p != nil {
+					providers.add(p.Actors, p.Label, p.LabelGroup, p.Workload)
+				}
+			}
+			both := 0
+			for _, w := range workloads {
+				inScope := inScope(w, rs)
+				isProvider := inScope && providers.matches(w)
+				isConsumer := (inScope || unscoped) && consumers.matches(w)
+				if isProvider {
+					br.providers++
+				}
+				if isConsumer {
+					br.consumers++
+				}
+				if isProvider && isConsumer {
+					both++
+				}
+			}
+			br.pairs = br.consumers*br.providers - both
+			rules = append(rules, br)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].pairs != rules[j].pairs {
+			return rules[i].pairs > rules[j].pairs
+		}
+		return rules[i].rule.Href < rules[j].rule.Href
+	})
+
+	// Build the output
+	data := [][]string{{"ruleset", "ruleset_href", "rule_href", "description", "flags", "consumer_workloads", "provider_workloads", "workload_pairs", "details"}}
+	stdOutData := [][]string{{"ruleset", "rule_href", "flags", "workload_pairs"}}
+	for _, r := range rules {
+		data = append(data, []string{r.ruleSet.Name, r.ruleSet.Href, r.rule.Href, r.rule.Description, strings.Join(r.flags, ";"), fmt.Sprintf("%d", r.consumers), fmt.Sprintf("%d", r.providers), fmt.Sprintf("%d", r.pairs), strings.Join(r.details, "; ")})
+		stdOutData = append(stdOutData, []string{r.ruleSet.Name, r.rule.Href, strings.Join(r.flags, ";"), fmt.Sprintf("%d", r.pairs)})
+	}
+
+	utils.LogInfo(fmt.Sprintf("checked %d rules in the %s policy. %d rules are flagged as broad.", checked, provisionStatus, len(rules)), true)
+	if len(rules) == 0 {
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-broad-rules-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, stdOutData, outputFileName)
+}
+
+// actors are the workloads of the consumers or providers of a rule
+type actors struct {
+	all       bool
+	labels    labelSet
+	workloads map[string]bool
+}
+
+func newActors() actors {
+	return actors{labels: make(labelSet), workloads: make(map[string]bool)}
+}
+
+// add adds the workload actors of a consumer or provider
+func (a *actors) add(actor string, label *illumioapi.Label, labelGroup *illumioapi.LabelGroup, wkld *illumioapi.Workload) {
+	if actor == "ams" {
+		a.all = true
+	}
+	if label != nil {
+		a.labels.add(label.Href)
+	}
+	if labelGroup != nil {
+		a.labels.addGroup(labelGroup.Href)
+	}
+	if wkld != nil {
+		a.workloads[wkld.Href] = true
+	}
+}
+
+// matches returns true if the workload is one of the actors
+func (a actors) matches(w illumioapi.Workload) bool {
+	return a.all || a.workloads[w.Href] || len(a.labels) > 0 && a.labels.matches(w)
+}
+
+// inScope returns true if the workload is in a scope of the ruleset. A ruleset without scopes or with an empty scope is global.
+func inScope(w illumioapi.Workload, rs illumioapi.RuleSet) bool {
+	if len(rs.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range rs.Scopes {
+		set := make(labelSet)
+		for _, s := range scope {
+			if s == nil {
+				continue
+			}
+			if s.Label != nil {
+				set.add(s.Label.Href)
+			}
+			if s.LabelGroup != nil {
+				set.addGroup(s.LabelGroup.Href)
+			}
+		}
+		if set.matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds a label to the set
+func (s labelSet) add(href string) {
+	key := pce.Labels[href].Key
+	if s[key] == nil {
+		s[key] = make(map[string]bool)
+	}
+	s[key][href] = true
+}
+
+// addGroup adds the labels of a label group and its sub groups to the set
+func (s labelSet) addGroup(href string) {
+	for _, l := range pce.ExpandLabelGroup(href) {
+		s.add(l)
+	}
+}
+
+// matches returns true if the workload has one of the labels of every key in the set
+func (s labelSet) matches(w illumioapi.Workload) bool {
+	for key, hrefs := range s {
+		if !hrefs[w.GetLabelByKey(key, pce.Labels).Href] {
+			return false
+		}
+	}
+	return true
+}
+
+// allServices returns true if the rule allows all services
+func allServices(r *illumioapi.Rule) bool {
+	if r.IngressServices == nil {
+		return false
+	}
+	for _, s := range *r.IngressServices {
+		if s == nil {
+			continue
+		}
+		if s.Href != nil {
+			for _, sp := range pce.Services[*s.Href].ServicePorts {
+				if sp != nil && sp.Protocol == -1 {
+					return true
+				}
+			}
+		} else if s.Protocol != nil && *s.Protocol == -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if s is in the slice
+func contains(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
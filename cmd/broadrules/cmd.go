@@ -0,0 +1,61 @@
+package broadrules
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName string
+var draft bool
+var maxWorkloads int
+
+func init() {
+	BroadRulesCmd.Flags().BoolVar(&draft, "draft", false, "check the draft policy instead of the active policy.")
+	BroadRulesCmd.Flags().IntVar(&maxWorkloads, "max-workloads", 100, "flag label groups in a rule or its ruleset scope that expand to more than this many workloads.")
+	BroadRulesCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	BroadRulesCmd.Flags().SortFlags = false
+}
+
+// BroadRulesCmd reports overly broad rules
+var BroadRulesCmd = &cobra.Command{
+	Use:   "broad-rules",
+	Short: "Report overly broad rules with the number of workload pairs each rule allows.",
+	Long: `
+Report enabled rules in enabled rulesets that are overly broad with the number of workload pairs each rule allows.
+
+A rule is flagged for any of the following:
+- all_workloads_consumer: a consumer is all workloads.
+- all_workloads_provider: a provider is all workloads.
+- any_ip_consumer: a consumer is an ip list with 0.0.0.0/0 or ::/0.
+- all_services: a service is all services (protocol -1).
+- large_label_group: a consumer, provider, or ruleset scope has a label group that expands to more than --max-workloads workloads.
+
+The workload pairs of a rule are the consumer workloads times the provider workloads, not counting a workload to itself. Providers and consumers are all workloads, labels, label groups, and workloads in the ruleset scope. Extra-scope consumers are across the pce. Labels of different keys must all match and labels of the same key are any match. Ip lists, virtual services, and virtual servers are not counted as workloads. Managed and unmanaged workloads are counted.
+
+The output is sorted by the number of workload pairs.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report broad rules in the active policy
+  workloader broad-rules
+
+  # Report broad rules in the draft policy and flag label groups with more than 25 workloads
+  workloader broad-rules --draft --max-workloads 25`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if maxWorkloads < 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--max-workloads must be 0 or more")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("broad-rules")
+		broadRules()
+		utils.LogEndCommand("broad-rules")
+	},
+}
@@ -0,0 +1,252 @@
+package certexpiry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Statuses
+const (
+	statusOK           = "ok"
+	statusExpiring     = "expiring"
+	statusExpired      = "expired"
+	statusUntrusted    = "untrusted"
+	statusNeverExpires = "never_expires"
+	statusWarning      = "warning"
+)
+
+// item is a row of the report
+type item struct {
+	itemType, name, href string
+	expires              time.Time
+	status, detail       string
+}
+
+// pairingProfile is a pairing profile. The key lifespan is "unlimited" or seconds, so the illumioapi string field cannot hold it.
+type pairingProfile struct {
+	Href              string      `json:"href"`
+	Name              string      `json:"name"`
+	Enabled           bool        `json:"enabled"`
+	KeyLifespan       interface{} `json:"key_lifespan"`
+	AllowedUsesPerKey interface{} `json:"allowed_uses_per_key"`
+}
+
+// lifespan returns the key lifespan and false if keys never expire
+func (p pairingProfile) lifespan() (time.Duration, bool) {
+	var seconds float64
+	switch v := p.KeyLifespan.(type) {
+	case float64:
+		seconds = v
+	case string:
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = s
+	default:
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, seconds > 0
+}
+
+// pairingKeyEvent is a pairing key event with the pairing profile it was created for
+type pairingKeyEvent struct {
+	illumioapi.Event
+	ResourceChanges []struct {
+		Resource map[string]struct {
+			Href string `json:"href"`
+		} `json:"resource"`
+	} `json:"resource_changes"`
+}
+
+func certExpiry() {
+	now := time.Now()
+	horizon := now.AddDate(0, 0, days)
+	items := []item{}
+	items = append(items, pceCertificates(now, horizon)...)
+	items = append(items, venCertificates()...)
+	items = append(items, pairingKeys(now, horizon)...)
+
+	data := [][]string{{"type", "name", "href", "expires", "days_left", "status", "detail"}}
+	counts := make(map[string]int)
+	for _, i := range items {
+		expires, daysLeft := "", ""
+		if !i.expires.IsZero() {
+			expires = i.expires.Format(time.RFC3339)
+			daysLeft = strconv.Itoa(int(i.expires.Sub(now).Hours() / 24))
+		}
+		data = append(data, []string{i.itemType, i.name, i.href, expires, daysLeft, i.status, i.detail})
+		counts[i.status]++
+		if i.status == statusExpiring || i.status == statusExpired || i.status == statusUntrusted || i.status == statusWarning {
+			msg := fmt.Sprintf("%s %s - %s", i.itemType, i.name, i.status)
+			if expires != "" {
+				msg = fmt.Sprintf("%s %s", msg, expires)
+			}
+			if i.detail != "" {
+				msg = fmt.Sprintf("%s - %s", msg, i.detail)
+			}
+			utils.LogWarning(msg, true)
+			utils.RecordFailure(msg)
+		}
+	}
+	utils.LogInfo(fmt.Sprintf("%d expiring within %d days, %d expired, %d untrusted, %d never expire, and %d warnings", counts[statusExpiring], days, counts[statusExpired], counts[statusUntrusted], counts[statusNeverExpires], counts[statusWarning]), true)
+	if len(data) == 1 {
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-cert-expiry-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// expiryStatus returns expired, expiring, or ok
+func expiryStatus(expires, now, horizon time.Time) string {
+	if expires.Before(now) {
+		return statusExpired
+	}
+	if expires.Before(horizon) {
+		return statusExpiring
+	}
+	return statusOK
+}
+
+// pceCertificates returns the certificates the pce presents at the fqdn and port in pce.yaml or the environment variables. The first certificate is verified with the system roots and the pce fqdn.
+func pceCertificates(now, horizon time.Time) []item {
+	items := []item{}
+	fqdn := utils.PCEFQDN(pce.FriendlyName)
+	addr := net.JoinHostPort(fqdn, strconv.Itoa(utils.PCEPort(pce.FriendlyName)))
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{InsecureSkipVerify: true, ServerName: fqdn})
+	if err != nil {
+		return append(items, item{itemType: "pce_certificate", name: addr, status: statusWarning, detail: fmt.Sprintf("connecting - %s", err)})
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return append(items, item{itemType: "pce_certificate", name: addr, status: statusWarning, detail: "no certificates"})
+	}
+
+	// Verify the chain
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, verifyErr := certs[0].Verify(x509.VerifyOptions{DNSName: fqdn, Intermediates: intermediates})
+
+	for i, c := range certs {
+		it := item{itemType: "pce_certificate", name: c.Subject.CommonName, href: addr, expires: c.NotAfter, status: expiryStatus(c.NotAfter, now, horizon)}
+		if it.name == "" {
+			it.name = c.Subject.String()
+		}
+		it.detail = fmt.Sprintf("issuer %s", c.Issuer.CommonName)
+		if i == 0 && len(c.DNSNames) > 0 {
+			it.detail = fmt.Sprintf("%s; names %s", it.detail, strings.Join(c.DNSNames, ","))
+		}
+		if i == 0 && verifyErr != nil {
+			if it.status != statusExpired {
+				it.status = statusUntrusted
+			}
+			it.detail = fmt.Sprintf("%s; %s", it.detail, verifyErr)
+		}
+		items = append(items, it)
+	}
+	return items
+}
+
+// venCertificates returns the vens with health conditions about certificates or trust
+func venCertificates() []item {
+	items := []item{}
+	vens, a, err := pce.GetVens(nil)
+	utils.LogAPIResp("GetVens", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting vens - %s. skipping ven certificates.", err), true)
+		return items
+	}
+	for _, v := range vens {
+		for _, c := range v.Conditions {
+			t := strings.ToLower(c.LatestEvent.NotificationType)
+			if !strings.Contains(t, "cert") && !strings.Contains(t, "trust") && !strings.Contains(t, "tls") {
+				continue
+			}
+			items = append(items, item{itemType: "ven_certificate", name: v.Hostname, href: v.Href, status: statusWarning, detail: fmt.Sprintf("%s since %s", c.LatestEvent.NotificationType, c.FirstReportedTimestamp.Format(time.RFC3339))})
+		}
+	}
+	return items
+}
+
+// pairingKeys returns the pairing profiles and the pairing keys created in their key lifespan
+func pairingKeys(now, horizon time.Time) []item {
+	items := []item{}
+	profiles := []pairingProfile{}
+	a, err := pce.GetCollection("pairing_profiles", false, nil, &profiles)
+	utils.LogAPIResp("GetPairingProfiles", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting pairing profiles - %s. skipping pairing keys.", err), true)
+		return items
+	}
+
+	// Profile rows and the longest lifespan for the event query
+	profileMap := make(map[string]pairingProfile)
+	var longest time.Duration
+	for _, p := range profiles {
+		profileMap[p.Href] = p
+		it := item{itemType: "pairing_profile", name: p.Name, href: p.Href, status: statusOK, detail: fmt.Sprintf("enabled %t; allowed uses per key %v", p.Enabled, p.AllowedUsesPerKey)}
+		if lifespan, expires := p.lifespan(); expires {
+			it.detail = fmt.Sprintf("%s; key lifespan %s", it.detail, lifespan)
+			if lifespan > longest {
+				longest = lifespan
+			}
+		} else {
+			it.detail = fmt.Sprintf("%s; keys never expire", it.detail)
+			if p.Enabled {
+				it.status = statusNeverExpires
+			}
+		}
+		items = append(items, it)
+	}
+	if longest == 0 {
+		return items
+	}
+
+	// Get the pairing key events in the longest lifespan
+	qp := map[string]string{"event_type": "pairing_profile.create_pairing_key", "timestamp[gte]": now.Add(-longest).UTC().Format(time.RFC3339), "max_results": "10000"}
+	events := []pairingKeyEvent{}
+	a, err = pce.GetCollection("events", false, qp, &events)
+	if len(events) >= 500 {
+		events = nil
+		a, err = pce.GetCollection("events", true, qp, &events)
+	}
+	utils.LogAPIResp("GetCollection events", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting pairing key events - %s. skipping pairing keys.", err), true)
+		return items
+	}
+	for _, e := range events {
+		e.PopulateCreatedBy()
+		for _, rc := range e.ResourceChanges {
+			for _, r := range rc.Resource {
+				p, ok := profileMap[r.Href]
+				if !ok {
+					continue
+				}
+				lifespan, expires := p.lifespan()
+				if !expires {
+					continue
+				}
+				expiry := e.Timestamp.Add(lifespan)
+				if expiry.Before(now) {
+					continue
+				}
+				items = append(items, item{itemType: "pairing_key", name: p.Name, href: p.Href, expires: expiry, status: expiryStatus(expiry, now, horizon), detail: fmt.Sprintf("created %s by %s", e.Timestamp.Format(time.RFC3339), e.EventCreatedBy.Name)})
+			}
+		}
+	}
+	return items
+}
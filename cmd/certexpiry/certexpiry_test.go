@@ -0,0 +1,34 @@
+package certexpiry
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+)
+
+func TestPCECertificatesUseConfiguredFQDN(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	pce = s.PCE()
+	// A forwarder or proxy can change the request target so the certificate check must not dial pce.FQDN
+	pce.FQDN = "pce.invalid"
+
+	now := time.Now()
+	items := pceCertificates(now, now.AddDate(0, 0, 30))
+	if len(items) == 0 {
+		t.Fatal("no certificates returned")
+	}
+	want := net.JoinHostPort("localhost", strconv.Itoa(s.PCE().Port))
+	for _, it := range items {
+		if strings.HasPrefix(it.detail, "connecting") {
+			t.Fatalf("dialing the pce failed - %s", it.detail)
+		}
+		if it.href != want {
+			t.Errorf("certificate %s came from %s, want %s", it.name, it.href, want)
+		}
+	}
+}
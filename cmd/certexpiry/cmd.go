@@ -0,0 +1,56 @@
+package certexpiry
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName string
+var days int
+
+func init() {
+	CertExpiryCmd.Flags().IntVar(&days, "days", 30, "warn on certificates and pairing keys that expire within this many days.")
+	CertExpiryCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	CertExpiryCmd.Flags().SortFlags = false
+}
+
+// CertExpiryCmd reports pce certificate, ven certificate, and pairing key expiry
+var CertExpiryCmd = &cobra.Command{
+	Use:   "cert-expiry",
+	Short: "Report PCE certificate, VEN certificate, and pairing key expiry.",
+	Long: `
+Report PCE certificate, VEN certificate and trust, and pairing key expiry and warn on anything expiring within --days days.
+
+The report has a row for each of:
+- pce_certificate: each certificate the PCE presents with its expiry. The first certificate is also checked against the system's trusted certificate authorities and the PCE fqdn. VENs must trust the PCE certificate to connect.
+- ven_certificate: each VEN with a health condition about certificates or trust (e.g., a VEN that cannot verify the PCE certificate).
+- pairing_profile: each pairing profile with its key lifespan. Enabled profiles with keys that never expire are flagged.
+- pairing_key: each pairing key created in the key lifespan of its profile with its expiry. Keys are found from the pairing key events, so keys older than the event retention are not included. A key can also expire after its allowed uses.
+
+The status is ok, expiring, expired, untrusted (not expired but not trusted, see the detail and days_left), never_expires, or warning. Each expiring, expired, untrusted, and warning row is logged as a warning, and the command exits with 3 (partial_failure) so a scheduled job or monitoring check can alert on the exit code.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report anything expiring in the next 30 days
+  workloader cert-expiry
+
+  # Report anything expiring in the next 90 days
+  workloader cert-expiry --days 90`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if days < 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--days must be 0 or more")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("cert-expiry")
+		certExpiry()
+		utils.LogEndCommand("cert-expiry")
+	},
+}
@@ -0,0 +1,156 @@
+package changereport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Categories
+const (
+	categoryChange      = "change"
+	categoryProvision   = "provision"
+	categoryFailedLogin = "failed_login"
+	categoryOther       = "other"
+)
+
+// changeEvent is a pce event with the resources it changed and the request that made it. The illumioapi event does not have them.
+type changeEvent struct {
+	illumioapi.Event
+	ResourceChanges []struct {
+		Resource   map[string]map[string]interface{} `json:"resource"`
+		ChangeType string                            `json:"change_type"`
+		Changes    map[string]interface{}            `json:"changes"`
+	} `json:"resource_changes"`
+	Action struct {
+		APIMethod   string `json:"api_method"`
+		APIEndpoint string `json:"api_endpoint"`
+		SrcIP       string `json:"src_ip"`
+	} `json:"action"`
+}
+
+// category returns the category of the event
+func (e changeEvent) category() string {
+	switch {
+	case e.EventType == "sec_policy.create":
+		return categoryProvision
+	case e.Status == "failure" && (strings.HasPrefix(e.EventType, "user.") && (strings.Contains(e.EventType, "login") || strings.Contains(e.EventType, "sign_in")) || e.EventType == "request.authentication_failed"):
+		return categoryFailedLogin
+	case len(e.ResourceChanges) > 0:
+		return categoryChange
+	}
+	return categoryOther
+}
+
+// change is a row of the report
+type change struct {
+	timestamp                                           time.Time
+	user, category, eventType, objectType, object, href string
+	changeType, fields, status, srcIP                   string
+}
+
+func changeReport(startTime, endTime time.Time, objectTypes, users []string) {
+
+	// Get the events
+	utils.LogInfo(fmt.Sprintf("getting events from %s to %s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339)), true)
+	qp := map[string]string{"timestamp[gte]": startTime.Format(time.RFC3339), "timestamp[lte]": endTime.Format(time.RFC3339), "max_results": "10000"}
+	events := []changeEvent{}
+	a, err := pce.GetCollection("events", false, qp, &events)
+	if len(events) >= 500 {
+		events = nil
+		a, err = pce.GetCollection("events", true, qp, &events)
+	}
+	utils.LogAPIResp("GetCollection events", a)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("getting events - %s", err))
+	}
+	utils.LogInfo(fmt.Sprintf("%d events", len(events)), true)
+
+	// Build the changes
+	changes := []change{}
+	for _, e := range events {
+		e.PopulateCreatedBy()
+		category := e.category()
+		if category == categoryOther && !allEvents {
+			continue
+		}
+		if len(users) > 0 && !containsFold(users, e.EventCreatedBy.Name) {
+			continue
+		}
+		base := change{timestamp: e.Timestamp, user: e.EventCreatedBy.Name, category: category, eventType: e.EventType, status: e.Status, srcIP: e.Action.SrcIP}
+		if base.changeType = e.EventType; strings.Contains(e.EventType, ".") {
+			base.changeType = e.EventType[strings.LastIndex(e.EventType, ".")+1:]
+		}
+		if len(e.ResourceChanges) == 0 {
+			if category == categoryFailedLogin {
+				base.objectType, base.object, base.href = "user", e.EventCreatedBy.Name, e.EventCreatedBy.Href
+			}
+			if len(objectTypes) == 0 || containsFold(objectTypes, base.objectType) {
+				changes = append(changes, base)
+			}
+			continue
+		}
+		for _, rc := range e.ResourceChanges {
+			c := base
+			if rc.ChangeType != "" {
+				c.changeType = rc.ChangeType
+			}
+			for t, r := range rc.Resource {
+				c.objectType, c.object, c.href = t, objectName(r), fmt.Sprintf("%v", r["href"])
+			}
+			if len(objectTypes) > 0 && !containsFold(objectTypes, c.objectType) {
+				continue
+			}
+			fields := []string{}
+			for f := range rc.Changes {
+				fields = append(fields, f)
+			}
+			sort.Strings(fields)
+			c.fields = strings.Join(fields, ";")
+			changes = append(changes, c)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].timestamp.Before(changes[j].timestamp) })
+
+	// Build the output
+	data := [][]string{{"timestamp", "user", "category", "event_type", "object_type", "object", "object_href", "change", "changed_fields", "status", "src_ip"}}
+	counts := make(map[string]int)
+	for _, c := range changes {
+		data = append(data, []string{c.timestamp.Format(time.RFC3339), c.user, c.category, c.eventType, c.objectType, c.object, c.href, c.changeType, c.fields, c.status, c.srcIP})
+		counts[c.category]++
+	}
+	utils.LogInfo(fmt.Sprintf("%d changes, %d provisions, %d failed logins, and %d other events", counts[categoryChange], counts[categoryProvision], counts[categoryFailedLogin], counts[categoryOther]), true)
+	if len(data) == 1 {
+		utils.LogInfo("no changes matching criteria", true)
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-change-report-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// objectName returns the name of a resource in an event. Workloads have a hostname, labels have a value, users have a username, and
+// provisioned policy has a commit message.
+func objectName(r map[string]interface{}) string {
+	for _, k := range []string{"name", "hostname", "value", "username", "commit_message"} {
+		if v, ok := r[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// containsFold returns true if s is in the slice ignoring case
+func containsFold(slice []string, s string) bool {
+	for _, v := range slice {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package changereport
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var start, end, objectTypes, users, outputFileName string
+var allEvents bool
+
+func init() {
+	ChangeReportCmd.Flags().StringVarP(&start, "start", "s", time.Now().AddDate(0, 0, -7).In(time.UTC).Format("2006-01-02"), "start date in the format of yyyy-mm-dd.")
+	ChangeReportCmd.Flags().StringVarP(&end, "end", "e", time.Now().In(time.UTC).Format("2006-01-02"), "end date in the format of yyyy-mm-dd.")
+	ChangeReportCmd.Flags().StringVar(&objectTypes, "object-types", "", "comma-separated object types to include (e.g., workload,rule_set,sec_rule). blank is all object types.")
+	ChangeReportCmd.Flags().StringVar(&users, "users", "", "comma-separated usernames to include. blank is all users.")
+	ChangeReportCmd.Flags().BoolVar(&allEvents, "all-events", false, "include every event. default is object changes, provisioning, and failed logins.")
+	ChangeReportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	ChangeReportCmd.Flags().SortFlags = false
+}
+
+// ChangeReportCmd creates a change log from the pce events
+var ChangeReportCmd = &cobra.Command{
+	Use:   "change-report",
+	Short: "Create a change log of who changed what in the PCE from the events api.",
+	Long: `
+Create a change log of who changed what in the PCE from the events api.
+
+Each object change in an event is a row with the time, user, object type, object name and href, the change (create, update, or delete), and the fields that were updated. The category of each row is one of:
+- change: an object was created, updated, or deleted.
+- provision: policy was provisioned.
+- failed_login: a user login or api authentication failed.
+- other: any other event. only included with --all-events.
+
+Use --object-types to limit the report to some object types. The object type is the resource type in the event (e.g., workload, label, rule_set, sec_rule, ip_list, service, or sec_policy for provisioning). Failed logins have the user object type. Use --users to limit the report to changes made by some users.
+
+The start and end dates are in UTC. Use --format html for a page to share.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Create a change log for the last 7 days
+  workloader change-report
+
+  # Create an html change log of rule changes by a user in january
+  workloader change-report --start 2024-01-01 --end 2024-01-31 --object-types rule_set,sec_rule --users jane@example.com --format html`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		startTime, err := time.Parse("2006-01-02", start)
+		if err != nil {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("invalid --start %s. use yyyy-mm-dd", start))
+		}
+		endTime, err := time.Parse("2006-01-02", end)
+		if err != nil {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("invalid --end %s. use yyyy-mm-dd", end))
+		}
+		endTime = endTime.Add(24*time.Hour - time.Second)
+		if endTime.Before(startTime) {
+			utils.LogErrorCode(utils.ExitValidation, "--end must be on or after --start")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("change-report")
+		changeReport(startTime, endTime, splitList(objectTypes), splitList(users))
+		utils.LogEndCommand("change-report")
+	},
+}
+
+// splitList returns the values of a comma-separated flag
+func splitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -4,17 +4,20 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/pcemgmt"
 	"github.com/brian1917/workloader/utils"
 	"github.com/spf13/cobra"
 )
 
-var modeChangeInput, issuesOnly bool
+var modeChangeInput, issuesOnly, allPCEs bool
 var pce illumioapi.PCE
-var outputFileName, role, app, env, loc, labelFile, hostFile string
+var outputFileName, role, app, env, loc, labelFile, hostFile, pceList string
 var err error
 
 func init() {
@@ -26,6 +29,8 @@ func init() {
 	CompatibilityCmd.Flags().StringVarP(&loc, "loc", "l", "", "loc label value. label flags are an \"and\" operator.")
 	CompatibilityCmd.Flags().StringVar(&labelFile, "label-file", "", "csv file with labels to filter query. the file should have 4 headers: role, app, env, and loc. The four columns in each row is an \"AND\" operation. Each row is an \"OR\" operation.")
 	CompatibilityCmd.Flags().StringVar(&hostFile, "host-file", "", "csv file with hrefs or hostnames. any labels or label files are ignored with this flag.")
+	CompatibilityCmd.Flags().StringVar(&pceList, "pces", "", "comma-separated list of pce names from pce.yaml to combine in one report. default is the target or default pce.")
+	CompatibilityCmd.Flags().BoolVar(&allPCEs, "all-pces", false, "combine all pces in pce.yaml in one report.")
 	CompatibilityCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 	CompatibilityCmd.Flags().SortFlags = false
 }
@@ -49,19 +54,74 @@ If using --label-file, the other label flags are ignored. The label file first r
 
 With the input file above, the query will get all IDLE workloads that are labeled as WEB (role) AND ERP (app) AND PROD (env) AND any location OR IDLE workloads that are labeled DB (role) AND CRM (app) AND any environment AND AWS (loc).
 
+Use --pces or --all-pces to combine the idle workloads of multiple pces in one report for fleet-wide planning. The report starts with a pce column and the same label flags, label file, or host file are used on each pce. A pce that fails is logged and the others are still reported. A rollup file has the green, yellow, and red counts of each check for each pce and for all pces. The rollup of a check is red if any workload is red, yellow if any workload is yellow, and green otherwise. With --mode-input, each pce gets its own mode input file.
+
+Examples:
+  workloader compatibility --pces prod-pce,dr-pce
+  workloader compatibility --all-pces --issues-only --env prod
+
 The update-pce and --no-prompt flags are ignored for this command.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		pce, err = utils.GetTargetPCE(true)
-		if err != nil {
-			utils.LogError(err.Error())
+		names := []string{}
+		if allPCEs {
+			names = pcemgmt.GetAllPCENames()
+		} else if pceList != "" {
+			names = strings.Split(strings.ReplaceAll(pceList, " ", ""), ",")
+		}
+		if allPCEs && pceList != "" {
+			utils.LogErrorCode(utils.ExitValidation, "--pces and --all-pces cannot be used together")
 		}
+		sort.Strings(names)
 
-		compatibilityReport()
+		compatibilityReport(names)
 	},
 }
 
-func compatibilityReport() {
+// checkHeaders are the compatibility checks in the rollup
+var checkHeaders = []string{"status", "required_packages_installed", "ipsec_service_enabled", "ipv4_forwarding_enabled", "ipv4_forwarding_pkt_cnt", "iptables_rule_cnt", "ipv6_global_scope", "ipv6_active_conn_cnt", "ip6tables_rule_cnt", "routing_table_conflict", "IPv6_enabled", "Unwanted_nics", "GroupPolicy"}
+
+// rollup counts the statuses of each check by pce
+type rollup map[string]map[string]map[string]int
+
+func (r rollup) add(pceName, check, status string, count int) {
+	if r[pceName] == nil {
+		r[pceName] = make(map[string]map[string]int)
+	}
+	if r[pceName][check] == nil {
+		r[pceName][check] = make(map[string]int)
+	}
+	r[pceName][check][status] += count
+}
+
+// data returns the rollup csv with a row for each pce and check and the totals of the pces as all
+func (r rollup) data(pceNames []string) [][]string {
+	for _, p := range pceNames {
+		for check, counts := range r[p] {
+			for status, count := range counts {
+				r.add("all", check, status, count)
+			}
+		}
+	}
+	data := [][]string{{"pce", "check", "green", "yellow", "red", "na", "rollup"}}
+	for _, p := range append(pceNames, "all") {
+		for _, check := range checkHeaders {
+			counts := r[p][check]
+			status := "green"
+			if counts["red"] > 0 {
+				status = "red"
+			} else if counts["yellow"] > 0 {
+				status = "yellow"
+			} else if counts["green"] == 0 {
+				status = "na"
+			}
+			data = append(data, []string{p, check, strconv.Itoa(counts["green"]), strconv.Itoa(counts["yellow"]), strconv.Itoa(counts["red"]), strconv.Itoa(counts["na"]), status})
+		}
+	}
+	return data
+}
+
+func compatibilityReport(pceNames []string) {
 
 	// Log command
 	utils.LogStartCommand("compatibility")
@@ -72,6 +132,68 @@ func compatibilityReport() {
 	stdOutData = append(stdOutData, []string{"hostname", "href", "status"})
 	modeChangeInputData = append(modeChangeInputData, []string{"href", "mode"})
 
+	// Single pce report
+	if len(pceNames) == 0 {
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		csvRows, stdOutRows, modeRows, err := pceCompatibility(pce.FriendlyName, rollup{})
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		writeReport(append(csvData, csvRows...), append(stdOutData, stdOutRows...))
+		writeModeInput(append(modeChangeInputData, modeRows...), outputFileName)
+		utils.LogEndCommand("compatibility")
+		return
+	}
+
+	// Combine the pces with the pce name in the first column
+	csvData[0] = append([]string{"pce"}, csvData[0]...)
+	stdOutData[0] = append([]string{"pce"}, stdOutData[0]...)
+	timestamp := time.Now().Format("20060102_150405")
+	r := rollup{}
+	reported := []string{}
+	for _, name := range pceNames {
+		utils.LogInfo(fmt.Sprintf("getting compatibility reports from %s", name), true)
+		pce, err = utils.GetPCEbyName(name, true)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - %s", name, err), true)
+			utils.RecordFailure(fmt.Sprintf("%s - %s", name, err))
+			continue
+		}
+		csvRows, stdOutRows, modeRows, err := pceCompatibility(name, r)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - %s", name, err), true)
+			utils.RecordFailure(fmt.Sprintf("%s - %s", name, err))
+			continue
+		}
+		reported = append(reported, name)
+		for _, row := range csvRows {
+			csvData = append(csvData, append([]string{name}, row...))
+		}
+		for _, row := range stdOutRows {
+			stdOutData = append(stdOutData, append([]string{name}, row...))
+		}
+		writeModeInput(append(modeChangeInputData, modeRows...), fmt.Sprintf("workloader-compatibility-mode-input-%s-%s.csv", name, timestamp))
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-compatibility-%s.csv", timestamp)
+	}
+	writeReport(csvData, stdOutData)
+
+	// Write the rollup
+	if len(reported) > 0 {
+		rollupData := r.data(reported)
+		utils.WriteOutput(rollupData, rollupData, fmt.Sprintf("%s-rollup.csv", strings.TrimSuffix(outputFileName, ".csv")))
+		utils.LogInfo(fmt.Sprintf("compatibility reports combined for %d of %d pces.", len(reported), len(pceNames)), true)
+	}
+	utils.LogEndCommand("compatibility")
+}
+
+// pceCompatibility returns the report rows, stdout rows, and mode input rows of the idle workloads in the pce and adds the checks to the rollup
+func pceCompatibility(pceName string, r rollup) (csvData, stdOutData, modeChangeInputData [][]string, err error) {
+
 	// Get all idle  workloads - start query with just idle
 	qp := map[string]string{"mode": "idle"}
 
@@ -83,13 +205,13 @@ func compatibilityReport() {
 			// Parse the CSV
 			labelData, err := utils.ParseCSV(labelFile)
 			if err != nil {
-				utils.LogError(err.Error())
+				return nil, nil, nil, err
 			}
 
 			// Get the labelQuery
 			qp["labels"], err = pce.WorkloadQueryLabelParameter(labelData)
 			if err != nil {
-				utils.LogError(err.Error())
+				return nil, nil, nil, err
 			}
 
 		} else {
@@ -103,7 +225,7 @@ func compatibilityReport() {
 				}
 				// Confirm the label exists
 				if label, ok := pce.Labels[keys[i]+labelValue]; !ok {
-					utils.LogError(fmt.Sprintf("%s does not exist as a %s label", labelValue, keys[i]))
+					return nil, nil, nil, fmt.Errorf("%s does not exist as a %s label", labelValue, keys[i])
 				} else {
 					queryLabels = append(queryLabels, label.Href)
 				}
@@ -117,14 +239,14 @@ func compatibilityReport() {
 		}
 
 		if len(qp["labels"]) > 10000 {
-			utils.LogError(fmt.Sprintf("the query is too large. the total character count is %d and the limit for this command is 10,000", len(qp["labels"])))
+			return nil, nil, nil, fmt.Errorf("the query is too large. the total character count is %d and the limit for this command is 10,000", len(qp["labels"]))
 		}
 
 		// Get all workloads from the query
 		wklds, a, err := pce.GetWklds(qp)
 		utils.LogAPIResp("GetAllWorkloadsQP", a)
 		if err != nil {
-			utils.LogError(err.Error())
+			return nil, nil, nil, err
 		}
 
 		// Get Idle workload count
@@ -138,7 +260,7 @@ func compatibilityReport() {
 		// If the hostfile is provided, parse it.
 		hostFileCsvData, err := utils.ParseCSV(hostFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return nil, nil, nil, err
 		}
 		for i, row := range hostFileCsvData {
 			var w illumioapi.Workload
@@ -148,13 +270,13 @@ func compatibilityReport() {
 				w, a, err = pce.GetWkldByHref(row[0])
 				utils.LogAPIResp("GetWkldByHref", a)
 				if err != nil {
-					utils.LogError(err.Error())
+					return nil, nil, nil, err
 				}
 			} else {
 				w, a, err = pce.GetWkldByHostname(row[0])
 				utils.LogAPIResp("GetWkldByHostname", a)
 				if err != nil {
-					utils.LogError(err.Error())
+					return nil, nil, nil, err
 				}
 			}
 			if w.Hostname == "" {
@@ -174,13 +296,14 @@ func compatibilityReport() {
 	warningLogs := []string{}
 
 	// Iterate through each workload
-	for i, w := range idleWklds {
+	progress := utils.NewProgress("reviewing compatibility reports", len(idleWklds))
+	for _, w := range idleWklds {
 
 		// Get the compatibility report and append
 		cr, a, err := pce.GetCompatibilityReport(w)
 		utils.LogAPIResp("GetCompatibilityReport", a)
 		if err != nil {
-			utils.LogError(fmt.Sprintf("getting compatibility report for %s (%s) - %s", w.Hostname, w.Href, err))
+			return nil, nil, nil, fmt.Errorf("getting compatibility report for %s (%s) - %s", w.Hostname, w.Href, err)
 		}
 
 		// Set the initial values for Linux, AIX, and Solaris and override for Windows
@@ -250,18 +373,19 @@ func compatibilityReport() {
 			}
 		}
 
-		// Update stdout
-		end := ""
-		if i+1 == len(idleWklds) {
-			end = "\r\n"
-		}
-		fmt.Printf("\r%s [INFO] - reviewed compatibility report %d of %d (%d%%).%s", time.Now().Format("2006-01-02 15:04:05 "), i+1, len(idleWklds), (i+1)*100/len(idleWklds), end)
+		// Update progress
+		progress.Increment()
 
 		if cr.QualifyStatus == "" {
 			warningLogs = append(warningLogs, fmt.Sprintf("%s is an idle workload but does not have a compatibility report", w.Hostname))
 			continue
 		}
 
+		// Add the checks to the rollup
+		for i, status := range []string{cr.QualifyStatus, requiredPackagesInstalled, ipsecServiceEnabled, ipv4ForwardingEnabled, ipv4ForwardingPktCnt, iptablesRuleCnt, ipv6GlobalScope, ipv6ActiveConnCnt, iP6TablesRuleCnt, routingTableConflict, iPv6Enabled, unwantedNics, groupPolicy} {
+			r.add(pceName, checkHeaders[i], status, 1)
+		}
+
 		// Put into slice if it's NOT green and issuesOnly is true
 		if (cr.QualifyStatus != "green" && issuesOnly) || !issuesOnly {
 			csvData = append(csvData, []string{w.Hostname, w.Href, cr.QualifyStatus, w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value, utils.PtrToStr(w.OsID), utils.PtrToStr(w.OsDetail), requiredPackagesInstalled, requiredPackagesMissing, ipsecServiceEnabled, ipv4ForwardingEnabled, ipv4ForwardingPktCnt, iptablesRuleCnt, ipv6GlobalScope, ipv6ActiveConnCnt, iP6TablesRuleCnt, routingTableConflict, iPv6Enabled, unwantedNics, groupPolicy, a.RespBody})
@@ -273,12 +397,18 @@ func compatibilityReport() {
 		}
 
 	}
+	progress.Done()
 
 	// Warnings
 	for _, wl := range warningLogs {
 		utils.LogWarning(wl, true)
 	}
 
+	return csvData, stdOutData, modeChangeInputData, nil
+}
+
+// writeReport writes the report if there is data
+func writeReport(csvData, stdOutData [][]string) {
 	// If the CSV data has more than just the headers, create output file and write it.
 	if len(csvData) > 1 {
 		if outputFileName == "" {
@@ -290,14 +420,16 @@ func compatibilityReport() {
 		// Log command execution for 0 results
 		utils.LogInfo("no workloads with compatibility reports for provided query.", true)
 	}
+}
 
-	// Write the mode change CSV
+// writeModeInput writes the mode change csv if --mode-input is set
+func writeModeInput(modeChangeInputData [][]string, fileName string) {
 	if modeChangeInput && len(modeChangeInputData) > 1 {
 		// Create CSV
-		if outputFileName == "" {
-			outputFileName = "mode-input-" + outputFileName
+		if fileName == "" {
+			fileName = "mode-input-" + fileName
 		}
-		outFile, err := os.Create(outputFileName)
+		outFile, err := os.Create(fileName)
 		if err != nil {
 			utils.LogError(fmt.Sprintf("creating CSV - %s\n", err))
 		}
@@ -311,6 +443,4 @@ func compatibilityReport() {
 		// Log
 		utils.LogInfo(fmt.Sprintf("Created a file to be used with workloader mode command to change all green status IDLE workloads to build: %s", outFile.Name()), true)
 	}
-	utils.LogEndCommand("compatibility")
-
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// registerCompletions adds dynamic shell completion for PCE names, PCE groups, label values, label keys, and ruleset names.
+// Values from the PCE are cached so completion stays fast.
+func registerCompletions() {
+	RootCmd.RegisterFlagCompletionFunc("pce", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return utils.CompletionPCENames(), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	RootCmd.RegisterFlagCompletionFunc("pce-group", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		groups := []string{}
+		for g := range viper.GetStringMap("pce_groups") {
+			groups = append(groups, g)
+		}
+		sort.Strings(groups)
+		return groups, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		c.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+			if f.Value.Type() != "string" {
+				return
+			}
+			switch f.Name {
+			case "role", "app", "env", "loc":
+				key := f.Name
+				c.RegisterFlagCompletionFunc(f.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return utils.CompletionLabels(completionPCE(cmd), key), cobra.ShellCompDirectiveNoFileComp
+				})
+			case "label-key":
+				c.RegisterFlagCompletionFunc(f.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return utils.CompletionLabelKeys(completionPCE(cmd)), cobra.ShellCompDirectiveNoFileComp
+				})
+			case "label-keys":
+				c.RegisterFlagCompletionFunc(f.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return completeList(utils.CompletionLabelKeys(completionPCE(cmd)), toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+				})
+			case "pce-list", "pces":
+				c.RegisterFlagCompletionFunc(f.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return completeList(utils.CompletionPCENames(), toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+				})
+			}
+		})
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(RootCmd)
+
+	// Commands with ruleset names as arguments
+	if c, _, err := RootCmd.Find([]string{"template-create"}); err == nil && c != RootCmd {
+		c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return utils.CompletionRulesetNames(completionPCE(cmd)), cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+}
+
+// completionPCE returns the --pce flag value or blank for the default PCE
+func completionPCE(cmd *cobra.Command) string {
+	if f := cmd.Flag("pce"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// completeList completes the last item of a comma-separated list
+func completeList(values []string, toComplete string) []string {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	used := make(map[string]bool)
+	for _, u := range strings.Split(prefix, ",") {
+		used[u] = true
+	}
+	completions := []string{}
+	for _, v := range values {
+		if !used[v] {
+			completions = append(completions, prefix+v)
+		}
+	}
+	return completions
+}
@@ -0,0 +1,80 @@
+package compliancereport
+
+import (
+	"fmt"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName string
+var draft bool
+
+func init() {
+	ComplianceReportCmd.Flags().BoolVar(&draft, "draft", false, "evaluate the draft policy instead of the active policy. use to check changes before they are provisioned.")
+	ComplianceReportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	ComplianceReportCmd.Flags().SortFlags = false
+}
+
+// ComplianceReportCmd evaluates the PCE against a compliance policy file
+var ComplianceReportCmd = &cobra.Command{
+	Use:   "compliance-report [yaml policy file]",
+	Short: "Create a pass/fail report of the PCE against a segmentation compliance policy file.",
+	Long: `
+Create a pass/fail report of the PCE against a segmentation compliance policy file for audit evidence (e.g., PCI or SWIFT).
+
+Each requirement in the policy file is a control. A control that passes has one row with the number of workloads or rules checked. A control that fails has one row for each workload or rule that does not meet it. Use --format html for a page to share with auditors.
+
+The policy file is yaml with any of the following keys:
+- required_labels: label keys every workload must have.
+- include_unmanaged: check unmanaged workloads for required labels. default is managed workloads only.
+- enforcement: the minimum enforcement (idle, visibility_only, selective, or full) of managed workloads by env label value. * is the minimum for workloads with other env labels or no env label.
+- prohibited_rules: broad rules that must not be in the policy. each has a name and any of consumers (any_ip or all_workloads), providers (all_workloads), and all_services (true). a rule is prohibited if it matches every key that is set. all_workloads is all workloads across the PCE, not in a ruleset scope.
+- blocked_ports: ports that no rule can allow (e.g., 23/tcp, 137-139/udp, or 3389 for tcp and udp).
+
+Example policy file:
+required_labels: [role, app, env, loc]
+enforcement:
+  PROD: full
+  "*": visibility_only
+prohibited_rules:
+  - name: any ip on all services
+    consumers: any_ip
+    all_services: true
+  - name: all workloads to all workloads
+    consumers: all_workloads
+    providers: all_workloads
+blocked_ports: [23/tcp, 3389/tcp, 137-139/udp]
+
+Rules are evaluated in the active policy. Use --draft to evaluate the draft policy.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Create a csv compliance report
+  workloader compliance-report pci.yaml
+
+  # Create an html compliance report of the draft policy
+  workloader compliance-report pci.yaml --draft --format html`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if len(args) != 1 {
+			fmt.Println("Command requires 1 argument for the policy file. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+		policy, err := parsePolicy(args[0])
+		if err != nil {
+			utils.LogErrorCode(utils.ExitValidation, err.Error())
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("compliance-report")
+		complianceReport(policy)
+		utils.LogEndCommand("compliance-report")
+	},
+}
@@ -0,0 +1,333 @@
+package compliancereport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Output headers
+const (
+	HeaderControl     = "control"
+	HeaderRequirement = "requirement"
+	HeaderStatus      = "status"
+	HeaderObjectType  = "object_type"
+	HeaderObject      = "object"
+	HeaderHref        = "href"
+	HeaderDetail      = "detail"
+)
+
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+)
+
+// finding is one row of the report
+type finding struct {
+	control, requirement, status, objectType, object, href, detail string
+}
+
+// control is the findings of one requirement. A control without failures has one passing finding.
+type control struct {
+	name, requirement, checkedType string
+	checked                        int
+	failures                       []finding
+}
+
+// fail adds a failing finding for an object
+func (c *control) fail(objectType, object, href, detail string) {
+	c.failures = append(c.failures, finding{control: c.name, requirement: c.requirement, status: statusFail, objectType: objectType, object: object, href: href, detail: detail})
+}
+
+// findings returns the failures or a passing finding
+func (c *control) findings() []finding {
+	if len(c.failures) > 0 {
+		return c.failures
+	}
+	return []finding{{control: c.name, requirement: c.requirement, status: statusPass, detail: fmt.Sprintf("%d %s checked", c.checked, c.checkedType)}}
+}
+
+func complianceReport(policy Policy) {
+
+	// Load the PCE
+	provisionStatus := "active"
+	if draft {
+		provisionStatus = "draft"
+	}
+	load := illumioapi.LoadInput{Labels: true, Workloads: true}
+	if len(policy.ProhibitedRules) > 0 || len(policy.blockedPorts) > 0 {
+		load.ProvisionStatus = provisionStatus
+		load.IPLists = true
+		load.Services = true
+		load.RuleSets = true
+	}
+	if !policy.IncludeUnmanaged {
+		load.WorkloadsQueryParameters = map[string]string{"managed": "true"}
+	}
+	apiResps, err := pce.Load(load)
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	controls := []*control{}
+	controls = append(controls, labelControls(policy)...)
+	controls = append(controls, enforcementControls(policy)...)
+	controls = append(controls, ruleControls(policy)...)
+
+	// Build the output
+	csvData := [][]string{{HeaderControl, HeaderRequirement, HeaderStatus, HeaderObjectType, HeaderObject, HeaderHref, HeaderDetail}}
+	stdOutData := [][]string{{HeaderControl, HeaderRequirement, HeaderStatus, "failures"}}
+	passed, failed := 0, 0
+	for _, c := range controls {
+		for _, f := range c.findings() {
+			csvData = append(csvData, []string{f.control, f.requirement, f.status, f.objectType, f.object, f.href, f.detail})
+		}
+		status := statusPass
+		if len(c.failures) > 0 {
+			status = statusFail
+			failed++
+		} else {
+			passed++
+		}
+		stdOutData = append(stdOutData, []string{c.name, c.requirement, status, fmt.Sprintf("%d", len(c.failures))})
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-compliance-report-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(csvData, stdOutData, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d controls passed and %d controls failed against %s policy", passed, failed, provisionStatus), true)
+}
+
+// labelControls checks every workload has a label for each required label key
+func labelControls(policy Policy) []*control {
+	controls := []*control{}
+	for _, key := range policy.RequiredLabels {
+		c := &control{name: "required-labels", requirement: fmt.Sprintf("workloads have a %s label", key), checkedType: "workloads"}
+		for _, w := range pce.WorkloadsSlice {
+			c.checked++
+			if w.GetLabelByKey(key, pce.Labels).Href == "" {
+				c.fail("workload", w.Hostname, w.Href, fmt.Sprintf("no %s label", key))
+			}
+		}
+		controls = append(controls, c)
+	}
+	return controls
+}
+
+// enforcementControls checks managed workloads are in the target enforcement mode or higher for their env label. The * env is the target for
+// workloads with an env that is not in the policy.
+func enforcementControls(policy Policy) []*control {
+	envs := []string{}
+	for env := range policy.Enforcement {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	controls := []*control{}
+	for _, env := range envs {
+		target := policy.Enforcement[env]
+		requirement := fmt.Sprintf("%s workloads are in %s enforcement or higher", env, target)
+		if env == "*" {
+			requirement = fmt.Sprintf("workloads in other envs are in %s enforcement or higher", target)
+		}
+		c := &control{name: "enforcement", requirement: requirement, checkedType: "workloads"}
+		for _, w := range pce.WorkloadsSlice {
+			mode := w.GetMode()
+			if mode == "unmanaged" {
+				continue
+			}
+			wkldEnv := w.GetEnv(pce.Labels).Value
+			if _, ok := policy.Enforcement[wkldEnv]; !ok {
+				wkldEnv = "*"
+			}
+			if wkldEnv != env {
+				continue
+			}
+			c.checked++
+			if modeRank[mode] < modeRank[target] {
+				c.fail("workload", w.Hostname, w.Href, fmt.Sprintf("%s enforcement", mode))
+			}
+		}
+		controls = append(controls, c)
+	}
+	return controls
+}
+
+// policyRule is an enabled rule in an enabled ruleset
+type policyRule struct {
+	ruleSet illumioapi.RuleSet
+	rule    *illumioapi.Rule
+}
+
+// policyRules returns the enabled rules of enabled rulesets sorted by href. The ruleset map is keyed by href and name so only hrefs are used.
+func policyRules() []policyRule {
+	rules := []policyRule{}
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href || rs.Enabled != nil && !*rs.Enabled {
+			continue
+		}
+		for _, r := range rs.Rules {
+			if r == nil || r.Enabled != nil && !*r.Enabled {
+				continue
+			}
+			rules = append(rules, policyRule{ruleSet: rs, rule: r})
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].rule.Href < rules[j].rule.Href })
+	return rules
+}
+
+// ruleControls checks for prohibited broad rules and rules that allow blocked ports
+func ruleControls(policy Policy) []*control {
+	rules := policyRules()
+	controls := []*control{}
+
+	for _, p := range policy.ProhibitedRules {
+		c := &control{name: "prohibited-rules", requirement: fmt.Sprintf("no rules match %s", p.Name), checkedType: "rules"}
+		for _, r := range rules {
+			c.checked++
+			if p.Consumers != "" && !consumersMatch(r, p.Consumers) {
+				continue
+			}
+			if p.Providers == actorAllWorkloads && !providersAllWorkloads(r) {
+				continue
+			}
+			if p.AllServices && !allServices(r.rule) {
+				continue
+			}
+			c.fail("rule", r.ruleSet.Name, r.rule.Href, r.rule.Description)
+		}
+		controls = append(controls, c)
+	}
+
+	for _, b := range policy.blockedPorts {
+		c := &control{name: "blocked-ports", requirement: fmt.Sprintf("no rules allow %s", b.text), checkedType: "rules"}
+		for _, r := range rules {
+			c.checked++
+			if services := allowedServices(r.rule, b); len(services) > 0 {
+				c.fail("rule", r.ruleSet.Name, r.rule.Href, fmt.Sprintf("allowed by %s", strings.Join(services, ";")))
+			}
+		}
+		controls = append(controls, c)
+	}
+
+	return controls
+}
+
+// globalScope returns true if the ruleset applies to all workloads. A ruleset without scopes or with an empty scope is global.
+func globalScope(rs illumioapi.RuleSet) bool {
+	if len(rs.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range rs.Scopes {
+		if len(scope) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// consumersMatch returns true if a consumer of the rule is any ip or all workloads across the PCE
+func consumersMatch(r policyRule, actor string) bool {
+	for _, c := range r.rule.Consumers {
+		if c == nil {
+			continue
+		}
+		if actor == actorAnyIP && c.IPList != nil && utils.AnyIP(pce.IPLists[c.IPList.Href]) {
+			return true
+		}
+		if actor == actorAllWorkloads && c.Actors == "ams" && (globalScope(r.ruleSet) || r.rule.UnscopedConsumers != nil && *r.rule.UnscopedConsumers) {
+			return true
+		}
+	}
+	return false
+}
+
+// providersAllWorkloads returns true if a provider of the rule is all workloads across the PCE
+func providersAllWorkloads(r policyRule) bool {
+	for _, p := range r.rule.Providers {
+		if p != nil && p.Actors == "ams" && globalScope(r.ruleSet) {
+			return true
+		}
+	}
+	return false
+}
+
+// allServices returns true if the rule allows all services
+func allServices(r *illumioapi.Rule) bool {
+	if r.IngressServices == nil {
+		return false
+	}
+	for _, s := range *r.IngressServices {
+		if s == nil {
+			continue
+		}
+		if s.Href != nil {
+			for _, sp := range pce.Services[*s.Href].ServicePorts {
+				if sp != nil && sp.Protocol == -1 {
+					return true
+				}
+			}
+		} else if s.Protocol != nil && *s.Protocol == -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedServices returns the services of the rule that allow the blocked port
+func allowedServices(r *illumioapi.Rule, b blockedPort) []string {
+	allowed := []string{}
+	if r.IngressServices == nil {
+		return allowed
+	}
+	for _, s := range *r.IngressServices {
+		if s == nil {
+			continue
+		}
+		if s.Href != nil {
+			svc := pce.Services[*s.Href]
+			for _, sp := range svc.ServicePorts {
+				if sp != nil && b.allows(sp.Port, sp.ToPort, sp.Protocol) {
+					allowed = append(allowed, svc.Name)
+					break
+				}
+			}
+			continue
+		}
+		port, toPort, proto := 0, 0, 0
+		if s.Port != nil {
+			port = *s.Port
+		}
+		if s.ToPort != nil {
+			toPort = *s.ToPort
+		}
+		if s.Protocol != nil {
+			proto = *s.Protocol
+		}
+		if b.allows(port, toPort, proto) {
+			allowed = append(allowed, portProtoString(port, toPort, proto))
+		}
+	}
+	return allowed
+}
+
+// portProtoString returns a port or port range and protocol (e.g., 8080-8090/tcp)
+func portProtoString(port, toPort, proto int) string {
+	protocol := fmt.Sprintf("%d", proto)
+	if proto == 6 {
+		protocol = "tcp"
+	} else if proto == 17 {
+		protocol = "udp"
+	}
+	if toPort != 0 {
+		return fmt.Sprintf("%d-%d/%s", port, toPort, protocol)
+	}
+	return fmt.Sprintf("%d/%s", port, protocol)
+}
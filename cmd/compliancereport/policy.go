@@ -0,0 +1,136 @@
+package compliancereport
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the compliance policy file the PCE is evaluated against
+type Policy struct {
+	RequiredLabels   []string          `yaml:"required_labels"`
+	IncludeUnmanaged bool              `yaml:"include_unmanaged"`
+	Enforcement      map[string]string `yaml:"enforcement"`
+	ProhibitedRules  []ProhibitedRule  `yaml:"prohibited_rules"`
+	BlockedPorts     []string          `yaml:"blocked_ports"`
+	blockedPorts     []blockedPort
+}
+
+// ProhibitedRule is a broad rule that must not be in the policy. A rule matches if it matches every field that is set.
+type ProhibitedRule struct {
+	Name        string `yaml:"name"`
+	Consumers   string `yaml:"consumers"`
+	Providers   string `yaml:"providers"`
+	AllServices bool   `yaml:"all_services"`
+}
+
+// blockedPort is a port or port range that must not be allowed by any rule. A protocol of 0 is tcp and udp.
+type blockedPort struct {
+	text     string
+	from, to int
+	proto    int
+}
+
+const (
+	actorAnyIP        = "any_ip"
+	actorAllWorkloads = "all_workloads"
+)
+
+// modeRank orders enforcement modes so a target is a minimum. Legacy modes are ranked with the mode they became.
+var modeRank = map[string]int{"idle": 0, "build": 1, "test": 1, "visibility_only": 1, "selective": 2, "enforced-no": 3, "enforced-low": 3, "enforced-high": 3, "full": 3}
+
+// parsePolicy reads and validates the policy file
+func parsePolicy(file string) (Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return p, fmt.Errorf("reading policy file - %s", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return p, fmt.Errorf("%s is not a valid policy file - %s", file, err)
+	}
+
+	for env, mode := range p.Enforcement {
+		mode = strings.ToLower(mode)
+		if mode != "idle" && mode != "visibility_only" && mode != "selective" && mode != "full" {
+			return p, fmt.Errorf("enforcement for %s must be idle, visibility_only, selective, or full", env)
+		}
+		p.Enforcement[env] = mode
+	}
+	for i, r := range p.ProhibitedRules {
+		if r.Name == "" {
+			p.ProhibitedRules[i].Name = fmt.Sprintf("prohibited rule %d", i+1)
+		}
+		if r.Consumers != "" && r.Consumers != actorAnyIP && r.Consumers != actorAllWorkloads {
+			return p, fmt.Errorf("%s consumers must be %s or %s", p.ProhibitedRules[i].Name, actorAnyIP, actorAllWorkloads)
+		}
+		if r.Providers != "" && r.Providers != actorAllWorkloads {
+			return p, fmt.Errorf("%s providers must be %s", p.ProhibitedRules[i].Name, actorAllWorkloads)
+		}
+		if r.Consumers == "" && r.Providers == "" && !r.AllServices {
+			return p, fmt.Errorf("%s must set consumers, providers, or all_services", p.ProhibitedRules[i].Name)
+		}
+	}
+	for _, bp := range p.BlockedPorts {
+		b, err := parseBlockedPort(bp)
+		if err != nil {
+			return p, err
+		}
+		p.blockedPorts = append(p.blockedPorts, b)
+	}
+	if len(p.RequiredLabels) == 0 && len(p.Enforcement) == 0 && len(p.ProhibitedRules) == 0 && len(p.blockedPorts) == 0 {
+		return p, fmt.Errorf("%s has no controls", file)
+	}
+	return p, nil
+}
+
+// parseBlockedPort parses a port or port range with an optional protocol (e.g., 23/tcp, 137-139/udp, or 3389)
+func parseBlockedPort(s string) (blockedPort, error) {
+	b := blockedPort{text: strings.TrimSpace(s)}
+	ports, proto, hasProto := strings.Cut(strings.ToLower(b.text), "/")
+	if hasProto {
+		switch proto {
+		case "tcp":
+			b.proto = 6
+		case "udp":
+			b.proto = 17
+		default:
+			return b, fmt.Errorf("blocked port %s - protocol must be tcp or udp", s)
+		}
+	}
+	from, to, isRange := strings.Cut(ports, "-")
+	var err error
+	if b.from, err = strconv.Atoi(from); err != nil || b.from < 1 || b.from > 65535 {
+		return b, fmt.Errorf("blocked port %s - invalid port", s)
+	}
+	b.to = b.from
+	if isRange {
+		if b.to, err = strconv.Atoi(to); err != nil || b.to < b.from || b.to > 65535 {
+			return b, fmt.Errorf("blocked port %s - invalid port range", s)
+		}
+	}
+	return b, nil
+}
+
+// allows returns true if a service port allows traffic on the blocked port. A protocol of -1 is all services and a port of 0 is all ports.
+func (b blockedPort) allows(port, toPort, proto int) bool {
+	if proto == -1 {
+		return true
+	}
+	if b.proto != 0 && proto != b.proto || b.proto == 0 && proto != 6 && proto != 17 {
+		return false
+	}
+	if port == 0 {
+		return true
+	}
+	if toPort == 0 {
+		toPort = port
+	}
+	return port <= b.to && toPort >= b.from
+}
@@ -0,0 +1,86 @@
+package consulsync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var consulAddr, consulToken, datacenters, mappingFile, externalDataSet, outputFileName string
+var insecure, includeUnhealthy, noNodes, noServices, noBindings, cleanup, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	ConsulSyncCmd.Flags().StringVarP(&consulAddr, "consul-addr", "s", "", "consul http address (e.g., https://consul.corp.local:8501). default is the CONSUL_HTTP_ADDR environment variable, then http://127.0.0.1:8500.")
+	ConsulSyncCmd.Flags().StringVarP(&consulToken, "consul-token", "t", "", "consul acl token with read access to nodes and services. default is the CONSUL_HTTP_TOKEN environment variable.")
+	ConsulSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the consul certificate.")
+	ConsulSyncCmd.Flags().StringVar(&datacenters, "datacenters", "", "comma-separated list of consul datacenters. default is all datacenters.")
+	ConsulSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: consul tag key, meta key, or consul: pseudo key and illumio label key. see help for details.")
+	ConsulSyncCmd.Flags().BoolVar(&includeUnhealthy, "include-unhealthy", false, "include service instances with failing health checks. by default only passing instances are backends.")
+	ConsulSyncCmd.Flags().BoolVar(&noNodes, "no-nodes", false, "do not create unmanaged workloads for nodes.")
+	ConsulSyncCmd.Flags().BoolVar(&noServices, "no-services", false, "do not create virtual services for services.")
+	ConsulSyncCmd.Flags().BoolVar(&noBindings, "no-bindings", false, "do not bind the workloads of service instances to the virtual services.")
+	ConsulSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "consul-sync", "external data set used to identify objects managed by consul-sync.")
+	ConsulSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads and virtual services in the external data set that are no longer registered in consul.")
+	ConsulSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	ConsulSyncCmd.Flags().SortFlags = false
+}
+
+// ConsulSyncCmd runs the consul-sync command
+var ConsulSyncCmd = &cobra.Command{
+	Use:         "consul-sync",
+	Short:       "Create, update, and delete unmanaged workloads and virtual services for Consul nodes and services.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete unmanaged workloads and virtual services for Consul nodes and services.
+
+Nodes in the catalog are unmanaged workloads with the node address as the interface. Nodes with a name or address matching an existing workload (such as a workload with a VEN) are skipped. The external data reference is datacenter/node/name.
+
+Each service is a virtual service with the most common instance port. Workloads with the address of a healthy service instance are bound to the virtual service, with a port override when the instance uses a different port. Virtual services are provisioned after they are created or updated. The external data reference is datacenter/service/name.
+
+Run on a schedule with --update-pce and --no-prompt to keep the PCE in lockstep with consul registration.
+
+The mapping file is a csv with a key in the first column and the illumio label key in the second column. Keys can be:
+- Tag keys. Tags in the format key=value or key:value are used as key and value (e.g., env=prod).
+- Node meta keys (nodes) and service meta keys (services).
+- Pseudo keys: consul:datacenter, consul:node (nodes), consul:service (services), consul:tag (the first tag without a key).
+
+Example mapping file:
++-------------------+-----------+
+|        key        | label_key |
++-------------------+-----------+
+| consul:service    | app       |
+| env               | env       |
+| consul:datacenter | loc       |
++-------------------+-----------+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if consulAddr == "" {
+			consulAddr = os.Getenv("CONSUL_HTTP_ADDR")
+		}
+		if consulAddr == "" {
+			consulAddr = "http://127.0.0.1:8500"
+		}
+		if consulToken == "" {
+			consulToken = os.Getenv("CONSUL_HTTP_TOKEN")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		consulSync()
+	},
+}
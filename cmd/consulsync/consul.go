@@ -0,0 +1,107 @@
+package consulsync
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// consulClient calls the Consul HTTP API
+type consulClient struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// consulNode is a node in the catalog
+type consulNode struct {
+	ID         string            `json:"ID"`
+	Node       string            `json:"Node"`
+	Address    string            `json:"Address"`
+	Datacenter string            `json:"Datacenter"`
+	Meta       map[string]string `json:"Meta"`
+}
+
+// consulServiceEntry is a service instance from the health api
+type consulServiceEntry struct {
+	Node    consulNode `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Tags    []string          `json:"Tags"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+	Checks []struct {
+		Status string `json:"Status"`
+	} `json:"Checks"`
+}
+
+func newConsulClient(addr, token string, insecure bool) consulClient {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return consulClient{addr: strings.TrimSuffix(addr, "/"), token: token, client: &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}}
+}
+
+// get sends a GET request and unmarshals the response into v
+func (c consulClient) get(path string, query url.Values, v interface{}) error {
+	req, err := http.NewRequest("GET", c.addr+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s - %d - %s", path, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// datacenters returns all datacenters known to the agent
+func (c consulClient) datacenters() ([]string, error) {
+	dcs := []string{}
+	err := c.get("/v1/catalog/datacenters", url.Values{}, &dcs)
+	return dcs, err
+}
+
+// nodes returns the nodes in a datacenter
+func (c consulClient) nodes(dc string) ([]consulNode, error) {
+	nodes := []consulNode{}
+	err := c.get("/v1/catalog/nodes", url.Values{"dc": {dc}}, &nodes)
+	return nodes, err
+}
+
+// services returns the service names and tags in a datacenter
+func (c consulClient) services(dc string) (map[string][]string, error) {
+	services := make(map[string][]string)
+	err := c.get("/v1/catalog/services", url.Values{"dc": {dc}}, &services)
+	return services, err
+}
+
+// serviceInstances returns the instances of a service with health. passing limits to instances with all checks passing.
+func (c consulClient) serviceInstances(dc, service string, passing bool) ([]consulServiceEntry, error) {
+	q := url.Values{"dc": {dc}}
+	if passing {
+		q.Set("passing", "true")
+	}
+	entries := []consulServiceEntry{}
+	err := c.get("/v1/health/service/"+url.PathEscape(service), q, &entries)
+	return entries, err
+}
@@ -0,0 +1,205 @@
+package consulsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/vssync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func consulSync() {
+
+	utils.LogStartCommand("consul-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	client := newConsulClient(consulAddr, consulToken, insecure)
+	dcs := []string{}
+	for _, dc := range strings.Split(datacenters, ",") {
+		if strings.TrimSpace(dc) != "" {
+			dcs = append(dcs, strings.TrimSpace(dc))
+		}
+	}
+	if len(dcs) == 0 {
+		if dcs, err = client.datacenters(); err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Map the existing workloads so nodes with a ven are not duplicated
+	existing := make(map[string]bool)
+	if !noNodes {
+		wklds, api, err := pce.GetWklds(nil)
+		utils.LogAPIResp("GetWklds", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, w := range wklds {
+			if utils.PtrToStr(w.ExternalDataSet) == externalDataSet {
+				continue
+			}
+			existing[strings.ToLower(w.Hostname)] = true
+			existing[strings.ToLower(strings.Split(w.Hostname, ".")[0])] = true
+			for _, i := range w.Interfaces {
+				existing[i.Address] = true
+			}
+		}
+	}
+
+	workloads := []umwlsync.Workload{}
+	virtualServices := []vssync.VirtualService{}
+	for _, dc := range dcs {
+
+		// Nodes
+		if !noNodes {
+			nodes, err := client.nodes(dc)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			utils.LogInfo(fmt.Sprintf("%s - %d nodes discovered", dc, len(nodes)), true)
+			for _, n := range nodes {
+				if existing[strings.ToLower(n.Node)] || existing[strings.ToLower(strings.Split(n.Node, ".")[0])] || existing[n.Address] {
+					utils.LogInfo(fmt.Sprintf("%s - node %s matches an existing workload. skipping.", dc, n.Node), false)
+					continue
+				}
+				attr := map[string]string{"consul:datacenter": dc, "consul:node": n.Node}
+				for k, v := range n.Meta {
+					attr[k] = v
+				}
+				workloads = append(workloads, umwlsync.Workload{
+					Hostname:              n.Node,
+					Name:                  n.Node,
+					Interfaces:            []string{"eth0:" + n.Address},
+					Description:           fmt.Sprintf("consul node in %s", dc),
+					DataCenter:            dc,
+					Labels:                umwlsync.MapLabels(attr, mapping),
+					ExternalDataReference: fmt.Sprintf("%s/node/%s", dc, n.Node),
+				})
+			}
+		}
+
+		// Services
+		if noServices {
+			continue
+		}
+		services, err := client.services(dc)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("%s - %d services discovered", dc, len(services)), true)
+		names := []string{}
+		for s := range services {
+			names = append(names, s)
+		}
+		sort.Strings(names)
+		for _, s := range names {
+			if s == "consul" {
+				continue
+			}
+			instances, err := client.serviceInstances(dc, s, !includeUnhealthy)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			if len(instances) == 0 {
+				utils.LogInfo(fmt.Sprintf("%s - service %s has no healthy instances. skipping.", dc, s), false)
+				continue
+			}
+
+			attr := map[string]string{"consul:datacenter": dc, "consul:service": s}
+			for k, v := range tagAttributes(services[s]) {
+				attr[k] = v
+			}
+			portCount := make(map[int]int)
+			backends := []vssync.Backend{}
+			for _, i := range instances {
+				for k, v := range i.Service.Meta {
+					if _, ok := attr[k]; !ok {
+						attr[k] = v
+					}
+				}
+				ip := i.Service.Address
+				if ip == "" {
+					ip = i.Node.Address
+				}
+				portCount[i.Service.Port]++
+				backends = append(backends, vssync.Backend{IP: ip, Port: i.Service.Port})
+			}
+
+			// Use the most common port for the virtual service
+			port, count := 0, 0
+			for p, c := range portCount {
+				if c > count || (c == count && p < port) {
+					port, count = p, c
+				}
+			}
+			vs := vssync.VirtualService{
+				Name:                  fmt.Sprintf("%s-%s", dc, s),
+				ExternalDataReference: fmt.Sprintf("%s/service/%s", dc, s),
+				Labels:                umwlsync.MapLabels(attr, mapping),
+				Backends:              backends,
+			}
+			if port != 0 {
+				vs.ServicePorts = []*illumioapi.ServicePort{{Port: port, Protocol: 6}}
+			}
+			virtualServices = append(virtualServices, vs)
+		}
+	}
+
+	if !noNodes {
+		umwlsync.Sync(umwlsync.Input{
+			PCE:             pce,
+			Command:         "consul-sync",
+			ExternalDataSet: externalDataSet,
+			Workloads:       workloads,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			OutputFileName:  outputFileName,
+		})
+	}
+
+	if !noServices {
+		vssync.Sync(vssync.Input{
+			PCE:             pce,
+			Command:         "consul-sync",
+			ExternalDataSet: externalDataSet,
+			VirtualServices: virtualServices,
+			Cleanup:         cleanup,
+			Bind:            !noBindings,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+		})
+	}
+
+	utils.LogEndCommand("consul-sync")
+}
+
+// tagAttributes converts consul tags to attributes. Tags in the format key=value or key:value are split and consul:tag is the first tag without a key.
+func tagAttributes(tags []string) map[string]string {
+	attr := make(map[string]string)
+	for _, t := range tags {
+		if kv := strings.SplitN(t, "=", 2); len(kv) == 2 {
+			attr[kv[0]] = kv[1]
+			continue
+		}
+		if kv := strings.SplitN(t, ":", 2); len(kv) == 2 {
+			attr[kv[0]] = kv[1]
+			continue
+		}
+		if _, ok := attr["consul:tag"]; !ok {
+			attr["consul:tag"] = t
+		}
+	}
+	return attr
+}
@@ -2,7 +2,6 @@ package containmentswitch
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -31,8 +30,9 @@ func init() {
 
 // TrafficCmd runs the workload identifier
 var ContainmentSwitchCmd = &cobra.Command{
-	Use:   "containment-switch [port protocol]",
-	Short: "Isolate a port on all workloads and optionally keep open the port on workloads that had traffic to that port in a configurable past window.",
+	Use:         "containment-switch [port protocol]",
+	Short:       "Isolate a port on all workloads and optionally keep open the port on workloads that had traffic to that port in a configurable past window.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Isolate a port on all workloads and optionally keep open the port on workloads that had traffic to that port in a configurable past window.
 
@@ -63,7 +63,7 @@ The --update-pce flag is required for Steps 2 through 7. If the --update-pce fla
 		// Get User Input
 		if len(args) != 2 {
 			fmt.Println("Command requires 2 arguments for the port and protocol. The input should be in the format of 445 tcp. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		targetPort, err := strconv.Atoi(args[0])
 		if err != nil {
@@ -183,7 +183,7 @@ func portLock(port int, protocol string) {
 			}
 
 			var prompt string
-			fmt.Printf("\r\n%s[PROMPT] - workloader will do the following in %s (%s):\r\n", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+			fmt.Printf("\r\n%s[PROMPT] - workloader will do the following in %s (%s):\r\n", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 			for i, c := range changes {
 				fmt.Printf("%s [PROMPT] - %d) %s\r\n", time.Now().Format("2006-01-02 15:04:05"), i+1, c)
 			}
@@ -2,7 +2,6 @@ package cwpimport
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -29,8 +28,9 @@ func init() {
 
 // WkldExportCmd runs the workload identifier
 var ContainerProfileImportCmd = &cobra.Command{
-	Use:   "cwp-import",
-	Short: "Update container workload profiles in the PCE.",
+	Use:         "cwp-import",
+	Short:       "Update container workload profiles in the PCE.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Update container workload profiles in the PCE.
 
@@ -42,7 +42,7 @@ Only label assignments are supported. Label restrictions will show as blank in t
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		importFile = args[0]
 
@@ -297,7 +297,7 @@ func importContainerProfiles(pce illumioapi.PCE, importFile, removeValue string,
 	// Prompt
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - do you want to run the import to %s (%s) (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n%s [PROMPT] - do you want to run the import to %s (%s) (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied", true)
@@ -71,6 +71,24 @@ type Result struct {
 	Error   string  `xml:"error,omitempty"`
 	Enabled string  `xml:"enabled,omitempty"`
 	Group   Group   `xml:"group,omitempty"`
+	// Panorama device groups and commit job
+	DeviceGroups []DeviceGroup `xml:"devicegroups>entry,omitempty"`
+	Job          string        `xml:"job,omitempty"`
+}
+
+// DeviceGroup - Declare Panorama device group container of PAN API call
+type DeviceGroup struct {
+	Name    string   `xml:"name,attr"`
+	Devices []Device `xml:"devices>entry,omitempty"`
+}
+
+// Device - Declare Panorama managed device container of PAN API call
+type Device struct {
+	Serial    string `xml:"serial"`
+	Connected string `xml:"connected"`
+	Vsys      []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"vsys>entry,omitempty"`
 }
 
 // Entry - Declare Entry container of PAN API call
@@ -106,6 +124,9 @@ type Member struct {
 type PAN struct {
 	Key          string
 	URL          string
+	Vsys         string
+	Target       string // Serial of a Panorama managed firewall. Blank targets the device at URL.
+	DeviceGroup  string
 	FoundCounter int
 	RegIPs       map[string]IPTags
 }
@@ -121,12 +142,18 @@ type IPTags struct {
 var pce illumioapi.PCE
 var err error
 var noPrompt, addIPv6, update, insecure, clean, removeOld, changePersistent, noHref bool
-var panURL, panKey, panVsys, filterFile, timeout string
+var panURL, panKey, panVsys, filterFile, timeout, deviceGroups, reportFile string
+var batchSize int
+var commit bool
 
 func init() {
 	DAGSyncCmd.Flags().StringVarP(&panURL, "url", "u", "", "URL required to reach Panorama or PAN FW(requires https://).")
 	DAGSyncCmd.Flags().StringVarP(&panKey, "key", "k", "", "Key used to authenticate with Panorama or PAN FW.")
-	DAGSyncCmd.Flags().StringVarP(&panVsys, "vsys", "v", "vsys1", "Vsys used to progam registered IPs and tags.")
+	DAGSyncCmd.Flags().StringVarP(&panVsys, "vsys", "v", "vsys1", "Vsys used to progam registered IPs and tags. Comma-separated list for multiple vsys.")
+	DAGSyncCmd.Flags().StringVar(&deviceGroups, "device-groups", "", "Comma-separated list of Panorama device groups. Registered IPs are programmed on each connected firewall in the device groups.")
+	DAGSyncCmd.Flags().IntVar(&batchSize, "batch-size", 500, "Maximum number of registered IPs in each register or unregister API call.")
+	DAGSyncCmd.Flags().BoolVar(&commit, "commit", false, "Commit after making changes. On Panorama with --device-groups a commit-all is sent to each device group. Registered IPs do not require a commit, so the default is to skip it.")
+	DAGSyncCmd.Flags().StringVar(&reportFile, "report-file", "", "Name of the reconciliation report of tags on PanOS the PCE no longer justifies. Default is a timestamped filename.")
 	DAGSyncCmd.Flags().BoolVarP(&addIPv6, "ipv6", "6", false, "Include IPv6 addresses in the syncing of PCE IP and labels/tags with PAN DAGs")
 	DAGSyncCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Ignore SSL certificate validation when communicating with PAN.")
 	DAGSyncCmd.Flags().BoolVarP(&update, "update-panos", "", false, "Implement identified changes on PanOS (versus just logging by default).")
@@ -148,7 +175,13 @@ var DAGSyncCmd = &cobra.Command{
 	Long: `
 Syncs IPs and labels from PCE workloads to Dynamic Address Groups on Palo Alto Devices.
 
-The PANOS_URL, PANOS_KEY, and PANOS_VSYS environment variables can be used instead of the --url (-u), --key (-k), and --vsys (-v) flags, respectively.
+The PANOS_URL, PANOS_KEY, PANOS_VSYS, and PANOS_DEVICE_GROUPS environment variables can be used instead of the --url (-u), --key (-k), --vsys (-v), and --device-groups flags, respectively.
+
+When --url is Panorama, --device-groups programs the registered IPs on each connected firewall in the device groups. The vsys of each firewall in the device group are used. If the device group does not list vsys, the --vsys values are used.
+
+Register and unregister calls are sent in batches of --batch-size entries. Changes are not committed unless --commit is used.
+
+A reconciliation report lists the tags on PanOS that the PCE no longer justifies (the IP is not on a PCE workload or the label is no longer on the workload). The report is created even without --update-panos.
 
 All ipv4 or ipv6 link local addresses will always be ignored (169.254.0.0/16 or FE80::/10).
 
@@ -228,14 +261,21 @@ func httpSetUp(httpAction, apiURL string, body []byte, disableTLSChecking bool,
 
 //panHTTP - Function to setup HTTP POST with necessary headers and other requirements
 func (pan *PAN) callHTTP(cmdType string, cmd string) DagResponse {
+	return pan.callAPI(url.Values{"type": {cmdType}, "cmd": {cmd}})
+}
+
+//callAPI - Send the parameters to the PAN API. Adds the key, vsys, and target (Panorama managed firewall).
+func (pan *PAN) callAPI(urlInfo url.Values) DagResponse {
 
 	var dagResp DagResponse
 	apiURL := fmt.Sprintf("%s/api", pan.URL)
-	urlInfo := url.Values{}
 	urlInfo.Set("key", pan.Key)
-	urlInfo.Set("type", cmdType)
-	urlInfo.Set("cmd", cmd)
-	urlInfo.Set("vsys", panVsys)
+	if pan.Vsys != "" && urlInfo.Get("type") != "commit" {
+		urlInfo.Set("vsys", pan.Vsys)
+	}
+	if pan.Target != "" {
+		urlInfo.Set("target", pan.Target)
+	}
 
 	url, err := url.ParseRequestURI(apiURL)
 	if err != nil {
@@ -354,7 +394,7 @@ func (pan *PAN) LoadRegisteredIPs() {
 	//var tmpDagEntries = make(map[string][]string)
 
 	//Send Set VSYS API request.  panHttp check for success within the response message.  Fails if not successful.
-	setVsysCMD := fmt.Sprintf("<set><system><setting><target-vsys>%s</target-vsys></setting></system></set>", panVsys)
+	setVsysCMD := fmt.Sprintf("<set><system><setting><target-vsys>%s</target-vsys></setting></system></set>", pan.Vsys)
 	dagResp = pan.callHTTP("op", setVsysCMD)
 
 	//remove parameter so we can readd
@@ -450,15 +490,16 @@ func (pan *PAN) UnRegister(listRegisterIP map[string]IPTags) {
 			updateCounter++
 		}
 	}
-	request = DagRequest{Type: "update", Version: "2.0", Payload: Payload{Unregister: RegIPs{Entry: entries}}}
-
-	//Create and Send API call to PAN to unregister
-	xmlData, _ := xml.MarshalIndent(request, "", "")
-	dagResp := pan.callHTTP("user-id", string(xmlData))
-	if dagResp.Status != "success" {
-		utils.LogInfo("UnRegister API response received error. Check logs", true)
-		for _, entry := range dagResp.MSG.Line.UIDResponse.Payload.Unregister.Entry {
-			utils.LogInfo(fmt.Sprintf("Unregister received error - %s", entry), false)
+	//Create and Send API calls to PAN to unregister in batches
+	for _, batch := range batchEntries(entries) {
+		request = DagRequest{Type: "update", Version: "2.0", Payload: Payload{Unregister: RegIPs{Entry: batch}}}
+		xmlData, _ := xml.MarshalIndent(request, "", "")
+		dagResp := pan.callHTTP("user-id", string(xmlData))
+		if dagResp.Status != "success" {
+			utils.LogInfo("UnRegister API response received error. Check logs", true)
+			for _, entry := range dagResp.MSG.Line.UIDResponse.Payload.Unregister.Entry {
+				utils.LogInfo(fmt.Sprintf("Unregister received error - %s", entry), false)
+			}
 		}
 	}
 	utils.LogInfo(fmt.Sprintf("%d IP(s) removed + %d Tag(s) deleted from RegisteredIPs on PanOS", removeCounter, updateCounter), true)
@@ -485,21 +526,111 @@ func (pan *PAN) Register(listRegisterIP map[string]IPTags) {
 		entries = append(entries, Entry{IP: ip, FromAgent: "0", Persistent: p, Tag: Tag{Members: allMembers}})
 		utils.LogInfo(fmt.Sprintf("Register %s with the following labels %s", ip, ipTags.Labels), false)
 	}
-	request = DagRequest{Type: "update", Version: "2.0", Payload: Payload{Register: RegIPs{Entry: entries}}}
+	//Send API calls to PAN in batches
+	for _, batch := range batchEntries(entries) {
+		request = DagRequest{Type: "update", Version: "2.0", Payload: Payload{Register: RegIPs{Entry: batch}}}
+		xmlData, _ := xml.MarshalIndent(request, "", "")
+		dagResp := pan.callHTTP("user-id", string(xmlData))
+		if dagResp.Status != "success" {
+			utils.LogInfo("Register API response received error. Check logs", true)
+			for _, entry := range dagResp.MSG.Line.UIDResponse.Payload.Register.Entry {
+				utils.LogInfo(fmt.Sprintf("Register received error - %s", entry), false)
+			}
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("%d Registered changes will be made. For specifics check workloader.log", len(listRegisterIP)), true)
+}
+
+//batchEntries - split the entries into slices of at most batchSize entries
+func batchEntries(entries []Entry) [][]Entry {
+	if batchSize <= 0 {
+		return [][]Entry{entries}
+	}
+	batches := [][]Entry{}
+	for len(entries) > batchSize {
+		batches = append(batches, entries[:batchSize])
+		entries = entries[batchSize:]
+	}
+	if len(entries) > 0 {
+		batches = append(batches, entries)
+	}
+	return batches
+}
 
-	//If update set send api to PAN
+//targets - build the list of firewall/vsys combinations to program. Panorama device groups are expanded to their connected firewalls.
+func (pan *PAN) targets() []PAN {
+	vsysList := strings.Split(strings.ReplaceAll(panVsys, " ", ""), ",")
+	targets := []PAN{}
 
-	xmlData, _ := xml.MarshalIndent(request, "", "")
-	dagResp := pan.callHTTP("user-id", string(xmlData))
-	if dagResp.Status != "success" {
-		utils.LogInfo("Register API response received error. Check logs", true)
-		for _, entry := range dagResp.MSG.Line.UIDResponse.Payload.Register.Entry {
-			utils.LogInfo(fmt.Sprintf("Register received error - %s", entry), false)
+	if deviceGroups == "" {
+		for _, v := range vsysList {
+			targets = append(targets, PAN{Key: pan.Key, URL: pan.URL, Vsys: v, RegIPs: map[string]IPTags{}})
 		}
+		return targets
+	}
 
+	dgResp := pan.callHTTP("op", "<show><devicegroups></devicegroups></show>")
+	dgMap := make(map[string]DeviceGroup)
+	for _, dg := range dgResp.Result.DeviceGroups {
+		dgMap[dg.Name] = dg
+	}
+	for _, dgName := range strings.Split(deviceGroups, ",") {
+		dg, ok := dgMap[strings.TrimSpace(dgName)]
+		if !ok {
+			utils.LogError(fmt.Sprintf("device group %s does not exist on Panorama", dgName))
+		}
+		for _, d := range dg.Devices {
+			if strings.ToLower(d.Connected) != "yes" {
+				utils.LogWarning(fmt.Sprintf("%s in device group %s is not connected. skipping.", d.Serial, dg.Name), true)
+				continue
+			}
+			deviceVsys := []string{}
+			for _, v := range d.Vsys {
+				deviceVsys = append(deviceVsys, v.Name)
+			}
+			if len(deviceVsys) == 0 {
+				deviceVsys = vsysList
+			}
+			for _, v := range deviceVsys {
+				targets = append(targets, PAN{Key: pan.Key, URL: pan.URL, Vsys: v, Target: d.Serial, DeviceGroup: dg.Name, RegIPs: map[string]IPTags{}})
+			}
+		}
 	}
+	return targets
+}
 
-	utils.LogInfo(fmt.Sprintf("%d Registered changes will be made. For specifics check workloader.log", len(listRegisterIP)), true)
+//name - description of the target used in logging
+func (pan *PAN) name() string {
+	if pan.Target == "" {
+		return fmt.Sprintf("%s %s", pan.URL, pan.Vsys)
+	}
+	return fmt.Sprintf("%s (%s) %s", pan.Target, pan.DeviceGroup, pan.Vsys)
+}
+
+//commitChanges - commit the device or, for Panorama device groups, send a commit-all to each device group.
+func (pan *PAN) commitChanges() {
+	commitTargets := [][2]string{}
+	if deviceGroups == "" {
+		commitTargets = append(commitTargets, [2]string{"", "<commit></commit>"})
+	} else {
+		for _, dg := range strings.Split(deviceGroups, ",") {
+			dg = strings.TrimSpace(dg)
+			commitTargets = append(commitTargets, [2]string{"all", fmt.Sprintf("<commit-all><shared-policy><device-group><entry name=\"%s\"/></device-group></shared-policy></commit-all>", dg)})
+		}
+	}
+	for _, c := range commitTargets {
+		params := url.Values{"type": {"commit"}, "cmd": {c[1]}}
+		if c[0] != "" {
+			params.Set("action", c[0])
+		}
+		dagResp := pan.callAPI(params)
+		if dagResp.Status != "success" {
+			utils.LogWarning(fmt.Sprintf("commit was not successful - %s", c[1]), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("commit job %s started - %s", dagResp.Result.Job, c[1]), true)
+	}
 }
 
 //checkHAPrimary - make sure we are adding Registered IPs to primary PAN in a HA
@@ -555,7 +686,7 @@ func isEqual(a1 []string, a2 []string) (bool, []string, []string) {
 func dagSync() {
 
 	//Enter Start Log for PAN DAG Sync
-	utils.LogStartCommand(fmt.Sprintf("PanOS DAG Sync - change=%t, insecure=%t, ipv6=%t, flush=%t, rmeoveOld=%t", update, insecure, addIPv6, clean, removeOld))
+	utils.LogStartCommand(fmt.Sprintf("PanOS DAG Sync - change=%t, insecure=%t, ipv6=%t, flush=%t, rmeoveOld=%t, commit=%t", update, insecure, addIPv6, clean, removeOld, commit))
 
 	//Check for valid panURL, panKey, and panVsys values from OS environment vars or via CLI
 	if tmp := os.Getenv("PANOS_URL"); tmp != "" && panURL == "" {
@@ -577,6 +708,10 @@ func dagSync() {
 		utils.LogError("Default PanOS vsys=\"vsys1\".  To override must either use environment variable \"PANOS_VSYS\" or \"--vsys\" or \"-v\" with vsys value.")
 	}
 
+	if tmp := os.Getenv("PANOS_DEVICE_GROUPS"); tmp != "" && deviceGroups == "" {
+		deviceGroups = tmp
+	}
+
 	//default pan struct created.
 	pan := PAN{Key: panKey, URL: panURL, RegIPs: map[string]IPTags{}, FoundCounter: 0}
 
@@ -613,10 +748,6 @@ func dagSync() {
 		filter = append(filter, map[string]string{"role": row[0], "app": row[1], "env": row[2], "loc": row[3]})
 	}
 
-	//Get PAN registered IPs and Workload IPs from PAN/PCE
-	utils.LogInfo(fmt.Sprintf("Calling PanOS get All Registered-IP - %s", panURL), true)
-	pan.LoadRegisteredIPs()
-
 	//Get all Workloads from PCE.  Dont do if you are cleanup RegisteredIPs.
 	workloadsMap := make(map[string]IPTags)
 	if !clean {
@@ -625,51 +756,78 @@ func dagSync() {
 		utils.LogInfo(fmt.Sprintf("%d Workloads IPs on PCE.", len(workloadsMap)), true)
 	}
 
+	//Sync each firewall/vsys
+	changed := false
+	reportData := [][]string{{"target", "device_group", "vsys", "ip", "tag", "added_by_workloader", "reason"}}
+	for _, target := range pan.targets() {
+		if target.syncTarget(workloadsMap, &reportData) {
+			changed = true
+		}
+	}
+
+	//Write the reconciliation report
+	if len(reportData) > 1 {
+		if reportFile == "" {
+			reportFile = fmt.Sprintf("workloader-dag-sync-reconciliation-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(reportData, reportData, reportFile)
+		utils.LogInfo(fmt.Sprintf("%d tags on PanOS are no longer justified by the PCE. see %s", len(reportData)-1, reportFile), true)
+	}
+
+	//Commit if requested
+	if changed && commit {
+		pan.commitChanges()
+	} else if changed {
+		utils.LogInfo("skipping commit. use --commit to commit the changes.", true)
+	}
+
+	utils.LogEndCommand("dag-sync")
+}
+
+//syncTarget - sync the registered IPs on a single firewall/vsys. Returns true if changes were sent to PanOS.
+func (pan *PAN) syncTarget(workloadsMap map[string]IPTags, reportData *[][]string) bool {
+
+	//Get PAN registered IPs
+	utils.LogInfo(fmt.Sprintf("Calling PanOS get All Registered-IP - %s", pan.name()), true)
+	pan.LoadRegisteredIPs()
+
 	//clear RegisterIPs and exit.  Make sure user adds --update-panos. Prompt user to make sure they want to do this..
 	if clean && len(pan.RegIPs) != 0 {
 		if !noPrompt && update {
 			var prompt string
-			fmt.Printf("\r\n%s [PROMPT] - %d Total RegisteredIPs %d Registered changes will be made . Do you want to continue (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pan.FoundCounter, len(pan.RegIPs))
+			fmt.Printf("\r\n%s [PROMPT] - %s - %d Total RegisteredIPs %d Registered changes will be made . Do you want to continue (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pan.name(), pan.FoundCounter, len(pan.RegIPs))
 			fmt.Scanln(&prompt)
 			if strings.ToLower(prompt) != "yes" {
 				utils.LogInfo(fmt.Sprintf("prompt denied flushing %d of total %d RegisteredIP.", pan.FoundCounter, len(pan.RegIPs)), true)
-				utils.LogEndCommand("wkld-import")
-				return
+				return false
 			}
 		}
 		if !update {
 			utils.LogInfo(fmt.Sprintf("%d Register changes will NOT be made - must enter \"--update-panos\" to make changes to PAN!!!", len(pan.RegIPs)), true)
-			utils.LogEndCommand("dag-sync")
-			return
-		} else {
-			utils.LogInfo(fmt.Sprintf("Flushing %d Register-IPs", len(pan.RegIPs)), true)
-			pan.UnRegister(pan.RegIPs)
-			utils.LogEndCommand("dag-sync")
-			return
+			return false
 		}
+		utils.LogInfo(fmt.Sprintf("Flushing %d Register-IPs", len(pan.RegIPs)), true)
+		pan.UnRegister(pan.RegIPs)
+		return true
 	}
 
 	//If there are no entries from PAN to match against just add all the workloads.
 	if len(pan.RegIPs) == 0 && len(workloadsMap) != 0 {
 		if !noPrompt && update {
 			var prompt string
-			fmt.Printf("\r\n%s [PROMPT] - %d Registers changes will be made. Do you want to make these changes (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(workloadsMap))
+			fmt.Printf("\r\n%s [PROMPT] - %s - %d Registers changes will be made. Do you want to make these changes (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pan.name(), len(workloadsMap))
 			fmt.Scanln(&prompt)
 			if strings.ToLower(prompt) != "yes" {
 				utils.LogInfo(fmt.Sprintf("prompt denied to registered %d IPs/Tags.", len(workloadsMap)), true)
-				utils.LogEndCommand("wkld-import")
-				return
+				return false
 			}
 		}
 		if !update {
 			utils.LogInfo(fmt.Sprintf("%d Register changes will NOT be made - must enter \"--update-panos\" to make changes to PanOS!!!", len(workloadsMap)), true)
-			utils.LogEndCommand("dag-sync")
-			return
-		} else {
-			pan.Register(workloadsMap)
-			utils.LogEndCommand("dag-sync")
-			return
+			return false
 		}
+		pan.Register(workloadsMap)
+		return true
 	}
 
 	//Cycle through Workload list as long as there are labels/tags continue.  Build arrays of IPs/Tags to Add/Remove.
@@ -694,6 +852,9 @@ func dagSync() {
 			}
 			if len(removeLabels) != 0 {
 				unregEntries[ip] = IPTags{Labels: removeLabels, Found: true, HrefLabel: pan.RegIPs[ip].HrefLabel}
+				for _, l := range removeLabels {
+					*reportData = append(*reportData, []string{pan.Target, pan.DeviceGroup, pan.Vsys, ip, l, strconv.FormatBool(pan.RegIPs[ip].Found), "label no longer on workload"})
+				}
 			}
 			//If labels are equal but we didnt find a workload tag then add it.
 		} else if !pan.RegIPs[ip].Found {
@@ -707,6 +868,9 @@ func dagSync() {
 	countNotFoundStaleIP := 0
 	for ip, ipTags := range pan.RegIPs {
 		if _, ok := workloadsMap[ip]; !ok {
+			for _, l := range ipTags.Labels {
+				*reportData = append(*reportData, []string{pan.Target, pan.DeviceGroup, pan.Vsys, ip, l, strconv.FormatBool(ipTags.Found), "ip not on a pce workload"})
+			}
 			if removeOld && (ipTags.Found || noHref) {
 				unregEntries[ip] = IPTags{}
 				countStaleIPs++
@@ -724,20 +888,23 @@ func dagSync() {
 	}
 
 	if len(regEntries) == 0 && len(unregEntries) == 0 {
-		utils.LogInfo("No Change. No Add/Update/Removals needed on PanOS.", true)
-		utils.LogEndCommand("dag-sync")
-		return
+		utils.LogInfo(fmt.Sprintf("No Change. No Add/Update/Removals needed on %s.", pan.name()), true)
+		return false
+	}
+
+	if !update {
+		utils.LogInfo(fmt.Sprintf("%d Register and %d Unregister changes will NOT be made - must enter \"--update-panos\" to make changes to PanOS!!!", len(regEntries), len(unregEntries)), true)
+		return false
 	}
 
-	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	// If update is set, but not noPrompt, we will prompt the user.
 	if update && !noPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - %d Register and %d Unregister changes will be made. Do you want to make these changes (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(regEntries), len(unregEntries))
+		fmt.Printf("\r\n%s [PROMPT] - %s - %d Register and %d Unregister changes will be made. Do you want to make these changes (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), pan.name(), len(regEntries), len(unregEntries))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo(fmt.Sprintf("prompt denied to registered %d and unregistered %d IPs/Tags.", len(regEntries), len(unregEntries)), true)
-			utils.LogEndCommand("wkld-import")
-			return
+			return false
 		}
 	}
 	if len(regEntries) != 0 {
@@ -747,5 +914,5 @@ func dagSync() {
 	if len(unregEntries) != 0 {
 		pan.UnRegister(unregEntries)
 	}
-	utils.LogEndCommand("dag-sync")
+	return true
 }
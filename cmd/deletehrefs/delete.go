@@ -33,22 +33,35 @@ func init() {
 
 // DeleteCmd runs the unpair
 var DeleteCmd = &cobra.Command{
-	Use:   "delete [csv file with hrefs to delete or semi-colon separate list of hrefs]",
-	Short: "Delete any object with an HREF (e.g., unmanaged workloads, labels, services, IPLists, etc.) from the PCE.",
+	Use:         "delete [csv file with hrefs to delete or semi-colon separate list of hrefs]",
+	Short:       "Delete any object with an HREF (e.g., unmanaged workloads, labels, services, IPLists, etc.) from the PCE.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true"},
 	Long: `  
-Delete any object with an HREF (e.g., unmanaged workloads, labels, services, IPLists, etc.) from the PCE.`,
+Delete any object with an HREF (e.g., unmanaged workloads, labels, services, IPLists, etc.) from the PCE.
+
+The argument can be left off to delete the hrefs in the --hrefs-file.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		input.PCE, err = utils.GetTargetPCE(true)
 		if err != nil {
 			utils.LogError(err.Error())
 		}
 
-		// Set the CSV file
-		if len(args) != 1 {
+		// Set the CSV file. The hrefs file is used if there is no argument and limits the hrefs if there is one.
+		if len(args) == 0 && utils.HrefsFile() != "" {
+			input.Hrefs = utils.HrefsFileHrefs()
+		} else if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		} else {
+			input.getHrefs(args[0])
+			hrefs := []string{}
+			for _, href := range input.Hrefs {
+				if utils.InHrefsFile(href) {
+					hrefs = append(hrefs, href)
+				}
+			}
+			input.Hrefs = hrefs
 		}
-		input.getHrefs(args[0])
 
 		// Get persistent flags from Viper
 		input.UpdatePCE = viper.Get("update_pce").(bool)
@@ -165,7 +178,7 @@ func DeleteHrefs(input Input) {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if input.UpdatePCE && !input.NoPrompt {
 		var prompt string
-		fmt.Printf("\r\n[PROMPT] - workloader identified %d objects to attempt to delete in %s (%s). Do you want to run the delete (yes/no)? ", len(input.Hrefs), input.PCE.FriendlyName, viper.Get(input.PCE.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n[PROMPT] - workloader identified %d objects to attempt to delete in %s (%s). Do you want to run the delete (yes/no)? ", len(input.Hrefs), input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied.", true)
@@ -188,6 +201,7 @@ func DeleteHrefs(input Input) {
 		utils.LogAPIResp("DeleteHref", a)
 		if a.StatusCode != 204 {
 			utils.LogWarning(fmt.Sprintf("%s - not deleted - status code %d", href, a.StatusCode), true)
+			utils.RecordFailure(fmt.Sprintf("%s - not deleted - status code %d", href, a.StatusCode))
 			skipped++
 		} else if a.StatusCode == 204 {
 			// Increment the delete and log
@@ -0,0 +1,73 @@
+package dependencymap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var apps, formats, outputFileName string
+var days, maxResults int
+var appGroupLoc, ipLists, ignoreIP, perApp, exclBlocked bool
+
+func init() {
+	DependencyMapCmd.Flags().StringVar(&apps, "app", "", "comma-separated app label values to limit the map to flows with those apps as a source or destination. default is all apps.")
+	DependencyMapCmd.Flags().BoolVar(&perApp, "per-app", false, "write a map for each app (or each --app) with the flows to and from it instead of one map.")
+	DependencyMapCmd.Flags().IntVar(&days, "days", 30, "days of traffic to map.")
+	DependencyMapCmd.Flags().BoolVarP(&appGroupLoc, "appgrp-loc", "l", false, "use location in app group.")
+	DependencyMapCmd.Flags().BoolVar(&ipLists, "ip-lists", false, "group ip addresses by their ip lists instead of one node per ip address.")
+	DependencyMapCmd.Flags().BoolVarP(&ignoreIP, "ignore-ip", "i", false, "exclude ip addresses from the map.")
+	DependencyMapCmd.Flags().BoolVar(&exclBlocked, "excl-blocked", false, "exclude blocked flows.")
+	DependencyMapCmd.Flags().StringVar(&formats, "formats", "dot,mermaid,json", "comma-separated formats to write. options are dot, mermaid, and json.")
+	DependencyMapCmd.Flags().IntVar(&maxResults, "max-results", 100000, "max results for the traffic query.")
+	DependencyMapCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the base name of the output files. the format extension (and app with --per-app) is added. default is current location with a timestamped filename.")
+	DependencyMapCmd.Flags().SortFlags = false
+}
+
+// DependencyMapCmd exports an app group dependency map
+var DependencyMapCmd = &cobra.Command{
+	Use:   "dependency-map",
+	Short: "Export an app group dependency map from traffic as Graphviz DOT, Mermaid, and JSON.",
+	Long: `
+Export an app group dependency map from traffic as Graphviz DOT, Mermaid, and JSON.
+
+Each node is an app group (app | env, or app | env | loc with --appgrp-loc) or an ip address. Workloads without an app and env label are the NO APP GROUP node. Use --ip-lists to group ip addresses by their ip lists or --ignore-ip to exclude them.
+
+Each edge is the traffic from a source to a destination app group with the services, flows, connections, and policy decisions. Edges are green when every flow is allowed, orange when any flow is potentially blocked, and red when any flow is blocked. The diagram edge label has the top 3 services. The json has every service.
+
+Use --app to limit the map to flows to and from some apps and --per-app to write a map for each app. Render the dot file with Graphviz (e.g., dot -Tsvg map.dot -o map.svg) and paste the mermaid file in a markdown code block or the Mermaid live editor.
+
+The files are written to the output directory or remote destination. --format does not apply.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Map all app groups over the last 30 days
+  workloader dependency-map
+
+  # Write a mermaid map for each of two apps with ip addresses grouped by ip list
+  workloader dependency-map --app ERP,CRM --per-app --ip-lists --formats mermaid`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		for _, f := range strings.Split(formats, ",") {
+			if f = strings.TrimSpace(f); f != "dot" && f != "mermaid" && f != "json" {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a valid format. options are dot, mermaid, and json", f))
+			}
+		}
+		if days <= 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--days must be positive")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("dependency-map")
+		dependencyMap()
+		utils.LogEndCommand("dependency-map")
+	},
+}
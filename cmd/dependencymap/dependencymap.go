@@ -0,0 +1,337 @@
+package dependencymap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Node types
+const (
+	nodeAppGroup = "app_group"
+	nodeIP       = "ip"
+	nodeIPList   = "ip_list"
+)
+
+// Edge colors by the most restrictive policy decision
+var decisionColors = map[string]string{"allowed": "#2e7d32", "potentially_blocked": "#ef6c00", "blocked": "#c62828"}
+
+// graph is the app group dependency map
+type graph struct {
+	PCE       string  `json:"pce"`
+	Generated string  `json:"generated"`
+	Days      int     `json:"days"`
+	App       string  `json:"app,omitempty"`
+	Nodes     []*node `json:"nodes"`
+	Edges     []*edge `json:"edges"`
+}
+
+// node is an app group or ip address
+type node struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// edge is the traffic from a source to a destination node
+type edge struct {
+	Source          string         `json:"source"`
+	Destination     string         `json:"destination"`
+	Flows           int            `json:"flows"`
+	Connections     int            `json:"connections"`
+	PolicyDecisions map[string]int `json:"policy_decisions"`
+	Services        []service      `json:"services"`
+	services        map[string]int
+}
+
+// service is a port and protocol with its connections
+type service struct {
+	Service     string `json:"service"`
+	Connections int    `json:"connections"`
+}
+
+// decision returns the most restrictive policy decision of an edge
+func (e *edge) decision() string {
+	for _, d := range []string{"blocked", "potentially_blocked"} {
+		if e.PolicyDecisions[d] > 0 {
+			return d
+		}
+	}
+	return "allowed"
+}
+
+// label returns the diagram label of an edge with the top 3 services
+func (e *edge) label() string {
+	names := []string{}
+	for i, s := range e.Services {
+		if i == 3 {
+			names = append(names, fmt.Sprintf("+%d more", len(e.Services)-3))
+			break
+		}
+		names = append(names, s.Service)
+	}
+	return strings.Join(names, ", ")
+}
+
+func dependencyMap() {
+
+	// Load the labels
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the app label hrefs
+	appValues := []string{}
+	appHrefs := [][]string{}
+	for _, a := range strings.Split(apps, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		label, ok := pce.Labels["app"+a]
+		if !ok {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s does not exist as an app label", a))
+		}
+		appValues = append(appValues, a)
+		appHrefs = append(appHrefs, []string{label.Href})
+	}
+
+	// Get the traffic. Apps are queried as sources and then as destinations.
+	utils.LogInfo(fmt.Sprintf("getting traffic for the last %d days", days), true)
+	pStatus := []string{"allowed", "potentially_blocked"}
+	if !exclBlocked {
+		pStatus = append(pStatus, "blocked")
+	}
+	tq := illumioapi.TrafficQuery{
+		StartTime:                       time.Now().AddDate(0, 0, -days),
+		EndTime:                         time.Now(),
+		PolicyStatuses:                  pStatus,
+		MaxFLows:                        maxResults,
+		TransmissionExcludes:            []string{"broadcast", "multicast"},
+		ExcludeWorkloadsFromIPListQuery: true,
+		SourcesInclude:                  appHrefs}
+	traffic, a, err := pce.GetTrafficAnalysis(tq)
+	utils.LogAPIResp("GetTrafficAnalysis", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if len(appHrefs) > 0 {
+		tq.SourcesInclude, tq.DestinationsInclude = [][]string{}, appHrefs
+		traffic2, a, err := pce.GetTrafficAnalysis(tq)
+		utils.LogAPIResp("GetTrafficAnalysis", a)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		traffic = illumioapi.DedupeExplorerTraffic(traffic, traffic2)
+	}
+	utils.LogInfo(fmt.Sprintf("%d flows", len(traffic)), true)
+	if len(traffic) >= maxResults {
+		utils.LogWarning(fmt.Sprintf("the traffic query reached the maximum of %d results. the map may be incomplete.", maxResults), true)
+	}
+
+	// Get the base file name
+	base := outputFileName
+	if base == "" {
+		base = fmt.Sprintf("workloader-dependency-map-%s", time.Now().Format("20060102_150405"))
+	}
+	if ext := filepath.Ext(base); ext == ".dot" || ext == ".mmd" || ext == ".json" {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	if !perApp {
+		write(buildGraph(traffic, ""), base)
+		return
+	}
+
+	// Write a map for each app. The apps are the --app values or every app in the traffic.
+	if len(appValues) == 0 {
+		inTraffic := make(map[string]bool)
+		for _, t := range traffic {
+			for _, w := range []*illumioapi.Workload{t.Src.Workload, t.Dst.Workload} {
+				if w != nil {
+					if app := w.GetApp(pce.Labels).Value; app != "" && !inTraffic[app] {
+						inTraffic[app] = true
+						appValues = append(appValues, app)
+					}
+				}
+			}
+		}
+		sort.Strings(appValues)
+	}
+	unsafe := regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	for _, app := range appValues {
+		write(buildGraph(traffic, app), fmt.Sprintf("%s-%s", base, unsafe.ReplaceAllString(app, "_")))
+	}
+}
+
+// buildGraph returns the map of the traffic. If app is not blank, only flows to or from the app are included.
+func buildGraph(traffic []illumioapi.TrafficAnalysis, app string) graph {
+	g := graph{PCE: pce.FriendlyName, Generated: time.Now().Format(time.RFC3339), Days: days, App: app}
+	nodes := make(map[string]*node)
+	edges := make(map[[2]string]*edge)
+	protocols := illumioapi.ProtocolList()
+
+	for _, t := range traffic {
+		if t.Src == nil || t.Dst == nil {
+			continue
+		}
+		if app != "" && !hasApp(t.Src.Workload, app) && !hasApp(t.Dst.Workload, app) {
+			continue
+		}
+		src, srcType := nodeID(t.Src.IP, t.Src.Workload, t.Src.IPLists)
+		dst, dstType := nodeID(t.Dst.IP, t.Dst.Workload, t.Dst.IPLists)
+		if src == "" || dst == "" {
+			continue
+		}
+		for id, nodeType := range map[string]string{src: srcType, dst: dstType} {
+			if _, ok := nodes[id]; !ok {
+				nodes[id] = &node{ID: id, Type: nodeType}
+				g.Nodes = append(g.Nodes, nodes[id])
+			}
+		}
+
+		e, ok := edges[[2]string{src, dst}]
+		if !ok {
+			e = &edge{Source: src, Destination: dst, PolicyDecisions: make(map[string]int), Services: []service{}, services: make(map[string]int)}
+			edges[[2]string{src, dst}] = e
+			g.Edges = append(g.Edges, e)
+		}
+		e.Flows++
+		e.Connections += t.NumConnections
+		e.PolicyDecisions[t.PolicyDecision]++
+		if t.ExpSrv != nil {
+			svc := protocols[t.ExpSrv.Proto]
+			if t.ExpSrv.Port != 0 {
+				svc = fmt.Sprintf("%d %s", t.ExpSrv.Port, svc)
+			}
+			e.services[svc] += t.NumConnections
+		}
+	}
+
+	// Sort the nodes, edges, and services for stable output
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+		return g.Edges[i].Destination < g.Edges[j].Destination
+	})
+	for _, e := range g.Edges {
+		for s, c := range e.services {
+			e.Services = append(e.Services, service{Service: s, Connections: c})
+		}
+		sort.Slice(e.Services, func(i, j int) bool {
+			if e.Services[i].Connections != e.Services[j].Connections {
+				return e.Services[i].Connections > e.Services[j].Connections
+			}
+			return e.Services[i].Service < e.Services[j].Service
+		})
+	}
+	return g
+}
+
+// hasApp returns true if the workload has the app label value
+func hasApp(w *illumioapi.Workload, app string) bool {
+	return w != nil && w.GetApp(pce.Labels).Value == app
+}
+
+// nodeID returns the node and node type of a traffic source or destination. The node is blank if ip addresses are ignored.
+func nodeID(ip string, w *illumioapi.Workload, lists *[]*illumioapi.IPList) (string, string) {
+	if w != nil {
+		if appGroupLoc {
+			return w.GetAppGroupL(pce.Labels), nodeAppGroup
+		}
+		return w.GetAppGroup(pce.Labels), nodeAppGroup
+	}
+	if ignoreIP {
+		return "", ""
+	}
+	if ipLists && lists != nil {
+		names := []string{}
+		for _, l := range *lists {
+			if l != nil && l.Name != "" {
+				names = append(names, l.Name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			return strings.Join(names, "; "), nodeIPList
+		}
+	}
+	return ip, nodeIP
+}
+
+// write writes the graph in each format
+func write(g graph, base string) {
+	if len(g.Edges) == 0 {
+		utils.LogInfo(fmt.Sprintf("no flows to map for %s", base), true)
+		return
+	}
+	utils.LogInfo(fmt.Sprintf("%d nodes and %d edges in %s", len(g.Nodes), len(g.Edges), base), true)
+	for _, f := range strings.Split(formats, ",") {
+		switch strings.TrimSpace(f) {
+		case "dot":
+			utils.WriteFileOutput(dot(g), base+".dot")
+		case "mermaid":
+			utils.WriteFileOutput(mermaid(g), base+".mmd")
+		case "json":
+			data, err := json.MarshalIndent(g, "", "  ")
+			if err != nil {
+				utils.LogError(fmt.Sprintf("creating json - %s", err))
+			}
+			utils.WriteFileOutput(data, base+".json")
+		}
+	}
+}
+
+// dot returns the graph as Graphviz DOT
+func dot(g graph) []byte {
+	var b bytes.Buffer
+	quote := func(s string) string {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	b.WriteString("digraph dependencies {\n  rankdir=LR;\n  node [shape=box, style=\"rounded,filled\", fillcolor=\"#e6ecf3\", fontname=\"Helvetica\"];\n  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, n := range g.Nodes {
+		if n.Type == nodeAppGroup {
+			fmt.Fprintf(&b, "  %s;\n", quote(n.ID))
+		} else {
+			fmt.Fprintf(&b, "  %s [shape=ellipse, fillcolor=\"#f5f5f5\"];\n", quote(n.ID))
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, color=%s];\n", quote(e.Source), quote(e.Destination), quote(e.label()), quote(decisionColors[e.decision()]))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// mermaid returns the graph as a Mermaid flowchart. Node ids are generated because app groups have spaces and pipes.
+func mermaid(g graph) []byte {
+	var b bytes.Buffer
+	escape := func(s string) string { return strings.ReplaceAll(s, `"`, "#quot;") }
+	ids := make(map[string]string)
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i+1)
+		if n.Type == nodeAppGroup {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n.ID], escape(n.ID))
+		} else {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", ids[n.ID], escape(n.ID))
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[e.Source], escape(e.label()), ids[e.Destination])
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(&b, "  linkStyle %d stroke:%s\n", i, decisionColors[e.decision()])
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,80 @@
+package dhcpimport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var format, scopesStr, externalDataSet, outputFileName string
+var scopes []*net.IPNet
+var updatePCE, noPrompt bool
+var err error
+
+func init() {
+	DHCPImportCmd.Flags().StringVar(&format, "format", "auto", "lease export format: isc, windows, or auto. auto uses windows for .csv files and isc for others.")
+	DHCPImportCmd.Flags().StringVar(&scopesStr, "scopes", "", "comma-separated list of dhcp scope cidrs (e.g., 10.1.0.0/16,10.2.0.0/24). unmanaged workload ips outside the scopes are not flagged as stale. default is all ips.")
+	DHCPImportCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "", "only check unmanaged workloads in this external data set (e.g., dns-import). default is all unmanaged workloads.")
+	DHCPImportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	DHCPImportCmd.Flags().SortFlags = false
+}
+
+// DHCPImportCmd runs the dhcp-import command
+var DHCPImportCmd = &cobra.Command{
+	Use:         "dhcp-import [lease file]",
+	Short:       "Update unmanaged workload interfaces from DHCP leases and flag unmanaged workloads with stale ips.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Update unmanaged workload interfaces from DHCP leases and flag unmanaged workloads with stale ips.
+
+Supported lease files are an ISC dhcpd.leases file and a Windows DHCP csv from the following PowerShell:
+Get-DhcpServerv4Scope | Get-DhcpServerv4Lease | Export-Csv leases.csv -NoTypeInformation
+
+Only active leases that are not expired are used. Unmanaged workloads are matched to leases by hostname (full or short name, case insensitive).
+
+If the matched lease ip is not on the unmanaged workload, the interface with an ip that is not in any lease is changed to the lease ip (the first interface if all ips are in leases). The interface name and other interfaces are kept. Changes are applied with wkld-import.
+
+Unmanaged workloads that do not match a lease and have an ip in the --scopes that is not in any lease are flagged as stale in the output.
+
+The output status is current, updated, stale, or no-lease.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if len(args) != 1 {
+			fmt.Println("Command requires 1 argument for the lease file. See usage help.")
+			return
+		}
+		if format != "auto" && format != "isc" && format != "windows" {
+			utils.LogError("--format must be isc, windows, or auto")
+		}
+		for _, s := range strings.Split(scopesStr, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			_, ipNet, err := net.ParseCIDR(strings.TrimSpace(s))
+			if err != nil {
+				utils.LogError(fmt.Sprintf("invalid scope %s - %s", s, err))
+			}
+			scopes = append(scopes, ipNet)
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		dhcpImport(args[0])
+	},
+}
@@ -0,0 +1,170 @@
+package dhcpimport
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+func dhcpImport(leaseFile string) {
+
+	utils.LogStartCommand("dhcp-import")
+
+	leases, err := parseLeases(leaseFile, format)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d active leases in %s", len(leases), leaseFile), true)
+	leaseByName := make(map[string]lease)
+	leasedIPs := make(map[string]bool)
+	for _, l := range leases {
+		leasedIPs[l.ip] = true
+		if l.hostname == "" {
+			continue
+		}
+		leaseByName[strings.ToLower(l.hostname)] = l
+		leaseByName[strings.ToLower(strings.Split(l.hostname, ".")[0])] = l
+	}
+
+	// Get the unmanaged workloads
+	qp := map[string]string{"managed": "false"}
+	if externalDataSet != "" {
+		qp["external_data_set"] = externalDataSet
+	}
+	wklds, api, err := pce.GetWklds(qp)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d unmanaged workloads", len(wklds)), true)
+
+	data := [][]string{{wkldexport.HeaderHostname, wkldexport.HeaderHref, "current_interfaces", "lease_ip", "lease_mac", "lease_expires", "new_interfaces", "status"}}
+	importData := [][]string{{wkldexport.HeaderHref, wkldexport.HeaderInterfaces}}
+	counts := make(map[string]int)
+	for _, w := range wklds {
+		current := interfaceString(w.Interfaces)
+		l, ok := leaseByName[strings.ToLower(w.Hostname)]
+		if !ok {
+			l, ok = leaseByName[strings.ToLower(strings.Split(w.Hostname, ".")[0])]
+		}
+
+		// No lease for the hostname
+		if !ok {
+			status := "no-lease"
+			for _, i := range w.Interfaces {
+				if inScope(i.Address) && !leasedIPs[i.Address] {
+					status = "stale"
+					break
+				}
+			}
+			counts[status]++
+			data = append(data, []string{w.Hostname, w.Href, current, "", "", "", "", status})
+			continue
+		}
+
+		expires := ""
+		if !l.expires.IsZero() {
+			expires = l.expires.Format(time.RFC3339)
+		}
+
+		// Lease ip is already on the workload
+		found := false
+		for _, i := range w.Interfaces {
+			if i.Address == l.ip {
+				found = true
+			}
+		}
+		if found {
+			counts["current"]++
+			data = append(data, []string{w.Hostname, w.Href, current, l.ip, l.mac, expires, "", "current"})
+			continue
+		}
+
+		// Replace the interface of the same ip version without a lease or the first interface
+		newInterfaces := []*illumioapi.Interface{}
+		replace := -1
+		for n, i := range w.Interfaces {
+			if !leasedIPs[i.Address] && isIPv4(i.Address) == isIPv4(l.ip) {
+				replace = n
+				break
+			}
+		}
+		if replace == -1 {
+			replace = 0
+		}
+		for n, i := range w.Interfaces {
+			if n == replace {
+				newInterfaces = append(newInterfaces, &illumioapi.Interface{Name: i.Name, Address: l.ip})
+				continue
+			}
+			newInterfaces = append(newInterfaces, i)
+		}
+		if len(newInterfaces) == 0 {
+			newInterfaces = append(newInterfaces, &illumioapi.Interface{Name: "umwl0", Address: l.ip})
+		}
+		counts["updated"]++
+		newStr := interfaceString(newInterfaces)
+		data = append(data, []string{w.Hostname, w.Href, current, l.ip, l.mac, expires, newStr, "updated"})
+		importData = append(importData, []string{w.Href, newStr})
+		utils.LogInfo(fmt.Sprintf("%s - %s - interfaces %s to %s", w.Hostname, w.Href, current, newStr), false)
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-dhcp-import-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d current, %d to update, %d stale, %d with no lease", counts["current"], counts["updated"], counts["stale"], counts["no-lease"]), true)
+
+	if len(importData) > 1 {
+		importFile := fmt.Sprintf("workloader-dhcp-import-interfaces-%s.csv", time.Now().Format("20060102_150405"))
+		utils.WriteOutput(importData, importData, importFile)
+		wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+			PCE:             pce,
+			Data:            importData,
+			MatchString:     wkldexport.HeaderHref,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			UpdateWorkloads: true,
+		})
+	}
+
+	utils.LogEndCommand("dhcp-import")
+}
+
+// interfaceString returns interfaces in the wkld-import format (e.g., eth0:10.0.0.1/24;eth1:10.0.1.1)
+func interfaceString(interfaces []*illumioapi.Interface) string {
+	s := []string{}
+	for _, i := range interfaces {
+		entry := i.Name + ":" + i.Address
+		if i.CidrBlock != nil && *i.CidrBlock != 0 {
+			entry = entry + "/" + strconv.Itoa(*i.CidrBlock)
+		}
+		s = append(s, entry)
+	}
+	return strings.Join(s, ";")
+}
+
+// inScope returns true if the ip is in a dhcp scope or no scopes are provided
+func inScope(ip string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	for _, s := range scopes {
+		if s.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPv4(ip string) bool {
+	return net.ParseIP(ip).To4() != nil
+}
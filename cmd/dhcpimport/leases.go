@@ -0,0 +1,168 @@
+package dhcpimport
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// lease is an active dhcp lease normalized from the export
+type lease struct {
+	ip       string
+	mac      string
+	hostname string
+	expires  time.Time // zero for reservations and infinite leases
+}
+
+// parseLeases parses an isc dhcpd.leases file or a windows Get-DhcpServerv4Lease csv export. Expired and inactive leases are skipped.
+func parseLeases(filename, format string) ([]lease, error) {
+	if format == "auto" {
+		format = "isc"
+		if strings.HasSuffix(strings.ToLower(filename), ".csv") {
+			format = "windows"
+		}
+	}
+	if format == "windows" {
+		return parseWindows(filename)
+	}
+	return parseISC(filename)
+}
+
+// parseISC parses an isc dhcpd.leases file. Later entries for an ip replace earlier ones as in dhcpd.
+func parseISC(filename string) ([]lease, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	byIP := make(map[string]lease)
+	order := []string{}
+	active := make(map[string]bool)
+	var current *lease
+	state := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+		switch {
+		case fields[0] == "lease" && len(fields) >= 2:
+			current = &lease{ip: fields[1]}
+			state = ""
+		case fields[0] == "}" && current != nil:
+			if _, ok := byIP[current.ip]; !ok {
+				order = append(order, current.ip)
+			}
+			byIP[current.ip] = *current
+			active[current.ip] = state == "active" && (current.expires.IsZero() || current.expires.After(time.Now()))
+			current = nil
+		case current == nil:
+			continue
+		case fields[0] == "binding" && len(fields) >= 3 && fields[1] == "state":
+			state = fields[2]
+		case fields[0] == "hardware" && len(fields) >= 3:
+			current.mac = normalizeMAC(fields[2])
+		case fields[0] == "client-hostname" && len(fields) >= 2:
+			current.hostname = strings.Trim(strings.Join(fields[1:], " "), "\"")
+		case fields[0] == "ends" && len(fields) >= 2:
+			current.expires = parseISCTime(fields[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	leases := []lease{}
+	for _, ip := range order {
+		if active[ip] {
+			leases = append(leases, byIP[ip])
+		}
+	}
+	return leases, nil
+}
+
+// parseISCTime parses the time of an ends statement (e.g., 4 2024/01/04 10:00:00, epoch 1704362400, or never)
+func parseISCTime(fields []string) time.Time {
+	switch {
+	case fields[0] == "never":
+		return time.Time{}
+	case fields[0] == "epoch" && len(fields) >= 2:
+		if e, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			return time.Unix(e, 0)
+		}
+	case len(fields) >= 3:
+		if t, err := time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseWindows parses a csv from Get-DhcpServerv4Lease | Export-Csv. Only active leases and reservations are returned.
+func parseWindows(filename string) ([]lease, error) {
+	rows, err := utils.ParseCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Export-Csv adds a #TYPE row before the headers in older versions of powershell
+	if len(rows) > 0 && len(rows[0]) > 0 && strings.HasPrefix(rows[0][0], "#TYPE") {
+		rows = rows[1:]
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", filename)
+	}
+	headers := make(map[string]int)
+	for i, h := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, h := range []string{"ipaddress", "hostname"} {
+		if _, ok := headers[h]; !ok {
+			return nil, fmt.Errorf("%s does not have a %s header", filename, h)
+		}
+	}
+	get := func(row []string, header string) string {
+		if i, ok := headers[header]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	leases := []lease{}
+	for _, row := range rows[1:] {
+		if state := get(row, "addressstate"); state != "" && !strings.HasPrefix(strings.ToLower(state), "active") {
+			continue
+		}
+		l := lease{ip: get(row, "ipaddress"), mac: normalizeMAC(get(row, "clientid")), hostname: get(row, "hostname")}
+		if net.ParseIP(l.ip) == nil {
+			continue
+		}
+		if exp := get(row, "leaseexpirytime"); exp != "" {
+			for _, layout := range []string{"1/2/2006 3:04:05 PM", "2006-01-02 15:04:05", "2006-01-02T15:04:05", time.RFC3339} {
+				if t, err := time.ParseInLocation(layout, exp, time.Local); err == nil {
+					l.expires = t
+					break
+				}
+			}
+			if !l.expires.IsZero() && l.expires.Before(time.Now()) {
+				continue
+			}
+		}
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+// normalizeMAC converts aa-bb-cc-dd-ee-ff and AA:BB:CC:DD:EE:FF to aa:bb:cc:dd:ee:ff
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
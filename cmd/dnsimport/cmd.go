@@ -0,0 +1,84 @@
+package dnsimport
+
+import (
+	"regexp"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var zoneFiles, axfrServer, zones, includeStr, excludeStr, parserFile, externalDataSet, outputFileName string
+var capitalize int
+var shortNames, cleanup, updatePCE, noPrompt bool
+var include, exclude *regexp.Regexp
+var err error
+
+func init() {
+	DNSImportCmd.Flags().StringVarP(&zoneFiles, "zone-files", "f", "", "comma-separated list of zone files in master file format. the zone in --zones with the same position is the origin if the file does not have $ORIGIN.")
+	DNSImportCmd.Flags().StringVarP(&axfrServer, "axfr-server", "s", "", "dns server to request zone transfers from (e.g., ns1.corp.local or 10.0.0.53:53).")
+	DNSImportCmd.Flags().StringVarP(&zones, "zones", "z", "", "comma-separated list of zones (e.g., corp.local,dmz.corp.local).")
+	DNSImportCmd.Flags().StringVar(&includeStr, "include", "", "regular expression the fully qualified name must match (e.g., \"^(web|db)[0-9]+\\.\").")
+	DNSImportCmd.Flags().StringVar(&excludeStr, "exclude", "", "regular expression to exclude fully qualified names (e.g., \"^(dhcp|vpn)-\").")
+	DNSImportCmd.Flags().StringVar(&parserFile, "parser-file", "", "hostparse parser file to label the unmanaged workloads from the hostname. see the hostparse command for the format.")
+	DNSImportCmd.Flags().IntVar(&capitalize, "capitalize", 1, "set 1 for uppercase labels, 2 for lowercase labels or 0 to leave capitalization as is in parsed hostname.")
+	DNSImportCmd.Flags().BoolVar(&shortNames, "short-names", false, "parse and name unmanaged workloads with the short name instead of the fully qualified name.")
+	DNSImportCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "dns-import", "external data set used to identify unmanaged workloads managed by dns-import.")
+	DNSImportCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for records no longer in the zones.")
+	DNSImportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	DNSImportCmd.Flags().SortFlags = false
+}
+
+// DNSImportCmd runs the dns-import command
+var DNSImportCmd = &cobra.Command{
+	Use:         "dns-import",
+	Short:       "Create unmanaged workloads from DNS A and AAAA records in zone files or zone transfers.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create unmanaged workloads from DNS A and AAAA records in zone files or zone transfers.
+
+Records are read from zone files with --zone-files or with a zone transfer (AXFR) of each zone in --zones from --axfr-server. The dns server must allow zone transfers from the host running workloader.
+
+Records with the same name are one unmanaged workload with an interface for each ip address. The fully qualified name is the external data reference. Use --include and --exclude to limit the records.
+
+Names or ip addresses that match an existing workload (such as a managed workload with a VEN) are skipped.
+
+With --parser-file, labels are parsed from the hostname using the same regex file as hostparse:
++-------------------------------+------+------+------+-----+
+|             REGEX             | ROLE | APP  | ENV  | LOC |
++-------------------------------+------+------+------+-----+
+| (\w+)-(\w+)-(p|d)\d+\..*       | ${1} | ${2} | ${3} |     |
++-------------------------------+------+------+------+-----+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if zoneFiles == "" && (axfrServer == "" || zones == "") {
+			utils.LogError("--zone-files or --axfr-server and --zones are required")
+		}
+		if includeStr != "" {
+			if include, err = regexp.Compile(includeStr); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+		if excludeStr != "" {
+			if exclude, err = regexp.Compile(excludeStr); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		dnsImport()
+	},
+}
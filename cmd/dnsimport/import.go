@@ -0,0 +1,150 @@
+package dnsimport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brian1917/workloader/cmd/hostparse"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func dnsImport() {
+
+	utils.LogStartCommand("dns-import")
+
+	// Load the parser
+	var parser *hostparse.Parser
+	if parserFile != "" {
+		if parser, err = hostparse.NewParser(parserFile, capitalize); err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Get the records
+	zoneList := []string{}
+	for _, z := range strings.Split(zones, ",") {
+		if strings.TrimSpace(z) != "" {
+			zoneList = append(zoneList, strings.TrimSpace(z))
+		}
+	}
+	records := []record{}
+	if zoneFiles != "" {
+		for i, f := range strings.Split(zoneFiles, ",") {
+			origin := ""
+			if i < len(zoneList) {
+				origin = zoneList[i]
+			}
+			r, err := parseZoneFile(strings.TrimSpace(f), origin)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			utils.LogInfo(fmt.Sprintf("%d a and aaaa records in %s", len(r), f), true)
+			records = append(records, r...)
+		}
+	} else {
+		for _, z := range zoneList {
+			r, err := axfr(axfrServer, z)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			utils.LogInfo(fmt.Sprintf("%d a and aaaa records in %s from %s", len(r), z, axfrServer), true)
+			records = append(records, r...)
+		}
+	}
+
+	// Get the existing workloads
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	existing := make(map[string]string)
+	for _, w := range wklds {
+		if utils.PtrToStr(w.ExternalDataSet) == externalDataSet {
+			continue
+		}
+		if w.Hostname != "" {
+			existing[strings.ToLower(w.Hostname)] = w.Hostname
+		}
+		for _, i := range w.Interfaces {
+			existing[i.Address] = w.Hostname
+		}
+	}
+
+	// Group the records by name
+	ipsByName := make(map[string][]string)
+	for _, r := range records {
+		name := strings.ToLower(r.name)
+		if include != nil && !include.MatchString(name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(name) {
+			continue
+		}
+		ipsByName[name] = append(ipsByName[name], r.ip)
+	}
+	names := []string{}
+	for n := range ipsByName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// Build the unmanaged workloads
+	workloads := []umwlsync.Workload{}
+	unlabeled := 0
+	for _, fqdn := range names {
+		hostname := fqdn
+		if shortNames {
+			hostname = strings.Split(fqdn, ".")[0]
+		}
+		if h, ok := existing[fqdn]; ok {
+			utils.LogInfo(fmt.Sprintf("%s matches existing workload %s. skipping.", fqdn, h), false)
+			continue
+		}
+		if h, ok := existing[strings.Split(fqdn, ".")[0]]; ok {
+			utils.LogInfo(fmt.Sprintf("%s matches existing workload %s. skipping.", fqdn, h), false)
+			continue
+		}
+		w := umwlsync.Workload{Hostname: hostname, Name: hostname, Description: "dns record " + fqdn, ExternalDataReference: fqdn}
+		skip := false
+		for n, ip := range ipsByName[fqdn] {
+			if h, ok := existing[ip]; ok {
+				utils.LogInfo(fmt.Sprintf("%s - %s is used by existing workload %s. skipping.", fqdn, ip, h), false)
+				skip = true
+				break
+			}
+			w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s", n, ip))
+		}
+		if skip {
+			continue
+		}
+		if parser != nil {
+			labels, matched := parser.Labels(hostname)
+			if !matched {
+				unlabeled++
+				utils.LogInfo(fmt.Sprintf("%s does not match a parser regex", hostname), false)
+			}
+			w.Labels = labels
+		}
+		workloads = append(workloads, w)
+	}
+	utils.LogInfo(fmt.Sprintf("%d names for unmanaged workloads", len(workloads)), true)
+	if parser != nil && unlabeled > 0 {
+		utils.LogInfo(fmt.Sprintf("%d names do not match a parser regex. see workloader.log for details.", unlabeled), true)
+	}
+
+	umwlsync.Sync(umwlsync.Input{
+		PCE:             pce,
+		Command:         "dns-import",
+		ExternalDataSet: externalDataSet,
+		Workloads:       workloads,
+		Cleanup:         cleanup,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		OutputFileName:  outputFileName,
+	})
+
+	utils.LogEndCommand("dns-import")
+}
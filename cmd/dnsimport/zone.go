@@ -0,0 +1,280 @@
+package dnsimport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// record is an A or AAAA record
+type record struct {
+	name string // fully qualified without the trailing period
+	ip   string
+}
+
+// parseZoneFile returns the A and AAAA records in a master format zone file.
+// $ORIGIN, $TTL, @, relative names, omitted owners, and parentheses are supported. $INCLUDE and $GENERATE are skipped.
+func parseZoneFile(filename, origin string) ([]record, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	origin = fqdn(origin)
+	records := []record{}
+	owner := origin
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNum, entry, depth := 0, "", 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripComment(scanner.Text())
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		entry += line + " "
+		if depth > 0 {
+			continue
+		}
+		entry = strings.NewReplacer("(", " ", ")", " ").Replace(strings.TrimRight(entry, " "))
+		line, entry = entry, ""
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		// Directives
+		if strings.HasPrefix(fields[0], "$") {
+			switch strings.ToUpper(fields[0]) {
+			case "$ORIGIN":
+				if len(fields) > 1 {
+					origin = absolute(fields[1], origin)
+				}
+			case "$TTL":
+			default:
+				return records, fmt.Errorf("%s line %d - %s is not supported", filename, lineNum, fields[0])
+			}
+			continue
+		}
+
+		// Owner name is omitted when the line starts with whitespace
+		if line[0] != ' ' && line[0] != '\t' {
+			owner = absolute(fields[0], origin)
+			fields = fields[1:]
+		}
+
+		// Skip the optional ttl and class to get the type
+		for len(fields) > 0 && (isTTL(fields[0]) || isClass(fields[0])) {
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "A", "AAAA":
+			if net.ParseIP(fields[1]) == nil {
+				return records, fmt.Errorf("%s line %d - %s is not a valid ip address", filename, lineNum, fields[1])
+			}
+			records = append(records, record{name: strings.TrimSuffix(owner, "."), ip: fields[1]})
+		}
+	}
+	return records, scanner.Err()
+}
+
+// stripComment removes a comment that is not inside quotes
+func stripComment(line string) string {
+	inQuote := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			inQuote = !inQuote
+		case ';':
+			if !inQuote {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func fqdn(name string) string {
+	if name == "" || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// absolute returns the fully qualified name of a name relative to the origin
+func absolute(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case origin == "":
+		return name
+	}
+	return name + "." + origin
+}
+
+func isClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// isTTL returns true for a ttl in seconds or with units (e.g., 3600 or 1h30m)
+func isTTL(s string) bool {
+	if _, err := strconv.Atoi(s); err == nil {
+		return true
+	}
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if !strings.ContainsRune("0123456789smhdw", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// axfr requests a zone transfer over tcp and returns the A and AAAA records
+func axfr(server, zone string) ([]record, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	conn, err := net.DialTimeout("tcp", server, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Build the query
+	id := make([]byte, 2)
+	rand.Read(id)
+	msg := append(id, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(strings.TrimSuffix(zone, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 252, 0, 1)
+	query := make([]byte, 2, len(msg)+2)
+	binary.BigEndian.PutUint16(query, uint16(len(msg)))
+	if _, err := conn.Write(append(query, msg...)); err != nil {
+		return nil, err
+	}
+
+	// Read messages until the closing soa
+	records := []record{}
+	soaCount := 0
+	for soaCount < 2 {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, fmt.Errorf("axfr %s from %s - %s", zone, server, err)
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(length))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, fmt.Errorf("axfr %s from %s - %s", zone, server, err)
+		}
+		r, soas, err := parseMessage(resp)
+		if err != nil {
+			return nil, fmt.Errorf("axfr %s from %s - %s", zone, server, err)
+		}
+		records = append(records, r...)
+		soaCount += soas
+		if soas == 0 && len(r) == 0 && len(resp) <= 12 {
+			break
+		}
+	}
+	return records, nil
+}
+
+// parseMessage returns the A and AAAA records and the number of soa records in a dns message
+func parseMessage(msg []byte) ([]record, int, error) {
+	if len(msg) < 12 {
+		return nil, 0, fmt.Errorf("short dns message")
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		codes := map[byte]string{1: "format error", 2: "server failure", 3: "name error", 4: "not implemented", 5: "refused"}
+		return nil, 0, fmt.Errorf("rcode %d %s. verify the server allows zone transfers to this host", rcode, codes[rcode])
+	}
+	qdCount, anCount := int(binary.BigEndian.Uint16(msg[4:])), int(binary.BigEndian.Uint16(msg[6:]))
+	offset := 12
+	for i := 0; i < qdCount; i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = next + 4
+	}
+
+	records := []record{}
+	soas := 0
+	for i := 0; i < anCount; i++ {
+		name, next, err := readName(msg, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if next+10 > len(msg) {
+			return nil, 0, fmt.Errorf("short dns record")
+		}
+		rrType := binary.BigEndian.Uint16(msg[next:])
+		rdLength := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdLength > len(msg) {
+			return nil, 0, fmt.Errorf("short dns record")
+		}
+		switch {
+		case rrType == 1 && rdLength == 4, rrType == 28 && rdLength == 16:
+			records = append(records, record{name: name, ip: net.IP(msg[rdata : rdata+rdLength]).String()})
+		case rrType == 6:
+			soas++
+		}
+		offset = rdata + rdLength
+	}
+	return records, soas, nil
+}
+
+// readName reads a possibly compressed name at offset and returns the name and the offset after it
+func readName(msg []byte, offset int) (string, int, error) {
+	labels := []string{}
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("invalid dns name")
+		}
+		l := int(msg[offset])
+		switch {
+		case l == 0:
+			if next == -1 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case l&0xc0 == 0xc0:
+			if offset+1 >= len(msg) || jumps > 20 {
+				return "", 0, fmt.Errorf("invalid dns name")
+			}
+			if next == -1 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+l > len(msg) {
+				return "", 0, fmt.Errorf("invalid dns name")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+l]))
+			offset += 1 + l
+		}
+	}
+}
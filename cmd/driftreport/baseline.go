@@ -0,0 +1,232 @@
+package driftreport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Compared fields. Labels are label:<key>.
+const (
+	fieldHostname    = "hostname"
+	fieldInterfaces  = "interfaces"
+	fieldEnforcement = "enforcement"
+	fieldVENVersion  = "ven_version"
+	labelPrefix      = "label:"
+)
+
+// wkldState is the compared fields of a workload
+type wkldState struct {
+	href, hostname string
+	fields         map[string]string
+}
+
+// baseline is the workloads at the time of the baseline keyed by href or by hostname if the baseline has no hrefs. Fields are the
+// compared fields the baseline has. Label keys are the label columns of a csv baseline. An extract has every label key.
+type baseline struct {
+	workloads map[string]wkldState
+	byHref    bool
+	created   time.Time
+	fields    []string
+	allLabels bool
+	labelKeys []string
+}
+
+// key returns the key of a workload in the baseline
+func (b baseline) key(href, hostname string) string {
+	if b.byHref {
+		return href
+	}
+	return strings.ToLower(hostname)
+}
+
+// loadBaseline reads a wkld-export csv or an extract zip or directory
+func loadBaseline(file string) (baseline, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return baseline{}, fmt.Errorf("opening baseline - %s", err)
+	}
+	if info.IsDir() {
+		b, err := extractBaseline(os.DirFS(file))
+		if b.created.IsZero() {
+			b.created = info.ModTime()
+		}
+		return b, err
+	}
+	if strings.EqualFold(path.Ext(file), ".zip") {
+		z, err := zip.OpenReader(file)
+		if err != nil {
+			return baseline{}, fmt.Errorf("opening baseline - %s", err)
+		}
+		defer z.Close()
+		b, err := extractBaseline(z)
+		if b.created.IsZero() {
+			b.created = info.ModTime()
+		}
+		return b, err
+	}
+	b, err := csvBaseline(file)
+	b.created = info.ModTime()
+	return b, err
+}
+
+// csvBaseline reads the output of wkld-export. Columns that are not wkld-export headers are label keys.
+func csvBaseline(file string) (baseline, error) {
+	b := baseline{workloads: make(map[string]wkldState)}
+	data, err := utils.ParseCSV(file)
+	if err != nil {
+		return b, err
+	}
+	if len(data) < 2 {
+		return b, fmt.Errorf("%s has no workloads", file)
+	}
+
+	known := make(map[string]bool)
+	for _, h := range wkldexport.AllHeaders(true, true) {
+		known[h] = true
+	}
+	cols := make(map[string]int)
+	labelCols := make(map[string]int)
+	for i, h := range data[0] {
+		cols[h] = i
+		if !known[h] {
+			labelCols[h] = i
+		}
+	}
+	if _, ok := cols[wkldexport.HeaderHostname]; !ok {
+		return b, fmt.Errorf("%s is not a wkld-export file - no %s header", file, wkldexport.HeaderHostname)
+	}
+	_, b.byHref = cols[wkldexport.HeaderHref]
+	for key := range labelCols {
+		b.labelKeys = append(b.labelKeys, labelPrefix+key)
+	}
+	sort.Strings(b.labelKeys)
+	for field, header := range map[string]string{fieldHostname: wkldexport.HeaderHostname, fieldInterfaces: wkldexport.HeaderInterfaces, fieldEnforcement: wkldexport.HeaderEnforcement, fieldVENVersion: wkldexport.HeaderAgentVersion} {
+		if _, ok := cols[header]; ok {
+			b.fields = append(b.fields, field)
+		}
+	}
+	sort.Strings(b.fields)
+
+	value := func(row []string, header string) string {
+		if i, ok := cols[header]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	for _, row := range data[1:] {
+		s := wkldState{href: value(row, wkldexport.HeaderHref), hostname: value(row, wkldexport.HeaderHostname), fields: make(map[string]string)}
+		s.fields[fieldHostname] = s.hostname
+		s.fields[fieldInterfaces] = sortInterfaces(strings.Split(value(row, wkldexport.HeaderInterfaces), ";"))
+		s.fields[fieldEnforcement] = value(row, wkldexport.HeaderEnforcement)
+		s.fields[fieldVENVersion] = strings.TrimPrefix(value(row, wkldexport.HeaderAgentVersion), "unmanaged")
+		for key, i := range labelCols {
+			if i < len(row) {
+				s.fields[labelPrefix+key] = row[i]
+			}
+		}
+		b.workloads[b.key(s.href, s.hostname)] = s
+	}
+	return b, nil
+}
+
+// extractBaseline reads the workloads and labels of an extract. The files can be in a pce-extract directory. The baseline time is the
+// latest modified time of the workload files.
+func extractBaseline(fsys fs.FS) (baseline, error) {
+	b := baseline{workloads: make(map[string]wkldState), byHref: true, allLabels: true, fields: []string{fieldEnforcement, fieldHostname, fieldInterfaces, fieldVENVersion}}
+	files, _ := fs.Glob(fsys, "workloads/*.json")
+	if len(files) == 0 {
+		files, _ = fs.Glob(fsys, "*/workloads/*.json")
+	}
+	if len(files) == 0 {
+		return b, fmt.Errorf("baseline is not an extract - no workloads/*.json files")
+	}
+
+	// Labels in the workloads may only have an href. They are resolved from labels.json or the current labels.
+	labels := make(map[string]illumioapi.Label)
+	for k, v := range pce.Labels {
+		labels[k] = v
+	}
+	if data, err := fs.ReadFile(fsys, path.Join(path.Dir(path.Dir(files[0])), "labels.json")); err == nil {
+		extractLabels := []illumioapi.Label{}
+		if err := json.Unmarshal(data, &extractLabels); err != nil {
+			return b, fmt.Errorf("reading labels.json - %s", err)
+		}
+		for _, l := range extractLabels {
+			labels[l.Href] = l
+		}
+	}
+
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return b, fmt.Errorf("reading %s - %s", f, err)
+		}
+		var w illumioapi.Workload
+		if err := json.Unmarshal(data, &w); err != nil {
+			return b, fmt.Errorf("reading %s - %s", f, err)
+		}
+		if info, err := fs.Stat(fsys, f); err == nil && info.ModTime().After(b.created) {
+			b.created = info.ModTime()
+		}
+		if w.Labels != nil {
+			for _, l := range *w.Labels {
+				if l != nil && l.Key == "" {
+					*l = labels[l.Href]
+				}
+			}
+		}
+		s := currentState(w, labels)
+		b.workloads[s.href] = s
+	}
+	return b, nil
+}
+
+// currentState returns the compared fields of a workload
+func currentState(w illumioapi.Workload, labels map[string]illumioapi.Label) wkldState {
+	s := wkldState{href: w.Href, hostname: w.Hostname, fields: make(map[string]string)}
+	s.fields[fieldHostname] = w.Hostname
+	s.fields[fieldInterfaces] = sortInterfaces(wkldexport.InterfaceToString(w, false))
+	s.fields[fieldEnforcement] = w.GetMode()
+	if w.Agent != nil && w.Agent.Status != nil {
+		s.fields[fieldVENVersion] = w.Agent.Status.AgentVersion
+	}
+	if w.VEN != nil && w.VEN.Version != "" {
+		s.fields[fieldVENVersion] = w.VEN.Version
+	}
+	if w.Labels != nil {
+		for _, l := range *w.Labels {
+			if l == nil {
+				continue
+			}
+			label := labels[l.Href]
+			if label.Key == "" {
+				label = *l
+			}
+			s.fields[labelPrefix+label.Key] = label.Value
+		}
+	}
+	return s
+}
+
+// sortInterfaces sorts and joins interfaces so the order does not matter
+func sortInterfaces(interfaces []string) string {
+	sorted := []string{}
+	for _, i := range interfaces {
+		if i != "" {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ";")
+}
@@ -0,0 +1,67 @@
+package driftreport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var since, outputFileName string
+var noEvents bool
+
+func init() {
+	DriftReportCmd.Flags().StringVar(&since, "since", "", "time of the baseline for the event lookup in RFC 3339 format or yyyy-mm-dd. default is the time the baseline was created.")
+	DriftReportCmd.Flags().BoolVar(&noEvents, "no-events", false, "do not look up who made each change in the pce events.")
+	DriftReportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	DriftReportCmd.Flags().SortFlags = false
+}
+
+// DriftReportCmd compares the workloads to a baseline
+var DriftReportCmd = &cobra.Command{
+	Use:   "drift-report [baseline]",
+	Short: "Report workload changes since a baseline from wkld-export or extract.",
+	Long: `
+Report workload changes since a baseline for change-control audits.
+
+The baseline is the csv output of wkld-export or the pce-extract.zip (or unzipped directory) of extract. Workloads are matched by href, or by hostname if the baseline csv has no href column. Labels, hostname, interfaces, enforcement, and ven version are compared.
+
+The output has a row for each workload that was added or removed and for each field that changed. Each row has the time, user, and event type of the latest pce event for the workload since the baseline so auditors can see when and by whom it was changed. The baseline time is when the wkld-export file was last modified or the extract was created. Use --since to set it. Events are kept on the pce for a limited time and the event lookup is limited to 10,000 events. Use --no-events to skip the lookup.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Take a baseline and compare to it later
+  workloader wkld-export --output-file baseline.csv
+  workloader drift-report baseline.csv
+
+  # Compare to an extract and look up events since the start of the change window
+  workloader drift-report pce-extract.zip --since 2026-10-01T22:00:00Z`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if len(args) != 1 {
+			fmt.Println("Command requires 1 argument for the baseline file. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+
+		var sinceTime time.Time
+		if since != "" {
+			if sinceTime, err = time.Parse(time.RFC3339, since); err != nil {
+				if sinceTime, err = time.ParseInLocation("2006-01-02", since, time.Local); err != nil {
+					utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--since must be RFC 3339 or yyyy-mm-dd - %s", since))
+				}
+			}
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("drift-report")
+		driftReport(args[0], sinceTime)
+		utils.LogEndCommand("drift-report")
+	},
+}
@@ -0,0 +1,179 @@
+package driftreport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// driftEvent is a pce event with the resources it changed. The illumioapi event does not have the resource changes.
+type driftEvent struct {
+	illumioapi.Event
+	ResourceChanges []struct {
+		Resource map[string]struct {
+			Href string `json:"href"`
+		} `json:"resource"`
+	} `json:"resource_changes"`
+}
+
+func driftReport(file string, sinceTime time.Time) {
+
+	// Load the PCE first so an extract baseline can resolve label hrefs
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	utils.LogInfo(fmt.Sprintf("reading baseline %s", file), true)
+	b, err := loadBaseline(file)
+	if err != nil {
+		utils.LogErrorCode(utils.ExitValidation, err.Error())
+	}
+	if sinceTime.IsZero() {
+		sinceTime = b.created
+	}
+	utils.LogInfo(fmt.Sprintf("baseline has %d workloads from %s", len(b.workloads), sinceTime.Format(time.RFC3339)), true)
+
+	current := make(map[string]wkldState)
+	for _, w := range pce.WorkloadsSlice {
+		if w.Deleted != nil && *w.Deleted {
+			continue
+		}
+		s := currentState(w, pce.Labels)
+		current[b.key(s.href, s.hostname)] = s
+	}
+
+	// Get who made the changes
+	lastEvent := make(map[string]driftEvent)
+	if !noEvents {
+		lastEvent = workloadEvents(sinceTime)
+	}
+	event := func(s wkldState) []string {
+		e, ok := lastEvent[s.href]
+		if !ok {
+			return []string{"", "", ""}
+		}
+		return []string{e.Timestamp.Format(time.RFC3339), e.EventCreatedBy.Name, e.EventType}
+	}
+
+	rows := [][]string{}
+	added, removed, changed := 0, 0, 0
+	for k, s := range current {
+		if _, ok := b.workloads[k]; !ok {
+			rows = append(rows, append([]string{s.hostname, s.href, "added", "", "", ""}, event(s)...))
+			added++
+		}
+	}
+	for k, base := range b.workloads {
+		s, ok := current[k]
+		if !ok {
+			rows = append(rows, append([]string{base.hostname, base.href, "removed", "", "", ""}, event(base)...))
+			removed++
+			continue
+		}
+		diffs := 0
+		for _, f := range compareFields(b, base, s) {
+			if base.fields[f] != s.fields[f] {
+				rows = append(rows, append([]string{s.hostname, s.href, "changed", f, base.fields[f], s.fields[f]}, event(s)...))
+				diffs++
+			}
+		}
+		if diffs > 0 {
+			changed++
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !strings.EqualFold(rows[i][0], rows[j][0]) {
+			return strings.ToLower(rows[i][0]) < strings.ToLower(rows[j][0])
+		}
+		if rows[i][1] != rows[j][1] {
+			return rows[i][1] < rows[j][1]
+		}
+		return rows[i][3] < rows[j][3]
+	})
+
+	utils.LogInfo(fmt.Sprintf("%d workloads added, %d removed, and %d changed since the baseline", added, removed, changed), true)
+	if len(rows) == 0 {
+		utils.LogInfo("no drift from the baseline", true)
+		return
+	}
+	data := append([][]string{{"hostname", "href", "change", "field", "baseline_value", "current_value", "changed_at", "changed_by", "event_type"}}, rows...)
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-drift-report-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// compareFields returns the fields to compare for a workload. Labels are the label columns of a csv baseline or the keys in either the
+// extract or the current workload.
+func compareFields(b baseline, base, current wkldState) []string {
+	fields := append(append([]string{}, b.fields...), b.labelKeys...)
+	if b.allLabels {
+		keys := make(map[string]bool)
+		for _, s := range []wkldState{base, current} {
+			for f := range s.fields {
+				if strings.HasPrefix(f, labelPrefix) {
+					keys[f] = true
+				}
+			}
+		}
+		labelFields := []string{}
+		for f := range keys {
+			labelFields = append(labelFields, f)
+		}
+		sort.Strings(labelFields)
+		fields = append(fields, labelFields...)
+	}
+	return fields
+}
+
+// workloadEvents returns the latest event since the baseline for each workload href. Events for a workload's ven are included.
+func workloadEvents(sinceTime time.Time) map[string]driftEvent {
+	lastEvent := make(map[string]driftEvent)
+	utils.LogInfo(fmt.Sprintf("getting events since %s", sinceTime.Format(time.RFC3339)), true)
+	qp := map[string]string{"timestamp[gte]": sinceTime.UTC().Format(time.RFC3339), "max_results": "10000"}
+	events := []driftEvent{}
+	a, err := pce.GetCollection("events", false, qp, &events)
+	if len(events) >= 500 {
+		events = nil
+		a, err = pce.GetCollection("events", true, qp, &events)
+	}
+	utils.LogAPIResp("GetCollection events", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting events - %s. the report will not have who made the changes.", err), true)
+		return lastEvent
+	}
+
+	// Map ven hrefs to workload hrefs
+	venWkld := make(map[string]string)
+	for _, w := range pce.WorkloadsSlice {
+		if w.VEN != nil && w.VEN.Href != "" {
+			venWkld[w.VEN.Href] = w.Href
+		}
+	}
+
+	for _, e := range events {
+		e.PopulateCreatedBy()
+		for _, rc := range e.ResourceChanges {
+			for _, r := range rc.Resource {
+				href := r.Href
+				if wkld, ok := venWkld[href]; ok {
+					href = wkld
+				}
+				if href == "" {
+					continue
+				}
+				if last, ok := lastEvent[href]; !ok || e.Timestamp.After(last.Timestamp) {
+					lastEvent[href] = e
+				}
+			}
+		}
+	}
+	utils.LogInfo(fmt.Sprintf("%d events since the baseline", len(events)), true)
+	return lastEvent
+}
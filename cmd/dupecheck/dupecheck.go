@@ -13,12 +13,17 @@ import (
 var pce illumioapi.PCE
 var caseSensitive bool
 var outputFileName string
+var jira utils.JiraInput
 var err error
 
 func init() {
 	DupeCheckCmd.Flags().BoolVarP(&caseSensitive, "case-sensitive", "c", false, "Require hostname/name matches to be case-sensitve.")
 	DupeCheckCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
+	DupeCheckCmd.Flags().BoolVar(&jira.Enabled, "jira", false, "create a jira issue for each finding. findings with an unresolved issue are skipped. requires jira_url and jira_token in pce.yaml or WORKLOADER_JIRA_ environment variables.")
+	DupeCheckCmd.Flags().StringVar(&jira.Project, "jira-project", "", "jira project key. default is jira_project in pce.yaml or the WORKLOADER_JIRA_PROJECT environment variable.")
+	DupeCheckCmd.Flags().StringVar(&jira.GroupBy, "jira-group-by", "", "output header to create one issue per group instead of per finding (e.g., app).")
+	DupeCheckCmd.Flags().StringVar(&jira.Summary, "jira-summary", "", "go template for the issue summary using output headers as fields (e.g., {{.hostname}}). grouped issues also have {{.group}} and {{.count}}.")
 	DupeCheckCmd.Flags().SortFlags = false
 }
 
@@ -123,6 +128,11 @@ func dupeCheck() {
 			outputFileName = fmt.Sprintf("workloader-dupecheck-%s.csv", time.Now().Format("20060102_150405"))
 		}
 		utils.WriteOutput(data, data, outputFileName)
+		if jira.Enabled {
+			if err := utils.FileJiraIssues(jira, "dupecheck", data, []string{"href"}, "duplicate unmanaged workload {{if .hostname}}{{.hostname}}{{else}}{{.name}}{{end}}", "{{.count}} duplicate unmanaged workloads - {{.group}}"); err != nil {
+				utils.LogWarning(fmt.Sprintf("creating jira issues - %s", err), true)
+			}
+		}
 		utils.LogInfo(fmt.Sprintf("%d unmanaged workloads found. See %s for output. The output file can be used as input to workloader delete command.", len(data)-1, outputFileName), true)
 	} else {
 		utils.LogInfo("No duplicates found", true)
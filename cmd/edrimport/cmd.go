@@ -0,0 +1,78 @@
+package edrimport
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var cs falcon
+var clientID, clientSecret, fqlFilter, csvFile, mappingFile, externalDataSet, outputFileName string
+var staleDays int
+var noUMWL, cleanup, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	EDRImportCmd.Flags().StringVar(&cs.baseURL, "falcon-url", "https://api.crowdstrike.com", "crowdstrike falcon api url for the cloud of the tenant (e.g., https://api.us-2.crowdstrike.com or https://api.eu-1.crowdstrike.com).")
+	EDRImportCmd.Flags().StringVar(&clientID, "client-id", "", "falcon api client id with hosts read scope. default is the FALCON_CLIENT_ID environment variable.")
+	EDRImportCmd.Flags().StringVar(&clientSecret, "client-secret", "", "falcon api client secret. default is the FALCON_CLIENT_SECRET environment variable.")
+	EDRImportCmd.Flags().StringVar(&fqlFilter, "fql-filter", "", "falcon query language filter for hosts (e.g., \"platform_name:'Linux'\").")
+	EDRImportCmd.Flags().StringVar(&csvFile, "csv", "", "csv host export from crowdstrike or another edr tool to use instead of the falcon api. requires a hostname header. optional headers are ip (semicolon-separated), id, and last_seen.")
+	EDRImportCmd.Flags().IntVar(&staleDays, "stale-days", 30, "ignore edr hosts not seen in this many days. 0 includes all hosts.")
+	EDRImportCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: edr field (e.g., platform_name, site_name, tag, or csv header) and illumio label key for unmanaged workloads.")
+	EDRImportCmd.Flags().BoolVar(&noUMWL, "no-umwl", false, "only create the coverage gap report. do not create unmanaged workloads for edr hosts without a ven.")
+	EDRImportCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "edr-import", "external data set used to identify unmanaged workloads managed by edr-import.")
+	EDRImportCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for hosts that are no longer in the edr or now have a ven.")
+	EDRImportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the coverage gap report. default is current location with a timestamped filename.")
+	EDRImportCmd.Flags().SortFlags = false
+}
+
+// EDRImportCmd runs the edr-import command
+var EDRImportCmd = &cobra.Command{
+	Use:         "edr-import",
+	Short:       "Create unmanaged workloads for EDR hosts without a VEN and report managed workloads missing from the EDR.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create unmanaged workloads for EDR hosts without a VEN and report managed workloads missing from the EDR.
+
+Hosts are read from the CrowdStrike Falcon hosts api using an api client with the Hosts read scope or from a csv export with --csv. The csv can be from any edr tool and requires a hostname header. Optional headers are ip (multiple ips separated by semicolons), id (default is the hostname), and last_seen (RFC 3339 or YYYY-MM-DD).
+
+EDR hosts are matched to PCE workloads by hostname (full or short name, case insensitive) and then ip address.
+
+The coverage gap report has each edr host and managed workload with a gap value:
+- none: the host is in the edr and has a ven.
+- missing_ven: the host is in the edr and does not have a ven.
+- missing_edr: the workload has a ven and is not in the edr.
+
+EDR hosts with missing_ven that do not match an existing unmanaged workload are created as unmanaged workloads with the edr id as the external data reference unless --no-umwl is set. The mapping file is a csv with an edr field in the first column and the illumio label key in the second column to label those unmanaged workloads. Falcon fields are platform_name, os_version, product_type_desc, machine_domain, site_name, status, and tag (the first falcon grouping tag). Csv fields are the csv headers.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if clientID == "" {
+			clientID = os.Getenv("FALCON_CLIENT_ID")
+		}
+		if clientSecret == "" {
+			clientSecret = os.Getenv("FALCON_CLIENT_SECRET")
+		}
+		if csvFile == "" && (clientID == "" || clientSecret == "") {
+			utils.LogError("--csv or falcon api client credentials are required")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		edrImport()
+	},
+}
@@ -0,0 +1,144 @@
+package edrimport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// falcon calls the CrowdStrike Falcon API
+type falcon struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// falconDevice is the subset of a falcon host used by edr-import
+type falconDevice struct {
+	DeviceID        string   `json:"device_id"`
+	Hostname        string   `json:"hostname"`
+	LocalIP         string   `json:"local_ip"`
+	ExternalIP      string   `json:"external_ip"`
+	PlatformName    string   `json:"platform_name"`
+	OSVersion       string   `json:"os_version"`
+	ProductTypeDesc string   `json:"product_type_desc"`
+	MachineDomain   string   `json:"machine_domain"`
+	SiteName        string   `json:"site_name"`
+	Status          string   `json:"status"`
+	LastSeen        string   `json:"last_seen"`
+	Tags            []string `json:"tags"`
+}
+
+// login gets an oauth2 token with the api client credentials
+func (f *falcon) login(clientID, clientSecret string) error {
+	f.baseURL = strings.TrimSuffix(f.baseURL, "/")
+	f.client = &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+	form := url.Values{"client_id": {clientID}, "client_secret": {clientSecret}}
+	resp, err := f.client.PostForm(f.baseURL+"/oauth2/token", form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("falcon oauth2 token - %d - %s", resp.StatusCode, string(body))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return err
+	}
+	f.token = token.AccessToken
+	return nil
+}
+
+// call sends a request to the api and unmarshals the response into result
+func (f *falcon) call(method, path string, payload, result interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, f.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s - %d - %s", method, path, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// getDevices returns all hosts matching the fql filter
+func (f *falcon) getDevices(filter string) ([]falconDevice, error) {
+
+	// Get the device ids with the scroll api
+	ids := []string{}
+	offset := ""
+	for {
+		q := url.Values{"limit": {"5000"}}
+		if offset != "" {
+			q.Set("offset", offset)
+		}
+		if filter != "" {
+			q.Set("filter", filter)
+		}
+		var resp struct {
+			Resources []string `json:"resources"`
+			Meta      struct {
+				Pagination struct {
+					Offset string `json:"offset"`
+					Total  int    `json:"total"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		if err := f.call("GET", "/devices/queries/devices-scroll/v1?"+q.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		ids = append(ids, resp.Resources...)
+		offset = resp.Meta.Pagination.Offset
+		if len(resp.Resources) == 0 || offset == "" || len(ids) >= resp.Meta.Pagination.Total {
+			break
+		}
+	}
+
+	// Get the device details in batches
+	devices := []falconDevice{}
+	for i := 0; i < len(ids); i += 500 {
+		end := i + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var resp struct {
+			Resources []falconDevice `json:"resources"`
+		}
+		if err := f.call("POST", "/devices/entities/devices/v2", map[string][]string{"ids": ids[i:end]}, &resp); err != nil {
+			return nil, err
+		}
+		devices = append(devices, resp.Resources...)
+	}
+	return devices, nil
+}
@@ -0,0 +1,259 @@
+package edrimport
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// edrHost is a host from the edr normalized from the api or csv
+type edrHost struct {
+	id         string
+	hostname   string
+	ips        []string
+	lastSeen   time.Time
+	attributes map[string]string
+}
+
+func edrImport() {
+
+	utils.LogStartCommand("edr-import")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Get the edr hosts
+	var hosts []edrHost
+	source := "crowdstrike"
+	if csvFile != "" {
+		source = csvFile
+		hosts, err = parseCSV(csvFile)
+	} else {
+		hosts, err = falconHosts()
+	}
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d hosts in %s", len(hosts), source), true)
+
+	// Remove stale hosts
+	if staleDays > 0 {
+		current := []edrHost{}
+		cutoff := time.Now().AddDate(0, 0, -staleDays)
+		for _, h := range hosts {
+			if !h.lastSeen.IsZero() && h.lastSeen.Before(cutoff) {
+				utils.LogInfo(fmt.Sprintf("%s - last seen %s. skipping stale host.", h.hostname, h.lastSeen.Format("2006-01-02")), false)
+				continue
+			}
+			current = append(current, h)
+		}
+		utils.LogInfo(fmt.Sprintf("%d hosts seen in the last %d days", len(current), staleDays), true)
+		hosts = current
+	}
+
+	// Get the workloads
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	nameMap := make(map[string]illumioapi.Workload)
+	ipMap := make(map[string]illumioapi.Workload)
+	for _, w := range wklds {
+		// Skip unmanaged workloads edr-import creates so they are recreated or cleaned up each run
+		if utils.PtrToStr(w.ExternalDataSet) == externalDataSet {
+			continue
+		}
+		// Prefer managed workloads when a name or ip is used more than once
+		for _, n := range []string{strings.ToLower(w.Hostname), strings.ToLower(strings.Split(w.Hostname, ".")[0])} {
+			if e, ok := nameMap[n]; n != "" && (!ok || (e.GetMode() == "unmanaged" && w.GetMode() != "unmanaged")) {
+				nameMap[n] = w
+			}
+		}
+		for _, i := range w.Interfaces {
+			if e, ok := ipMap[i.Address]; !ok || (e.GetMode() == "unmanaged" && w.GetMode() != "unmanaged") {
+				ipMap[i.Address] = w
+			}
+		}
+	}
+
+	// Compare
+	data := [][]string{{wkldexport.HeaderHostname, "ips", "edr_id", "edr_last_seen", "workload_hostname", wkldexport.HeaderHref, "managed", "gap"}}
+	inEDR := make(map[string]bool)
+	workloads := []umwlsync.Workload{}
+	missingVEN := 0
+	for _, h := range hosts {
+		w, matched := nameMap[strings.ToLower(h.hostname)]
+		if !matched {
+			w, matched = nameMap[strings.ToLower(strings.Split(h.hostname, ".")[0])]
+		}
+		for _, ip := range h.ips {
+			if matched {
+				break
+			}
+			w, matched = ipMap[ip]
+		}
+
+		lastSeen := ""
+		if !h.lastSeen.IsZero() {
+			lastSeen = h.lastSeen.Format(time.RFC3339)
+		}
+		managed := matched && w.GetMode() != "unmanaged"
+		gap := "none"
+		if !managed {
+			gap = "missing_ven"
+			missingVEN++
+		}
+		data = append(data, []string{h.hostname, strings.Join(h.ips, ";"), h.id, lastSeen, w.Hostname, w.Href, fmt.Sprintf("%t", managed), gap})
+		if matched {
+			inEDR[w.Href] = true
+			continue
+		}
+
+		// Build the unmanaged workload
+		umwl := umwlsync.Workload{Hostname: h.hostname, Name: h.hostname, Description: "edr host without a ven", Labels: umwlsync.MapLabels(h.attributes, mapping), ExternalDataReference: h.id}
+		for n, ip := range h.ips {
+			umwl.Interfaces = append(umwl.Interfaces, fmt.Sprintf("eth%d:%s", n, ip))
+		}
+		workloads = append(workloads, umwl)
+	}
+
+	missingEDR := 0
+	for _, w := range wklds {
+		if w.GetMode() == "unmanaged" || inEDR[w.Href] {
+			continue
+		}
+		missingEDR++
+		data = append(data, []string{"", strings.Join(wkldIPs(w), ";"), "", "", w.Hostname, w.Href, "true", "missing_edr"})
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-edr-import-coverage-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d edr hosts are missing a ven. %d managed workloads are missing from the edr.", missingVEN, missingEDR), true)
+
+	// Sync the unmanaged workloads
+	if !noUMWL {
+		umwlsync.Sync(umwlsync.Input{
+			PCE:             pce,
+			Command:         "edr-import",
+			ExternalDataSet: externalDataSet,
+			Workloads:       workloads,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			OutputFileName:  "umwl-" + outputFileName,
+		})
+	}
+
+	utils.LogEndCommand("edr-import")
+}
+
+// wkldIPs returns the interface ips of a workload
+func wkldIPs(w illumioapi.Workload) []string {
+	ips := []string{}
+	for _, i := range w.Interfaces {
+		ips = append(ips, i.Address)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// falconHosts gets the hosts from the falcon api
+func falconHosts() ([]edrHost, error) {
+	if err := cs.login(clientID, clientSecret); err != nil {
+		return nil, err
+	}
+	devices, err := cs.getDevices(fqlFilter)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []edrHost{}
+	for _, d := range devices {
+		h := edrHost{id: d.DeviceID, hostname: d.Hostname, attributes: map[string]string{
+			"platform_name":     d.PlatformName,
+			"os_version":        d.OSVersion,
+			"product_type_desc": d.ProductTypeDesc,
+			"machine_domain":    d.MachineDomain,
+			"site_name":         d.SiteName,
+			"status":            d.Status,
+		}}
+		if d.LocalIP != "" {
+			h.ips = append(h.ips, d.LocalIP)
+		}
+		for _, t := range d.Tags {
+			if strings.HasPrefix(t, "FalconGroupingTags/") {
+				h.attributes["tag"] = strings.TrimPrefix(t, "FalconGroupingTags/")
+				break
+			}
+		}
+		h.lastSeen, _ = time.Parse(time.RFC3339, d.LastSeen)
+		if h.hostname == "" {
+			h.hostname = d.LocalIP
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// parseCSV gets the hosts from a csv export
+func parseCSV(filename string) ([]edrHost, error) {
+	rows, err := utils.ParseCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", filename)
+	}
+	headers := make(map[string]int)
+	for i, h := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := headers["hostname"]; !ok {
+		return nil, fmt.Errorf("%s does not have a hostname header", filename)
+	}
+	hosts := []edrHost{}
+	for n, row := range rows[1:] {
+		h := edrHost{attributes: make(map[string]string)}
+		for header, i := range headers {
+			if i < len(row) {
+				h.attributes[header] = strings.TrimSpace(row[i])
+			}
+		}
+		h.hostname = h.attributes["hostname"]
+		if h.hostname == "" {
+			utils.LogWarning(fmt.Sprintf("csv line %d - no hostname. skipping.", n+2), true)
+			continue
+		}
+		h.id = h.attributes["id"]
+		if h.id == "" {
+			h.id = strings.ToLower(h.hostname)
+		}
+		for _, ip := range strings.Split(h.attributes["ip"], ";") {
+			if net.ParseIP(strings.TrimSpace(ip)) != nil {
+				h.ips = append(h.ips, strings.TrimSpace(ip))
+			}
+		}
+		if ls := h.attributes["last_seen"]; ls != "" {
+			if h.lastSeen, err = time.Parse(time.RFC3339, ls); err != nil {
+				h.lastSeen, _ = time.Parse("2006-01-02", ls)
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
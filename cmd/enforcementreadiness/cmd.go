@@ -0,0 +1,83 @@
+package enforcementreadiness
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var days, recentDays, maxResults int
+var minCoverage float64
+var maxHeartbeatAge time.Duration
+var minVersion, targetEnforcement, targetVisibility, outputFileName, modeFileName string
+
+func init() {
+	EnforcementReadinessCmd.Flags().IntVar(&days, "days", 30, "days of traffic to evaluate.")
+	EnforcementReadinessCmd.Flags().Float64Var(&minCoverage, "min-coverage", 100, "minimum percent of connections allowed by rules for a workload to be ready.")
+	EnforcementReadinessCmd.Flags().IntVar(&recentDays, "recent-days", 7, "a workload with potentially blocked flows last seen in this many days is not ready.")
+	EnforcementReadinessCmd.Flags().DurationVar(&maxHeartbeatAge, "max-heartbeat-age", time.Hour, "maximum time since the last heartbeat for a workload to be ready.")
+	EnforcementReadinessCmd.Flags().StringVar(&minVersion, "min-ven-version", "", "minimum ven version for a workload to be ready. default is the highest ven version in the pce.")
+	EnforcementReadinessCmd.Flags().StringVar(&targetEnforcement, "target-enforcement", "full", "enforcement in the mode file. must be selective or full.")
+	EnforcementReadinessCmd.Flags().StringVar(&targetVisibility, "target-visibility", "", "optional visibility in the mode file (off, blocked, blocked_allowed, or enhanced_data_collection).")
+	EnforcementReadinessCmd.Flags().IntVar(&maxResults, "max-results", 100000, "max results for the traffic query.")
+	EnforcementReadinessCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	EnforcementReadinessCmd.Flags().StringVar(&modeFileName, "mode-file", "", "optionally specify the name of the mode file location. default is current location with a timestamped filename.")
+	EnforcementReadinessCmd.Flags().SortFlags = false
+}
+
+// EnforcementReadinessCmd evaluates visibility workloads for enforcement
+var EnforcementReadinessCmd = &cobra.Command{
+	Use:   "enforcement-readiness",
+	Short: "Rank visibility workloads by readiness for enforcement and create a mode file for the ready workloads.",
+	Long: `
+Rank managed workloads in visibility (visibility_only, build, or test) by readiness for enforcement and create a mode file for the ready workloads.
+
+A workload is ready when:
+- at least --min-coverage percent of its connections (as a source or destination) in the last --days days were allowed by rules.
+- it has no potentially blocked flows last seen in the last --recent-days days.
+- its ven version is at least --min-ven-version. the default is the highest ven version in the pce.
+- it is online with a heartbeat in the last --max-heartbeat-age and has no error health conditions.
+
+A workload with no traffic in the last --days days is not ready because its coverage cannot be evaluated.
+
+The output has every visibility workload ranked with the ready workloads first, then by coverage and connections, and the reasons a workload is not ready. The mode file has the ready workloads with the --target-enforcement and --target-visibility to use as the input for the mode command after review.
+
+The traffic query is limited to --max-results flows. Broadcast and multicast traffic is excluded.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Evaluate visibility workloads over the last 30 days
+  workloader enforcement-readiness
+
+  # Allow 98% coverage and move the ready workloads to selective enforcement after review
+  workloader enforcement-readiness --min-coverage 98 --target-enforcement selective --mode-file ready.csv
+  workloader mode ready.csv --update-pce`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if targetEnforcement != "selective" && targetEnforcement != "full" {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--target-enforcement must be selective or full - %s", targetEnforcement))
+		}
+		if targetVisibility != "" && targetVisibility != "off" && targetVisibility != "blocked" && targetVisibility != "blocked_allowed" && targetVisibility != "enhanced_data_collection" {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--target-visibility must be off, blocked, blocked_allowed, or enhanced_data_collection - %s", targetVisibility))
+		}
+		if days <= 0 || recentDays < 0 || maxHeartbeatAge <= 0 || minCoverage < 0 || minCoverage > 100 {
+			utils.LogErrorCode(utils.ExitValidation, "--days and --max-heartbeat-age must be positive, --recent-days cannot be negative, and --min-coverage must be 0 to 100")
+		}
+		if minVersion != "" && len(versionParts(minVersion)) == 0 {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a valid --min-ven-version", minVersion))
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("enforcement-readiness")
+		enforcementReadiness()
+		utils.LogEndCommand("enforcement-readiness")
+	},
+}
@@ -0,0 +1,239 @@
+package enforcementreadiness
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// candidate is a visibility workload and its traffic
+type candidate struct {
+	wkld                                            illumioapi.Workload
+	version                                         string
+	flows, connections, allowed, potentiallyBlocked int
+	recentPotentiallyBlocked                        int
+	reasons                                         []string
+}
+
+// coverage returns the percent of connections allowed by rules
+func (c candidate) coverage() float64 {
+	if c.connections == 0 {
+		return 0
+	}
+	return float64(c.allowed) / float64(c.connections) * 100
+}
+
+// ready returns true if the workload has no reasons it is not ready
+func (c candidate) ready() bool {
+	return len(c.reasons) == 0
+}
+
+// visibility returns true if the mode is visibility_only or the legacy build or test modes
+func visibility(mode string) bool {
+	return mode == "visibility_only" || mode == "build" || mode == "test"
+}
+
+func enforcementReadiness() {
+
+	// Load the managed workloads
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true, WorkloadsQueryParameters: map[string]string{"managed": "true"}})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the candidates and the highest ven version
+	candidates := make(map[string]*candidate)
+	highest := ""
+	for _, w := range pce.WorkloadsSlice {
+		version := ""
+		if w.Agent != nil && w.Agent.Status != nil {
+			version = w.Agent.Status.AgentVersion
+		}
+		if w.VEN != nil && w.VEN.Version != "" {
+			version = w.VEN.Version
+		}
+		if len(versionParts(version)) > 0 && (highest == "" || versionLess(highest, version)) {
+			highest = version
+		}
+		if visibility(w.GetMode()) {
+			candidates[w.Href] = &candidate{wkld: w, version: version}
+		}
+	}
+	if minVersion == "" {
+		minVersion = highest
+	}
+	utils.LogInfo(fmt.Sprintf("%d of %d managed workloads are in visibility. minimum ven version is %s", len(candidates), len(pce.WorkloadsSlice), minVersion), true)
+	if len(candidates) == 0 {
+		return
+	}
+
+	// Get the traffic
+	utils.LogInfo(fmt.Sprintf("getting traffic for the last %d days", days), true)
+	now := time.Now()
+	tq := illumioapi.TrafficQuery{
+		StartTime:                       now.AddDate(0, 0, -days),
+		EndTime:                         now,
+		PolicyStatuses:                  []string{},
+		MaxFLows:                        maxResults,
+		TransmissionExcludes:            []string{"broadcast", "multicast"},
+		ExcludeWorkloadsFromIPListQuery: true}
+	traffic, a, err := pce.GetTrafficAnalysis(tq)
+	utils.LogAPIResp("GetTrafficAnalysis", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if len(traffic) >= maxResults {
+		utils.LogWarning(fmt.Sprintf("the traffic query reached the maximum of %d results. coverage may be incomplete.", maxResults), true)
+	}
+
+	// Tally each flow for the source and destination candidates
+	recent := now.AddDate(0, 0, -recentDays)
+	for _, t := range traffic {
+		hrefs := []string{}
+		if t.Src != nil && t.Src.Workload != nil {
+			hrefs = append(hrefs, t.Src.Workload.Href)
+		}
+		if t.Dst != nil && t.Dst.Workload != nil && (len(hrefs) == 0 || t.Dst.Workload.Href != hrefs[0]) {
+			hrefs = append(hrefs, t.Dst.Workload.Href)
+		}
+		for _, href := range hrefs {
+			c, ok := candidates[href]
+			if !ok {
+				continue
+			}
+			c.flows++
+			c.connections += t.NumConnections
+			switch t.PolicyDecision {
+			case "allowed":
+				c.allowed += t.NumConnections
+			case "potentially_blocked":
+				c.potentiallyBlocked++
+				if t.TimestampRange != nil {
+					if last, err := time.Parse(time.RFC3339, t.TimestampRange.LastDetected); err != nil || last.After(recent) {
+						c.recentPotentiallyBlocked++
+					}
+				}
+			}
+		}
+	}
+
+	// Evaluate each candidate
+	sorted := []*candidate{}
+	for _, c := range candidates {
+		evaluate(c, now)
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ready() != sorted[j].ready() {
+			return sorted[i].ready()
+		}
+		if sorted[i].coverage() != sorted[j].coverage() {
+			return sorted[i].coverage() > sorted[j].coverage()
+		}
+		if sorted[i].connections != sorted[j].connections {
+			return sorted[i].connections > sorted[j].connections
+		}
+		return sorted[i].wkld.Hostname < sorted[j].wkld.Hostname
+	})
+
+	// Build the outputs
+	data := [][]string{{"rank", "hostname", "href", "role", "app", "env", "loc", "enforcement", "ven_version", "last_heartbeat", "flows", "connections", "allowed_connections", "coverage_percent", "potentially_blocked_flows", "recent_potentially_blocked_flows", "ready", "reasons"}}
+	stdOutData := [][]string{{"rank", "hostname", "coverage_percent", "ready", "reasons"}}
+	modeData := [][]string{{"href", "enforcement", "visibility", "hostname"}}
+	for i, c := range sorted {
+		w := c.wkld
+		heartbeat := ""
+		if w.Agent != nil && w.Agent.Status != nil {
+			heartbeat = w.Agent.Status.LastHeartbeatOn
+		}
+		reasons := strings.Join(c.reasons, "; ")
+		data = append(data, []string{strconv.Itoa(i + 1), w.Hostname, w.Href, w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value, w.GetMode(), c.version, heartbeat, strconv.Itoa(c.flows), strconv.Itoa(c.connections), strconv.Itoa(c.allowed), fmt.Sprintf("%.1f", c.coverage()), strconv.Itoa(c.potentiallyBlocked), strconv.Itoa(c.recentPotentiallyBlocked), strconv.FormatBool(c.ready()), reasons})
+		stdOutData = append(stdOutData, []string{strconv.Itoa(i + 1), w.Hostname, fmt.Sprintf("%.1f", c.coverage()), strconv.FormatBool(c.ready()), reasons})
+		if c.ready() {
+			modeData = append(modeData, []string{w.Href, targetEnforcement, targetVisibility, w.Hostname})
+		}
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-enforcement-readiness-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, stdOutData, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d of %d visibility workloads are ready for enforcement", len(modeData)-1, len(sorted)), true)
+	if len(modeData) > 1 {
+		if modeFileName == "" {
+			modeFileName = fmt.Sprintf("workloader-enforcement-readiness-mode-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(modeData, modeData, modeFileName)
+		utils.LogInfo("review the mode file and use it as the input for the mode command to change the enforcement", true)
+	}
+}
+
+// evaluate sets the reasons a candidate is not ready
+func evaluate(c *candidate, now time.Time) {
+	if c.connections == 0 {
+		c.reasons = append(c.reasons, fmt.Sprintf("no traffic in %d days", days))
+	} else if c.coverage() < minCoverage {
+		c.reasons = append(c.reasons, fmt.Sprintf("%.1f%% coverage is less than %.1f%%", c.coverage(), minCoverage))
+	}
+	if c.recentPotentiallyBlocked > 0 {
+		c.reasons = append(c.reasons, fmt.Sprintf("%d potentially blocked flows in %d days", c.recentPotentiallyBlocked, recentDays))
+	}
+	if minVersion != "" && versionLess(c.version, minVersion) {
+		c.reasons = append(c.reasons, fmt.Sprintf("ven version %s is less than %s", c.version, minVersion))
+	}
+	if !c.wkld.Online {
+		c.reasons = append(c.reasons, "offline")
+	}
+	if c.wkld.Agent == nil || c.wkld.Agent.Status == nil {
+		c.reasons = append(c.reasons, "no agent status")
+		return
+	}
+	status := c.wkld.Agent.Status
+	if hb, err := time.Parse(time.RFC3339, status.LastHeartbeatOn); err != nil {
+		c.reasons = append(c.reasons, "no heartbeat")
+	} else if age := now.Sub(hb); age > maxHeartbeatAge {
+		c.reasons = append(c.reasons, fmt.Sprintf("last heartbeat %s ago", age.Round(time.Second)))
+	}
+	for _, h := range status.AgentHealth {
+		if h != nil && strings.EqualFold(h.Severity, "error") {
+			c.reasons = append(c.reasons, fmt.Sprintf("%s health condition", h.Type))
+		}
+	}
+}
+
+// versionParts returns the numbers of a version (e.g., 22.5.10-1234 is 22, 5, 10, 1234)
+func versionParts(version string) []int {
+	parts := []int{}
+	for _, p := range strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' }) {
+		i, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, i)
+	}
+	return parts
+}
+
+// versionLess returns true if version is lower than min. A version that cannot be parsed is lower.
+func versionLess(version, min string) bool {
+	v, m := versionParts(version), versionParts(min)
+	if len(v) == 0 {
+		return true
+	}
+	for i := range m {
+		vi := 0
+		if i < len(v) {
+			vi = v[i]
+		}
+		if vi != m[i] {
+			return vi < m[i]
+		}
+	}
+	return false
+}
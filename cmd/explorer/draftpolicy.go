@@ -0,0 +1,186 @@
+package explorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// draftTrafficAnalysis is an explorer result that includes the draft policy decision
+type draftTrafficAnalysis struct {
+	illumioapi.TrafficAnalysis
+	DraftPolicyDecision string `json:"draft_policy_decision"`
+}
+
+// asyncQueryStatus is used to check the status of an async query and its rule update
+type asyncQueryStatus struct {
+	Href   string `json:"href"`
+	Status string `json:"status"`
+	Rules  string `json:"rules"`
+	Result string `json:"result"`
+}
+
+// updateRules is used as the body of the PUT request to update the rules of an async query
+type updateRules struct {
+	Href string `json:"href,omitempty"`
+}
+
+// getDraftTraffic runs an async explorer query, re-evaluates the results against draft policy, and returns the flows.
+// The draft policy decision of each flow is stored in the draftDecisions map keyed by the flow's consolidateKey.
+func getDraftTraffic(tq illumioapi.TrafficQuery) ([]illumioapi.TrafficAnalysis, error) {
+
+	// Check the version. Draft policy decisions require the async explorer api.
+	if pce.Version.Major == 0 {
+		_, api, err := pce.GetVersion()
+		utils.LogAPIResp("GetVersion", api)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pce.Version.Major < 21 || (pce.Version.Major == 21 && pce.Version.Minor < 2) {
+		return nil, fmt.Errorf("draft policy decisions require pce version 21.2 or higher")
+	}
+
+	// Build the request
+	req, err := trafficRequest(tq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the async query
+	asyncQuery, api, err := pce.CreateAsyncTrafficRequest(req)
+	utils.LogAPIResp("CreateAsyncTrafficRequest", api)
+	if err != nil {
+		return nil, err
+	}
+	utils.LogInfo(fmt.Sprintf("created async explorer query %s", asyncQuery.Href), false)
+
+	// Wait for the query to complete
+	if _, err := waitForAsyncQuery(asyncQuery.Href, func(aq asyncQueryStatus) bool { return aq.Status == "completed" }); err != nil {
+		return nil, err
+	}
+
+	// Request the rules to be updated against draft policy
+	api, err = pce.Put(&updateRules{Href: asyncQuery.Href + "/update_rules"})
+	utils.LogAPIResp("UpdateRules", api)
+	if err != nil {
+		return nil, err
+	}
+	utils.LogInfo("re-evaluating flows against draft policy", true)
+
+	// Wait for the rules to be updated
+	aq, err := waitForAsyncQuery(asyncQuery.Href, func(aq asyncQueryStatus) bool { return aq.Rules == "completed" })
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the results
+	var draftTraffic []draftTrafficAnalysis
+	api, err = pce.GetCollectionHeaders(strings.TrimPrefix(aq.Result, fmt.Sprintf("/orgs/%d/", pce.Org)), false, nil, map[string]string{"Accept": "application/json"}, &draftTraffic)
+	utils.LogAPIResp("GetAsyncQueryResults", api)
+	if err != nil {
+		return nil, err
+	}
+
+	if draftDecisions == nil {
+		draftDecisions = make(map[string]string)
+	}
+	traffic := []illumioapi.TrafficAnalysis{}
+	for _, t := range draftTraffic {
+		traffic = append(traffic, t.TrafficAnalysis)
+		draftDecisions[consolidateKey(t.TrafficAnalysis)] = t.DraftPolicyDecision
+	}
+
+	return traffic, nil
+}
+
+// draftPollTimeout limits the wait for an async query when no long poll timeout is set
+const draftPollTimeout = time.Hour
+
+// waitForAsyncQuery polls the async queries until the provided check is met. It stops when the query fails, no longer exists,
+// or the long poll timeout (default 1 hour) is reached.
+func waitForAsyncQuery(href string, check func(aq asyncQueryStatus) bool) (asyncQueryStatus, error) {
+	deadline := utils.PollDeadline(pce.FriendlyName)
+	if deadline.IsZero() {
+		deadline = time.Now().Add(draftPollTimeout)
+	}
+	for {
+		var asyncQueries []asyncQueryStatus
+		api, err := pce.GetCollection("traffic_flows/async_queries", false, nil, &asyncQueries)
+		utils.LogAPIResp("GetAsyncQueries", api)
+		if err != nil {
+			return asyncQueryStatus{}, err
+		}
+		found := false
+		for _, aq := range asyncQueries {
+			if aq.Href != href {
+				continue
+			}
+			found = true
+			if aq.Status == "failed" || aq.Rules == "failed" {
+				return aq, fmt.Errorf("async query %s failed", href)
+			}
+			if check(aq) {
+				return aq, nil
+			}
+		}
+		if !found {
+			return asyncQueryStatus{}, fmt.Errorf("async query %s no longer exists", href)
+		}
+		if time.Now().After(deadline) {
+			return asyncQueryStatus{}, fmt.Errorf("async query %s did not complete before the long poll timeout. increase it with --long-poll-timeout", href)
+		}
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// trafficRequest returns the explorer request illumioapi builds for the traffic query. illumioapi only builds the request in GetTrafficAnalysis,
+// so the query is sent to a local server that records the request and returns no flows. The server reports a version before the async api (21.2)
+// so GetTrafficAnalysis sends the request once without polling.
+func trafficRequest(q illumioapi.TrafficQuery) (illumioapi.TrafficAnalysisRequest, error) {
+	body := make(chan []byte, 1)
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/product_version"):
+			fmt.Fprint(w, `{"version":"21.1.0"}`)
+		case strings.HasSuffix(r.URL.Path, "/traffic_flows/traffic_analysis_queries") && r.Method == http.MethodPost:
+			b, _ := io.ReadAll(r.Body)
+			select {
+			case body <- b:
+			default:
+			}
+			fmt.Fprint(w, "[]")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	addr := s.Listener.Addr().(*net.TCPAddr)
+	local := illumioapi.PCE{FQDN: addr.IP.String(), Port: addr.Port, Org: pce.Org, User: "workloader", Key: "workloader", DisableTLSChecking: true}
+	if _, _, err := local.GetTrafficAnalysis(q); err != nil {
+		return illumioapi.TrafficAnalysisRequest{}, err
+	}
+
+	var req illumioapi.TrafficAnalysisRequest
+	select {
+	case b := <-body:
+		if err := json.Unmarshal(b, &req); err != nil {
+			return illumioapi.TrafficAnalysisRequest{}, fmt.Errorf("building explorer request - %s", err)
+		}
+	default:
+		return illumioapi.TrafficAnalysisRequest{}, fmt.Errorf("building explorer request - no request was sent")
+	}
+	queryName := "workloader explorer draft policy"
+	req.QueryName = &queryName
+	return req, nil
+}
@@ -0,0 +1,50 @@
+package explorer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+)
+
+// TestTrafficRequest checks the draft policy request is the request illumioapi builds for the traffic query
+func TestTrafficRequest(t *testing.T) {
+	pce = illumioapi.PCE{Org: 1}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := illumioapi.TrafficQuery{
+		SourcesInclude:                  [][]string{{"/orgs/1/labels/1", "10.0.0.1"}},
+		DestinationsExclude:             []string{"/orgs/1/sec_policy/active/ip_lists/1"},
+		PortRangeInclude:                [][3]int{{1000, 2000, 6}},
+		WindowsServiceExclude:           []string{"wuauserv"},
+		TransmissionExcludes:            []string{"broadcast"},
+		StartTime:                       start,
+		EndTime:                         start.Add(24 * time.Hour),
+		PolicyStatuses:                  []string{"allowed"},
+		MaxFLows:                        1000,
+		QueryOperator:                   "or",
+		ExcludeWorkloadsFromIPListQuery: true,
+	}
+	req, err := trafficRequest(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.QueryName == nil || *req.QueryName != "workloader explorer draft policy" {
+		t.Errorf("query name is %v", req.QueryName)
+	}
+	if len(req.Sources.Include) != 1 || len(req.Sources.Include[0]) != 2 || req.Sources.Include[0][0].Label.Href != "/orgs/1/labels/1" || req.Sources.Include[0][1].IPAddress.Value != "10.0.0.1" {
+		t.Errorf("source includes are %+v", req.Sources.Include)
+	}
+	if len(req.Destinations.Exclude) != 2 || req.Destinations.Exclude[0].IPList.Href != "/orgs/1/sec_policy/active/ip_lists/1" || req.Destinations.Exclude[1].Transmission != "broadcast" {
+		t.Errorf("destination excludes are %+v", req.Destinations.Exclude)
+	}
+	if len(req.ExplorerServices.Include) != 1 || req.ExplorerServices.Include[0].ToPort != 2000 || len(req.ExplorerServices.Exclude) != 1 || req.ExplorerServices.Exclude[0].WindowsService != "wuauserv" {
+		t.Errorf("services are %+v", req.ExplorerServices)
+	}
+	if !req.StartDate.Equal(q.StartTime) || req.MaxResults != 1000 || req.SourcesDestinationsQueryOp != "or" || req.ExcludeWorkloadsFromIPListQuery == nil || !*req.ExcludeWorkloadsFromIPListQuery {
+		t.Errorf("request is %+v", req)
+	}
+
+	if _, err := trafficRequest(illumioapi.TrafficQuery{SourcesInclude: [][]string{{"not-an-object"}}}); err == nil {
+		t.Error("invalid include did not return an error")
+	}
+}
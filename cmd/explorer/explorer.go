@@ -1,6 +1,7 @@
 package explorer
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,12 +13,15 @@ import (
 	"github.com/spf13/viper"
 )
 
-var inclHrefDstFile, exclHrefDstFile, inclHrefSrcFile, exclHrefSrcFile, inclServiceCSV, exclServiceCSV, inclProcessCSV, exclProcessCSV, start, end, loopFile, outputFileName string
-var exclAllowed, exclPotentiallyBlocked, exclBlocked, exclUnknown, appGroupLoc, consolidate, nonUni, legacyOutput, consAndProvierOnLoop, exclWorkloadsFromIPListQuery bool
-var maxResults, iterativeThreshold int
+var inclHrefDstFile, exclHrefDstFile, inclHrefSrcFile, exclHrefSrcFile, inclServiceCSV, exclServiceCSV, inclProcessCSV, exclProcessCSV, start, end, loopFile, outputFileName, reportEmailTo, webhookURL string
+var exclAllowed, exclPotentiallyBlocked, exclBlocked, exclUnknown, appGroupLoc, consolidate, nonUni, legacyOutput, consAndProvierOnLoop, exclWorkloadsFromIPListQuery, draftPolicy bool
+var maxResults, iterativeThreshold, lookbackDays int
+var interval time.Duration
 var pce illumioapi.PCE
+var hec utils.HECConfig
 var err error
 var whm map[string]illumioapi.Workload
+var draftDecisions map[string]string
 
 func init() {
 
@@ -40,11 +44,22 @@ func init() {
 	ExplorerCmd.Flags().BoolVar(&exclUnknown, "excl-unknown", false, "excludes unkown policy decision traffic flows.")
 	ExplorerCmd.Flags().BoolVar(&nonUni, "incl-non-unicast", false, "includes non-unicast (broadcast and multicast) flows in the output. Default is unicast only.")
 	ExplorerCmd.Flags().IntVarP(&maxResults, "max-results", "m", 100000, "max results in explorer. Maximum value is 200000.")
-	ExplorerCmd.Flags().BoolVar(&consolidate, "consolidate", false, "consolidate flows that have same source IP, destination IP, port, and protocol.")
+	ExplorerCmd.Flags().BoolVar(&consolidate, "consolidate", false, "consolidate flows that only differ by timestamps (e.g., ephemeral source ports) into a single row. date_first and date_last become first and last seen, num_flows is summed, and a num_records column shows how many records were merged.")
+	ExplorerCmd.Flags().BoolVar(&draftPolicy, "draft-policy", false, "re-evaluate each flow against the current draft policy and add a draft_policy_status column showing what the decision would be after provisioning. requires pce 21.2+ and cannot be used with iterative-query-threshold.")
 	ExplorerCmd.Flags().BoolVar(&appGroupLoc, "loc-in-ag", false, "includes the location in the app group in CSV output.")
 	ExplorerCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename. If iterating through labels, the labels will be appended to the provided name before the provided file extension. To name the files for the labels, use just an extension (--output-file .csv).")
 	ExplorerCmd.Flags().IntVar(&iterativeThreshold, "iterative-query-threshold", 0, "If set greater than 0, workloader will run iterative explorer queries to maximize the return records. (Not advisable for most usecases).")
 
+	ExplorerCmd.Flags().DurationVar(&interval, "interval", 0, "run the query on a schedule with the provided interval (e.g., 24h or 168h). requires --lookback-days. the command runs until stopped. default of 0 runs once.")
+	ExplorerCmd.Flags().IntVar(&lookbackDays, "lookback-days", 0, "set the start date to this many days before each run and the end date to the day of the run. overrides start and end. useful with --interval.")
+	ExplorerCmd.Flags().StringVar(&reportEmailTo, "report-email-to", "", "comma-separated list of email addresses to send an html summary and the csv output(s) to after each run. see the command help for smtp settings.")
+	ExplorerCmd.Flags().StringVar(&webhookURL, "webhook-url", "", "url to post a json summary (flow counts by policy decision and output file names) to after each run.")
+	ExplorerCmd.Flags().StringVar(&hec.URL, "splunk-hec-url", "", "splunk http event collector url (e.g., https://splunk.company.com:8088) to send each output row to as an event.")
+	ExplorerCmd.Flags().StringVar(&hec.Token, "splunk-hec-token", "", "splunk http event collector token. default is the SPLUNK_HEC_TOKEN environment variable.")
+	ExplorerCmd.Flags().StringVar(&hec.SourceType, "splunk-sourcetype", "workloader:explorer", "sourcetype for splunk events.")
+	ExplorerCmd.Flags().StringVar(&hec.Index, "splunk-index", "", "splunk index for events. default is the index of the token.")
+	ExplorerCmd.Flags().BoolVar(&hec.Insecure, "splunk-insecure", false, "do not validate the splunk certificate.")
+
 	ExplorerCmd.Flags().BoolVar(&legacyOutput, "legacy", false, "legacy output")
 	ExplorerCmd.Flags().MarkHidden("legacy")
 
@@ -62,7 +77,17 @@ See the flags for filtering options.
 
 Use the following commands to get necessary HREFs for include/exlude files: label-export, ipl-export, wkld-export.
 
+Use --interval with --lookback-days to run the same query on a schedule (e.g., --interval 24h --lookback-days 1 for a daily report). --interval requires --lookback-days so each run queries a new date range.
+Results of each run can be delivered with --report-email-to and/or --webhook-url. Each flow can be sent to a Splunk HTTP Event Collector with --splunk-hec-url. Email requires the smtp_server, smtp_port, smtp_user, smtp_password, and smtp_from
+keys in pce.yaml or the WORKLOADER_SMTP_SERVER, WORKLOADER_SMTP_PORT, WORKLOADER_SMTP_USER, WORKLOADER_SMTP_PASSWORD, and WORKLOADER_SMTP_FROM environment variables.
+
 The update-pce and --no-prompt flags are ignored for this command.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// A fixed start and end would query the same range on every run
+		if interval > 0 && lookbackDays == 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--interval requires --lookback-days so each run queries a new date range")
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 
 		pce, err = utils.GetTargetPCE(true)
@@ -73,18 +98,22 @@ The update-pce and --no-prompt flags are ignored for this command.`,
 		// Set output to CSV only
 		viper.Set("output_format", "csv")
 
-		explorerExport()
+		runScheduled()
 	},
 }
 
-func explorerExport() {
+// explorerExport runs the explorer query and writes the csv output(s). Errors are returned so a scheduled run can log them and continue.
+func explorerExport() error {
 
 	// Log start
 	utils.LogStartCommand("explorer")
 
 	// Run some checks on iterative query value
 	if iterativeThreshold > 0 && iterativeThreshold > maxResults {
-		utils.LogError("iterative-query-threshold must be less than or equal to max results")
+		return errors.New("iterative-query-threshold must be less than or equal to max results")
+	}
+	if draftPolicy && iterativeThreshold > 0 {
+		return errors.New("draft-policy cannot be used with iterative-query-threshold")
 	}
 	if float64(iterativeThreshold) > 0.9*float64(maxResults) {
 		utils.LogWarning("recommended to set iterative-query-threshold lower than 90% of max results.", true)
@@ -95,7 +124,7 @@ func explorerExport() {
 
 	// Check max results for valid value
 	if maxResults < 1 || maxResults > 200000 {
-		utils.LogError("max-results must be between 1 and 200000")
+		return errors.New("max-results must be between 1 and 200000")
 	}
 	tq.MaxFLows = maxResults
 
@@ -103,7 +132,7 @@ func explorerExport() {
 	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true})
 	utils.LogMultiAPIResp(apiResps)
 	if err != nil {
-		utils.LogError(err.Error())
+		return err
 	}
 
 	// Build policy status slice
@@ -126,14 +155,14 @@ func explorerExport() {
 	// Get the start date
 	tq.StartTime, err = time.Parse("2006-01-02 MST", fmt.Sprintf("%s %s", start, "UTC"))
 	if err != nil {
-		utils.LogError(err.Error())
+		return err
 	}
 	tq.StartTime = tq.StartTime.In(time.UTC)
 
 	// Get the end date
 	tq.EndTime, err = time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 23:59:59 %s", end, "UTC"))
 	if err != nil {
-		utils.LogError(err.Error())
+		return err
 	}
 	tq.EndTime = tq.EndTime.In(time.UTC)
 
@@ -141,13 +170,13 @@ func explorerExport() {
 	if exclServiceCSV != "" {
 		tq.PortProtoExclude, err = utils.GetServicePortsCSV(exclServiceCSV)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 	}
 	if inclServiceCSV != "" {
 		tq.PortProtoInclude, err = utils.GetServicePortsCSV(inclServiceCSV)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 	}
 
@@ -155,13 +184,13 @@ func explorerExport() {
 	if inclProcessCSV != "" {
 		tq.ProcessInclude, err = utils.GetProcesses(inclProcessCSV)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 	}
 	if exclProcessCSV != "" {
 		tq.ProcessExclude, err = utils.GetProcesses(exclProcessCSV)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 	}
 
@@ -170,7 +199,7 @@ func explorerExport() {
 		// Parse the file
 		d, err := utils.ParseCSV(inclHrefSrcFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 		// For each entry in the file, add an include - OR operator
 		// Semi-colons are used to differentiate hrefs in the same include - AND operator.
@@ -186,7 +215,7 @@ func explorerExport() {
 		// Parse the file
 		d, err := utils.ParseCSV(inclHrefDstFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 		// For each entry in the file, add an include - OR operator
 		// Semi-colons are used to differentiate hrefs in the same include - AND operator.
@@ -202,7 +231,7 @@ func explorerExport() {
 		// Parse the file
 		d, err := utils.ParseCSV(exclHrefSrcFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 		// For each entry in the file, add an exclude - OR operator
 		for _, entry := range d {
@@ -215,7 +244,7 @@ func explorerExport() {
 		// Parse the file
 		d, err := utils.ParseCSV(exclHrefDstFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 		// For each entry in the file, add an exclude - OR operator
 		for _, entry := range d {
@@ -233,7 +262,7 @@ func explorerExport() {
 	if loopFile != "" {
 		d, err := utils.ParseCSV(loopFile)
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 
 		for _, n := range d {
@@ -251,7 +280,10 @@ func explorerExport() {
 
 	// If we aren't iterating - generate
 	if len(iterateList) == 0 {
-		if iterativeThreshold == 0 {
+		if draftPolicy {
+			utils.LogInfo("making single explorer query with draft policy decisions", false)
+			traffic, err = getDraftTraffic(tq)
+		} else if iterativeThreshold == 0 {
 			traffic, a, err = pce.GetTrafficAnalysis(tq)
 			utils.LogInfo("making single explorer query", false)
 			utils.LogInfo(a.ReqBody, false)
@@ -261,7 +293,7 @@ func explorerExport() {
 			traffic, err = pce.IterateTraffic(tq, true)
 		}
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 
 		outFileName := fmt.Sprintf("workloader-explorer-%s.csv", time.Now().Format("20060102_150405"))
@@ -271,12 +303,11 @@ func explorerExport() {
 
 		// Consolidate if needed
 		originalFlowCount := len(traffic)
+		var numRecords map[string]int
 		if consolidate {
-			cf := consolidateFlows(traffic)
-			traffic = nil
-			traffic = append(traffic, cf...)
+			traffic, numRecords = consolidateFlows(traffic)
 		}
-		createExplorerCSV(outFileName, traffic)
+		createExplorerCSV(outFileName, traffic, numRecords)
 		if consolidate {
 			utils.LogInfo(fmt.Sprintf("%d consolidated traffic records exported from %d total records", len(traffic), originalFlowCount), true)
 		} else {
@@ -284,10 +315,11 @@ func explorerExport() {
 		}
 		// Log end
 		utils.LogEndCommand("explorer")
-		return
+		return nil
 	}
 
 	// Get here if we are iterating.
+	progress := utils.NewProgress("querying label sets", len(iterateList))
 	for i, labels := range iterateList {
 
 		// Build the new query struct
@@ -304,10 +336,12 @@ func explorerExport() {
 		}
 
 		// Log the query
-		utils.LogInfo(fmt.Sprintf("Querying label set %d of %d - %s as a source", i+1, len(iterateList), strings.Join(logEntries, ";")), true)
+		utils.LogInfo(fmt.Sprintf("Querying label set %d of %d - %s as a source", i+1, len(iterateList), strings.Join(logEntries, ";")), false)
 
 		// Run the first traffic query with the app as a source
-		if iterativeThreshold == 0 {
+		if draftPolicy {
+			traffic, err = getDraftTraffic(newTQ)
+		} else if iterativeThreshold == 0 {
 			traffic, a, err = pce.GetTrafficAnalysis(newTQ)
 			utils.LogAPIResp("GetTrafficAnalysis", a)
 			utils.LogInfo(a.ReqBody, false)
@@ -316,7 +350,7 @@ func explorerExport() {
 			traffic, err = pce.IterateTraffic(newTQ, true)
 		}
 		if err != nil {
-			utils.LogError(err.Error())
+			return err
 		}
 
 		if consAndProvierOnLoop {
@@ -326,10 +360,12 @@ func explorerExport() {
 			newTQ.DestinationsInclude = append(newTQ.SourcesInclude, labels)
 
 			// Log the query
-			utils.LogInfo(fmt.Sprintf("Querying label set %d of %d - %s as a destination and depduping from source query", i+1, len(iterateList), strings.Join(logEntries, ";")), true)
+			utils.LogInfo(fmt.Sprintf("Querying label set %d of %d - %s as a destination and depduping from source query", i+1, len(iterateList), strings.Join(logEntries, ";")), false)
 
 			// Run the first traffic query with the app as a source
-			if iterativeThreshold == 0 {
+			if draftPolicy {
+				traffic2, err = getDraftTraffic(newTQ)
+			} else if iterativeThreshold == 0 {
 				traffic2, a, err = pce.GetTrafficAnalysis(newTQ)
 				utils.LogAPIResp("GetTrafficAnalysis", a)
 				utils.LogInfo(a.ReqBody, false)
@@ -337,7 +373,7 @@ func explorerExport() {
 				traffic2, err = pce.IterateTraffic(newTQ, true)
 			}
 			if err != nil {
-				utils.LogError(err.Error())
+				return err
 			}
 
 			// Now we need to de-dupe traffic1 and traffic 2
@@ -348,10 +384,9 @@ func explorerExport() {
 
 		// Consolidate if needed
 		originalFlowCount := len(traffic)
+		var numRecords map[string]int
 		if consolidate {
-			cf := consolidateFlows(traffic)
-			traffic = nil
-			traffic = append(traffic, cf...)
+			traffic, numRecords = consolidateFlows(traffic)
 		}
 
 		// Generate the CSV
@@ -380,7 +415,7 @@ func explorerExport() {
 
 				// Remove leading "-" if it exists
 			}
-			createExplorerCSV(outFileName, traffic)
+			createExplorerCSV(outFileName, traffic, numRecords)
 			if consolidate {
 				utils.LogInfo(fmt.Sprintf("%d consolidated traffic records exported from %d total records", len(traffic), originalFlowCount), true)
 			}
@@ -388,10 +423,13 @@ func explorerExport() {
 		} else {
 			utils.LogInfo(fmt.Sprintln("No traffic records."), true)
 		}
+		progress.Increment()
 	}
+	progress.Done()
 
 	// Log end
 	utils.LogEndCommand("explorer")
+	return nil
 }
 
 func wkldGW(hostname string, wkldHostMap map[string]illumioapi.Workload) string {
@@ -415,39 +453,84 @@ func wkldInterfaceName(hostname, ip string, wkldHostMap map[string]illumioapi.Wo
 	return "NA"
 }
 
-func consolidateFlows(trafficFlows []illumioapi.TrafficAnalysis) []illumioapi.TrafficAnalysis {
-	cTraffic := make(map[string]illumioapi.TrafficAnalysis)
+// consolidatedFlow holds a flow and the number of raw records merged into it
+type consolidatedFlow struct {
+	flow       illumioapi.TrafficAnalysis
+	numRecords int
+}
+
+// consolidateFlows merges flows that only differ by their timestamps (e.g., ephemeral source ports reported as separate records).
+// The merged flow keeps the earliest first detected and latest last detected timestamps and sums the connection counts.
+func consolidateFlows(trafficFlows []illumioapi.TrafficAnalysis) ([]illumioapi.TrafficAnalysis, map[string]int) {
+	cTraffic := make(map[string]*consolidatedFlow)
+	keys := []string{}
 	for _, t := range trafficFlows {
-		if val, ok := cTraffic[fmt.Sprintf("%s%s%d%d", t.Src.IP, t.Dst.IP, t.ExpSrv.Port, t.ExpSrv.Proto)]; !ok {
-			t.PolicyDecision = fmt.Sprintf("%s (%d)", t.PolicyDecision, t.NumConnections)
-			cTraffic[fmt.Sprintf("%s%s%d%d", t.Src.IP, t.Dst.IP, t.ExpSrv.Port, t.ExpSrv.Proto)] = t
-		} else {
-			// We already have an entry so we consolidate. Start by building the new with src, dst, port, and proto
-			tNew := illumioapi.TrafficAnalysis{Src: t.Src, Dst: t.Dst, ExpSrv: &illumioapi.ExpSrv{Port: t.ExpSrv.Port, Proto: t.ExpSrv.Proto}}
-			// New connections is the old value plus this new one.
-			tNew.NumConnections = val.NumConnections + t.NumConnections
-			// The time stamps are semi-colon separated
-			tNew.TimestampRange = &illumioapi.TimestampRange{FirstDetected: fmt.Sprintf("%s; %s", val.TimestampRange.FirstDetected, t.TimestampRange.FirstDetected), LastDetected: fmt.Sprintf("%s; %s", val.TimestampRange.LastDetected, t.TimestampRange.LastDetected)}
-			// Process, windows, service, and transmission type are also semi-colon separated
-			tNew.ExpSrv.Process = fmt.Sprintf("%s; %s", val.ExpSrv.Process, t.ExpSrv.Process)
-			tNew.ExpSrv.WindowsService = fmt.Sprintf("%s; %s", val.ExpSrv.WindowsService, t.ExpSrv.WindowsService)
-			tNew.ExpSrv.User = fmt.Sprintf("%s; %s", val.ExpSrv.User, t.ExpSrv.User)
-			tNew.Transmission = fmt.Sprintf("%s; %s", val.Transmission, t.Transmission)
-			// Policy decision includes the flow counter
-			tNew.PolicyDecision = fmt.Sprintf("%s; %s(%d)", val.PolicyDecision, t.PolicyDecision, t.NumConnections)
-			// Replace the value
-			cTraffic[fmt.Sprintf("%s%s%d%d", t.Src.IP, t.Dst.IP, t.ExpSrv.Port, t.ExpSrv.Proto)] = tNew
+		key := consolidateKey(t)
+		val, ok := cTraffic[key]
+		if !ok {
+			// Copy the timestamp range so edits don't change the original flow
+			if t.TimestampRange != nil {
+				t.TimestampRange = &illumioapi.TimestampRange{FirstDetected: t.TimestampRange.FirstDetected, LastDetected: t.TimestampRange.LastDetected}
+			} else {
+				t.TimestampRange = &illumioapi.TimestampRange{}
+			}
+			cTraffic[key] = &consolidatedFlow{flow: t, numRecords: 1}
+			keys = append(keys, key)
+			continue
+		}
+
+		// We already have an entry so we consolidate. Connections are summed.
+		val.numRecords++
+		val.flow.NumConnections = val.flow.NumConnections + t.NumConnections
+		if t.TimestampRange == nil {
+			continue
+		}
+		// Keep the earliest first detected and the latest last detected
+		if earlierTimestamp(t.TimestampRange.FirstDetected, val.flow.TimestampRange.FirstDetected) {
+			val.flow.TimestampRange.FirstDetected = t.TimestampRange.FirstDetected
+		}
+		if earlierTimestamp(val.flow.TimestampRange.LastDetected, t.TimestampRange.LastDetected) {
+			val.flow.TimestampRange.LastDetected = t.TimestampRange.LastDetected
 		}
 	}
 
 	var returnResults []illumioapi.TrafficAnalysis
-	for _, t := range cTraffic {
-		returnResults = append(returnResults, t)
+	numRecords := make(map[string]int)
+	for _, k := range keys {
+		returnResults = append(returnResults, cTraffic[k].flow)
+		numRecords[k] = cTraffic[k].numRecords
+	}
+	return returnResults, numRecords
+}
+
+// consolidateKey returns the key used to consolidate flows. Everything except timestamps and connection counts is included.
+func consolidateKey(t illumioapi.TrafficAnalysis) string {
+	process, winService, user := "", "", ""
+	port, proto := 0, 0
+	if t.ExpSrv != nil {
+		process, winService, user, port, proto = t.ExpSrv.Process, t.ExpSrv.WindowsService, t.ExpSrv.User, t.ExpSrv.Port, t.ExpSrv.Proto
+	}
+	return strings.Join([]string{t.Src.IP, t.Dst.IP, strconv.Itoa(port), strconv.Itoa(proto), process, winService, user, t.Transmission, t.PolicyDecision}, "|")
+}
+
+// earlierTimestamp returns true if a is before b. Blank values are never earlier.
+func earlierTimestamp(a, b string) bool {
+	if a == "" {
+		return false
 	}
-	return returnResults
+	if b == "" {
+		return true
+	}
+	aTime, errA := time.Parse(time.RFC3339, a)
+	bTime, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return aTime.Before(bTime)
 }
 
-func createExplorerCSV(filename string, traffic []illumioapi.TrafficAnalysis) {
+// createExplorerCSV writes the traffic to a CSV. numRecords is populated when flows are consolidated and adds a num_records column.
+func createExplorerCSV(filename string, traffic []illumioapi.TrafficAnalysis, numRecords map[string]int) {
 
 	// Build our CSV structure
 	data := [][]string{{"src_ip", "src_interface_name", "src_net_mask", "src_default_gw", "src_hostname", "src_role", "src_app", "src_env", "src_loc", "src_app_group", "src_ip_lists", "dst_ip", "dst_interface_name", "dst_net_mask", "dst_default_gw", "dst_hostname", "dst_role", "dst_app", "dst_env", "dst_loc", "dst_app_group", "dst_ip_lists", "port", "protocol", "process", "windows_service", "user", "transmission", "policy_status", "date_first", "date_last", "num_flows"}}
@@ -455,8 +538,15 @@ func createExplorerCSV(filename string, traffic []illumioapi.TrafficAnalysis) {
 	if legacyOutput {
 		data = [][]string{{"src_ip", "src_interface_name", "src_net_mask", "src_default_gw", "src_hostname", "src_role", "src_app", "src_env", "src_loc", "src_app_group", "dst_ip", "dst_interface_name", "dst_net_mask", "dst_default_gw", "dst_hostname", "dst_role", "dst_app", "dst_env", "dst_loc", "dst_app_group", "port", "protocol", "policy_status", "date_first", "date_last", "num_flows"}}
 	}
+	if draftPolicy {
+		data[0] = append(data[0], "draft_policy_status")
+	}
+	if numRecords != nil {
+		data[0] = append(data[0], "num_records")
+	}
 
-	// Add each traffic entry to the data slice
+	// Stream each traffic entry to the output. Rows are only kept for splunk.
+	stream := utils.NewOutputStream(filename, data[0])
 	for _, t := range traffic {
 		src := []string{t.Src.IP, "NA", "NA", "NA", "NA", "NA", "NA", "NA", "NA", "NA"}
 		if t.Src.Workload != nil {
@@ -520,7 +610,27 @@ func createExplorerCSV(filename string, traffic []illumioapi.TrafficAnalysis) {
 		d = append(d, t.TimestampRange.FirstDetected)
 		d = append(d, t.TimestampRange.LastDetected)
 		d = append(d, strconv.Itoa(t.NumConnections))
-		data = append(data, d)
+		if policyCounts != nil {
+			policyCounts[t.PolicyDecision]++
+		}
+		if draftPolicy {
+			d = append(d, draftDecisions[consolidateKey(t)])
+		}
+		if numRecords != nil {
+			d = append(d, strconv.Itoa(numRecords[consolidateKey(t)]))
+		}
+		stream.Write(d)
+		if hec.URL != "" {
+			data = append(data, d)
+		}
+	}
+	stream.Close()
+	if f := stream.FileName(); f != "" {
+		outputFiles = append(outputFiles, f)
+	}
+	if hec.URL != "" {
+		if err := utils.SendHEC(hec, "explorer", data); err != nil {
+			utils.LogWarning(fmt.Sprintf("sending to splunk hec - %s", err), true)
+		}
 	}
-	utils.WriteOutput(data, data, filename)
 }
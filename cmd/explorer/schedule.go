@@ -0,0 +1,118 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// outputFiles and policyCounts are populated by createExplorerCSV and reset before each scheduled run
+var outputFiles []string
+var policyCounts map[string]int
+
+// webhookPayload is the body posted to the webhook after each run
+type webhookPayload struct {
+	Command      string         `json:"command"`
+	PCE          string         `json:"pce"`
+	Start        string         `json:"start"`
+	End          string         `json:"end"`
+	Files        []string       `json:"files"`
+	TotalFlows   int            `json:"total_flows"`
+	PolicyCounts map[string]int `json:"policy_counts"`
+}
+
+// webhookClient posts the run summary. The timeout keeps a slow webhook from holding up the next scheduled run.
+var webhookClient = &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+
+// runScheduled runs the explorer export once or on an interval, delivering the results after each run.
+// A single run exits on an error. A scheduled run logs the error and waits for the next run.
+func runScheduled() {
+
+	for {
+		// Reset the tracking variables
+		outputFiles = nil
+		policyCounts = make(map[string]int)
+
+		// Adjust the dates if a look back is provided
+		if lookbackDays > 0 {
+			start = time.Now().AddDate(0, 0, -lookbackDays).In(time.UTC).Format("2006-01-02")
+			end = time.Now().In(time.UTC).Format("2006-01-02")
+		}
+
+		if err := explorerExport(); err != nil {
+			if interval == 0 {
+				utils.LogError(err.Error())
+			}
+			utils.LogWarning(fmt.Sprintf("explorer run failed - %s", err), true)
+		} else {
+			deliverReport()
+		}
+
+		if interval == 0 {
+			return
+		}
+		utils.LogInfo(fmt.Sprintf("next explorer run at %s", time.Now().Add(interval).Format("2006-01-02 15:04:05")), true)
+		time.Sleep(interval)
+	}
+}
+
+// deliverReport emails and/or posts the results of the run
+func deliverReport() {
+
+	if reportEmailTo == "" && webhookURL == "" {
+		return
+	}
+
+	// Sort the policy decisions for consistent output
+	decisions := []string{}
+	total := 0
+	for d, c := range policyCounts {
+		decisions = append(decisions, d)
+		total = total + c
+	}
+	sort.Strings(decisions)
+
+	if reportEmailTo != "" {
+		var body strings.Builder
+		body.WriteString(fmt.Sprintf("<h3>workloader explorer report - %s</h3>", html.EscapeString(pce.FriendlyName)))
+		body.WriteString(fmt.Sprintf("<p>traffic from %s to %s</p>", html.EscapeString(start), html.EscapeString(end)))
+		body.WriteString("<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\"><tr><th>policy decision</th><th>flows</th></tr>")
+		for _, d := range decisions {
+			body.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>", html.EscapeString(d), policyCounts[d]))
+		}
+		body.WriteString(fmt.Sprintf("<tr><td><b>total</b></td><td><b>%d</b></td></tr></table>", total))
+		if len(outputFiles) == 0 {
+			body.WriteString("<p>no traffic records.</p>")
+		}
+
+		recipients := strings.Split(strings.ReplaceAll(reportEmailTo, " ", ""), ",")
+		subject := fmt.Sprintf("workloader explorer report - %s - %s", pce.FriendlyName, time.Now().Format("2006-01-02"))
+		if err := utils.SendEmail(recipients, subject, body.String(), outputFiles); err != nil {
+			utils.LogWarning(fmt.Sprintf("sending email - %s", err), true)
+		} else {
+			utils.LogInfo(fmt.Sprintf("emailed explorer report to %s", strings.Join(recipients, ", ")), true)
+		}
+	}
+
+	if webhookURL != "" {
+		payload, err := json.Marshal(webhookPayload{Command: "explorer", PCE: pce.FriendlyName, Start: start, End: end, Files: outputFiles, TotalFlows: total, PolicyCounts: policyCounts})
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("creating webhook payload - %s", err), true)
+			return
+		}
+		resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewBuffer(payload))
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("posting to webhook - %s", err), true)
+			return
+		}
+		resp.Body.Close()
+		utils.LogInfo(fmt.Sprintf("posted explorer summary to webhook - status code %d", resp.StatusCode), true)
+	}
+}
@@ -0,0 +1,59 @@
+package exposure
+
+import (
+	"fmt"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var policy, outputFileName, summaryFileName string
+var internetOnly, appGroupLoc bool
+
+func init() {
+	ExposureCmd.Flags().StringVar(&policy, "policy", "both", "policy to evaluate. options are active, draft, or both.")
+	ExposureCmd.Flags().BoolVar(&internetOnly, "internet-only", false, "only report services reachable from ip lists with 0.0.0.0/0 or ::/0.")
+	ExposureCmd.Flags().BoolVarP(&appGroupLoc, "appgrp-loc", "l", false, "use location in app group.")
+	ExposureCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the workload output file location. default is current location with a timestamped filename.")
+	ExposureCmd.Flags().StringVar(&summaryFileName, "summary-file", "", "optionally specify the name of the app group summary file location. default is current location with a timestamped filename.")
+	ExposureCmd.Flags().SortFlags = false
+}
+
+// ExposureCmd reports the services reachable from ip lists
+var ExposureCmd = &cobra.Command{
+	Use:   "exposure",
+	Short: "Report the ports on each managed workload reachable from ip lists under draft and active policy.",
+	Long: `
+Report the ports on each managed workload reachable from ip lists under draft and active policy to find internet-exposed services.
+
+Every enabled rule in an enabled ruleset with an ip list consumer is resolved to the managed workloads it allows. Providers are all workloads, labels, label groups, and workloads in the ruleset scope. Labels of different keys must all match and labels of the same key are any match. Virtual service and virtual server providers are not included.
+
+An ip list with 0.0.0.0/0 or ::/0 is internet exposed. Use --internet-only to only report those.
+
+The workload output has a row for each workload, service, and ip list with whether the active and draft policy allow it and the rulesets that allow it, so a draft change that adds or removes exposure is easy to find. The summary output has the exposed workloads and internet-exposed services of each app group for each policy.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report exposure in the draft and active policy
+  workloader exposure
+
+  # Report internet-exposed services in the active policy
+  workloader exposure --policy active --internet-only`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if policy != "active" && policy != "draft" && policy != "both" {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--policy must be active, draft, or both - %s", policy))
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("exposure")
+		exposure()
+		utils.LogEndCommand("exposure")
+	},
+}
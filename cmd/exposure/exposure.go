@@ -0,0 +1,345 @@
+package exposure
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// exposed is a service on a workload reachable from an ip list
+type exposed struct {
+	wkld     illumioapi.Workload
+	service  string
+	ipList   string
+	internet bool
+	policy   map[string]bool
+	ruleSets map[string]bool
+	rules    map[string]bool
+}
+
+// labelSet is label hrefs by key. A workload matches if it has one of the labels of every key.
+type labelSet map[string]map[string]bool
+
+func exposure() {
+
+	// Load the labels and managed workloads
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true, WorkloadsQueryParameters: map[string]string{"managed": "true"}})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	statuses := []string{"active", "draft"}
+	if policy != "both" {
+		statuses = []string{policy}
+	}
+
+	// Evaluate each policy. The policy objects are loaded for each provision status.
+	exposures := make(map[[3]string]*exposed)
+	for _, status := range statuses {
+		apiResps, err := pce.Load(illumioapi.LoadInput{ProvisionStatus: status, IPLists: true, Services: true, RuleSets: true, LabelGroups: true})
+		utils.LogMultiAPIResp(apiResps)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		evaluate(status, exposures)
+	}
+
+	// Sort internet exposure first and then by hostname
+	sorted := []*exposed{}
+	for _, e := range exposures {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].internet != sorted[j].internet {
+			return sorted[i].internet
+		}
+		if sorted[i].wkld.Hostname != sorted[j].wkld.Hostname {
+			return sorted[i].wkld.Hostname < sorted[j].wkld.Hostname
+		}
+		if sorted[i].service != sorted[j].service {
+			return sorted[i].service < sorted[j].service
+		}
+		return sorted[i].ipList < sorted[j].ipList
+	})
+
+	// Build the workload output and the app group summary
+	data := [][]string{{"hostname", "href", "app_group", "enforcement", "service", "ip_list", "internet_exposed", "active", "draft", "rulesets", "rule_hrefs"}}
+	stdOutData := [][]string{{"hostname", "service", "ip_list", "internet_exposed", "active", "draft"}}
+	type groupSummary struct {
+		workloads, internetWorkloads, services, ipLists map[string]bool
+	}
+	summaries := make(map[[2]string]*groupSummary)
+	internetWklds := make(map[string]bool)
+	for _, e := range sorted {
+		appGroup := e.wkld.GetAppGroup(pce.Labels)
+		if appGroupLoc {
+			appGroup = e.wkld.GetAppGroupL(pce.Labels)
+		}
+		data = append(data, []string{e.wkld.Hostname, e.wkld.Href, appGroup, e.wkld.GetMode(), e.service, e.ipList, fmt.Sprintf("%t", e.internet), policyValue(e, "active"), policyValue(e, "draft"), joinKeys(e.ruleSets), joinKeys(e.rules)})
+		stdOutData = append(stdOutData, []string{e.wkld.Hostname, e.service, e.ipList, fmt.Sprintf("%t", e.internet), policyValue(e, "active"), policyValue(e, "draft")})
+		if e.internet {
+			internetWklds[e.wkld.Href] = true
+		}
+
+		for _, status := range statuses {
+			if !e.policy[status] {
+				continue
+			}
+			s, ok := summaries[[2]string{appGroup, status}]
+			if !ok {
+				s = &groupSummary{workloads: make(map[string]bool), internetWorkloads: make(map[string]bool), services: make(map[string]bool), ipLists: make(map[string]bool)}
+				summaries[[2]string{appGroup, status}] = s
+			}
+			s.workloads[e.wkld.Href] = true
+			s.ipLists[e.ipList] = true
+			if e.internet {
+				s.internetWorkloads[e.wkld.Href] = true
+				s.services[e.service] = true
+			}
+		}
+	}
+	keys := [][2]string{}
+	for k := range summaries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ii, ij := len(summaries[keys[i]].internetWorkloads), len(summaries[keys[j]].internetWorkloads)
+		if ii != ij {
+			return ii > ij
+		}
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	summaryData := [][]string{{"app_group", "policy", "exposed_workloads", "internet_exposed_workloads", "internet_exposed_services", "ip_lists"}}
+	for _, k := range keys {
+		s := summaries[k]
+		summaryData = append(summaryData, []string{k[0], k[1], fmt.Sprintf("%d", len(s.workloads)), fmt.Sprintf("%d", len(s.internetWorkloads)), joinKeys(s.services), joinKeys(s.ipLists)})
+	}
+
+	if len(internetWklds) > 0 {
+		utils.LogWarning(fmt.Sprintf("%d workloads have internet-exposed services in the %s policy", len(internetWklds), strings.Join(statuses, " or ")), true)
+	}
+	utils.LogInfo(fmt.Sprintf("%d exposed services on workloads from ip lists", len(data)-1), true)
+	if len(data) == 1 {
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-exposure-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, stdOutData, outputFileName)
+	if summaryFileName == "" {
+		summaryFileName = fmt.Sprintf("workloader-exposure-summary-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(summaryData, summaryData, summaryFileName)
+}
+
+// evaluate adds the exposure of the loaded policy. The ruleset map is keyed by href and name so only hrefs are used.
+func evaluate(status string, exposures map[[3]string]*exposed) {
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href || rs.Enabled != nil && !*rs.Enabled {
+			continue
+		}
+		for _, r := range rs.Rules {
+			if r == nil || r.Enabled != nil && !*r.Enabled {
+				continue
+			}
+			if r.ResolveLabelsAs != nil && len(r.ResolveLabelsAs.Providers) > 0 && !contains(r.ResolveLabelsAs.Providers, "workloads") {
+				continue
+			}
+
+			// Get the ip list consumers
+			ipLists := []illumioapi.IPList{}
+			for _, c := range r.Consumers {
+				if c != nil && c.IPList != nil {
+					if ipl, ok := pce.IPLists[c.IPList.Href]; ok && (!internetOnly || utils.AnyIP(ipl)) {
+						ipLists = append(ipLists, ipl)
+					}
+				}
+			}
+			if len(ipLists) == 0 {
+				continue
+			}
+
+			services := ruleServices(r)
+			for _, w := range pce.WorkloadsSlice {
+				if !inScope(w, rs) || !isProvider(w, r) {
+					continue
+				}
+				for _, ipl := range ipLists {
+					for _, svc := range services {
+						k := [3]string{w.Href, svc, ipl.Name}
+						e, ok := exposures[k]
+						if !ok {
+							e = &exposed{wkld: w, service: svc, ipList: ipl.Name, internet: utils.AnyIP(ipl), policy: make(map[string]bool), ruleSets: make(map[string]bool), rules: make(map[string]bool)}
+							exposures[k] = e
+						}
+						e.policy[status] = true
+						e.ruleSets[rs.Name] = true
+						e.rules[r.Href] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// inScope returns true if the workload is in a scope of the ruleset. A ruleset without scopes or with an empty scope is global.
+func inScope(w illumioapi.Workload, rs illumioapi.RuleSet) bool {
+	if len(rs.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range rs.Scopes {
+		set := make(labelSet)
+		for _, s := range scope {
+			if s == nil {
+				continue
+			}
+			if s.Label != nil {
+				set.add(s.Label.Href)
+			}
+			if s.LabelGroup != nil {
+				set.addGroup(s.LabelGroup.Href)
+			}
+		}
+		if set.matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// isProvider returns true if the workload is a provider of the rule
+func isProvider(w illumioapi.Workload, r *illumioapi.Rule) bool {
+	set := make(labelSet)
+	for _, p := range r.Providers {
+		if p == nil {
+			continue
+		}
+		if p.Actors == "ams" || p.Workload != nil && p.Workload.Href == w.Href {
+			return true
+		}
+		if p.Label != nil {
+			set.add(p.Label.Href)
+		}
+		if p.LabelGroup != nil {
+			set.addGroup(p.LabelGroup.Href)
+		}
+	}
+	return len(set) > 0 && set.matches(w)
+}
+
+// add adds a label to the set
+func (s labelSet) add(href string) {
+	key := pce.Labels[href].Key
+	if s[key] == nil {
+		s[key] = make(map[string]bool)
+	}
+	s[key][href] = true
+}
+
+// addGroup adds the labels of a label group and its sub groups to the set
+func (s labelSet) addGroup(href string) {
+	for _, l := range pce.ExpandLabelGroup(href) {
+		s.add(l)
+	}
+}
+
+// matches returns true if the workload has one of the labels of every key in the set
+func (s labelSet) matches(w illumioapi.Workload) bool {
+	for key, hrefs := range s {
+		if !hrefs[w.GetLabelByKey(key, pce.Labels).Href] {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleServices returns the services of a rule as port and protocol strings
+func ruleServices(r *illumioapi.Rule) []string {
+	protocols := illumioapi.ProtocolList()
+	services := []string{}
+	portString := func(port, toPort, proto int) string {
+		if proto == -1 {
+			return "all services"
+		}
+		if port == 0 && (proto == 6 || proto == 17) {
+			return fmt.Sprintf("all %s", protocols[proto])
+		}
+		if port == 0 {
+			return protocols[proto]
+		}
+		if toPort > port {
+			return fmt.Sprintf("%d-%d %s", port, toPort, protocols[proto])
+		}
+		return fmt.Sprintf("%d %s", port, protocols[proto])
+	}
+	if r.IngressServices == nil {
+		return services
+	}
+	for _, s := range *r.IngressServices {
+		if s == nil {
+			continue
+		}
+		if s.Href != nil {
+			svc := pce.Services[*s.Href]
+			for _, sp := range svc.ServicePorts {
+				if sp != nil {
+					services = append(services, portString(sp.Port, sp.ToPort, sp.Protocol))
+				}
+			}
+			for _, ws := range svc.WindowsServices {
+				if ws != nil {
+					services = append(services, portString(ws.Port, ws.ToPort, ws.Protocol))
+				}
+			}
+			continue
+		}
+		port, toPort, proto := 0, 0, 0
+		if s.Port != nil {
+			port = *s.Port
+		}
+		if s.ToPort != nil {
+			toPort = *s.ToPort
+		}
+		if s.Protocol != nil {
+			proto = *s.Protocol
+		}
+		services = append(services, portString(port, toPort, proto))
+	}
+	return services
+}
+
+// policyValue returns the value of the active or draft column. It is blank if the policy was not evaluated.
+func policyValue(e *exposed, status string) string {
+	if policy != "both" && policy != status {
+		return ""
+	}
+	return fmt.Sprintf("%t", e.policy[status])
+}
+
+// joinKeys returns the sorted keys of a set joined with semicolons
+func joinKeys(m map[string]bool) string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ";")
+}
+
+// contains returns true if s is in the slice
+func contains(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -69,31 +69,34 @@ func workloads() {
 	if err != nil {
 		utils.LogError(err.Error())
 	}
-	// Iterate through each workload
+	// Get each workload so we can include service details that GetAllWorkloads does not have
+	progress := utils.NewProgress("exporting workloads", len(wklds))
+	responses, err := utils.GetPages(len(wklds), func(i int) (string, illumioapi.APIResponse, error) {
+		_, a, err := pce.GetWkldByHref(wklds[i].Href)
+		progress.Increment()
+		return a.RespBody, a, err
+	})
+	progress.Done()
+	if err != nil {
+		utils.LogError(err.Error())
+	}
 	for i, w := range wklds {
-		// Get the workload so we can include service details that GetAllWorkloads does not have
-		w, a, err := pce.GetWkldByHref(w.Href)
-		if err != nil {
-			utils.LogError(err.Error())
-		}
 		// Create the file
 		wkldFile, err := os.Create(fmt.Sprintf("%s/workloads/%s.json", outDir, strings.TrimPrefix(w.Href, fmt.Sprintf("/orgs/%d/workloads/", pce.Org))))
 		if err != nil {
 			utils.LogError(err.Error())
 		}
 		// Write the file
-		_, err = wkldFile.WriteString(a.RespBody)
+		_, err = wkldFile.WriteString(responses[i])
 		if err != nil {
 			utils.LogError(err.Error())
 		}
 		// CLose the file
 		wkldFile.Close()
-		// Update progress
-		fmt.Printf("\rExported %d of %d workloads (%d%%).", i, len(wklds), i*100/len(wklds))
 	}
+
 	// Update stdout
-	fmt.Printf("\r                                                      ")
-	fmt.Printf("\rExported %d workloads.\r\n", len(wklds))
+	fmt.Printf("Exported %d workloads.\r\n", len(wklds))
 }
 
 func services() {
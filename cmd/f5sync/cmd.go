@@ -0,0 +1,77 @@
+package f5sync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var f5 bigIP
+var f5User, f5Password, partitions, vipsAs, mappingFile, externalDataSet, outputFileName string
+var insecure, cleanup, noBindings, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	F5SyncCmd.Flags().StringVarP(&f5.server, "f5-server", "s", "", "big-ip management address in format server.com or server.com:8443.")
+	F5SyncCmd.Flags().StringVarP(&f5User, "f5-user", "u", "", "big-ip user. default is the F5_USER environment variable.")
+	F5SyncCmd.Flags().StringVarP(&f5Password, "f5-pwd", "p", "", "big-ip password. default is the F5_PASSWORD environment variable.")
+	F5SyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the big-ip certificate.")
+	F5SyncCmd.Flags().StringVar(&partitions, "partitions", "", "comma-separated list of partitions to sync. default is all partitions.")
+	F5SyncCmd.Flags().StringVar(&vipsAs, "vips-as", "virtual-service", "represent virtual servers as virtual-service or umwl.")
+	F5SyncCmd.Flags().BoolVar(&noBindings, "no-bindings", false, "do not bind pool member workloads to the virtual services.")
+	F5SyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: pseudo key (f5:partition, f5:name, f5:pool, f5:description) and illumio label key.")
+	F5SyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "f5-sync", "external data set used to identify objects managed by f5-sync.")
+	F5SyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete virtual services and unmanaged workloads in the external data set that are no longer on the big-ip.")
+	F5SyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	F5SyncCmd.Flags().SortFlags = false
+}
+
+// F5SyncCmd runs the f5-sync command
+var F5SyncCmd = &cobra.Command{
+	Use:         "f5-sync",
+	Short:       "Create an Illumio virtual service or unmanaged workload for each BIG-IP virtual server with pool members bound.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create an Illumio virtual service or unmanaged workload for each BIG-IP virtual server with pool members bound.
+
+Virtual servers and pool members are read with iControl REST. Route domains are removed from addresses.
+
+With --vips-as virtual-service (default), each virtual server is a virtual service with the destination ip as the ip override and the destination port as the service. Pool members are matched to PCE workloads by ip address and bound to the virtual service, with a port override when the member port is different. Bindings are updated on each run. Virtual services are provisioned after they are created or updated.
+
+With --vips-as umwl, each virtual server is an unmanaged workload with the destination ip as the interface.
+
+The external data reference is the virtual server full path (e.g., /Common/vs_web).
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if f5.server == "" {
+			utils.LogError("--f5-server is required")
+		}
+		if f5User == "" {
+			f5User = os.Getenv("F5_USER")
+		}
+		if f5Password == "" {
+			f5Password = os.Getenv("F5_PASSWORD")
+		}
+		if vipsAs != "virtual-service" && vipsAs != "umwl" {
+			utils.LogError("--vips-as must be virtual-service or umwl")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		f5Sync()
+	},
+}
@@ -0,0 +1,138 @@
+package f5sync
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bigIP calls the BIG-IP iControl REST API
+type bigIP struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// f5Virtual is the subset of an ltm virtual server used by f5-sync
+type f5Virtual struct {
+	Name        string `json:"name"`
+	Partition   string `json:"partition"`
+	FullPath    string `json:"fullPath"`
+	Destination string `json:"destination"`
+	IPProtocol  string `json:"ipProtocol"`
+	Pool        string `json:"pool"`
+	Description string `json:"description"`
+	Disabled    bool   `json:"disabled"`
+}
+
+// f5PoolMember is the subset of an ltm pool member used by f5-sync
+type f5PoolMember struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// login gets an authentication token
+func (b *bigIP) login(user, password string, insecure bool) error {
+	b.client = &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}
+	var resp struct {
+		Token struct {
+			Token string `json:"token"`
+		} `json:"token"`
+	}
+	if err := b.call("POST", "/mgmt/shared/authn/login", map[string]string{"username": user, "password": password, "loginProviderName": "tmos"}, &resp); err != nil {
+		return err
+	}
+	b.token = resp.Token.Token
+	return nil
+}
+
+// call sends a request to the api and unmarshals the response into result
+func (b *bigIP) call(method, path string, payload, result interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("https://%s%s", b.server, path), reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("X-F5-Auth-Token", b.token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s - %d - %s", method, path, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// getVirtuals returns all ltm virtual servers
+func (b *bigIP) getVirtuals() ([]f5Virtual, error) {
+	var resp struct {
+		Items []f5Virtual `json:"items"`
+	}
+	err := b.call("GET", "/mgmt/tm/ltm/virtual", nil, &resp)
+	return resp.Items, err
+}
+
+// getPoolMembers returns the members of a pool using its full path (e.g., /Common/pool_web)
+func (b *bigIP) getPoolMembers(poolPath string) ([]f5PoolMember, error) {
+	var resp struct {
+		Items []f5PoolMember `json:"items"`
+	}
+	err := b.call("GET", fmt.Sprintf("/mgmt/tm/ltm/pool/%s/members", strings.ReplaceAll(poolPath, "/", "~")), nil, &resp)
+	return resp.Items, err
+}
+
+// parseDestination parses a virtual server destination or pool member name (e.g., /Common/10.0.0.1%1:443 or /Common/2001:db8::1.443) into the ip and port
+func parseDestination(destination string) (string, int, error) {
+	d := destination[strings.LastIndex(destination, "/")+1:]
+
+	// IPv4 uses a colon before the port and IPv6 uses a period
+	sep := ":"
+	if strings.Count(d, ":") > 1 {
+		sep = "."
+	}
+	idx := strings.LastIndex(d, sep)
+	if idx == -1 {
+		return "", 0, fmt.Errorf("%s does not include a port", destination)
+	}
+	ip := stripRouteDomain(d[:idx])
+	if net.ParseIP(ip) == nil {
+		return "", 0, fmt.Errorf("%s does not include a valid ip address", destination)
+	}
+	portStr := d[idx+1:]
+	if portStr == "any" || portStr == "0" {
+		return ip, 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("%s does not include a valid port", destination)
+	}
+	return ip, port, nil
+}
+
+// stripRouteDomain removes the route domain from an address (e.g., 10.0.0.1%1)
+func stripRouteDomain(address string) string {
+	return strings.Split(address, "%")[0]
+}
@@ -0,0 +1,123 @@
+package f5sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/vssync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func f5Sync() {
+
+	utils.LogStartCommand("f5-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Log in to the big-ip
+	if err := f5.login(f5User, f5Password, insecure); err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the virtual servers
+	virtuals, err := f5.getVirtuals()
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d virtual servers on %s", len(virtuals), f5.server), true)
+
+	partitionFilter := make(map[string]bool)
+	if partitions != "" {
+		for _, p := range strings.Split(partitions, ",") {
+			partitionFilter[strings.TrimSpace(p)] = true
+		}
+	}
+
+	virtualServices := []vssync.VirtualService{}
+	poolMembers := make(map[string][]vssync.Backend)
+	for _, v := range virtuals {
+		if len(partitionFilter) > 0 && !partitionFilter[v.Partition] {
+			continue
+		}
+		ip, port, err := parseDestination(v.Destination)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - %s. skipping.", v.FullPath, err), true)
+			continue
+		}
+
+		proto := 6
+		if strings.ToLower(v.IPProtocol) == "udp" {
+			proto = 17
+		}
+		servicePorts := []*illumioapi.ServicePort{{Port: port, Protocol: proto}}
+		if port == 0 {
+			utils.LogWarning(fmt.Sprintf("%s listens on any port. using 1-65535.", v.FullPath), true)
+			servicePorts = []*illumioapi.ServicePort{{Port: 1, ToPort: 65535, Protocol: proto}}
+		}
+
+		// Get the pool members
+		if _, ok := poolMembers[v.Pool]; !ok && v.Pool != "" && !noBindings {
+			members, err := f5.getPoolMembers(v.Pool)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("getting pool members of %s - %s", v.Pool, err), true)
+			}
+			for _, m := range members {
+				_, memberPort, err := parseDestination(m.Name)
+				if err != nil {
+					utils.LogWarning(fmt.Sprintf("%s pool member %s - %s", v.Pool, m.Name, err), true)
+					continue
+				}
+				poolMembers[v.Pool] = append(poolMembers[v.Pool], vssync.Backend{IP: stripRouteDomain(m.Address), Port: memberPort})
+			}
+		}
+
+		attributes := map[string]string{"f5:partition": v.Partition, "f5:name": v.Name, "f5:pool": v.Pool, "f5:description": v.Description}
+		virtualServices = append(virtualServices, vssync.VirtualService{
+			Name:                  strings.TrimPrefix(strings.ReplaceAll(v.FullPath, "/", "-"), "-"),
+			ExternalDataReference: v.FullPath,
+			IPs:                   []string{ip},
+			ServicePorts:          servicePorts,
+			Labels:                umwlsync.MapLabels(attributes, mapping),
+			Backends:              poolMembers[v.Pool],
+		})
+	}
+
+	if vipsAs == "umwl" {
+		workloads := []umwlsync.Workload{}
+		for _, vs := range virtualServices {
+			workloads = append(workloads, umwlsync.Workload{Hostname: vs.Name, Name: vs.Name, Interfaces: []string{"umwl0:" + vs.IPs[0]}, Description: "big-ip virtual server " + vs.ExternalDataReference, Labels: vs.Labels, ExternalDataReference: vs.ExternalDataReference})
+		}
+		umwlsync.Sync(umwlsync.Input{
+			PCE:             pce,
+			Command:         "f5-sync",
+			ExternalDataSet: externalDataSet,
+			Workloads:       workloads,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			OutputFileName:  outputFileName,
+		})
+	} else {
+		vssync.Sync(vssync.Input{
+			PCE:             pce,
+			Command:         "f5-sync",
+			ExternalDataSet: externalDataSet,
+			VirtualServices: virtualServices,
+			Cleanup:         cleanup,
+			Bind:            !noBindings,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+		})
+	}
+
+	utils.LogEndCommand("f5-sync")
+}
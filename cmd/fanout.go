@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/workloader/cmd/pcemgmt"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// fanoutFlags are removed from the arguments of the command run on each PCE. The bool indicates if the flag takes a value.
+var fanoutFlags = map[string]bool{"--all-pces": false, "--pce-group": true, "--pce-concurrency": true, "--no-prompt": false, "--output-template": true, "--result-file": true}
+
+// fanoutResult is the result of the command on a PCE
+type fanoutResult struct {
+	pce      string
+	exitCode int
+	duration time.Duration
+	files    []string
+	lastErr  string
+}
+
+// fanoutPCEs returns the PCEs from --all-pces or --pce-group. Groups are lists of PCE names in pce_groups in pce.yaml.
+func fanoutPCEs() ([]string, error) {
+	all := pcemgmt.GetAllPCENames()
+	sort.Strings(all)
+	if allPCEs {
+		return all, nil
+	}
+	groups := viper.GetStringMapStringSlice("pce_groups")
+	group, ok := groups[pceGroup]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in pce_groups in pce.yaml", pceGroup)
+	}
+	known := make(map[string]bool)
+	for _, p := range all {
+		known[p] = true
+	}
+	for _, p := range group {
+		if !known[p] {
+			return nil, fmt.Errorf("%s in pce group %s is not a configured pce", p, pceGroup)
+		}
+	}
+	return group, nil
+}
+
+// runAcrossPCEs runs the command on each PCE from --all-pces or --pce-group concurrently and returns the exit code. Output lines and logs
+// are prefixed with the PCE name, output files start with the PCE name, and a summary of the results is written when all PCEs finish.
+// The exit code is ExitPartial when the command fails on some PCEs and the highest exit code of the PCEs when it fails on all of them.
+func runAcrossPCEs(cmd *cobra.Command) int {
+	if allPCEs && pceGroup != "" {
+		utils.LogErrorCode(utils.ExitValidation, "--all-pces and --pce-group cannot be used together")
+	}
+	if targetPCE != "" {
+		utils.LogErrorCode(utils.ExitValidation, "--pce cannot be used with --all-pces or --pce-group")
+	}
+	pces, err := fanoutPCEs()
+	if err != nil {
+		utils.LogErrorCode(utils.ExitConfig, err.Error())
+	}
+	if len(pces) == 0 {
+		utils.LogErrorCode(utils.ExitConfig, "there are no pces to run the command on")
+	}
+	utils.LogStartCommand(cmd.Name())
+	utils.LogInfo(fmt.Sprintf("running %s on %d pces: %s", cmd.Name(), len(pces), strings.Join(pces, ", ")), true)
+
+	// Prompt once instead of on every PCE
+	if updatePCE && !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - Do you want to run %s with --update-pce on %d pces (%s) (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), cmd.Name(), len(pces), strings.Join(pces, ", "))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			utils.LogEndCommand(cmd.Name())
+			return 0
+		}
+	}
+
+	// Output file names start with the PCE name
+	template := outputTemplate
+	if template == "" {
+		template = utils.OutputTemplate()
+	}
+	if template == "" {
+		template = "workloader-{name}-{timestamp}"
+	}
+	if !strings.Contains(template, "{pce}") {
+		template = "{pce}-" + template
+	}
+
+	concurrency := pceConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := make([]fanoutResult, len(pces))
+	sem := make(chan struct{}, concurrency)
+	var out sync.Mutex
+	var wg sync.WaitGroup
+	for i, pce := range pces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pce string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runOnPCE(pce, fanoutArgs(pce, template), &out)
+		}(i, pce)
+	}
+	wg.Wait()
+
+	// Summary
+	exitCode := utils.ExitSuccess
+	data := [][]string{{"pce", "status", "exit_code", "duration", "output_files", "error"}}
+	for _, r := range results {
+		status := "success"
+		if r.exitCode != 0 {
+			status = "failed"
+			utils.RecordFailure(fmt.Sprintf("%s - %s", r.pce, r.lastErr))
+			if r.exitCode > exitCode {
+				exitCode = r.exitCode
+			}
+		}
+		data = append(data, []string{r.pce, status, strconv.Itoa(r.exitCode), r.duration.Round(time.Second).String(), strings.Join(r.files, ";"), r.lastErr})
+	}
+	utils.WriteOutput(data, data, fmt.Sprintf("workloader-%s-pce-summary-%s.csv", cmd.Name(), time.Now().Format("20060102_150405")))
+	utils.LogInfo(fmt.Sprintf("%s completed on %d of %d pces", cmd.Name(), len(pces)-failedCount(results), len(pces)), true)
+	utils.LogEndCommand(cmd.Name())
+	if failed := failedCount(results); failed > 0 && failed < len(pces) {
+		return utils.ExitPartial
+	}
+	return exitCode
+}
+
+// fanoutArgs returns the arguments of the command for a PCE. --output-file names are prefixed with the PCE name so PCEs do not write the same file.
+func fanoutArgs(pce, template string) []string {
+	args := []string{}
+	for i := 1; i < len(os.Args); i++ {
+		flag, value, hasValue := strings.Cut(os.Args[i], "=")
+		if takesValue, ok := fanoutFlags[flag]; ok {
+			if takesValue && !hasValue {
+				i++
+			}
+			continue
+		}
+		if flag == "--output-file" {
+			if !hasValue && i+1 < len(os.Args) {
+				i++
+				value = os.Args[i]
+			}
+			if value != "" {
+				value = filepath.Join(filepath.Dir(value), pce+"-"+filepath.Base(value))
+			}
+			args = append(args, "--output-file", value)
+			continue
+		}
+		args = append(args, os.Args[i])
+	}
+	args = append(args, "--pce", pce, "--output-template", template)
+	if updatePCE {
+		args = append(args, "--no-prompt")
+	}
+	return args
+}
+
+// runOnPCE runs the command for a PCE in the working directory with its log in a temporary directory. Output lines are printed with the
+// PCE name and the log is added to the log file with the PCE name when the command finishes.
+func runOnPCE(pce string, args []string, out *sync.Mutex) (result fanoutResult) {
+	result.pce = pce
+	start := time.Now()
+	defer func() { result.duration = time.Since(start) }()
+
+	dir, err := os.MkdirTemp("", "workloader-"+pce+"-")
+	if err != nil {
+		result.exitCode, result.lastErr = 1, err.Error()
+		return result
+	}
+	defer os.RemoveAll(dir)
+	c, cleanup, err := utils.WorkloaderCommand(context.Background(), dir, nil, args...)
+	if err != nil {
+		result.exitCode, result.lastErr = 1, err.Error()
+		return result
+	}
+	defer cleanup()
+	if c.Dir, err = os.Getwd(); err != nil {
+		result.exitCode, result.lastErr = 1, err.Error()
+		return result
+	}
+	c.Stdin = nil
+	c.Env = append(c.Env, utils.OutputEncryptionEnv()...)
+	pr, pw := io.Pipe()
+	c.Stdout, c.Stderr = pw, pw
+
+	// Print the output with the PCE name and capture output files and errors
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if _, file, ok := strings.Cut(line, "output file: "); ok {
+				result.files = append(result.files, strings.TrimSpace(file))
+			}
+			if strings.Contains(line, "[ERROR]") {
+				result.lastErr = strings.TrimSpace(line[strings.Index(line, "[ERROR]")+len("[ERROR]"):])
+				result.lastErr = strings.TrimSuffix(strings.TrimPrefix(result.lastErr, "- "), " see workloader.log for detailed information if error is from an illumio api call.")
+			}
+			out.Lock()
+			fmt.Printf("[%s] %s\r\n", pce, line)
+			out.Unlock()
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	runErr := c.Run()
+	pw.Close()
+	<-done
+
+	if runErr != nil {
+		result.exitCode = 1
+		if c.ProcessState != nil && c.ProcessState.ExitCode() > 0 {
+			result.exitCode = c.ProcessState.ExitCode()
+		}
+		if result.lastErr == "" {
+			result.lastErr = runErr.Error()
+		}
+	}
+
+	// Add the log with the PCE name
+	if f, err := os.Open(filepath.Join(dir, "workloader.log")); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+		out.Lock()
+		for scanner.Scan() {
+			fmt.Fprintf(utils.Logger.Writer(), "[%s] %s\n", pce, scanner.Text())
+		}
+		out.Unlock()
+		f.Close()
+	}
+	return result
+}
+
+// failedCount returns the number of PCEs where the command failed
+func failedCount(results []fanoutResult) int {
+	n := 0
+	for _, r := range results {
+		if r.exitCode != 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -61,7 +61,7 @@ The update-pce and --no-prompt flags are ignored for this command.`,
 		// Get csv file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
@@ -0,0 +1,70 @@
+package gcpsync
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var keyFile, projects, mappingFile, externalDataSet, outputFileName string
+var cleanup, allProjects, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	GCPSyncCmd.Flags().StringVarP(&keyFile, "key-file", "k", "", "service account json key file. default is the GOOGLE_APPLICATION_CREDENTIALS environment variable, then the metadata server (workload identity or attached service account).")
+	GCPSyncCmd.Flags().StringVar(&projects, "projects", "", "comma-separated list of project ids. default is the project of the credentials.")
+	GCPSyncCmd.Flags().BoolVar(&allProjects, "all-projects", false, "sync all active projects the credentials can access. requires the cloud resource manager api.")
+	GCPSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: gcp label and illumio label key. see help for pseudo labels.")
+	GCPSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "gcp-sync", "external data set used to identify unmanaged workloads managed by gcp-sync.")
+	GCPSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for instances that are stopped or no longer exist.")
+	GCPSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	GCPSyncCmd.Flags().SortFlags = false
+}
+
+// GCPSyncCmd runs the gcp-sync command
+var GCPSyncCmd = &cobra.Command{
+	Use:         "gcp-sync",
+	Short:       "Create, update, and delete unmanaged workloads for GCP compute engine instances.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete unmanaged workloads for GCP compute engine instances.
+
+Authentication uses a service account json key file (--key-file or GOOGLE_APPLICATION_CREDENTIALS). If neither is set, workloader gets a token from the metadata server, which supports workload identity and attached service accounts. The service account needs the Compute Viewer role on each project.
+
+Each unmanaged workload uses the instance id as the external data reference. The hostname is the custom hostname, then the instance name. The primary internal IP of each NIC is added as an interface.
+
+Only RUNNING instances are synced. With --cleanup (default), unmanaged workloads for stopped, suspended, and deleted instances are deleted.
+
+The mapping file is a csv with the gcp label in the first column and the illumio label key in the second column. A header of gcp_label,label_key is optional. In addition to gcp labels, the following pseudo labels can be used: gcp:project, gcp:zone, gcp:region, gcp:network, gcp:subnetwork.
+
+Example mapping file:
++-----------------------+-----------+
+|       gcp_label       | label_key |
++-----------------------+-----------+
+| app                   | app       |
+| environment           | env       |
+| gcp:region            | loc       |
++-----------------------+-----------+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if projects != "" && allProjects {
+			utils.LogError("--projects and --all-projects cannot be used together")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		gcpSync()
+	},
+}
@@ -0,0 +1,278 @@
+package gcpsync
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Scopes for service account tokens. gcp-sync only reads and label-writeback sets instance labels.
+const (
+	gcpScope      = "https://www.googleapis.com/auth/cloud-platform.read-only"
+	gcpWriteScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// gcpClient calls the Google Cloud APIs with a bearer token
+type gcpClient struct {
+	token          string
+	defaultProject string
+	client         *http.Client
+}
+
+// serviceAccountKey is the subset of the service account json key file used by gcp-sync
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+	PrivateKeyID string `json:"private_key_id"`
+}
+
+// gceInstance is the subset of a compute engine instance used by gcp-sync
+type gceInstance struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Hostname          string            `json:"hostname"`
+	Zone              string            `json:"zone"`
+	Status            string            `json:"status"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		Name          string `json:"name"`
+		NetworkIP     string `json:"networkIP"`
+		Ipv6Address   string `json:"ipv6Address"`
+		Network       string `json:"network"`
+		Subnetwork    string `json:"subnetwork"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+// newGCPClient gets a token for the scope from the service account key file or, if no key file is provided, from the metadata server (workload identity or attached service account).
+func newGCPClient(keyFile, scope string) (gcpClient, error) {
+	c := gcpClient{client: &http.Client{Timeout: 60 * time.Second}}
+
+	if keyFile == "" {
+		keyFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if keyFile == "" {
+		return c, c.metadataToken()
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return c, err
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return c, fmt.Errorf("parsing %s - %s", keyFile, err)
+	}
+	if key.Type != "service_account" {
+		return c, fmt.Errorf("%s is type %s. only service_account key files are supported", keyFile, key.Type)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	c.defaultProject = key.ProjectID
+
+	assertion, err := signJWT(key, scope)
+	if err != nil {
+		return c, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	resp, err := c.client.PostForm(key.TokenURI, form)
+	if err != nil {
+		return c, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return c, fmt.Errorf("getting gcp token - %d - %s", resp.StatusCode, string(body))
+	}
+	var t tokenResponse
+	if err := json.Unmarshal(body, &t); err != nil {
+		return c, err
+	}
+	c.token = t.AccessToken
+	return c, nil
+}
+
+// metadataToken gets a token for the attached service account from the metadata server
+func (c *gcpClient) metadataToken() error {
+	for _, target := range []struct {
+		path  string
+		value *string
+	}{{"instance/service-accounts/default/token", &c.token}, {"project/project-id", &c.defaultProject}} {
+		req, err := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/"+target.path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("no service account key file provided and metadata server is not reachable - %s", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("metadata server %s - %d - %s", target.path, resp.StatusCode, string(body))
+		}
+		if target.value == &c.token {
+			var t tokenResponse
+			if err := json.Unmarshal(body, &t); err != nil {
+				return err
+			}
+			c.token = t.AccessToken
+		} else {
+			*target.value = string(body)
+		}
+	}
+	return nil
+}
+
+// signJWT creates the signed assertion for the service account
+func signJWT(key serviceAccountKey, scope string) (string, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid private key in service account key file")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	rsaKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account private key is not rsa")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": key.ClientEmail, "scope": scope, "aud": key.TokenURI, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// get sends a GET request and unmarshals the response into v
+func (c gcpClient) get(reqURL string, v interface{}) error {
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s - %d - %s", strings.Split(reqURL, "?")[0], resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// listProjects returns the ids of all active projects the credentials can access
+func (c gcpClient) listProjects() ([]string, error) {
+	projects := []string{}
+	pageToken := ""
+	for {
+		var resp struct {
+			Projects []struct {
+				ProjectID string `json:"projectId"`
+			} `json:"projects"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		reqURL := "https://cloudresourcemanager.googleapis.com/v1/projects?filter=" + url.QueryEscape("lifecycleState:ACTIVE")
+		if pageToken != "" {
+			reqURL = reqURL + "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		if err := c.get(reqURL, &resp); err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Projects {
+			projects = append(projects, p.ProjectID)
+		}
+		if resp.NextPageToken == "" {
+			return projects, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// listInstances returns all instances in all zones of a project
+func (c gcpClient) listInstances(project string) ([]gceInstance, error) {
+	instances := []gceInstance{}
+	pageToken := ""
+	for {
+		var resp struct {
+			Items map[string]struct {
+				Instances []gceInstance `json:"instances"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		reqURL := fmt.Sprintf("https://compute.googleapis.com/compute/v1/projects/%s/aggregated/instances?maxResults=500", url.PathEscape(project))
+		if pageToken != "" {
+			reqURL = reqURL + "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		if err := c.get(reqURL, &resp); err != nil {
+			return nil, err
+		}
+		for _, scope := range resp.Items {
+			instances = append(instances, scope.Instances...)
+		}
+		if resp.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// zone returns the zone name from the zone url
+func (i gceInstance) zone() string {
+	return path.Base(i.Zone)
+}
+
+// region returns the region from the zone (e.g., us-central1-a is in us-central1)
+func (i gceInstance) region() string {
+	z := i.zone()
+	if idx := strings.LastIndex(z, "-"); idx > 0 {
+		return z[:idx]
+	}
+	return z
+}
@@ -0,0 +1,76 @@
+package gcpsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// LabelWriter sets labels on compute engine instances. It is used by label-writeback.
+type LabelWriter struct {
+	c gcpClient
+}
+
+// NewLabelWriter gets a token with write access from the service account key file or the metadata server.
+func NewLabelWriter(keyFile string) (*LabelWriter, error) {
+	c, err := newGCPClient(keyFile, gcpWriteScope)
+	if err != nil {
+		return nil, err
+	}
+	return &LabelWriter{c: c}, nil
+}
+
+// SetLabels merges the labels with the existing labels on an instance. Returns false if the instance already has the labels.
+func (l *LabelWriter) SetLabels(project, zone, instance string, labels map[string]string) (bool, error) {
+	instanceURL := fmt.Sprintf("https://compute.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s", url.PathEscape(project), url.PathEscape(zone), url.PathEscape(instance))
+
+	// The current label fingerprint is required to set labels
+	var current struct {
+		Labels           map[string]string `json:"labels"`
+		LabelFingerprint string            `json:"labelFingerprint"`
+	}
+	if err := l.c.get(instanceURL, &current); err != nil {
+		return false, err
+	}
+	merged := make(map[string]string)
+	for k, v := range current.Labels {
+		merged[k] = v
+	}
+	change := false
+	for k, v := range labels {
+		if merged[k] != v {
+			change = true
+		}
+		merged[k] = v
+	}
+	if !change {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"labels": merged, "labelFingerprint": current.LabelFingerprint})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest("POST", instanceURL+"/setLabels", bytes.NewBuffer(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+l.c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s/setLabels - %d - %s", instanceURL, resp.StatusCode, string(body))
+	}
+	return true, nil
+}
@@ -0,0 +1,123 @@
+package gcpsync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func gcpSync() {
+
+	utils.LogStartCommand("gcp-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	client, err := newGCPClient(keyFile, gcpScope)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the projects
+	projectIDs := []string{}
+	if allProjects {
+		projectIDs, err = client.listProjects()
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	} else if projects != "" {
+		projectIDs = strings.Split(strings.ReplaceAll(projects, " ", ""), ",")
+	} else if client.defaultProject != "" {
+		projectIDs = []string{client.defaultProject}
+	} else {
+		utils.LogError("no project found in the credentials. use --projects or --all-projects")
+	}
+	utils.LogInfo(fmt.Sprintf("syncing %d projects", len(projectIDs)), true)
+
+	workloads := []umwlsync.Workload{}
+	for _, project := range projectIDs {
+		instances, err := client.listInstances(project)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("project %s - %s", project, err))
+		}
+		utils.LogInfo(fmt.Sprintf("%d instances discovered in project %s", len(instances), project), true)
+		for _, i := range instances {
+			if i.Status != "RUNNING" {
+				utils.LogInfo(fmt.Sprintf("%s is %s. skipping.", i.Name, i.Status), false)
+				continue
+			}
+			workloads = append(workloads, instanceToWorkload(i, project, mapping))
+		}
+	}
+
+	umwlsync.Sync(umwlsync.Input{
+		PCE:             pce,
+		Command:         "gcp-sync",
+		ExternalDataSet: externalDataSet,
+		Workloads:       workloads,
+		Cleanup:         cleanup,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		OutputFileName:  outputFileName,
+	})
+
+	utils.LogEndCommand("gcp-sync")
+}
+
+// instanceToWorkload converts a compute engine instance to an unmanaged workload
+func instanceToWorkload(i gceInstance, project string, mapping map[string]string) umwlsync.Workload {
+
+	attributes := map[string]string{
+		"gcp:project": project,
+		"gcp:zone":    i.zone(),
+		"gcp:region":  i.region(),
+	}
+	if len(i.NetworkInterfaces) > 0 {
+		attributes["gcp:network"] = path.Base(i.NetworkInterfaces[0].Network)
+		attributes["gcp:subnetwork"] = path.Base(i.NetworkInterfaces[0].Subnetwork)
+	}
+	for k, v := range i.Labels {
+		attributes[k] = v
+	}
+
+	w := umwlsync.Workload{
+		Hostname:              i.Hostname,
+		Name:                  i.Name,
+		Description:           fmt.Sprintf("gcp instance %s in project %s", i.Name, project),
+		DataCenter:            i.zone(),
+		Labels:                umwlsync.MapLabels(attributes, mapping),
+		ExternalDataReference: i.ID,
+	}
+	if w.Hostname == "" {
+		w.Hostname = i.Name
+	}
+
+	for n, nic := range i.NetworkInterfaces {
+		name := nic.Name
+		if name == "" {
+			name = fmt.Sprintf("nic%d", n)
+		}
+		if nic.NetworkIP != "" {
+			w.Interfaces = append(w.Interfaces, fmt.Sprintf("%s:%s", name, nic.NetworkIP))
+		}
+		if nic.Ipv6Address != "" {
+			w.Interfaces = append(w.Interfaces, fmt.Sprintf("%s:%s", name, nic.Ipv6Address))
+		}
+		for _, ac := range nic.AccessConfigs {
+			if ac.NatIP != "" && w.PublicIP == "" {
+				w.PublicIP = ac.NatIP
+			}
+		}
+	}
+
+	return w
+}
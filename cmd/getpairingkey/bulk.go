@@ -0,0 +1,132 @@
+package getpairingkey
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// pkTarget is a host to create a pairing key for
+type pkTarget struct {
+	hostname string
+	os       string
+}
+
+// readHostFile returns a target for each row of the host file. The hostname header is required and the os header is optional.
+// Without headers, the first column is the hostname.
+func readHostFile() ([]pkTarget, error) {
+	data, err := utils.ParseCSV(hostFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", hostFile)
+	}
+	hostCol, osCol := 0, -1
+	headers := false
+	for i, h := range data[0] {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "hostname", "host", "name":
+			hostCol, headers = i, true
+		case "os", "os_type", "platform":
+			osCol, headers = i, true
+		}
+	}
+	if headers {
+		data = data[1:]
+	}
+	targets := []pkTarget{}
+	for _, row := range data {
+		t := pkTarget{hostname: strings.TrimSpace(row[hostCol]), os: installOS}
+		if osCol != -1 && osCol < len(row) && strings.TrimSpace(row[osCol]) != "" {
+			t.os = strings.TrimSpace(row[osCol])
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// installCommand returns the pairing script command for the os. Windows uses pair.ps1 and all others use pair.sh.
+// The management server is the pce fqdn and port in pce.yaml or the environment variables.
+func installCommand(pp illumioapi.PairingProfile, osType, activationCode string) string {
+	server := fmt.Sprintf("%s:%d", utils.PCEFQDN(pce.FriendlyName), utils.PCEPort(pce.FriendlyName))
+	profileID := path.Base(pp.Href)
+	if strings.Contains(strings.ToLower(osType), "win") {
+		return fmt.Sprintf(`PowerShell -Command "& {Set-ExecutionPolicy -Scope process remotesigned -Force; Start-Sleep -s 3; [System.Net.ServicePointManager]::SecurityProtocol=[Enum]::ToObject([System.Net.SecurityProtocolType], 3072); (New-Object System.Net.WebClient).DownloadFile('https://%s/api/v22/software/ven/image?pair_script=pair.ps1&profile_id=%s', (echo $env:windir\temp\pair.ps1)); & $env:windir\temp\pair.ps1 -management-server %s -activation-code %s;}"`, server, profileID, server, activationCode)
+	}
+	return fmt.Sprintf(`rm -fr /opt/illumio_ven_data/tmp && umask 026 && mkdir -p /opt/illumio_ven_data/tmp && curl --tlsv1.2 "https://%s/api/v22/software/ven/image?pair_script=pair.sh&profile_id=%s" -o /opt/illumio_ven_data/tmp/pair.sh && chmod +x /opt/illumio_ven_data/tmp/pair.sh && /opt/illumio_ven_data/tmp/pair.sh --management-server %s --activation-code %s`, server, profileID, server, activationCode)
+}
+
+// bulkPK creates a pairing key for each target and writes a csv of the targets and keys
+func bulkPK(pp illumioapi.PairingProfile) {
+
+	// Build the targets from the host file or the count
+	targets := []pkTarget{}
+	if hostFile != "" {
+		if targets, err = readHostFile(); err != nil {
+			utils.LogError(err.Error())
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			targets = append(targets, pkTarget{os: installOS})
+		}
+	}
+
+	// Create the keys
+	headers := []string{"hostname", "pairing_profile", "activation_code", "error"}
+	if installCommands {
+		headers = append(headers, "os", "install_command")
+	}
+	data := [][]string{headers}
+	keys := []string{}
+	progress := utils.NewProgress("creating pairing keys", len(targets))
+	for _, t := range targets {
+		pk, a, err := pce.CreatePairingKey(pp)
+		utils.LogAPIResp("CreatePairingKey", a)
+		if err == nil && pk.ActivationCode == "" {
+			err = fmt.Errorf("status code %d without an activation code", a.StatusCode)
+		}
+		progress.Increment()
+		row := []string{t.hostname, pp.Name, pk.ActivationCode, ""}
+		if err != nil {
+			row[3] = err.Error()
+			utils.RecordFailure(fmt.Sprintf("creating pairing key for %s - %s", t.hostname, err))
+		} else {
+			keys = append(keys, pk.ActivationCode)
+		}
+		if installCommands {
+			command := ""
+			if err == nil {
+				command = installCommand(pp, t.os, pk.ActivationCode)
+			}
+			row = append(row, t.os, command)
+		}
+		data = append(data, row)
+	}
+	progress.Done()
+	utils.LogInfo(fmt.Sprintf("created %d of %d pairing keys with %s", len(keys), len(targets), pp.Name), true)
+
+	// Write the output
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-get-pk-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	// Write the keys to the file one per line
+	if pkFile != "" {
+		file, err := os.Create(pkFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		defer file.Close()
+		if _, err = io.WriteString(file, strings.Join(keys, "\n")+"\n"); err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+}
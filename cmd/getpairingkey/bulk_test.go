@@ -0,0 +1,27 @@
+package getpairingkey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+func TestInstallCommandUsesConfiguredServer(t *testing.T) {
+	viper.Set("pk-test.fqdn", "pce.example.com")
+	viper.Set("pk-test.port", 8443)
+	t.Cleanup(func() { viper.Set("pk-test", nil) })
+	pce = illumioapi.PCE{FriendlyName: "pk-test", FQDN: "127.0.0.1", Port: 40000}
+	pp := illumioapi.PairingProfile{Href: "/orgs/1/pairing_profiles/7"}
+
+	for _, osType := range []string{"linux", "windows"} {
+		cmd := installCommand(pp, osType, "code")
+		if !strings.Contains(cmd, "https://pce.example.com:8443/api/v22/software/ven/image") || strings.Contains(cmd, "127.0.0.1") {
+			t.Errorf("%s command does not use the configured pce - %s", osType, cmd)
+		}
+		if !strings.Contains(cmd, "profile_id=7") || !strings.Contains(cmd, "management-server pce.example.com:8443") {
+			t.Errorf("%s command is missing the profile or management server - %s", osType, cmd)
+		}
+	}
+}
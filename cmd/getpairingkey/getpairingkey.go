@@ -14,12 +14,19 @@ import (
 // Declare local global variables
 var pce illumioapi.PCE
 var err error
-var profile, pkFile string
+var profile, pkFile, hostFile, installOS, outputFileName string
+var count int
+var installCommands bool
 
 // Init handles flags
 func init() {
 	GetPairingKey.Flags().StringVarP(&profile, "profile", "p", "default", "Pairing profile name.")
-	GetPairingKey.Flags().StringVarP(&pkFile, "file", "f", "", "File to store pairing key")
+	GetPairingKey.Flags().StringVarP(&pkFile, "file", "f", "", "File to store pairing key. With --count or --host-file, the keys are stored one per line.")
+	GetPairingKey.Flags().IntVarP(&count, "count", "n", 1, "Number of pairing keys to create. More than 1 writes a csv of the keys.")
+	GetPairingKey.Flags().StringVar(&hostFile, "host-file", "", "csv file with a hostname header and an optional os header. one key is created for each row and written to a csv.")
+	GetPairingKey.Flags().BoolVar(&installCommands, "install-commands", false, "write a csv with the pairing script install command for each key.")
+	GetPairingKey.Flags().StringVar(&installOS, "os", "linux", "os for install commands of keys without an os in the host file. windows uses the powershell script and all others use the shell script.")
+	GetPairingKey.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 	GetPairingKey.Flags().SortFlags = false
 }
 
@@ -30,6 +37,12 @@ var GetPairingKey = &cobra.Command{
 	Long: `
 Gets a pairing key. The default pairing profile is used unless a profile name is specified with --profile (-p).
 
+Use --count (-n) to create multiple keys or --host-file to create one key for each host in a csv. The host file has a hostname header and an optional os header. Both write a csv of the hostname, pairing profile, and activation code of each key. Add --install-commands to include the pairing script command for each key. Rows without an os use --os.
+
+Examples:
+  workloader get-pk --profile linux-prod --count 25
+  workloader get-pk --profile linux-prod --host-file new-hosts.csv --install-commands
+
 The update-pce and --no-prompt flags are ignored for this command.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
@@ -39,6 +52,13 @@ The update-pce and --no-prompt flags are ignored for this command.`,
 			utils.LogError(err.Error())
 		}
 
+		if count < 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--count must be at least 1")
+		}
+		if hostFile != "" && count != 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--count cannot be used with --host-file")
+		}
+
 		getPK()
 	},
 }
@@ -56,6 +76,11 @@ func getPK() {
 	}
 
 	for _, pp := range pps {
+		if pp.Name == profile && (count > 1 || hostFile != "" || installCommands) {
+			bulkPK(pp)
+			utils.LogEndCommand("get-pk")
+			return
+		}
 		if pp.Name == profile {
 			pk, a, err := pce.CreatePairingKey(pp)
 			utils.LogAPIResp("CreatePairingKey", a)
@@ -78,6 +103,9 @@ func getPK() {
 			}
 		}
 	}
+	if count > 1 || hostFile != "" || installCommands {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s does not exist as a pairing profile", profile))
+	}
 	utils.LogEndCommand("get-pk")
 
 }
@@ -40,8 +40,9 @@ func init() {
 
 // HostnameCmd runs the hostname parser
 var HostnameCmd = &cobra.Command{
-	Use:   "hostparse [parser file csv]",
-	Short: "Label workloads by parsing hostnames from provided regex functions.",
+	Use:         "hostparse [parser file csv]",
+	Short:       "Label workloads by parsing hostnames from provided regex functions.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Label workloads by parsing hostnames.
 
@@ -74,7 +75,7 @@ An input CSV specifics the regex functions to use to assign labels. An example i
 		// Get CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		parserFile = args[0]
 
@@ -488,7 +489,7 @@ func hostnameParser() {
 		if noPrompt {
 			response = "yes"
 		} else if updatePCE {
-			fmt.Printf("Do you want to update Workloads and potentially create new labels in %s (%s) (yes/no)? ", pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+			fmt.Printf("Do you want to update Workloads and potentially create new labels in %s (%s) (yes/no)? ", pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 			fmt.Scanln(&response)
 		} else {
 			fmt.Println("List of ALL Regex Matched Hostnames even if no Workload exist on the PCE. ")
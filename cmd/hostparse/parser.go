@@ -0,0 +1,67 @@
+package hostparse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// Parser labels hostnames using the regex rules of a hostparse parser file so other commands can derive labels from hostnames.
+type Parser struct {
+	rules      []regexstruct
+	compiled   []*regexp.Regexp
+	capitalize int
+}
+
+// NewParser loads a parser file in the hostparse format. capitalize follows the hostparse --capitalize flag (0 as is, 1 upper, 2 lower).
+func NewParser(file string, capitalize int) (*Parser, error) {
+	data, err := utils.ParseCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parser{capitalize: capitalize}
+	for i, row := range data {
+		if i == 0 || len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		for len(row) < 5 {
+			row = append(row, "")
+		}
+		re, err := regexp.Compile(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d - %s", file, i+1, err)
+		}
+		p.rules = append(p.rules, regexstruct{regex: row[0], labelcg: map[string]string{"role": row[1], "app": row[2], "env": row[3], "loc": row[4]}})
+		p.compiled = append(p.compiled, re)
+	}
+	return p, nil
+}
+
+// Labels returns the label key to value map from the first regex that matches the hostname.
+func (p *Parser) Labels(hostname string) (map[string]string, bool) {
+	for i, re := range p.compiled {
+		if !re.MatchString(hostname) {
+			continue
+		}
+		labels := make(map[string]string)
+		for key, replace := range p.rules[i].labelcg {
+			if replace == "" {
+				continue
+			}
+			value := strings.TrimSpace(re.ReplaceAllString(hostname, replace))
+			switch p.capitalize {
+			case 1:
+				value = strings.ToUpper(value)
+			case 2:
+				value = strings.ToLower(value)
+			}
+			if value != "" {
+				labels[key] = value
+			}
+		}
+		return labels, true
+	}
+	return nil, false
+}
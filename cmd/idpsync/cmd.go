@@ -0,0 +1,95 @@
+package idpsync
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var source, ldapServer, bindUser, bindPassword, baseDNs, ldapFilter, oktaURL, oktaToken, oktaSearch, groupFilterStr, outputFileName string
+var insecure, startTLS, cleanup, updatePCE, noPrompt bool
+var groupFilter *regexp.Regexp
+var err error
+
+func init() {
+	IdPSyncCmd.Flags().StringVar(&source, "source", "ldap", "identity provider: ldap or okta.")
+	IdPSyncCmd.Flags().StringVarP(&ldapServer, "ldap-server", "s", "", "ldap url of a domain controller (e.g., ldaps://dc1.corp.local:636).")
+	IdPSyncCmd.Flags().StringVarP(&bindUser, "bind-user", "u", "", "ldap bind user. default is the AD_BIND_USER environment variable.")
+	IdPSyncCmd.Flags().StringVarP(&bindPassword, "bind-pwd", "p", "", "ldap bind password. default is the AD_BIND_PASSWORD environment variable.")
+	IdPSyncCmd.Flags().BoolVar(&startTLS, "start-tls", false, "upgrade an ldap:// connection with starttls.")
+	IdPSyncCmd.Flags().StringVarP(&baseDNs, "ous", "o", "", "semicolon-separated list of ou distinguished names to search for groups.")
+	IdPSyncCmd.Flags().StringVarP(&ldapFilter, "ldap-filter", "f", "(&(objectClass=group)(groupType:1.2.840.113556.1.4.803:=2147483648))", "ldap filter. default is security groups.")
+	IdPSyncCmd.Flags().StringVar(&oktaURL, "okta-url", "", "okta org url (e.g., https://company.okta.com).")
+	IdPSyncCmd.Flags().StringVar(&oktaToken, "okta-token", "", "okta api token. default is the OKTA_API_TOKEN environment variable.")
+	IdPSyncCmd.Flags().StringVar(&oktaSearch, "okta-search", "type eq \"APP_GROUP\"", "okta group search expression. default is groups imported from an app such as active directory.")
+	IdPSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the ldap or okta certificate.")
+	IdPSyncCmd.Flags().StringVar(&groupFilterStr, "group-filter", "", "regular expression to limit the groups by name (e.g., \"^seg-\").")
+	IdPSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete pce user groups that are no longer in the identity provider. only groups in the same domains as the synced groups are deleted.")
+	IdPSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	IdPSyncCmd.Flags().SortFlags = false
+}
+
+// IdPSyncCmd runs the idp-sync command
+var IdPSyncCmd = &cobra.Command{
+	Use:         "idp-sync",
+	Short:       "Create, update, and delete PCE user groups from LDAP or Okta groups.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete PCE user groups from LDAP or Okta groups.
+
+PCE user groups are used in rules for adaptive user segmentation. The VEN evaluates membership using the group SID so groups without a SID are skipped. With --source okta, only groups imported from Active Directory have a SID (objectSid in the group profile).
+
+Groups are matched to PCE user groups by SID. New groups are created. Groups with a different name or description are updated.
+
+With --cleanup (default), PCE user groups that are not in the identity provider are deleted. Only groups with a SID in the same domain as a synced group are deleted so groups from other domains or added manually for other domains are not removed. Groups used in a ruleset cannot be deleted and are logged.
+
+The output file has the groups, the member count (ldap only), and the action.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		switch source {
+		case "ldap":
+			if ldapServer == "" || baseDNs == "" {
+				utils.LogError("--ldap-server and --ous are required for ldap")
+			}
+			if bindUser == "" {
+				bindUser = os.Getenv("AD_BIND_USER")
+			}
+			if bindPassword == "" {
+				bindPassword = os.Getenv("AD_BIND_PASSWORD")
+			}
+		case "okta":
+			if oktaURL == "" {
+				utils.LogError("--okta-url is required for okta")
+			}
+			if oktaToken == "" {
+				oktaToken = os.Getenv("OKTA_API_TOKEN")
+			}
+		default:
+			utils.LogError("--source must be ldap or okta")
+		}
+		if groupFilterStr != "" {
+			if groupFilter, err = regexp.Compile(groupFilterStr); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		idpSync()
+	},
+}
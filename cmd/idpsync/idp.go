@@ -0,0 +1,143 @@
+package idpsync
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// idpGroup is a group from the identity provider
+type idpGroup struct {
+	name        string
+	sid         string
+	description string
+	members     int // -1 when the source does not return membership
+}
+
+// ldapGroups binds to the directory and returns the groups under each base dn
+func ldapGroups(baseDNs []string) ([]idpGroup, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	conn, err := ldap.DialURL(ldapServer, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if startTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Bind(bindUser, bindPassword); err != nil {
+		return nil, err
+	}
+
+	groups := []idpGroup{}
+	for _, baseDN := range baseDNs {
+		req := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, ldapFilter, []string{"cn", "sAMAccountName", "objectSid", "description", "member"}, nil)
+		result, err := conn.SearchWithPaging(req, 500)
+		if err != nil {
+			return nil, fmt.Errorf("searching %s - %s", baseDN, err)
+		}
+		for _, e := range result.Entries {
+			g := idpGroup{name: e.GetAttributeValue("sAMAccountName"), sid: sidString(e.GetRawAttributeValue("objectSid")), description: e.GetAttributeValue("description"), members: len(e.GetAttributeValues("member"))}
+			if g.name == "" {
+				g.name = e.GetAttributeValue("cn")
+			}
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+// sidString converts a binary objectSid to the string format (e.g., S-1-5-21-1004336348-1177238915-682003330-512)
+func sidString(b []byte) string {
+	if len(b) < 8 || len(b) < 8+4*int(b[1]) {
+		return ""
+	}
+	var authority uint64
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+	sid := fmt.Sprintf("S-%d-%d", b[0], authority)
+	for i := 0; i < int(b[1]); i++ {
+		sid = fmt.Sprintf("%s-%d", sid, binary.LittleEndian.Uint32(b[8+4*i:]))
+	}
+	return sid
+}
+
+// oktaGroups returns the okta groups matching the search expression.
+// Groups imported from active directory include the objectSid in the profile.
+func oktaGroups(search string) ([]idpGroup, error) {
+	client := &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}
+	q := url.Values{"limit": {"200"}}
+	if search != "" {
+		q.Set("search", search)
+	}
+	next := fmt.Sprintf("%s/api/v1/groups?%s", strings.TrimSuffix(oktaURL, "/"), q.Encode())
+	nextLink := regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+	groups := []idpGroup{}
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "SSWS "+oktaToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil, fmt.Errorf("okta get groups - %d - %s", resp.StatusCode, string(body))
+		}
+		var page []struct {
+			Profile struct {
+				Name                       string `json:"name"`
+				Description                string `json:"description"`
+				ObjectSid                  string `json:"objectSid"`
+				WindowsDomainQualifiedName string `json:"windowsDomainQualifiedName"`
+			} `json:"profile"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for _, g := range page {
+			name := g.Profile.WindowsDomainQualifiedName
+			if name == "" {
+				name = g.Profile.Name
+			}
+			groups = append(groups, idpGroup{name: name, sid: g.Profile.ObjectSid, description: g.Profile.Description, members: -1})
+		}
+
+		next = ""
+		for _, l := range resp.Header.Values("Link") {
+			if m := nextLink.FindStringSubmatch(l); m != nil {
+				next = m[1]
+			}
+		}
+	}
+	return groups, nil
+}
+
+// domainSID returns the domain portion of a sid (e.g., S-1-5-21-1004336348-1177238915-682003330)
+func domainSID(sid string) string {
+	if !strings.HasPrefix(sid, "S-1-5-21-") {
+		return ""
+	}
+	return sid[:strings.LastIndex(sid, "-")]
+}
@@ -0,0 +1,184 @@
+package idpsync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// userGroup is a PCE user group (security principal) with the description
+type userGroup struct {
+	Href        string `json:"href,omitempty"`
+	Name        string `json:"name,omitempty"`
+	SID         string `json:"sid,omitempty"`
+	Description string `json:"description"`
+}
+
+func idpSync() {
+
+	utils.LogStartCommand("idp-sync")
+
+	// Get the identity provider groups
+	var groups []idpGroup
+	if source == "ldap" {
+		ous := []string{}
+		for _, ou := range strings.Split(baseDNs, ";") {
+			if strings.TrimSpace(ou) != "" {
+				ous = append(ous, strings.TrimSpace(ou))
+			}
+		}
+		groups, err = ldapGroups(ous)
+	} else {
+		groups, err = oktaGroups(oktaSearch)
+	}
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d groups in %s", len(groups), source), true)
+
+	// Filter and index by sid
+	idpMap := make(map[string]idpGroup)
+	domains := make(map[string]bool)
+	for _, g := range groups {
+		if groupFilter != nil && !groupFilter.MatchString(g.name) {
+			continue
+		}
+		if g.sid == "" {
+			utils.LogInfo(fmt.Sprintf("%s does not have a sid. skipping.", g.name), false)
+			continue
+		}
+		idpMap[g.sid] = g
+		domains[domainSID(g.sid)] = true
+	}
+	utils.LogInfo(fmt.Sprintf("%d groups with a sid match the filter", len(idpMap)), true)
+
+	// Get the pce user groups. The illumioapi type does not include the description.
+	pceGroups := []userGroup{}
+	api, err := pce.GetCollection("security_principals", false, nil, &pceGroups)
+	utils.LogAPIResp("GetSecurityPrincipals", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	pceMap := make(map[string]userGroup)
+	for _, g := range pceGroups {
+		pceMap[g.SID] = g
+	}
+	usedGroups := make(map[string]bool)
+	if cleanup {
+		used, api, err := pce.GetADUserGroups(nil)
+		utils.LogAPIResp("GetADUserGroups", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, u := range used {
+			usedGroups[u.Href] = u.UsedByRuleSet
+		}
+	}
+
+	// Compare
+	creates, updates, deletes := []userGroup{}, []userGroup{}, []userGroup{}
+	data := [][]string{{"name", "sid", "description", "members", "href", "action"}}
+	sids := []string{}
+	for sid := range idpMap {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+	for _, sid := range sids {
+		g := idpMap[sid]
+		members := ""
+		if g.members >= 0 {
+			members = strconv.Itoa(g.members)
+		}
+		existing, ok := pceMap[sid]
+		switch {
+		case !ok:
+			creates = append(creates, userGroup{Name: g.name, SID: g.sid, Description: g.description})
+			data = append(data, []string{g.name, g.sid, g.description, members, "", "create"})
+			utils.LogInfo(fmt.Sprintf("create - %s - %s", g.name, g.sid), false)
+		case existing.Name != g.name || existing.Description != g.description:
+			updates = append(updates, userGroup{Href: existing.Href, Name: g.name, Description: g.description})
+			data = append(data, []string{g.name, g.sid, g.description, members, existing.Href, "update"})
+			utils.LogInfo(fmt.Sprintf("update - %s - %s - name %s to %s - description %s to %s", existing.Href, g.sid, existing.Name, g.name, existing.Description, g.description), false)
+		default:
+			data = append(data, []string{g.name, g.sid, g.description, members, existing.Href, "none"})
+		}
+	}
+	if cleanup {
+		for _, g := range pceGroups {
+			if _, ok := idpMap[g.SID]; ok || !domains[domainSID(g.SID)] {
+				continue
+			}
+			if usedGroups[g.Href] {
+				utils.LogWarning(fmt.Sprintf("%s - %s is not in %s but is used in a ruleset. skipping delete.", g.Name, g.SID, source), true)
+				data = append(data, []string{g.Name, g.SID, g.Description, "", g.Href, "delete-skipped-in-use"})
+				continue
+			}
+			deletes = append(deletes, g)
+			data = append(data, []string{g.Name, g.SID, g.Description, "", g.Href, "delete"})
+			utils.LogInfo(fmt.Sprintf("delete - %s - %s - %s", g.Href, g.Name, g.SID), false)
+		}
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-idp-sync-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	if len(creates)+len(updates)+len(deletes) == 0 {
+		utils.LogInfo("pce user groups match the identity provider. nothing to be done.", true)
+		utils.LogEndCommand("idp-sync")
+		return
+	}
+	utils.LogInfo(fmt.Sprintf("workloader will create %d, update %d, and delete %d user groups.", len(creates), len(updates), len(deletes)), true)
+	if !updatePCE {
+		utils.LogInfo("see workloader.log and the output file for more details. to do the sync, run again using --update-pce flag.", true)
+		utils.LogEndCommand("idp-sync")
+		return
+	}
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will create %d, update %d, and delete %d user groups in %s (%s). do you want to run the sync (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(creates), len(updates), len(deletes), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			utils.LogEndCommand("idp-sync")
+			return
+		}
+	}
+
+	for _, g := range creates {
+		var created userGroup
+		api, err := pce.Post("security_principals", &g, &created)
+		utils.LogAPIResp("CreateSecurityPrincipal", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("creating %s - %s - %s", g.Name, g.SID, err), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("created %s - %s - %s", created.Href, g.Name, g.SID), true)
+	}
+	for _, g := range updates {
+		href := g.Href
+		api, err := pce.Put(&g)
+		utils.LogAPIResp("UpdateSecurityPrincipal", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("updating %s - %s", href, err), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("updated %s - %s", href, g.Name), true)
+	}
+	for _, g := range deletes {
+		api, err := pce.DeleteHref(g.Href)
+		utils.LogAPIResp("DeleteHref", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("deleting %s - %s", g.Href, err), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("deleted %s - %s", g.Href, g.Name), true)
+	}
+
+	utils.LogEndCommand("idp-sync")
+}
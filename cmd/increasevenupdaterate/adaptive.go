@@ -0,0 +1,121 @@
+package increasevenupdaterate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// pceCluster is a cluster in the health api response
+type pceCluster struct {
+	FQDN   string `json:"fqdn"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// pceHealth returns the status of the pce health api and its latency. The status is the first cluster that is not normal.
+func pceHealth() (status string, latency time.Duration) {
+	var clusters []pceCluster
+	start := time.Now()
+	api, err := pce.GetHref("/health", &clusters)
+	latency = time.Since(start)
+	utils.LogAPIResp("GetHealth", api)
+	if err != nil {
+		return fmt.Sprintf("error - %s", err), latency
+	}
+	if api.StatusCode != 200 {
+		return fmt.Sprintf("status code %d", api.StatusCode), latency
+	}
+	for _, c := range clusters {
+		if c.Status != "normal" {
+			return fmt.Sprintf("%s %s", c.FQDN, c.Status), latency
+		}
+	}
+	return "normal", latency
+}
+
+// adaptiveIncrease increases the update rate of the workloads in steps. Each step checks pce health and api latency first.
+// The step grows by --step-vens while the pce is healthy and is halved with a --backoff wait when the pce is degraded.
+// It returns the step report rows and true if it stopped after --max-backoffs.
+func adaptiveIncrease(wklds []illumioapi.Workload, iteration int) ([][]string, bool) {
+	data := [][]string{}
+	remaining := wklds
+	step := startVENs
+	backoffs := 0
+	for stepNum := 1; len(remaining) > 0; stepNum++ {
+
+		// Back off if the pce is degraded
+		status, latency := pceHealth()
+		if status != "normal" || latency > maxLatency {
+			backoffs++
+			if step = step / 2; step < 50 {
+				step = 50
+			}
+			utils.LogWarning(fmt.Sprintf("step %d - pce health is %s with %s latency. backing off to %d vens per step and waiting %s.", stepNum, status, latency.Round(time.Millisecond), step, backoff), true)
+			data = append(data, []string{strconv.Itoa(iteration), strconv.Itoa(stepNum), time.Now().Format(time.RFC3339), "0", strconv.Itoa(len(wklds) - len(remaining)), status, strconv.FormatInt(latency.Milliseconds(), 10), "backoff"})
+			if backoffs >= maxBackoffs {
+				utils.RecordFailure(fmt.Sprintf("stopped after %d backoffs in a row with %d of %d workloads increased", backoffs, len(wklds)-len(remaining), len(wklds)))
+				return data, true
+			}
+			time.Sleep(backoff)
+			continue
+		}
+		backoffs = 0
+
+		// Increase the rate for the step in calls of 50 vens
+		batch := remaining
+		if step < len(batch) {
+			batch = batch[:step]
+		}
+		for i := 0; i < len(batch); i += 50 {
+			end := i + 50
+			if end > len(batch) {
+				end = len(batch)
+			}
+			start := time.Now()
+			a, err := pce.IncreaseTrafficUpdateRate(batch[i:end])
+			utils.LogAPIResp("IncreaseTrafficUpdateRate", a)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			if callLatency := time.Since(start); callLatency > latency {
+				latency = callLatency
+			}
+		}
+		remaining = remaining[len(batch):]
+		utils.LogInfo(fmt.Sprintf("step %d - increased ven update rate for %d workloads (%d of %d). pce health is %s with %s latency.", stepNum, len(batch), len(wklds)-len(remaining), len(wklds), status, latency.Round(time.Millisecond)), true)
+
+		// Step up if the calls were under the max latency
+		action := "hold"
+		if latency <= maxLatency && step < maxVENs {
+			if step += stepVENs; step > maxVENs {
+				step = maxVENs
+			}
+			action = "increase"
+		}
+		data = append(data, []string{strconv.Itoa(iteration), strconv.Itoa(stepNum), time.Now().Format(time.RFC3339), strconv.Itoa(len(batch)), strconv.Itoa(len(wklds) - len(remaining)), status, strconv.FormatInt(latency.Milliseconds(), 10), action})
+
+		if len(remaining) > 0 {
+			time.Sleep(stepInterval)
+		}
+	}
+	return data, false
+}
+
+// validateAdaptive validates the adaptive flags
+func validateAdaptive() {
+	errs := []string{}
+	if startVENs < 1 || stepVENs < 0 || maxVENs < startVENs {
+		errs = append(errs, "--start-vens must be at least 1, --step-vens cannot be negative, and --max-vens cannot be less than --start-vens")
+	}
+	if maxLatency <= 0 || maxBackoffs < 1 {
+		errs = append(errs, "--max-latency and --max-backoffs must be greater than 0")
+	}
+	if len(errs) > 0 {
+		utils.LogErrorCode(utils.ExitValidation, strings.Join(errs, "; "))
+	}
+}
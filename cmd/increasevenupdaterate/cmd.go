@@ -14,10 +14,11 @@ import (
 )
 
 var role, app, env, loc string
-var forMinutes int
+var forMinutes, startVENs, stepVENs, maxVENs, maxBackoffs int
+var stepInterval, maxLatency, backoff time.Duration
 var pce illumioapi.PCE
 var err error
-var updatePCE, noPrompt bool
+var updatePCE, noPrompt, adaptive bool
 
 func init() {
 	IncreaseVENUpdateRateCmd.Flags().StringVarP(&role, "role", "r", "", "Role Label. Blank means all roles.")
@@ -25,25 +26,40 @@ func init() {
 	IncreaseVENUpdateRateCmd.Flags().StringVarP(&env, "env", "e", "", "Environment Label. Blank means all environments.")
 	IncreaseVENUpdateRateCmd.Flags().StringVarP(&loc, "loc", "l", "", "Location Label. Blank means all locations.")
 	IncreaseVENUpdateRateCmd.Flags().IntVarP(&forMinutes, "for-minutes", "f", 0, "Minutes to issue increase command every 10 minutes (e.g., 60 will run the process for 60 minutes with the command running 6 total times.")
+	IncreaseVENUpdateRateCmd.Flags().BoolVar(&adaptive, "adaptive", false, "Increase the rate in steps that grow while the PCE is healthy and back off when health or API latency degrades.")
+	IncreaseVENUpdateRateCmd.Flags().IntVar(&startVENs, "start-vens", 50, "VENs in the first step with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().IntVar(&stepVENs, "step-vens", 50, "VENs added to each step while the PCE is healthy with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().IntVar(&maxVENs, "max-vens", 1000, "Maximum VENs in a step with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().DurationVar(&stepInterval, "step-interval", 30*time.Second, "Time between steps with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().DurationVar(&maxLatency, "max-latency", 2*time.Second, "API latency that causes a backoff with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().DurationVar(&backoff, "backoff", 2*time.Minute, "Time to wait after a backoff with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().IntVar(&maxBackoffs, "max-backoffs", 10, "Backoffs in a row before stopping with --adaptive.")
+	IncreaseVENUpdateRateCmd.Flags().SortFlags = false
 
 }
 
 // IncreaseVENUpdateRateCmd runs the workload identifier
 var IncreaseVENUpdateRateCmd = &cobra.Command{
-	Use:   "increase-ven-rate",
-	Short: "Increase the VEN update rate to every 30 seconds for a period of 10 minutes.",
+	Use:         "increase-ven-rate",
+	Short:       "Increase the VEN update rate to every 30 seconds for a period of 10 minutes.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true"},
 	Long: `
 Increase the VEN update rate to every 30 seconds for a period of 10 minutes.
 
 Use the role, app, env, and loc labels to specify workloads. One label can be provided for each key and they are combined with the "AND" operator.
 
-The forMinutes flag can be used to have workloader run the command every 10 minutes for the specified forMinutes value. You'll need to keep your shell open (or run in the background).`,
+The forMinutes flag can be used to have workloader run the command every 10 minutes for the specified forMinutes value. You'll need to keep your shell open (or run in the background).
+
+The --adaptive flag increases the rate in steps instead of all workloads at once. Before each step, workloader checks the PCE health API and its latency. While the PCE is normal and the latency of the health and increase calls is under --max-latency, each step adds --step-vens up to --max-vens. When the PCE is not normal or latency is over --max-latency, the step size is halved and workloader waits --backoff before checking again. After --max-backoffs backoffs in a row, the command stops and exits with 3. A csv with each step's VENs, health, latency, and action is written at the end.`,
 
 	Example: `# Increase frequency for all workloads in the CRM (app) PROD (env) app group for the default 10 mins:
   workloader increase-ven-rate --app CRM --env PROD
 
   # Increase frequency for all workloads in the CRM (app) PROD (env) app group for an hour:
-  workloader increase-ven-rate --app CRM --env PROD --for-minutes 60`,
+  workloader increase-ven-rate --app CRM --env PROD --for-minutes 60
+
+  # Increase frequency for all workloads starting with 100 VENs per minute and backing off when API latency is over 3 seconds:
+  workloader increase-ven-rate --adaptive --start-vens 100 --step-vens 100 --step-interval 1m --max-latency 3s`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		pce, err = utils.GetTargetPCE(true)
@@ -55,6 +71,10 @@ The forMinutes flag can be used to have workloader run the command every 10 minu
 		updatePCE = viper.Get("update_pce").(bool)
 		noPrompt = viper.Get("no_prompt").(bool)
 
+		if adaptive {
+			validateAdaptive()
+		}
+
 		increaseVENUpdateRate()
 	},
 }
@@ -93,6 +113,17 @@ func increaseVENUpdateRate() {
 
 	// Get the workloads
 	pce.Load(illumioapi.LoadInput{Workloads: true, WorkloadsQueryParameters: qp})
+
+	// Limit to the workloads in the hrefs file
+	if utils.HrefsFile() != "" {
+		wklds := []illumioapi.Workload{}
+		for _, w := range pce.WorkloadsSlice {
+			if utils.WorkloadInHrefsFile(w) {
+				wklds = append(wklds, w)
+			}
+		}
+		pce.WorkloadsSlice = wklds
+	}
 	utils.LogInfo(fmt.Sprintf("%d workloads identified", len(pce.WorkloadsSlice)), true)
 
 	// If we have zero workloads, we are done.
@@ -103,7 +134,7 @@ func increaseVENUpdateRate() {
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
-		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring VEN update rate incease in %s (%s). To update, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(pce.WorkloadsSlice), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string)), true)
+		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring VEN update rate incease in %s (%s). To update, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(pce.WorkloadsSlice), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName)), true)
 		utils.LogEndCommand("increase-ven-rate")
 		return
 	}
@@ -122,7 +153,29 @@ func increaseVENUpdateRate() {
 
 	iterations := 0
 	requiredIterations := forMinutes / 10
+	adaptiveData := [][]string{{"iteration", "step", "time", "vens", "total_vens", "pce_health", "latency_ms", "action"}}
 	for iterations <= requiredIterations {
+		iterationStart := time.Now()
+
+		// Increase in steps based on pce health
+		if adaptive {
+			data, stopped := adaptiveIncrease(pce.WorkloadsSlice, iterations+1)
+			adaptiveData = append(adaptiveData, data...)
+			if stopped {
+				break
+			}
+			iterations++
+			if iterations <= requiredIterations {
+				wait := 600*time.Second - time.Since(iterationStart)
+				if wait < 0 {
+					wait = 0
+				}
+				utils.LogInfo(fmt.Sprintf("%d iterations remaining. running another in %s", requiredIterations-iterations+1, wait.Round(time.Second)), true)
+				time.Sleep(wait)
+			}
+			continue
+		}
+
 		numAPICalls := int(math.Ceil(float64(len(pce.WorkloadsSlice)) / 50))
 		utils.LogInfo(fmt.Sprintf("increase traffic update rate accepts 50 VENs at a time. %d api calls required.", numAPICalls), true)
 
@@ -152,6 +205,9 @@ func increaseVENUpdateRate() {
 			time.Sleep(600 * time.Second)
 		}
 	}
+	if adaptive {
+		utils.WriteOutput(adaptiveData, adaptiveData, fmt.Sprintf("workloader-increase-ven-rate-adaptive-%s.csv", time.Now().Format("20060102_150405")))
+	}
 	utils.LogEndCommand("increase-ven-rate")
 
 }
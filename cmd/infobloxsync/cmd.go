@@ -0,0 +1,73 @@
+package infobloxsync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var ib wapi
+var eaQuery, networkView, groupByEA, namePrefix, externalDataSet, outputFileName string
+var insecure, includeRanges, includeIPv6, cleanup, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	InfobloxSyncCmd.Flags().StringVarP(&ib.server, "infoblox-server", "s", "", "infoblox grid manager address in format server.com or server.com:8443.")
+	InfobloxSyncCmd.Flags().StringVar(&ib.version, "wapi-version", "v2.10", "wapi version.")
+	InfobloxSyncCmd.Flags().StringVarP(&ib.user, "infoblox-user", "u", "", "infoblox user. default is the INFOBLOX_USER environment variable.")
+	InfobloxSyncCmd.Flags().StringVarP(&ib.password, "infoblox-pwd", "p", "", "infoblox password. default is the INFOBLOX_PASSWORD environment variable.")
+	InfobloxSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the infoblox certificate.")
+	InfobloxSyncCmd.Flags().StringVarP(&eaQuery, "ea-query", "q", "", "comma-separated extensible attribute filters. use = for an exact match and ~= for a regex match (e.g., \"Site=NYC,Environment~=^prod\").")
+	InfobloxSyncCmd.Flags().StringVar(&networkView, "network-view", "", "only sync networks in this network view. default is all views.")
+	InfobloxSyncCmd.Flags().BoolVar(&includeRanges, "include-ranges", false, "include dhcp ranges in addition to networks.")
+	InfobloxSyncCmd.Flags().BoolVar(&includeIPv6, "include-ipv6", false, "include ipv6 networks and ranges.")
+	InfobloxSyncCmd.Flags().StringVarP(&groupByEA, "group-by-ea", "g", "", "create one ip list per value of this extensible attribute. default is one ip list per network or range.")
+	InfobloxSyncCmd.Flags().StringVar(&namePrefix, "name-prefix", "IB-", "prefix for ip list names.")
+	InfobloxSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "infoblox-sync", "external data set used to identify ip lists managed by infoblox-sync.")
+	InfobloxSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete ip lists in the external data set that no longer match in infoblox.")
+	InfobloxSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	InfobloxSyncCmd.Flags().SortFlags = false
+}
+
+// InfobloxSyncCmd runs the infoblox-sync command
+var InfobloxSyncCmd = &cobra.Command{
+	Use:         "infoblox-sync",
+	Short:       "Create and update PCE IP lists from Infoblox networks and ranges matching an extensible attribute query.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create and update PCE IP lists from Infoblox networks and ranges matching an extensible attribute query.
+
+Networks (and optionally ranges) are read from the Infoblox WAPI. By default each network or range is an ip list named with the --name-prefix and the cidr or range. The comment is the description. Use --group-by-ea to create one ip list per value of an extensible attribute (e.g., --group-by-ea Site creates IB-NYC, IB-LON, etc.).
+
+IP lists are identified by the external data set. IP list contents are replaced with the Infoblox data so IPAM is authoritative. Created and updated ip lists are provisioned. With --cleanup (default), ip lists in the external data set that no longer match are deleted and the deletion is provisioned.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if ib.server == "" {
+			utils.LogError("--infoblox-server is required")
+		}
+		if ib.user == "" {
+			ib.user = os.Getenv("INFOBLOX_USER")
+		}
+		if ib.password == "" {
+			ib.password = os.Getenv("INFOBLOX_PASSWORD")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		infobloxSync()
+	},
+}
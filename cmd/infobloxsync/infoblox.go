@@ -0,0 +1,96 @@
+package infobloxsync
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// wapi calls the Infoblox WAPI
+type wapi struct {
+	server   string
+	version  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// extAttr is an Infoblox extensible attribute value
+type extAttr struct {
+	Value interface{} `json:"value"`
+}
+
+// ibNetwork is the subset of an Infoblox network or range used by infoblox-sync
+type ibNetwork struct {
+	Ref         string             `json:"_ref"`
+	Network     string             `json:"network"`
+	StartAddr   string             `json:"start_addr"`
+	EndAddr     string             `json:"end_addr"`
+	NetworkView string             `json:"network_view"`
+	Comment     string             `json:"comment"`
+	ExtAttrs    map[string]extAttr `json:"extattrs"`
+}
+
+// ea returns the string value of an extensible attribute
+func (n ibNetwork) ea(name string) string {
+	if a, ok := n.ExtAttrs[name]; ok && a.Value != nil {
+		return fmt.Sprintf("%v", a.Value)
+	}
+	return ""
+}
+
+// get returns all objects of a type using paging. The query is added to the request.
+func (w *wapi) get(object string, query url.Values) ([]ibNetwork, error) {
+	if w.client == nil {
+		w.client = &http.Client{Timeout: 120 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}
+	}
+
+	objects := []ibNetwork{}
+	pageID := ""
+	for {
+		q := url.Values{}
+		if pageID != "" {
+			q.Set("_page_id", pageID)
+		} else {
+			for k, v := range query {
+				q[k] = v
+			}
+			q.Set("_paging", "1")
+			q.Set("_return_as_object", "1")
+			q.Set("_max_results", "1000")
+		}
+		req, err := http.NewRequest("GET", fmt.Sprintf("https://%s/wapi/%s/%s?%s", w.server, w.version, object, q.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(w.user, w.password)
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("get %s - %d - %s", object, resp.StatusCode, string(body))
+		}
+		var page struct {
+			Result     []ibNetwork `json:"result"`
+			NextPageID string      `json:"next_page_id"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Result...)
+		if page.NextPageID == "" {
+			return objects, nil
+		}
+		pageID = page.NextPageID
+	}
+}
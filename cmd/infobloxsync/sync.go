@@ -0,0 +1,191 @@
+package infobloxsync
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/cmd/iplimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// ipList is an ip list built from infoblox data
+type ipList struct {
+	name        string
+	description string
+	ref         string
+	entries     []string
+}
+
+func infobloxSync() {
+
+	utils.LogStartCommand("infoblox-sync")
+
+	// Build the query
+	query := url.Values{}
+	if eaQuery != "" {
+		for _, q := range strings.Split(eaQuery, ",") {
+			if strings.Contains(q, "~=") {
+				x := strings.SplitN(q, "~=", 2)
+				query.Set("*"+strings.TrimSpace(x[0])+"~", strings.TrimSpace(x[1]))
+				continue
+			}
+			x := strings.SplitN(q, "=", 2)
+			if len(x) != 2 {
+				utils.LogError(fmt.Sprintf("%s is not a valid extensible attribute filter", q))
+			}
+			query.Set("*"+strings.TrimSpace(x[0]), strings.TrimSpace(x[1]))
+		}
+	}
+	if networkView != "" {
+		query.Set("network_view", networkView)
+	}
+
+	// Get the networks and ranges
+	objectTypes := []string{"network"}
+	if includeRanges {
+		objectTypes = append(objectTypes, "range")
+	}
+	if includeIPv6 {
+		for _, o := range objectTypes {
+			objectTypes = append(objectTypes, "ipv6"+o)
+		}
+	}
+	networks := []ibNetwork{}
+	for _, o := range objectTypes {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("_return_fields+", "extattrs,comment")
+		n, err := ib.get(o, q)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("%d %s objects match in infoblox", len(n), o), true)
+		networks = append(networks, n...)
+	}
+
+	// Build the ip lists
+	ipLists := make(map[string]*ipList)
+	for _, n := range networks {
+		entry := n.Network
+		if entry == "" {
+			entry = fmt.Sprintf("%s-%s", n.StartAddr, n.EndAddr)
+		}
+		if groupByEA == "" {
+			ref := fmt.Sprintf("%s/%s", n.NetworkView, entry)
+			ipLists[ref] = &ipList{name: namePrefix + entry, description: n.Comment, ref: ref, entries: []string{entry}}
+			continue
+		}
+		value := n.ea(groupByEA)
+		if value == "" {
+			utils.LogWarning(fmt.Sprintf("%s does not have a %s extensible attribute. skipping.", entry, groupByEA), true)
+			continue
+		}
+		ref := fmt.Sprintf("%s=%s", groupByEA, value)
+		if _, ok := ipLists[ref]; !ok {
+			ipLists[ref] = &ipList{name: namePrefix + value, description: fmt.Sprintf("infoblox networks with %s", ref), ref: ref}
+		}
+		ipLists[ref].entries = append(ipLists[ref].entries, entry)
+	}
+
+	// Build the ipl-import data
+	refs := []string{}
+	for ref := range ipLists {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	importData := [][]string{{iplimport.HeaderName, iplimport.HeaderDescription, iplimport.HeaderInclude, iplimport.HeaderExternalDataSet, iplimport.HeaderExternalDataRef}}
+	for _, ref := range refs {
+		ipl := ipLists[ref]
+		sort.Strings(ipl.entries)
+		importData = append(importData, []string{ipl.name, ipl.description, strings.Join(ipl.entries, ";"), externalDataSet, ipl.ref})
+	}
+
+	// Get the existing ip lists in the external data set
+	existingIPLs, api, err := pce.GetIPLists(map[string]string{"external_data_set": externalDataSet}, "draft")
+	utils.LogAPIResp("GetIPLists", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Build the delete data
+	deleteData := [][]string{{"href", "name", "external_data_set", "external_data_reference"}}
+	if cleanup {
+		for _, ipl := range existingIPLs {
+			if ipl.ExternalDataSet != externalDataSet {
+				continue
+			}
+			if _, ok := ipLists[ipl.ExternalDataReference]; !ok {
+				utils.LogInfo(fmt.Sprintf("%s - %s - %s no longer matches in infoblox and will be deleted", ipl.Name, ipl.Href, ipl.ExternalDataReference), false)
+				deleteData = append(deleteData, []string{ipl.Href, ipl.Name, ipl.ExternalDataSet, ipl.ExternalDataReference})
+			}
+		}
+	}
+
+	// Write the output files
+	timeStamp := time.Now().Format("20060102_150405")
+	importFile := fmt.Sprintf("workloader-infoblox-sync-ipl-import-%s.csv", timeStamp)
+	deleteFile := fmt.Sprintf("workloader-infoblox-sync-delete-%s.csv", timeStamp)
+	if outputFileName != "" {
+		importFile = "ipl-import-" + outputFileName
+		deleteFile = "delete-" + outputFileName
+	}
+	if len(importData) > 1 {
+		utils.WriteOutput(importData, importData, importFile)
+	}
+	if len(deleteData) > 1 {
+		utils.WriteOutput(deleteData, deleteData, deleteFile)
+	}
+	utils.LogInfo(fmt.Sprintf("%d ip lists from infoblox. %d ip lists to delete.", len(importData)-1, len(deleteData)-1), true)
+
+	// Run the import. ipl-import only changes ip lists that are different and handles the update-pce and prompt logic.
+	if len(importData) > 1 {
+		iplimport.ImportIPLists(pce, importFile, updatePCE, noPrompt, false, true)
+	}
+
+	// Delete the ip lists that no longer match
+	if len(deleteData) == 1 {
+		utils.LogEndCommand("infoblox-sync")
+		return
+	}
+	if !updatePCE {
+		utils.LogInfo(fmt.Sprintf("%d ip lists will be deleted. see %s for details. to do the delete, run again using --update-pce flag.", len(deleteData)-1, deleteFile), true)
+		utils.LogEndCommand("infoblox-sync")
+		return
+	}
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will delete %d ip lists in %s (%s). do you want to run the delete (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(deleteData)-1, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			utils.LogEndCommand("infoblox-sync")
+			return
+		}
+	}
+	deletedHrefs := []string{}
+	for _, row := range deleteData[1:] {
+		a, err := pce.DeleteHref(row[0])
+		utils.LogAPIResp("DeleteHref", a)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("deleting %s - %s - %d status code. the ip list may still be in use.", row[1], err, a.StatusCode), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("deleted %s - %s - %d", row[1], row[0], a.StatusCode), true)
+		deletedHrefs = append(deletedHrefs, row[0])
+	}
+	if len(deletedHrefs) > 0 {
+		a, err := pce.ProvisionHref(deletedHrefs, "workloader infoblox-sync")
+		utils.LogAPIResp("ProvisionHref", a)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("provisioning successful - status code %d", a.StatusCode), true)
+	}
+
+	utils.LogEndCommand("infoblox-sync")
+}
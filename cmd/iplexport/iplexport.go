@@ -2,7 +2,6 @@ package iplexport
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -49,7 +48,7 @@ The update-pce and --no-prompt flags are ignored for this command.`,
 		// Set the CSV file
 		if len(args) > 1 {
 			fmt.Println("command only accepts 1 or no arguments for the ip list name. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		if len(args) > 0 {
 			iplName = args[0]
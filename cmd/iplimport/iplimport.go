@@ -2,8 +2,6 @@ package iplimport
 
 import (
 	"fmt"
-	"os"
-	"sort"
 	"strings"
 
 	"github.com/brian1917/illumioapi"
@@ -25,8 +23,9 @@ func init() {
 
 // IplImportCmd runs the iplist import command
 var IplImportCmd = &cobra.Command{
-	Use:   "ipl-import [csv file to import]",
-	Short: "Create and update IP Lists from a CSV.",
+	Use:         "ipl-import [csv file to import]",
+	Short:       "Create and update IP Lists from a CSV.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapObjectWrite},
 	Long: `
 Create and update IP lists from a CSV file.
 
@@ -69,7 +68,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
@@ -206,40 +205,8 @@ csvEntries:
 		utils.LogError(err.Error())
 	}
 
-	// Create a map of CSV IP ranges
-	csvRangeMap := make(map[string]bool)
-	for _, ipl := range csvIPLs {
-		if ipl.IPL.IPRanges != nil {
-			for _, iplr := range *ipl.IPL.IPRanges {
-				csvRangeMap[fmt.Sprintf("%s%s%s%t", ipl.IPL.Name, iplr.FromIP, iplr.ToIP, iplr.Exclusion)] = true
-			}
-		}
-		if ipl.IPL.FQDNs != nil {
-			for _, f := range *ipl.IPL.FQDNs {
-				csvRangeMap[fmt.Sprintf("%s%s", ipl.IPL.Name, f.FQDN)] = true
-			}
-		}
-	}
-
-	// Create a map of Existing IP ranges
-	existingRangeMap := make(map[string]bool)
-	for _, ipl := range pce.IPListsSlice {
-		if ipl.IPRanges != nil {
-			for _, iplr := range *ipl.IPRanges {
-				existingRangeMap[fmt.Sprintf("%s%s%s%t", ipl.Name, iplr.FromIP, iplr.ToIP, iplr.Exclusion)] = true
-			}
-		}
-		if ipl.FQDNs != nil {
-			for _, f := range *ipl.FQDNs {
-				existingRangeMap[fmt.Sprintf("%s%s", ipl.Name, f.FQDN)] = true
-			}
-		}
-	}
-
-	// Create slice to hold new IPLs and IPLs that need update
-	var IPLsToCreate, IPLsToUpdate []entry
-
-	// Iterate through each CSV IP list and see what we need to do
+	// Compare the CSV IP lists to the PCE
+	changes := utils.NewChangeSet[illumioapi.IPList]("ip lists")
 	for _, csvIPL := range csvIPLs {
 
 		var existingIPL illumioapi.IPList
@@ -253,97 +220,22 @@ csvEntries:
 			}
 			// If no href is provided, search the IPL by name. If it doesn't exist, create it.
 		} else if existingIPL, ok = pce.IPLists[csvIPL.IPL.Name]; !ok {
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s does not exist and will be created.", csvIPL.csvLine, csvIPL.IPL.Name), false)
-			IPLsToCreate = append(IPLsToCreate, csvIPL)
+			changes.Create(csvIPL.IPL, csvIPL.IPL.Name, csvIPL.csvLine)
 			continue
 		}
 
-		// Get here if we are updating and start the log message
-		logMsg := fmt.Sprintf("csv line %d - %s exists in the PCE.", csvIPL.csvLine, existingIPL.Href)
-
-		// Set the update value to false
-		update := false
-
-		// Check the name
-		if existingIPL.Name != csvIPL.IPL.Name {
-			update = true
-			logMsg = fmt.Sprintf("%s name to be updated from %s to %s.", logMsg, existingIPL.Name, csvIPL.IPL.Name)
-		}
-
-		// Check the description
-		if existingIPL.Description != csvIPL.IPL.Description {
-			update = true
-			logMsg = fmt.Sprintf("%s descrption to be updated from %s to %s.", logMsg, existingIPL.Description, csvIPL.IPL.Description)
-		}
-
-		// Check that all IP ranges from CSV are in the PCE.
-		if csvIPL.IPL.IPRanges != nil {
-			for _, r := range *csvIPL.IPL.IPRanges {
-				if !existingRangeMap[fmt.Sprintf("%s%s%s%t", existingIPL.Name, r.FromIP, r.ToIP, r.Exclusion)] { // use existingIPL.Name in case name is being changed
-					rangeTxt := r.FromIP
-					if r.ToIP != "" {
-						rangeTxt = fmt.Sprintf("%s-%s", r.FromIP, r.ToIP)
-					}
-					if r.Exclusion {
-						rangeTxt = fmt.Sprintf("!%s", rangeTxt)
-					}
-					logMsg = fmt.Sprintf("%s %s will be added to the ip list.", logMsg, rangeTxt)
-					update = true
-				}
-			}
-		}
-
-		// Check that all IP ranges from PCE are in CSV
-		if existingIPL.IPRanges != nil {
-			for _, r := range *existingIPL.IPRanges {
-				if !csvRangeMap[fmt.Sprintf("%s%s%s%t", csvIPL.IPL.Name, r.FromIP, r.ToIP, r.Exclusion)] { // use csvIPL.IPL.Name in case name is being changed
-					rangeTxt := r.FromIP
-					if r.ToIP != "" {
-						rangeTxt = fmt.Sprintf("%s-%s", r.FromIP, r.ToIP)
-					}
-					if r.Exclusion {
-						rangeTxt = fmt.Sprintf("!%s", rangeTxt)
-					}
-					logMsg = fmt.Sprintf("%s %s will be removed from the ip list.", logMsg, rangeTxt)
-					update = true
-				}
-			}
-		}
-
-		// Check that FQDNs in the CSV are in the PCE
-		if csvIPL.IPL.FQDNs != nil {
-			for _, f := range *csvIPL.IPL.FQDNs {
-				if !existingRangeMap[fmt.Sprintf("%s%s", existingIPL.Name, f.FQDN)] { // use existingIPL.Name in case name is being changed
-					logMsg = fmt.Sprintf("%s %s will be added to the ip list.", logMsg, f.FQDN)
-					update = true
-				}
-			}
-		}
-
-		// Check that FQDNs in the PCE are in the CSV
-		if existingIPL.FQDNs != nil {
-			for _, f := range *existingIPL.FQDNs {
-				if !csvRangeMap[fmt.Sprintf("%s%s", csvIPL.IPL.Name, f.FQDN)] { // use csvIPL.IPL.Name in case name is being changed
-					logMsg = fmt.Sprintf("%s %s will be removed from the ip list.", logMsg, f.FQDN)
-					update = true
-				}
-			}
-		}
-
-		// Log the log message
-		if update {
-			utils.LogInfo(logMsg, false)
-		}
-
-		// If we need to update, add it to our slice
-		if update {
-			csvIPL.IPL.Href = existingIPL.Href
-			IPLsToUpdate = append(IPLsToUpdate, csvIPL)
-		}
+		// Compare the fields
+		fields := utils.DiffValue("name", existingIPL.Name, csvIPL.IPL.Name)
+		fields = append(fields, utils.DiffValue("description", existingIPL.Description, csvIPL.IPL.Description)...)
+		fields = append(fields, utils.DiffSet("ip ranges", ipRanges(existingIPL), ipRanges(csvIPL.IPL))...)
+		fields = append(fields, utils.DiffSet("fqdns", fqdns(existingIPL), fqdns(csvIPL.IPL))...)
+		csvIPL.IPL.Href = existingIPL.Href
+		changes.Update(csvIPL.IPL, existingIPL.Name, existingIPL.Href, fields, csvIPL.csvLine)
 	}
+	changes.Log()
 
 	// End run if we have nothing to do
-	if len(IPLsToCreate) == 0 && len(IPLsToUpdate) == 0 {
+	if changes.Empty() {
 		utils.LogInfo("nothing to be done.", true)
 		utils.LogEndCommand("ipl-import")
 		return
@@ -351,7 +243,7 @@ csvEntries:
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
-		utils.LogInfo(fmt.Sprintf("workloader identified %d ip-lists to create and %d ip-lists to update. see workloader.log for all identified changes. to do the import, run again using --update-pce flag", len(IPLsToCreate), len(IPLsToUpdate)), true)
+		utils.LogInfo(fmt.Sprintf("workloader identified %s. see workloader.log for all identified changes. to do the import, run again using --update-pce flag", changes.Summary()), true)
 		utils.LogEndCommand("ipl-import")
 		return
 	}
@@ -359,60 +251,56 @@ csvEntries:
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("[PROMPT] - workloader will create %d iplists and update %d iplists in %s (%s). do you want to run the import (yes/no)? ", len(IPLsToCreate), len(IPLsToUpdate), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("[PROMPT] - workloader will create %d iplists and update %d iplists in %s (%s). do you want to run the import (yes/no)? ", len(changes.Creates), len(changes.Updates), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
-			utils.LogInfo(fmt.Sprintf("prompt denied for creating %d iplists and updating %d iplists.", len(IPLsToCreate), len(IPLsToUpdate)), true)
+			utils.LogInfo(fmt.Sprintf("prompt denied for creating %d iplists and updating %d iplists.", len(changes.Creates), len(changes.Updates)), true)
 			utils.LogEndCommand("ipl-import")
 			return
 		}
 	}
 
-	// Sort our slices by the CSV line
-	sort.SliceStable(IPLsToCreate, func(i, j int) bool { return IPLsToCreate[i].csvLine < IPLsToCreate[j].csvLine })
-	sort.SliceStable(IPLsToUpdate, func(i, j int) bool { return IPLsToUpdate[i].csvLine < IPLsToUpdate[j].csvLine })
-
 	// Create new IPLs
 	var updatedIPLs, createdIPLs, skippedIPLs int
 	provisionableIPLs := []string{}
 
-	for _, newIPL := range IPLsToCreate {
-		ipl, a, err := pce.CreateIPList(newIPL.IPL)
+	for _, newIPL := range changes.Creates {
+		ipl, a, err := pce.CreateIPList(newIPL.Object)
 		utils.LogAPIResp("CreateIPList", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("ending run - %d ip lists created - %d ip lists updated.", createdIPLs, updatedIPLs))
 			utils.LogError(err.Error())
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 not acceptable - see workloader.log for more details", newIPL.csvLine, newIPL.IPL.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 not acceptable - see workloader.log for more details", newIPL.CSVLines[0], newIPL.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skippedIPLs++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s created - status code %d", newIPL.csvLine, ipl.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line %d - %s created - status code %d", newIPL.CSVLines[0], ipl.Name, a.StatusCode), true)
 			createdIPLs++
 			provisionableIPLs = append(provisionableIPLs, ipl.Href)
 		}
 	}
 
 	// Update IPLs
-	for _, updateIPL := range IPLsToUpdate {
-		a, err := pce.UpdateIPList(updateIPL.IPL)
+	for _, updateIPL := range changes.Updates {
+		a, err := pce.UpdateIPList(updateIPL.Object)
 		utils.LogAPIResp("UpdateIPList", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("ending run - %d ip lists created - %d ip lists updated.", createdIPLs, updatedIPLs))
 			utils.LogError(err.Error())
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 not acceptable - see workloader.log for more details", updateIPL.csvLine, updateIPL.IPL.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 not acceptable - see workloader.log for more details", updateIPL.CSVLines[0], updateIPL.Object.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skippedIPLs++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s updated - status code %d", updateIPL.csvLine, updateIPL.IPL.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line %d - %s updated - status code %d", updateIPL.CSVLines[0], updateIPL.Object.Name, a.StatusCode), true)
 			updatedIPLs++
-			provisionableIPLs = append(provisionableIPLs, updateIPL.IPL.Href)
+			provisionableIPLs = append(provisionableIPLs, updateIPL.Href)
 		}
 	}
 
@@ -429,3 +317,32 @@ csvEntries:
 	utils.LogEndCommand("ipl-import")
 
 }
+
+// ipRanges returns the ip ranges of an ip list as text. Ranges use a dash and exclusions start with !.
+func ipRanges(ipl illumioapi.IPList) []string {
+	ranges := []string{}
+	if ipl.IPRanges != nil {
+		for _, r := range *ipl.IPRanges {
+			rangeTxt := r.FromIP
+			if r.ToIP != "" {
+				rangeTxt = fmt.Sprintf("%s-%s", r.FromIP, r.ToIP)
+			}
+			if r.Exclusion {
+				rangeTxt = fmt.Sprintf("!%s", rangeTxt)
+			}
+			ranges = append(ranges, rangeTxt)
+		}
+	}
+	return ranges
+}
+
+// fqdns returns the fqdns of an ip list
+func fqdns(ipl illumioapi.IPList) []string {
+	fqdns := []string{}
+	if ipl.FQDNs != nil {
+		for _, f := range *ipl.FQDNs {
+			fqdns = append(fqdns, f.FQDN)
+		}
+	}
+	return fqdns
+}
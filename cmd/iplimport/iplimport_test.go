@@ -0,0 +1,41 @@
+package iplimport
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/spf13/viper"
+)
+
+// TestImportIPListsChanges checks only ip lists with changed values are sent to the pce. Range order is ignored.
+func TestImportIPListsChanges(t *testing.T) {
+	s := mockpce.Start(t, "")
+	dir := s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	csvFile := filepath.Join(dir, "ipls.csv")
+	csv := "name,description,include\n" +
+		"Any (0.0.0.0/0 and ::/0),,::/0;0.0.0.0/0\n" +
+		"Corporate,corporate and vpn networks,192.168.0.0/16\n" +
+		"Lab,,10.50.0.0/16\n"
+	if err := os.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ImportIPLists(s.PCE(), csvFile, true, true, false, false)
+
+	writes := []string{}
+	for _, r := range s.Writes() {
+		writes = append(writes, r.Method+" "+r.Path)
+	}
+	want := []string{"POST /orgs/1/sec_policy/draft/ip_lists", "PUT /orgs/1/sec_policy/draft/ip_lists/2"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("writes are %v, want %v", writes, want)
+	}
+	if corp, _ := s.Object("/orgs/1/sec_policy/draft/ip_lists/2"); corp["description"] != "corporate and vpn networks" {
+		t.Errorf("corporate description is %v", corp["description"])
+	}
+}
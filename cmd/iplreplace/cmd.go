@@ -2,7 +2,6 @@ package iplreplace
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -40,8 +39,9 @@ func init() {
 
 // IplImportCmd runs the iplist import command
 var IplReplaceCmd = &cobra.Command{
-	Use:   "ipl-replace [name of IPL to replace or create]",
-	Short: "Replace all entries in an IP List with contents of a CSV file.",
+	Use:         "ipl-replace [name of IPL to replace or create]",
+	Short:       "Replace all entries in an IP List with contents of a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Replace all entries in an IP List with contents of a CSV file or files. Two files can be provided: one for ip entries (-i or --ip-file-name) and one for fqdns (-f or --fqdn-file-name).
 
@@ -65,7 +65,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("command requires 1 argument for the name of the IP list. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		iplName = args[0]
 
@@ -185,9 +185,9 @@ func iplReplace() {
 	if updatePCE && !noPrompt {
 		var prompt string
 		if iplToBeCreated {
-			fmt.Printf("[PROMPT] - workloader will create %s ip list with %d ip entries and %d in %s(%s). do you want to run the import (yes/no)? ", iplName, ipCount, fqdnCount, pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+			fmt.Printf("[PROMPT] - workloader will create %s ip list with %d ip entries and %d in %s(%s). do you want to run the import (yes/no)? ", iplName, ipCount, fqdnCount, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		} else {
-			fmt.Printf("[PROMPT] - workloader identified %d ip entries and %d fqdn entries to replace the existing %d ip entries and %d fqdn entries in %s ip list in %s(%s). do you want to run the import (yes/no)? ", ipCount, fqdnCount, len(*pceIPL.IPRanges), len(*pceIPL.FQDNs), pceIPL.Name, pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+			fmt.Printf("[PROMPT] - workloader identified %d ip entries and %d fqdn entries to replace the existing %d ip entries and %d fqdn entries in %s ip list in %s(%s). do you want to run the import (yes/no)? ", ipCount, fqdnCount, len(*pceIPL.IPRanges), len(*pceIPL.FQDNs), pceIPL.Name, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		}
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
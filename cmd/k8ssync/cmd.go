@@ -0,0 +1,72 @@
+package k8ssync
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var kubeconfigFile, contexts, mappingFile, servicesAs, externalDataSet, outputFileName string
+var cleanup, noNodes, includeClusterIP, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	K8sSyncCmd.Flags().StringVar(&kubeconfigFile, "kubeconfig", "", "kubeconfig file. default is the KUBECONFIG environment variable, then ~/.kube/config.")
+	K8sSyncCmd.Flags().StringVar(&contexts, "contexts", "", "comma-separated list of kubeconfig contexts to sync. default is the current context.")
+	K8sSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: kubernetes label or annotation and illumio label key. see help for details.")
+	K8sSyncCmd.Flags().StringVar(&servicesAs, "services-as", "umwl", "represent services and ingresses as umwl or virtual-service.")
+	K8sSyncCmd.Flags().BoolVar(&includeClusterIP, "include-cluster-ip", false, "include ClusterIP services. by default only LoadBalancer services, services with external ips, and ingresses are synced.")
+	K8sSyncCmd.Flags().BoolVar(&noNodes, "no-nodes", false, "do not create unmanaged workloads for nodes.")
+	K8sSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "k8s-sync", "external data set used to identify objects managed by k8s-sync.")
+	K8sSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads and virtual services in the external data set that are no longer in the clusters.")
+	K8sSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	K8sSyncCmd.Flags().SortFlags = false
+}
+
+// K8sSyncCmd runs the k8s-sync command
+var K8sSyncCmd = &cobra.Command{
+	Use:         "k8s-sync",
+	Short:       "Create, update, and delete unmanaged workloads or virtual services for Kubernetes nodes, services, and ingresses.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Create, update, and delete unmanaged workloads or virtual services for Kubernetes nodes, services, and ingresses.
+
+Each context in --contexts is read from the kubeconfig. Token, client certificate, and basic authentication are supported. Exec credential plugins are not supported; use a service account token with read access to nodes, namespaces, services, and ingresses.
+
+Nodes are unmanaged workloads with their internal and external addresses as interfaces. Services and ingresses are unmanaged workloads or virtual services (--services-as virtual-service) using their load balancer or external ips. Virtual services are provisioned after they are created or updated.
+
+The external data reference is context/kind/namespace/name, so re-runs update the same objects.
+
+The mapping file is a csv with the kubernetes key in the first column and the illumio label key in the second column. A header of k8s_key,label_key is optional. Keys are looked up in order of the object's annotations, the object's labels, and the namespace labels. The following pseudo keys can also be used: k8s:context, k8s:namespace, k8s:kind, k8s:name.
+
+Example mapping file:
++-----------------------------+-----------+
+|           k8s_key           | label_key |
++-----------------------------+-----------+
+| app.kubernetes.io/name      | app       |
+| illumio.com/env             | env       |
+| k8s:context                 | loc       |
++-----------------------------+-----------+
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if servicesAs != "umwl" && servicesAs != "virtual-service" {
+			utils.LogError("--services-as must be umwl or virtual-service")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		k8sSync()
+	},
+}
@@ -0,0 +1,301 @@
+package k8ssync
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeConfig is the subset of a kubeconfig file used by k8s-sync
+type kubeConfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Username              string      `yaml:"username"`
+			Password              string      `yaml:"password"`
+			Exec                  interface{} `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// k8sClient calls the kubernetes api for a single context
+type k8sClient struct {
+	context  string
+	server   string
+	token    string
+	username string
+	password string
+	client   *http.Client
+}
+
+// objectMeta is the metadata of a kubernetes object
+type objectMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	UID         string            `json:"uid"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type loadBalancerStatus struct {
+	Ingress []struct {
+		IP       string `json:"ip"`
+		Hostname string `json:"hostname"`
+	} `json:"ingress"`
+}
+
+// k8sNode is the subset of a node used by k8s-sync
+type k8sNode struct {
+	Metadata objectMeta `json:"metadata"`
+	Status   struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		NodeInfo struct {
+			OSImage string `json:"osImage"`
+		} `json:"nodeInfo"`
+	} `json:"status"`
+}
+
+// k8sService is the subset of a service used by k8s-sync
+type k8sService struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Type        string   `json:"type"`
+		ClusterIP   string   `json:"clusterIP"`
+		ExternalIPs []string `json:"externalIPs"`
+		Ports       []struct {
+			Name     string `json:"name"`
+			Protocol string `json:"protocol"`
+			Port     int    `json:"port"`
+			NodePort int    `json:"nodePort"`
+		} `json:"ports"`
+	} `json:"spec"`
+	Status struct {
+		LoadBalancer loadBalancerStatus `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// k8sIngress is the subset of an ingress used by k8s-sync
+type k8sIngress struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		TLS []interface{} `json:"tls"`
+	} `json:"spec"`
+	Status struct {
+		LoadBalancer loadBalancerStatus `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// k8sNamespace is the subset of a namespace used by k8s-sync
+type k8sNamespace struct {
+	Metadata objectMeta `json:"metadata"`
+}
+
+// loadKubeConfig parses the kubeconfig file. The default is the KUBECONFIG environment variable, then ~/.kube/config.
+func loadKubeConfig(file string) (kubeConfig, error) {
+	var kc kubeConfig
+	if file == "" {
+		file = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return kc, err
+		}
+		file = filepath.Join(home, ".kube", "config")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return kc, err
+	}
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return kc, fmt.Errorf("parsing %s - %s", file, err)
+	}
+	return kc, nil
+}
+
+// newK8sClient builds a client for the provided context
+func (kc kubeConfig) newK8sClient(context string) (k8sClient, error) {
+	c := k8sClient{context: context}
+
+	clusterName, userName := "", ""
+	found := false
+	for _, ctx := range kc.Contexts {
+		if ctx.Name == context {
+			clusterName, userName, found = ctx.Context.Cluster, ctx.Context.User, true
+		}
+	}
+	if !found {
+		return c, fmt.Errorf("context %s not found in kubeconfig", context)
+	}
+
+	tlsConfig := &tls.Config{}
+	for _, cl := range kc.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		c.server = strings.TrimSuffix(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		caPEM, err := dataOrFile(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority)
+		if err != nil {
+			return c, err
+		}
+		if caPEM != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return c, fmt.Errorf("invalid certificate authority for cluster %s", cl.Name)
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if c.server == "" {
+		return c, fmt.Errorf("cluster %s for context %s not found in kubeconfig", clusterName, context)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil {
+			return c, fmt.Errorf("user %s uses an exec credential plugin, which is not supported. use a service account token", u.Name)
+		}
+		c.token, c.username, c.password = u.User.Token, u.User.Username, u.User.Password
+		if c.token == "" && u.User.TokenFile != "" {
+			t, err := os.ReadFile(u.User.TokenFile)
+			if err != nil {
+				return c, err
+			}
+			c.token = strings.TrimSpace(string(t))
+		}
+		certPEM, err := dataOrFile(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return c, err
+		}
+		keyPEM, err := dataOrFile(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return c, err
+		}
+		if certPEM != nil && keyPEM != nil {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return c, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	c.client = &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}}
+	return c, nil
+}
+
+// dataOrFile returns the base64 decoded data or, if data is blank, the contents of the file
+func dataOrFile(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// list gets all pages of a list endpoint and unmarshals the items into the slice pointed to by v
+func (c k8sClient) list(path string, v interface{}) error {
+	items := []json.RawMessage{}
+	cont := ""
+	for {
+		reqURL := fmt.Sprintf("%s%s?limit=500", c.server, path)
+		if cont != "" {
+			reqURL = reqURL + "&continue=" + url.QueryEscape(cont)
+		}
+		req, err := http.NewRequest("GET", reqURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s %s - %d - %s", c.context, path, resp.StatusCode, string(body))
+		}
+		var page struct {
+			Items    []json.RawMessage `json:"items"`
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		items = append(items, page.Items...)
+		if page.Metadata.Continue == "" {
+			break
+		}
+		cont = page.Metadata.Continue
+	}
+
+	all, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(all, v)
+}
+
+// ips returns the ip addresses of a load balancer status
+func (lb loadBalancerStatus) ips() []string {
+	ips := []string{}
+	for _, i := range lb.Ingress {
+		if i.IP != "" {
+			ips = append(ips, i.IP)
+		}
+	}
+	return ips
+}
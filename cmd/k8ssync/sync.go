@@ -0,0 +1,206 @@
+package k8ssync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/vssync"
+	"github.com/brian1917/workloader/utils"
+)
+
+func k8sSync() {
+
+	utils.LogStartCommand("k8s-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	kc, err := loadKubeConfig(kubeconfigFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	contextList := []string{kc.CurrentContext}
+	if contexts != "" {
+		contextList = strings.Split(strings.ReplaceAll(contexts, " ", ""), ",")
+	}
+
+	workloads := []umwlsync.Workload{}
+	virtualServices := []vssync.VirtualService{}
+	for _, context := range contextList {
+		client, err := kc.newK8sClient(context)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		w, vs, err := discover(client, mapping)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		workloads = append(workloads, w...)
+		virtualServices = append(virtualServices, vs...)
+	}
+
+	umwlsync.Sync(umwlsync.Input{
+		PCE:             pce,
+		Command:         "k8s-sync",
+		ExternalDataSet: externalDataSet,
+		Workloads:       workloads,
+		Cleanup:         cleanup,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		OutputFileName:  outputFileName,
+	})
+
+	if servicesAs == "virtual-service" {
+		vssync.Sync(vssync.Input{
+			PCE:             pce,
+			Command:         "k8s-sync",
+			ExternalDataSet: externalDataSet,
+			VirtualServices: virtualServices,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+		})
+	}
+
+	utils.LogEndCommand("k8s-sync")
+}
+
+// discover reads the nodes, services, and ingresses from a cluster
+func discover(c k8sClient, mapping map[string]string) ([]umwlsync.Workload, []vssync.VirtualService, error) {
+
+	workloads := []umwlsync.Workload{}
+	virtualServices := []vssync.VirtualService{}
+
+	// Namespaces for label lookups
+	namespaces := []k8sNamespace{}
+	if err := c.list("/api/v1/namespaces", &namespaces); err != nil {
+		return nil, nil, err
+	}
+	nsLabels := make(map[string]map[string]string)
+	for _, ns := range namespaces {
+		nsLabels[ns.Metadata.Name] = ns.Metadata.Labels
+	}
+
+	// Nodes
+	if !noNodes {
+		nodes := []k8sNode{}
+		if err := c.list("/api/v1/nodes", &nodes); err != nil {
+			return nil, nil, err
+		}
+		utils.LogInfo(fmt.Sprintf("%s - %d nodes discovered", c.context, len(nodes)), true)
+		for _, n := range nodes {
+			w := umwlsync.Workload{
+				Hostname:              n.Metadata.Name,
+				Name:                  n.Metadata.Name,
+				Description:           fmt.Sprintf("kubernetes node in %s - %s", c.context, n.Status.NodeInfo.OSImage),
+				Labels:                umwlsync.MapLabels(attributes(c.context, "node", n.Metadata, nil), mapping),
+				ExternalDataReference: reference(c.context, "node", n.Metadata),
+			}
+			for _, a := range n.Status.Addresses {
+				switch a.Type {
+				case "InternalIP":
+					w.Interfaces = append(w.Interfaces, "eth0:"+a.Address)
+				case "ExternalIP":
+					w.PublicIP = a.Address
+				case "Hostname":
+					w.Hostname = a.Address
+				}
+			}
+			workloads = append(workloads, w)
+		}
+	}
+
+	// Services
+	services := []k8sService{}
+	if err := c.list("/api/v1/services", &services); err != nil {
+		return nil, nil, err
+	}
+	utils.LogInfo(fmt.Sprintf("%s - %d services discovered", c.context, len(services)), true)
+	for _, s := range services {
+		ips := append(s.Status.LoadBalancer.ips(), s.Spec.ExternalIPs...)
+		if len(ips) == 0 && includeClusterIP && s.Spec.ClusterIP != "" && s.Spec.ClusterIP != "None" {
+			ips = []string{s.Spec.ClusterIP}
+		}
+		if len(ips) == 0 {
+			utils.LogInfo(fmt.Sprintf("%s - service %s/%s has no load balancer or external ips. skipping.", c.context, s.Metadata.Namespace, s.Metadata.Name), false)
+			continue
+		}
+		ports := []*illumioapi.ServicePort{}
+		for _, p := range s.Spec.Ports {
+			proto := 6
+			switch strings.ToUpper(p.Protocol) {
+			case "UDP":
+				proto = 17
+			case "SCTP":
+				proto = 132
+			}
+			ports = append(ports, &illumioapi.ServicePort{Port: p.Port, Protocol: proto})
+		}
+		attr := attributes(c.context, "service", s.Metadata, nsLabels[s.Metadata.Namespace])
+		virtualServices = append(virtualServices, vssync.VirtualService{Name: fmt.Sprintf("%s-%s-%s", c.context, s.Metadata.Namespace, s.Metadata.Name), ExternalDataReference: reference(c.context, "service", s.Metadata), IPs: ips, ServicePorts: ports, Labels: umwlsync.MapLabels(attr, mapping)})
+	}
+
+	// Ingresses
+	ingresses := []k8sIngress{}
+	if err := c.list("/apis/networking.k8s.io/v1/ingresses", &ingresses); err != nil {
+		return nil, nil, err
+	}
+	utils.LogInfo(fmt.Sprintf("%s - %d ingresses discovered", c.context, len(ingresses)), true)
+	for _, i := range ingresses {
+		ips := i.Status.LoadBalancer.ips()
+		if len(ips) == 0 {
+			utils.LogInfo(fmt.Sprintf("%s - ingress %s/%s has no load balancer ips. skipping.", c.context, i.Metadata.Namespace, i.Metadata.Name), false)
+			continue
+		}
+		ports := []*illumioapi.ServicePort{{Port: 80, Protocol: 6}}
+		if len(i.Spec.TLS) > 0 {
+			ports = append(ports, &illumioapi.ServicePort{Port: 443, Protocol: 6})
+		}
+		attr := attributes(c.context, "ingress", i.Metadata, nsLabels[i.Metadata.Namespace])
+		virtualServices = append(virtualServices, vssync.VirtualService{Name: fmt.Sprintf("%s-%s-%s-ingress", c.context, i.Metadata.Namespace, i.Metadata.Name), ExternalDataReference: reference(c.context, "ingress", i.Metadata), IPs: ips, ServicePorts: ports, Labels: umwlsync.MapLabels(attr, mapping)})
+	}
+
+	// Convert the services and ingresses to unmanaged workloads if not using virtual services
+	if servicesAs == "umwl" {
+		for _, vs := range virtualServices {
+			w := umwlsync.Workload{Hostname: vs.Name, Name: vs.Name, Description: "kubernetes " + vs.ExternalDataReference, Labels: vs.Labels, ExternalDataReference: vs.ExternalDataReference}
+			for n, ip := range vs.IPs {
+				w.Interfaces = append(w.Interfaces, fmt.Sprintf("umwl%d:%s", n, ip))
+			}
+			workloads = append(workloads, w)
+		}
+	}
+
+	return workloads, virtualServices, nil
+}
+
+// attributes returns the lookup map used for label mapping. Annotations override object labels, which override namespace labels.
+func attributes(context, kind string, meta objectMeta, namespaceLabels map[string]string) map[string]string {
+	attr := make(map[string]string)
+	for _, m := range []map[string]string{namespaceLabels, meta.Labels, meta.Annotations} {
+		for k, v := range m {
+			attr[k] = v
+		}
+	}
+	attr["k8s:context"] = context
+	attr["k8s:namespace"] = meta.Namespace
+	attr["k8s:kind"] = kind
+	attr["k8s:name"] = meta.Name
+	return attr
+}
+
+// reference returns the external data reference for an object
+func reference(context, kind string, meta objectMeta) string {
+	if meta.Namespace == "" {
+		return fmt.Sprintf("%s/%s/%s", context, kind, meta.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", context, kind, meta.Namespace, meta.Name)
+}
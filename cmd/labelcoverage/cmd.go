@@ -0,0 +1,72 @@
+package labelcoverage
+
+import (
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var labelKeys, byKeys, outputFileName, workloadFileName string
+var managedOnly, unmanagedOnly bool
+
+func init() {
+	LabelCoverageCmd.Flags().StringVar(&labelKeys, "label-keys", "", "comma-separated label keys to check. default is all label dimensions.")
+	LabelCoverageCmd.Flags().StringVar(&byKeys, "by", "", "comma-separated label keys to break down missing labels by. default is all other label dimensions.")
+	LabelCoverageCmd.Flags().BoolVar(&managedOnly, "managed-only", false, "only check managed workloads.")
+	LabelCoverageCmd.Flags().BoolVar(&unmanagedOnly, "unmanaged-only", false, "only check unmanaged workloads.")
+	LabelCoverageCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the summary output file location. default is current location with a timestamped filename.")
+	LabelCoverageCmd.Flags().StringVar(&workloadFileName, "workload-file", "", "optionally specify the name of the workload output file location. default is current location with a timestamped filename.")
+	LabelCoverageCmd.Flags().SortFlags = false
+}
+
+// LabelCoverageCmd reports workloads missing labels
+var LabelCoverageCmd = &cobra.Command{
+	Use:   "label-coverage",
+	Short: "Report workloads missing a label for each label dimension broken down by their other labels.",
+	Long: `
+Report workloads missing a label for each label dimension broken down by their other labels.
+
+The summary output has a row for each checked label key with the total number of workloads missing it and a row for each value of the other label keys (e.g., the number of workloads missing an app label in each location). Workloads without the other label are counted as unlabeled.
+
+The workload output has each workload missing a checked label with its current labels in wkld-import format. Fill in the blank labels and import it with wkld-import to remediate. Blank values do not change existing labels in wkld-import.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report missing labels for every label dimension
+  workloader label-coverage
+
+  # Report workloads missing an app label by location and env
+  workloader label-coverage --label-keys app --by loc,env
+
+  # Label the workloads after filling in the workload output
+  workloader wkld-import workloader-label-coverage-workloads-<timestamp>.csv --update-pce`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if managedOnly && unmanagedOnly {
+			utils.LogErrorCode(utils.ExitValidation, "--managed-only and --unmanaged-only cannot both be set")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("label-coverage")
+		labelCoverage()
+		utils.LogEndCommand("label-coverage")
+	},
+}
+
+// keyList returns the keys of a comma-separated flag
+func keyList(flag string) []string {
+	keys := []string{}
+	for _, k := range strings.Split(flag, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
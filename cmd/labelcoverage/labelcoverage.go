@@ -0,0 +1,167 @@
+package labelcoverage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// unlabeled is the breakdown value for workloads without the breakdown label
+const unlabeled = "unlabeled"
+
+// breakdown is the workloads and the workloads missing a label for a value of another label key
+type breakdown struct {
+	workloads, missing int
+}
+
+func labelCoverage() {
+
+	// Get the label dimensions. PCEs without label dimensions use role, app, env, and loc.
+	dimensions := []string{}
+	labelDimensions, a, err := pce.GetLabelDimensions(nil)
+	utils.LogAPIResp("GetLabelDimensions", a)
+	if err != nil || len(labelDimensions) == 0 {
+		dimensions = []string{"role", "app", "env", "loc"}
+	}
+	for _, d := range labelDimensions {
+		dimensions = append(dimensions, d.Key)
+	}
+
+	// Validate the keys against the dimensions
+	validKey := make(map[string]bool)
+	for _, d := range dimensions {
+		validKey[d] = true
+	}
+	checked, by := keyList(labelKeys), keyList(byKeys)
+	for _, k := range append(append([]string{}, checked...), by...) {
+		if !validKey[k] {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a label dimension. valid keys are %s", k, strings.Join(dimensions, ", ")))
+		}
+	}
+	if len(checked) == 0 {
+		checked = dimensions
+	}
+
+	// Load the PCE
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Count the workloads missing each checked key in total and by the values of the other keys
+	total, missing := 0, make(map[string]int)
+	breakdowns := make(map[string]map[string]map[string]*breakdown)
+	for _, k := range checked {
+		breakdowns[k] = make(map[string]map[string]*breakdown)
+	}
+	wkldData := [][]string{append(append([]string{wkldexport.HeaderHref, wkldexport.HeaderHostname, wkldexport.HeaderName}, dimensions...), "missing_labels")}
+	for _, w := range pce.WorkloadsSlice {
+		managed := w.GetMode() != "unmanaged"
+		if (managedOnly && !managed) || (unmanagedOnly && managed) {
+			continue
+		}
+		total++
+
+		values := make(map[string]string)
+		for _, d := range dimensions {
+			values[d] = w.GetLabelByKey(d, pce.Labels).Value
+		}
+
+		missingKeys := []string{}
+		for _, k := range checked {
+			if values[k] == "" {
+				missing[k]++
+				missingKeys = append(missingKeys, k)
+			}
+			for _, b := range breakdownKeys(k, by, dimensions) {
+				v := values[b]
+				if v == "" {
+					v = unlabeled
+				}
+				if breakdowns[k][b] == nil {
+					breakdowns[k][b] = make(map[string]*breakdown)
+				}
+				if breakdowns[k][b][v] == nil {
+					breakdowns[k][b][v] = &breakdown{}
+				}
+				breakdowns[k][b][v].workloads++
+				if values[k] == "" {
+					breakdowns[k][b][v].missing++
+				}
+			}
+		}
+
+		if len(missingKeys) > 0 {
+			row := []string{w.Href, w.Hostname, w.Name}
+			for _, d := range dimensions {
+				row = append(row, values[d])
+			}
+			wkldData = append(wkldData, append(row, strings.Join(missingKeys, ";")))
+		}
+	}
+
+	// Build the summary with the total for each key followed by the breakdowns with the most missing first
+	data := [][]string{{"missing_label", "by_label", "by_value", "workloads", "missing", "missing_percent"}}
+	for _, k := range checked {
+		data = append(data, summaryRow(k, "all", "", breakdown{workloads: total, missing: missing[k]}))
+		utils.LogInfo(fmt.Sprintf("%d of %d workloads missing %s label", missing[k], total, k), true)
+		for _, b := range breakdownKeys(k, by, dimensions) {
+			values := []string{}
+			for v := range breakdowns[k][b] {
+				values = append(values, v)
+			}
+			sort.Slice(values, func(i, j int) bool {
+				mi, mj := breakdowns[k][b][values[i]].missing, breakdowns[k][b][values[j]].missing
+				if mi != mj {
+					return mi > mj
+				}
+				return values[i] < values[j]
+			})
+			for _, v := range values {
+				data = append(data, summaryRow(k, b, v, *breakdowns[k][b][v]))
+			}
+		}
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-label-coverage-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	utils.LogInfo(fmt.Sprintf("%d of %d workloads are missing at least one label", len(wkldData)-1, total), true)
+	if len(wkldData) > 1 {
+		if workloadFileName == "" {
+			workloadFileName = fmt.Sprintf("workloader-label-coverage-workloads-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(wkldData, wkldData, workloadFileName)
+	}
+}
+
+// breakdownKeys returns the keys to break down a missing key by
+func breakdownKeys(key string, by, dimensions []string) []string {
+	if len(by) == 0 {
+		by = dimensions
+	}
+	keys := []string{}
+	for _, b := range by {
+		if b != key {
+			keys = append(keys, b)
+		}
+	}
+	return keys
+}
+
+// summaryRow returns a summary output row
+func summaryRow(key, byKey, byValue string, b breakdown) []string {
+	pct := 0.0
+	if b.workloads > 0 {
+		pct = float64(b.missing) / float64(b.workloads) * 100
+	}
+	return []string{key, byKey, byValue, fmt.Sprintf("%d", b.workloads), fmt.Sprintf("%d", b.missing), fmt.Sprintf("%.1f", pct)}
+}
@@ -60,12 +60,25 @@ func exportLabels() {
 	}
 	stdOutData := [][]string{{"href", "key", "value"}}
 
-	// Get all labels
-	labels, a, err := pce.GetLabels(map[string]string{"usage": "true"})
-	utils.LogAPIResp("GetAllLabels", a)
+	// Get all labels. Usage is slow to calculate so get each label key in parallel. PCEs without label dimensions use one request.
+	queries := []map[string]string{{"usage": "true"}}
+	dimensions, a, err := pce.GetLabelDimensions(nil)
+	utils.LogAPIResp("GetLabelDimensions", a)
+	if err == nil && len(dimensions) > 0 {
+		queries = []map[string]string{}
+		for _, d := range dimensions {
+			queries = append(queries, map[string]string{"usage": "true", "key": d.Key})
+		}
+	}
+	pages, err := utils.GetPages(len(queries), func(i int) ([]illumioapi.Label, illumioapi.APIResponse, error) {
+		labels, a, err := pce.GetLabels(queries[i])
+		utils.LogAPIResp("GetAllLabels", a)
+		return labels, a, err
+	})
 	if err != nil {
 		utils.LogError(err.Error())
 	}
+	labels := utils.MergePages(pages)
 
 	// Check our search term
 	newLabels := []illumioapi.Label{}
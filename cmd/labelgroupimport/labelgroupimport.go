@@ -2,7 +2,6 @@ package labelgroupimport
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/brian1917/workloader/cmd/labelgroupexport"
@@ -19,12 +18,6 @@ var provision, updatePCE, noPrompt bool
 var pce illumioapi.PCE
 var err error
 
-// Struct for entries
-type entry struct {
-	csvLine    int
-	labelGroup illumioapi.LabelGroup
-}
-
 func init() {
 	LabelGroupImportCmd.Flags().BoolVarP(&provision, "provision", "p", false, "Provision changes.")
 	LabelGroupImportCmd.Flags().SortFlags = false
@@ -32,8 +25,9 @@ func init() {
 
 // LabelGroupImportCmd runs the upload command
 var LabelGroupImportCmd = &cobra.Command{
-	Use:   "labelgroup-import [csv file to import]",
-	Short: "Create and modify label groups from a CSV file.",
+	Use:         "labelgroup-import [csv file to import]",
+	Short:       "Create and modify label groups from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapObjectWrite},
 	Long: `
 Create and modify label groups from a CSV file.
 
@@ -65,7 +59,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("command requires 1 argument for the csv file. see usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
@@ -94,9 +88,8 @@ func labelGroupImport() {
 		utils.LogError(err.Error())
 	}
 
-	// Start slices to hold the results
-	newLabelGroups := []entry{}
-	updatedLabelGroups := []entry{}
+	// Start the change set to hold the results
+	changes := utils.NewChangeSet[illumioapi.LabelGroup]("label groups")
 
 	// Headers
 	headers := make(map[string]*int)
@@ -170,9 +163,8 @@ CSVEntries:
 				}
 			}
 
-			// Add to the new labelgroup slice
-			newLabelGroups = append(newLabelGroups, entry{csvLine: i + 1, labelGroup: newLG})
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s - will be created.", i+1, line[*headers[labelgroupexport.HeaderName]]), false)
+			// Add to the creates
+			changes.Create(newLG, newLG.Name, i+1)
 
 		} else {
 			// The label group HREF field is present and the value is provided,
@@ -183,25 +175,20 @@ CSVEntries:
 				continue CSVEntries
 			}
 
-			// Set update to false
-			update := false
+			// Keep the current name for logging
+			currentName := pceLabelGroup.Name
+			var fields []utils.FieldChange
 
 			// Name
 			if val, ok := headers[labelgroupexport.HeaderName]; ok {
-				if line[*val] != pceLabelGroup.Name {
-					update = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - the name will change from %s to %s.", i+1, pceLabelGroup.Name, line[*val]), false)
-					pceLabelGroup.Name = line[*val]
-				}
+				fields = append(fields, utils.DiffValue("name", pceLabelGroup.Name, line[*val])...)
+				pceLabelGroup.Name = line[*val]
 			}
 
 			// Description
 			if val, ok := headers[labelgroupexport.HeaderDescription]; ok {
-				if line[*val] != pceLabelGroup.Description {
-					update = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - the description will change from %s to %s.", i+1, pceLabelGroup.Description, line[*val]), false)
-					pceLabelGroup.Description = line[*val]
-				}
+				fields = append(fields, utils.DiffValue("description", pceLabelGroup.Description, line[*val])...)
+				pceLabelGroup.Description = line[*val]
 			}
 
 			// Key
@@ -214,117 +201,67 @@ CSVEntries:
 			}
 
 			// Member labels
-			// Create maps for the labels in the PCE and the labels in the CSV entry
-			// Set the label update to false
-			labelUpdate := false
-			pceLabels := make(map[string]bool)
-			csvLabels := make(map[string]bool)
-
+			var newLabels []*illumioapi.Label
+			for _, l := range pceLabelGroup.Labels {
+				newLabels = append(newLabels, &illumioapi.Label{Href: l.Href})
+			}
 			if val, ok := headers[labelgroupexport.HeaderMemberLabels]; ok && line[*val] != "" {
-
-				// Populate PCE labels
+				pceLabels := []string{}
 				for _, l := range pceLabelGroup.Labels {
-					pceLabels[pce.Labels[l.Href].Value] = true
+					pceLabels = append(pceLabels, pce.Labels[l.Href].Value)
 				}
-				// Populate CSV labels
-				for _, l := range strings.Split(strings.Replace(line[*val], "; ", ";", -1), ";") {
-					csvLabels[l] = true
-				}
-
-				// Check if CSV labels are in the PCE
-				for l := range csvLabels {
-					if !pceLabels[l] {
-						// Check if the label exists
-						if _, check := pce.Labels[key+l]; !check {
-							utils.LogWarning(fmt.Sprintf("csv line %d - %s(%s) does not exist in the PCE as a label. skipping entry.", i+1, l, key), true)
-							continue CSVEntries
-						}
-						labelUpdate = true
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s label is in the CSV but not in the PCE. It will be added.", i+1, l), false)
-					}
-				}
-				// Check if PCE labels are in the CSV
-				for l := range pceLabels {
-					if !csvLabels[l] {
-						labelUpdate = true
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s label is not in the CSV but is in the PCE. It will be removed.", i+1, l), false)
+				csvLabels := strings.Split(strings.Replace(line[*val], "; ", ";", -1), ";")
+				for _, l := range csvLabels {
+					if _, check := pce.Labels[key+l]; !check {
+						utils.LogWarning(fmt.Sprintf("csv line %d - %s(%s) does not exist in the PCE as a label. skipping entry.", i+1, l, key), true)
+						continue CSVEntries
 					}
 				}
-
-				// If we are updating the labels, replace with newLabels
-				var newLabels []*illumioapi.Label
-				if labelUpdate {
-					update = true
-					for l := range csvLabels {
+				if labelChange := utils.DiffSet("member labels", pceLabels, csvLabels); labelChange != nil {
+					fields = append(fields, labelChange...)
+					newLabels = nil
+					for _, l := range csvLabels {
 						newLabels = append(newLabels, &illumioapi.Label{Href: pce.Labels[key+l].Href})
 					}
-					pceLabelGroup.Labels = newLabels
-				} else {
-					for _, l := range pceLabelGroup.Labels {
-						newLabels = append(newLabels, &illumioapi.Label{Href: l.Href})
-					}
-					pceLabelGroup.Labels = newLabels
 				}
 			}
+			pceLabelGroup.Labels = newLabels
 
-			// Member Sub Groups
-			// Create maps for the subgroups in the PCE and the labels in the CSV entry and set sgUpdate to false
-			sgUpdate := false
-			pceSGs := make(map[string]bool)
-			csvSGs := make(map[string]bool)
-
+			// Member sub groups
+			var newSubGroups []*illumioapi.SubGroups
+			for _, sg := range pceLabelGroup.SubGroups {
+				newSubGroups = append(newSubGroups, &illumioapi.SubGroups{Href: sg.Href})
+			}
 			if val, ok := headers[labelgroupexport.HeaderMemberLabelGroups]; ok && line[*val] != "" {
+				pceSGs := []string{}
 				for _, sg := range pceLabelGroup.SubGroups {
-					pceSGs[pce.LabelGroups[sg.Href].Name] = true
-				}
-				for _, sg := range strings.Split(strings.Replace(line[*val], "; ", ";", -1), ";") {
-					csvSGs[sg] = true
+					pceSGs = append(pceSGs, pce.LabelGroups[sg.Href].Name)
 				}
-
-				// Check if CSV groups are in the PCE
-				for sg := range csvSGs {
-					if !pceSGs[sg] {
-						// Check if the group exists
-						if _, check := pce.LabelGroups[key+sg]; !check {
-							utils.LogWarning(fmt.Sprintf("csv line %d - %s(%s) does not exist in the PCE as a label group. skipping entry.", i+1, sg, key), true)
-							continue CSVEntries
-						}
-						sgUpdate = true
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s subgroup is in the CSV but not in the PCE. It will be added.", i+1, sg), false)
+				csvSGs := strings.Split(strings.Replace(line[*val], "; ", ";", -1), ";")
+				for _, sg := range csvSGs {
+					if _, check := pce.LabelGroups[key+sg]; !check {
+						utils.LogWarning(fmt.Sprintf("csv line %d - %s(%s) does not exist in the PCE as a label group. skipping entry.", i+1, sg, key), true)
+						continue CSVEntries
 					}
 				}
-				// Check if PCE labels are in the CSV
-				for sg := range pceSGs {
-					if !csvSGs[sg] {
-						sgUpdate = true
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s subgroup is not in the CSV but is in the PCE. It will be removed.", i+1, sg), false)
+				if sgChange := utils.DiffSet("member label groups", pceSGs, csvSGs); sgChange != nil {
+					fields = append(fields, sgChange...)
+					newSubGroups = nil
+					for _, sg := range csvSGs {
+						newSubGroups = append(newSubGroups, &illumioapi.SubGroups{Href: pce.LabelGroups[key+sg].Href})
 					}
 				}
 			}
-			// If we are updating the sub groups, replace with newSubGroups
-			var newSubGroups []*illumioapi.SubGroups
-			if sgUpdate {
-				update = true
-				for sg := range csvSGs {
-					newSubGroups = append(newSubGroups, &illumioapi.SubGroups{Href: pce.LabelGroups[sg].Href})
-				}
-				pceLabelGroup.SubGroups = newSubGroups
-			} else {
-				for _, sg := range pceLabelGroup.SubGroups {
-					newSubGroups = append(newSubGroups, &illumioapi.SubGroups{Href: sg.Href})
-				}
-				pceLabelGroup.SubGroups = newSubGroups
-			}
+			pceLabelGroup.SubGroups = newSubGroups
 
-			// If update is set to true, add it to the slice
-			if update {
-				updatedLabelGroups = append(updatedLabelGroups, entry{csvLine: i + 1, labelGroup: pceLabelGroup})
-			}
+			// Add to the updates if anything changed
+			changes.Update(pceLabelGroup, currentName, pceLabelGroup.Href, fields, i+1)
 		}
 	}
+	changes.Log()
 
 	// End run if we have nothing to do
-	if len(newLabelGroups) == 0 && len(updatedLabelGroups) == 0 {
+	if changes.Empty() {
 		utils.LogInfo("nothing to be done.", true)
 		utils.LogEndCommand("labelgroup-import")
 		return
@@ -332,7 +269,7 @@ CSVEntries:
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
-		utils.LogInfo(fmt.Sprintf("workloader identified %d label groups to create and %d label groups to update. See workloader.log for all identified changes. To do the import, run again using --update-pce flag", len(newLabelGroups), len(updatedLabelGroups)), true)
+		utils.LogInfo(fmt.Sprintf("workloader identified %s. See workloader.log for all identified changes. To do the import, run again using --update-pce flag", changes.Summary()), true)
 		utils.LogEndCommand("labelgroup-import")
 		return
 	}
@@ -340,11 +277,11 @@ CSVEntries:
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("[PROMPT] - workloader will create %d label groups and update %d label groups in %s (%s). Do you want to run the import (yes/no)? ", len(newLabelGroups), len(updatedLabelGroups), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("[PROMPT] - workloader will create %d label groups and update %d label groups in %s (%s). Do you want to run the import (yes/no)? ", len(changes.Creates), len(changes.Updates), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
-			utils.LogInfo(fmt.Sprintf("prompt denied for creating %d label groups and updating %d label groups.", len(newLabelGroups), len(updatedLabelGroups)), true)
+			utils.LogInfo(fmt.Sprintf("prompt denied for creating %d label groups and updating %d label groups.", len(changes.Creates), len(changes.Updates)), true)
 			utils.LogEndCommand("labelgroup-import")
 			return
 		}
@@ -355,41 +292,41 @@ CSVEntries:
 	updatedLGs := 0
 	provisionableLGs := []string{}
 	// Create Label Groups
-	for _, newLG := range newLabelGroups {
-		lg, a, err := pce.CreateLabelGroup(newLG.labelGroup)
+	for _, newLG := range changes.Creates {
+		lg, a, err := pce.CreateLabelGroup(newLG.Object)
 		utils.LogAPIResp("CreateLabelGroup", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("ending run - %d label groups created - %d label groups updated.", createdLGs, updatedLGs))
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 Not Acceptable - See workloader.log for more details", newLG.csvLine, newLG.labelGroup.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 Not Acceptable - See workloader.log for more details", newLG.CSVLines[0], newLG.Object.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skipped++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s created - status code %d", newLG.csvLine, lg.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line %d - %s created - status code %d", newLG.CSVLines[0], lg.Name, a.StatusCode), true)
 			createdLGs++
 			provisionableLGs = append(provisionableLGs, lg.Href)
 		}
 	}
 
 	// Update Label Groups
-	for _, updateLG := range updatedLabelGroups {
-		a, err := pce.UpdateLabelGroup(updateLG.labelGroup)
+	for _, updateLG := range changes.Updates {
+		a, err := pce.UpdateLabelGroup(updateLG.Object)
 		utils.LogAPIResp("UpdateLabelGroup", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("ending run - %d label groups created - %d label groups updated.", createdLGs, updatedLGs))
 			utils.LogError(err.Error())
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 Not Acceptable - See workloader.log for more details", updateLG.csvLine, updateLG.labelGroup.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s - 406 Not Acceptable - See workloader.log for more details", updateLG.CSVLines[0], updateLG.Object.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skipped++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s updated - status code %d", updateLG.csvLine, updateLG.labelGroup.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line %d - %s updated - status code %d", updateLG.CSVLines[0], updateLG.Object.Name, a.StatusCode), true)
 			updatedLGs++
-			provisionableLGs = append(provisionableLGs, updateLG.labelGroup.Href)
+			provisionableLGs = append(provisionableLGs, updateLG.Href)
 		}
 	}
 
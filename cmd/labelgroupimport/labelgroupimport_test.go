@@ -0,0 +1,51 @@
+package labelgroupimport
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+// TestLabelGroupImportChanges checks only label groups with changed values are sent to the pce. Member order is ignored.
+func TestLabelGroupImportChanges(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Add("sec_policy/draft/label_groups",
+		map[string]interface{}{"href": "/orgs/1/sec_policy/draft/label_groups/1", "name": "All Envs", "key": "env", "labels": []interface{}{map[string]interface{}{"href": "/orgs/1/labels/4"}, map[string]interface{}{"href": "/orgs/1/labels/5"}}},
+		map[string]interface{}{"href": "/orgs/1/sec_policy/draft/label_groups/2", "name": "Tiers", "key": "role", "labels": []interface{}{map[string]interface{}{"href": "/orgs/1/labels/1"}}},
+	)
+	dir := s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	csvFile = filepath.Join(dir, "labelgroups.csv")
+	csv := "href,name,key,description,member_labels\n" +
+		"/orgs/1/sec_policy/draft/label_groups/1,All Envs,env,,DEV;PROD\n" +
+		"/orgs/1/sec_policy/draft/label_groups/2,Tiers,role,,WEB;DB\n" +
+		",Apps,app,,ORDERING\n"
+	if err := os.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if pce, err = utils.GetTargetPCE(true); err != nil {
+		t.Fatal(err)
+	}
+	updatePCE, noPrompt, provision = true, true, false
+	labelGroupImport()
+
+	writes := []string{}
+	for _, r := range s.Writes() {
+		writes = append(writes, r.Method+" "+r.Path)
+	}
+	want := []string{"POST /orgs/1/sec_policy/draft/label_groups", "PUT /orgs/1/sec_policy/draft/label_groups/2"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("writes are %v, want %v", writes, want)
+	}
+	if tiers, _ := s.Object("/orgs/1/sec_policy/draft/label_groups/2"); len(tiers["labels"].([]interface{})) != 2 {
+		t.Errorf("tiers members are %v, want WEB and DB", tiers["labels"])
+	}
+}
@@ -33,8 +33,9 @@ func init() {}
 
 // IplImportCmd runs the iplist import command
 var LabelImportCmd = &cobra.Command{
-	Use:   "label-import [csv file to import]",
-	Short: "Create and update labels from a CSV.",
+	Use:         "label-import [csv file to import]",
+	Short:       "Create and update labels from a CSV.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapObjectWrite},
 	Long: `
 Create and update labels from a CSV file. 
 
@@ -59,7 +60,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
@@ -83,7 +84,7 @@ func ImportLabels(pce illumioapi.PCE, inputFile string, updatePCE, noPrompt bool
 	utils.LogStartCommand("label-import")
 
 	// Open CSV File
-	file, err := os.Open(csvFile)
+	file, err := os.Open(inputFile)
 	if err != nil {
 		utils.LogError(err.Error())
 	}
@@ -209,7 +210,7 @@ func ImportLabels(pce illumioapi.PCE, inputFile string, updatePCE, noPrompt bool
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("[PROMPT] - workloader will create %d labels and update %d labels in %s (%s). Do you want to run the import (yes/no)? ", len(labelsToCreate), len(labelsToUpdate), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("[PROMPT] - workloader will create %d labels and update %d labels in %s (%s). Do you want to run the import (yes/no)? ", len(labelsToCreate), len(labelsToUpdate), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
@@ -231,6 +232,7 @@ func ImportLabels(pce illumioapi.PCE, inputFile string, updatePCE, noPrompt bool
 		if a.StatusCode == 406 {
 			utils.LogWarning(fmt.Sprintf("csv line %d - %s (%s) - 406 Not Acceptable - See workloader.log for more details", newLabel.csvLine, newLabel.label.Value, newLabel.label.Key), true)
 			utils.LogWarning(a.RespBody, false)
+			utils.RecordFailure(fmt.Sprintf("csv line %d - %s (%s) - 406 Not Acceptable", newLabel.csvLine, newLabel.label.Value, newLabel.label.Key))
 			skippedLabels++
 		}
 		if err == nil {
@@ -249,6 +251,7 @@ func ImportLabels(pce illumioapi.PCE, inputFile string, updatePCE, noPrompt bool
 		if a.StatusCode == 406 {
 			utils.LogWarning(fmt.Sprintf("csv line %d - %s (%s) - 406 Not Acceptable - See workloader.log for more details", updateLabel.csvLine, updateLabel.label.Value, updateLabel.label.Key), true)
 			utils.LogWarning(a.RespBody, false)
+			utils.RecordFailure(fmt.Sprintf("csv line %d - %s (%s) - 406 Not Acceptable", updateLabel.csvLine, updateLabel.label.Value, updateLabel.label.Key))
 			skippedLabels++
 		}
 		if err == nil {
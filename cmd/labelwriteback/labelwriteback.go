@@ -0,0 +1,258 @@
+package labelwriteback
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/awssync"
+	"github.com/brian1917/workloader/cmd/azuresync"
+	"github.com/brian1917/workloader/cmd/gcpsync"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var cloud, labelKeys, tagPrefix, externalDataSet, awsProfile, roleArns, externalID, tenantID, clientID, clientSecret, keyFile, outputFileName string
+var updateCloud, noPrompt bool
+var err error
+
+func init() {
+	LabelWritebackCmd.Flags().StringVar(&cloud, "cloud", "", "cloud of the unmanaged workloads: aws, azure, or gcp.")
+	LabelWritebackCmd.Flags().StringVar(&labelKeys, "label-keys", "", "comma-separated list of label keys to write. default is all label keys.")
+	LabelWritebackCmd.Flags().StringVar(&tagPrefix, "tag-prefix", "illumio-", "prefix for the tag key. the tag key is the prefix and the label key (e.g., illumio-app).")
+	LabelWritebackCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "", "external data set of the unmanaged workloads. default is the sync command of the cloud (e.g., aws-sync).")
+	LabelWritebackCmd.Flags().StringVar(&awsProfile, "aws-profile", "", "aws profile. see aws-sync.")
+	LabelWritebackCmd.Flags().StringVar(&roleArns, "role-arns", "", "comma-separated list of aws iam role arns to assume for each account. see aws-sync.")
+	LabelWritebackCmd.Flags().StringVar(&externalID, "external-id", "", "external id used when assuming aws roles.")
+	LabelWritebackCmd.Flags().StringVar(&tenantID, "tenant-id", "", "azure ad tenant id. default is the AZURE_TENANT_ID environment variable.")
+	LabelWritebackCmd.Flags().StringVar(&clientID, "client-id", "", "azure service principal client id. default is the AZURE_CLIENT_ID environment variable.")
+	LabelWritebackCmd.Flags().StringVar(&clientSecret, "client-secret", "", "azure service principal client secret. default is the AZURE_CLIENT_SECRET environment variable.")
+	LabelWritebackCmd.Flags().StringVarP(&keyFile, "key-file", "k", "", "gcp service account json key file. see gcp-sync.")
+	LabelWritebackCmd.Flags().BoolVar(&updateCloud, "update-cloud", false, "write the tags to the cloud. default only logs the tags to write.")
+	LabelWritebackCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	LabelWritebackCmd.Flags().SortFlags = false
+}
+
+// LabelWritebackCmd runs the label-writeback command
+var LabelWritebackCmd = &cobra.Command{
+	Use:   "label-writeback",
+	Short: "Write Illumio labels to AWS, Azure, or GCP instance tags.",
+	Long: `
+Write Illumio labels to AWS, Azure, or GCP instance tags.
+
+Labels of the unmanaged workloads created by aws-sync, azure-sync, or gcp-sync are written to the corresponding instance as tags (labels in gcp) so cloud cost and operations tools can use the segmentation labels. The instance is identified from the unmanaged workload data written by the sync command:
+- aws: the instance id (external data reference), the region from the availability zone (data center), and the account from the description. Requires ec2:CreateTags.
+- azure: the resource id from the description. Requires the Tag Contributor role.
+- gcp: the instance name and project from the description and the zone (data center). Requires compute.instances.setLabels.
+
+Existing tags are kept. Tags are only added or overwritten; a tag is not removed when a workload does not have the label. GCP label keys and values are lowercase and characters other than letters, numbers, underscores, and dashes are replaced with underscores.
+
+The --update-pce flag is ignored. Use --update-cloud to write the tags.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if cloud != "aws" && cloud != "azure" && cloud != "gcp" {
+			utils.LogError("--cloud must be aws, azure, or gcp")
+		}
+		if externalDataSet == "" {
+			externalDataSet = cloud + "-sync"
+		}
+		if tenantID == "" {
+			tenantID = os.Getenv("AZURE_TENANT_ID")
+		}
+		if clientID == "" {
+			clientID = os.Getenv("AZURE_CLIENT_ID")
+		}
+		if clientSecret == "" {
+			clientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+		}
+
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		labelWriteback()
+	},
+}
+
+// target is a cloud instance and the tags to write
+type target struct {
+	wkld     illumioapi.Workload
+	resource string // instance id, azure resource id, or gcp project/zone/name
+	account  string
+	region   string
+	project  string
+	zone     string
+	name     string
+	tags     map[string]string
+}
+
+var awsRegion = regexp.MustCompile(`^([a-z]{2}(-gov)?-[a-z]+-\d+)`)
+
+func labelWriteback() {
+
+	utils.LogStartCommand("label-writeback")
+
+	// Get the unmanaged workloads of the sync command
+	wklds, api, err := pce.GetWklds(map[string]string{"managed": "false", "external_data_set": externalDataSet})
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d unmanaged workloads in the %s external data set", len(wklds), externalDataSet), true)
+
+	keyFilter := make(map[string]bool)
+	for _, k := range strings.Split(labelKeys, ",") {
+		if strings.TrimSpace(k) != "" {
+			keyFilter[strings.TrimSpace(k)] = true
+		}
+	}
+
+	targets := []target{}
+	data := [][]string{{wkldexport.HeaderHostname, wkldexport.HeaderHref, "cloud", "resource", "tags"}}
+	for _, w := range wklds {
+		t := target{wkld: w, tags: make(map[string]string)}
+		if w.Labels != nil {
+			for _, l := range *w.Labels {
+				label := pce.Labels[l.Href]
+				if len(keyFilter) > 0 && !keyFilter[label.Key] {
+					continue
+				}
+				key, value := tagPrefix+label.Key, label.Value
+				if cloud == "gcp" {
+					key, value = gcpLabel(key), gcpLabel(value)
+				}
+				t.tags[key] = value
+			}
+		}
+		if len(t.tags) == 0 {
+			continue
+		}
+
+		// Identify the instance
+		description := utils.PtrToStr(w.Description)
+		switch cloud {
+		case "aws":
+			t.resource = utils.PtrToStr(w.ExternalDataReference)
+			fmt.Sscanf(description, "aws instance %s in account %s", &t.name, &t.account)
+			t.region = awsRegion.FindString(utils.PtrToStr(w.DataCenter))
+			if t.region == "" {
+				utils.LogWarning(fmt.Sprintf("%s - %s - cannot get the aws region from the data center %s. skipping.", w.Hostname, w.Href, utils.PtrToStr(w.DataCenter)), true)
+				continue
+			}
+		case "azure":
+			t.resource = strings.TrimPrefix(description, "azure vm ")
+			if !strings.HasPrefix(t.resource, "/subscriptions/") {
+				utils.LogWarning(fmt.Sprintf("%s - %s - cannot get the azure resource id from the description. skipping.", w.Hostname, w.Href), true)
+				continue
+			}
+		case "gcp":
+			fmt.Sscanf(description, "gcp instance %s in project %s", &t.name, &t.project)
+			t.zone = utils.PtrToStr(w.DataCenter)
+			if t.name == "" || t.project == "" || t.zone == "" {
+				utils.LogWarning(fmt.Sprintf("%s - %s - cannot get the gcp instance from the description and data center. skipping.", w.Hostname, w.Href), true)
+				continue
+			}
+			t.resource = fmt.Sprintf("%s/%s/%s", t.project, t.zone, t.name)
+		}
+		targets = append(targets, t)
+		data = append(data, []string{w.Hostname, w.Href, cloud, t.resource, tagString(t.tags)})
+	}
+
+	if len(targets) == 0 {
+		utils.LogInfo("no unmanaged workloads with labels to write.", true)
+		utils.LogEndCommand("label-writeback")
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-label-writeback-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d %s instances to tag.", len(targets), cloud), true)
+
+	if !updateCloud {
+		utils.LogInfo("see the output file for the tags. to write the tags, run again using --update-cloud flag.", true)
+		utils.LogEndCommand("label-writeback")
+		return
+	}
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will write tags to %d %s instances. do you want to run the update (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(targets), cloud)
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			utils.LogEndCommand("label-writeback")
+			return
+		}
+	}
+
+	// Write the tags
+	var write func(t target) error
+	switch cloud {
+	case "aws":
+		writer, err := awssync.NewTagWriter(awsProfile, roleArns, externalID)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		write = func(t target) error { return writer.CreateTags(t.account, t.region, t.resource, t.tags) }
+	case "azure":
+		writer, err := azuresync.NewTagWriter(tenantID, clientID, clientSecret)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		write = func(t target) error { return writer.MergeTags(t.resource, t.tags) }
+	case "gcp":
+		writer, err := gcpsync.NewLabelWriter(keyFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		write = func(t target) error {
+			_, err := writer.SetLabels(t.project, t.zone, t.name, t.tags)
+			return err
+		}
+	}
+	errors := 0
+	for _, t := range targets {
+		if err := write(t); err != nil {
+			errors++
+			utils.LogWarning(fmt.Sprintf("%s - %s - %s", t.wkld.Hostname, t.resource, err), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("tagged %s - %s - %s", t.wkld.Hostname, t.resource, tagString(t.tags)), false)
+	}
+	utils.LogInfo(fmt.Sprintf("tagged %d instances. %d errors.", len(targets)-errors, errors), true)
+
+	utils.LogEndCommand("label-writeback")
+}
+
+// tagString returns the tags in the format key=value;key=value
+func tagString(tags map[string]string) string {
+	s := []string{}
+	for k, v := range tags {
+		s = append(s, k+"="+v)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ";")
+}
+
+var gcpInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// gcpLabel converts a string to a valid gcp label key or value
+func gcpLabel(s string) string {
+	s = gcpInvalid.ReplaceAllString(strings.ToLower(s), "_")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
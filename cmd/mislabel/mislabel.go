@@ -18,6 +18,8 @@ import (
 var appFlag, exclWkldFile, exclPortFile, exclAppFile, outputFileName string
 var debug, ignoreLoc, inclUnmanagedAppGroups bool
 var pce illumioapi.PCE
+var hec utils.HECConfig
+var jira utils.JiraInput
 var err error
 
 func init() {
@@ -29,6 +31,15 @@ func init() {
 	MisLabelCmd.Flags().BoolVar(&ignoreLoc, "ignore-location", false, "Do not use location in comparing app groups.")
 	MisLabelCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
+	MisLabelCmd.Flags().StringVar(&hec.URL, "splunk-hec-url", "", "splunk http event collector url (e.g., https://splunk.company.com:8088) to send each output row to as an event.")
+	MisLabelCmd.Flags().StringVar(&hec.Token, "splunk-hec-token", "", "splunk http event collector token. default is the SPLUNK_HEC_TOKEN environment variable.")
+	MisLabelCmd.Flags().StringVar(&hec.SourceType, "splunk-sourcetype", "workloader:mislabel", "sourcetype for splunk events.")
+	MisLabelCmd.Flags().StringVar(&hec.Index, "splunk-index", "", "splunk index for events. default is the index of the token.")
+	MisLabelCmd.Flags().BoolVar(&hec.Insecure, "splunk-insecure", false, "do not validate the splunk certificate.")
+	MisLabelCmd.Flags().BoolVar(&jira.Enabled, "jira", false, "create a jira issue for each finding. findings with an unresolved issue are skipped. requires jira_url and jira_token in pce.yaml or WORKLOADER_JIRA_ environment variables.")
+	MisLabelCmd.Flags().StringVar(&jira.Project, "jira-project", "", "jira project key. default is jira_project in pce.yaml or the WORKLOADER_JIRA_PROJECT environment variable.")
+	MisLabelCmd.Flags().StringVar(&jira.GroupBy, "jira-group-by", "", "output header to create one issue per group instead of per finding (e.g., app).")
+	MisLabelCmd.Flags().StringVar(&jira.Summary, "jira-summary", "", "go template for the issue summary using output headers as fields (e.g., {{.hostname}}). grouped issues also have {{.group}} and {{.count}}.")
 	MisLabelCmd.Flags().SortFlags = false
 }
 
@@ -271,6 +282,16 @@ func misLabel() {
 			outputFileName = fmt.Sprintf("workloader-mislabel-%s.csv", time.Now().Format("20060102_150405"))
 		}
 		utils.WriteOutput(data, data, outputFileName)
+		if hec.URL != "" {
+			if err := utils.SendHEC(hec, "mislabel", data); err != nil {
+				utils.LogWarning(fmt.Sprintf("sending to splunk hec - %s", err), true)
+			}
+		}
+		if jira.Enabled {
+			if err := utils.FileJiraIssues(jira, "mislabel", data, []string{"hostname"}, "potentially mislabeled workload {{.hostname}}", "{{.count}} potentially mislabeled workloads - {{.group}}"); err != nil {
+				utils.LogWarning(fmt.Sprintf("creating jira issues - %s", err), true)
+			}
+		}
 		utils.LogInfo(fmt.Sprintf("%d potentially mislabeled workloads detected.", len(data)-1), true)
 	} else {
 		// Log if we don't find any
@@ -0,0 +1,136 @@
+package mode
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// parseCanary returns the canary percent or count from --canary (e.g., 10% or 5)
+func parseCanary(s string) (percent float64, count int, err error) {
+	if strings.HasSuffix(s, "%") {
+		percent, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return 0, 0, fmt.Errorf("--canary percent must be more than 0%% and less than 100%% - %s", s)
+		}
+		return percent, 0, nil
+	}
+	count, err = strconv.Atoi(s)
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("--canary must be a percent (e.g., 10%%) or a number of workloads - %s", s)
+	}
+	return 0, count, nil
+}
+
+// selectCanary returns a random subset of the workloads to change first. With --canary-by, the percent is taken from each group of
+// workloads with the same label values so every group has at least one canary workload.
+func selectCanary(wklds []illumioapi.Workload) map[string]bool {
+	percent, count, _ := parseCanary(canary)
+	r := rand.New(rand.NewSource(canarySeed))
+
+	// Group the workloads
+	groups := make(map[string][]illumioapi.Workload)
+	groupNames := []string{}
+	for _, w := range wklds {
+		values := []string{}
+		for _, key := range strings.Split(canaryBy, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				values = append(values, w.GetLabelByKey(key, pce.Labels).Value)
+			}
+		}
+		group := strings.Join(values, " | ")
+		if _, ok := groups[group]; !ok {
+			groupNames = append(groupNames, group)
+		}
+		groups[group] = append(groups[group], w)
+	}
+	sort.Strings(groupNames)
+
+	selected := make(map[string]bool)
+	for _, g := range groupNames {
+		members := groups[g]
+		r.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+		n := count
+		if percent > 0 {
+			n = int(math.Ceil(float64(len(members)) * percent / 100))
+		}
+		if n > len(members) {
+			n = len(members)
+		}
+		for _, w := range members[:n] {
+			selected[w.Href] = true
+		}
+	}
+	return selected
+}
+
+// soak checks the traffic of the canary workloads every --check-interval until --soak is over. It returns the blocked and potentially
+// blocked flows to and from the canary workloads and false if there are more than --max-blocked.
+func soak(canaryWklds map[string]bool, changed time.Time) ([]illumioapi.TrafficAnalysis, bool) {
+	end := changed.Add(soakPeriod)
+	utils.LogInfo(fmt.Sprintf("soaking %d canary workloads until %s. checking for blocked and potentially blocked flows every %s.", len(canaryWklds), end.Format("2006-01-02 15:04:05"), checkInterval), true)
+	var flows []illumioapi.TrafficAnalysis
+	for {
+		wait := checkInterval
+		if remaining := time.Until(end); remaining < wait {
+			wait = remaining
+		}
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+
+		traffic, a, err := pce.GetTrafficAnalysis(illumioapi.TrafficQuery{
+			StartTime:            changed,
+			EndTime:              time.Now(),
+			PolicyStatuses:       []string{"blocked", "potentially_blocked"},
+			MaxFLows:             100000,
+			TransmissionExcludes: []string{"broadcast", "multicast"}})
+		utils.LogAPIResp("GetTrafficAnalysis", a)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("checking canary traffic - %s", err), true)
+		}
+		flows = nil
+		for _, t := range traffic {
+			if (t.Src != nil && t.Src.Workload != nil && canaryWklds[t.Src.Workload.Href]) || (t.Dst != nil && t.Dst.Workload != nil && canaryWklds[t.Dst.Workload.Href]) {
+				flows = append(flows, t)
+			}
+		}
+		utils.LogInfo(fmt.Sprintf("%d blocked or potentially blocked flows to and from canary workloads since the change", len(flows)), true)
+		if len(flows) > maxBlocked {
+			return flows, false
+		}
+		if !time.Now().Before(end) {
+			return flows, true
+		}
+	}
+}
+
+// writeCanaryFlows writes the blocked and potentially blocked flows that halted the rollout
+func writeCanaryFlows(flows []illumioapi.TrafficAnalysis) {
+	protocols := illumioapi.ProtocolList()
+	data := [][]string{{"src_ip", "src_hostname", "dst_ip", "dst_hostname", "port", "protocol", "policy_decision", "connections", "last_seen"}}
+	for _, t := range flows {
+		srcHost, dstHost, port, proto, lastSeen := "", "", "", "", ""
+		if t.Src.Workload != nil {
+			srcHost = t.Src.Workload.Hostname
+		}
+		if t.Dst.Workload != nil {
+			dstHost = t.Dst.Workload.Hostname
+		}
+		if t.ExpSrv != nil {
+			port, proto = strconv.Itoa(t.ExpSrv.Port), protocols[t.ExpSrv.Proto]
+		}
+		if t.TimestampRange != nil {
+			lastSeen = t.TimestampRange.LastDetected
+		}
+		data = append(data, []string{t.Src.IP, srcHost, t.Dst.IP, dstHost, port, proto, t.PolicyDecision, strconv.Itoa(t.NumConnections), lastSeen})
+	}
+	utils.WriteOutput(data, data, fmt.Sprintf("workloader-mode-canary-flows-%s.csv", time.Now().Format("20060102_150405")))
+}
@@ -23,20 +23,32 @@ const (
 )
 
 // Set global variables for flags
-var csvFile string
-var useIndividualAPI, legacyPCE, updatePCE, noPrompt bool
+var csvFile, canary, canaryBy string
+var useIndividualAPI, legacyPCE, updatePCE, noPrompt, rollback bool
+var soakPeriod, checkInterval time.Duration
+var maxBlocked int
+var canarySeed int64
 var pce illumioapi.PCE
 var err error
 
 // Init handles flags
 func init() {
 	ModeCmd.Flags().BoolVarP(&useIndividualAPI, "individual-api", "i", false, "Use individual API calls getting workloads from the PCE. This will save time for PCEs with large number of workloads when a small amount is being changed.")
+	ModeCmd.Flags().StringVar(&canary, "canary", "", "Change a random subset of the workloads first as a percent (e.g., 10%) or a number of workloads and soak before changing the rest.")
+	ModeCmd.Flags().StringVar(&canaryBy, "canary-by", "", "Comma-separated label keys to select the canary percent from each group of workloads with the same labels (e.g., app,env).")
+	ModeCmd.Flags().DurationVar(&soakPeriod, "soak", time.Hour, "Time to check the canary workloads before changing the rest (e.g., 30m or 4h).")
+	ModeCmd.Flags().DurationVar(&checkInterval, "check-interval", 5*time.Minute, "Time between traffic checks during the soak.")
+	ModeCmd.Flags().IntVar(&maxBlocked, "max-blocked", 0, "Halt the rollout if the canary workloads have more than this many blocked or potentially blocked flows.")
+	ModeCmd.Flags().BoolVar(&rollback, "rollback", false, "Change the canary workloads back to their previous modes if the rollout halts.")
+	ModeCmd.Flags().Int64Var(&canarySeed, "canary-seed", 1, "Seed for the random canary selection. The same seed and input select the same canary workloads.")
+	ModeCmd.Flags().SortFlags = false
 }
 
 // ModeCmd runs the hostname parser
 var ModeCmd = &cobra.Command{
-	Use:   "mode [csv file with mode info]",
-	Short: "Change the state of workloads based on a CSV input.",
+	Use:         "mode [csv file with mode info]",
+	Short:       "Change the state of workloads based on a CSV input.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true", utils.AnnotationCapabilities: utils.CapWorkloadWrite},
 	Long: `
 Change a workload's state based on an input CSV with at least two columns: workload href and desired state.
 
@@ -46,7 +58,16 @@ PCE versions 20.x or more recent can optionally leverage the new workload proper
  
 CSV input should have at least two columns: href and enforcement.  A third column for visibility is optional. Additional columns will be ignored
  
-VENs can accept the following enforcement values: idle, visibility_only, selective, or full.  When setting VEN enforcement to visibility_only the default condition is blocked_allowed. VENs accept the following optional visibility values: off, blocked, blocked_allowed.`,
+VENs can accept the following enforcement values: idle, visibility_only, selective, or full.  When setting VEN enforcement to visibility_only the default condition is blocked_allowed. VENs accept the following optional visibility values: off, blocked, blocked_allowed.
+
+Use --canary for a staged rollout. A random subset of the workloads to change (e.g., 10% or 5 workloads) is changed first. With --canary-by, the percent is selected from each group of workloads with the same labels (e.g., each app and env) so every group is in the canary. The same --canary-seed and input select the same canary workloads, so the output without --update-pce shows the canary workloads that will be changed first.
+
+After the canary workloads are changed, the traffic to and from them is checked every --check-interval for the --soak period. If there are more than --max-blocked blocked or potentially blocked flows, the rollout halts: the rest of the workloads are not changed, the flows are written to a csv, the canary workloads are changed back with --rollback, and the command exits with 3 (partial_failure). Otherwise the rest of the workloads are changed.`,
+	Example: `# Change modes from a csv
+  workloader mode input.csv --update-pce
+
+  # Change 10% of each app and env first, soak for 2 hours, and change the canary back if flows are blocked
+  workloader mode input.csv --canary 10% --canary-by app,env --soak 2h --rollback --update-pce`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		pce, err = utils.GetTargetPCE(true)
@@ -57,7 +78,7 @@ VENs can accept the following enforcement values: idle, visibility_only, selecti
 		// Set the hostfile
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
@@ -65,6 +86,20 @@ VENs can accept the following enforcement values: idle, visibility_only, selecti
 		updatePCE = viper.Get("update_pce").(bool)
 		noPrompt = viper.Get("no_prompt").(bool)
 
+		// Validate the canary flags
+		if canary != "" {
+			percent, _, err := parseCanary(canary)
+			if err != nil {
+				utils.LogErrorCode(utils.ExitValidation, err.Error())
+			}
+			if canaryBy != "" && percent == 0 {
+				utils.LogErrorCode(utils.ExitValidation, "--canary-by requires a --canary percent (e.g., 10%)")
+			}
+			if soakPeriod < 0 || checkInterval <= 0 || maxBlocked < 0 {
+				utils.LogErrorCode(utils.ExitValidation, "--soak and --max-blocked cannot be negative and --check-interval must be positive")
+			}
+		}
+
 		modeUpdate()
 	},
 }
@@ -177,16 +212,17 @@ func modeUpdate() {
 	var a illumioapi.APIResponse
 
 	if useIndividualAPI {
-		for i, t := range targets {
+		progress := utils.NewProgress("getting workloads from the pce", len(targets))
+		for _, t := range targets {
 			w, a, err := pce.GetWkldByHref(t.href)
 			utils.LogAPIResp("GetWkldByHref", a)
 			if err != nil {
 				utils.LogError(err.Error())
 			}
-			fmt.Printf("\r%s [INFO] - Getting %d of %d workloads (%d%%) from PCE.", time.Now().Format("2006-01-02 15:04:05 "), i+1, len(targets), (i+1)*100/len(targets))
+			progress.Increment()
 			wklds = append(wklds, w)
 		}
-		fmt.Println()
+		progress.Done()
 	} else {
 		var qp = (map[string]string{"managed": "true"})
 		utils.LogInfo("Getting all managed workloads from the PCE. For large deployments and limited number of mode changes, it might be quicker to use the -i flag to run individual API calls to get just workloads that will be changed.", true)
@@ -213,14 +249,23 @@ func modeUpdate() {
 	// Enforcement switch is false unless we are moving a workload into enforcement
 	enforceCount := 0
 
+	// Keep the previous enforcement and visibility for a canary rollback
+	previousModes := make(map[string][2]string)
+
 	// Cycle through each entry in the CSV
 	for _, t := range targets {
 
+		// Skip workloads that are not in the hrefs file
+		if w, ok := wkldMap[t.href]; ok && !utils.WorkloadInHrefsFile(w) {
+			continue
+		}
+
 		// Check if the mode matches the target mode
 		if w, ok := wkldMap[t.href]; ok {
 			update := false
 			currentEnforcement := ""
 			currentVisibility := ""
+			previousModes[w.Href] = [2]string{w.GetMode(), w.GetVisibilityLevel()}
 			// Enforcement
 			if w.GetMode() != t.enforcement && t.enforcement != "" {
 				utils.LogInfo(fmt.Sprintf("required change - %s - current enforcement: %s - desired enforcement: %s", w.Hostname, w.GetMode(), t.enforcement), false)
@@ -253,6 +298,21 @@ func modeUpdate() {
 		}
 	}
 
+	// Select the canary workloads
+	canaryWklds := make(map[string]bool)
+	if canary != "" && len(workloadUpdates) > 0 {
+		canaryWklds = selectCanary(workloadUpdates)
+		data[0] = append(data[0], "rollout")
+		for i := 1; i < len(data); i++ {
+			if canaryWklds[data[i][1]] {
+				data[i] = append(data[i], "canary")
+			} else {
+				data[i] = append(data[i], "remainder")
+			}
+		}
+		utils.LogInfo(fmt.Sprintf("%d canary workloads selected to change first.", len(canaryWklds)), true)
+	}
+
 	// Process output
 	if len(workloadUpdates) == 0 {
 		fmt.Println("0 workloads requiring state update.")
@@ -264,7 +324,7 @@ func modeUpdate() {
 
 		// If updatePCE is disabled, we are just going to alert the user what will happen and log
 		if !updatePCE {
-			utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring mode change in %s (%s). To update their modes, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(data)-1, pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string)), true)
+			utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring mode change in %s (%s). To update their modes, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(data)-1, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName)), true)
 			utils.LogEndCommand("mode")
 			return
 		}
@@ -294,21 +354,13 @@ func modeUpdate() {
 		}
 
 		// If we get here, user accepted prompt or no-prompt was set.
-		api, err := pce.BulkWorkload(workloadUpdates, "update", true)
-		for _, a := range api {
-			utils.LogAPIResp("BulkWorkloadUpdate", a)
-			for _, w := range a.Warnings {
-				utils.LogWarning(w, true)
+		if canary != "" {
+			if !canaryRollout(workloadUpdates, canaryWklds, previousModes) {
+				utils.LogEndCommand("mode")
+				return
 			}
-		}
-		if err != nil {
-			utils.LogError(fmt.Sprintf("running bulk update - %s", err))
-		}
-		// Log successful run.
-		utils.LogInfo(fmt.Sprintf("bulk updated %d workloads. API Responses:", len(workloadUpdates)), false)
-		for _, a := range api {
-			utils.LogInfo(a.RespBody, false)
-
+		} else {
+			bulkUpdate(workloadUpdates)
 		}
 	}
 
@@ -316,3 +368,73 @@ func modeUpdate() {
 	utils.LogInfo(fmt.Sprintf("%d workloads mode updated. See workloader.log for details.", len(workloadUpdates)), true)
 	utils.LogEndCommand("mode")
 }
+
+// bulkUpdate updates the workloads
+func bulkUpdate(workloadUpdates []illumioapi.Workload) {
+	api, err := pce.BulkWorkload(workloadUpdates, "update", true)
+	for _, a := range api {
+		utils.LogAPIResp("BulkWorkloadUpdate", a)
+		for _, w := range a.Warnings {
+			utils.LogWarning(w, true)
+		}
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("running bulk update - %s", err))
+	}
+	// Log successful run.
+	utils.LogInfo(fmt.Sprintf("bulk updated %d workloads. API Responses:", len(workloadUpdates)), false)
+	for _, a := range api {
+		utils.LogInfo(a.RespBody, false)
+
+	}
+}
+
+// canaryRollout changes the canary workloads, soaks, and changes the rest. It returns false if the rollout halted.
+func canaryRollout(workloadUpdates []illumioapi.Workload, canaryWklds map[string]bool, previousModes map[string][2]string) bool {
+	canaryUpdates, remainder := []illumioapi.Workload{}, []illumioapi.Workload{}
+	for _, w := range workloadUpdates {
+		if canaryWklds[w.Href] {
+			canaryUpdates = append(canaryUpdates, w)
+		} else {
+			remainder = append(remainder, w)
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("changing %d canary workloads.", len(canaryUpdates)), true)
+	changed := time.Now()
+	bulkUpdate(canaryUpdates)
+
+	flows, ok := soak(canaryWklds, changed)
+	if !ok {
+		msg := fmt.Sprintf("canary rollout halted - %d blocked or potentially blocked flows to and from canary workloads is more than --max-blocked %d. %d workloads were not changed.", len(flows), maxBlocked, len(remainder))
+		utils.LogWarning(msg, true)
+		utils.RecordFailure(msg)
+		writeCanaryFlows(flows)
+		if rollback {
+			rollbacks := []illumioapi.Workload{}
+			for _, w := range canaryUpdates {
+				previous := previousModes[w.Href]
+				if err := w.SetMode(previous[0]); err != nil {
+					utils.LogWarning(fmt.Sprintf("%s - rolling back enforcement - %s", w.Hostname, err), true)
+					continue
+				}
+				if !legacyPCE && previous[1] != "" {
+					if err := w.SetVisibilityLevel(previous[1]); err != nil {
+						utils.LogWarning(fmt.Sprintf("%s - rolling back visibility - %s", w.Hostname, err), true)
+						continue
+					}
+				}
+				rollbacks = append(rollbacks, w)
+			}
+			utils.LogInfo(fmt.Sprintf("rolling back %d canary workloads to their previous modes.", len(rollbacks)), true)
+			bulkUpdate(rollbacks)
+		}
+		return false
+	}
+
+	utils.LogInfo(fmt.Sprintf("canary soak passed. changing the remaining %d workloads.", len(remainder)), true)
+	if len(remainder) > 0 {
+		bulkUpdate(remainder)
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package netscalersync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/ns"
+	"github.com/brian1917/workloader/utils"
+)
+
+// backend is a real server behind a netscaler virtual server
+type backend struct {
+	ip   string
+	port int
+}
+
+type serviceGroupBindingResp struct {
+	Bindings []struct {
+		ServiceGroupName string `json:"servicegroupname"`
+	} `json:"lbvserver_servicegroup_binding"`
+}
+
+type serviceGroupMemberResp struct {
+	Members []struct {
+		IP   string `json:"ip"`
+		Port int    `json:"port"`
+	} `json:"servicegroup_servicegroupmember_binding"`
+}
+
+type serviceBindingResp struct {
+	Bindings []struct {
+		Ipv46 string `json:"ipv46"`
+		Port  int    `json:"port"`
+	} `json:"lbvserver_service_binding"`
+}
+
+// nitroGet sends a GET request to the nitro config api and unmarshals the response
+func nitroGet(netscaler ns.NetScaler, endpoint string, v interface{}) error {
+	api, err := netscaler.API(endpoint, "GET", nil)
+	if err != nil {
+		return err
+	}
+	if api.StatusCode != 200 {
+		return fmt.Errorf("%s - expected 200. received %d - %s", endpoint, api.StatusCode, api.RespBody)
+	}
+	return json.Unmarshal([]byte(api.RespBody), v)
+}
+
+// getBackends returns the members of the service groups and the services bound to the virtual server
+func getBackends(netscaler ns.NetScaler, vsName string) ([]backend, error) {
+	backends := []backend{}
+
+	// Service groups
+	var sgResp serviceGroupBindingResp
+	if err := nitroGet(netscaler, "lbvserver_servicegroup_binding/"+url.PathEscape(vsName), &sgResp); err != nil {
+		return nil, err
+	}
+	for _, sg := range sgResp.Bindings {
+		var memberResp serviceGroupMemberResp
+		if err := nitroGet(netscaler, "servicegroup_servicegroupmember_binding/"+url.PathEscape(sg.ServiceGroupName), &memberResp); err != nil {
+			return nil, err
+		}
+		for _, m := range memberResp.Members {
+			backends = append(backends, backend{ip: m.IP, port: m.Port})
+		}
+	}
+
+	// Services bound directly to the virtual server
+	var svcResp serviceBindingResp
+	if err := nitroGet(netscaler, "lbvserver_service_binding/"+url.PathEscape(vsName), &svcResp); err != nil {
+		return nil, err
+	}
+	for _, s := range svcResp.Bindings {
+		backends = append(backends, backend{ip: s.Ipv46, port: s.Port})
+	}
+
+	return backends, nil
+}
+
+// syncBindings binds the workloads with an interface matching a backend ip to the active virtual service and removes bindings for workloads that are no longer backends.
+// If apply is false, the changes are only logged. The number of binding changes is returned.
+func syncBindings(netscaler ns.NetScaler, nsVirtualServers []ns.VirtualServer, wkldIPMap map[string]illumioapi.Workload, apply bool) int {
+
+	// Get the active virtual services in the external data set
+	activeVS, api, err := pce.GetVirtualServices(map[string]string{"external_data_set": externalDataSet}, "active")
+	utils.LogAPIResp("GetVirtualServices", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	activeVSMap := make(map[string]illumioapi.VirtualService)
+	for _, vs := range activeVS {
+		if vs.ExternalDataSet == externalDataSet {
+			activeVSMap[vs.Name] = vs
+		}
+	}
+
+	changes := 0
+	for _, nsvs := range nsVirtualServers {
+		vs, ok := activeVSMap[nsvs.Name]
+		if !ok {
+			continue
+		}
+
+		// Build the desired bindings
+		backends, err := getBackends(netscaler, nsvs.Name)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("getting backends for %s - %s", nsvs.Name, err), true)
+			continue
+		}
+		desired := make(map[string]illumioapi.ServiceBinding)
+		for _, b := range backends {
+			wkld, exists := wkldIPMap[b.ip]
+			if !exists {
+				utils.LogInfo(fmt.Sprintf("%s - backend %s is not a pce workload", nsvs.Name, b.ip), false)
+				continue
+			}
+			sb := illumioapi.ServiceBinding{VirtualService: vs, Workload: illumioapi.Workload{Href: wkld.Href}}
+			if b.port != 0 && b.port != nsvs.Port && len(vs.ServicePorts) > 0 {
+				sb.PortOverrides = []illumioapi.PortOverrides{{Port: vs.ServicePorts[0].Port, Proto: vs.ServicePorts[0].Protocol, NewPort: b.port}}
+			}
+			desired[wkld.Href] = sb
+		}
+
+		// Get the existing bindings
+		existing, api, err := pce.GetServiceBindings(map[string]string{"virtual_service": vs.Href})
+		utils.LogAPIResp("GetServiceBindings", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("getting service bindings for %s - %s", nsvs.Name, err), true)
+			continue
+		}
+		existingMap := make(map[string]illumioapi.ServiceBinding)
+		for _, sb := range existing {
+			existingMap[sb.Workload.Href] = sb
+		}
+
+		// Create the missing bindings
+		createBindings := []illumioapi.ServiceBinding{}
+		for href, sb := range desired {
+			if _, ok := existingMap[href]; !ok {
+				utils.LogInfo(fmt.Sprintf("%s - bind %s", nsvs.Name, href), true)
+				createBindings = append(createBindings, sb)
+			}
+		}
+		if apply && len(createBindings) > 0 {
+			_, api, err := pce.CreateServiceBinding(createBindings)
+			utils.LogAPIResp("CreateServiceBinding", api)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("error creating bindings for %s - %d status code - %s", nsvs.Name, api.StatusCode, api.RespBody), true)
+			}
+		}
+
+		// Remove the bindings that are no longer backends
+		for href, sb := range existingMap {
+			if _, ok := desired[href]; ok {
+				continue
+			}
+			utils.LogInfo(fmt.Sprintf("%s - unbind %s", nsvs.Name, href), true)
+			changes++
+			if !apply {
+				continue
+			}
+			api, err := pce.DeleteHref(sb.Href)
+			utils.LogAPIResp("DeleteHref", api)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("error deleting binding %s - %d status code - %s", sb.Href, api.StatusCode, api.RespBody), true)
+			}
+		}
+		changes = changes + len(createBindings)
+	}
+
+	return changes
+}
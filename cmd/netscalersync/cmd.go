@@ -12,7 +12,7 @@ import (
 var pce illumioapi.PCE
 var netscaler ns.NetScaler
 var externalDataSet string
-var cleanup, updatePCE, noPrompt bool
+var cleanup, noBindings, updatePCE, noPrompt bool
 var err error
 
 func init() {
@@ -22,19 +22,23 @@ func init() {
 	NetScalerSyncCmd.Flags().StringVarP(&netscaler.Password, "netscaler-pwd", "p", "", "netscaler password")
 	NetScalerSyncCmd.Flags().StringVarP(&externalDataSet, "externalDataSet", "e", "workloader-netscaler-sync", "external data set")
 	NetScalerSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "clean up virtual services (VIPs) and unmanaged workloads (SNAT IPs) in external data set that are no longer in netscaler.")
+	NetScalerSyncCmd.Flags().BoolVar(&noBindings, "no-bindings", false, "do not bind the backend workloads of each virtual server to the virtual service.")
 	NetScalerSyncCmd.Flags().SortFlags = false
 
 }
 
 // NetScalerSyncCmd runs the NetScalerSync command
 var NetScalerSyncCmd = &cobra.Command{
-	Use:   "netscaler-sync",
-	Short: "Create an Illumio Virtual Service for each Citrix virtual server and an unmanaged workload for each SNAT IP.",
+	Use:         "netscaler-sync",
+	Short:       "Create an Illumio Virtual Service for each Citrix virtual server with its backend workloads bound and an unmanaged workload for each SNAT IP.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Create an Illumio Virtual Service for each Citrix virtual server and an unmanaged workload for each SNAT IP.
 
 This version only supports single IP VIPs.
 
+The members of the service groups and the services bound to each virtual server are matched to PCE workloads by IP address. The matching workloads are bound to the virtual service, with a port override when the backend port is different than the virtual server port. Bindings for workloads that are no longer backends are removed. Use --no-bindings to skip.
+
 Recommended to run without --update-pce first to log of what will change.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -11,7 +11,6 @@ import (
 	"github.com/brian1917/ns"
 	"github.com/brian1917/workloader/utils"
 	"github.com/google/uuid"
-	"github.com/spf13/viper"
 )
 
 func nsSync(pce illumioapi.PCE, netscaler ns.NetScaler) {
@@ -26,6 +25,21 @@ func nsSync(pce illumioapi.PCE, netscaler ns.NetScaler) {
 	}
 	utils.LogInfo(fmt.Sprintf("get illumio virtual services - %d", api.StatusCode), true)
 
+	// Get all workloads to map backend IPs to workloads for service bindings
+	wkldIPMap := make(map[string]illumioapi.Workload)
+	if !noBindings {
+		allWklds, api, err := pce.GetWklds(nil)
+		utils.LogAPIResp("GetWklds", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, w := range allWklds {
+			for _, i := range w.Interfaces {
+				wkldIPMap[i.Address] = w
+			}
+		}
+	}
+
 	// Get Illumio unmanaged workloads from the external dataset
 	pceUMWLs, api, err := pce.GetWklds(map[string]string{"managed": "false", "external_data_set": externalDataSet})
 	utils.LogAPIResp("GetWklds", api)
@@ -159,6 +173,9 @@ func nsSync(pce illumioapi.PCE, netscaler ns.NetScaler) {
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
+		if !noBindings {
+			utils.LogInfo(fmt.Sprintf("%d service binding changes identified for existing virtual services. bindings for new virtual services are created after they are provisioned.", syncBindings(netscaler, nsVirtualServers, wkldIPMap, false)), true)
+		}
 		utils.LogInfo("See workloader.log for more details. To do the import, run again using --update-pce flag.", true)
 		utils.LogEndCommand("netscaler-sync")
 		return
@@ -167,7 +184,7 @@ func nsSync(pce illumioapi.PCE, netscaler ns.NetScaler) {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - workloader will create %d virtual services (vips), create %d unmanaged workloads (snips), update %d virtual services (vips), update %d unmanaged workloads (snips), remove %d virtual services (vips), and remove %d unmanaged workloads (snips) in %s (%s). do you want to run the import (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(createVirtualServices), len(createUMWLs), len(updateVirtualServices), len(updateUMWLs), len(removeVirtualServices), len(removeUMWLs), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n%s [PROMPT] - workloader will create %d virtual services (vips), create %d unmanaged workloads (snips), update %d virtual services (vips), update %d unmanaged workloads (snips), remove %d virtual services (vips), and remove %d unmanaged workloads (snips) in %s (%s). do you want to run the import (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(createVirtualServices), len(createUMWLs), len(updateVirtualServices), len(updateUMWLs), len(removeVirtualServices), len(removeUMWLs), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied.", true)
@@ -255,6 +272,11 @@ func nsSync(pce illumioapi.PCE, netscaler ns.NetScaler) {
 	}
 	utils.LogInfo(fmt.Sprintf("provisioning virtual service changes - %d", api.StatusCode), true)
 
+	// Bind the backend workloads to the active virtual services
+	if !noBindings {
+		utils.LogInfo(fmt.Sprintf("%d service binding changes made", syncBindings(netscaler, nsVirtualServers, wkldIPMap, true)), true)
+	}
+
 	utils.LogEndCommand("netscaler-sync")
 }
 
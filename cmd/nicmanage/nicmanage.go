@@ -2,7 +2,7 @@ package nicmanage
 
 import (
 	"fmt"
-	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,20 +16,33 @@ import (
 var updatePCE, noPrompt bool
 var pce illumioapi.PCE
 var err error
-var outputFileName, csvFile string
+var outputFileName, csvFile, ignorePattern, clearPattern, labelFile string
 
 func init() {
+	NICManageCmd.Flags().StringVar(&ignorePattern, "ignore-pattern", "", "regex of interface names to set as ignored on all workloads in scope (e.g., \"^docker|^veth|^cali\"). used instead of a csv file.")
+	NICManageCmd.Flags().StringVar(&clearPattern, "clear-pattern", "", "regex of interface names to set as not ignored on all workloads in scope. used instead of a csv file.")
+	NICManageCmd.Flags().StringVar(&labelFile, "label-file", "", "csv file with labels to limit the workloads in scope. the first row is label keys. the columns in each row are an \"and\" operation and the rows are an \"or\" operation.")
 	NICManageCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	NICManageCmd.Flags().SortFlags = false
 }
 
 // NICManageCmd produces a report of all network interfaces
 var NICManageCmd = &cobra.Command{
-	Use:   "nic-manage [csv file to import]",
-	Short: "Manage interfaces for managed or unmanaged workloads by setting ignored field to true or false.",
+	Use:         "nic-manage [csv file to import]",
+	Short:       "Manage interfaces for managed or unmanaged workloads by setting ignored field to true or false.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Manage interfaces for managed or unmanaged workloads by setting ignored field to true or false.
 
-Head input CSV requires a header row with at least two headers: wkld_href and ignored. Other columns can be present as well. It is recommended to run worklodaer nic-export and  modify the ignored column in that output.`,
+Head input CSV requires a header row with at least two headers: wkld_href and ignored. Other columns can be present as well. It is recommended to run worklodaer nic-export and  modify the ignored column in that output.
+
+Instead of a csv file, use --ignore-pattern and --clear-pattern to set or clear ignored interfaces by regex on every workload in scope. Interfaces that match --ignore-pattern are set to ignored and interfaces that match --clear-pattern are set to not ignored. An interface that matches both patterns is not changed. Use --label-file to limit the workloads in scope with or without a csv file.
+
+The output is a diff csv with a row for each interface that changes state. Run without --update-pce to review the diff before making changes.
+
+Examples:
+  workloader nic-manage --ignore-pattern "^docker|^veth|^cali"
+  workloader nic-manage --ignore-pattern "^docker" --clear-pattern "^eth" --label-file k8s-nodes.csv --update-pce`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		pce, err = utils.GetTargetPCE(true)
@@ -42,44 +55,76 @@ Head input CSV requires a header row with at least two headers: wkld_href and ig
 		noPrompt = viper.Get("no_prompt").(bool)
 
 		// Set the CSV file
-		if len(args) != 1 {
+		if ignorePattern != "" || clearPattern != "" {
+			if len(args) != 0 {
+				utils.LogErrorCode(utils.ExitValidation, "a csv file cannot be used with --ignore-pattern or --clear-pattern")
+			}
+		} else if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		} else {
+			csvFile = args[0]
 		}
-		csvFile = args[0]
 
 		nicManage()
 	},
 }
 
+// nicChange is an interface on a workload that changes ignored state
+type nicChange struct {
+	wkldHref      string
+	interfaceName string
+	ignored       bool
+}
+
 func nicManage() {
 
 	// Log Start
 	utils.LogStartCommand("nic-manage")
 
-	// Parse the CSV file
-	csvData, err := utils.ParseCSV(csvFile)
-	if err != nil {
-		utils.LogError(err.Error())
+	// Compile the patterns
+	var ignoreRegex, clearRegex *regexp.Regexp
+	if ignorePattern != "" {
+		if ignoreRegex, err = regexp.Compile(ignorePattern); err != nil {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--ignore-pattern - %s", err))
+		}
+	}
+	if clearPattern != "" {
+		if clearRegex, err = regexp.Compile(clearPattern); err != nil {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--clear-pattern - %s", err))
+		}
 	}
 
-	// Get the headers
-	csvHeaders := findHeaders(csvData[0])
+	// Limit the workloads to the label file
+	qp := make(map[string]string)
+	if labelFile != "" {
+		labelData, err := utils.ParseCSV(labelFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		qp["labels"], err = pce.WorkloadQueryLabelParameter(labelData)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
 
 	// Get all the workloads from the PCE
-	wklds, a, err := pce.GetWklds(nil)
+	wklds, a, err := pce.GetWklds(qp)
 	utils.LogAPIResp("GetAllWorkloadsQP", a)
 	if err != nil {
 		utils.LogError(err.Error())
 	}
 	wkldHrefMap := make(map[string]illumioapi.Workload)
 	for _, w := range wklds {
+		if w.IgnoredInterfaceNames == nil {
+			w.IgnoredInterfaceNames = &[]string{}
+		}
 		wkldHrefMap[w.Href] = w
 	}
 
 	// Create a map where they key is the concatenated value of the workload href and the nicName
 	wkldInterfaceMap := make(map[string]bool)
-	for _, w := range wklds {
+	for _, w := range wkldHrefMap {
 		// Populate all interfaces as false since they are not ignored
 		for _, iFace := range w.Interfaces {
 			wkldInterfaceMap[w.Href+iFace.Name] = false
@@ -90,90 +135,71 @@ func nicManage() {
 		}
 	}
 
-	// Create a map to hold wkldhref and interface name.
-	csvInterfaces := make(map[string]bool)
+	// Get the changes from the csv or the patterns
+	var changes []nicChange
+	if csvFile != "" {
+		changes = csvChanges(wkldInterfaceMap)
+	} else {
+		changes = patternChanges(wklds, wkldInterfaceMap, ignoreRegex, clearRegex)
+	}
 
 	// Create a slice of workloads that need to be updated
 	updatedWkldsMap := make(map[string]illumioapi.Workload)
 	updatedWklds := []illumioapi.Workload{}
-
-	// Iterate through the CSV input
-	interfaceChangeCount := 0
-	for rowNum, dataRow := range csvData {
-
-		// Skip the first row
-		if rowNum == 0 {
-			continue
+	for _, c := range changes {
+		newWkld, ok := updatedWkldsMap[c.wkldHref]
+		if !ok {
+			newWkld = wkldHrefMap[c.wkldHref]
 		}
-
-		// Check if this interface has already been in the input
-		if csvInterfaces[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]] {
-			utils.LogError(fmt.Sprintf("CSV row %d - this interface name appears twice. If using output from workloader nic-export, use the -c argument to consolidate to a single line", rowNum+1))
+		if c.ignored {
+			// If the interface is now ignored, we need to append it
+			x := append(append([]string{}, *newWkld.IgnoredInterfaceNames...), c.interfaceName)
+			newWkld.IgnoredInterfaceNames = &x
 		} else {
-			// Add it to the map if it's the first time we see it
-			csvInterfaces[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]] = true
-		}
-
-		// Convert the CSV value to a boolean.
-		csvBool, err := strconv.ParseBool(dataRow[csvHeaders.ignored])
-		if err != nil {
-			utils.LogError(fmt.Sprintf("CSV row %d - %s", rowNum+1, err.Error()))
-		}
-
-		// Check if the value in the CSV matches the value in the PCE
-		var pceBool, ok bool
-		if pceBool, ok = wkldInterfaceMap[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]]; !ok {
-			utils.LogError(fmt.Sprintf("CSV row %d - interface %s does not exist on workload %s or the workload does not exist.", rowNum+1, dataRow[csvHeaders.interfaceName], dataRow[csvHeaders.wkldHref]))
-		}
-
-		// Check if the workload and CSV value match
-		if pceBool != csvBool {
-			utils.LogInfo(fmt.Sprintf("CSV row %d - interface %s needs to be updated from %t to %t", rowNum+1, dataRow[csvHeaders.interfaceName], pceBool, csvBool), false)
-			interfaceChangeCount++
-			// If they don't match and the csvBool is true, we need to append it
-			newWkld := illumioapi.Workload{}
-			if val, ok := updatedWkldsMap[dataRow[csvHeaders.wkldHref]]; ok {
-				newWkld = val
-			} else {
-				newWkld = wkldHrefMap[dataRow[csvHeaders.wkldHref]]
-			}
-			if csvBool {
-				x := append(*newWkld.IgnoredInterfaceNames, dataRow[csvHeaders.interfaceName])
-				newWkld.IgnoredInterfaceNames = &x
-				updatedWkldsMap[newWkld.Href] = newWkld
-			} else {
-				// If they dont match and csvBool is false, we need to remove it
-				updatedInterfaces := []string{}
-				for _, iFace := range *newWkld.IgnoredInterfaceNames {
-					if iFace == dataRow[csvHeaders.interfaceName] {
-						continue
-					}
-					updatedInterfaces = append(updatedInterfaces, iFace)
+			// If the interface is no longer ignored, we need to remove it
+			updatedInterfaces := []string{}
+			for _, iFace := range *newWkld.IgnoredInterfaceNames {
+				if iFace == c.interfaceName {
+					continue
 				}
-				newWkld.IgnoredInterfaceNames = &updatedInterfaces
-				updatedWkldsMap[newWkld.Href] = newWkld
+				updatedInterfaces = append(updatedInterfaces, iFace)
 			}
+			newWkld.IgnoredInterfaceNames = &updatedInterfaces
+		}
+		if !ok {
+			updatedWklds = append(updatedWklds, newWkld)
 		}
+		updatedWkldsMap[newWkld.Href] = newWkld
 	}
 
 	// Convert the update map to the update slice
-	for _, w := range updatedWkldsMap {
-		updatedWklds = append(updatedWklds, w)
+	for i, w := range updatedWklds {
+		updatedWklds[i] = updatedWkldsMap[w.Href]
 	}
 
 	// End run there are no updates required
-	if interfaceChangeCount == 0 {
+	if len(changes) == 0 {
 		utils.LogInfo("no changes identified", true)
 		utils.LogEndCommand("nic-manage")
 		return
 	}
 
+	// Write the diff of the interfaces that change state
+	diffData := [][]string{{"hostname", "wkld_href", "interface_name", "current_ignored", "new_ignored"}}
+	for _, c := range changes {
+		diffData = append(diffData, []string{wkldHrefMap[c.wkldHref].Hostname, c.wkldHref, c.interfaceName, strconv.FormatBool(!c.ignored), strconv.FormatBool(c.ignored)})
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-nic-manage-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(diffData, diffData, outputFileName)
+
 	// Log the results
-	utils.LogInfo(fmt.Sprintf("workloader identified %d interfaces on %d workloads that require updates.", interfaceChangeCount, len(updatedWklds)), true)
+	utils.LogInfo(fmt.Sprintf("workloader identified %d interfaces on %d workloads that require updates.", len(changes), len(updatedWklds)), true)
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
-		utils.LogInfo("See workloader.log for more details. To implement the changes, run again using --update-pce flag.", true)
+		utils.LogInfo(fmt.Sprintf("See %s for details. To implement the changes, run again using --update-pce flag.", outputFileName), true)
 		utils.LogEndCommand("nic-manage")
 		return
 	}
@@ -181,7 +207,7 @@ func nicManage() {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - Do you want to run the import to %s at %s (yes/no)?", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n%s [PROMPT] - Do you want to run the import to %s at %s (yes/no)?", time.Now().Format("2006-01-02 15:04:05 "), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo(fmt.Sprintf("prompt denied to update %d workloads.", len(updatedWklds)), true)
@@ -199,5 +225,59 @@ func nicManage() {
 		utils.LogError(fmt.Sprintf("bulk updating workloads - %s", err))
 	}
 	utils.LogInfo(fmt.Sprintf("bulk update workload successful for %d workloads - status code %d", len(updatedWklds), api[0].StatusCode), true)
+	utils.LogEndCommand("nic-manage")
+}
+
+// csvChanges returns the interfaces in the csv file with an ignored value that does not match the pce
+func csvChanges(wkldInterfaceMap map[string]bool) []nicChange {
+
+	// Parse the CSV file
+	csvData, err := utils.ParseCSV(csvFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the headers
+	csvHeaders := findHeaders(csvData[0])
+
+	// Create a map to hold wkldhref and interface name.
+	csvInterfaces := make(map[string]bool)
+
+	// Iterate through the CSV input
+	changes := []nicChange{}
+	for rowNum, dataRow := range csvData {
+
+		// Skip the first row
+		if rowNum == 0 {
+			continue
+		}
+
+		// Check if this interface has already been in the input
+		if csvInterfaces[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]] {
+			utils.LogError(fmt.Sprintf("CSV row %d - this interface name appears twice. If using output from workloader nic-export, use the -c argument to consolidate to a single line", rowNum+1))
+		} else {
+			// Add it to the map if it's the first time we see it
+			csvInterfaces[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]] = true
+		}
+
+		// Convert the CSV value to a boolean.
+		csvBool, err := strconv.ParseBool(dataRow[csvHeaders.ignored])
+		if err != nil {
+			utils.LogError(fmt.Sprintf("CSV row %d - %s", rowNum+1, err.Error()))
+		}
+
+		// Check if the value in the CSV matches the value in the PCE
+		var pceBool, ok bool
+		if pceBool, ok = wkldInterfaceMap[dataRow[csvHeaders.wkldHref]+dataRow[csvHeaders.interfaceName]]; !ok {
+			utils.LogError(fmt.Sprintf("CSV row %d - interface %s does not exist on workload %s or the workload does not exist.", rowNum+1, dataRow[csvHeaders.interfaceName], dataRow[csvHeaders.wkldHref]))
+		}
+
+		// Check if the workload and CSV value match
+		if pceBool != csvBool {
+			utils.LogInfo(fmt.Sprintf("CSV row %d - interface %s needs to be updated from %t to %t", rowNum+1, dataRow[csvHeaders.interfaceName], pceBool, csvBool), false)
+			changes = append(changes, nicChange{wkldHref: dataRow[csvHeaders.wkldHref], interfaceName: dataRow[csvHeaders.interfaceName], ignored: csvBool})
+		}
+	}
 
+	return changes
 }
@@ -0,0 +1,49 @@
+package nicmanage
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// patternChanges returns the interfaces that match --ignore-pattern and are not ignored and the interfaces that match --clear-pattern and are ignored
+func patternChanges(wklds []illumioapi.Workload, wkldInterfaceMap map[string]bool, ignoreRegex, clearRegex *regexp.Regexp) []nicChange {
+	changes := []nicChange{}
+	for _, w := range wklds {
+
+		// Check the interfaces and the ignored interface names that are no longer interfaces
+		names := []string{}
+		for _, iFace := range w.Interfaces {
+			names = append(names, iFace.Name)
+		}
+		if w.IgnoredInterfaceNames != nil {
+			names = append(names, *w.IgnoredInterfaceNames...)
+		}
+
+		checked := make(map[string]bool)
+		for _, name := range names {
+			if checked[name] {
+				continue
+			}
+			checked[name] = true
+
+			ignoreMatch := ignoreRegex != nil && ignoreRegex.MatchString(name)
+			clearMatch := clearRegex != nil && clearRegex.MatchString(name)
+			if ignoreMatch && clearMatch {
+				utils.LogInfo(fmt.Sprintf("%s - interface %s matches --ignore-pattern and --clear-pattern. skipping.", w.Hostname, name), false)
+				continue
+			}
+			ignored := wkldInterfaceMap[w.Href+name]
+			if ignoreMatch && !ignored {
+				changes = append(changes, nicChange{wkldHref: w.Href, interfaceName: name, ignored: true})
+			}
+			if clearMatch && ignored {
+				changes = append(changes, nicChange{wkldHref: w.Href, interfaceName: name, ignored: false})
+			}
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,96 @@
+package nsxsync
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var nsx nsxManager
+var mappingFile, domain, groupFilterStr, matchBy, outputFileName string
+var insecure, reverse, updateNSX, updatePCE, noPrompt bool
+var groupFilter *regexp.Regexp
+var err error
+
+func init() {
+	NSXSyncCmd.Flags().StringVarP(&nsx.server, "nsx-manager", "s", "", "nsx-t manager address in format server.com or server.com:8443.")
+	NSXSyncCmd.Flags().StringVarP(&nsx.user, "nsx-user", "u", "", "nsx-t user. default is the NSX_USER environment variable.")
+	NSXSyncCmd.Flags().StringVarP(&nsx.password, "nsx-pwd", "p", "", "nsx-t password. default is the NSX_PASSWORD environment variable.")
+	NSXSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the nsx-t manager certificate.")
+	NSXSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: nsx tag scope (or nsx:group) and illumio label key.")
+	NSXSyncCmd.Flags().StringVar(&domain, "domain", "default", "nsx-t policy domain for groups.")
+	NSXSyncCmd.Flags().StringVar(&groupFilterStr, "group-filter", "", "regular expression to limit the groups used for the nsx:group mapping (e.g., \"^app-\").")
+	NSXSyncCmd.Flags().StringVar(&matchBy, "match-by", "both", "match vms to workloads by name, ip, or both. both tries the name first.")
+	NSXSyncCmd.Flags().BoolVar(&reverse, "reverse", false, "write illumio labels to nsx-t vm tags instead of labeling workloads from nsx-t.")
+	NSXSyncCmd.Flags().BoolVar(&updateNSX, "update-nsx", false, "apply the tag changes to nsx-t in reverse mode. default only logs the changes.")
+	NSXSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	NSXSyncCmd.Flags().SortFlags = false
+}
+
+// NSXSyncCmd runs the nsx-sync command
+var NSXSyncCmd = &cobra.Command{
+	Use:         "nsx-sync",
+	Short:       "Label workloads from NSX-T VM tags and groups or write Illumio labels back to NSX-T VM tags.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Label workloads from NSX-T VM tags and groups or write Illumio labels back to NSX-T VM tags.
+
+The mapping file is a csv with the nsx tag scope in the first column and the illumio label key in the second column. A header of scope,label_key is optional. The pseudo scope nsx:group maps the name of the policy group the vm is a member of (use --group-filter to limit the groups). If a vm is in more than one matching group, the first group alphabetically is used.
+
+Example mapping file:
++-------------+-----------+
+|    scope    | label_key |
++-------------+-----------+
+| application | app       |
+| environment | env       |
+| nsx:group   | role      |
++-------------+-----------+
+
+VMs are matched to workloads by vm name (full or short name, case insensitive) and/or ip address.
+
+Default mode labels the matching workloads using wkld-import. Use --update-pce to apply the labels.
+
+With --reverse, the labels of the matching workloads are written to the vm tags with the mapped scope. Tags with other scopes are kept. A tag is removed if the workload does not have the label. The nsx:group mapping is ignored. Use --update-nsx to apply the tag changes. This is useful during migrations so nsx-t policy can continue to use tags based on illumio labels.
+
+Recommended to run without --update-pce or --update-nsx first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if nsx.server == "" {
+			utils.LogError("--nsx-manager is required")
+		}
+		if mappingFile == "" {
+			utils.LogError("--mapping-file is required")
+		}
+		if nsx.user == "" {
+			nsx.user = os.Getenv("NSX_USER")
+		}
+		if nsx.password == "" {
+			nsx.password = os.Getenv("NSX_PASSWORD")
+		}
+		if matchBy != "name" && matchBy != "ip" && matchBy != "both" {
+			utils.LogError("--match-by must be name, ip, or both")
+		}
+		if groupFilterStr != "" {
+			if groupFilter, err = regexp.Compile(groupFilterStr); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		nsxSync()
+	},
+}
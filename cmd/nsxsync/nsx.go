@@ -0,0 +1,162 @@
+package nsxsync
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// nsxManager calls the NSX-T manager and policy APIs
+type nsxManager struct {
+	server   string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// nsxTag is an NSX tag
+type nsxTag struct {
+	Scope string `json:"scope"`
+	Tag   string `json:"tag"`
+}
+
+// nsxVM is the subset of an NSX fabric virtual machine used by nsx-sync
+type nsxVM struct {
+	ExternalID  string   `json:"external_id"`
+	DisplayName string   `json:"display_name"`
+	PowerState  string   `json:"power_state"`
+	Tags        []nsxTag `json:"tags"`
+}
+
+// nsxVIF is the subset of an NSX fabric virtual interface used by nsx-sync
+type nsxVIF struct {
+	OwnerVMID     string `json:"owner_vm_id"`
+	IPAddressInfo []struct {
+		IPAddresses []string `json:"ip_addresses"`
+	} `json:"ip_address_info"`
+}
+
+// nsxGroup is the subset of an NSX policy group used by nsx-sync
+type nsxGroup struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+// call sends a request to the api and unmarshals the response into result
+func (n *nsxManager) call(method, path string, query url.Values, payload, result interface{}) error {
+	if n.client == nil {
+		n.client = &http.Client{Timeout: 120 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}
+	}
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	u := fmt.Sprintf("https://%s%s", n.server, path)
+	if len(query) > 0 {
+		u = u + "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(n.user, n.password)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s - %d - %s", method, path, resp.StatusCode, string(body))
+	}
+	if result == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// list gets all pages of a list api. results must be a pointer to a slice.
+func (n *nsxManager) list(path string, query url.Values, results interface{}) error {
+	all := []json.RawMessage{}
+	if query == nil {
+		query = url.Values{}
+	}
+	for {
+		var page struct {
+			Results []json.RawMessage `json:"results"`
+			Cursor  string            `json:"cursor"`
+		}
+		if err := n.call("GET", path, query, nil, &page); err != nil {
+			return err
+		}
+		all = append(all, page.Results...)
+		if page.Cursor == "" {
+			break
+		}
+		query.Set("cursor", page.Cursor)
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, results)
+}
+
+// getVMs returns the virtual machines and a map of vm external id to ip addresses
+func (n *nsxManager) getVMs() ([]nsxVM, map[string][]string, error) {
+	vms := []nsxVM{}
+	if err := n.list("/api/v1/fabric/virtual-machines", nil, &vms); err != nil {
+		return nil, nil, err
+	}
+	vifs := []nsxVIF{}
+	if err := n.list("/api/v1/fabric/vifs", nil, &vifs); err != nil {
+		return nil, nil, err
+	}
+	ips := make(map[string][]string)
+	for _, v := range vifs {
+		for _, info := range v.IPAddressInfo {
+			ips[v.OwnerVMID] = append(ips[v.OwnerVMID], info.IPAddresses...)
+		}
+	}
+	return vms, ips, nil
+}
+
+// getGroupMembers returns a map of vm external id to the names of the policy groups it is a member of
+func (n *nsxManager) getGroupMembers(domain string) (map[string][]string, error) {
+	groups := []nsxGroup{}
+	if err := n.list(fmt.Sprintf("/policy/api/v1/infra/domains/%s/groups", url.PathEscape(domain)), nil, &groups); err != nil {
+		return nil, err
+	}
+	members := make(map[string][]string)
+	for _, g := range groups {
+		if groupFilter != nil && !groupFilter.MatchString(g.DisplayName) {
+			continue
+		}
+		vms := []nsxVM{}
+		if err := n.list(fmt.Sprintf("/policy/api/v1/infra/domains/%s/groups/%s/members/virtual-machines", url.PathEscape(domain), url.PathEscape(g.ID)), nil, &vms); err != nil {
+			return nil, err
+		}
+		for _, vm := range vms {
+			members[vm.ExternalID] = append(members[vm.ExternalID], g.DisplayName)
+		}
+	}
+	return members, nil
+}
+
+// updateTags replaces the tags on a virtual machine
+func (n *nsxManager) updateTags(externalID string, tags []nsxTag) error {
+	return n.call("POST", "/api/v1/fabric/virtual-machines", url.Values{"action": []string{"update_tags"}}, map[string]interface{}{"external_id": externalID, "tags": tags}, nil)
+}
@@ -0,0 +1,235 @@
+package nsxsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+func nsxSync() {
+
+	utils.LogStartCommand("nsx-sync")
+
+	// Parse the mapping file
+	mapping, err := umwlsync.ParseMappingFile(mappingFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the vms
+	vms, vmIPs, err := nsx.getVMs()
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d vms in %s", len(vms), nsx.server), true)
+
+	// Get the workloads
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	nameMap := make(map[string]illumioapi.Workload)
+	ipMap := make(map[string]illumioapi.Workload)
+	dupeIPs := make(map[string]bool)
+	for _, w := range wklds {
+		if w.Hostname != "" {
+			nameMap[strings.ToLower(w.Hostname)] = w
+			nameMap[strings.ToLower(strings.Split(w.Hostname, ".")[0])] = w
+		}
+		for _, i := range w.Interfaces {
+			if _, ok := ipMap[i.Address]; ok {
+				dupeIPs[i.Address] = true
+			}
+			ipMap[i.Address] = w
+		}
+	}
+
+	// match returns the workload for a vm
+	match := func(vm nsxVM) (illumioapi.Workload, bool) {
+		if matchBy != "ip" {
+			for _, n := range []string{vm.DisplayName, strings.Split(vm.DisplayName, ".")[0]} {
+				if w, ok := nameMap[strings.ToLower(n)]; ok {
+					return w, true
+				}
+			}
+		}
+		if matchBy != "name" {
+			for _, ip := range vmIPs[vm.ExternalID] {
+				if dupeIPs[ip] {
+					utils.LogWarning(fmt.Sprintf("%s - %s is used by more than one workload. skipping ip match.", vm.DisplayName, ip), false)
+					continue
+				}
+				if w, ok := ipMap[ip]; ok {
+					return w, true
+				}
+			}
+		}
+		return illumioapi.Workload{}, false
+	}
+
+	if reverse {
+		writeTags(vms, match, mapping)
+	} else {
+		labelWorkloads(vms, match, mapping)
+	}
+
+	utils.LogEndCommand("nsx-sync")
+}
+
+// labelWorkloads labels the matching workloads from the vm tags and groups
+func labelWorkloads(vms []nsxVM, match func(nsxVM) (illumioapi.Workload, bool), mapping map[string]string) {
+
+	// Get the group membership if it is in the mapping
+	groupMembers := make(map[string][]string)
+	if _, ok := mapping["nsx:group"]; ok {
+		if groupMembers, err = nsx.getGroupMembers(domain); err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	labelKeys := []string{}
+	for _, k := range mapping {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	labelData := [][]string{append([]string{wkldexport.HeaderHostname}, labelKeys...)}
+	labeled := make(map[string]bool)
+	unmatched := 0
+	for _, vm := range vms {
+		w, ok := match(vm)
+		if !ok {
+			unmatched++
+			utils.LogInfo(fmt.Sprintf("%s - %s does not match a workload", vm.DisplayName, vm.ExternalID), false)
+			continue
+		}
+		if labeled[w.Href] {
+			utils.LogWarning(fmt.Sprintf("%s matches %s which already matched another vm. skipping.", vm.DisplayName, w.Hostname), true)
+			continue
+		}
+		labeled[w.Href] = true
+
+		attributes := make(map[string]string)
+		for _, t := range vm.Tags {
+			attributes[t.Scope] = t.Tag
+		}
+		if groups := groupMembers[vm.ExternalID]; len(groups) > 0 {
+			sort.Strings(groups)
+			attributes["nsx:group"] = groups[0]
+			if len(groups) > 1 {
+				utils.LogInfo(fmt.Sprintf("%s is in %d groups. using %s.", vm.DisplayName, len(groups), groups[0]), false)
+			}
+		}
+		labels := umwlsync.MapLabels(attributes, mapping)
+		row := []string{w.Hostname}
+		for _, k := range labelKeys {
+			row = append(row, labels[k])
+		}
+		labelData = append(labelData, row)
+	}
+	utils.LogInfo(fmt.Sprintf("%d vms match workloads. %d vms do not match.", len(labelData)-1, unmatched), true)
+	if len(labelData) == 1 {
+		return
+	}
+
+	labelFile := fmt.Sprintf("workloader-nsx-sync-labels-%s.csv", time.Now().Format("20060102_150405"))
+	if outputFileName != "" {
+		labelFile = outputFileName
+	}
+	utils.WriteOutput(labelData, labelData, labelFile)
+	wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+		PCE:             pce,
+		Data:            labelData,
+		MatchString:     "hostname",
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		UpdateWorkloads: true,
+	})
+}
+
+// writeTags writes the labels of the matching workloads to the vm tags
+func writeTags(vms []nsxVM, match func(nsxVM) (illumioapi.Workload, bool), mapping map[string]string) {
+
+	type tagUpdate struct {
+		vm   nsxVM
+		tags []nsxTag
+	}
+	updates := []tagUpdate{}
+	data := [][]string{{"vm_name", "external_id", "hostname", "scope", "current_tag", "new_tag"}}
+
+	for _, vm := range vms {
+		w, ok := match(vm)
+		if !ok {
+			continue
+		}
+
+		current := make(map[string]string)
+		newTags := []nsxTag{}
+		for _, t := range vm.Tags {
+			if _, mapped := mapping[t.Scope]; !mapped {
+				newTags = append(newTags, t)
+			}
+			current[t.Scope] = t.Tag
+		}
+
+		change := false
+		scopes := []string{}
+		for s := range mapping {
+			if s != "nsx:group" {
+				scopes = append(scopes, s)
+			}
+		}
+		sort.Strings(scopes)
+		for _, scope := range scopes {
+			value := w.GetLabelByKey(mapping[scope], pce.Labels).Value
+			if value != "" {
+				newTags = append(newTags, nsxTag{Scope: scope, Tag: value})
+			}
+			if current[scope] != value {
+				change = true
+				data = append(data, []string{vm.DisplayName, vm.ExternalID, w.Hostname, scope, current[scope], value})
+			}
+		}
+		if change {
+			updates = append(updates, tagUpdate{vm: vm, tags: newTags})
+		}
+	}
+
+	if len(updates) == 0 {
+		utils.LogInfo("nsx-t tags match the illumio labels. nothing to be done.", true)
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-nsx-sync-tag-changes-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d vms require tag changes.", len(updates)), true)
+
+	if !updateNSX {
+		utils.LogInfo("see the output file for the tag changes. to apply the changes, run again using --update-nsx flag.", true)
+		return
+	}
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will update tags on %d vms in %s. do you want to run the update (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(updates), nsx.server)
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			return
+		}
+	}
+	for _, u := range updates {
+		if err := nsx.updateTags(u.vm.ExternalID, u.tags); err != nil {
+			utils.LogWarning(fmt.Sprintf("updating tags on %s - %s", u.vm.DisplayName, err), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("updated tags on %s", u.vm.DisplayName), true)
+	}
+}
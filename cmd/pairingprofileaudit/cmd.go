@@ -0,0 +1,68 @@
+package pairingprofileaudit
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName, remediationFileName string
+var keyDays, staleDays, keyLifespan, usesPerKey int
+var includeDisabled bool
+
+func init() {
+	PairingProfileAuditCmd.Flags().IntVar(&keyDays, "key-days", 90, "number of days of pairing key events to check for unused keys.")
+	PairingProfileAuditCmd.Flags().IntVar(&staleDays, "stale-days", 7, "pairing keys older than this many days that have not been used are flagged.")
+	PairingProfileAuditCmd.Flags().IntVar(&keyLifespan, "key-lifespan", 86400, "key lifespan in seconds for profiles with keys that never expire in the remediation file.")
+	PairingProfileAuditCmd.Flags().IntVar(&usesPerKey, "uses-per-key", 1, "allowed uses per key for profiles with unlimited use keys in the remediation file.")
+	PairingProfileAuditCmd.Flags().BoolVar(&includeDisabled, "include-disabled", false, "flag disabled pairing profiles. disabled profiles cannot pair so they are not flagged by default.")
+	PairingProfileAuditCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	PairingProfileAuditCmd.Flags().StringVar(&remediationFileName, "remediation-file", "", "optionally specify the name of the remediation file for pairing-profile-import. default is current location with a timestamped filename.")
+	PairingProfileAuditCmd.Flags().SortFlags = false
+}
+
+// PairingProfileAuditCmd audits pairing profiles and keys
+var PairingProfileAuditCmd = &cobra.Command{
+	Use:   "pairing-profile-audit",
+	Short: "Audit pairing profiles and keys and create a remediation file for pairing-profile-import.",
+	Long: `
+Audit pairing profiles and keys and create a remediation file for pairing-profile-import.
+
+Each pairing profile is flagged for:
+- unlimited_uses: keys can pair an unlimited number of VENs.
+- non_expiring_keys: keys never expire.
+- label_override: one or more of the role, app, env, and loc labels are not locked, so the person pairing can set or override them.
+- unused_keys: keys created more than --stale-days days ago (in the last --key-days days) that have not expired and have not been used. Key use is not in the API, so a key is unused when the profile has not paired a VEN since the key was created. Keys are found from the pairing key events, so keys older than the event retention are not included.
+
+Disabled pairing profiles cannot pair VENs and are only flagged with --include-disabled.
+
+The remediation file has a row for each flagged profile with the key lifespan set to --key-lifespan seconds if keys never expire, the allowed uses per key set to --uses-per-key if keys have unlimited uses, and every label locked. Unused keys cannot be revoked individually; set enabled to false in the remediation file to stop all of a profile's keys. Review and edit the file and then use it with pairing-profile-import.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Audit pairing profiles
+  workloader pairing-profile-audit
+
+  # Audit and tighten the flagged profiles
+  workloader pairing-profile-audit --remediation-file pp-remediation.csv
+  workloader pairing-profile-import pp-remediation.csv --update-pce`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if keyDays < 1 || staleDays < 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--key-days must be 1 or more and --stale-days must be 0 or more")
+		}
+		if keyLifespan < 1 || usesPerKey < 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--key-lifespan and --uses-per-key must be 1 or more")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("pairing-profile-audit")
+		pairingProfileAudit()
+		utils.LogEndCommand("pairing-profile-audit")
+	},
+}
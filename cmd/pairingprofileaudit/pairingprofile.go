@@ -0,0 +1,93 @@
+package pairingprofileaudit
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/brian1917/illumioapi"
+)
+
+// Headers used by the remediation file and pairing-profile-import
+const (
+	HeaderHref              = "href"
+	HeaderName              = "name"
+	HeaderEnabled           = "enabled"
+	HeaderKeyLifespan       = "key_lifespan"
+	HeaderAllowedUsesPerKey = "allowed_uses_per_key"
+	HeaderRoleLabelLock     = "role_label_lock"
+	HeaderAppLabelLock      = "app_label_lock"
+	HeaderEnvLabelLock      = "env_label_lock"
+	HeaderLocLabelLock      = "loc_label_lock"
+)
+
+// Unlimited is the key lifespan and allowed uses per key value for no limit
+const Unlimited = "unlimited"
+
+// PairingProfile is a pairing profile. The key lifespan and allowed uses per key are "unlimited" or a number, so the illumioapi string fields cannot hold them.
+type PairingProfile struct {
+	Href              string      `json:"href"`
+	Name              string      `json:"name"`
+	Enabled           bool        `json:"enabled"`
+	IsDefault         bool        `json:"is_default"`
+	KeyLifespan       interface{} `json:"key_lifespan"`
+	AllowedUsesPerKey interface{} `json:"allowed_uses_per_key"`
+	RoleLabelLock     bool        `json:"role_label_lock"`
+	AppLabelLock      bool        `json:"app_label_lock"`
+	EnvLabelLock      bool        `json:"env_label_lock"`
+	LocLabelLock      bool        `json:"loc_label_lock"`
+	TotalUseCount     int         `json:"total_use_count"`
+	LastPairingAt     string      `json:"last_pairing_at"`
+	CreatedAt         string      `json:"created_at"`
+}
+
+// GetPairingProfiles returns the pairing profiles in the PCE
+func GetPairingProfiles(pce illumioapi.PCE) (profiles []PairingProfile, api illumioapi.APIResponse, err error) {
+	api, err = pce.GetCollection("pairing_profiles", false, nil, &profiles)
+	if len(profiles) >= 500 {
+		profiles = nil
+		api, err = pce.GetCollection("pairing_profiles", true, nil, &profiles)
+	}
+	return profiles, api, err
+}
+
+// LimitValue returns a key lifespan or allowed uses per key as unlimited or a whole number
+func LimitValue(v interface{}) string {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'f', 0, 64)
+	case string:
+		if t == "" {
+			return Unlimited
+		}
+		return t
+	case nil:
+		return Unlimited
+	}
+	return fmt.Sprint(v)
+}
+
+// ParseLimit returns a key lifespan or allowed uses per key for the api: "unlimited" or a whole number greater than 0
+func ParseLimit(s string) (interface{}, error) {
+	if s == Unlimited {
+		return Unlimited, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 1 {
+		return nil, fmt.Errorf("%s is not unlimited or a whole number greater than 0", s)
+	}
+	return i, nil
+}
+
+// unlockedLabels returns the label keys that are not locked
+func (p PairingProfile) unlockedLabels() []string {
+	unlocked := []string{}
+	for _, l := range []struct {
+		key    string
+		locked bool
+	}{{"role", p.RoleLabelLock}, {"app", p.AppLabelLock}, {"env", p.EnvLabelLock}, {"loc", p.LocLabelLock}} {
+		if !l.locked {
+			unlocked = append(unlocked, l.key)
+		}
+	}
+	return unlocked
+}
@@ -0,0 +1,169 @@
+package pairingprofileaudit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Findings
+const (
+	findingUnlimitedUses   = "unlimited_uses"
+	findingNonExpiringKeys = "non_expiring_keys"
+	findingLabelOverride   = "label_override"
+	findingUnusedKeys      = "unused_keys"
+)
+
+// pairingKeyEvent is a pairing key event with the pairing profile it was created for
+type pairingKeyEvent struct {
+	illumioapi.Event
+	ResourceChanges []struct {
+		Resource map[string]struct {
+			Href string `json:"href"`
+		} `json:"resource"`
+	} `json:"resource_changes"`
+}
+
+// unusedKeys are the unused keys of a profile
+type unusedKeys struct {
+	created, unused int
+	oldest          time.Time
+}
+
+func pairingProfileAudit() {
+	profiles, a, err := GetPairingProfiles(pce)
+	utils.LogAPIResp("GetPairingProfiles", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	keys := pairingKeys(profiles)
+
+	data := [][]string{{HeaderHref, HeaderName, HeaderEnabled, "is_default", HeaderKeyLifespan, HeaderAllowedUsesPerKey, "unlocked_labels", "total_use_count", "last_pairing_at", "keys_created", "unused_keys", "oldest_unused_key", "findings"}}
+	remediation := [][]string{{HeaderHref, HeaderName, HeaderEnabled, HeaderKeyLifespan, HeaderAllowedUsesPerKey, HeaderRoleLabelLock, HeaderAppLabelLock, HeaderEnvLabelLock, HeaderLocLabelLock}}
+	counts := make(map[string]int)
+	for _, p := range profiles {
+		lifespan, uses, unlocked := LimitValue(p.KeyLifespan), LimitValue(p.AllowedUsesPerKey), p.unlockedLabels()
+		k := keys[p.Href]
+
+		findings := []string{}
+		if p.Enabled || includeDisabled {
+			if uses == Unlimited {
+				findings = append(findings, findingUnlimitedUses)
+			}
+			if lifespan == Unlimited {
+				findings = append(findings, findingNonExpiringKeys)
+			}
+			if len(unlocked) > 0 {
+				findings = append(findings, findingLabelOverride)
+			}
+			if k.unused > 0 {
+				findings = append(findings, findingUnusedKeys)
+			}
+		}
+		for _, f := range findings {
+			counts[f]++
+		}
+
+		oldest := ""
+		if !k.oldest.IsZero() {
+			oldest = k.oldest.Format(time.RFC3339)
+		}
+		data = append(data, []string{p.Href, p.Name, strconv.FormatBool(p.Enabled), strconv.FormatBool(p.IsDefault), lifespan, uses, strings.Join(unlocked, ";"), strconv.Itoa(p.TotalUseCount), p.LastPairingAt, strconv.Itoa(k.created), strconv.Itoa(k.unused), oldest, strings.Join(findings, ";")})
+
+		if len(findings) == 0 {
+			continue
+		}
+		if lifespan == Unlimited {
+			lifespan = strconv.Itoa(keyLifespan)
+		}
+		if uses == Unlimited {
+			uses = strconv.Itoa(usesPerKey)
+		}
+		remediation = append(remediation, []string{p.Href, p.Name, strconv.FormatBool(p.Enabled), lifespan, uses, "true", "true", "true", "true"})
+	}
+
+	utils.LogInfo(fmt.Sprintf("%d pairing profiles - %d with unlimited uses, %d with non-expiring keys, %d with label override, and %d with unused keys", len(profiles), counts[findingUnlimitedUses], counts[findingNonExpiringKeys], counts[findingLabelOverride], counts[findingUnusedKeys]), true)
+	if len(profiles) == 0 {
+		return
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-pairing-profile-audit-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	if len(remediation) > 1 {
+		if remediationFileName == "" {
+			remediationFileName = fmt.Sprintf("workloader-pairing-profile-audit-remediation-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(remediation, remediation, remediationFileName)
+		utils.LogInfo(fmt.Sprintf("review %s and run workloader pairing-profile-import %s --update-pce to tighten %d pairing profiles", remediationFileName, remediationFileName, len(remediation)-1), true)
+	}
+}
+
+// pairingKeys returns the keys created in the last key-days days for each profile and the unused keys older than stale-days days.
+// A key is unused if it has not expired and the profile has not paired a VEN since it was created.
+func pairingKeys(profiles []PairingProfile) map[string]unusedKeys {
+	keys := make(map[string]unusedKeys)
+	now := time.Now()
+
+	qp := map[string]string{"event_type": "pairing_profile.create_pairing_key", "timestamp[gte]": now.AddDate(0, 0, -keyDays).UTC().Format(time.RFC3339), "max_results": "10000"}
+	events := []pairingKeyEvent{}
+	a, err := pce.GetCollection("events", false, qp, &events)
+	if len(events) >= 500 {
+		events = nil
+		a, err = pce.GetCollection("events", true, qp, &events)
+	}
+	utils.LogAPIResp("GetCollection events", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting pairing key events - %s. skipping unused keys.", err), true)
+		return keys
+	}
+
+	profileMap := make(map[string]PairingProfile)
+	for _, p := range profiles {
+		profileMap[p.Href] = p
+	}
+	for _, e := range events {
+		for _, rc := range e.ResourceChanges {
+			for _, r := range rc.Resource {
+				p, ok := profileMap[r.Href]
+				if !ok {
+					continue
+				}
+				k := keys[p.Href]
+				k.created++
+
+				// Skip recent, expired, and used keys
+				if now.Sub(e.Timestamp) < time.Duration(staleDays)*24*time.Hour {
+					keys[p.Href] = k
+					continue
+				}
+				if lifespan := LimitValue(p.KeyLifespan); lifespan != Unlimited {
+					if seconds, err := strconv.Atoi(lifespan); err == nil && e.Timestamp.Add(time.Duration(seconds)*time.Second).Before(now) {
+						keys[p.Href] = k
+						continue
+					}
+				}
+				if lastPairing, err := time.Parse(time.RFC3339, p.LastPairingAt); err == nil && lastPairing.After(e.Timestamp) {
+					keys[p.Href] = k
+					continue
+				}
+
+				k.unused++
+				if k.oldest.IsZero() || e.Timestamp.Before(k.oldest) {
+					k.oldest = e.Timestamp
+				}
+				keys[p.Href] = k
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,236 @@
+package pairingprofileimport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/pairingprofileaudit"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var csvFile string
+var updatePCE, noPrompt bool
+var pce illumioapi.PCE
+var err error
+
+// PairingProfileImportCmd updates pairing profiles from a csv
+var PairingProfileImportCmd = &cobra.Command{
+	Use:         "pairing-profile-import [csv file to import]",
+	Short:       "Update pairing profiles from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Update pairing profiles from a CSV file.
+
+The input file requires headers and matches fields to header values. The following headers can be used for editing (other headers will be ignored):
+` + "\r\n- " + pairingprofileaudit.HeaderHref + " (required)\r\n" +
+		"- " + pairingprofileaudit.HeaderName + "\r\n" +
+		"- " + pairingprofileaudit.HeaderEnabled + " (true or false)\r\n" +
+		"- " + pairingprofileaudit.HeaderKeyLifespan + " (unlimited or seconds)\r\n" +
+		"- " + pairingprofileaudit.HeaderAllowedUsesPerKey + " (unlimited or a number)\r\n" +
+		"- " + pairingprofileaudit.HeaderRoleLabelLock + " (true or false)\r\n" +
+		"- " + pairingprofileaudit.HeaderAppLabelLock + " (true or false)\r\n" +
+		"- " + pairingprofileaudit.HeaderEnvLabelLock + " (true or false)\r\n" +
+		"- " + pairingprofileaudit.HeaderLocLabelLock + " (true or false)\r\n" + `
+
+Besides href for matching, no field is required. Blank values are not changed. Pairing profiles are not created.
+
+It's recommended to use the remediation file from pairing-profile-audit.
+
+Recommended to run without --update-pce first to log of what will change. If --update-pce is used, import will not update pairing profiles without user confirmation, unless --no-prompt is used.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.Logger.Fatalf("error getting PCE for csv command - %s", err)
+		}
+
+		// Set the CSV file
+		if len(args) != 1 {
+			fmt.Println("command requires 1 argument for the csv file. see usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+		csvFile = args[0]
+
+		// Get the debug value from viper
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		pairingProfileImport()
+	},
+}
+
+// profileUpdate is the body to update a pairing profile. Only the fields that change are set.
+type profileUpdate struct {
+	Href              string      `json:"href,omitempty"`
+	Name              *string     `json:"name,omitempty"`
+	Enabled           *bool       `json:"enabled,omitempty"`
+	KeyLifespan       interface{} `json:"key_lifespan,omitempty"`
+	AllowedUsesPerKey interface{} `json:"allowed_uses_per_key,omitempty"`
+	RoleLabelLock     *bool       `json:"role_label_lock,omitempty"`
+	AppLabelLock      *bool       `json:"app_label_lock,omitempty"`
+	EnvLabelLock      *bool       `json:"env_label_lock,omitempty"`
+	LocLabelLock      *bool       `json:"loc_label_lock,omitempty"`
+}
+
+func pairingProfileImport() {
+	// Log start of command
+	utils.LogStartCommand("pairing-profile-import")
+
+	// Parse the CSV
+	csvData, err := utils.ParseCSV(csvFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the pairing profiles
+	profiles, a, err := pairingprofileaudit.GetPairingProfiles(pce)
+	utils.LogAPIResp("GetPairingProfiles", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	profileMap := make(map[string]pairingprofileaudit.PairingProfile)
+	for _, p := range profiles {
+		profileMap[p.Href] = p
+	}
+
+	// Start the change set to hold the results
+	changes := utils.NewChangeSet[profileUpdate]("pairing profiles")
+
+	// Headers
+	headers := make(map[string]*int)
+
+	// Process each row of the CSV
+CSVEntries:
+	for i, line := range csvData {
+
+		// If it's the first row, process the headers
+		if i == 0 {
+			for c, l := range line {
+				x := c
+				headers[l] = &x
+			}
+			if headers[pairingprofileaudit.HeaderHref] == nil {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is a required header", pairingprofileaudit.HeaderHref))
+			}
+			continue
+		}
+
+		p, ok := profileMap[line[*headers[pairingprofileaudit.HeaderHref]]]
+		if !ok {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s does not exist in the PCE. skipping entry.", i+1, line[*headers[pairingprofileaudit.HeaderHref]]), true)
+			continue
+		}
+		update := profileUpdate{Href: p.Href}
+		var fields []utils.FieldChange
+
+		// Name
+		if col, ok := headers[pairingprofileaudit.HeaderName]; ok && line[*col] != "" && line[*col] != p.Name {
+			fields = append(fields, utils.DiffValue("name", p.Name, line[*col])...)
+			update.Name = &line[*col]
+		}
+
+		// Key lifespan and allowed uses per key
+		for _, limit := range []struct {
+			header  string
+			current interface{}
+			value   *interface{}
+		}{
+			{pairingprofileaudit.HeaderKeyLifespan, p.KeyLifespan, &update.KeyLifespan},
+			{pairingprofileaudit.HeaderAllowedUsesPerKey, p.AllowedUsesPerKey, &update.AllowedUsesPerKey},
+		} {
+			col, ok := headers[limit.header]
+			if !ok || line[*col] == "" {
+				continue
+			}
+			value := strings.ToLower(line[*col])
+			v, err := pairingprofileaudit.ParseLimit(value)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("csv line %d - %s - %s. skipping entry.", i+1, limit.header, err), true)
+				continue CSVEntries
+			}
+			if current := pairingprofileaudit.LimitValue(limit.current); current != value {
+				fields = append(fields, utils.DiffValue(limit.header, current, value)...)
+				*limit.value = v
+			}
+		}
+
+		// Enabled and label locks
+		for _, b := range []struct {
+			header  string
+			current bool
+			value   **bool
+		}{
+			{pairingprofileaudit.HeaderEnabled, p.Enabled, &update.Enabled},
+			{pairingprofileaudit.HeaderRoleLabelLock, p.RoleLabelLock, &update.RoleLabelLock},
+			{pairingprofileaudit.HeaderAppLabelLock, p.AppLabelLock, &update.AppLabelLock},
+			{pairingprofileaudit.HeaderEnvLabelLock, p.EnvLabelLock, &update.EnvLabelLock},
+			{pairingprofileaudit.HeaderLocLabelLock, p.LocLabelLock, &update.LocLabelLock},
+		} {
+			col, ok := headers[b.header]
+			if !ok || line[*col] == "" {
+				continue
+			}
+			v, err := strconv.ParseBool(line[*col])
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("csv line %d - %s - %s is not true or false. skipping entry.", i+1, b.header, line[*col]), true)
+				continue CSVEntries
+			}
+			if v != b.current {
+				fields = append(fields, utils.DiffValue(b.header, strconv.FormatBool(b.current), strconv.FormatBool(v))...)
+				*b.value = &v
+			}
+		}
+
+		changes.Update(update, p.Name, p.Href, fields, i+1)
+	}
+	changes.Log()
+
+	// End run if we have nothing to do
+	if changes.Empty() {
+		utils.LogInfo("nothing to be done.", true)
+		utils.LogEndCommand("pairing-profile-import")
+		return
+	}
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !updatePCE {
+		utils.LogInfo(fmt.Sprintf("workloader identified %d pairing profiles to update. See workloader.log for all identified changes. To do the import, run again using --update-pce flag", len(changes.Updates)), true)
+		utils.LogEndCommand("pairing-profile-import")
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if updatePCE && !noPrompt {
+		var prompt string
+		fmt.Printf("[PROMPT] - workloader will update %d pairing profiles in %s (%s). Do you want to run the import (yes/no)? ", len(changes.Updates), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo(fmt.Sprintf("prompt denied for updating %d pairing profiles.", len(changes.Updates)), true)
+			utils.LogEndCommand("pairing-profile-import")
+			return
+		}
+	}
+
+	// Update the pairing profiles
+	updated := 0
+	for _, u := range changes.Updates {
+		a, err := pce.Put(&u.Object)
+		utils.LogAPIResp("UpdatePairingProfile", a)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s - %d - %s", u.CSVLines[0], u.Name, a.StatusCode, err), true)
+			utils.LogWarning(a.RespBody, false)
+			utils.RecordFailure(fmt.Sprintf("updating %s", u.Name))
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("csv line %d - %s updated - status code %d", u.CSVLines[0], u.Name, a.StatusCode), true)
+		updated++
+	}
+	utils.LogInfo(fmt.Sprintf("%d pairing profiles updated", updated), true)
+	utils.LogEndCommand("pairing-profile-import")
+}
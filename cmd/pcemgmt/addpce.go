@@ -18,14 +18,23 @@ import (
 )
 
 // Set global variables for flags
-var session, useAPIKey, noAuth, proxy bool
+var session, login, useAPIKey, noAuth, proxy, oauth2, serviceAccountToken, keychain bool
+var keyName, caFile, clientCert, clientKey string
 var configFilePath string
 var err error
 
 func init() {
 	AddPCECmd.Flags().BoolVarP(&session, "session", "s", false, "authentication will be temporary session token. No API Key will be generated.")
+	AddPCECmd.Flags().BoolVarP(&login, "login", "l", false, "log in with username, password, and a one-time code if required to create an api key. prompts for the org if the user has more than one.")
+	AddPCECmd.Flags().StringVar(&keyName, "key-name", "", "name of the api key created by the pce. default is workloader-<hostname>.")
 	AddPCECmd.Flags().BoolVarP(&proxy, "proxy", "p", false, "set a proxy. can be changed later with clear-proxy and set-proxy commands.")
 	AddPCECmd.Flags().BoolVarP(&useAPIKey, "api-key", "a", false, "use pre-generated api credentials from an api key or a service account.")
+	AddPCECmd.Flags().BoolVar(&oauth2, "oauth2", false, "authenticate with an oauth2 client credentials grant (e.g., saas pces). tokens are requested and refreshed by each command.")
+	AddPCECmd.Flags().BoolVar(&serviceAccountToken, "service-account-token", false, "authenticate with a platform service account bearer token.")
+	AddPCECmd.Flags().BoolVar(&keychain, "keychain", false, "store the api key or secret in the os credential store instead of the pce.yaml file. see pce-keychain command for details.")
+	AddPCECmd.Flags().StringVar(&caFile, "ca-file", "", "pem file with the ca certificates that sign the pce certificate. added to the system roots.")
+	AddPCECmd.Flags().StringVar(&clientCert, "client-cert", "", "pem file with the client certificate for pces that require mutual tls. requires --client-key.")
+	AddPCECmd.Flags().StringVar(&clientKey, "client-key", "", "pem file with the private key for --client-cert.")
 	AddPCECmd.Flags().BoolVarP(&noAuth, "no-auth", "n", false, "do not authenticate to the pce. subsequent commands will require WORKLOADER_API_USER, WORKLOADER_API_KEY, WORKLOADER_ORG environment variables to be set.")
 	AddPCECmd.Flags().SortFlags = false
 }
@@ -49,6 +58,12 @@ to generate a session token that is valid for 10 minutes after inactivity.
 The command can be automated (avoid prompt) by setting the following environment variables:
 PCE_NAME, PCE_FQDN, PCE_PORT, PCE_USER, PCE_PWD, PCE_DISABLE_TLS, PCE_PROXY.
 
+The --oauth2 flag stores a token url, client id, client secret, and optional scope instead of an api key. The --service-account-token flag stores a bearer token. These can be automated with the PCE_TOKEN_URL, PCE_CLIENT_ID, PCE_CLIENT_SECRET, PCE_SCOPE, PCE_TOKEN, and PCE_ORG environment variables. The WORKLOADER_CLIENT_SECRET and WORKLOADER_API_TOKEN environment variables can be used at run time instead of storing the secret or token in the pce.yaml by leaving them blank.
+
+The --login (-l) flag authenticates with your username and password and creates an api key in the org you select. If the login server requires multi-factor authentication, the command prompts for the one-time code. The key is named workloader-<hostname> unless --key-name is set. The PCE_OTP and PCE_ORG environment variables can be used to avoid the prompts.
+
+The --ca-file flag sets a CA bundle for PCEs with certificates from a private CA. The --client-cert and --client-key flags set a client certificate for PCEs that require mutual TLS. They are stored as ca_file, client_cert, and client_key in the pce.yaml and can be edited there later. They require --login, --api-key, --oauth2, --service-account-token, or --no-auth.
+
 The ILLUMIO_LOGIN_SERVER environment variable can be used to specify a login server (note - rarely needed).
 
 The --update-pce and --no-prompt flags are ignored for this command.
@@ -138,6 +153,60 @@ func addPCE() {
 
 	var apiUser, apiKey, orgStr string
 	var org int
+	auth := utils.PCEAuth{Type: utils.PCEAuthAPIKey}
+	tokenAuth := oauth2 || serviceAccountToken
+	if oauth2 && serviceAccountToken {
+		utils.LogError("--oauth2 and --service-account-token cannot be used together")
+	}
+	if login && (session || useAPIKey || noAuth || tokenAuth) {
+		utils.LogError("--login cannot be used with --session, --api-key, --no-auth, --oauth2, or --service-account-token")
+	}
+
+	// Set the CA bundle and client certificate so the authentication below uses them
+	pceTLS := utils.PCETLS{CAFile: caFile, ClientCert: clientCert, ClientKey: clientKey}
+	if pceTLS.Enabled() && !login && !useAPIKey && !tokenAuth && !noAuth {
+		utils.LogError("--ca-file, --client-cert, and --client-key require --login, --api-key, --oauth2, --service-account-token, or --no-auth")
+	}
+	for _, f := range []*string{&pceTLS.CAFile, &pceTLS.ClientCert, &pceTLS.ClientKey} {
+		if *f != "" {
+			if *f, err = filepath.Abs(*f); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+	}
+	viper.Set(pceName+".ca_file", pceTLS.CAFile)
+	viper.Set(pceName+".client_cert", pceTLS.ClientCert)
+	viper.Set(pceName+".client_key", pceTLS.ClientKey)
+
+	// Get the oauth2 or token information
+	prompt := func(env, text string, hidden bool) string {
+		value := os.Getenv(env)
+		if value != "" {
+			return value
+		}
+		fmt.Print(text)
+		if hidden {
+			b, _ := term.ReadPassword(int(syscall.Stdin))
+			fmt.Println("")
+			return string(b)
+		}
+		fmt.Scanln(&value)
+		return value
+	}
+	if oauth2 {
+		auth = utils.PCEAuth{Type: utils.PCEAuthOAuth2}
+		auth.TokenURL = prompt("PCE_TOKEN_URL", "OAuth2 Token URL: ", false)
+		auth.ClientID = prompt("PCE_CLIENT_ID", "Client ID: ", false)
+		auth.ClientSecret = prompt("PCE_CLIENT_SECRET", "Client Secret (blank to use WORKLOADER_CLIENT_SECRET at run time): ", true)
+		auth.Scope = prompt("PCE_SCOPE", "Scope (optional): ", false)
+	}
+	if serviceAccountToken {
+		auth = utils.PCEAuth{Type: utils.PCEAuthToken}
+		auth.Token = prompt("PCE_TOKEN", "Service Account Token (blank to use WORKLOADER_API_TOKEN at run time): ", true)
+	}
+	if tokenAuth {
+		orgStr = os.Getenv("PCE_ORG")
+	}
 
 	// Get api key information if flag is set
 	if useAPIKey {
@@ -149,10 +218,12 @@ func addPCE() {
 
 	}
 
-	// Get the org if using an api key or not authenticating
-	if useAPIKey || noAuth {
+	// Get the org if using an api key, a token, or not authenticating
+	if (useAPIKey || noAuth || tokenAuth) && orgStr == "" {
 		fmt.Print("Org: ")
 		fmt.Scanln(&orgStr)
+	}
+	if useAPIKey || noAuth || tokenAuth {
 		org, err = strconv.Atoi(orgStr)
 		if err != nil {
 			utils.LogError(err.Error())
@@ -160,7 +231,7 @@ func addPCE() {
 	}
 
 	// If not using an API key or skipping auth, get the email and password
-	if !noAuth && !useAPIKey {
+	if !noAuth && !useAPIKey && !tokenAuth {
 		user = os.Getenv("PCE_USER")
 		if user == "" {
 			fmt.Print("Email: ")
@@ -203,15 +274,42 @@ func addPCE() {
 		pce.User = apiUser
 		pce.Key = apiKey
 		pce.DisableTLSChecking = disableTLS
-		_, api, _ := pce.GetVersion()
+		check := pce
+		check.FriendlyName = pceName
+		if pceTLS.Enabled() {
+			if err := utils.StartPCERetryProxy(&check, utils.APIRetryConfig{}); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+		_, api, _ := check.GetVersion()
+		if api.StatusCode != 200 {
+			utils.LogError(fmt.Sprintf("checking credentials by getting PCE version returned a status code of %d.", api.StatusCode))
+		}
+	}
+
+	// If using a token, check authentication through the token proxy
+	if tokenAuth {
+		check := illumioapi.PCE{FriendlyName: pceName, FQDN: fqdn, Port: port, Proxy: proxyServer, Org: org, DisableTLSChecking: disableTLS}
+		checkAuth := auth
+		if checkAuth.ClientSecret == "" {
+			checkAuth.ClientSecret = os.Getenv("WORKLOADER_CLIENT_SECRET")
+		}
+		if checkAuth.Token == "" {
+			checkAuth.Token = os.Getenv("WORKLOADER_API_TOKEN")
+		}
+		if err := utils.StartPCEAuthProxy(&check, checkAuth); err != nil {
+			utils.LogError(err.Error())
+		}
+		_, api, _ := check.GetVersion()
 		if api.StatusCode != 200 {
 			utils.LogError(fmt.Sprintf("checking credentials by getting PCE version returned a status code of %d.", api.StatusCode))
 		}
+		pce = illumioapi.PCE{FQDN: fqdn, Port: port, Proxy: proxyServer, Org: org, DisableTLSChecking: disableTLS}
 	}
 
 	// Process session if set
 	var apiResponses []illumioapi.APIResponse
-	if !noAuth {
+	if !noAuth && !tokenAuth {
 		// Generate session credentials if session flag set
 		if session {
 			fmt.Println("\r\nAuthenticating ...")
@@ -231,7 +329,13 @@ func addPCE() {
 				fmt.Println("\r\nAuthenticating and generating API Credentials...")
 			}
 			pce = illumioapi.PCE{FQDN: fqdn, Port: port, DisableTLSChecking: disableTLS}
-			userLogin, apiResponses, err = pce.LoginAPIKey(user, pwd, "workloader", "created by workloader")
+			if login {
+				pce.FriendlyName = pceName
+				pce.Proxy = proxyServer
+				userLogin, apiResponses, err = utils.PCELoginAPIKey(&pce, loginInput(user, pwd))
+			} else {
+				userLogin, apiResponses, err = pce.LoginAPIKey(user, pwd, "workloader", "created by workloader")
+			}
 			for _, a := range apiResponses {
 				utils.LogAPIResp("LoginAPIKey", a)
 			}
@@ -253,6 +357,21 @@ func addPCE() {
 	viper.Set(pceName+".disableTLSChecking", pce.DisableTLSChecking)
 	viper.Set(pceName+".userHref", userLogin.Href)
 	viper.Set(pceName+".proxy", pce.Proxy)
+	viper.Set(pceName+".auth_type", auth.Type)
+	viper.Set(pceName+".token_url", auth.TokenURL)
+	viper.Set(pceName+".client_id", auth.ClientID)
+	viper.Set(pceName+".client_secret", auth.ClientSecret)
+	viper.Set(pceName+".scope", auth.Scope)
+	viper.Set(pceName+".token", auth.Token)
+	viper.Set(pceName+".credential_store", "")
+	if keychain {
+		migrateKeychain(pceName)
+	}
+	if utils.EncryptionEnabled() {
+		if err := utils.EncryptPCESecrets(pceName); err != nil {
+			utils.LogError(err.Error())
+		}
+	}
 	if !viper.IsSet("max_entries_for_stdout") {
 		viper.Set("max_entries_for_stdout", 100)
 	}
@@ -272,3 +391,37 @@ func addPCE() {
 	}
 	utils.LogEndCommand("pce-add")
 }
+
+// loginInput builds the input for the --login flow. The one-time code and org are prompted for only when needed.
+func loginInput(user, pwd string) utils.PCELoginInput {
+	input := utils.PCELoginInput{User: user, Password: pwd, OTP: os.Getenv("PCE_OTP"), KeyName: keyName, KeyDescription: "created by workloader pce-add --login"}
+	if input.KeyName == "" {
+		hostname, _ := os.Hostname()
+		input.KeyName = "workloader-" + hostname
+	}
+	if orgStr := os.Getenv("PCE_ORG"); orgStr != "" {
+		if input.Org, err = strconv.Atoi(orgStr); err != nil {
+			utils.LogError(fmt.Sprintf("PCE_ORG - %s", err))
+		}
+	}
+	input.PromptOTP = func() string {
+		var otp string
+		fmt.Print("One-time code: ")
+		fmt.Scanln(&otp)
+		return strings.TrimSpace(otp)
+	}
+	input.SelectOrg = func(orgs []*illumioapi.Org) int {
+		fmt.Println("\r\nOrgs:")
+		for i, o := range orgs {
+			fmt.Printf("%d) %s (org %d)\r\n", i+1, o.DisplayName, o.ID)
+		}
+		var choice int
+		fmt.Print("Select an org [1]: ")
+		fmt.Scanln(&choice)
+		if choice < 1 || choice > len(orgs) {
+			choice = 1
+		}
+		return orgs[choice-1].ID
+	}
+	return input
+}
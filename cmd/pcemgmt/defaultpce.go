@@ -1,9 +1,12 @@
 package pcemgmt
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/brian1917/workloader/utils"
 
@@ -28,7 +31,7 @@ Changes the default PCE to be used for all commands targeting a single PCE (i.e.
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the name of the new deafult PCE. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		newDefaultPCE := args[0]
 
@@ -63,17 +66,87 @@ Get the default PCE to be used for all commands targeting a single PCE (i.e., do
 
 		utils.LogStartCommand("get-default")
 
-		fmt.Printf("%s - %s\r\n", viper.Get("default_pce_name").(string), viper.Get(viper.Get("default_pce_name").(string)+".fqdn").(string))
+		name := utils.DefaultPCEName()
+		fqdn := viper.GetString(name + ".fqdn")
+		if utils.IsEnvPCE(name) {
+			pce, _, _ := utils.GetEnvPCE(name)
+			fqdn = pce.FQDN
+		}
+		fmt.Printf("%s - %s\r\n", name, fqdn)
 
 		utils.LogEndCommand("get-default")
 
 	},
 }
 
+// Set global variables for flags
+var testPCEs bool
+var listFormat string
+
+func init() {
+	PCEListCmd.Flags().BoolVar(&testPCEs, "test", false, "test each pce for connectivity, authentication, version, and latency.")
+	PCEListCmd.Flags().StringVar(&listFormat, "format", "text", "output format. text or json.")
+	PCEListCmd.Flags().SortFlags = false
+}
+
+// pceListOrg is an org of a PCE in the pce-list output
+type pceListOrg struct {
+	Org  int    `json:"org"`
+	Name string `json:"name,omitempty"`
+}
+
+// pceListEntry is a PCE in the pce-list output
+type pceListEntry struct {
+	Name          string       `json:"name"`
+	FQDN          string       `json:"fqdn"`
+	Port          int          `json:"port"`
+	Org           int          `json:"org"`
+	Orgs          []pceListOrg `json:"orgs,omitempty"`
+	Default       bool         `json:"default"`
+	AuthType      string       `json:"auth_type"`
+	ProxyPath     string       `json:"proxy_path"`
+	Env           bool         `json:"env"`
+	Tested        bool         `json:"tested"`
+	Reachable     bool         `json:"reachable,omitempty"`
+	Authenticated bool         `json:"authenticated,omitempty"`
+	Version       string       `json:"version,omitempty"`
+	LatencyMS     int64        `json:"latency_ms,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// testPCE checks that a PCE is reachable and the credentials work. Latency is the time for an authenticated api call.
+func testPCE(entry *pceListEntry) {
+	entry.Tested = true
+	pce, err := utils.GetPCEbyName(entry.Name, false)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	entry.Reachable = true
+	entry.Version = fmt.Sprintf("%d.%d.%d-%d", pce.Version.Major, pce.Version.Minor, pce.Version.Patch, pce.Version.Build)
+	start := time.Now()
+	_, api, err := pce.GetLabels(map[string]string{"max_results": "1"})
+	entry.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil || api.StatusCode != 200 {
+		entry.Error = fmt.Sprintf("authentication check returned a status code of %d", api.StatusCode)
+		if err != nil {
+			entry.Error = fmt.Sprintf("authentication check - %s", err)
+		}
+		return
+	}
+	entry.Authenticated = true
+}
+
 // PCEListCmd gets all PCEs
 var PCEListCmd = &cobra.Command{
 	Use:   "pce-list",
 	Short: "List all PCEs in pce.yaml.",
+	Long: `
+List all PCEs in pce.yaml. The default PCE is marked with an asterisk.
+
+Use --test to check each PCE. The test gets the PCE version to check connectivity and makes an authenticated api call to check the credentials and measure latency. The proxy path is the proxy from pce.yaml or direct.
+
+Use --format json for output that can be parsed by scripts. The json is an array of PCEs with name, fqdn, port, org, orgs, default, auth_type, proxy_path, env (defined by environment variables), and tested. Tested PCEs also include reachable, authenticated, version, latency_ms, and error.`,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		configFilePath, err = filepath.Abs(viper.ConfigFileUsed())
 		if err != nil {
@@ -82,39 +155,84 @@ var PCEListCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 
-		allSettings := viper.AllSettings()
+		listFormat = strings.ToLower(listFormat)
+		if listFormat != "text" && listFormat != "json" {
+			utils.LogError("invalid format - must be text or json.")
+		}
+
+		defaultPCEName := utils.DefaultPCEName()
 
-		defaultPCEName := ""
-		if viper.Get("default_pce_name") != nil {
-			defaultPCEName = viper.Get("default_pce_name").(string)
+		pceNames := GetAllPCENames()
+		sort.Strings(pceNames)
+		entries := []pceListEntry{}
+		for _, k := range pceNames {
+			entry := pceListEntry{Name: k, FQDN: viper.GetString(k + ".fqdn"), Port: viper.GetInt(k + ".port"), Org: viper.GetInt(k + ".org"), Default: k == defaultPCEName, AuthType: utils.GetPCEAuth(k).Type, ProxyPath: "direct"}
+			if viper.GetString(k+".proxy") != "" {
+				entry.ProxyPath = viper.GetString(k + ".proxy")
+			}
+			if utils.IsEnvPCE(k) {
+				pce, auth, _ := utils.GetEnvPCE(k)
+				entry = pceListEntry{Name: k, FQDN: pce.FQDN, Port: pce.Port, Org: pce.Org, Default: k == defaultPCEName, AuthType: auth.Type, ProxyPath: "direct", Env: true}
+				if pce.Proxy != "" {
+					entry.ProxyPath = pce.Proxy
+				}
+			}
+			if viper.IsSet(k + ".orgs") {
+				orgs, err := utils.GetPCEOrgs(k)
+				if err != nil {
+					utils.LogError(err.Error())
+				}
+				for _, o := range orgs {
+					entry.Orgs = append(entry.Orgs, pceListOrg{Org: o.Org, Name: o.Name})
+				}
+			}
+			if testPCEs {
+				testPCE(&entry)
+				utils.LogInfo(fmt.Sprintf("pce-list test - %s - reachable: %t - authenticated: %t - %s", k, entry.Reachable, entry.Authenticated, entry.Error), false)
+			}
+			entries = append(entries, entry)
 		}
 
-		count := 0
-		for k := range allSettings {
-			if viper.Get(k+".fqdn") != nil {
-				if k == defaultPCEName {
-					fmt.Printf("* %s (%s)\r\n", k, viper.Get(k+".fqdn").(string))
-					count++
+		if listFormat == "json" {
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		for _, e := range entries {
+			marker := " "
+			if e.Default {
+				marker = "*"
+			}
+			fmt.Printf("%s %s (%s)\r\n", marker, e.Name, e.FQDN)
+			if e.Tested {
+				if e.Authenticated {
+					fmt.Printf("    ok - version %s - %dms - %s\r\n", e.Version, e.LatencyMS, e.ProxyPath)
 				} else {
-					fmt.Printf("  %s (%s)\r\n", k, viper.Get(k+".fqdn").(string))
-					count++
+					fmt.Printf("    failed - %s - %s\r\n", e.Error, e.ProxyPath)
 				}
 			}
+			for _, o := range e.Orgs {
+				fmt.Printf("    org %d %s\r\n", o.Org, o.Name)
+			}
 		}
-		if count == 0 {
+		if len(entries) == 0 {
 			utils.LogInfo("no pce configured. run pce-add to add a pce to pce.yaml file.", true)
 		}
 
 	},
 }
 
-// GetAllPCEnames returns PCE names in the pce.yaml file
+// GetAllPCEnames returns PCE names in the pce.yaml file and PCEs defined by environment variables
 func GetAllPCENames() (pceNames []string) {
 	allSettings := viper.AllSettings()
 	for k := range allSettings {
-		if viper.Get(k+".fqdn") != nil {
+		if viper.Get(k+".fqdn") != nil && !utils.IsEnvPCE(k) {
 			pceNames = append(pceNames, k)
 		}
 	}
-	return pceNames
+	return append(pceNames, utils.EnvPCENames()...)
 }
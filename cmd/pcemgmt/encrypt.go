@@ -0,0 +1,107 @@
+package pcemgmt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/brian1917/workloader/utils"
+	"golang.org/x/term"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Set global variables for flags
+var osKey, decrypt bool
+
+func init() {
+	PCEEncryptCmd.Flags().BoolVar(&osKey, "os-key", false, "encrypt with a random key stored in the os credential store instead of a passphrase.")
+	PCEEncryptCmd.Flags().BoolVar(&decrypt, "decrypt", false, "decrypt the secrets and remove encryption from the pce.yaml file.")
+	PCEEncryptCmd.Flags().SortFlags = false
+}
+
+// PCEEncryptCmd encrypts the secrets in pce.yaml
+var PCEEncryptCmd = &cobra.Command{
+	Use:   "pce-encrypt",
+	Short: "Encrypt the api keys and secrets in the pce.yaml file.",
+	Long: `
+Encrypt the api keys and secrets in the pce.yaml file.
+
+The api keys, oauth2 client secrets, service account tokens, and org keys of all pces are encrypted with AES-256-GCM. By default, the key is derived from a passphrase. Commands prompt for the passphrase or read it from the WORKLOADER_KEY environment variable. Use --os-key to encrypt with a random key stored in the os credential store (macOS Keychain, Windows Credential Manager, or libsecret) so no passphrase is needed but the file can only be decrypted on this machine.
+
+Running the command again encrypts secrets added since the last run. pce-add encrypts new pces automatically when the pce.yaml file is encrypted.
+
+Use --decrypt to decrypt the secrets and remove encryption.
+
+The --update-pce and --no-prompt flags are ignored for this command.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		configFilePath, err = filepath.Abs(viper.ConfigFileUsed())
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		utils.LogStartCommand("pce-encrypt")
+
+		if decrypt {
+			if !utils.EncryptionEnabled() {
+				utils.LogError(fmt.Sprintf("%s is not encrypted", configFilePath))
+			}
+			for _, name := range GetAllPCENames() {
+				if err := utils.DecryptPCESecrets(name); err != nil {
+					utils.LogError(err.Error())
+				}
+			}
+			utils.DisableEncryption()
+		} else {
+			if !utils.EncryptionEnabled() {
+				mode := utils.EncryptionPassphrase
+				passphrase := ""
+				if osKey {
+					mode = utils.EncryptionOSKey
+				} else {
+					passphrase = newPassphrase()
+				}
+				if err := utils.EnableEncryption(mode, passphrase); err != nil {
+					utils.LogError(err.Error())
+				}
+			}
+			for _, name := range GetAllPCENames() {
+				if err := utils.EncryptPCESecrets(name); err != nil {
+					utils.LogError(err.Error())
+				}
+			}
+		}
+
+		if err := viper.WriteConfig(); err != nil {
+			utils.LogError(err.Error())
+		}
+		if decrypt {
+			utils.LogInfo(fmt.Sprintf("decrypted secrets in %s", configFilePath), true)
+		} else {
+			utils.LogInfo(fmt.Sprintf("encrypted secrets in %s", configFilePath), true)
+		}
+
+		utils.LogEndCommand("pce-encrypt")
+	},
+}
+
+// newPassphrase reads a new passphrase from WORKLOADER_KEY or prompts for it twice
+func newPassphrase() string {
+	if os.Getenv("WORKLOADER_KEY") != "" {
+		return os.Getenv("WORKLOADER_KEY")
+	}
+	fmt.Print("New passphrase: ")
+	first, _ := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println("")
+	fmt.Print("Confirm passphrase: ")
+	second, _ := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println("")
+	if string(first) != string(second) {
+		utils.LogError("passphrases do not match")
+	}
+	return string(first)
+}
@@ -0,0 +1,118 @@
+package pcemgmt
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/brian1917/workloader/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Set global variables for flags
+var allPCEs, revert bool
+
+func init() {
+	PCEKeychainCmd.Flags().BoolVar(&allPCEs, "all", false, "migrate all pces in the pce.yaml file.")
+	PCEKeychainCmd.Flags().BoolVar(&revert, "revert", false, "move the secrets from the os credential store back to the pce.yaml file.")
+	PCEKeychainCmd.Flags().SortFlags = false
+}
+
+// PCEKeychainCmd moves PCE secrets from pce.yaml to the OS credential store
+var PCEKeychainCmd = &cobra.Command{
+	Use:   "pce-keychain [name of pce]",
+	Short: "Move pce api keys and secrets from pce.yaml to the os credential store.",
+	Long: `
+Move pce api keys and secrets from pce.yaml to the os credential store.
+
+The credential store is the macOS Keychain, the Windows Credential Manager, or the secret service (libsecret) on Linux. Linux requires the secret-tool command.
+
+The api key, oauth2 client secret, and service account token are moved. They are removed from the pce.yaml file and the pce is marked with credential_store: keychain. Commands read the secrets from the credential store automatically.
+
+Use --all to migrate every pce in the pce.yaml file. Use --revert to move the secrets back to the pce.yaml file.
+
+New pces can be stored in the credential store with pce-add --keychain.
+
+The --update-pce and --no-prompt flags are ignored for this command.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		configFilePath, err = filepath.Abs(viper.ConfigFileUsed())
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pceNames := []string{}
+		if allPCEs {
+			pceNames = GetAllPCENames()
+		} else if len(args) == 1 {
+			pceNames = append(pceNames, args[0])
+		} else {
+			fmt.Println("Command requires 1 argument for the name of the PCE or the --all flag. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+
+		utils.LogStartCommand("pce-keychain")
+		for _, name := range pceNames {
+			if !viper.IsSet(name + ".fqdn") {
+				utils.LogError(fmt.Sprintf("%s is not in %s", name, configFilePath))
+			}
+			if revert {
+				revertKeychain(name)
+			} else {
+				migrateKeychain(name)
+			}
+		}
+		if err := viper.WriteConfig(); err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogEndCommand("pce-keychain")
+	},
+}
+
+// migrateKeychain moves the secrets of a pce to the os credential store. The caller writes the config.
+func migrateKeychain(name string) {
+	count := 0
+	for _, field := range utils.KeychainSecrets {
+		value, err := utils.DecryptSecret(viper.GetString(name + "." + field))
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		if value == "" {
+			continue
+		}
+		if err := utils.KeychainSet(name, field, value); err != nil {
+			utils.LogError(err.Error())
+		}
+		viper.Set(name+"."+field, "")
+		count++
+	}
+	viper.Set(name+".credential_store", utils.CredentialStoreKeychain)
+	utils.LogInfo(fmt.Sprintf("%s - moved %d secrets to the os credential store", name, count), true)
+}
+
+// revertKeychain moves the secrets of a pce from the os credential store to the pce.yaml. The caller writes the config.
+func revertKeychain(name string) {
+	if !utils.UsesKeychain(name) {
+		utils.LogInfo(fmt.Sprintf("%s does not use the os credential store. skipping.", name), true)
+		return
+	}
+	count := 0
+	for _, field := range utils.KeychainSecrets {
+		value, err := utils.KeychainGet(name, field)
+		if err != nil || value == "" {
+			continue
+		}
+		if utils.EncryptionEnabled() {
+			if value, err = utils.EncryptSecret(value); err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+		viper.Set(name+"."+field, value)
+		utils.KeychainDelete(name, field)
+		count++
+	}
+	viper.Set(name+".credential_store", "")
+	utils.LogInfo(fmt.Sprintf("%s - moved %d secrets to %s", name, count, configFilePath), true)
+}
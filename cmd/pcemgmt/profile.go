@@ -0,0 +1,56 @@
+package pcemgmt
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/brian1917/workloader/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ProfileListCmd lists the config profiles
+var ProfileListCmd = &cobra.Command{
+	Use:   "profile-list",
+	Short: "List config profiles.",
+	Long: `
+List config profiles.
+
+A profile is a complete config file with its own PCEs, default PCE, and output settings. Select a profile with the --profile flag or the WORKLOADER_PROFILE environment variable. The flag takes precedence over the environment variable, which takes precedence over ILLUMIO_CONFIG.
+
+Named profiles are stored in ~/.workloader/profiles/<name>/pce.yaml. Set WORKLOADER_PROFILES_DIR for a different location. The --profile value can also be a directory with a pce.yaml or the path to a yaml file.
+
+Create a profile by running pce-add with a new profile name:
+workloader pce-add --profile customer-a
+
+Optional output settings in a profile's pce.yaml:
+default_out: both          # output format when --out is not set
+output_dir: /data/cust-a   # directory for output files without a path
+
+The active profile is marked with an asterisk.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		configFilePath, err = filepath.Abs(viper.ConfigFileUsed())
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		profiles, err := utils.GetProfiles()
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, p := range profiles {
+			if p == utils.ActiveProfile {
+				fmt.Printf("* %s\r\n", p)
+			} else {
+				fmt.Printf("  %s\r\n", p)
+			}
+		}
+		if len(profiles) == 0 {
+			utils.LogInfo(fmt.Sprintf("no profiles in %s. run pce-add --profile <name> to create one.", utils.ProfilesDir()), true)
+		}
+		fmt.Printf("\r\nconfig file: %s\r\n", configFilePath)
+	},
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/brian1917/workloader/utils"
@@ -37,7 +36,7 @@ var RemovePCECmd = &cobra.Command{
 		// Get Name of PCE
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the name of the PCE to logout. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		pceName = args[0]
 
@@ -90,6 +89,13 @@ func removePce() {
 		utils.LogInfo(fmt.Sprintf("deleted api key: %s", saveHref), true)
 	}
 
+	// Remove secrets from the os credential store
+	if utils.UsesKeychain(pceName) {
+		for _, field := range utils.KeychainSecrets {
+			utils.KeychainDelete(pceName, field)
+		}
+	}
+
 	// Remove login information from YAML
 	configMap := viper.AllSettings()
 	delete(configMap, pceName)
@@ -0,0 +1,201 @@
+package pcemgmt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Set global variables for flags
+var rotateKeyName, rotateOutputFile string
+var keepOldKey bool
+var warnDays int
+
+func init() {
+	PCERotateKeyCmd.Flags().StringVar(&rotateKeyName, "key-name", "", "name of the new api key. default is workloader-<hostname>.")
+	PCERotateKeyCmd.Flags().BoolVar(&keepOldKey, "keep-old", false, "do not revoke the old api key after the new one is verified.")
+	PCERotateKeyCmd.Flags().IntVar(&warnDays, "warn-days", 30, "warn about api keys that expire within this many days.")
+	PCERotateKeyCmd.Flags().StringVar(&rotateOutputFile, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	PCERotateKeyCmd.Flags().SortFlags = false
+}
+
+// PCERotateKeyCmd creates a new api key for a pce and revokes the old one
+var PCERotateKeyCmd = &cobra.Command{
+	Use:         "pce-rotate-key [name of pce]",
+	Short:       "Replace the api key for a pce and report api keys nearing expiry.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Replace the api key for a pce and report api keys nearing expiry.
+
+The command uses the current credentials to list the api keys of the api user. The output reports each key's creation date, expiration date, and days until expiry. Keys that expire within --warn-days are logged as warnings. PCEs that do not enforce api key lifetimes have no expiration date.
+
+With --update-pce, the command creates a new api key, verifies it, saves it to the pce.yaml file, and revokes the old key. The new key is stored where the old one was: the os credential store if the pce uses pce-keychain, encrypted if the pce.yaml file is encrypted, or in plain text. Use --keep-old to keep the old key active. The pce.yaml file is written before the old key is revoked so the credentials are never lost.
+
+If no pce is provided, the --pce flag or the default pce is used. Only pces that authenticate with an api key can be rotated. PCEs from environment variables are reported but not rotated.
+
+Recommended to run without --update-pce first to review the keys.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		configFilePath, err = filepath.Abs(viper.ConfigFileUsed())
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		name := viper.GetString("target_pce")
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if name == "" {
+			name = utils.DefaultPCEName()
+		}
+		if name == "" {
+			fmt.Println("Command requires 1 argument for the name of the PCE or a default PCE. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+
+		rotateKey(name, viper.Get("update_pce").(bool), viper.Get("no_prompt").(bool))
+	},
+}
+
+// rotateKey reports the api keys of a pce and replaces the current key if updatePCE is set
+func rotateKey(name string, updatePCE, noPrompt bool) {
+
+	utils.LogStartCommand("pce-rotate-key")
+
+	if auth := utils.GetPCEAuth(name); auth.Type != utils.PCEAuthAPIKey && !utils.IsEnvPCE(name) {
+		utils.LogError(fmt.Sprintf("%s uses %s authentication. only api keys can be rotated.", name, auth.Type))
+	}
+
+	pce, err := utils.GetPCEbyName(name, false)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	userHref, keys, err := utils.GetPCEAPIKeys(pce)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Build the report
+	data := [][]string{{"name", "key_id", "auth_username", "created_at", "expires_at", "days_until_expiry", "in_use", "status"}}
+	var oldHref string
+	for _, k := range keys {
+		inUse := k.AuthUsername == pce.User
+		if inUse {
+			oldHref = k.Href
+		}
+		days, status := "", "no expiration"
+		if expiry, ok := k.Expiry(); ok {
+			d := int(time.Until(expiry).Hours() / 24)
+			days = fmt.Sprintf("%d", d)
+			switch {
+			case time.Now().After(expiry):
+				status = "expired"
+			case d <= warnDays:
+				status = "expiring"
+				utils.LogWarning(fmt.Sprintf("%s - api key %s (%s) expires in %d days", name, k.Name, k.AuthUsername, d), true)
+			default:
+				status = "ok"
+			}
+		}
+		data = append(data, []string{k.Name, k.KeyID, k.AuthUsername, k.CreatedAt, k.ExpiresAt, days, fmt.Sprintf("%t", inUse), status})
+	}
+	if rotateOutputFile == "" {
+		rotateOutputFile = fmt.Sprintf("workloader-pce-rotate-key-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, rotateOutputFile)
+	utils.LogInfo(fmt.Sprintf("%s - %d api keys for %s", name, len(keys), userHref), true)
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !updatePCE {
+		utils.LogInfo("See the output file for the api keys. To rotate the current key, run again using --update-pce flag.", true)
+		utils.LogEndCommand("pce-rotate-key")
+		return
+	}
+
+	if utils.IsEnvPCE(name) {
+		utils.LogError(fmt.Sprintf("%s is defined by environment variables. create a new key in the pce and update the environment variables.", name))
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if !noPrompt {
+		var prompt string
+		oldKeyAction := "revoke the old key"
+		if keepOldKey {
+			oldKeyAction = "keep the old key"
+		}
+		fmt.Printf("%s [PROMPT] - workloader will create a new api key for %s (%s), save it to %s, and %s. Do you want to rotate the key? (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), name, viper.GetString(name+".fqdn"), configFilePath, oldKeyAction)
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied to rotate the api key.", true)
+			utils.LogEndCommand("pce-rotate-key")
+			return
+		}
+	}
+
+	// Create the new key
+	if rotateKeyName == "" {
+		hostname, _ := os.Hostname()
+		rotateKeyName = "workloader-" + hostname
+	}
+	newKey, api, err := utils.CreatePCEAPIKey(pce, userHref, rotateKeyName, fmt.Sprintf("created by workloader pce-rotate-key on %s", time.Now().Format("2006-01-02")))
+	utils.LogAPIResp("CreateAPIKey", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%s - created api key %s (%s)", name, newKey.Name, newKey.AuthUsername), true)
+
+	// Verify the new key before saving it
+	verify := pce
+	verify.User, verify.Key = newKey.AuthUsername, newKey.Secret
+	if _, _, err := utils.GetPCEAPIKeys(verify); err != nil {
+		utils.LogError(fmt.Sprintf("verifying new api key %s. the old key is still in use - %s", newKey.AuthUsername, err))
+	}
+	utils.LogInfo(fmt.Sprintf("%s - verified api key %s", name, newKey.AuthUsername), true)
+
+	// Save the new key where the old one was stored
+	secret := newKey.Secret
+	if utils.UsesKeychain(name) {
+		if err := utils.KeychainSet(name, "key", secret); err != nil {
+			utils.LogError(fmt.Sprintf("storing new api key %s in the os credential store - %s", newKey.AuthUsername, err))
+		}
+		secret = ""
+	} else if utils.EncryptionEnabled() {
+		if secret, err = utils.EncryptSecret(secret); err != nil {
+			utils.LogError(fmt.Sprintf("encrypting new api key %s - %s", newKey.AuthUsername, err))
+		}
+	}
+	viper.Set(name+".user", newKey.AuthUsername)
+	viper.Set(name+".key", secret)
+	viper.Set(name+".userHref", userHref)
+	if err := utils.WriteConfig(); err != nil {
+		utils.LogError(fmt.Sprintf("saving new api key %s. the old key was not revoked - %s", newKey.AuthUsername, err))
+	}
+	utils.LogInfo(fmt.Sprintf("%s - saved api key %s to %s", name, newKey.AuthUsername, configFilePath), true)
+
+	// Revoke the old key with the new credentials
+	if keepOldKey || oldHref == "" {
+		if oldHref == "" {
+			utils.LogWarning(fmt.Sprintf("%s - old api key %s not found in the api keys for %s. revoke it manually.", name, pce.User, userHref), true)
+		}
+		utils.LogEndCommand("pce-rotate-key")
+		return
+	}
+	api, err = verify.DeleteHref(oldHref)
+	utils.LogAPIResp("DeleteAPIKey", api)
+	if err != nil || api.StatusCode != 204 {
+		utils.LogWarning(fmt.Sprintf("%s - revoking old api key %s - %d - %v. revoke it manually.", name, oldHref, api.StatusCode, err), true)
+	} else {
+		utils.LogInfo(fmt.Sprintf("%s - revoked old api key %s", name, pce.User), true)
+	}
+
+	utils.LogEndCommand("pce-rotate-key")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+// addPluginCommands adds a command for each plugin. Built-in commands take precedence over plugins with the same name.
+func addPluginCommands() {
+	for _, p := range utils.FindPlugins() {
+		if c, _, err := RootCmd.Find([]string{p.Name}); err == nil && c != RootCmd {
+			utils.LogDebug(fmt.Sprintf("plugin %s is not used because %s is a built-in command", p.Path, p.Name))
+			continue
+		}
+		RootCmd.AddCommand(pluginCmd(p))
+	}
+}
+
+// pluginCmd returns the command that runs a plugin. Global flags are read by workloader and passed to the plugin in the environment.
+// All other arguments are passed to the plugin.
+func pluginCmd(p utils.Plugin) *cobra.Command {
+	var pluginArgs []string
+	return &cobra.Command{
+		Use:                strings.TrimSpace(p.Name + " " + p.Usage),
+		Short:              p.Short,
+		Long:               p.Long,
+		Annotations:        map[string]string{"plugin": p.Path},
+		DisableFlagParsing: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			var err error
+			if pluginArgs, err = globalFlags(args); err != nil {
+				utils.LogError(err.Error())
+			}
+			RootCmd.PersistentPreRun(cmd, pluginArgs)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			utils.LogDebug(fmt.Sprintf("running plugin %s with args %s", p.Path, strings.Join(pluginArgs, " ")))
+			plugin := exec.Command(p.Path, pluginArgs...)
+			plugin.Stdin, plugin.Stdout, plugin.Stderr = os.Stdin, os.Stdout, os.Stderr
+			plugin.Env = utils.PluginEnv(p)
+			if err := plugin.Run(); err != nil {
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					utils.Exit(cmd.Name(), exitErr.ExitCode())
+				}
+				utils.LogError(fmt.Sprintf("running plugin %s - %s", p.Path, err))
+			}
+		},
+	}
+}
+
+// globalFlags sets the global flags in args and returns the remaining args. Arguments after -- are not checked.
+func globalFlags(args []string) ([]string, error) {
+	remaining := []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			return append(remaining, args[i+1:]...), nil
+		}
+		if !strings.HasPrefix(args[i], "--") {
+			remaining = append(remaining, args[i])
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		flag := RootCmd.PersistentFlags().Lookup(name)
+		if flag == nil {
+			remaining = append(remaining, args[i])
+			continue
+		}
+		if !hasValue {
+			if flag.Value.Type() == "bool" {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return nil, fmt.Errorf("flag needs an argument: --%s", name)
+			}
+		}
+		if err := RootCmd.PersistentFlags().Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid argument %q for --%s - %s", value, name, err)
+		}
+	}
+	return remaining, nil
+}
+
+// pluginListCmd lists the plugins
+var pluginListCmd = &cobra.Command{
+	Use:   "plugin-list",
+	Short: "List plugin commands.",
+	Long: `
+List plugin commands.
+
+A plugin is an executable named workloader-<command> in ~/.workloader/plugins (or WORKLOADER_PLUGINS_DIR) or PATH. It runs as workloader <command> with the remaining arguments. Built-in commands take precedence over plugins with the same name.
+
+An optional manifest next to the executable with the same name and a .yaml extension (e.g., workloader-foo.yaml) sets the help text with short, long, and usage (the arguments after the command name) keys.
+
+Global flags (e.g., --pce, --update-pce, --no-prompt, --format) are read by workloader and passed to the plugin in WORKLOADER_PLUGIN_ environment variables (e.g., WORKLOADER_PLUGIN_TARGET_PCE and WORKLOADER_PLUGIN_UPDATE_PCE). ILLUMIO_CONFIG is the config file and WORKLOADER_EXECUTABLE is the workloader executable. Plugins written in Go can call utils.PluginInit() to use the same PCEs, logging, and output options as built-in commands.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		utils.LogStartCommand("plugin-list")
+
+		data := [][]string{{"command", "path", "manifest", "description"}}
+		for _, p := range utils.FindPlugins() {
+			if c, _, err := RootCmd.Find([]string{p.Name}); err == nil && c.Annotations["plugin"] == p.Path {
+				data = append(data, []string{p.Name, p.Path, p.Manifest, p.Short})
+			}
+		}
+		if len(data) == 1 {
+			utils.LogInfo(fmt.Sprintf("no plugins found in %s or PATH", utils.PluginsDir()), true)
+		} else {
+			utils.WriteOutput(data, data, fmt.Sprintf("workloader-plugin-list-%s.csv", time.Now().Format("20060102_150405")))
+		}
+
+		utils.LogEndCommand("plugin-list")
+	},
+}
@@ -0,0 +1,47 @@
+package policydiff
+
+import (
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var source, target, objects, outputFileName string
+var draft bool
+
+func init() {
+	PolicyDiffCmd.Flags().StringVarP(&source, "source", "s", "", "name of the source pce (not fqdn). see workloader pce-list for options.")
+	PolicyDiffCmd.Flags().StringVarP(&target, "target", "t", "", "name of the pce (not fqdn) to compare to the source.")
+	PolicyDiffCmd.Flags().BoolVar(&draft, "draft", false, "compare the draft policy instead of the active policy.")
+	PolicyDiffCmd.Flags().StringVar(&objects, "objects", "", "comma-separated list of object types to compare: labels, services, iplists, rulesets, rules, and boundaries. default is all.")
+	PolicyDiffCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	PolicyDiffCmd.MarkFlagRequired("source")
+	PolicyDiffCmd.MarkFlagRequired("target")
+	PolicyDiffCmd.Flags().SortFlags = false
+}
+
+// PolicyDiffCmd compares the policy objects of two PCEs
+var PolicyDiffCmd = &cobra.Command{
+	Use:   "policy-diff",
+	Short: "Compare the policy objects of two PCEs.",
+	Long: `
+Compare the policy objects of two PCEs to keep PCEs such as prod and DR or regional PCEs in lockstep.
+
+Labels, services, ip lists, rulesets, rules, and enforcement boundaries are compared. Objects are matched by name because hrefs are different on each PCE. Labels are matched by key and value. Rules are matched in rulesets with the same name by their consumers, providers, services, and extra-scope setting, so a rule with a changed consumer, provider, or service is reported as missing from both PCEs. Labels, label groups, ip lists, services, and workloads in rules, scopes, and boundaries are compared by name.
+
+The output has a row for each object that is only on one PCE and for each field that is different.
+
+The active policy is compared by default. Use --draft to compare the draft policy.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Compare the active policy of the prod and dr pces
+  workloader policy-diff --source prod --target dr
+
+  # Compare the draft rulesets and rules
+  workloader policy-diff --source prod --target dr --draft --objects rulesets,rules`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		utils.LogStartCommand("policy-diff")
+		policyDiff()
+		utils.LogEndCommand("policy-diff")
+	},
+}
@@ -0,0 +1,415 @@
+package policydiff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Output headers
+const (
+	HeaderObjectType  = "object_type"
+	HeaderName        = "name"
+	HeaderDifference  = "difference"
+	HeaderField       = "field"
+	HeaderSourceValue = "source_value"
+	HeaderTargetValue = "target_value"
+)
+
+// Differences
+const (
+	differenceOnlySource = "only_in_source"
+	differenceOnlyTarget = "only_in_target"
+	differenceField      = "different"
+)
+
+// objectTypes are compared in this order
+var objectTypes = []string{"labels", "services", "iplists", "rulesets", "rules", "boundaries"}
+
+// diffObject is a policy object with the fields that are compared. Multi-value fields are sorted and joined with semi-colons.
+type diffObject struct {
+	name   string
+	fields map[string]string
+}
+
+// policyPCE is a PCE with its policy loaded. Objects in rules and scopes are resolved to names so they can be compared across PCEs.
+type policyPCE struct {
+	pce        illumioapi.PCE
+	status     string
+	boundaries []illumioapi.EnforcementBoundary
+	workloads  map[string]string
+}
+
+func policyDiff() {
+
+	// Validate the object types
+	compare := make(map[string]bool)
+	if objects == "" {
+		for _, t := range objectTypes {
+			compare[t] = true
+		}
+	} else {
+		for _, t := range strings.Split(strings.ReplaceAll(objects, " ", ""), ",") {
+			valid := false
+			for _, o := range objectTypes {
+				if strings.EqualFold(t, o) {
+					compare[o], valid = true, true
+				}
+			}
+			if !valid {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a valid object type - must be %s", t, strings.Join(objectTypes, ", ")))
+			}
+		}
+	}
+	if source == target {
+		utils.LogErrorCode(utils.ExitValidation, "source and target must be different pces")
+	}
+
+	status := "active"
+	if draft {
+		status = "draft"
+	}
+	src := loadPolicy(source, status, compare["boundaries"])
+	tgt := loadPolicy(target, status, compare["boundaries"])
+
+	// Compare each object type
+	data := [][]string{{HeaderObjectType, HeaderName, HeaderDifference, HeaderField, HeaderSourceValue, HeaderTargetValue}}
+	counts := []string{}
+	for _, t := range objectTypes {
+		if !compare[t] {
+			continue
+		}
+		rows := diffObjects(t, src.objects(t), tgt.objects(t))
+		data = append(data, rows...)
+		counts = append(counts, fmt.Sprintf("%d %s", len(rows), t))
+	}
+
+	if len(data) > 1 {
+		if outputFileName == "" {
+			outputFileName = fmt.Sprintf("workloader-policy-diff-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(data, data, outputFileName)
+	}
+	utils.LogInfo(fmt.Sprintf("%d differences in the %s policy of %s and %s - %s", len(data)-1, status, source, target, strings.Join(counts, ", ")), true)
+}
+
+// loadPolicy gets a PCE by name and loads its policy objects
+func loadPolicy(name, status string, boundaries bool) *policyPCE {
+	pce, err := utils.GetPCEbyName(name, false)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("getting %s pce - %s", name, err))
+	}
+	utils.LogInfo(fmt.Sprintf("getting %s policy objects from %s (%s)", status, pce.FriendlyName, pce.FQDN), true)
+	apiResps, err := pce.Load(illumioapi.LoadInput{ProvisionStatus: status, Labels: true, LabelGroups: true, IPLists: true, Services: true, RuleSets: true, VirtualServices: true, VirtualServers: true, ConsumingSecurityPrincipals: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("%s - %s", name, err))
+	}
+	p := &policyPCE{pce: pce, status: status, workloads: make(map[string]string)}
+
+	// Enforcement boundaries are not in the illumioapi load
+	if boundaries {
+		a, err := pce.GetCollection("sec_policy/"+status+"/enforcement_boundaries", false, nil, &p.boundaries)
+		utils.LogAPIResp("GetEnforcementBoundaries", a)
+		if err != nil && a.StatusCode == 404 {
+			utils.LogWarning(fmt.Sprintf("%s does not support enforcement boundaries. skipping.", name), true)
+		} else if err != nil {
+			utils.LogError(fmt.Sprintf("%s - getting enforcement boundaries - %s", name, err))
+		}
+	}
+	return p
+}
+
+// diffObjects compares the objects of one type and returns the output rows
+func diffObjects(objectType string, src, tgt map[string]diffObject) [][]string {
+	names := []string{}
+	for n := range src {
+		names = append(names, n)
+	}
+	for n := range tgt {
+		if _, ok := src[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	rows := [][]string{}
+	for _, n := range names {
+		s, inSource := src[n]
+		t, inTarget := tgt[n]
+		switch {
+		case !inTarget:
+			rows = append(rows, []string{objectType, s.name, differenceOnlySource, "", "", ""})
+		case !inSource:
+			rows = append(rows, []string{objectType, t.name, differenceOnlyTarget, "", "", ""})
+		default:
+			fields := []string{}
+			for f := range s.fields {
+				fields = append(fields, f)
+			}
+			sort.Strings(fields)
+			for _, f := range fields {
+				if s.fields[f] != t.fields[f] {
+					rows = append(rows, []string{objectType, s.name, differenceField, f, s.fields[f], t.fields[f]})
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// objects returns the objects of a type keyed by the name they are matched on
+func (p *policyPCE) objects(objectType string) map[string]diffObject {
+	objects := make(map[string]diffObject)
+	add := func(key, name string, fields map[string]string) {
+		objects[key] = diffObject{name: name, fields: fields}
+	}
+
+	switch objectType {
+	case "labels":
+		for _, l := range p.pce.LabelsSlice {
+			add(l.Key+"="+l.Value, l.Key+"="+l.Value, nil)
+		}
+
+	case "services":
+		for _, s := range p.pce.ServicesSlice {
+			ports, windowsServices := []string{}, []string{}
+			for _, sp := range s.ServicePorts {
+				if sp != nil {
+					ports = append(ports, portString(sp.Port, sp.ToPort, sp.Protocol, sp.IcmpType, sp.IcmpCode))
+				}
+			}
+			for _, ws := range s.WindowsServices {
+				if ws != nil {
+					windowsServices = append(windowsServices, strings.Trim(strings.Join([]string{ws.ServiceName, ws.ProcessName, portString(ws.Port, ws.ToPort, ws.Protocol, ws.IcmpType, ws.IcmpCode)}, " "), " "))
+				}
+			}
+			add(s.Name, s.Name, map[string]string{"description": s.Description, "service_ports": joinSorted(ports), "windows_services": joinSorted(windowsServices)})
+		}
+
+	case "iplists":
+		for _, ipl := range p.pce.IPListsSlice {
+			ranges, fqdns := []string{}, []string{}
+			if ipl.IPRanges != nil {
+				for _, r := range *ipl.IPRanges {
+					if r == nil {
+						continue
+					}
+					ipRange := r.FromIP
+					if r.ToIP != "" {
+						ipRange = r.FromIP + "-" + r.ToIP
+					}
+					if r.Exclusion {
+						ipRange = "!" + ipRange
+					}
+					ranges = append(ranges, ipRange)
+				}
+			}
+			if ipl.FQDNs != nil {
+				for _, f := range *ipl.FQDNs {
+					if f != nil {
+						fqdns = append(fqdns, f.FQDN)
+					}
+				}
+			}
+			add(ipl.Name, ipl.Name, map[string]string{"description": ipl.Description, "ip_ranges": joinSorted(ranges), "fqdns": joinSorted(fqdns)})
+		}
+
+	case "rulesets":
+		for _, rs := range p.ruleSets() {
+			add(rs.Name, rs.Name, map[string]string{"description": rs.Description, "enabled": boolString(rs.Enabled), "scopes": p.scopes(rs)})
+		}
+
+	case "rules":
+		for _, rs := range p.ruleSets() {
+			for _, r := range rs.Rules {
+				if r == nil {
+					continue
+				}
+				consumers := []string{}
+				for _, c := range r.Consumers {
+					if c != nil {
+						consumers = append(consumers, p.actor(c.Actors, c.Label, c.LabelGroup, c.IPList, c.Workload, c.VirtualService, nil))
+					}
+				}
+				for _, csp := range r.ConsumingSecurityPrincipals {
+					if csp != nil {
+						consumers = append(consumers, "user_group:"+p.pce.ConsumingSecurityPrincipals[csp.Href].Name)
+					}
+				}
+				providers := []string{}
+				for _, pr := range r.Providers {
+					if pr != nil {
+						providers = append(providers, p.actor(pr.Actors, pr.Label, pr.LabelGroup, pr.IPList, pr.Workload, pr.VirtualService, pr.VirtualServer))
+					}
+				}
+				services := []string{}
+				if r.IngressServices != nil {
+					for _, s := range *r.IngressServices {
+						if s != nil {
+							services = append(services, p.service(*s))
+						}
+					}
+				}
+				name := fmt.Sprintf("%s | consumers: %s | providers: %s | services: %s", rs.Name, joinSorted(consumers), joinSorted(providers), joinSorted(services))
+				if r.UnscopedConsumers != nil && *r.UnscopedConsumers {
+					name = name + " | extra-scope"
+				}
+				resolveAs := []string{}
+				if r.ResolveLabelsAs != nil {
+					resolveAs = append(resolveAs, "consumers:"+joinSorted(r.ResolveLabelsAs.Consumers), "providers:"+joinSorted(r.ResolveLabelsAs.Providers))
+				}
+				add(name, name, map[string]string{"enabled": boolString(r.Enabled), "description": r.Description, "resolve_labels_as": strings.Join(resolveAs, " "), "sec_connect": boolString(r.SecConnect),
+					"stateless": boolString(r.Stateless), "machine_auth": boolString(r.MachineAuth), "network_type": r.NetworkType})
+			}
+		}
+
+	case "boundaries":
+		for _, eb := range p.boundaries {
+			consumers, providers, services := []string{}, []string{}, []string{}
+			for _, c := range eb.Consumers {
+				consumers = append(consumers, p.actor(c.Actors, c.Label, c.LabelGroup, c.IPList, c.Workload, c.VirtualService, nil))
+			}
+			for _, pr := range eb.Providers {
+				providers = append(providers, p.actor(pr.Actors, pr.Label, pr.LabelGroup, pr.IPList, pr.Workload, pr.VirtualService, pr.VirtualServer))
+			}
+			for _, s := range eb.IngressServices {
+				services = append(services, p.service(s))
+			}
+			add(eb.Name, eb.Name, map[string]string{"consumers": joinSorted(consumers), "providers": joinSorted(providers), "services": joinSorted(services)})
+		}
+	}
+
+	return objects
+}
+
+// ruleSets returns the rulesets. The ruleset map is keyed by href and name so only hrefs are used.
+func (p *policyPCE) ruleSets() []illumioapi.RuleSet {
+	ruleSets := []illumioapi.RuleSet{}
+	for href, rs := range p.pce.RuleSets {
+		if href == rs.Href {
+			ruleSets = append(ruleSets, rs)
+		}
+	}
+	return ruleSets
+}
+
+// scopes returns the scopes of a ruleset. Each scope is its labels and label groups joined with a plus sign.
+func (p *policyPCE) scopes(rs illumioapi.RuleSet) string {
+	scopes := []string{}
+	for _, scope := range rs.Scopes {
+		s := []string{}
+		for _, sc := range scope {
+			if sc == nil {
+				continue
+			}
+			s = append(s, p.actor("", sc.Label, sc.LabelGroup, nil, nil, nil, nil))
+		}
+		sort.Strings(s)
+		if len(s) == 0 {
+			s = append(s, "all")
+		}
+		scopes = append(scopes, strings.Join(s, "+"))
+	}
+	return joinSorted(scopes)
+}
+
+// actor returns the name of a consumer, provider, or scope
+func (p *policyPCE) actor(actors string, label *illumioapi.Label, labelGroup *illumioapi.LabelGroup, ipList *illumioapi.IPList, wkld *illumioapi.Workload, virtualService *illumioapi.VirtualService, virtualServer *illumioapi.VirtualServer) string {
+	switch {
+	case actors == "ams":
+		return "all workloads"
+	case actors != "":
+		return actors
+	case label != nil:
+		l := p.pce.Labels[label.Href]
+		return "label:" + l.Key + "=" + l.Value
+	case labelGroup != nil:
+		return "label_group:" + p.pce.LabelGroups[labelGroup.Href].Name
+	case ipList != nil:
+		return "ip_list:" + p.pce.IPLists[ipList.Href].Name
+	case wkld != nil:
+		return "workload:" + p.workload(wkld.Href)
+	case virtualService != nil:
+		return "virtual_service:" + p.pce.VirtualServices[virtualService.Href].Name
+	case virtualServer != nil:
+		return "virtual_server:" + p.pce.VirtualServers[virtualServer.Href].Name
+	}
+	return ""
+}
+
+// workload returns the hostname of a workload in a rule. Workloads are only looked up when a rule uses them.
+func (p *policyPCE) workload(href string) string {
+	if hostname, ok := p.workloads[href]; ok {
+		return hostname
+	}
+	w, a, err := p.pce.GetWkldByHref(href)
+	utils.LogAPIResp("GetWkldByHref", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("%s - getting workload %s - %s", p.pce.FriendlyName, href, err), true)
+	}
+	p.workloads[href] = w.Hostname
+	if w.Hostname == "" {
+		p.workloads[href] = w.Name
+	}
+	return p.workloads[href]
+}
+
+// service returns the name of a service or the port and protocol of a rule or boundary
+func (p *policyPCE) service(s illumioapi.IngressServices) string {
+	if s.Href != nil {
+		return "service:" + p.pce.Services[*s.Href].Name
+	}
+	port, toPort, proto := 0, 0, 0
+	if s.Port != nil {
+		port = *s.Port
+	}
+	if s.ToPort != nil {
+		toPort = *s.ToPort
+	}
+	if s.Protocol != nil {
+		proto = *s.Protocol
+	}
+	return portString(port, toPort, proto, 0, 0)
+}
+
+// portString returns a port, port range, or icmp type and code with the protocol (e.g., 443/tcp, 8080-8090/tcp, or icmp 8/0)
+func portString(port, toPort, proto, icmpType, icmpCode int) string {
+	protocols := map[int]string{-1: "all", 1: "icmp", 6: "tcp", 17: "udp", 58: "icmpv6"}
+	protocol, ok := protocols[proto]
+	if !ok {
+		protocol = strconv.Itoa(proto)
+	}
+	switch {
+	case proto == 1 || proto == 58:
+		if icmpType != 0 || icmpCode != 0 {
+			return fmt.Sprintf("%s %d/%d", protocol, icmpType, icmpCode)
+		}
+		return protocol
+	case port == 0:
+		return protocol
+	case toPort != 0:
+		return fmt.Sprintf("%d-%d/%s", port, toPort, protocol)
+	}
+	return fmt.Sprintf("%d/%s", port, protocol)
+}
+
+// joinSorted sorts values and joins them with semi-colons
+func joinSorted(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ";")
+}
+
+// boolString returns true or false for a bool pointer. nil is blank.
+func boolString(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
@@ -31,6 +31,8 @@ var ProcessExportCmd = &cobra.Command{
 	Long: `
 Create a CSV export of all running processes on all workloads.
 
+The privileged_exposure column is true when the process runs as root or a Windows admin/system account and listens on all interfaces (0.0.0.0 or ::).
+
 The update-pce and --no-prompt flags are ignored for this command.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
@@ -88,7 +90,7 @@ func ExportProcesses(pce illumioapi.PCE, outputFileName string) {
 	}
 
 	// Setup CSV Data
-	csvData := [][]string{{"hostname", "href", "process_name", "service_name", "port", "proto"}}
+	csvData := [][]string{{"hostname", "href", "process_name", "service_name", "port", "proto", "user", "address", "privileged_exposure"}}
 
 	// Set up slice of workloads
 	for _, wHref := range wkldHrefs {
@@ -101,7 +103,7 @@ func ExportProcesses(pce illumioapi.PCE, outputFileName string) {
 			continue
 		}
 		for _, osp := range w.Services.OpenServicePorts {
-			csvData = append(csvData, []string{w.Hostname, w.Href, osp.ProcessName, osp.WinServiceName, strconv.Itoa(osp.Port), strconv.Itoa(osp.Protocol)})
+			csvData = append(csvData, []string{w.Hostname, w.Href, osp.ProcessName, osp.WinServiceName, strconv.Itoa(osp.Port), strconv.Itoa(osp.Protocol), osp.User, osp.Address, strconv.FormatBool(utils.PrivilegedExposure(osp.User, osp.Address))})
 		}
 	}
 
@@ -0,0 +1,68 @@
+package rbacaudit
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName string
+var unusedDays int
+var noAPIKeys bool
+
+func init() {
+	RBACAuditCmd.Flags().IntVar(&unusedDays, "unused-days", 90, "flag users that have not logged in for this many days.")
+	RBACAuditCmd.Flags().BoolVar(&noAPIKeys, "no-api-keys", false, "do not get the api keys of each user and service account. use to reduce api calls on pces with many users.")
+	RBACAuditCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	RBACAuditCmd.Flags().SortFlags = false
+}
+
+// RBACAuditCmd exports the users, groups, api keys, and their roles for access reviews
+var RBACAuditCmd = &cobra.Command{
+	Use:   "rbac-audit",
+	Short: "Export PCE users, external groups, service accounts, and api keys with their roles for access reviews.",
+	Long: `
+Export PCE users, external groups, service accounts, and api keys with their roles and scopes for periodic access reviews.
+
+Each row is one of:
+- user: a local or external user with the roles granted to the user directly, the last login, and the last login ip.
+- group: an external group mapping (e.g., an ldap or saml group) with the roles granted to its members.
+- service_account: a service account with its roles.
+- api_key: an api key of a user or service account with the owner's roles.
+
+Roles are in the format role (scope). The scope is the labels and label groups of the role or global.
+
+The flags column has:
+- global_admin: a global organization owner or global administrator role.
+- unused: a user that has not logged in for --unused-days days.
+- never_logged_in: a user that has never logged in.
+- no_roles: a user, group, or service account without roles. users can still have roles from external groups.
+
+Users in external groups get the group's roles in the PCE when they log in. The identity provider has the group members, so they are not in the export.
+
+Getting api keys is one api call for each user and service account. Use --no-api-keys to skip them.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Export the access review
+  workloader rbac-audit
+
+  # Flag users that have not logged in for 30 days and skip the api keys
+  workloader rbac-audit --unused-days 30 --no-api-keys`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if unusedDays < 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--unused-days must be 1 or more")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("rbac-audit")
+		rbacAudit()
+		utils.LogEndCommand("rbac-audit")
+	},
+}
@@ -0,0 +1,256 @@
+package rbacaudit
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Flags
+const (
+	flagGlobalAdmin   = "global_admin"
+	flagUnused        = "unused"
+	flagNeverLoggedIn = "never_logged_in"
+	flagNoRoles       = "no_roles"
+)
+
+// user is a pce user
+type user struct {
+	Href               string `json:"href"`
+	Username           string `json:"username"`
+	FullName           string `json:"full_name"`
+	Type               string `json:"type"`
+	Locked             bool   `json:"locked"`
+	LastLoginOn        string `json:"last_login_on"`
+	LastLoginIPAddress string `json:"last_login_ip_address"`
+	CreatedAt          string `json:"created_at"`
+}
+
+// principal is a user or external group that permissions are granted to
+type principal struct {
+	Href        string `json:"href"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+}
+
+// permission is a role with a scope granted to a principal or service account
+type permission struct {
+	Href string `json:"href"`
+	Role struct {
+		Href string `json:"href"`
+	} `json:"role"`
+	Scope []struct {
+		Label      *illumioapi.Label      `json:"label"`
+		LabelGroup *illumioapi.LabelGroup `json:"label_group"`
+	} `json:"scope"`
+	AuthSecurityPrincipal struct {
+		Href string `json:"href"`
+	} `json:"auth_security_principal"`
+}
+
+// serviceAccount is a pce service account
+type serviceAccount struct {
+	Href        string       `json:"href"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	CreatedAt   string       `json:"created_at"`
+	Permissions []permission `json:"permissions"`
+}
+
+// roleNames are the display names of the pce roles
+var roleNames = map[string]string{
+	"owner":                     "Global Organization Owner",
+	"admin":                     "Global Administrator",
+	"read_only":                 "Global Viewer",
+	"global_object_provisioner": "Global Policy Object Provisioner",
+	"ruleset_manager":           "Full Ruleset Manager",
+	"limited_ruleset_manager":   "Limited Ruleset Manager",
+	"ruleset_provisioner":       "Ruleset Provisioner",
+	"ruleset_viewer":            "Ruleset Viewer",
+	"workload_manager":          "Workload Manager",
+}
+
+func rbacAudit() {
+
+	// Load the labels and label groups for the scopes
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, LabelGroups: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the users. Users are not in the org.
+	users := []user{}
+	a, err := pce.GetHref("/users", &users)
+	utils.LogAPIResp("GetUsers", a)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("getting users - %s", err))
+	}
+
+	// Get the principals and permissions
+	principals := []principal{}
+	a, err = pce.GetCollection("auth_security_principals", false, nil, &principals)
+	utils.LogAPIResp("GetAuthSecurityPrincipals", a)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("getting auth security principals - %s", err))
+	}
+	permissions := []permission{}
+	a, err = pce.GetCollection("permissions", false, nil, &permissions)
+	utils.LogAPIResp("GetPermissions", a)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("getting permissions - %s", err))
+	}
+
+	// Service accounts are not in older PCEs
+	serviceAccounts := []serviceAccount{}
+	a, err = pce.GetCollection("service_accounts", false, nil, &serviceAccounts)
+	utils.LogAPIResp("GetServiceAccounts", a)
+	if err != nil && a.StatusCode == 404 {
+		utils.LogWarning("the pce does not support service accounts. skipping.", true)
+	} else if err != nil {
+		utils.LogError(fmt.Sprintf("getting service accounts - %s", err))
+	}
+	utils.LogInfo(fmt.Sprintf("%d users, %d auth security principals, %d permissions, and %d service accounts", len(users), len(principals), len(permissions), len(serviceAccounts)), true)
+
+	// Map the roles to the principals
+	roles := make(map[string][]string)
+	globalAdmin := make(map[string]bool)
+	for _, p := range permissions {
+		r, admin := roleString(p)
+		roles[p.AuthSecurityPrincipal.Href] = append(roles[p.AuthSecurityPrincipal.Href], r)
+		globalAdmin[p.AuthSecurityPrincipal.Href] = globalAdmin[p.AuthSecurityPrincipal.Href] || admin
+	}
+	userPrincipals := make(map[string]string)
+	for _, p := range principals {
+		if p.Type == "user" {
+			userPrincipals[strings.ToLower(p.Name)] = p.Href
+		}
+	}
+
+	data := [][]string{{"type", "name", "description", "href", "auth_type", "roles", "last_login", "last_login_ip", "created_at", "owner", "flags"}}
+	counts := make(map[string]int)
+	addRow := func(row []string, flags []string) {
+		for _, f := range flags {
+			counts[f]++
+		}
+		data = append(data, append(row, strings.Join(flags, ";")))
+	}
+	unusedBefore := time.Now().AddDate(0, 0, -unusedDays)
+
+	// Users
+	sort.Slice(users, func(i, j int) bool { return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username) })
+	for _, u := range users {
+		p := userPrincipals[strings.ToLower(u.Username)]
+		flags := roleFlags(roles[p], globalAdmin[p])
+		if u.LastLoginOn == "" {
+			flags = append(flags, flagNeverLoggedIn)
+		} else if last, err := time.Parse(time.RFC3339, u.LastLoginOn); err == nil && last.Before(unusedBefore) {
+			flags = append(flags, flagUnused)
+		}
+		addRow([]string{"user", u.Username, u.FullName, u.Href, u.Type, strings.Join(roles[p], ";"), u.LastLoginOn, u.LastLoginIPAddress, u.CreatedAt, ""}, flags)
+		if !noAPIKeys {
+			addAPIKeys(u.Href, u.Username, roles[p], globalAdmin[p], addRow)
+		}
+	}
+
+	// External groups
+	sort.Slice(principals, func(i, j int) bool { return strings.ToLower(principals[i].Name) < strings.ToLower(principals[j].Name) })
+	for _, p := range principals {
+		if p.Type != "group" {
+			continue
+		}
+		addRow([]string{"group", p.Name, p.DisplayName, p.Href, "external", strings.Join(roles[p.Href], ";"), "", "", "", ""}, roleFlags(roles[p.Href], globalAdmin[p.Href]))
+	}
+
+	// Service accounts have their permissions or permissions to their href
+	sort.Slice(serviceAccounts, func(i, j int) bool {
+		return strings.ToLower(serviceAccounts[i].Name) < strings.ToLower(serviceAccounts[j].Name)
+	})
+	for _, sa := range serviceAccounts {
+		saRoles, saAdmin := roles[sa.Href], globalAdmin[sa.Href]
+		for _, p := range sa.Permissions {
+			r, admin := roleString(p)
+			saRoles = append(saRoles, r)
+			saAdmin = saAdmin || admin
+		}
+		addRow([]string{"service_account", sa.Name, sa.Description, sa.Href, "", strings.Join(saRoles, ";"), "", "", sa.CreatedAt, ""}, roleFlags(saRoles, saAdmin))
+		if !noAPIKeys {
+			addAPIKeys(sa.Href, sa.Name, saRoles, saAdmin, addRow)
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("%d global admin grants, %d unused users, %d users that never logged in, and %d accounts without roles", counts[flagGlobalAdmin], counts[flagUnused], counts[flagNeverLoggedIn], counts[flagNoRoles]), true)
+	if len(data) == 1 {
+		utils.LogInfo("no users, groups, or service accounts", true)
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-rbac-audit-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// addAPIKeys adds a row for each api key of a user or service account with the owner's roles
+func addAPIKeys(ownerHref, owner string, roles []string, globalAdmin bool, addRow func(row []string, flags []string)) {
+	keys := []illumioapi.APIKey{}
+	a, err := pce.GetHref(ownerHref+"/api_keys", &keys)
+	utils.LogAPIResp("GetAPIKeys", a)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("getting api keys of %s - %s", owner, err), true)
+		return
+	}
+	flags := []string{}
+	if globalAdmin {
+		flags = append(flags, flagGlobalAdmin)
+	}
+	for _, k := range keys {
+		name := k.KeyID
+		if k.Name != "" {
+			name = fmt.Sprintf("%s (%s)", k.Name, k.KeyID)
+		}
+		addRow([]string{"api_key", name, k.Description, k.Href, "", strings.Join(roles, ";"), "", "", k.CreatedAt, owner}, flags)
+	}
+}
+
+// roleString returns the role and scope of a permission (e.g., Ruleset Manager (app:ORDERING, env:PROD)) and true if it is a global
+// organization owner or administrator role
+func roleString(p permission) (string, bool) {
+	role := path.Base(p.Role.Href)
+	name := roleNames[role]
+	if name == "" {
+		name = role
+	}
+	scope := []string{}
+	for _, s := range p.Scope {
+		if s.Label != nil {
+			l := pce.Labels[s.Label.Href]
+			scope = append(scope, fmt.Sprintf("%s:%s", l.Key, l.Value))
+		}
+		if s.LabelGroup != nil {
+			lg := pce.LabelGroups[s.LabelGroup.Href]
+			scope = append(scope, fmt.Sprintf("%s_label_group:%s", lg.Key, lg.Name))
+		}
+	}
+	if len(scope) == 0 {
+		return fmt.Sprintf("%s (global)", name), role == "owner" || role == "admin"
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(scope, ", ")), false
+}
+
+// roleFlags returns the global admin and no roles flags
+func roleFlags(roles []string, globalAdmin bool) []string {
+	flags := []string{}
+	if globalAdmin {
+		flags = append(flags, flagGlobalAdmin)
+	}
+	if len(roles) == 0 {
+		flags = append(flags, flagNoRoles)
+	}
+	return flags
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var trafficDays, top, maxResults int
+var title, outputFileName string
+
+func init() {
+	ReportCmd.Flags().StringVar(&title, "title", "", "title of the report. default is Illumio segmentation report for the pce name.")
+	ReportCmd.Flags().IntVar(&trafficDays, "traffic-days", 7, "days of traffic for the top talkers. 0 skips the traffic query.")
+	ReportCmd.Flags().IntVar(&top, "top", 10, "number of top talkers, services, and rulesets in the charts.")
+	ReportCmd.Flags().IntVar(&maxResults, "max-results", 100000, "maximum results for the traffic query.")
+	ReportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	ReportCmd.Flags().SortFlags = false
+}
+
+// ReportCmd creates an html dashboard of the PCE
+var ReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Create a self-contained html dashboard of labeling, enforcement, VEN health, policy, and top talkers.",
+	Long: `
+Create a self-contained html dashboard of the PCE to share with leadership.
+
+The dashboard has:
+- label coverage: the percent of workloads with each label key.
+- enforcement: managed workloads by enforcement mode and the number of unmanaged workloads.
+- ven health: managed workloads by online status, policy sync state, and health conditions.
+- policy: the number of active rulesets and rules and the rulesets with the most rules.
+- top talkers: the sources, destinations, and services with the most connections in the last --traffic-days days (default 7).
+
+The charts are inline svg and the page has no scripts or external files, so it can be emailed (e.g., with --email-to) or attached to a ticket. The --format flag does not apply to this command.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Create the dashboard
+  workloader report
+
+  # Create the dashboard with 30 days of traffic and email it
+  workloader report --traffic-days 30 --email-to ciso@company.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		if trafficDays < 0 || top < 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--traffic-days must be 0 or more and --top must be at least 1")
+		}
+
+		utils.LogStartCommand("report")
+		report()
+		utils.LogEndCommand("report")
+	},
+}
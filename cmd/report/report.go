@@ -0,0 +1,315 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// dashboard is the data for the html template
+type dashboard struct {
+	Title, PCE, Generated string
+	Tiles                 []tile
+	Sections              []section
+}
+
+// tile is a headline number at the top of the dashboard
+type tile struct {
+	Label, Value string
+}
+
+// section is a titled group of charts
+type section struct {
+	Title, Note string
+	Charts      []chart
+}
+
+// chart is a horizontal bar chart
+type chart struct {
+	Title string
+	Bars  []bar
+}
+
+// bar is a bar of a chart. Width is in pixels and Y is the top of the bar.
+type bar struct {
+	Label, Text string
+	Width       float64
+	Y           int
+}
+
+// Chart dimensions used by the template
+const (
+	barHeight   = 22
+	barGap      = 6
+	barMaxWidth = 320
+)
+
+// Height returns the svg height of the chart
+func (c chart) Height() int {
+	return len(c.Bars)*(barHeight+barGap) + barGap
+}
+
+// count is a labeled count used to build charts
+type count struct {
+	label string
+	value int
+}
+
+// countChart returns a chart of counts sorted by value. Only the first max counts are charted if max is greater than 0.
+func countChart(title string, counts map[string]int, max int) chart {
+	sorted := []count{}
+	for l, v := range counts {
+		sorted = append(sorted, count{label: l, value: v})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].value == sorted[j].value {
+			return sorted[i].label < sorted[j].label
+		}
+		return sorted[i].value > sorted[j].value
+	})
+	if max > 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	c := chart{Title: title}
+	for i, s := range sorted {
+		c.Bars = append(c.Bars, newBar(i, s.label, fmt.Sprintf("%d", s.value), percent(s.value, sorted[0].value)))
+	}
+	return c
+}
+
+// newBar returns the i-th bar of a chart with a length of pct percent of the maximum. Long labels are shortened to fit beside the bar.
+func newBar(i int, label, text string, pct float64) bar {
+	if r := []rune(label); len(r) > 30 {
+		label = string(r[:29]) + "…"
+	}
+	return bar{Label: label, Text: text, Width: pct / 100 * barMaxWidth, Y: barGap + i*(barHeight+barGap)}
+}
+
+// percent returns part as a percent of total
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+func report() {
+
+	// Load the PCE
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true, RuleSets: true, ProvisionStatus: "active"})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	d := dashboard{Title: title, PCE: fmt.Sprintf("%s (%s)", pce.FriendlyName, pce.FQDN), Generated: time.Now().Format("2006-01-02 15:04 MST")}
+	if d.Title == "" {
+		d.Title = fmt.Sprintf("Illumio Segmentation Report - %s", pce.FriendlyName)
+	}
+
+	managed, unmanaged := 0, 0
+	for _, w := range pce.WorkloadsSlice {
+		if w.GetMode() == "unmanaged" {
+			unmanaged++
+		} else {
+			managed++
+		}
+	}
+	labelSection, coverage := labelCoverage()
+	policySection, ruleSets, rules := policy()
+	d.Tiles = []tile{
+		{Label: "managed workloads", Value: fmt.Sprintf("%d", managed)},
+		{Label: "unmanaged workloads", Value: fmt.Sprintf("%d", unmanaged)},
+		{Label: "fully labeled workloads", Value: fmt.Sprintf("%.0f%%", coverage)},
+		{Label: "workloads in full enforcement", Value: fmt.Sprintf("%.0f%%", percent(enforcedWorkloads(), managed))},
+		{Label: "active rulesets", Value: fmt.Sprintf("%d", ruleSets)},
+		{Label: "active rules", Value: fmt.Sprintf("%d", rules)},
+	}
+	d.Sections = []section{labelSection, enforcement(), venHealth(), policySection}
+	if trafficDays > 0 {
+		d.Sections = append(d.Sections, topTalkers())
+	}
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, d); err != nil {
+		utils.LogError(fmt.Sprintf("creating report - %s", err))
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-report-%s.html", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteFileOutput(buf.Bytes(), outputFileName)
+}
+
+// labelCoverage returns the percent of workloads with each label key and the percent of workloads with every key
+func labelCoverage() (section, float64) {
+
+	// Order the keys in use with role, app, env, and loc first
+	order := map[string]int{"role": 0, "app": 1, "env": 2, "loc": 3}
+	inUse := make(map[string]bool)
+	keys := []string{}
+	for _, l := range pce.LabelsSlice {
+		if !inUse[l.Key] {
+			inUse[l.Key] = true
+			keys = append(keys, l.Key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, iDefault := order[keys[i]]
+		oj, jDefault := order[keys[j]]
+		if iDefault && jDefault {
+			return oi < oj
+		}
+		if iDefault || jDefault {
+			return iDefault
+		}
+		return keys[i] < keys[j]
+	})
+
+	labeled := make(map[string]int)
+	full := 0
+	for _, w := range pce.WorkloadsSlice {
+		all := true
+		for _, k := range keys {
+			if w.GetLabelByKey(k, pce.Labels).Href != "" {
+				labeled[k]++
+			} else {
+				all = false
+			}
+		}
+		if all {
+			full++
+		}
+	}
+
+	c := chart{Title: "Workloads with each label"}
+	for i, k := range keys {
+		p := percent(labeled[k], len(pce.WorkloadsSlice))
+		c.Bars = append(c.Bars, newBar(i, k, fmt.Sprintf("%.0f%% (%d)", p, labeled[k]), p))
+	}
+	coverage := percent(full, len(pce.WorkloadsSlice))
+	return section{Title: "Label Coverage", Note: fmt.Sprintf("%d of %d workloads (%.0f%%) have every label key.", full, len(pce.WorkloadsSlice), coverage), Charts: []chart{c}}, coverage
+}
+
+// enforcedWorkloads returns the number of managed workloads in full enforcement
+func enforcedWorkloads() int {
+	enforced := 0
+	for _, w := range pce.WorkloadsSlice {
+		if m := w.GetMode(); m == "full" || strings.HasPrefix(m, "enforced") {
+			enforced++
+		}
+	}
+	return enforced
+}
+
+// enforcement returns the enforcement mode distribution
+func enforcement() section {
+	modes := make(map[string]int)
+	for _, w := range pce.WorkloadsSlice {
+		modes[w.GetMode()]++
+	}
+	return section{Title: "Enforcement", Charts: []chart{countChart("Workloads by enforcement mode", modes, 0)}}
+}
+
+// venHealth returns the online status, policy sync state, and health conditions of managed workloads
+func venHealth() section {
+	online, syncState, health := make(map[string]int), make(map[string]int), make(map[string]int)
+	for _, w := range pce.WorkloadsSlice {
+		if w.GetMode() == "unmanaged" {
+			continue
+		}
+		if w.Online {
+			online["online"]++
+		} else {
+			online["offline"]++
+		}
+		if w.Agent == nil || w.Agent.Status == nil {
+			continue
+		}
+		state := w.Agent.Status.SecurityPolicySyncState
+		if state == "" {
+			state = "unknown"
+		}
+		syncState[state]++
+		if len(w.Agent.Status.AgentHealth) == 0 {
+			health["healthy"]++
+		}
+		for _, h := range w.Agent.Status.AgentHealth {
+			if h != nil {
+				health[fmt.Sprintf("%s (%s)", h.Type, h.Severity)]++
+			}
+		}
+	}
+	return section{Title: "VEN Health", Charts: []chart{countChart("Managed workloads by status", online, 0), countChart("Policy sync state", syncState, 0), countChart("Health conditions", health, top)}}
+}
+
+// policy returns the ruleset and rule counts and the rulesets with the most rules
+func policy() (section, int, int) {
+	ruleSets, rules := 0, 0
+	ruleCounts := make(map[string]int)
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href {
+			continue
+		}
+		ruleSets++
+		rules += len(rs.Rules)
+		ruleCounts[rs.Name] = len(rs.Rules)
+	}
+	return section{Title: "Policy", Note: fmt.Sprintf("%d active rulesets with %d rules.", ruleSets, rules), Charts: []chart{countChart(fmt.Sprintf("Top %d rulesets by rules", top), ruleCounts, top)}}, ruleSets, rules
+}
+
+// topTalkers returns the sources, destinations, and services with the most connections
+func topTalkers() section {
+	utils.LogInfo(fmt.Sprintf("getting traffic for the last %d days", trafficDays), true)
+	tq := illumioapi.TrafficQuery{
+		StartTime:                       time.Now().AddDate(0, 0, -trafficDays),
+		EndTime:                         time.Now(),
+		PolicyStatuses:                  []string{},
+		MaxFLows:                        maxResults,
+		TransmissionExcludes:            []string{"broadcast", "multicast"},
+		ExcludeWorkloadsFromIPListQuery: true}
+	traffic, a, err := pce.GetTrafficAnalysis(tq)
+	utils.LogAPIResp("GetTrafficAnalysis", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	protocols := illumioapi.ProtocolList()
+	sources, destinations, services := make(map[string]int), make(map[string]int), make(map[string]int)
+	connections := 0
+	for _, t := range traffic {
+		connections += t.NumConnections
+		if t.Src != nil {
+			sources[endpoint(t.Src.IP, t.Src.Workload)] += t.NumConnections
+		}
+		if t.Dst != nil {
+			destinations[endpoint(t.Dst.IP, t.Dst.Workload)] += t.NumConnections
+		}
+		if t.ExpSrv != nil {
+			services[fmt.Sprintf("%d %s", t.ExpSrv.Port, protocols[t.ExpSrv.Proto])] += t.NumConnections
+		}
+	}
+	note := fmt.Sprintf("%d connections in %d flows in the last %d days.", connections, len(traffic), trafficDays)
+	if len(traffic) >= maxResults {
+		note = note + fmt.Sprintf(" The traffic query reached the maximum of %d results.", maxResults)
+	}
+	return section{Title: "Top Talkers", Note: note, Charts: []chart{
+		countChart(fmt.Sprintf("Top %d sources by connections", top), sources, top),
+		countChart(fmt.Sprintf("Top %d destinations by connections", top), destinations, top),
+		countChart(fmt.Sprintf("Top %d services by connections", top), services, top)}}
+}
+
+// endpoint returns the workload hostname or the ip of a traffic source or destination
+func endpoint(ip string, w *illumioapi.Workload) string {
+	if w != nil && w.Hostname != "" {
+		return w.Hostname
+	}
+	if w != nil && w.Name != "" {
+		return w.Name
+	}
+	return ip
+}
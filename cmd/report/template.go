@@ -0,0 +1,47 @@
+package report
+
+import "html/template"
+
+// dashboardTemplate is a standalone page. The styles are inline and the charts are svg so the page has no external files or scripts.
+var dashboardTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;font-size:14px;color:#222;background:#f4f5f7;margin:0;padding:24px}
+h1{font-size:22px;margin:0 0 4px}
+h2{font-size:17px;margin:0 0 4px}
+h3{font-size:14px;margin:12px 0 4px;color:#555}
+.meta{color:#666;margin-bottom:20px}
+.tiles{display:flex;flex-wrap:wrap;gap:12px;margin-bottom:20px}
+.tile{background:#fff;border-radius:6px;padding:12px 16px;min-width:150px;box-shadow:0 1px 2px rgba(0,0,0,.1)}
+.tile .value{font-size:26px;font-weight:600;color:#1f5fa8}
+.tile .label{color:#666}
+.section{background:#fff;border-radius:6px;padding:16px;margin-bottom:16px;box-shadow:0 1px 2px rgba(0,0,0,.1)}
+.note{color:#666}
+.charts{display:flex;flex-wrap:wrap;gap:24px}
+.chart{flex:1 1 420px;max-width:640px}
+svg text{font-size:12px;fill:#222}
+.empty{color:#999;font-style:italic}
+</style>
+</head><body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{.PCE}} &middot; generated {{.Generated}}</div>
+<div class="tiles">{{range .Tiles}}
+<div class="tile"><div class="value">{{.Value}}</div><div class="label">{{.Label}}</div></div>{{end}}
+</div>
+{{range .Sections}}<div class="section">
+<h2>{{.Title}}</h2>{{if .Note}}
+<div class="note">{{.Note}}</div>{{end}}
+<div class="charts">{{range .Charts}}
+<div class="chart"><h3>{{.Title}}</h3>{{if .Bars}}
+<svg width="100%" height="{{.Height}}" viewBox="0 0 600 {{.Height}}" preserveAspectRatio="xMinYMin meet" xmlns="http://www.w3.org/2000/svg">{{range .Bars}}
+<text x="0" y="{{.Y}}" dy="15">{{.Label}}</text>
+<rect x="200" y="{{.Y}}" width="320" height="22" rx="3" fill="#e6ecf3"/>
+<rect x="200" y="{{.Y}}" width="{{printf "%.1f" .Width}}" height="22" rx="3" fill="#1f5fa8"/>
+<text x="528" y="{{.Y}}" dy="15">{{.Text}}</text>{{end}}
+</svg>{{else}}
+<div class="empty">no data</div>{{end}}
+</div>{{end}}
+</div>
+</div>
+{{end}}</body></html>
+`))
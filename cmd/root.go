@@ -4,44 +4,80 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/brian1917/workloader/utils"
 
+	"github.com/brian1917/workloader/cmd/adsync"
 	"github.com/brian1917/workloader/cmd/allpce"
+	"github.com/brian1917/workloader/cmd/ansibleinventory"
+	"github.com/brian1917/workloader/cmd/apply"
+	"github.com/brian1917/workloader/cmd/awssync"
+	"github.com/brian1917/workloader/cmd/azuresync"
+	"github.com/brian1917/workloader/cmd/broadrules"
+	"github.com/brian1917/workloader/cmd/certexpiry"
+	"github.com/brian1917/workloader/cmd/changereport"
 	"github.com/brian1917/workloader/cmd/checkversion"
 	"github.com/brian1917/workloader/cmd/compatibility"
+	"github.com/brian1917/workloader/cmd/compliancereport"
+	"github.com/brian1917/workloader/cmd/consulsync"
 	"github.com/brian1917/workloader/cmd/containmentswitch"
 	"github.com/brian1917/workloader/cmd/cwpexport"
 	"github.com/brian1917/workloader/cmd/cwpimport"
 	"github.com/brian1917/workloader/cmd/dagsync"
 	"github.com/brian1917/workloader/cmd/deletehrefs"
 	"github.com/brian1917/workloader/cmd/deleteunusedlabels"
+	"github.com/brian1917/workloader/cmd/dependencymap"
+	"github.com/brian1917/workloader/cmd/dhcpimport"
+	"github.com/brian1917/workloader/cmd/dnsimport"
+	"github.com/brian1917/workloader/cmd/driftreport"
 	"github.com/brian1917/workloader/cmd/dupecheck"
+	"github.com/brian1917/workloader/cmd/edrimport"
+	"github.com/brian1917/workloader/cmd/enforcementreadiness"
 	"github.com/brian1917/workloader/cmd/explorer"
+	"github.com/brian1917/workloader/cmd/exposure"
 	"github.com/brian1917/workloader/cmd/extract"
+	"github.com/brian1917/workloader/cmd/f5sync"
 	"github.com/brian1917/workloader/cmd/flowimport"
 	"github.com/brian1917/workloader/cmd/flowsummary"
+	"github.com/brian1917/workloader/cmd/gcpsync"
 	"github.com/brian1917/workloader/cmd/getpairingkey"
 	"github.com/brian1917/workloader/cmd/hostparse"
+	"github.com/brian1917/workloader/cmd/idpsync"
 	"github.com/brian1917/workloader/cmd/increasevenupdaterate"
+	"github.com/brian1917/workloader/cmd/infobloxsync"
 	"github.com/brian1917/workloader/cmd/iplexport"
 	"github.com/brian1917/workloader/cmd/iplimport"
 	"github.com/brian1917/workloader/cmd/iplreplace"
+	"github.com/brian1917/workloader/cmd/k8ssync"
+	"github.com/brian1917/workloader/cmd/labelcoverage"
 	"github.com/brian1917/workloader/cmd/labelexport"
 	"github.com/brian1917/workloader/cmd/labelgroupexport"
 	"github.com/brian1917/workloader/cmd/labelgroupimport"
 	"github.com/brian1917/workloader/cmd/labelimport"
+	"github.com/brian1917/workloader/cmd/labelwriteback"
 	"github.com/brian1917/workloader/cmd/mislabel"
 	"github.com/brian1917/workloader/cmd/mode"
 	"github.com/brian1917/workloader/cmd/netscalersync"
 	"github.com/brian1917/workloader/cmd/nicexport"
 	"github.com/brian1917/workloader/cmd/nicmanage"
+	"github.com/brian1917/workloader/cmd/nsxsync"
+	"github.com/brian1917/workloader/cmd/pairingprofileaudit"
+	"github.com/brian1917/workloader/cmd/pairingprofileimport"
 	"github.com/brian1917/workloader/cmd/pcemgmt"
+	"github.com/brian1917/workloader/cmd/policydiff"
 	"github.com/brian1917/workloader/cmd/processexport"
+	"github.com/brian1917/workloader/cmd/rbacaudit"
+	"github.com/brian1917/workloader/cmd/report"
+	"github.com/brian1917/workloader/cmd/ruleanalysis"
+	"github.com/brian1917/workloader/cmd/rulecomplexity"
 	"github.com/brian1917/workloader/cmd/ruleexport"
 	"github.com/brian1917/workloader/cmd/ruleimport"
 	"github.com/brian1917/workloader/cmd/rulesetexport"
 	"github.com/brian1917/workloader/cmd/rulesetimport"
+	"github.com/brian1917/workloader/cmd/scheduler"
+	"github.com/brian1917/workloader/cmd/servemetrics"
+	"github.com/brian1917/workloader/cmd/server"
 	"github.com/brian1917/workloader/cmd/servicefinder"
 	"github.com/brian1917/workloader/cmd/subnet"
 	"github.com/brian1917/workloader/cmd/svcexport"
@@ -49,18 +85,25 @@ import (
 	"github.com/brian1917/workloader/cmd/templatecreate"
 	"github.com/brian1917/workloader/cmd/templateimport"
 	"github.com/brian1917/workloader/cmd/templatelist"
+	"github.com/brian1917/workloader/cmd/tfexport"
 	"github.com/brian1917/workloader/cmd/traffic"
+	"github.com/brian1917/workloader/cmd/trafficanomaly"
 	"github.com/brian1917/workloader/cmd/umwlcleanup"
+	"github.com/brian1917/workloader/cmd/undo"
 	"github.com/brian1917/workloader/cmd/unpair"
 	"github.com/brian1917/workloader/cmd/unusedports"
 	"github.com/brian1917/workloader/cmd/unusedumwl"
 	"github.com/brian1917/workloader/cmd/upgrade"
+	"github.com/brian1917/workloader/cmd/vcentersync"
 	"github.com/brian1917/workloader/cmd/venexport"
 	"github.com/brian1917/workloader/cmd/venhealth"
 	"github.com/brian1917/workloader/cmd/venimport"
+	"github.com/brian1917/workloader/cmd/venversions"
+	"github.com/brian1917/workloader/cmd/vulnimport"
 	"github.com/brian1917/workloader/cmd/wkldexport"
 	"github.com/brian1917/workloader/cmd/wkldimport"
 	"github.com/brian1917/workloader/cmd/wkldiplmapping"
+	"github.com/brian1917/workloader/cmd/wkldrename"
 	"github.com/brian1917/workloader/cmd/wkldreplicate"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -73,26 +116,123 @@ var RootCmd = &cobra.Command{
 Workloader is a tool that helps manage resources in an Illumio PCE.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		viper.Set("debug", debug)
+		viper.Set("result_file_flag", resultFile)
+		utils.StartResult(cmd.Name())
+		logFormat = strings.ToLower(logFormat)
+		if logFormat != "" && logFormat != utils.LogFormatText && logFormat != utils.LogFormatJSON {
+			utils.LogErrorCode(utils.ExitValidation, "Invalid log-format - must be text or json.")
+		}
+		viper.Set("log_format_flag", logFormat)
+		viper.Set("log_file_flag", logFile)
+		viper.Set("log_max_size_flag", logMaxSize)
+		viper.Set("log_max_backups_flag", logMaxBackups)
+		viper.Set("log_max_age_flag", logMaxAge)
+		utils.ConfigureLog()
+		progress = strings.ToLower(progress)
+		if progress != "" && progress != utils.ProgressAuto && progress != utils.ProgressBar && progress != utils.ProgressPlain && progress != utils.ProgressOff {
+			utils.LogErrorCode(utils.ExitValidation, "Invalid progress - must be auto, bar, plain, or off.")
+		}
+		viper.Set("progress_flag", progress)
+		viper.Set("page_workers_flag", pageWorkers)
+		viper.Set("read_only_flag", readOnly)
+		if updatePCE && utils.ReadOnly() {
+			utils.LogWarning("read-only mode is enabled. ignoring --update-pce.", true)
+			updatePCE = false
+		}
 		viper.Set("update_pce", updatePCE)
+		viper.Set("simulate", !updatePCE && cmd.Annotations[utils.AnnotationSimulate] == "true")
+		provision, _ := cmd.Flags().GetBool("provision")
+		utils.SetRequiredCapabilities(cmd.Annotations[utils.AnnotationCapabilities], provision)
 		viper.Set("no_prompt", noPrompt)
 		viper.Set("verbose", verbose)
+		viper.Set("notify", notify)
+		viper.Set("email_to", emailTo)
 		// If the targetPCE is not set in the persistent flag, we clear it from the YAML
 		if targetPCE == "" {
 			viper.Set("target_pce", "")
 		} else {
 			viper.Set("target_pce", targetPCE)
 		}
+		viper.Set("target_org", targetOrg)
+		viper.Set("target_member", targetMember)
+		viper.Set("max_retries", maxRetries)
+		viper.Set("rps", rps)
+		viper.Set("connect_timeout_flag", connectTimeout)
+		viper.Set("read_timeout_flag", readTimeout)
+		viper.Set("long_poll_timeout_flag", longPollTimeout)
+		viper.Set("cache_ttl_flag", cacheTTL)
+		viper.Set("no_cache_flag", noCache)
 
-		//Output format
+		//Output format. The default_out key in the config file is used when --out is not set.
+		if !cmd.Flags().Changed("out") && viper.GetString("default_out") != "" {
+			outFormat = viper.GetString("default_out")
+		}
 		outFormat = strings.ToLower(outFormat)
 		if outFormat != "both" && outFormat != "stdout" && outFormat != "csv" {
-			utils.LogError("Invalid out - must be csv, stdout, or both.")
+			utils.LogErrorCode(utils.ExitValidation, "Invalid out - must be csv, stdout, or both.")
 		}
 		viper.Set("output_format", outFormat)
-		if err := viper.WriteConfig(); err != nil {
-			utils.LogError(err.Error())
+
+		// File format. The default_format key in the config file is used when --format is not set.
+		if fileFormat == "" {
+			fileFormat = viper.GetString("default_format")
+		}
+		fileFormat = strings.ToLower(fileFormat)
+		if fileFormat == "" {
+			fileFormat = "csv"
+		}
+		if !utils.ValidOutputFormat(fileFormat) {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("Invalid format - must be %s.", strings.Join(utils.OutputFormats(), ", ")))
+		}
+		viper.Set("file_format", fileFormat)
+		viper.Set("output_template_flag", outputTemplate)
+		viper.Set("output_dir_flag", outputDir)
+		if err := utils.WriteConfig(); err != nil {
+			utils.LogErrorCode(utils.ExitConfig, err.Error())
 		}
 
+		// Read the public keys or passphrase for encrypted output before the command does any work
+		viper.Set("encrypt_output_flag", encryptOutput)
+		if err := utils.LoadOutputEncryption(); err != nil {
+			utils.LogErrorCode(utils.ExitValidation, err.Error())
+		}
+
+		// Limit the workloads the command targets to the hrefs file
+		viper.Set("hrefs_file_flag", hrefsFile)
+		if hrefsFile != "" {
+			if cmd.Annotations[utils.AnnotationHrefsFile] != "true" {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--hrefs-file cannot be used with %s", cmd.Name()))
+			}
+			if err := utils.LoadHrefsFile(); err != nil {
+				utils.LogErrorCode(utils.ExitValidation, err.Error())
+			}
+		}
+
+		// Run the command on each PCE instead
+		if allPCEs || pceGroup != "" {
+			if approvalFile != "" {
+				utils.LogErrorCode(utils.ExitValidation, "--approval-file cannot be used with --all-pces or --pce-group")
+			}
+			utils.Exit(cmd.Name(), runAcrossPCEs(cmd))
+		}
+
+		// Write the changes to the approval file for review or run only if the changes match the approval file
+		viper.Set("approval_file_flag", approvalFile)
+		viper.Set("approval_review", false)
+		if approvalFile != "" && !updatePCE {
+			startApprovalReview(cmd)
+		} else if approvalFile != "" {
+			checkApproval(cmd, args)
+		}
+
+		// Open a servicenow ticket with the dry run output before updating the PCE
+		if updatePCE && snowTicket != "" && os.Getenv("WORKLOADER_SNOW_DRY_RUN") == "" {
+			openServiceNowTicket(cmd, args)
+		}
+
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		closeServiceNowTicket(cmd)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 
@@ -100,8 +240,12 @@ Workloader is a tool that helps manage resources in an Illumio PCE.`,
 	},
 }
 
-var updatePCE, noPrompt, debug, verbose bool
-var outFormat, targetPCE string
+var updatePCE, noPrompt, debug, verbose, notify, readOnly, allPCEs, noCache bool
+var emailTo, resultFile string
+var outFormat, fileFormat, outputTemplate, outputDir, encryptOutput, hrefsFile, logFormat, logFile, progress, targetPCE, pceGroup, targetOrg, targetMember, profile string
+var maxRetries, pageWorkers, logMaxSize, logMaxBackups, pceConcurrency int
+var rps float64
+var connectTimeout, readTimeout, longPollTimeout, logMaxAge, cacheTTL time.Duration
 
 // All subcommand flags are taken care of in their package's init.
 // Root init sets up everything else - all usage templates, Viper, etc.
@@ -113,11 +257,16 @@ func init() {
 	// Login
 	RootCmd.AddCommand(pcemgmt.AddPCECmd)
 	RootCmd.AddCommand(pcemgmt.RemovePCECmd)
+	RootCmd.AddCommand(pcemgmt.PCEKeychainCmd)
+	RootCmd.AddCommand(pcemgmt.PCEEncryptCmd)
+	RootCmd.AddCommand(pcemgmt.PCERotateKeyCmd)
 	RootCmd.AddCommand(pcemgmt.PCEListCmd)
+	RootCmd.AddCommand(pcemgmt.ProfileListCmd)
 	RootCmd.AddCommand(pcemgmt.GetDefaultPCECmd)
 	RootCmd.AddCommand(pcemgmt.SetDefaultPCECmd)
 	RootCmd.AddCommand(allpce.AllPceCmd)
 	RootCmd.AddCommand(allpce.TargetPcesCmd)
+	RootCmd.AddCommand(allpce.AllOrgsCmd)
 	RootCmd.AddCommand(pcemgmt.SetProxyCmd)
 	RootCmd.AddCommand(pcemgmt.ClearProxyCmd)
 
@@ -142,7 +291,14 @@ func init() {
 	RootCmd.AddCommand(cwpexport.ContainerProfileExportCmd)
 	RootCmd.AddCommand(cwpimport.ContainerProfileImportCmd)
 	RootCmd.AddCommand(flowimport.FlowImportCmd)
+	RootCmd.AddCommand(tfexport.TFExportCmd)
+	RootCmd.AddCommand(ansibleinventory.AnsibleInventoryCmd)
+	RootCmd.AddCommand(vulnimport.VulnImportCmd)
+	RootCmd.AddCommand(edrimport.EDRImportCmd)
+	RootCmd.AddCommand(dnsimport.DNSImportCmd)
+	RootCmd.AddCommand(dhcpimport.DHCPImportCmd)
 	RootCmd.AddCommand(templateimport.TemplateImportCmd)
+	RootCmd.AddCommand(apply.ApplyCmd)
 	RootCmd.AddCommand(templatelist.TemplateListCmd)
 	RootCmd.AddCommand(templatecreate.TemplateCreateCmd)
 
@@ -151,6 +307,17 @@ func init() {
 	RootCmd.AddCommand(subnet.SubnetCmd)
 	RootCmd.AddCommand(hostparse.HostnameCmd)
 	RootCmd.AddCommand(dagsync.DAGSyncCmd)
+	RootCmd.AddCommand(awssync.AWSSyncCmd)
+	RootCmd.AddCommand(azuresync.AzureSyncCmd)
+	RootCmd.AddCommand(gcpsync.GCPSyncCmd)
+	RootCmd.AddCommand(k8ssync.K8sSyncCmd)
+	RootCmd.AddCommand(vcentersync.VCenterSyncCmd)
+	RootCmd.AddCommand(f5sync.F5SyncCmd)
+	RootCmd.AddCommand(infobloxsync.InfobloxSyncCmd)
+	RootCmd.AddCommand(adsync.ADSyncCmd)
+	RootCmd.AddCommand(nsxsync.NSXSyncCmd)
+	RootCmd.AddCommand(idpsync.IdPSyncCmd)
+	RootCmd.AddCommand(consulsync.ConsulSyncCmd)
 
 	// Workload management
 	RootCmd.AddCommand(compatibility.CompatibilityCmd)
@@ -159,6 +326,8 @@ func init() {
 	RootCmd.AddCommand(getpairingkey.GetPairingKey)
 	RootCmd.AddCommand(unpair.UnpairCmd)
 	RootCmd.AddCommand(deletehrefs.DeleteCmd)
+	RootCmd.AddCommand(undo.UndoCmd)
+	RootCmd.AddCommand(pluginListCmd)
 	RootCmd.AddCommand(umwlcleanup.UMWLCleanUpCmd)
 	RootCmd.AddCommand(nicmanage.NICManageCmd)
 	RootCmd.AddCommand(containmentswitch.ContainmentSwitchCmd)
@@ -167,9 +336,11 @@ func init() {
 
 	// Label management
 	RootCmd.AddCommand(deleteunusedlabels.LabelsDeleteUnusedCmd)
+	RootCmd.AddCommand(labelwriteback.LabelWritebackCmd)
 
 	// Reporting
 	RootCmd.AddCommand(ruleexport.RuleUsageCmd)
+	RootCmd.AddCommand(ruleexport.RuleUsageTrendCmd)
 	RootCmd.AddCommand(unusedports.UnusedPortsCmd)
 	RootCmd.AddCommand(mislabel.MisLabelCmd)
 	RootCmd.AddCommand(dupecheck.DupeCheckCmd)
@@ -181,6 +352,29 @@ func init() {
 	RootCmd.AddCommand(wkldiplmapping.WkldIPLMappingCmd)
 	RootCmd.AddCommand(venhealth.VenHealthCmd)
 	RootCmd.AddCommand(unusedumwl.UnusedUmwlCmd)
+	RootCmd.AddCommand(compliancereport.ComplianceReportCmd)
+	RootCmd.AddCommand(policydiff.PolicyDiffCmd)
+	RootCmd.AddCommand(report.ReportCmd)
+	RootCmd.AddCommand(driftreport.DriftReportCmd)
+	RootCmd.AddCommand(labelcoverage.LabelCoverageCmd)
+	RootCmd.AddCommand(rulecomplexity.RuleComplexityCmd)
+	RootCmd.AddCommand(enforcementreadiness.EnforcementReadinessCmd)
+	RootCmd.AddCommand(dependencymap.DependencyMapCmd)
+	RootCmd.AddCommand(exposure.ExposureCmd)
+	RootCmd.AddCommand(ruleanalysis.RuleAnalysisCmd)
+	RootCmd.AddCommand(broadrules.BroadRulesCmd)
+	RootCmd.AddCommand(venversions.VenVersionsCmd)
+	RootCmd.AddCommand(changereport.ChangeReportCmd)
+	RootCmd.AddCommand(rbacaudit.RBACAuditCmd)
+	RootCmd.AddCommand(certexpiry.CertExpiryCmd)
+	RootCmd.AddCommand(pairingprofileaudit.PairingProfileAuditCmd)
+	RootCmd.AddCommand(pairingprofileimport.PairingProfileImportCmd)
+	RootCmd.AddCommand(trafficanomaly.TrafficBaselineCmd)
+	RootCmd.AddCommand(trafficanomaly.TrafficAnomalyCmd)
+	RootCmd.AddCommand(wkldrename.WkldRenameCmd)
+	RootCmd.AddCommand(servemetrics.ServeMetricsCmd)
+	RootCmd.AddCommand(server.ServerCmd)
+	RootCmd.AddCommand(scheduler.SchedulerCmd)
 
 	// Version Commands
 	RootCmd.AddCommand(versionCmd)
@@ -192,6 +386,9 @@ func init() {
 	// Undocumented
 	RootCmd.AddCommand(extract.ExtractCmd)
 
+	// Plugins
+	addPluginCommands()
+
 	// Set the usage templates
 	for _, c := range RootCmd.Commands() {
 		c.SetUsageTemplate(utils.SubCmdTemplate())
@@ -201,31 +398,72 @@ func init() {
 
 	// Setup Viper
 	viper.SetConfigType("yaml")
-	if os.Getenv("ILLUMIO_CONFIG") != "" {
-		viper.SetConfigFile(os.Getenv("ILLUMIO_CONFIG"))
-	} else {
-		viper.SetConfigFile("./pce.yaml")
+	configFile, err := utils.ConfigFile(os.Args[1:])
+	if err != nil {
+		utils.LogErrorCode(utils.ExitConfig, err.Error())
 	}
+	viper.SetConfigFile(configFile)
 	viper.ReadInConfig()
 
 	// Persistent flags that will be passed into root command pre-run.
 	RootCmd.PersistentFlags().BoolVar(&updatePCE, "update-pce", false, "Command will update the PCE after a single user prompt. Default will just log potentialy changes to workloads.")
+	RootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "Refuse every PCE api request that makes a change, regardless of other flags. Traffic queries are allowed. Can also be set with read_only: true in pce.yaml or WORKLOADER_READ_ONLY=true.")
 	RootCmd.PersistentFlags().BoolVar(&noPrompt, "no-prompt", false, "Remove the user prompt when used with update-pce.")
+	RootCmd.PersistentFlags().StringVar(&approvalFile, "approval-file", "", "Two-step review and apply without a prompt. Without --update-pce, the changes are simulated and written with their hash to this file for review. With --update-pce, the command runs without a prompt only if the changes still match the file.")
+	RootCmd.PersistentFlags().StringVar(&hrefsFile, "hrefs-file", "", "File with one workload or VEN href per line, such as the output of a previous report, to limit unpair, mode, upgrade, delete, wkld-export, and increase-ven-rate to those objects. A csv with a header uses the href column.")
 	RootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug level logging for troubleshooting.")
 	RootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "When debug is enabled, include the raw API responses. This makes workloader.log increase in size significantly.")
+	RootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 0, "Retry PCE api calls that are throttled (429), unavailable (502, 503, 504), or fail to connect with backoff that honors Retry-After and X-RateLimit headers. Default uses api_max_retries in pce.yaml or WORKLOADER_API_MAX_RETRIES.")
+	RootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", 0, "Timeout to connect to the PCE (e.g., 30s). Default uses connect_timeout in the PCE entry or top level of pce.yaml or WORKLOADER_CONNECT_TIMEOUT. 0 is no limit.")
+	RootCmd.PersistentFlags().DurationVar(&readTimeout, "read-timeout", 0, "Timeout for each PCE api request (e.g., 5m). Default uses read_timeout in the PCE entry or top level of pce.yaml or WORKLOADER_READ_TIMEOUT. 0 is no limit.")
+	RootCmd.PersistentFlags().DurationVar(&longPollTimeout, "long-poll-timeout", 0, "Timeout for async jobs and traffic queries, including waiting for results (e.g., 1h). Default uses long_poll_timeout in the PCE entry or top level of pce.yaml or WORKLOADER_LONG_POLL_TIMEOUT. 0 is no limit.")
+	RootCmd.PersistentFlags().Float64Var(&rps, "rps", 0, "Maximum PCE api requests per second. The rate is reduced automatically when the PCE throttles. Default uses api_rps in pce.yaml or WORKLOADER_API_RPS. 0 is unlimited.")
+	RootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0, "Cache PCE GET responses, such as all workloads and labels, for this long (e.g., 30m) so commands run back-to-back do not download the same objects. Any change made through workloader clears the cache. Default uses cache_ttl in pce.yaml or WORKLOADER_CACHE_TTL. 0 does not cache.")
+	RootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Send every request to the PCE even when a cache ttl is set.")
+	RootCmd.PersistentFlags().IntVar(&pageWorkers, "page-workers", 0, "Maximum concurrent PCE api requests for commands that fetch pages in parallel. Requests still honor --rps. Default uses page_workers in pce.yaml or WORKLOADER_PAGE_WORKERS and then 4.")
 	RootCmd.PersistentFlags().StringVar(&outFormat, "out", "csv", "Output format. 3 options: csv, stdout, both")
+	RootCmd.PersistentFlags().StringVar(&fileFormat, "format", "", "Output file format: csv, json, jsonl, yaml, xlsx, or html. Default uses default_format in pce.yaml and then csv. Import commands read csv.")
+	RootCmd.PersistentFlags().StringVar(&outputTemplate, "output-template", "", "Template for output file names (e.g., {command}-{pce}-{timestamp}.csv). Tokens are {command}, {detail}, {name}, {pce}, {org}, {timestamp}, {date}, and {ext}. Default uses output_template in pce.yaml and then the command's name. Names set with --output-file are not changed.")
+	RootCmd.PersistentFlags().StringVar(&outputDir, "output-dir", "", "Directory for output files without a path. Default uses output_dir in pce.yaml and then the current directory.")
+	RootCmd.PersistentFlags().StringVar(&encryptOutput, "encrypt-output", "", "Encrypt output files with OpenPGP so they can be decrypted with gpg. A comma-separated list of public key files to encrypt to or passphrase to use WORKLOADER_OUTPUT_PASSPHRASE or a prompt. Encrypted files end in .gpg. Default uses encrypt_output in pce.yaml or WORKLOADER_ENCRYPT_OUTPUT.")
+	RootCmd.PersistentFlags().StringVar(&progress, "progress", "", "Progress for long-running commands. 4 options: auto, bar, plain, off. auto uses a bar on a terminal and plain log lines when output is redirected or in CI. Default uses progress in pce.yaml or WORKLOADER_PROGRESS and then auto.")
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "Log format for workloader.log and stdout logs. 2 options: text, json. Default uses log_format in pce.yaml or WORKLOADER_LOG_FORMAT and then text.")
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Log file. Default uses log_file in pce.yaml or WORKLOADER_LOG_FILE and then workloader.log in the working directory.")
+	RootCmd.PersistentFlags().IntVar(&logMaxSize, "log-max-size", 0, "Rotate the log file when it is larger than this many MB. Default uses log_max_size in pce.yaml or WORKLOADER_LOG_MAX_SIZE. 0 does not rotate by size.")
+	RootCmd.PersistentFlags().IntVar(&logMaxBackups, "log-max-backups", 0, "Number of rotated log files to keep. Default uses log_max_backups in pce.yaml or WORKLOADER_LOG_MAX_BACKUPS. 0 keeps all.")
+	RootCmd.PersistentFlags().DurationVar(&logMaxAge, "log-max-age", 0, "Remove rotated log files older than this (e.g., 720h). Default uses log_max_age in pce.yaml or WORKLOADER_LOG_MAX_AGE. 0 keeps all.")
+	RootCmd.PersistentFlags().StringVar(&resultFile, "result-file", "", "Write a json summary of the command (status, exit code, output files, warnings, failures, and objects changed) to this file when it exits. Can also be set with WORKLOADER_RESULT_FILE.")
+	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config profile to use. A profile name in ~/.workloader/profiles (or WORKLOADER_PROFILES_DIR), a directory with a pce.yaml, or a yaml file. The WORKLOADER_PROFILE environment variable can be used instead.")
 	RootCmd.PersistentFlags().StringVar(&targetPCE, "pce", "", "PCE to use in command if not using default PCE.")
+	RootCmd.PersistentFlags().BoolVar(&allPCEs, "all-pces", false, "Run the command on every PCE in pce.yaml and environment variables concurrently. Output lines and logs are prefixed with the PCE name, output files start with the PCE name, and a summary is written when all PCEs finish.")
+	RootCmd.PersistentFlags().StringVar(&pceGroup, "pce-group", "", "Run the command on each PCE of a group in pce_groups in pce.yaml like --all-pces.")
+	RootCmd.PersistentFlags().IntVar(&pceConcurrency, "pce-concurrency", 4, "Maximum PCEs to run the command on at the same time with --all-pces or --pce-group.")
+	RootCmd.PersistentFlags().StringVar(&targetMember, "member", "", "Supercluster member fqdn or short name to send read requests to. Writes always go to the leader.")
+	RootCmd.PersistentFlags().StringVar(&targetOrg, "org", "", "Org id or name from the orgs list of the PCE entry. Default is the org of the PCE entry.")
+	RootCmd.PersistentFlags().BoolVar(&notify, "notify", false, "Post start, finish, and failure summaries to Slack and/or Teams. Requires notify_slack_webhook and/or notify_teams_webhook in pce.yaml or the WORKLOADER_NOTIFY_SLACK_WEBHOOK and WORKLOADER_NOTIFY_TEAMS_WEBHOOK environment variables.")
+	RootCmd.PersistentFlags().StringVar(&emailTo, "email-to", "", "Comma-separated list of email addresses to send the output files to when the command completes. Requires smtp_server and smtp_from in pce.yaml or WORKLOADER_SMTP_ environment variables. Optional smtp_port, smtp_user, smtp_password, and smtp_tls (auto, implicit, starttls, or none).")
+	RootCmd.PersistentFlags().StringVar(&snowTicket, "snow-ticket", "", "Open a ServiceNow change or incident with the dry run output when used with update-pce. Requires servicenow_instance, servicenow_user, and servicenow_password in pce.yaml or WORKLOADER_SERVICENOW_ environment variables.")
+	RootCmd.PersistentFlags().BoolVar(&snowWaitApproval, "snow-wait-approval", false, "Wait for the ServiceNow change to be approved before updating the PCE. The command stops if the change is rejected.")
+	RootCmd.PersistentFlags().DurationVar(&snowApprovalTimeout, "snow-approval-timeout", 24*time.Hour, "Maximum time to wait for ServiceNow approval.")
+
+	// Shell completion for PCE names, labels, and rulesets
+	registerCompletions()
 
 	RootCmd.Flags().SortFlags = false
 
 }
 
-// Execute is called by the CLI main function to initiate the Cobra application
+// Execute is called by the CLI main function to initiate the Cobra application. It exits with ExitValidation for invalid commands,
+// flags, or arguments and with the code of the command (e.g., ExitPartial) otherwise. Commands exit with LogError on errors.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd, err := RootCmd.ExecuteC()
+	if err != nil {
+		// The flags were not parsed
+		viper.Set("result_file_flag", utils.FlagFromArgs(os.Args[1:], "--result-file"))
+		utils.StartResult(cmd.Name())
+		utils.LogErrorCode(utils.ExitValidation, err.Error())
 	}
+	utils.Exit(cmd.Name(), utils.ExitSuccess)
 }
 
 // versionCmd returns the version of workloader
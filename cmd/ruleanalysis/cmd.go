@@ -0,0 +1,54 @@
+package ruleanalysis
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName string
+var draft, noBoundaries bool
+
+func init() {
+	RuleAnalysisCmd.Flags().BoolVar(&draft, "draft", false, "analyze the draft policy instead of the active policy.")
+	RuleAnalysisCmd.Flags().BoolVar(&noBoundaries, "no-boundaries", false, "do not check for rules made redundant by enforcement boundaries.")
+	RuleAnalysisCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	RuleAnalysisCmd.Flags().SortFlags = false
+}
+
+// RuleAnalysisCmd finds duplicate, shadowed, and redundant rules
+var RuleAnalysisCmd = &cobra.Command{
+	Use:   "rule-analysis",
+	Short: "Find duplicate rules, rules shadowed by broader rules, and rules made redundant by enforcement boundaries.",
+	Long: `
+Find rules that can be deleted and create a cleanup csv.
+
+Enabled rules in enabled rulesets are checked for:
+- duplicate: the rule has the same scopes, extra-scope setting, consumers, providers, and services as another rule. The rule with the lowest href is kept.
+- shadowed: another rule allows everything the rule allows. The other rule's ruleset scopes are the same or broader, its consumers and providers include the rule's (all workloads includes labels and workloads, and labels of fewer keys or more values are broader), and its services include the rule's ports.
+- boundary_redundant: every workload the rule applies to is in selective enforcement and no enforcement boundary applies to those workloads on the rule's services, so the traffic is allowed without the rule.
+
+Label groups are compared by their labels. Ip lists are compared by href except an ip list with 0.0.0.0/0 or ::/0 includes every ip list. Services with processes or windows services are compared by href. Rules with different resolve labels as, stateless, machine authentication, or secure connect settings are not compared.
+
+The first column of the output is the draft href of each rule so the csv can be reviewed and used as the input for the delete command (e.g., workloader delete cleanup.csv --provision --update-pce).
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Analyze the active policy
+  workloader rule-analysis --output-file cleanup.csv
+
+  # Delete the rules after reviewing the csv
+  workloader delete cleanup.csv --provision --update-pce`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("rule-analysis")
+		ruleAnalysis()
+		utils.LogEndCommand("rule-analysis")
+	},
+}
@@ -0,0 +1,267 @@
+package ruleanalysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// labelSet is label hrefs by key. A workload matches if it has one of the labels of every key.
+type labelSet map[string]map[string]bool
+
+// add adds a label to the set
+func (s labelSet) add(href string) {
+	key := pce.Labels[href].Key
+	if s[key] == nil {
+		s[key] = make(map[string]bool)
+	}
+	s[key][href] = true
+}
+
+// addGroup adds the labels of a label group and its sub groups to the set
+func (s labelSet) addGroup(href string) {
+	for _, l := range pce.ExpandLabelGroup(href) {
+		s.add(l)
+	}
+}
+
+// matches returns true if the workload has one of the labels of every key in the set
+func (s labelSet) matches(w illumioapi.Workload) bool {
+	for key, hrefs := range s {
+		if !hrefs[w.GetLabelByKey(key, pce.Labels).Href] {
+			return false
+		}
+	}
+	return true
+}
+
+// covers returns true if every workload that matches o matches s. s can not have a key o does not have and its labels for each key include o's.
+func (s labelSet) covers(o labelSet) bool {
+	for key, hrefs := range s {
+		if len(o[key]) == 0 {
+			return false
+		}
+		for href := range o[key] {
+			if !hrefs[href] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s labelSet) String() string {
+	keys := []string{}
+	for key, hrefs := range s {
+		keys = append(keys, fmt.Sprintf("%s=%s", key, joinKeys(hrefs)))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// actors are the consumers or providers of a rule. Labels of different keys in a rule are combined so they are one label set.
+type actors struct {
+	ams       bool
+	labels    labelSet
+	workloads map[string]bool
+	ipLists   map[string]bool
+	anyIP     bool
+	other     map[string]bool
+}
+
+func newActors() actors {
+	return actors{labels: make(labelSet), workloads: make(map[string]bool), ipLists: make(map[string]bool), other: make(map[string]bool)}
+}
+
+// add adds the actors of a consumer or provider
+func (a *actors) add(actor string, label *illumioapi.Label, labelGroup *illumioapi.LabelGroup, wkld *illumioapi.Workload, ipl *illumioapi.IPList) {
+	if actor == "ams" {
+		a.ams = true
+	}
+	if label != nil {
+		a.labels.add(label.Href)
+	}
+	if labelGroup != nil {
+		a.labels.addGroup(labelGroup.Href)
+	}
+	if wkld != nil {
+		a.workloads[wkld.Href] = true
+	}
+	if ipl != nil {
+		a.ipLists[ipl.Href] = true
+		if utils.AnyIP(pce.IPLists[ipl.Href]) {
+			a.anyIP = true
+		}
+	}
+}
+
+// covers returns true if a includes every actor of o
+func (a actors) covers(o actors) bool {
+	if o.ams && !a.ams {
+		return false
+	}
+	if len(o.labels) > 0 && !a.ams && (len(a.labels) == 0 || !a.labels.covers(o.labels)) {
+		return false
+	}
+	for href := range o.workloads {
+		if !a.ams && !a.workloads[href] {
+			return false
+		}
+	}
+	for href := range o.ipLists {
+		if !a.anyIP && !a.ipLists[href] {
+			return false
+		}
+	}
+	for href := range o.other {
+		if !a.other[href] {
+			return false
+		}
+	}
+	return true
+}
+
+// includes returns true if the workload is one of the actors
+func (a actors) includes(w illumioapi.Workload) bool {
+	return a.ams || a.workloads[w.Href] || len(a.labels) > 0 && a.labels.matches(w)
+}
+
+func (a actors) String() string {
+	return fmt.Sprintf("ams=%t;labels=%s;workloads=%s;ip_lists=%s;other=%s", a.ams, a.labels, joinKeys(a.workloads), joinKeys(a.ipLists), joinKeys(a.other))
+}
+
+// portRange is a range of ports for a protocol
+type portRange struct {
+	proto, from, to int
+}
+
+// services are the ports of a rule. Services with processes or windows services are compared by href.
+type services struct {
+	all    bool
+	ranges []portRange
+	other  map[string]bool
+}
+
+// newServices returns the services of ingress services
+func newServices(ingressServices []*illumioapi.IngressServices) services {
+	s := services{other: make(map[string]bool)}
+	for _, is := range ingressServices {
+		if is == nil {
+			continue
+		}
+		if is.Href != nil {
+			svc := pce.Services[*is.Href]
+			if svc.ProcessName != "" || len(svc.WindowsServices) > 0 {
+				s.other[*is.Href] = true
+				continue
+			}
+			for _, sp := range svc.ServicePorts {
+				if sp != nil {
+					s.addPort(sp.Port, sp.ToPort, sp.Protocol)
+				}
+			}
+			continue
+		}
+		port, toPort, proto := 0, 0, 0
+		if is.Port != nil {
+			port = *is.Port
+		}
+		if is.ToPort != nil {
+			toPort = *is.ToPort
+		}
+		if is.Protocol != nil {
+			proto = *is.Protocol
+		}
+		s.addPort(port, toPort, proto)
+	}
+	sort.Slice(s.ranges, func(i, j int) bool {
+		if s.ranges[i].proto != s.ranges[j].proto {
+			return s.ranges[i].proto < s.ranges[j].proto
+		}
+		if s.ranges[i].from != s.ranges[j].from {
+			return s.ranges[i].from < s.ranges[j].from
+		}
+		return s.ranges[i].to < s.ranges[j].to
+	})
+	return s
+}
+
+// addPort adds a port or port range. Protocol -1 is all services and a protocol without a port is all ports.
+func (s *services) addPort(port, toPort, proto int) {
+	if proto == -1 {
+		s.all = true
+		return
+	}
+	if port == 0 {
+		s.ranges = append(s.ranges, portRange{proto: proto, from: 0, to: 65535})
+		return
+	}
+	if toPort < port {
+		toPort = port
+	}
+	s.ranges = append(s.ranges, portRange{proto: proto, from: port, to: toPort})
+}
+
+// covers returns true if s includes every port of o
+func (s services) covers(o services) bool {
+	if s.all {
+		return true
+	}
+	if o.all {
+		return false
+	}
+	for href := range o.other {
+		if !s.other[href] {
+			return false
+		}
+	}
+	for _, or := range o.ranges {
+		covered := false
+		for _, sr := range s.ranges {
+			if sr.proto == or.proto && sr.from <= or.from && sr.to >= or.to {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps returns true if s and o can have a port in common. Services compared by href always overlap.
+func (s services) overlaps(o services) bool {
+	if s.all || o.all || len(s.other) > 0 || len(o.other) > 0 {
+		return true
+	}
+	for _, sr := range s.ranges {
+		for _, or := range o.ranges {
+			if sr.proto == or.proto && sr.from <= or.to && or.from <= sr.to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s services) String() string {
+	ranges := []string{}
+	for _, r := range s.ranges {
+		ranges = append(ranges, fmt.Sprintf("%d:%d-%d", r.proto, r.from, r.to))
+	}
+	return fmt.Sprintf("all=%t;ranges=%s;other=%s", s.all, strings.Join(ranges, ","), joinKeys(s.other))
+}
+
+// joinKeys returns the sorted keys of a set joined with semicolons
+func joinKeys(m map[string]bool) string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ";")
+}
@@ -0,0 +1,322 @@
+package ruleanalysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/ruleexport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Findings
+const (
+	findingDuplicate         = "duplicate"
+	findingShadowed          = "shadowed"
+	findingBoundaryRedundant = "boundary_redundant"
+)
+
+// analyzedRule is an enabled rule with its scopes, actors, and services normalized for comparison
+type analyzedRule struct {
+	ruleSet   illumioapi.RuleSet
+	rule      *illumioapi.Rule
+	scopes    []labelSet
+	unscoped  bool
+	consumers actors
+	providers actors
+	services  services
+	settings  string
+}
+
+// key returns a string that is the same for duplicate rules
+func (r analyzedRule) key() string {
+	scopes := []string{}
+	for _, s := range r.scopes {
+		scopes = append(scopes, s.String())
+	}
+	sort.Strings(scopes)
+	return strings.Join([]string{strings.Join(scopes, "|"), fmt.Sprintf("%t", r.unscoped), r.consumers.String(), r.providers.String(), r.services.String(), r.settings}, "#")
+}
+
+// covers returns true if r allows everything o allows
+func (r analyzedRule) covers(o analyzedRule) bool {
+	if r.settings != o.settings || o.unscoped && !r.unscoped {
+		return false
+	}
+	for _, os := range o.scopes {
+		covered := false
+		for _, rs := range r.scopes {
+			if rs.covers(os) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return r.consumers.covers(o.consumers) && r.providers.covers(o.providers) && r.services.covers(o.services)
+}
+
+// finding is a rule that can be deleted
+type finding struct {
+	rule      analyzedRule
+	finding   string
+	coveredBy *analyzedRule
+	detail    string
+}
+
+func ruleAnalysis() {
+
+	// Load the PCE
+	provisionStatus := "active"
+	if draft {
+		provisionStatus = "draft"
+	}
+	load := illumioapi.LoadInput{ProvisionStatus: provisionStatus, Labels: true, LabelGroups: true, IPLists: true, Services: true, RuleSets: true}
+	if !noBoundaries {
+		load.Workloads = true
+		load.WorkloadsQueryParameters = map[string]string{"managed": "true"}
+	}
+	apiResps, err := pce.Load(load)
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Normalize the enabled rules of enabled rulesets sorted by href. The ruleset map is keyed by href and name so only hrefs are used.
+	rules := []analyzedRule{}
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href || rs.Enabled != nil && !*rs.Enabled {
+			continue
+		}
+		for _, r := range rs.Rules {
+			if r == nil || r.Enabled != nil && !*r.Enabled {
+				continue
+			}
+			rules = append(rules, analyze(rs, r))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].rule.Href < rules[j].rule.Href })
+	utils.LogInfo(fmt.Sprintf("analyzing %d enabled rules in the %s policy", len(rules), provisionStatus), true)
+
+	// Duplicates keep the first rule with the same key
+	findings := []finding{}
+	flagged := make(map[string]bool)
+	first := make(map[string]int)
+	for i, r := range rules {
+		k := r.key()
+		if j, ok := first[k]; ok {
+			findings = append(findings, finding{rule: r, finding: findingDuplicate, coveredBy: &rules[j], detail: "same scopes, consumers, providers, and services"})
+			flagged[r.rule.Href] = true
+			continue
+		}
+		first[k] = i
+	}
+
+	// Shadowed rules are covered by a rule that is not flagged. Rules that cover each other are duplicates and the first is kept.
+	for i, r := range rules {
+		if flagged[r.rule.Href] {
+			continue
+		}
+		for j, o := range rules {
+			if i == j || flagged[o.rule.Href] || !o.covers(r) {
+				continue
+			}
+			if r.covers(o) {
+				if j < i {
+					findings = append(findings, finding{rule: r, finding: findingDuplicate, coveredBy: &rules[j], detail: "allows the same traffic"})
+					flagged[r.rule.Href] = true
+					break
+				}
+				continue
+			}
+			findings = append(findings, finding{rule: r, finding: findingShadowed, coveredBy: &rules[j], detail: "a broader rule allows the same traffic"})
+			flagged[r.rule.Href] = true
+			break
+		}
+	}
+
+	// Rules made redundant by enforcement boundaries
+	if !noBoundaries {
+		findings = append(findings, boundaryRedundant(rules, flagged, provisionStatus)...)
+	}
+
+	// Build the output
+	data := [][]string{{ruleexport.HeaderRuleHref, ruleexport.HeaderRulesetName, ruleexport.HeaderRulesetHref, ruleexport.HeaderRuleDescription, "finding", "covered_by_rule_href", "covered_by_ruleset_name", "detail"}}
+	counts := make(map[string]int)
+	for _, f := range findings {
+		coveredHref, coveredRuleSet := "", ""
+		if f.coveredBy != nil {
+			coveredHref, coveredRuleSet = f.coveredBy.rule.Href, f.coveredBy.ruleSet.Name
+		}
+		data = append(data, []string{strings.Replace(f.rule.rule.Href, "/sec_policy/active/", "/sec_policy/draft/", 1), f.rule.ruleSet.Name, f.rule.ruleSet.Href, f.rule.rule.Description, f.finding, coveredHref, coveredRuleSet, f.detail})
+		counts[f.finding]++
+	}
+	utils.LogInfo(fmt.Sprintf("%d duplicate, %d shadowed, and %d boundary redundant rules", counts[findingDuplicate], counts[findingShadowed], counts[findingBoundaryRedundant]), true)
+	if len(data) == 1 {
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-rule-analysis-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// analyze normalizes a rule for comparison
+func analyze(rs illumioapi.RuleSet, r *illumioapi.Rule) analyzedRule {
+	a := analyzedRule{ruleSet: rs, rule: r, unscoped: r.UnscopedConsumers != nil && *r.UnscopedConsumers, consumers: newActors(), providers: newActors()}
+
+	// A ruleset without scopes is one empty scope
+	if len(rs.Scopes) == 0 {
+		a.scopes = []labelSet{{}}
+	}
+	for _, scope := range rs.Scopes {
+		s := labelSet{}
+		for _, l := range scope {
+			if l == nil {
+				continue
+			}
+			if l.Label != nil {
+				s.add(l.Label.Href)
+			}
+			if l.LabelGroup != nil {
+				s.addGroup(l.LabelGroup.Href)
+			}
+		}
+		a.scopes = append(a.scopes, s)
+	}
+
+	for _, c := range r.Consumers {
+		if c == nil {
+			continue
+		}
+		a.consumers.add(c.Actors, c.Label, c.LabelGroup, c.Workload, c.IPList)
+		if c.VirtualService != nil {
+			a.consumers.other[c.VirtualService.Href] = true
+		}
+	}
+	for _, p := range r.Providers {
+		if p == nil {
+			continue
+		}
+		a.providers.add(p.Actors, p.Label, p.LabelGroup, p.Workload, p.IPList)
+		if p.VirtualService != nil {
+			a.providers.other[p.VirtualService.Href] = true
+		}
+		if p.VirtualServer != nil {
+			a.providers.other[p.VirtualServer.Href] = true
+		}
+	}
+	for _, s := range r.ConsumingSecurityPrincipals {
+		if s != nil {
+			a.consumers.other[s.Href] = true
+		}
+	}
+
+	if r.IngressServices != nil {
+		a.services = newServices(*r.IngressServices)
+	}
+
+	// Settings that change what a rule allows must be the same to compare rules
+	settings := []string{}
+	if r.ResolveLabelsAs != nil {
+		c, p := append([]string{}, r.ResolveLabelsAs.Consumers...), append([]string{}, r.ResolveLabelsAs.Providers...)
+		sort.Strings(c)
+		sort.Strings(p)
+		settings = append(settings, strings.Join(c, ","), strings.Join(p, ","))
+	}
+	for _, b := range []*bool{r.Stateless, r.MachineAuth, r.SecConnect} {
+		settings = append(settings, fmt.Sprintf("%t", b != nil && *b))
+	}
+	a.settings = strings.Join(settings, ";")
+	return a
+}
+
+// boundary is an enforcement boundary with its providers and services normalized for comparison
+type boundary struct {
+	name      string
+	providers actors
+	services  services
+}
+
+// boundaryRedundant returns the rules whose providers are all in selective enforcement with no enforcement boundary applying to them on the
+// rule's services. Rules with providers that are not workloads (e.g., virtual services) are skipped.
+func boundaryRedundant(rules []analyzedRule, flagged map[string]bool, provisionStatus string) []finding {
+	findings := []finding{}
+
+	// Enforcement boundaries are not in the illumioapi load
+	ebs := []illumioapi.EnforcementBoundary{}
+	a, err := pce.GetCollection("sec_policy/"+provisionStatus+"/enforcement_boundaries", false, nil, &ebs)
+	utils.LogAPIResp("GetEnforcementBoundaries", a)
+	if err != nil && a.StatusCode == 404 {
+		utils.LogWarning("the pce does not support enforcement boundaries. skipping boundary redundant rules.", true)
+		return findings
+	} else if err != nil {
+		utils.LogError(fmt.Sprintf("getting enforcement boundaries - %s", err))
+	}
+	boundaries := []boundary{}
+	for _, eb := range ebs {
+		b := boundary{name: eb.Name, providers: newActors()}
+		for _, p := range eb.Providers {
+			b.providers.add(p.Actors, p.Label, p.LabelGroup, p.Workload, p.IPList)
+		}
+		ingressServices := []*illumioapi.IngressServices{}
+		for i := range eb.IngressServices {
+			ingressServices = append(ingressServices, &eb.IngressServices[i])
+		}
+		b.services = newServices(ingressServices)
+		boundaries = append(boundaries, b)
+	}
+
+	for _, r := range rules {
+		if flagged[r.rule.Href] || len(r.providers.ipLists) > 0 || len(r.providers.other) > 0 {
+			continue
+		}
+		selective := []illumioapi.Workload{}
+		for _, w := range pce.WorkloadsSlice {
+			if w.Deleted != nil && *w.Deleted || !r.inScope(w) || !r.providers.includes(w) {
+				continue
+			}
+			if w.GetMode() != "selective" {
+				selective = nil
+				break
+			}
+			selective = append(selective, w)
+		}
+		if len(selective) == 0 {
+			continue
+		}
+		applies := false
+		for _, b := range boundaries {
+			if !b.services.overlaps(r.services) {
+				continue
+			}
+			for _, w := range selective {
+				if b.providers.includes(w) {
+					applies = true
+					break
+				}
+			}
+			if applies {
+				break
+			}
+		}
+		if !applies {
+			findings = append(findings, finding{rule: r, finding: findingBoundaryRedundant, detail: fmt.Sprintf("all %d providing workloads are in selective enforcement and no enforcement boundary applies. the rule is needed if they move to full enforcement.", len(selective))})
+		}
+	}
+	return findings
+}
+
+// inScope returns true if the workload is in a scope of the rule's ruleset
+func (r analyzedRule) inScope(w illumioapi.Workload) bool {
+	for _, s := range r.scopes {
+		if s.matches(w) {
+			return true
+		}
+	}
+	return false
+}
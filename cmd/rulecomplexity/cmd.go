@@ -0,0 +1,62 @@
+package rulecomplexity
+
+import (
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var outputFileName, rulesetFileName string
+var draft bool
+var minScore int
+
+func init() {
+	RuleComplexityCmd.Flags().BoolVar(&draft, "draft", false, "score the draft policy instead of the active policy.")
+	RuleComplexityCmd.Flags().IntVar(&minScore, "min-score", 0, "only output rules with a score of at least this value.")
+	RuleComplexityCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the rule output file location. default is current location with a timestamped filename.")
+	RuleComplexityCmd.Flags().StringVar(&rulesetFileName, "ruleset-file", "", "optionally specify the name of the ruleset output file location. default is current location with a timestamped filename.")
+	RuleComplexityCmd.Flags().SortFlags = false
+}
+
+// RuleComplexityCmd scores rules on complexity and risk
+var RuleComplexityCmd = &cobra.Command{
+	Use:   "rule-complexity",
+	Short: "Score each ruleset and rule on complexity and risk to prioritize policy cleanup.",
+	Long: `
+Score each enabled rule in enabled rulesets on complexity and risk to prioritize policy cleanup. The score is the sum of the risk and complexity scores.
+
+Risk score:
+- any ip (0.0.0.0/0 or ::/0) consumer: 5
+- all services: 5. otherwise more than 1000 ports: 4, more than 100: 3, more than 10: 2, more than 1: 1. a tcp or udp service without a port is all ports.
+- ruleset with no scope (all workloads): 3. otherwise a scope with 1 label: 2 or 2 labels: 1.
+- extra-scope (unscoped) consumers: 2
+- all workloads consumer: 3 if across the pce (global ruleset or extra-scope) or 2 in the scope
+- all workloads provider: 3 if across the pce (global ruleset) or 2 in the scope
+
+Complexity score:
+- 1 for each consumer and provider after the first of each
+- 1 for each service after the first
+- 1 for each ruleset scope after the first because the rule is applied in each scope
+
+The rule output is sorted by score with the reasons for each score. The ruleset output has the number of rules and the total, maximum, and average rule score of each ruleset.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Score the active policy
+  workloader rule-complexity
+
+  # Only output the draft rules with a score of 8 or more
+  workloader rule-complexity --draft --min-score 8`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("rule-complexity")
+		ruleComplexity()
+		utils.LogEndCommand("rule-complexity")
+	},
+}
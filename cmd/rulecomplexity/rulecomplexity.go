@@ -0,0 +1,281 @@
+package rulecomplexity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// allPorts is the number of ports of a tcp or udp service without a port
+const allPorts = 65535
+
+// score is the risk and complexity of a rule with the reasons for each point
+type score struct {
+	risk, complexity int
+	reasons          []string
+}
+
+// add adds points with the reason
+func (s *score) add(risk bool, points int, reason string) {
+	if points <= 0 {
+		return
+	}
+	if risk {
+		s.risk += points
+	} else {
+		s.complexity += points
+	}
+	s.reasons = append(s.reasons, fmt.Sprintf("%s (+%d)", reason, points))
+}
+
+// total returns the rule score
+func (s score) total() int {
+	return s.risk + s.complexity
+}
+
+// scoredRule is a rule with its ruleset and score
+type scoredRule struct {
+	ruleSet illumioapi.RuleSet
+	rule    *illumioapi.Rule
+	ports   int
+	score   score
+}
+
+func ruleComplexity() {
+
+	// Load the PCE
+	provisionStatus := "active"
+	if draft {
+		provisionStatus = "draft"
+	}
+	apiResps, err := pce.Load(illumioapi.LoadInput{ProvisionStatus: provisionStatus, IPLists: true, Services: true, RuleSets: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Score the enabled rules of enabled rulesets. The ruleset map is keyed by href and name so only hrefs are used.
+	rules := []scoredRule{}
+	ruleSets := []illumioapi.RuleSet{}
+	for href, rs := range pce.RuleSets {
+		if href != rs.Href || rs.Enabled != nil && !*rs.Enabled {
+			continue
+		}
+		ruleSets = append(ruleSets, rs)
+		for _, r := range rs.Rules {
+			if r == nil || r.Enabled != nil && !*r.Enabled {
+				continue
+			}
+			rules = append(rules, scoreRule(rs, r))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].score.total() != rules[j].score.total() {
+			return rules[i].score.total() > rules[j].score.total()
+		}
+		return rules[i].rule.Href < rules[j].rule.Href
+	})
+
+	// Rule output
+	data := [][]string{{"ruleset", "ruleset_href", "rule_href", "description", "consumers", "providers", "services", "ports", "risk_score", "complexity_score", "score", "reasons"}}
+	stdOutData := [][]string{{"ruleset", "rule_href", "score", "reasons"}}
+	totals, max, counts := make(map[string]int), make(map[string]int), make(map[string]int)
+	for _, r := range rules {
+		s := r.score.total()
+		totals[r.ruleSet.Href] += s
+		counts[r.ruleSet.Href]++
+		if s > max[r.ruleSet.Href] {
+			max[r.ruleSet.Href] = s
+		}
+		if s < minScore {
+			continue
+		}
+		services := 0
+		if r.rule.IngressServices != nil {
+			services = len(*r.rule.IngressServices)
+		}
+		reasons := strings.Join(r.score.reasons, "; ")
+		data = append(data, []string{r.ruleSet.Name, r.ruleSet.Href, r.rule.Href, r.rule.Description, fmt.Sprintf("%d", len(r.rule.Consumers)), fmt.Sprintf("%d", len(r.rule.Providers)), fmt.Sprintf("%d", services), fmt.Sprintf("%d", r.ports), fmt.Sprintf("%d", r.score.risk), fmt.Sprintf("%d", r.score.complexity), fmt.Sprintf("%d", s), reasons})
+		stdOutData = append(stdOutData, []string{r.ruleSet.Name, r.rule.Href, fmt.Sprintf("%d", s), reasons})
+	}
+
+	// Ruleset output sorted by total score
+	sort.Slice(ruleSets, func(i, j int) bool {
+		if totals[ruleSets[i].Href] != totals[ruleSets[j].Href] {
+			return totals[ruleSets[i].Href] > totals[ruleSets[j].Href]
+		}
+		return ruleSets[i].Name < ruleSets[j].Name
+	})
+	rsData := [][]string{{"ruleset", "ruleset_href", "scopes", "rules", "total_score", "max_score", "average_score"}}
+	for _, rs := range ruleSets {
+		average := 0.0
+		if counts[rs.Href] > 0 {
+			average = float64(totals[rs.Href]) / float64(counts[rs.Href])
+		}
+		rsData = append(rsData, []string{rs.Name, rs.Href, fmt.Sprintf("%d", len(rs.Scopes)), fmt.Sprintf("%d", counts[rs.Href]), fmt.Sprintf("%d", totals[rs.Href]), fmt.Sprintf("%d", max[rs.Href]), fmt.Sprintf("%.1f", average)})
+	}
+
+	utils.LogInfo(fmt.Sprintf("scored %d rules in %d %s rulesets. %d rules have a score of at least %d.", len(rules), len(ruleSets), provisionStatus, len(data)-1, minScore), true)
+	if len(data) > 1 {
+		if outputFileName == "" {
+			outputFileName = fmt.Sprintf("workloader-rule-complexity-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(data, stdOutData, outputFileName)
+	}
+	if len(rsData) > 1 {
+		if rulesetFileName == "" {
+			rulesetFileName = fmt.Sprintf("workloader-rule-complexity-rulesets-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(rsData, rsData, rulesetFileName)
+	}
+}
+
+// scoreRule scores a rule on risk and complexity
+func scoreRule(rs illumioapi.RuleSet, r *illumioapi.Rule) scoredRule {
+	sr := scoredRule{ruleSet: rs, rule: r}
+	s := &sr.score
+	global := globalScope(rs)
+	unscoped := r.UnscopedConsumers != nil && *r.UnscopedConsumers
+
+	// Risk from broad consumers and providers
+	for _, c := range r.Consumers {
+		if c == nil {
+			continue
+		}
+		if c.IPList != nil && utils.AnyIP(pce.IPLists[c.IPList.Href]) {
+			s.add(true, 5, fmt.Sprintf("any ip consumer %s", pce.IPLists[c.IPList.Href].Name))
+		}
+		if c.Actors == "ams" {
+			if global || unscoped {
+				s.add(true, 3, "all workloads consumer across the pce")
+			} else {
+				s.add(true, 2, "all workloads consumer in the scope")
+			}
+		}
+	}
+	for _, p := range r.Providers {
+		if p != nil && p.Actors == "ams" {
+			if global {
+				s.add(true, 3, "all workloads provider across the pce")
+			} else {
+				s.add(true, 2, "all workloads provider in the scope")
+			}
+		}
+	}
+
+	// Risk from the scope breadth
+	if global {
+		s.add(true, 3, "ruleset has no scope")
+	} else {
+		broadest := -1
+		for _, scope := range rs.Scopes {
+			if broadest == -1 || len(scope) < broadest {
+				broadest = len(scope)
+			}
+		}
+		s.add(true, 3-broadest, fmt.Sprintf("scope with %d labels", broadest))
+	}
+	if unscoped {
+		s.add(true, 2, "extra-scope consumers")
+	}
+
+	// Risk from the service size
+	var all bool
+	sr.ports, all = servicePorts(r)
+	switch {
+	case all:
+		s.add(true, 5, "all services")
+	case sr.ports > 1000:
+		s.add(true, 4, fmt.Sprintf("%d ports", sr.ports))
+	case sr.ports > 100:
+		s.add(true, 3, fmt.Sprintf("%d ports", sr.ports))
+	case sr.ports > 10:
+		s.add(true, 2, fmt.Sprintf("%d ports", sr.ports))
+	case sr.ports > 1:
+		s.add(true, 1, fmt.Sprintf("%d ports", sr.ports))
+	}
+
+	// Complexity from the cardinality
+	s.add(false, len(r.Consumers)-1, fmt.Sprintf("%d consumers", len(r.Consumers)))
+	s.add(false, len(r.Providers)-1, fmt.Sprintf("%d providers", len(r.Providers)))
+	if r.IngressServices != nil {
+		s.add(false, len(*r.IngressServices)-1, fmt.Sprintf("%d services", len(*r.IngressServices)))
+	}
+	s.add(false, len(rs.Scopes)-1, fmt.Sprintf("%d scopes", len(rs.Scopes)))
+
+	return sr
+}
+
+// servicePorts returns the number of ports a rule allows and true if it allows all services
+func servicePorts(r *illumioapi.Rule) (int, bool) {
+	ports := 0
+	if r.IngressServices == nil {
+		return ports, false
+	}
+	for _, s := range *r.IngressServices {
+		if s == nil {
+			continue
+		}
+		if s.Href != nil {
+			svc := pce.Services[*s.Href]
+			for _, sp := range svc.ServicePorts {
+				if sp == nil {
+					continue
+				}
+				if sp.Protocol == -1 {
+					return allPorts, true
+				}
+				ports += portCount(sp.Port, sp.ToPort, sp.Protocol)
+			}
+			for _, ws := range svc.WindowsServices {
+				if ws != nil {
+					ports += portCount(ws.Port, ws.ToPort, ws.Protocol)
+				}
+			}
+			continue
+		}
+		port, toPort, proto := 0, 0, 0
+		if s.Port != nil {
+			port = *s.Port
+		}
+		if s.ToPort != nil {
+			toPort = *s.ToPort
+		}
+		if s.Protocol != nil {
+			proto = *s.Protocol
+		}
+		if proto == -1 {
+			return allPorts, true
+		}
+		ports += portCount(port, toPort, proto)
+	}
+	return ports, false
+}
+
+// portCount returns the number of ports in a port range. A tcp or udp service without a port is all ports.
+func portCount(port, toPort, proto int) int {
+	if port == 0 && (proto == 6 || proto == 17) {
+		return allPorts
+	}
+	if toPort > port {
+		return toPort - port + 1
+	}
+	return 1
+}
+
+// globalScope returns true if the ruleset applies to all workloads. A ruleset without scopes or with an empty scope is global.
+func globalScope(rs illumioapi.RuleSet) bool {
+	if len(rs.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range rs.Scopes {
+		if len(scope) == 0 {
+			return true
+		}
+	}
+	return false
+}
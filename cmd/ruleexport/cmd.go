@@ -553,7 +553,11 @@ func ExportRules(input Input) {
 	if skippedRules > 0 {
 		utils.LogWarning(fmt.Sprintf("%d rules skipped because could not create valid traffic query", skippedRules), true)
 	}
-	utils.LogInfo(fmt.Sprintf("output file: %s", input.OutputFileName), true)
+	if utils.IsRemoteOutput(input.OutputFileName) {
+		utils.FinishLineOutput(input.OutputFileName)
+	} else {
+		utils.LogInfo(fmt.Sprintf("output file: %s", input.OutputFileName), true)
+	}
 	utils.LogEndCommand("rule-export")
 
 }
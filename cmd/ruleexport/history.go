@@ -0,0 +1,115 @@
+package ruleexport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// historyEntry is the hit count of a rule from one completed traffic query
+type historyEntry struct {
+	Time            time.Time `json:"time"`
+	AsyncQueryHref  string    `json:"async_query_href"`
+	RuleHref        string    `json:"rule_href"`
+	RulesetName     string    `json:"ruleset_name"`
+	RuleDescription string    `json:"rule_description"`
+	Flows           int       `json:"flows"`
+}
+
+// historyFile returns the rule usage history file of a PCE. The history directory defaults to ~/.workloader/rule-usage.
+func historyFile(dir, pceName string) string {
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			dir = "workloader-rule-usage"
+		} else {
+			dir = filepath.Join(home, ".workloader", "rule-usage")
+		}
+	}
+	return filepath.Join(dir, pceName+".jsonl")
+}
+
+// readHistory reads the rule usage history. A history file that does not exist has no entries.
+func readHistory(file string) ([]historyEntry, error) {
+	entries := []historyEntry{}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening rule usage history - %s", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("reading rule usage history %s - %s", file, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// appendHistory adds the completed traffic queries to the history. Queries already in the history are skipped so running rule-usage on the
+// same export more than once does not count a query twice.
+func appendHistory(file string, entries []historyEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	existing, err := readHistory(file)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("%s. rule usage is not being recorded.", err), true)
+		return
+	}
+	recorded := make(map[string]bool)
+	for _, e := range existing {
+		recorded[e.AsyncQueryHref] = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		utils.LogWarning(fmt.Sprintf("creating rule usage history directory - %s. rule usage is not being recorded.", err), true)
+		return
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("opening rule usage history - %s. rule usage is not being recorded.", err), true)
+		return
+	}
+	defer f.Close()
+	added := 0
+	for _, e := range entries {
+		if recorded[e.AsyncQueryHref] {
+			continue
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		f.Write(append(line, '\n'))
+		recorded[e.AsyncQueryHref] = true
+		added++
+	}
+	utils.LogInfo(fmt.Sprintf("%d rule hit counts added to history %s", added, file), true)
+}
+
+// historyTime returns the time a traffic query was created or now if the time is not valid
+func historyTime(createdAt string) time.Time {
+	if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		return t.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// flowsInt converts the flows column to an int
+func flowsInt(flows string) int {
+	i, _ := strconv.Atoi(flows)
+	return i
+}
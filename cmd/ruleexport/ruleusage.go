@@ -2,7 +2,6 @@ package ruleexport
 
 import (
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,10 +13,22 @@ import (
 )
 
 var pce illumioapi.PCE
+var hec utils.HECConfig
 var inputFile, outputFileName string
+var watch time.Duration
+var historyDir string
+var noHistory bool
 
 func init() {
 	RuleUsageCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	RuleUsageCmd.Flags().StringVar(&hec.URL, "splunk-hec-url", "", "splunk http event collector url (e.g., https://splunk.company.com:8088) to send each output row to as an event.")
+	RuleUsageCmd.Flags().StringVar(&hec.Token, "splunk-hec-token", "", "splunk http event collector token. default is the SPLUNK_HEC_TOKEN environment variable.")
+	RuleUsageCmd.Flags().StringVar(&hec.SourceType, "splunk-sourcetype", "workloader:rule-usage", "sourcetype for splunk events.")
+	RuleUsageCmd.Flags().StringVar(&hec.Index, "splunk-index", "", "splunk index for events. default is the index of the token.")
+	RuleUsageCmd.Flags().BoolVar(&hec.Insecure, "splunk-insecure", false, "do not validate the splunk certificate.")
+	RuleUsageCmd.Flags().DurationVar(&watch, "watch", 0, "check the traffic queries on this interval (e.g., 10m) until stopped and write only the rules that changed since the previous check.")
+	RuleUsageCmd.Flags().StringVar(&historyDir, "history-dir", "", "directory of the rule usage history. default is ~/.workloader/rule-usage.")
+	RuleUsageCmd.Flags().BoolVar(&noHistory, "no-history", false, "do not add the completed traffic queries to the rule usage history.")
 }
 
 var RuleUsageCmd = &cobra.Command{
@@ -30,6 +41,9 @@ Run workloader rule-export with the --traffic-count flags and any necessary traf
 The output will have all the rules with an async query href.
 Within 24 hours, pass the output file of rule-export into this rule-usage command to get the results of the traffic queries.
 Run as many times as needed until all traffic queries have been processed. 
+Use --watch to check on an interval instead. After the first check, only the rules with newly completed or expired queries are written.
+
+The hit count of each completed query is added to a history file for the PCE in ~/.workloader/rule-usage (or --history-dir). Use rule-usage-trend to report hit counts over time and rules with no hits in consecutive runs. Use --no-history to not record the hit counts.
 
 The update-pce and --no-prompt flags are ignored for this command.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -42,24 +56,42 @@ The update-pce and --no-prompt flags are ignored for this command.`,
 		// Get the input file
 		if len(args) != 1 {
 			fmt.Println("command requires 1 argument for the csv file. see usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		inputFile = args[0]
 
-		retrieveTraffic()
+		// parse the input csv
+		csvData, err := utils.ParseCSV(inputFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		// Each check uses the results of the previous check so completed queries are not downloaded again
+		if watch > 0 {
+			utils.Watch("rule-usage", watch, []string{HeaderRuleHref, "async_query_href"}, func(write bool) [][]string {
+				csvData = retrieveTraffic(csvData, write)
+				return csvData
+			})
+		} else {
+			retrieveTraffic(csvData, true)
+		}
 	},
 }
 
-func retrieveTraffic() {
-	// parse the input csv
-	csvData, err := utils.ParseCSV(inputFile)
-	if err != nil {
-		utils.LogError(err.Error())
-	}
-
+// retrieveTraffic adds the results of completed traffic queries to the rule-export data and returns it. The output file is written when write is true.
+func retrieveTraffic(csvData [][]string, write bool) [][]string {
 	// Find the async_query_href and the status header
 	var asyncHrefCol, asyncQueryStatusCol, flowsCol, flowsByPortCol int
+	ruleHrefCol, rulesetNameCol, ruleDescriptionCol := -1, -1, -1
 	for i, col := range csvData[0] {
+		switch col {
+		case HeaderRuleHref:
+			ruleHrefCol = i
+		case HeaderRulesetName:
+			rulesetNameCol = i
+		case HeaderRuleDescription:
+			ruleDescriptionCol = i
+		}
 		if col == "async_query_href" {
 			asyncHrefCol = i
 		}
@@ -91,10 +123,11 @@ func retrieveTraffic() {
 
 	// Iterate through the csv and check for reesults
 	newCsvData := [][]string{}
+	history := []historyEntry{}
 	var numStillPending, numAlreadyCompleted, numNewlyCompleted, numExpired int
 	for i, row := range csvData {
 		// Create thew new CSV data
-		newCsvData = append(newCsvData, row)
+		newCsvData = append(newCsvData, append([]string{}, row...))
 		// Skip the first row
 		if i == 0 {
 			continue
@@ -113,6 +146,7 @@ func retrieveTraffic() {
 		var exists bool
 		if aq, exists = asyncHrefMap[row[asyncHrefCol]]; !exists {
 			utils.LogWarning(fmt.Sprintf("csv row %d - %s does not exist as an async query. invalid href or it expired.", i+1, row[asyncHrefCol]), true)
+			newCsvData[len(newCsvData)-1][asyncQueryStatusCol] = "expired"
 			numExpired++
 			continue
 		}
@@ -130,6 +164,16 @@ func retrieveTraffic() {
 		// Edit the csv
 		newCsvData[len(newCsvData)-1][flowsCol], newCsvData[len(newCsvData)-1][flowsByPortCol] = processFlows(traffic)
 		newCsvData[len(newCsvData)-1][asyncQueryStatusCol] = "completed"
+		if ruleHrefCol != -1 {
+			e := historyEntry{Time: historyTime(aq.CreatedAt), AsyncQueryHref: aq.Href, RuleHref: row[ruleHrefCol], Flows: flowsInt(newCsvData[len(newCsvData)-1][flowsCol])}
+			if rulesetNameCol != -1 {
+				e.RulesetName = row[rulesetNameCol]
+			}
+			if ruleDescriptionCol != -1 {
+				e.RuleDescription = row[ruleDescriptionCol]
+			}
+			history = append(history, e)
+		}
 		utils.LogInfo(fmt.Sprintf("csv row %d - %s completed and downloaded", i+1, aq.Href), true)
 		numNewlyCompleted++
 
@@ -146,7 +190,21 @@ func retrieveTraffic() {
 	utils.LogInfo(fmt.Sprintf("%d rule traffic queries completed on this run.", numNewlyCompleted), true)
 	utils.LogInfo(fmt.Sprintf("%d rule traffic queries expired (see warnings).", numExpired), true)
 	utils.LogInfo(fmt.Sprintf("%d rule traffic queries still pending.", numStillPending), true)
+
+	// Record the completed queries. In watch mode, each check records the queries it completed.
+	if !noHistory {
+		appendHistory(historyFile(historyDir, pce.FriendlyName), history)
+	}
+	if !write {
+		return newCsvData
+	}
 	utils.WriteOutput(newCsvData, [][]string{}, outputFileName)
+	if hec.URL != "" {
+		if err := utils.SendHEC(hec, "rule-usage", newCsvData); err != nil {
+			utils.LogWarning(fmt.Sprintf("sending to splunk hec - %s", err), true)
+		}
+	}
+	return newCsvData
 }
 
 func processFlows(traffic []illumioapi.TrafficAnalysis) (flowCount, flowCountByPort string) {
@@ -0,0 +1,136 @@
+package ruleexport
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var zeroRuns int
+var since string
+
+func init() {
+	RuleUsageTrendCmd.Flags().IntVar(&zeroRuns, "zero-runs", 3, "number of consecutive runs with no hits for a rule to be a deletion candidate.")
+	RuleUsageTrendCmd.Flags().StringVar(&since, "since", "", "only include runs on or after this date (yyyy-mm-dd).")
+	RuleUsageTrendCmd.Flags().StringVar(&historyDir, "history-dir", "", "directory of the rule usage history. default is ~/.workloader/rule-usage.")
+	RuleUsageTrendCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	RuleUsageTrendCmd.Flags().SortFlags = false
+}
+
+// RuleUsageTrendCmd reports rule hit counts over time from the rule-usage history
+var RuleUsageTrendCmd = &cobra.Command{
+	Use:   "rule-usage-trend",
+	Short: "Report rule hit counts over time from the rule-usage history.",
+	Long: `
+Report rule hit counts over time from the rule-usage history.
+
+Each time rule-usage downloads a completed traffic query, the hit count of the rule is added to a history file for the PCE in ~/.workloader/rule-usage (or --history-dir). Each query is a run for that rule and is dated when rule-export created it. Run rule-export with --traffic-count and rule-usage on a schedule to build the history.
+
+The output has a row for each rule in the history with the number of runs, the hit count of each run, the total and latest hit counts, the date of the last run with hits, and the number of consecutive runs with no hits up to the latest run. Rules with no hits in the last --zero-runs runs (default 3) are deletion candidates.
+
+Rules that were deleted from the PCE stay in the history. Use --since to ignore older runs.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report rule hit trends for the default pce
+  workloader rule-usage-trend
+
+  # Flag rules with no hits in the last 6 runs since the start of the year
+  workloader rule-usage-trend --zero-runs 6 --since 2026-01-01`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Get the PCE for the history file name. The PCE api is not used.
+		pce, err = utils.GetTargetPCE(false)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		if zeroRuns < 1 {
+			utils.LogErrorCode(utils.ExitValidation, "--zero-runs must be at least 1")
+		}
+		var sinceTime time.Time
+		if since != "" {
+			if sinceTime, err = time.Parse("2006-01-02", since); err != nil {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("--since must be yyyy-mm-dd - %s", err))
+			}
+		}
+
+		utils.LogStartCommand("rule-usage-trend")
+		ruleUsageTrend(sinceTime)
+		utils.LogEndCommand("rule-usage-trend")
+	},
+}
+
+// ruleTrend is the history of one rule sorted by time
+type ruleTrend struct {
+	ruleHref string
+	entries  []historyEntry
+}
+
+func ruleUsageTrend(sinceTime time.Time) {
+	file := historyFile(historyDir, pce.FriendlyName)
+	history, err := readHistory(file)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Group the history by rule
+	trends := make(map[string]*ruleTrend)
+	for _, e := range history {
+		if e.Time.Before(sinceTime) {
+			continue
+		}
+		if trends[e.RuleHref] == nil {
+			trends[e.RuleHref] = &ruleTrend{ruleHref: e.RuleHref}
+		}
+		trends[e.RuleHref].entries = append(trends[e.RuleHref].entries, e)
+	}
+	if len(trends) == 0 {
+		utils.LogInfo(fmt.Sprintf("no rule usage history in %s. run rule-export with --traffic-count and rule-usage to build the history.", file), true)
+		return
+	}
+	ruleHrefs := []string{}
+	for href, t := range trends {
+		ruleHrefs = append(ruleHrefs, href)
+		sort.SliceStable(t.entries, func(i, j int) bool { return t.entries[i].Time.Before(t.entries[j].Time) })
+	}
+	sort.Strings(ruleHrefs)
+
+	// Build the output
+	csvData := [][]string{{HeaderRulesetName, HeaderRuleDescription, HeaderRuleHref, "runs", "first_run", "last_run", "total_flows", "last_flows", "flows_by_run", "last_hit", "consecutive_zero_runs", "deletion_candidate"}}
+	stdOutData := [][]string{{HeaderRulesetName, HeaderRuleDescription, "runs", "last_flows", "last_hit", "consecutive_zero_runs", "deletion_candidate"}}
+	candidates := 0
+	for _, href := range ruleHrefs {
+		t := trends[href]
+		first, last := t.entries[0], t.entries[len(t.entries)-1]
+		total, zeros := 0, 0
+		lastHit := "never"
+		byRun := []string{}
+		for _, e := range t.entries {
+			total += e.Flows
+			byRun = append(byRun, fmt.Sprintf("%s (%d)", e.Time.Format("2006-01-02"), e.Flows))
+			if e.Flows > 0 {
+				lastHit = e.Time.Format("2006-01-02")
+				zeros = 0
+			} else {
+				zeros++
+			}
+		}
+		candidate := zeros >= zeroRuns
+		if candidate {
+			candidates++
+		}
+		csvData = append(csvData, []string{last.RulesetName, last.RuleDescription, href, strconv.Itoa(len(t.entries)), first.Time.Format("2006-01-02"), last.Time.Format("2006-01-02"),
+			strconv.Itoa(total), strconv.Itoa(last.Flows), strings.Join(byRun, "; "), lastHit, strconv.Itoa(zeros), strconv.FormatBool(candidate)})
+		stdOutData = append(stdOutData, []string{last.RulesetName, last.RuleDescription, strconv.Itoa(len(t.entries)), strconv.Itoa(last.Flows), lastHit, strconv.Itoa(zeros), strconv.FormatBool(candidate)})
+	}
+
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-rule-usage-trend-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(csvData, stdOutData, outputFileName)
+	utils.LogInfo(fmt.Sprintf("%d rules in history. %d rules with no hits in the last %d runs are deletion candidates.", len(ruleHrefs), candidates, zeroRuns), true)
+}
@@ -6,7 +6,6 @@ package ruleimport
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -38,8 +37,9 @@ func init() {
 
 // RuleImportCmd runs the upload command
 var RuleImportCmd = &cobra.Command{
-	Use:   "rule-import [csv file to import]",
-	Short: "Create and update rules from a CSV file.",
+	Use:         "rule-import [csv file to import]",
+	Short:       "Create and update rules from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapRulesetWrite},
 	Long: `
 Create and update rules in the PCE from a CSV file.
 
@@ -89,7 +89,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		globalInput.ImportFile = args[0]
 
@@ -767,7 +767,7 @@ CSVEntries:
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if input.UpdatePCE && !input.NoPrompt {
 		var prompt string
-		fmt.Printf("\r\n[PROMPT] - workloader identified %d rules to create and %d rules to update in %s (%s). Do you want to run the import (yes/no)? ", len(newRules), len(updatedRules), input.PCE.FriendlyName, viper.Get(input.PCE.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n[PROMPT] - workloader identified %d rules to create and %d rules to update in %s (%s). Do you want to run the import (yes/no)? ", len(newRules), len(updatedRules), input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied.", true)
@@ -2,7 +2,6 @@ package rulesetimport
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -29,8 +28,9 @@ func init() {
 
 // RuleSetImportCmd runs the import command
 var RuleSetImportCmd = &cobra.Command{
-	Use:   "ruleset-import [csv file to import]",
-	Short: "Create rulesets from a CSV file.",
+	Use:         "ruleset-import [csv file to import]",
+	Short:       "Create rulesets from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapRulesetWrite},
 	Long: `
 Create or update rulesets in the PCE from a CSV file.
 
@@ -65,7 +65,7 @@ Recommended to run without --update-pce first to log what will change.`,
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("command requires 1 argument for the csv file. see usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		input.ImportFile = args[0]
 
@@ -258,7 +258,7 @@ csvEntries:
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if input.UpdatePCE && !input.NoPrompt {
 		var prompt string
-		fmt.Printf("\r\n[PROMPT] - workloader identified %d rulesets to create and %d rulesets to update in %s (%s). Do you want to run the import (yes/no)? ", len(newRuleSets), len(updateRuleSets), input.PCE.FriendlyName, viper.Get(input.PCE.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n[PROMPT] - workloader identified %d rulesets to create and %d rulesets to update in %s (%s). Do you want to run the import (yes/no)? ", len(newRuleSets), len(updateRuleSets), input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied.", true)
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the supported shortcuts for common schedules
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is the range and names of a cron field
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bit set of the allowed values.
+type cronSchedule struct {
+	fields [5]uint64
+	// dom and dow are restricted (do not start with *). When both are restricted, either can match like standard cron.
+	domRestricted, dowRestricted bool
+}
+
+// parseCron parses a standard 5 field cron expression (minute hour day-of-month month day-of-week) or a macro such as @daily.
+// Fields support *, lists, ranges, steps, and month and day names.
+func parseCron(expr string) (cronSchedule, error) {
+	var c cronSchedule
+	expr = strings.TrimSpace(strings.ToLower(expr))
+	if m, ok := cronMacros[expr]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return c, fmt.Errorf("%q must have 5 fields (minute hour day-of-month month day-of-week) or be a macro such as @daily", expr)
+	}
+	for i, p := range parts {
+		bits, err := parseCronField(p, cronFields[i])
+		if err != nil {
+			return c, err
+		}
+		c.fields[i] = bits
+	}
+	// Sunday can be 0 or 7
+	if c.fields[4]&(1<<7) != 0 {
+		c.fields[4] |= 1
+	}
+	c.domRestricted, c.dowRestricted = !strings.HasPrefix(parts[2], "*"), !strings.HasPrefix(parts[4], "*")
+	return c, nil
+}
+
+// parseCronField returns the bit set of the values in a field
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", part[i+1:], f.name)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], f); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue returns the value of a number or name in a field
+func cronValue(s string, f cronField) (int, error) {
+	for i, n := range f.names {
+		if s == n {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field. must be %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// dayMatches returns true if the schedule runs on the day of t
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.fields[2]&(1<<uint(t.Day())) != 0, c.fields[4]&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// matches returns true if the schedule runs in the minute of t
+func (c cronSchedule) matches(t time.Time) bool {
+	return c.fields[0]&(1<<uint(t.Minute())) != 0 && c.fields[1]&(1<<uint(t.Hour())) != 0 && c.fields[3]&(1<<uint(t.Month())) != 0 && c.dayMatches(t)
+}
+
+// next returns the next minute after t the schedule runs. The zero time is returned if there is no run in the next 5 years (e.g., February 30).
+func (c cronSchedule) next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case c.fields[3]&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.fields[1]&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.fields[0]&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,352 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Declare local global variables
+var historyDir, runJobName string
+var check, history bool
+
+func init() {
+	SchedulerCmd.Flags().StringVar(&historyDir, "history-dir", "", "directory for job history and logs. default is history_dir in the schedule file and then ~/.workloader/scheduler.")
+	SchedulerCmd.Flags().BoolVar(&check, "check", false, "validate the schedule file and log the next run of each job without running them.")
+	SchedulerCmd.Flags().StringVar(&runJobName, "run", "", "run the named job once now and exit.")
+	SchedulerCmd.Flags().BoolVar(&history, "history", false, "write the run history to an output file and exit.")
+	SchedulerCmd.Flags().SortFlags = false
+}
+
+// SchedulerCmd runs the scheduler command
+var SchedulerCmd = &cobra.Command{
+	Use:   "scheduler [schedule yaml file]",
+	Short: "Run workloader commands on cron schedules from a yaml file.",
+	Long: `
+Run workloader commands on cron schedules from a yaml file.
+
+The schedule file has a list of jobs. Each job has a name, a cron schedule, a workloader command, and optional settings:
+
+history_dir: /var/lib/workloader   # optional. default is ~/.workloader/scheduler.
+keep: 30                           # optional. runs to keep for each job. default is 30.
+jobs:
+  - name: nightly-wkld-export
+    schedule: "0 2 * * *"          # minute hour day-of-month month day-of-week or @hourly, @daily, @weekly, @monthly, @yearly
+    command: wkld-export
+    args: ["--managed-only"]
+    pce: prod                      # optional. default is the default pce.
+    update_pce: false              # optional. runs with --update-pce --no-prompt.
+    notify: true                   # optional. runs with --notify.
+    email_to: team@example.com     # optional. runs with --email-to.
+    timeout: 2h                    # optional. the run is stopped after the timeout.
+    keep: 60                       # optional. overrides the top-level keep.
+
+Schedules use the local time of the server. A job does not start while its previous run is still running. The skipped run is recorded in the history.
+
+Each run is a separate workloader process in <history_dir>/<job name>/<start time>. The run directory has the output files, workloader.log, and output.txt with the command output. Each run is added to <history_dir>/history.jsonl. Older run directories are removed after the number of runs to keep.
+
+The command runs until stopped. Use --check to validate the file, --run to run one job now, and --history to export the run history.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Validate user input
+		if len(args) != 1 {
+			fmt.Println("command requires 1 argument for the schedule yaml file. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+
+		s, err := loadSchedule(args[0], cmd.Root())
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		if historyDir != "" {
+			s.HistoryDir = historyDir
+		}
+
+		switch {
+		case history:
+			exportHistory(s)
+		case check:
+			checkSchedule(s)
+		case runJobName != "":
+			runNow(s, runJobName)
+		default:
+			runScheduler(s)
+		}
+	},
+}
+
+// schedule is the schedule yaml file
+type schedule struct {
+	HistoryDir string `yaml:"history_dir"`
+	Keep       int    `yaml:"keep"`
+	Jobs       []*job `yaml:"jobs"`
+	history    sync.Mutex
+}
+
+// job is a scheduled workloader command
+type job struct {
+	Name      string   `yaml:"name"`
+	Schedule  string   `yaml:"schedule"`
+	Command   string   `yaml:"command"`
+	Args      []string `yaml:"args"`
+	PCE       string   `yaml:"pce"`
+	UpdatePCE bool     `yaml:"update_pce"`
+	Notify    bool     `yaml:"notify"`
+	EmailTo   string   `yaml:"email_to"`
+	Timeout   string   `yaml:"timeout"`
+	Keep      int      `yaml:"keep"`
+	cron      cronSchedule
+	timeout   time.Duration
+	running   bool
+	mu        sync.Mutex
+}
+
+// historyEntry is a run in history.jsonl
+type historyEntry struct {
+	Job      string `json:"job"`
+	Trigger  string `json:"trigger"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Duration string `json:"duration"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	RunDir   string `json:"run_dir,omitempty"`
+}
+
+// loadSchedule reads and validates the schedule file
+func loadSchedule(file string, root *cobra.Command) (*schedule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &schedule{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("reading %s - %s", file, err)
+	}
+	if s.Keep <= 0 {
+		s.Keep = 30
+	}
+	if s.HistoryDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		s.HistoryDir = filepath.Join(home, ".workloader", "scheduler")
+	}
+	if len(s.Jobs) == 0 {
+		return nil, fmt.Errorf("%s has no jobs", file)
+	}
+	names := make(map[string]bool)
+	for _, j := range s.Jobs {
+		if j.Name == "" || strings.ContainsAny(j.Name, `/\`) || j.Name == "." || j.Name == ".." {
+			return nil, fmt.Errorf("job name %q must not be blank or have slashes", j.Name)
+		}
+		if names[j.Name] {
+			return nil, fmt.Errorf("job name %s is used more than once", j.Name)
+		}
+		names[j.Name] = true
+		if j.cron, err = parseCron(j.Schedule); err != nil {
+			return nil, fmt.Errorf("%s - schedule %s", j.Name, err)
+		}
+		if c, _, err := root.Find([]string{j.Command}); err != nil || c == root || c.Name() != j.Command || j.Command == "scheduler" {
+			return nil, fmt.Errorf("%s - %q is not a workloader command", j.Name, j.Command)
+		}
+		if j.Timeout != "" {
+			if j.timeout, err = time.ParseDuration(j.Timeout); err != nil {
+				return nil, fmt.Errorf("%s - timeout %s", j.Name, err)
+			}
+		}
+		if j.Keep <= 0 {
+			j.Keep = s.Keep
+		}
+	}
+	return s, nil
+}
+
+// args returns the workloader arguments for a job
+func (j *job) args() []string {
+	args := append([]string{j.Command}, j.Args...)
+	if j.PCE != "" {
+		args = append(args, "--pce", j.PCE)
+	}
+	if j.UpdatePCE {
+		args = append(args, "--update-pce", "--no-prompt")
+	}
+	if j.Notify {
+		args = append(args, "--notify")
+	}
+	if j.EmailTo != "" {
+		args = append(args, "--email-to", j.EmailTo)
+	}
+	return args
+}
+
+// checkSchedule logs the next run of each job
+func checkSchedule(s *schedule) {
+	now := time.Now()
+	for _, j := range s.Jobs {
+		next := "never"
+		if n := j.cron.next(now); !n.IsZero() {
+			next = n.Format("2006-01-02 15:04")
+		}
+		utils.LogInfo(fmt.Sprintf("%s - next run %s - workloader %s", j.Name, next, strings.Join(j.args(), " ")), true)
+	}
+	utils.LogInfo(fmt.Sprintf("%d jobs are valid", len(s.Jobs)), true)
+}
+
+// runNow runs one job and exits
+func runNow(s *schedule, name string) {
+	for _, j := range s.Jobs {
+		if j.Name == name {
+			e := s.run(j, "manual")
+			if e.Status == "partial_failure" {
+				utils.RecordFailure(fmt.Sprintf("%s - %s", j.Name, e.Error))
+			} else if e.Status != "success" {
+				utils.LogError(fmt.Sprintf("%s - %s - %s", j.Name, e.Status, e.Error))
+			}
+			return
+		}
+	}
+	utils.LogError(fmt.Sprintf("%s is not a job in the schedule file", name))
+}
+
+// runScheduler runs the jobs on their schedules until stopped
+func runScheduler(s *schedule) {
+
+	utils.LogStartCommand("scheduler")
+	checkSchedule(s)
+
+	for {
+		now := time.Now()
+		minute := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute()+1, 0, 0, now.Location())
+		time.Sleep(time.Until(minute))
+		for _, j := range s.Jobs {
+			if j.cron.matches(minute) {
+				go s.run(j, "schedule")
+			}
+		}
+	}
+}
+
+// run runs a job unless its previous run is still running and records the run in the history
+func (s *schedule) run(j *job, trigger string) historyEntry {
+	start := time.Now()
+	e := historyEntry{Job: j.Name, Trigger: trigger, Start: start.Format(time.RFC3339)}
+
+	// Overlapping-run protection
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		e.Status, e.End, e.Duration = "skipped", start.Format(time.RFC3339), "0s"
+		e.Error = "previous run is still running"
+		utils.LogWarning(fmt.Sprintf("%s - skipping run because the previous run is still running", j.Name), true)
+		s.record(e)
+		return e
+	}
+	j.running = true
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		j.running = false
+		j.mu.Unlock()
+	}()
+
+	e.RunDir = filepath.Join(s.HistoryDir, j.Name, start.Format("20060102_150405"))
+	utils.LogInfo(fmt.Sprintf("%s - starting workloader %s", j.Name, strings.Join(j.args(), " ")), true)
+	err := os.MkdirAll(e.RunDir, 0700)
+	if err == nil {
+		ctx, cancel := context.Background(), func() {}
+		if j.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		}
+		var output []byte
+		output, err = utils.RunWorkloader(ctx, e.RunDir, map[string]interface{}{"output_dir": e.RunDir}, j.args()...)
+		cancel()
+		os.WriteFile(filepath.Join(e.RunDir, "output.txt"), output, 0600)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("stopped after timeout of %s", j.timeout)
+		}
+	}
+
+	e.End, e.Duration, e.Status = time.Now().Format(time.RFC3339), time.Since(start).Round(time.Second).String(), "success"
+	if utils.PartialFailure(err) {
+		e.Status, e.Error = "partial_failure", err.Error()
+		utils.LogWarning(fmt.Sprintf("%s - completed with failures in %s. see %s", j.Name, e.Duration, e.RunDir), true)
+	} else if err != nil {
+		e.Status, e.Error = "failed", err.Error()
+		utils.LogWarning(fmt.Sprintf("%s - failed after %s - %s. see %s", j.Name, e.Duration, err, e.RunDir), true)
+	} else {
+		utils.LogInfo(fmt.Sprintf("%s - completed in %s", j.Name, e.Duration), true)
+	}
+	s.record(e)
+	s.prune(j)
+	return e
+}
+
+// record adds a run to history.jsonl
+func (s *schedule) record(e historyEntry) {
+	s.history.Lock()
+	defer s.history.Unlock()
+	if err := os.MkdirAll(s.HistoryDir, 0700); err != nil {
+		utils.LogWarning(fmt.Sprintf("recording history - %s", err), true)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(s.HistoryDir, "history.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("recording history - %s", err), true)
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(e)
+	f.Write(append(line, '\n'))
+}
+
+// prune removes the oldest run directories of a job after the number to keep
+func (s *schedule) prune(j *job) {
+	entries, err := os.ReadDir(filepath.Join(s.HistoryDir, j.Name))
+	if err != nil {
+		return
+	}
+	runs := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			runs = append(runs, e.Name())
+		}
+	}
+	sort.Strings(runs)
+	for i := 0; i < len(runs)-j.Keep; i++ {
+		if err := os.RemoveAll(filepath.Join(s.HistoryDir, j.Name, runs[i])); err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - removing old run %s - %s", j.Name, runs[i], err), false)
+		}
+	}
+}
+
+// exportHistory writes the run history to an output file
+func exportHistory(s *schedule) {
+	f, err := os.Open(filepath.Join(s.HistoryDir, "history.jsonl"))
+	if err != nil {
+		utils.LogError(fmt.Sprintf("reading history - %s", err))
+	}
+	defer f.Close()
+	data := [][]string{{"job", "trigger", "start", "end", "duration", "status", "error", "run_dir"}}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		data = append(data, []string{e.Job, e.Trigger, e.Start, e.End, e.Duration, e.Status, e.Error, e.RunDir})
+	}
+	utils.WriteOutput(data, data, fmt.Sprintf("workloader-scheduler-history-%s.csv", time.Now().Format("20060102_150405")))
+}
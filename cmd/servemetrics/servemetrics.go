@@ -0,0 +1,248 @@
+package servemetrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Declare local global variables
+var listen, pceList string
+var interval time.Duration
+var allPCEs bool
+
+func init() {
+	ServeMetricsCmd.Flags().StringVar(&listen, "listen", ":9120", "address to serve the /metrics endpoint on.")
+	ServeMetricsCmd.Flags().DurationVar(&interval, "interval", 5*time.Minute, "how often to poll the pces.")
+	ServeMetricsCmd.Flags().StringVar(&pceList, "pces", "", "comma-separated list of pce names from pce.yaml to poll. default is the target or default pce.")
+	ServeMetricsCmd.Flags().BoolVar(&allPCEs, "all-pces", false, "poll all pces in pce.yaml.")
+	ServeMetricsCmd.Flags().SortFlags = false
+}
+
+// ServeMetricsCmd runs the serve-metrics command
+var ServeMetricsCmd = &cobra.Command{
+	Use:   "serve-metrics",
+	Short: "Poll PCEs and serve metrics on a /metrics endpoint for Prometheus.",
+	Long: `
+Poll PCEs and serve metrics on a /metrics endpoint for Prometheus.
+
+Each pce is polled on the interval. The following metrics are labeled with the pce name:
+- workloader_pce_up: 1 if the last poll succeeded
+- workloader_workloads: workloads by managed state and enforcement mode
+- workloader_workloads_offline: managed workloads that are offline
+- workloader_draft_changes_pending: pending draft objects by object type
+- workloader_rulesets and workloader_rules: active rulesets and rules
+- workloader_api_latency_seconds: latency of each api call in the last poll
+- workloader_poll_duration_seconds and workloader_poll_timestamp_seconds
+
+The command runs until stopped. The update-pce and --no-prompt flags are ignored for this command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		names := []string{}
+		if allPCEs {
+			for k := range viper.AllSettings() {
+				if viper.Get(k+".fqdn") != nil {
+					names = append(names, k)
+				}
+			}
+		} else if pceList != "" {
+			names = strings.Split(strings.ReplaceAll(pceList, " ", ""), ",")
+		}
+
+		pces := []illumioapi.PCE{}
+		if len(names) == 0 {
+			pce, err := utils.GetTargetPCE(false)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			pces = append(pces, pce)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			pce, err := utils.GetPCEbyName(n, false)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			pces = append(pces, pce)
+		}
+
+		serveMetrics(pces)
+	},
+}
+
+// metric is the help and type of a metric
+type metric struct {
+	name  string
+	help  string
+	mType string
+}
+
+// metrics are rendered in this order
+var metrics = []metric{
+	{"workloader_pce_up", "1 if the last poll of the pce succeeded.", "gauge"},
+	{"workloader_workloads", "Workloads by managed state and enforcement mode.", "gauge"},
+	{"workloader_workloads_offline", "Managed workloads that are offline.", "gauge"},
+	{"workloader_draft_changes_pending", "Pending draft policy objects by object type.", "gauge"},
+	{"workloader_rulesets", "Active rulesets.", "gauge"},
+	{"workloader_rules", "Active rules.", "gauge"},
+	{"workloader_api_latency_seconds", "Latency of each api call in the last poll.", "gauge"},
+	{"workloader_poll_duration_seconds", "Duration of the last poll.", "gauge"},
+	{"workloader_poll_timestamp_seconds", "Unix time of the last poll.", "gauge"},
+}
+
+// samples holds the metric lines for each pce
+var samples = struct {
+	sync.RWMutex
+	pce map[string]map[string][]string
+}{pce: make(map[string]map[string][]string)}
+
+func serveMetrics(pces []illumioapi.PCE) {
+
+	utils.LogStartCommand("serve-metrics")
+
+	// Poll each pce on the interval
+	for _, p := range pces {
+		go func(pce illumioapi.PCE) {
+			for {
+				s := poll(pce)
+				samples.Lock()
+				samples.pce[pce.FriendlyName] = s
+				samples.Unlock()
+				time.Sleep(interval)
+			}
+		}(p)
+	}
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		samples.RLock()
+		defer samples.RUnlock()
+		pceNames := []string{}
+		for n := range samples.pce {
+			pceNames = append(pceNames, n)
+		}
+		sort.Strings(pceNames)
+		for _, m := range metrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.mType)
+			for _, n := range pceNames {
+				for _, line := range samples.pce[n][m.name] {
+					fmt.Fprintln(w, line)
+				}
+			}
+		}
+	})
+
+	utils.LogInfo(fmt.Sprintf("serving metrics for %d pces on %s/metrics", len(pces), listen), true)
+	if err := http.ListenAndServe(listen, nil); err != nil {
+		utils.LogError(err.Error())
+	}
+}
+
+// poll gets the metrics for a pce
+func poll(pce illumioapi.PCE) map[string][]string {
+
+	s := make(map[string][]string)
+	add := func(name string, value float64, labels ...string) {
+		l := []string{fmt.Sprintf("pce=%q", pce.FriendlyName)}
+		for i := 0; i+1 < len(labels); i += 2 {
+			l = append(l, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+		}
+		s[name] = append(s[name], fmt.Sprintf("%s{%s} %v", name, strings.Join(l, ","), value))
+	}
+	start := time.Now()
+	up := 1.0
+
+	// timed runs an api call and records the latency
+	timed := func(call string, f func() (illumioapi.APIResponse, error)) bool {
+		callStart := time.Now()
+		api, err := f()
+		utils.LogAPIResp(call, api)
+		add("workloader_api_latency_seconds", time.Since(callStart).Seconds(), "call", call)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - %s - %s", pce.FriendlyName, call, err), true)
+			up = 0
+			return false
+		}
+		return true
+	}
+
+	// Workloads
+	var wklds []illumioapi.Workload
+	if timed("GetWklds", func() (illumioapi.APIResponse, error) {
+		var api illumioapi.APIResponse
+		var err error
+		wklds, api, err = pce.GetWklds(nil)
+		return api, err
+	}) {
+		counts := make(map[string]int)
+		offline := 0
+		for _, w := range wklds {
+			mode := w.GetMode()
+			managed := mode != "unmanaged"
+			if !managed {
+				mode = w.EnforcementMode
+			}
+			counts[fmt.Sprintf("%t|%s", managed, mode)]++
+			if managed && !w.Online {
+				offline++
+			}
+		}
+		keys := []string{}
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			x := strings.Split(k, "|")
+			add("workloader_workloads", float64(counts[k]), "managed", x[0], "enforcement_mode", x[1])
+		}
+		add("workloader_workloads_offline", float64(offline))
+	}
+
+	// Pending changes
+	var pending illumioapi.ChangeSubset
+	if timed("GetPendingChanges", func() (illumioapi.APIResponse, error) {
+		var api illumioapi.APIResponse
+		var err error
+		pending, api, err = pce.GetPendingChanges()
+		return api, err
+	}) {
+		for _, p := range []struct {
+			object string
+			count  int
+		}{{"rule_sets", len(pending.RuleSets)}, {"ip_lists", len(pending.IPLists)}, {"services", len(pending.Services)}, {"label_groups", len(pending.LabelGroups)}, {"virtual_services", len(pending.VirtualServices)}, {"virtual_servers", len(pending.VirtualServers)}, {"enforcement_boundaries", len(pending.EnforcementBoundaries)}, {"firewall_settings", len(pending.FirewallSettings)}, {"secure_connect_gateways", len(pending.SecureConnectGateways)}} {
+			add("workloader_draft_changes_pending", float64(p.count), "object", p.object)
+		}
+	}
+
+	// Rulesets and rules
+	var rulesets []illumioapi.RuleSet
+	if timed("GetRulesets", func() (illumioapi.APIResponse, error) {
+		var api illumioapi.APIResponse
+		var err error
+		rulesets, api, err = pce.GetRulesets(nil, "active")
+		return api, err
+	}) {
+		rules := 0
+		for _, rs := range rulesets {
+			rules = rules + len(rs.Rules)
+		}
+		add("workloader_rulesets", float64(len(rulesets)))
+		add("workloader_rules", float64(rules))
+	}
+
+	add("workloader_pce_up", up)
+	add("workloader_poll_duration_seconds", time.Since(start).Seconds())
+	add("workloader_poll_timestamp_seconds", float64(time.Now().Unix()))
+	utils.LogInfo(fmt.Sprintf("%s - poll completed in %s", pce.FriendlyName, time.Since(start).Round(time.Millisecond)), false)
+
+	return s
+}
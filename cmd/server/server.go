@@ -0,0 +1,394 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Declare local global variables
+var listen, commandList, tlsCert, tlsKey string
+var maxJobs int
+var jobTimeout time.Duration
+
+// defaultCommands are the exports, reports, and imports the server runs when --commands is not set
+var defaultCommands = []string{"wkld-export", "ven-export", "ipl-export", "label-export", "svc-export", "rule-export", "ruleset-export", "labelgroup-export", "cwp-export",
+	"unused-ports", "mislabel", "dupecheck", "explorer", "nic-export", "service-finder", "process-export", "wkld-ipl-mapping", "ven-health", "unused-umwl",
+	"wkld-import", "ven-import", "ipl-import", "label-import", "svc-import", "labelgroup-import", "rule-import", "ruleset-import"}
+
+// contentTypes are the content types of the output formats
+var contentTypes = map[string]string{"csv": "text/csv", "json": "application/json", "jsonl": "application/x-ndjson", "yaml": "application/yaml", "xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "html": "text/html"}
+
+// serverGlobalFlags are the global flags allowed in request args. The other global flags are set by the server, change the PCE, or write outside the job.
+var serverGlobalFlags = []string{"pce", "org", "member", "debug", "verbose", "max-retries", "connect-timeout", "read-timeout", "long-poll-timeout", "rps", "no-cache", "page-workers"}
+
+// serverCommandFlags are command flags that are not allowed in request args because they write outside the job or keep the command running
+var serverCommandFlags = []string{"output-file", "watch", "interval", "history-dir", "report-email-to"}
+
+func init() {
+	ServerCmd.Flags().StringVar(&listen, "listen", ":8080", "address to serve the api on.")
+	ServerCmd.Flags().StringVar(&commandList, "commands", "", "comma-separated list of commands to expose. default is the export, report, and import commands listed in the help.")
+	ServerCmd.Flags().IntVar(&maxJobs, "max-jobs", 4, "maximum commands to run at the same time. other requests wait.")
+	ServerCmd.Flags().DurationVar(&jobTimeout, "job-timeout", 30*time.Minute, "maximum time for a command to run.")
+	ServerCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "certificate file to serve https. requires --tls-key.")
+	ServerCmd.Flags().StringVar(&tlsKey, "tls-key", "", "private key file for --tls-cert.")
+	ServerCmd.Flags().SortFlags = false
+}
+
+// ServerCmd runs the server command
+var ServerCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Serve workloader commands as authenticated http endpoints.",
+	Long: `
+Serve workloader commands as authenticated http endpoints.
+
+Requests need an Authorization: Bearer header with a token from server_tokens (a list) in pce.yaml or the comma-separated WORKLOADER_SERVER_TOKENS environment variable. The server does not start without a token.
+
+Endpoints:
+- GET /healthz returns ok without authentication.
+- GET /api/v1/commands lists the exposed commands.
+- POST /api/v1/commands/<command> runs a command. The optional json body has args (a list of command flags, e.g., ["--pce", "prod"]) and input (the content of the csv file for import commands). The format query parameter sets the output format (csv, json, jsonl, yaml, xlsx, or html). The default is json.
+
+A command with one output file returns the file. Otherwise, the response is json with the output files and the command output. Errors are json with an error field.
+
+The default exposed commands are the exports (wkld-export, ven-export, ipl-export, label-export, svc-export, rule-export, ruleset-export, labelgroup-export, cwp-export), reports (unused-ports, mislabel, dupecheck, explorer, nic-export, service-finder, process-export, wkld-ipl-mapping, ven-health, unused-umwl), and imports (wkld-import, ven-import, ipl-import, label-import, svc-import, labelgroup-import, rule-import, ruleset-import). Use --commands to change them.
+
+Commands always run without --update-pce, so imports are dry runs that return the changes. Args can only have the command's own flags and the --pce, --org, --member, --debug, --verbose, --max-retries, --connect-timeout, --read-timeout, --long-poll-timeout, --rps, --no-cache, and --page-workers global flags. The --output-file, --watch, --interval, --history-dir, and --report-email-to command flags are not allowed.
+
+Each command runs as a separate workloader process with a copy of the config file that sets default_out, default_format, and output_dir and clears output_template. The command logs are added to the server's workloader.log. Use --tls-cert and --tls-key to serve https. The command runs until stopped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		commands := defaultCommands
+		if commandList != "" {
+			commands = strings.Split(strings.ReplaceAll(commandList, " ", ""), ",")
+		}
+		exposed := make(map[string]*cobra.Command)
+		for _, c := range commands {
+			found, _, err := cmd.Root().Find([]string{c})
+			if err != nil || found == cmd.Root() || found.Name() != c {
+				utils.LogError(fmt.Sprintf("%s is not a workloader command", c))
+			}
+			if c == cmd.Name() {
+				utils.LogError("the server command cannot be exposed")
+			}
+			exposed[c] = found
+		}
+		if (tlsCert == "") != (tlsKey == "") {
+			utils.LogError("--tls-cert and --tls-key must be used together")
+		}
+
+		serve(exposed)
+	},
+}
+
+// serverTokens returns the bearer tokens from WORKLOADER_SERVER_TOKENS or server_tokens in pce.yaml. Encrypted tokens are decrypted.
+func serverTokens() []string {
+	tokens := viper.GetStringSlice("server_tokens")
+	if os.Getenv("WORKLOADER_SERVER_TOKENS") != "" {
+		tokens = strings.Split(os.Getenv("WORKLOADER_SERVER_TOKENS"), ",")
+	}
+	valid := []string{}
+	for _, t := range tokens {
+		t, err := utils.DecryptSecret(strings.TrimSpace(t))
+		if err != nil {
+			utils.LogError(fmt.Sprintf("decrypting server_tokens - %s", err))
+		}
+		if t != "" {
+			valid = append(valid, t)
+		}
+	}
+	return valid
+}
+
+// authorized returns true if the request has one of the tokens
+func authorized(r *http.Request, tokens []string) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	given := []byte(strings.TrimPrefix(auth, "Bearer "))
+	ok := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(given, []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// runRequest is the body of a command request
+type runRequest struct {
+	Args  []string `json:"args"`
+	Input string   `json:"input"`
+}
+
+// outputFile is an output file in a json response. Content is base64 encoded when the file is not text.
+type outputFile struct {
+	Name     string `json:"name"`
+	Encoding string `json:"encoding,omitempty"`
+	Content  string `json:"content"`
+}
+
+// runResponse is the json response when a command does not have exactly one output file
+type runResponse struct {
+	Command string       `json:"command"`
+	Files   []outputFile `json:"files"`
+	Output  string       `json:"output"`
+	Error   string       `json:"error,omitempty"`
+}
+
+func serve(exposed map[string]*cobra.Command) {
+
+	utils.LogStartCommand("server")
+
+	tokens := serverTokens()
+	if len(tokens) == 0 {
+		utils.LogError("no tokens. set server_tokens in pce.yaml or WORKLOADER_SERVER_TOKENS.")
+	}
+	jobs := make(chan struct{}, maxJobs)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/api/v1/commands", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, tokens) {
+			writeError(w, http.StatusUnauthorized, "", "unauthorized", "")
+			return
+		}
+		list := []map[string]string{}
+		for name, c := range exposed {
+			list = append(list, map[string]string{"name": name, "usage": c.Use, "description": c.Short})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i]["name"] < list[j]["name"] })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("/api/v1/commands/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/api/v1/commands/")
+		if !authorized(r, tokens) {
+			utils.LogWarning(fmt.Sprintf("unauthorized request for %s from %s", name, r.RemoteAddr), false)
+			writeError(w, http.StatusUnauthorized, name, "unauthorized", "")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, name, "use POST to run a command", "")
+			return
+		}
+		if _, ok := exposed[name]; !ok {
+			writeError(w, http.StatusNotFound, name, fmt.Sprintf("%s is not an exposed command", name), "")
+			return
+		}
+		format := strings.ToLower(r.URL.Query().Get("format"))
+		if format == "" {
+			format = "json"
+		}
+		if !utils.ValidOutputFormat(format) {
+			writeError(w, http.StatusBadRequest, name, fmt.Sprintf("format must be %s", strings.Join(utils.OutputFormats(), ", ")), "")
+			return
+		}
+		var req runRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				writeError(w, http.StatusBadRequest, name, fmt.Sprintf("invalid json body - %s", err), "")
+				return
+			}
+		}
+		if err := checkArgs(exposed[name], req.Args); err != nil {
+			writeError(w, http.StatusBadRequest, name, err.Error(), "")
+			return
+		}
+
+		// Wait for a job slot
+		select {
+		case jobs <- struct{}{}:
+			defer func() { <-jobs }()
+		case <-r.Context().Done():
+			return
+		}
+
+		utils.LogInfo(fmt.Sprintf("running %s %s for %s", name, strings.Join(req.Args, " "), r.RemoteAddr), false)
+		files, output, err := run(r.Context(), name, format, req)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("%s - %s", name, err), false)
+			writeError(w, http.StatusInternalServerError, name, err.Error(), output)
+			return
+		}
+		if len(files) == 1 {
+			w.Header().Set("Content-Type", contentTypes[format])
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", files[0].name))
+			w.Write(files[0].data)
+			return
+		}
+		resp := runResponse{Command: name, Files: []outputFile{}, Output: output}
+		for _, f := range files {
+			o := outputFile{Name: f.name, Content: string(f.data)}
+			if !utf8.Valid(f.data) {
+				o.Encoding, o.Content = "base64", base64.StdEncoding.EncodeToString(f.data)
+			}
+			resp.Files = append(resp.Files, o)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	utils.LogInfo(fmt.Sprintf("serving %d commands on %s", len(exposed), listen), true)
+	var err error
+	if tlsCert != "" {
+		err = http.ListenAndServeTLS(listen, tlsCert, tlsKey, mux)
+	} else {
+		utils.LogWarning("serving http without tls. use --tls-cert and --tls-key to serve https.", true)
+		err = http.ListenAndServe(listen, mux)
+	}
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+}
+
+// allowedFlags returns the flags request args can have for a command
+func allowedFlags(c *cobra.Command) *pflag.FlagSet {
+	allowed := pflag.NewFlagSet(c.Name(), pflag.ContinueOnError)
+	c.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		for _, n := range serverCommandFlags {
+			if f.Name == n {
+				return
+			}
+		}
+		allowed.AddFlag(f)
+	})
+	for _, n := range serverGlobalFlags {
+		if f := c.Root().PersistentFlags().Lookup(n); f != nil {
+			allowed.AddFlag(f)
+		}
+	}
+	return allowed
+}
+
+// checkArgs returns an error if the args have a flag that is not allowed for the command. Flag values are skipped so a value starting with a dash is not read as a flag.
+func checkArgs(c *cobra.Command, args []string) error {
+	allowed := allowedFlags(c)
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return fmt.Errorf("-- is not allowed")
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			continue
+		}
+		if strings.HasPrefix(a, "--") {
+			name, _, hasValue := strings.Cut(a[2:], "=")
+			f := allowed.Lookup(name)
+			if f == nil {
+				return fmt.Errorf("--%s is not allowed", name)
+			}
+			if !hasValue && f.NoOptDefVal == "" {
+				i++
+			}
+			continue
+		}
+		// Shorthand flags can be combined (e.g., -ab) and the last can take the next arg or the rest of the arg as its value
+		for j := 1; j < len(a); j++ {
+			f := allowed.ShorthandLookup(a[j : j+1])
+			if f == nil {
+				return fmt.Errorf("-%s is not allowed", a[j:j+1])
+			}
+			if f.NoOptDefVal == "" {
+				if j == len(a)-1 {
+					i++
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// writeError writes a json error response
+func writeError(w http.ResponseWriter, status int, command, msg, output string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(runResponse{Command: command, Files: []outputFile{}, Output: output, Error: msg})
+}
+
+// jobFile is an output file of a command
+type jobFile struct {
+	name string
+	data []byte
+}
+
+// run runs a command without --update-pce in a temporary directory and returns the output files and the command output
+func run(ctx context.Context, name, format string, req runRequest) ([]jobFile, string, error) {
+	dir, err := os.MkdirTemp("", "workloader-server-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dir)
+	outDir := filepath.Join(dir, "output")
+	if err := os.Mkdir(outDir, 0700); err != nil {
+		return nil, "", err
+	}
+
+	args := []string{name}
+	if req.Input != "" {
+		input := filepath.Join(dir, "input.csv")
+		if err := os.WriteFile(input, []byte(req.Input), 0600); err != nil {
+			return nil, "", err
+		}
+		args = append(args, input)
+	}
+	args = append(args, req.Args...)
+
+	// The output options are set in the config copy so they do not conflict with command flags
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	output, runErr := utils.RunWorkloader(ctx, dir, map[string]interface{}{"default_out": "csv", "default_format": format, "output_dir": outDir, "output_template": ""}, args...)
+	appendLog(filepath.Join(dir, "workloader.log"))
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, string(output), fmt.Errorf("%s did not finish in %s", name, jobTimeout)
+	}
+	// Commands that fail on some items still return their output files
+	if runErr != nil && !utils.PartialFailure(runErr) {
+		return nil, string(output), fmt.Errorf("%s failed - %s", name, runErr)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return nil, string(output), err
+	}
+	files := []jobFile{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, e.Name()))
+		if err != nil {
+			return nil, string(output), err
+		}
+		files = append(files, jobFile{name: e.Name(), data: data})
+	}
+	return files, string(output), nil
+}
+
+// appendLog adds the log of a command to the server's log file
+func appendLog(jobLog string) {
+	data, err := os.ReadFile(jobLog)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	utils.Logger.Writer().Write(data)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestCheckArgs checks request args can only have the command's flags and the allowed global flags in any form
+func TestCheckArgs(t *testing.T) {
+	root := &cobra.Command{Use: "workloader"}
+	root.PersistentFlags().String("pce", "", "")
+	root.PersistentFlags().Bool("debug", false, "")
+	root.PersistentFlags().String("log-file", "", "")
+	root.PersistentFlags().Bool("all-pces", false, "")
+	root.PersistentFlags().String("pce-group", "", "")
+	root.PersistentFlags().Duration("cache-ttl", 0, "")
+	root.PersistentFlags().Bool("update-pce", false, "")
+	c := &cobra.Command{Use: "explorer", Run: func(*cobra.Command, []string) {}}
+	c.Flags().StringP("start", "s", "", "")
+	c.Flags().BoolP("consolidate", "c", false, "")
+	c.Flags().String("output-file", "", "")
+	c.Flags().Int("interval", 0, "")
+	root.AddCommand(c)
+
+	for _, args := range [][]string{
+		{"--pce", "prod", "--debug"},
+		{"--pce=prod", "--start", "2026-01-01"},
+		{"-s", "-1d", "-c"},
+		{"-cs", "2026-01-01"},
+		{"-cs2026-01-01"},
+		{"input.csv", "--consolidate"},
+	} {
+		if err := checkArgs(c, args); err != nil {
+			t.Errorf("%v - %s", args, err)
+		}
+	}
+	for _, args := range [][]string{
+		{"--log-file", "/tmp/x"},
+		{"--all-pces"},
+		{"--pce-group=prod"},
+		{"--cache-ttl", "1h"},
+		{"--update-pce"},
+		{"--output-file", "/tmp/x"},
+		{"--interval=60"},
+		{"--unknown"},
+		{"-x"},
+		{"-cx"},
+		{"--", "--update-pce"},
+	} {
+		if err := checkArgs(c, args); err == nil {
+			t.Errorf("%v is allowed", args)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 
 // Global variables
 var ports, processes, hrefFile, outputFileName string
-var idleOnly bool
+var idleOnly, privilegedOnly bool
 var pce illumioapi.PCE
 var err error
 
@@ -24,6 +24,7 @@ func init() {
 	ServiceFinderCmd.Flags().BoolVarP(&idleOnly, "idle-only", "i", false, "Only look at idle workloads.")
 	ServiceFinderCmd.Flags().StringVarP(&ports, "ports", "p", "", "Comma-separated list of ports.")
 	ServiceFinderCmd.Flags().StringVarP(&processes, "process-key-words", "k", "", "Comma-separated list of processes. Matching is partial (e.g., a \"python\" will find \"/usr/bin/python2.7\").")
+	ServiceFinderCmd.Flags().BoolVar(&privilegedOnly, "privileged-only", false, "only include processes running as root or a windows admin/system account listening on all interfaces (0.0.0.0 or ::). if no ports or processes are provided, all open ports are checked.")
 	ServiceFinderCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
 	ServiceFinderCmd.Flags().SortFlags = false
@@ -42,6 +43,9 @@ Examples:
 Find any workload listening on Port 80: workloader service-finder -p 80
 Find any workload listening on Port 80 or 443: workloader service-finder -p 80,443
 Find any IDLE workload listening on Port 80: workloader service-finder -i -p 80
+Find any process running as root or a Windows admin/system account listening on all interfaces: workloader service-finder --privileged-only
+
+The user column is the account running the listening process. The privileged_exposure column is true when that account is root or a Windows admin/system account and the process is listening on all interfaces (0.0.0.0 or ::).
 
 The update-pce and --no-prompt flags are ignored for this command.`,
 
@@ -91,6 +95,11 @@ func serviceFinder() {
 		portMap[p] = true
 	}
 
+	// Check that we have something to look for
+	if len(portMap) == 0 && len(processSlice) == 0 && !privilegedOnly {
+		utils.LogError("must provide ports, processes, or the privileged-only flag.")
+	}
+
 	// Start the workload slice
 	wklds := []illumioapi.Workload{}
 	var a illumioapi.APIResponse
@@ -124,12 +133,13 @@ func serviceFinder() {
 	utils.LogInfo(fmt.Sprintf("identified %d target workloads to check processes.", len(wklds)), true)
 
 	// Start our data struct
-	data := [][]string{{"href", "hostname", "port", "process", "role", "app", "env", "loc", "ip"}}
+	data := [][]string{{"href", "hostname", "port", "process", "user", "address", "privileged_exposure", "role", "app", "env", "loc", "ip"}}
 
 	// For each workload in our target list, make a single workload API call to get services
 	warningMsgs := []string{}
-	for i, w := range wklds {
-		fmt.Printf("\r%s [INFO] - checking %d of %d workloads", time.Now().Format("2006-01-02 15:04:05 "), i+1, len(wklds))
+	progress := utils.NewProgress("checking workloads", len(wklds))
+	for _, w := range wklds {
+		progress.Increment()
 		w, a, err = pce.GetWkldByHref(w.Href)
 		utils.LogAPIResp("GetWkldByHref", a)
 		if err != nil && a.StatusCode == 0 {
@@ -139,29 +149,34 @@ func serviceFinder() {
 			continue
 		}
 
-		// Iterate through each open port
-		if len(portMap) > 0 {
-			for _, o := range w.Services.OpenServicePorts {
-				if _, ok := portMap[o.Port]; ok {
-					data = append(data, []string{w.Href, w.Hostname, strconv.Itoa(o.Port), o.ProcessName, w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value, w.GetIPWithDefaultGW()})
-				}
-			}
+		if w.Services == nil {
+			continue
 		}
 
-		// Iterate through each running process
-		if len(processSlice) > 0 {
-			for _, wkldProcess := range w.Services.OpenServicePorts {
-				for _, providedProcess := range processSlice {
-					if strings.Contains(wkldProcess.ProcessName, providedProcess) {
-						data = append(data, []string{w.Href, w.Hostname, strconv.Itoa(wkldProcess.Port), wkldProcess.ProcessName, w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value, w.GetIPWithDefaultGW()})
-					}
+		// Iterate through each open port
+		for _, o := range w.Services.OpenServicePorts {
+			match := false
+			if len(portMap) == 0 && len(processSlice) == 0 {
+				match = true
+			}
+			if portMap[o.Port] {
+				match = true
+			}
+			for _, providedProcess := range processSlice {
+				if strings.Contains(o.ProcessName, providedProcess) {
+					match = true
 				}
 			}
+			privileged := utils.PrivilegedExposure(o.User, o.Address)
+			if !match || (privilegedOnly && !privileged) {
+				continue
+			}
+			data = append(data, []string{w.Href, w.Hostname, strconv.Itoa(o.Port), o.ProcessName, o.User, o.Address, strconv.FormatBool(privileged), w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value, w.GetIPWithDefaultGW()})
 		}
 
 	}
 	// Print a blank line for closing out progress
-	fmt.Println()
+	progress.Done()
 
 	// Print warnings
 	for _, msg := range warningMsgs {
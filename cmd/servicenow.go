@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var snowTicket string
+var snowWaitApproval bool
+var snowApprovalTimeout time.Duration
+var snowRecord utils.ServiceNowRecord
+
+// snowFlags are removed from the arguments for the dry run
+var snowFlags = []string{"update-pce", "no-prompt", "snow-ticket", "snow-wait-approval", "snow-approval-timeout", "approval-file"}
+
+// openServiceNowTicket runs the command without --update-pce to capture what will change, opens a ServiceNow change request or incident
+// with the output, and optionally waits for the change request to be approved before the command runs with --update-pce.
+func openServiceNowTicket(cmd *cobra.Command, cmdArgs []string) {
+
+	if snowTicket != "change" && snowTicket != "incident" {
+		utils.LogError("--snow-ticket must be change or incident")
+	}
+	table := "change_request"
+	if snowTicket == "incident" {
+		table = "incident"
+		if snowWaitApproval {
+			utils.LogError("--snow-wait-approval requires --snow-ticket change")
+		}
+	}
+
+	// Run the command without --update-pce
+	args := rerunArgs(cmd, cmdArgs, snowFlags)
+	exe, err := os.Executable()
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("running %s without --update-pce for the servicenow %s", cmd.Name(), snowTicket), true)
+	dryRun := exec.Command(exe, args...)
+	dryRun.Env = append(os.Environ(), "WORKLOADER_SNOW_DRY_RUN=true")
+	output, err := dryRun.CombinedOutput()
+	if err != nil {
+		utils.LogError(fmt.Sprintf("dry run failed - %s - %s", err, string(output)))
+	}
+
+	// Create the record
+	pceName := targetPCE
+	if pceName == "" {
+		pceName = "default pce"
+	}
+	description := fmt.Sprintf("workloader will run the following command with --update-pce:\n\nworkloader %s\n\nDry run output:\n\n%s", strings.Join(args, " "), string(output))
+	if len(description) > 30000 {
+		description = description[:30000] + "\n\n[truncated - see workloader.log]"
+	}
+	snowRecord, err = utils.CreateServiceNowRecord(table, fmt.Sprintf("workloader %s - %s", cmd.Name(), pceName), description)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("creating servicenow %s - %s", snowTicket, err))
+	}
+	utils.LogInfo(fmt.Sprintf("created servicenow %s %s", snowTicket, snowRecord.Number), true)
+
+	// Wait for approval
+	if snowWaitApproval {
+		if err := utils.WaitForServiceNowApproval(snowRecord, time.Minute, snowApprovalTimeout); err != nil {
+			utils.AddServiceNowWorkNote(snowRecord, fmt.Sprintf("workloader %s did not run - %s", cmd.Name(), err))
+			utils.LogError(err.Error())
+		}
+	}
+}
+
+// closeServiceNowTicket adds a work note to the record when the command completes
+func closeServiceNowTicket(cmd *cobra.Command) {
+	if snowRecord.SysID == "" {
+		return
+	}
+	if err := utils.AddServiceNowWorkNote(snowRecord, fmt.Sprintf("workloader %s completed at %s. see workloader.log for details.", cmd.Name(), time.Now().Format("2006-01-02 15:04:05"))); err != nil {
+		utils.LogWarning(fmt.Sprintf("adding work note to %s - %s", snowRecord.Number, err), true)
+	}
+}
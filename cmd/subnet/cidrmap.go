@@ -0,0 +1,332 @@
+package subnet
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// cidrLabels is a row of the cidr map
+type cidrLabels struct {
+	network net.IPNet
+	prefix  int
+	labels  map[string]string
+	line    int
+}
+
+// cidrMatch is the value of a label key from the most specific network matching a workload's IP address
+type cidrMatch struct {
+	value   string
+	network string
+	prefix  int
+}
+
+// parseNetwork parses a cidr or an ip address as a host network
+func parseNetwork(s string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%s is not a cidr or ip address", s)
+	}
+	if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// parseCIDRMap parses the cidr map and returns the rows and the label keys
+func parseCIDRMap(filename string, dimensions []string) ([]cidrLabels, []string) {
+	data, err := utils.ParseCSV(filename)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if len(data) < 2 {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s has no cidrs", filename))
+	}
+
+	// Process the headers
+	validKey := make(map[string]bool)
+	for _, d := range dimensions {
+		validKey[d] = true
+	}
+	cidrCol := -1
+	keyCols := make(map[string]int)
+	keys := []string{}
+	for c, h := range data[0] {
+		h = strings.TrimSpace(h)
+		if strings.ToLower(h) == "cidr" {
+			cidrCol = c
+			continue
+		}
+		if !validKey[h] {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s header is not a label dimension. valid keys are %s", h, strings.Join(dimensions, ", ")))
+		}
+		if _, ok := keyCols[h]; ok {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s header is in %s more than once", h, filename))
+		}
+		keyCols[h] = c
+		keys = append(keys, h)
+	}
+	if cidrCol == -1 {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s requires a cidr header", filename))
+	}
+	if len(keys) == 0 {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s requires at least one label key header", filename))
+	}
+
+	// Process the rows
+	cidrs := []cidrLabels{}
+	lines := make(map[string]int)
+	for i, line := range data[1:] {
+		if cidrCol >= len(line) || strings.TrimSpace(line[cidrCol]) == "" {
+			continue
+		}
+		network, err := parseNetwork(strings.TrimSpace(line[cidrCol]))
+		if err != nil {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s line %d - %s", filename, i+2, err))
+		}
+		if prev, ok := lines[network.String()]; ok {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s line %d - %s is also on line %d", filename, i+2, network.String(), prev))
+		}
+		lines[network.String()] = i + 2
+		prefix, _ := network.Mask.Size()
+		c := cidrLabels{network: *network, prefix: prefix, labels: make(map[string]string), line: i + 2}
+		for _, k := range keys {
+			if keyCols[k] < len(line) && strings.TrimSpace(line[keyCols[k]]) != "" {
+				c.labels[k] = strings.TrimSpace(line[keyCols[k]])
+			}
+		}
+		cidrs = append(cidrs, c)
+	}
+
+	return cidrs, keys
+}
+
+// parseExclusions parses a file with one cidr or ip address per line. A header row is skipped.
+func parseExclusions(filename string) []net.IPNet {
+	data, err := utils.ParseCSV(filename)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	exclusions := []net.IPNet{}
+	for i, line := range data {
+		if len(line) == 0 || strings.TrimSpace(line[0]) == "" {
+			continue
+		}
+		network, err := parseNetwork(strings.TrimSpace(line[0]))
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s line %d - %s", filename, i+1, err))
+		}
+		exclusions = append(exclusions, *network)
+	}
+	return exclusions
+}
+
+// lookup returns the value of each label key from the longest prefix containing the ip address
+func lookup(ip net.IP, cidrs []cidrLabels) map[string]cidrMatch {
+	matches := make(map[string]cidrMatch)
+	for _, c := range cidrs {
+		if !c.network.Contains(ip) {
+			continue
+		}
+		for k, v := range c.labels {
+			if m, ok := matches[k]; !ok || c.prefix > m.prefix {
+				matches[k] = cidrMatch{value: v, network: c.network.String(), prefix: c.prefix}
+			}
+		}
+	}
+	return matches
+}
+
+func cidrMapLabel() {
+
+	utils.LogStartCommand("subnet")
+
+	// Get the label dimensions. PCEs without label dimensions use role, app, env, and loc.
+	dimensions := []string{}
+	labelDimensions, a, err := pce.GetLabelDimensions(nil)
+	utils.LogAPIResp("GetLabelDimensions", a)
+	if err != nil || len(labelDimensions) == 0 {
+		dimensions = []string{"role", "app", "env", "loc"}
+	}
+	for _, d := range labelDimensions {
+		dimensions = append(dimensions, d.Key)
+	}
+
+	// Parse the map and exclusions
+	cidrs, keys := parseCIDRMap(cidrMapFile, dimensions)
+	exclusions := []net.IPNet{}
+	if exclusionFile != "" {
+		exclusions = parseExclusions(exclusionFile)
+	}
+	utils.LogInfo(fmt.Sprintf("%d cidrs in the map and %d exclusions", len(cidrs), len(exclusions)), true)
+
+	// Get all workloads
+	allWklds, a, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetAllWorkloads", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Find the labels for each workload
+	headers := []string{"hostname", "name", "href", "matched_ips"}
+	for _, k := range keys {
+		headers = append(headers, k, "original_"+k, k+"_cidr")
+	}
+	data := [][]string{headers}
+	changes := utils.NewChangeSet[illumioapi.Workload]("workloads")
+	assignments := make(map[string]map[string]string)
+	for _, w := range allWklds {
+		if !inScope(w) {
+			continue
+		}
+
+		// Look up each ip address. If the workload is managed, only the interfaces with a default gateway are used.
+		matchedIPs := []string{}
+		values := make(map[string]map[string]cidrMatch)
+		for _, i := range w.Interfaces {
+			if w.GetMode() != "unmanaged" && i.DefaultGatewayAddress == "" {
+				continue
+			}
+			ip := net.ParseIP(i.Address)
+			if ip == nil {
+				continue
+			}
+			excluded := false
+			for _, e := range exclusions {
+				if e.Contains(ip) {
+					excluded = true
+					break
+				}
+			}
+			if excluded {
+				utils.LogInfo(fmt.Sprintf("%s - %s is excluded", w.Href, i.Address), false)
+				continue
+			}
+			matches := lookup(ip, cidrs)
+			if len(matches) == 0 {
+				continue
+			}
+			matchedIPs = append(matchedIPs, i.Address)
+			for k, m := range matches {
+				if values[k] == nil {
+					values[k] = make(map[string]cidrMatch)
+				}
+				values[k][m.value] = m
+			}
+		}
+		if len(matchedIPs) == 0 {
+			continue
+		}
+
+		// Compare to the current labels
+		var fields []utils.FieldChange
+		row := []string{w.Hostname, w.Name, w.Href, strings.Join(matchedIPs, ";")}
+		assigned := make(map[string]string)
+		for _, k := range keys {
+			current := w.GetLabelByKey(k, pce.Labels).Value
+			if len(values[k]) > 1 {
+				conflicts := []string{}
+				for v, m := range values[k] {
+					conflicts = append(conflicts, fmt.Sprintf("%s (%s)", v, m.network))
+				}
+				sort.Strings(conflicts)
+				utils.LogWarning(fmt.Sprintf("%s - %s - ip addresses map to different %s labels: %s. skipping %s.", w.Hostname, w.Href, k, strings.Join(conflicts, ", "), k), true)
+				utils.RecordFailure(fmt.Sprintf("%s - conflicting %s labels", w.Href, k))
+				row = append(row, "", current, "")
+				continue
+			}
+			var m cidrMatch
+			for _, v := range values[k] {
+				m = v
+			}
+			if m.value == "" || m.value == current {
+				row = append(row, "", current, m.network)
+				continue
+			}
+			fields = append(fields, utils.DiffValue(k+" label", current, m.value)...)
+			assigned[k] = m.value
+			row = append(row, m.value, current, m.network)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		name := w.Hostname
+		if name == "" {
+			name = w.Name
+		}
+		changes.Update(w, name, w.Href, fields)
+		assignments[w.Href] = assigned
+		data = append(data, row)
+	}
+	changes.Log()
+
+	// End run if we have nothing to do
+	if changes.Empty() {
+		utils.LogInfo("no workloads identified for label change", true)
+		utils.LogEndCommand("subnet")
+		return
+	}
+
+	// Write the output file
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-subnet-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !updatePCE {
+		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring label change. To update their labels, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(changes.Updates)), true)
+		utils.LogEndCommand("subnet")
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if updatePCE && !noPrompt {
+		var prompt string
+		fmt.Printf("[PROMPT] - workloader will change the labels of %d workloads in %s (%s). Do you want to run the change (yes/no)? ", len(changes.Updates), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo(fmt.Sprintf("prompt denied to change labels of %d workloads.", len(changes.Updates)), true)
+			utils.LogEndCommand("subnet")
+			return
+		}
+	}
+
+	// Change the labels. Labels that do not exist are created.
+	updatedWklds := []illumioapi.Workload{}
+	for _, c := range changes.Updates {
+		w := c.Object
+		if w.Labels == nil {
+			w.Labels = &[]*illumioapi.Label{}
+		}
+		for _, k := range keys {
+			if v, ok := assignments[w.Href][k]; ok {
+				pce, err = w.ChangeLabel(pce, k, v)
+				if err != nil {
+					utils.LogError(err.Error())
+				}
+			}
+		}
+		updatedWklds = append(updatedWklds, w)
+	}
+	api, err := pce.BulkWorkload(updatedWklds, "update", true)
+	for _, a := range api {
+		utils.LogAPIResp("BulkWorkloadUpdate", a)
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("running bulk update - %s", err))
+	}
+	utils.LogInfo(fmt.Sprintf("bulk updated %d workloads.", len(updatedWklds)), true)
+	utils.LogEndCommand("subnet")
+}
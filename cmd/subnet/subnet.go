@@ -16,7 +16,7 @@ import (
 	"github.com/spf13/viper"
 )
 
-var csvFile, role, app, env, loc, outputFileName string
+var csvFile, role, app, env, loc, outputFileName, cidrMapFile, exclusionFile string
 var netCol, envCol, locCol int
 var debug, updatePCE, noPrompt, setLabelExcl bool
 var pce illumioapi.PCE
@@ -44,6 +44,8 @@ func init() {
 	SubnetCmd.Flags().StringVarP(&env, "env", "e", "", "Environment Label. Blank means all environments.")
 	SubnetCmd.Flags().StringVarP(&loc, "loc", "l", "", "Location Label. Blank means all locations.")
 	SubnetCmd.Flags().BoolVarP(&setLabelExcl, "exclude-labels", "x", false, "Use provided label filters as excludes.")
+	SubnetCmd.Flags().StringVar(&cidrMapFile, "cidr-map", "", "csv file mapping cidrs to labels. replaces the csv argument. see usage help.")
+	SubnetCmd.Flags().StringVar(&exclusionFile, "exclusion-file", "", "file with one cidr or ip address per line that is never labeled with --cidr-map.")
 	SubnetCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
 	SubnetCmd.Flags().SortFlags = false
@@ -52,8 +54,9 @@ func init() {
 
 // SubnetCmd runs the workload identifier
 var SubnetCmd = &cobra.Command{
-	Use:   "subnet [csv file with subnet inputs]",
-	Short: "Assign environment and location labels based on a workload's network.",
+	Use:         "subnet [csv file with subnet inputs]",
+	Short:       "Assign labels based on a workload's network.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Assign envrionment and location labels based on a workload's network.
 	
@@ -66,7 +69,24 @@ The input CSV requires headers and at least three columns: network, environment
 +----------------+------+-----+
 | 10.0.0.0/8     | PROD | BOS |
 | 192.168.0.0/16 | DEV  | NYC |
-+----------------+------+-----+`,
++----------------+------+-----+
+
+CIDR maps:
+Use --cidr-map instead of the csv argument to assign any label keys. The map requires a cidr header and the other headers are label keys. A blank value does not assign that key. Networks can overlap and, for each label key, the longest prefix with a value wins. Single IP addresses are also accepted. For example, 10.1.2.0/24 gets env PROD and loc BOS below, and 10.9.0.0/16 gets env DEV and loc BOS:
+
+  cidr,env,loc,app
+  10.0.0.0/8,DEV,BOS,
+  10.1.0.0/16,PROD,,
+  10.1.5.0/24,,,BACKUP
+
+IP addresses in the --exclusion-file (one cidr or ip per line) are never labeled, regardless of the map. If a workload's matched IP addresses map to different values for a label key, that key is skipped for the workload and the command exits with 3.
+
+The output csv has the href and assigned labels of each workload that changes and can be used with wkld-import.
+
+Examples:
+  workloader subnet subnets.csv
+  workloader subnet --cidr-map cidr-map.csv --exclusion-file excluded.txt
+  workloader subnet --cidr-map cidr-map.csv --update-pce --no-prompt`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		pce, err = utils.GetTargetPCE(true)
@@ -74,18 +94,30 @@ The input CSV requires headers and at least three columns: network, environment
 			utils.Logger.Fatalf("Error getting PCE for subnet command - %s", err)
 		}
 
+		// Get Viper configuration
+		debug = viper.Get("debug").(bool)
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		// The cidr map replaces the csv file
+		if cidrMapFile != "" {
+			if len(args) != 0 {
+				utils.LogErrorCode(utils.ExitValidation, "--cidr-map cannot be used with a csv file argument")
+			}
+			cidrMapLabel()
+			return
+		}
+		if exclusionFile != "" {
+			utils.LogErrorCode(utils.ExitValidation, "--exclusion-file requires --cidr-map")
+		}
+
 		// Get CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		csvFile = args[0]
 
-		// Get Viper configuration
-		debug = viper.Get("debug").(bool)
-		updatePCE = viper.Get("update_pce").(bool)
-		noPrompt = viper.Get("no_prompt").(bool)
-
 		subnetParser()
 	},
 }
@@ -137,6 +169,27 @@ func locParser(csvFile string, netCol, envCol, locCol int) []subnet {
 	return results
 }
 
+// inScope checks the workload against the role, app, env, and loc flags
+func inScope(w illumioapi.Workload) bool {
+	roleCheck, appCheck, envCheck, locCheck := true, true, true, true
+	if app != "" && w.GetApp(pce.Labels).Value != app {
+		appCheck = false
+	}
+	if role != "" && w.GetRole(pce.Labels).Value != role {
+		roleCheck = false
+	}
+	if env != "" && w.GetEnv(pce.Labels).Value != env {
+		envCheck = false
+	}
+	if loc != "" && w.GetLoc(pce.Labels).Value != loc {
+		locCheck = false
+	}
+	if setLabelExcl {
+		return !roleCheck || !appCheck || !locCheck || !envCheck
+	}
+	return roleCheck && appCheck && locCheck && envCheck
+}
+
 func subnetParser() {
 
 	utils.LogStartCommand("subnet")
@@ -158,26 +211,10 @@ func subnetParser() {
 		utils.LogError(err.Error())
 	}
 
-	// Confirm it's not unmanaged and check the labels to find our matches.
+	// Check the labels to find our matches.
 	wklds := []illumioapi.Workload{}
 	for _, w := range allWklds {
-
-		roleCheck, appCheck, envCheck, locCheck := true, true, true, true
-		if app != "" && w.GetApp(pce.Labels).Value != app {
-			appCheck = false
-		}
-		if role != "" && w.GetRole(pce.Labels).Value != role {
-			roleCheck = false
-		}
-		if env != "" && w.GetEnv(pce.Labels).Value != env {
-			envCheck = false
-		}
-		if loc != "" && w.GetLoc(pce.Labels).Value != loc {
-			locCheck = false
-		}
-		if roleCheck && appCheck && locCheck && envCheck && !setLabelExcl {
-			wklds = append(wklds, w)
-		} else if (!roleCheck || !appCheck || !locCheck || !envCheck) && setLabelExcl {
+		if inScope(w) {
 			wklds = append(wklds, w)
 		}
 	}
@@ -260,7 +297,7 @@ func subnetParser() {
 		// If updatePCE is set, but not noPrompt, we will prompt the user.
 		if updatePCE && !noPrompt {
 			var prompt string
-			fmt.Printf("[PROMPT] - workloader will change the labels of %d workloads in %s (%s). Do you want to run the change (yes/no)? ", len(data)-1, pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+			fmt.Printf("[PROMPT] - workloader will change the labels of %d workloads in %s (%s). Do you want to run the change (yes/no)? ", len(data)-1, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 			fmt.Scanln(&prompt)
 			if strings.ToLower(prompt) != "yes" {
 				utils.LogInfo(fmt.Sprintf("prompt denied to change labels of %d workloads.", len(data)-1), true)
@@ -2,7 +2,6 @@ package svcimport
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/brian1917/illumioapi"
 
@@ -22,8 +21,9 @@ func init() {
 
 // SvcImportCmd runs the service import command
 var SvcImportCmd = &cobra.Command{
-	Use:   "svc-import [csv file to import]",
-	Short: "Create and update services from a CSV.",
+	Use:         "svc-import [csv file to import]",
+	Short:       "Create and update services from a CSV.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapObjectWrite},
 	Long: `
 Create and update services from a CSV file. 
 
@@ -59,7 +59,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 
 		input.Data, err = utils.ParseCSV(args[0])
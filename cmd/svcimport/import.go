@@ -2,13 +2,13 @@ package svcimport
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/brian1917/illumioapi"
 	"github.com/brian1917/workloader/cmd/svcexport"
 	"github.com/brian1917/workloader/utils"
-	"github.com/spf13/viper"
 )
 
 // Input is the input object for the ImportServices Command
@@ -132,86 +132,55 @@ func ImportServices(input Input) {
 		}
 	}
 
-	// Iterate through the CSV Map
-	// Conver the CSVMap
-	newServices := []csvService{}
-	updatedServices := []csvService{}
+	// Compare the CSV services to the PCE
+	changes := utils.NewChangeSet[illumioapi.Service]("services")
 	for _, csvSvc := range csvSvcMap {
 		if csvSvc.service.Href == "" {
 			// Check if the service exists in the PCE.
 			if _, ok := svcNameMap[csvSvc.service.Name]; ok {
 				utils.LogError(fmt.Sprintf("csv line %s - %s already exists in the PCE. add an href to update it or use the --update-on-name flag.", strings.Join(intSliceToStrSlice(csvSvc.csvLines), ", "), csvSvc.service.Name))
 			}
-			newServices = append(newServices, csvSvc)
-			utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s to be created", strings.Join(intSliceToStrSlice(csvSvc.csvLines), ", "), csvSvc.service.Name), false)
+			changes.Create(csvSvc.service, csvSvc.service.Name, csvSvc.csvLines...)
 		} else {
 			// Href is provided so we need to check if we need to update
 			if pceSvc, ok := input.PCE.Services[csvSvc.service.Href]; !ok {
 				utils.LogError(fmt.Sprintf("csv line(s) %s - %s does not exist in the PCE", strings.Join(intSliceToStrSlice(csvSvc.csvLines), ", "), csvSvc.service.Href))
 			} else {
-
-				// Create a map of the pceSvc. The key is going to be name-port-toport-protocol-process-svc-icmpcode-icmptype
-				pceSvcMapSvcs := make(map[string]string)
-				for _, ws := range pceSvc.WindowsServices {
-					pceSvcMapSvcs[fmt.Sprintf("%s-%d-%d-%d-%s-%s-%d-%d", pceSvc.Href, ws.Port, ws.ToPort, ws.Protocol, ws.ProcessName, ws.ServiceName, ws.IcmpCode, ws.IcmpType)] = fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ProcessName: %s; Service: %s; ICMP Code: %d; ICMP Type: %d", ws.Port, ws.ToPort, ws.Protocol, ws.ProcessName, ws.ServiceName, ws.IcmpCode, ws.IcmpType)
-				}
-				for _, svp := range pceSvc.ServicePorts {
-					pceSvcMapSvcs[fmt.Sprintf("%s-%d-%d-%d-%d-%d", pceSvc.Href, svp.Port, svp.ToPort, svp.Protocol, svp.IcmpCode, svp.IcmpType)] = fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ICMP Code: %d; ICMP Type: %d", svp.Port, svp.ToPort, svp.Protocol, svp.IcmpCode, svp.IcmpType)
-				}
-
-				// Create a map of csvSvc with the same key
-				csvSvcMapSvcs := make(map[string]string)
-				for _, ws := range csvSvc.service.WindowsServices {
-					csvSvcMapSvcs[fmt.Sprintf("%s-%d-%d-%d-%s-%s-%d-%d", csvSvc.service.Href, ws.Port, ws.ToPort, ws.Protocol, ws.ProcessName, ws.ServiceName, ws.IcmpCode, ws.IcmpType)] = fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ProcessName: %s; Service: %s; ICMP Code: %d; ICMP Type: %d", ws.Port, ws.ToPort, ws.Protocol, ws.ProcessName, ws.ServiceName, ws.IcmpCode, ws.IcmpType)
-				}
-				for _, svp := range csvSvc.service.ServicePorts {
-					csvSvcMapSvcs[fmt.Sprintf("%s-%d-%d-%d-%d-%d", csvSvc.service.Href, svp.Port, svp.ToPort, svp.Protocol, svp.IcmpCode, svp.IcmpType)] = fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ICMP Code: %d; ICMP Type: %d", svp.Port, svp.ToPort, svp.Protocol, svp.IcmpCode, svp.IcmpType)
-				}
-
-				update := false
-				// Are all the services in the CSV entry in the PCE?
-				for s := range csvSvcMapSvcs {
-					if _, ok := pceSvcMapSvcs[s]; !ok {
-						update = true
-						utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s exists in the CSV but not the PCE. It will be added", strings.Join(intSliceToStrSlice(csvSvc.csvLines), ", "), csvSvcMapSvcs[s]), true)
-					}
-				}
-
-				for s := range pceSvcMapSvcs {
-					if _, ok := csvSvcMapSvcs[s]; !ok {
-						update = true
-						utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s exists in the PCE but not the CSV. It will be removed", strings.Join(intSliceToStrSlice(csvSvc.csvLines), ", "), pceSvcMapSvcs[s]), true)
-					}
-				}
-
-				if update {
-					updatedServices = append(updatedServices, csvSvc)
+				fields := utils.DiffValue("name", pceSvc.Name, csvSvc.service.Name)
+				if _, ok := input.Headers[svcexport.HeaderDescription]; ok {
+					fields = append(fields, utils.DiffValue("description", pceSvc.Description, csvSvc.service.Description)...)
 				}
+				fields = append(fields, utils.DiffSet("services", svcEntries(pceSvc), svcEntries(csvSvc.service))...)
+				changes.Update(csvSvc.service, pceSvc.Name, pceSvc.Href, fields, csvSvc.csvLines...)
 			}
 		}
 	}
+	sort.SliceStable(changes.Creates, func(i, j int) bool { return changes.Creates[i].CSVLines[0] < changes.Creates[j].CSVLines[0] })
+	sort.SliceStable(changes.Updates, func(i, j int) bool { return changes.Updates[i].CSVLines[0] < changes.Updates[j].CSVLines[0] })
+	changes.Log()
 
 	// End run if we have nothing to do
-	if len(newServices) == 0 && len(updatedServices) == 0 {
+	if changes.Empty() {
 		utils.LogInfo("nothing to be done.", true)
 		utils.LogEndCommand("svc-import")
 		return
 	}
 
 	if !input.UpdatePCE {
-		utils.LogInfo(fmt.Sprintf("workloader identified %d services to create and %d services to update. See workloader.log for all identified changes. To do the import, run again using --update-pce flag", len(newServices), len(updatedServices)), true)
+		utils.LogInfo(fmt.Sprintf("workloader identified %s. See workloader.log for all identified changes. To do the import, run again using --update-pce flag", changes.Summary()), true)
+		utils.LogEndCommand("svc-import")
 		return
 	}
 
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if input.UpdatePCE && !input.NoPrompt {
 		var prompt string
-		fmt.Printf("[PROMPT] - workloader will create %d services and update %d services in %s (%s). Do you want to run the import (yes/no)? ", len(newServices), len(updatedServices), input.PCE.FriendlyName, viper.Get(input.PCE.FriendlyName+".fqdn").(string))
+		fmt.Printf("[PROMPT] - workloader will create %d services and update %d services in %s (%s). Do you want to run the import (yes/no)? ", len(changes.Creates), len(changes.Updates), input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName))
 
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
-			utils.LogInfo(fmt.Sprintf("Prompt denied for creating %d iplists and updating %d iplists.", len(newServices), len(updatedServices)), true)
-			utils.LogEndCommand("ipl-import")
+			utils.LogInfo(fmt.Sprintf("Prompt denied for creating %d services and updating %d services.", len(changes.Creates), len(changes.Updates)), true)
+			utils.LogEndCommand("svc-import")
 			return
 		}
 	}
@@ -219,42 +188,42 @@ func ImportServices(input Input) {
 	// Create new services
 	var createdCount, updatedCount, skippedCount int
 	provisionableSvcs := []string{}
-	for _, newSvc := range newServices {
-		svc, a, err := input.PCE.CreateService(newSvc.service)
+	for _, newSvc := range changes.Creates {
+		svc, a, err := input.PCE.CreateService(newSvc.Object)
 		utils.LogAPIResp("CreateService", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("Ending run - %d services created - %d services Lists updated.", createdCount, updatedCount))
 			utils.LogError(err.Error())
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line(s) %s - %s - 406 Not Acceptable - See workloader.log for more details", strings.Join(intSliceToStrSlice(newSvc.csvLines), ", "), newSvc.service.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line(s) %s - %s - 406 Not Acceptable - See workloader.log for more details", strings.Join(intSliceToStrSlice(newSvc.CSVLines), ", "), newSvc.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skippedCount++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s created - status code %d", strings.Join(intSliceToStrSlice(newSvc.csvLines), ", "), svc.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s created - status code %d", strings.Join(intSliceToStrSlice(newSvc.CSVLines), ", "), svc.Name, a.StatusCode), true)
 			createdCount++
 			provisionableSvcs = append(provisionableSvcs, svc.Href)
 		}
 	}
 
 	// Update Services
-	for _, updateSvc := range updatedServices {
-		a, err := input.PCE.UpdateService(updateSvc.service)
+	for _, updateSvc := range changes.Updates {
+		a, err := input.PCE.UpdateService(updateSvc.Object)
 		utils.LogAPIResp("UpdateService", a)
 		if err != nil && a.StatusCode != 406 {
 			utils.LogError(fmt.Sprintf("Ending run - %d services created - %d services updated.", createdCount, updatedCount))
 			utils.LogError(err.Error())
 		}
 		if a.StatusCode == 406 {
-			utils.LogWarning(fmt.Sprintf("csv line(s) %s - %s - 406 Not Acceptable - See workloader.log for more details", strings.Join(intSliceToStrSlice(updateSvc.csvLines), ", "), updateSvc.service.Name), true)
+			utils.LogWarning(fmt.Sprintf("csv line(s) %s - %s - 406 Not Acceptable - See workloader.log for more details", strings.Join(intSliceToStrSlice(updateSvc.CSVLines), ", "), updateSvc.Name), true)
 			utils.LogWarning(a.RespBody, false)
 			skippedCount++
 		}
 		if err == nil {
-			utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s updated - status code %d", strings.Join(intSliceToStrSlice(updateSvc.csvLines), ", "), updateSvc.service.Name, a.StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("csv line(s) %s - %s updated - status code %d", strings.Join(intSliceToStrSlice(updateSvc.CSVLines), ", "), updateSvc.Name, a.StatusCode), true)
 			updatedCount++
-			provisionableSvcs = append(provisionableSvcs, updateSvc.service.Href)
+			provisionableSvcs = append(provisionableSvcs, updateSvc.Href)
 		}
 	}
 
@@ -271,3 +240,15 @@ func ImportServices(input Input) {
 		utils.LogEndCommand("svc-import")
 	}
 }
+
+// svcEntries returns the windows services and service ports of a service as text
+func svcEntries(svc illumioapi.Service) []string {
+	entries := []string{}
+	for _, ws := range svc.WindowsServices {
+		entries = append(entries, fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ProcessName: %s; Service: %s; ICMP Code: %d; ICMP Type: %d", ws.Port, ws.ToPort, ws.Protocol, ws.ProcessName, ws.ServiceName, ws.IcmpCode, ws.IcmpType))
+	}
+	for _, svp := range svc.ServicePorts {
+		entries = append(entries, fmt.Sprintf("Port: %d; To Port: %d; Proto: %d; ICMP Code: %d; ICMP Type: %d", svp.Port, svp.ToPort, svp.Protocol, svp.IcmpCode, svp.IcmpType))
+	}
+	return entries
+}
@@ -0,0 +1,49 @@
+package svcimport
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/spf13/viper"
+)
+
+// TestImportServicesChanges checks only services with changed values are sent to the pce. Port order is ignored.
+func TestImportServicesChanges(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Add("sec_policy/draft/services", map[string]interface{}{
+		"href":          "/orgs/1/sec_policy/draft/services/10",
+		"name":          "Web Ports",
+		"service_ports": []interface{}{map[string]interface{}{"port": 80, "proto": 6}, map[string]interface{}{"port": 8080, "proto": 6}},
+	})
+	s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	input := Input{PCE: s.PCE(), UpdatePCE: true, NoPrompt: true}
+	if _, err := input.PCE.Load(illumioapi.LoadInput{Services: true}); err != nil {
+		t.Fatal(err)
+	}
+	input.Data = [][]string{
+		{"href", "name", "description", "ports", "protocol"},
+		{"/orgs/1/sec_policy/draft/services/10", "Web Ports", "", "8080", "tcp"},
+		{"/orgs/1/sec_policy/draft/services/10", "Web Ports", "", "80", "tcp"},
+		{"/orgs/1/sec_policy/draft/services/3", "PostgreSQL", "", "5432", "tcp"},
+		{"/orgs/1/sec_policy/draft/services/3", "PostgreSQL", "", "5433", "tcp"},
+		{"", "Redis", "", "6379", "tcp"},
+	}
+	ImportServices(input)
+
+	writes := []string{}
+	for _, r := range s.Writes() {
+		writes = append(writes, r.Method+" "+r.Path)
+	}
+	want := []string{"POST /orgs/1/sec_policy/draft/services", "PUT /orgs/1/sec_policy/draft/services/3"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("writes are %v, want %v", writes, want)
+	}
+	if pg, _ := s.Object("/orgs/1/sec_policy/draft/services/3"); len(pg["service_ports"].([]interface{})) != 2 {
+		t.Errorf("postgresql service ports are %v, want 5432 and 5433", pg["service_ports"])
+	}
+}
@@ -49,7 +49,7 @@ Create a template based on mutliple rulesets:
 		// Set the template file
 		if len(args) == 0 {
 			fmt.Println("Command requires at least 1 argument for the ruleset name(s) to templatize. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		ruleSetNames = args
 
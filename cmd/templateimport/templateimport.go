@@ -28,8 +28,9 @@ var err error
 
 // TemplateImportCmd runs the template import command
 var TemplateImportCmd = &cobra.Command{
-	Use:   "template-import [template to import]",
-	Short: "Import an Illumio segmentation template.",
+	Use:         "template-import [template to import]",
+	Short:       "Import an Illumio segmentation template.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Import an Illumio segmentation template.
 
@@ -49,7 +50,7 @@ Use template-list command to see available templates.`,
 		// Set the template file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the template name. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		template = args[0]
 
@@ -0,0 +1,64 @@
+package tfexport
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hclWriter builds HCL text with indentation
+type hclWriter struct {
+	sb     strings.Builder
+	indent int
+}
+
+func (w *hclWriter) line(format string, a ...interface{}) {
+	w.sb.WriteString(strings.Repeat("  ", w.indent) + fmt.Sprintf(format, a...) + "\n")
+}
+
+func (w *hclWriter) open(format string, a ...interface{}) {
+	w.line(format+" {", a...)
+	w.indent++
+}
+
+func (w *hclWriter) close() {
+	w.indent--
+	w.line("}")
+}
+
+// attr writes a string attribute. Empty values are skipped.
+func (w *hclWriter) attr(name, value string) {
+	if value != "" {
+		w.line("%s = %s", name, hclString(value))
+	}
+}
+
+// expr writes an attribute with an unquoted expression (e.g., a resource reference or a number)
+func (w *hclWriter) expr(name string, value interface{}) {
+	w.line("%s = %v", name, value)
+}
+
+// hclString quotes a string and escapes HCL template sequences
+func hclString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{").Replace(s)
+	return `"` + s + `"`
+}
+
+var invalidName = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// resourceNames creates unique terraform resource names
+type resourceNames map[string]bool
+
+// name returns a unique resource name from the parts (e.g., label, app, web returns label_app_web)
+func (r resourceNames) name(parts ...string) string {
+	n := strings.Trim(invalidName.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_"), "_")
+	if n == "" || (n[0] >= '0' && n[0] <= '9') {
+		n = "r_" + n
+	}
+	unique := n
+	for i := 2; r[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", n, i)
+	}
+	r[unique] = true
+	return unique
+}
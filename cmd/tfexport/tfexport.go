@@ -0,0 +1,377 @@
+package tfexport
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+// Declare local global variables
+var pce illumioapi.PCE
+var err error
+var objects, importStyle, outputFileName string
+
+func init() {
+	TFExportCmd.Flags().StringVar(&objects, "objects", "labels,iplists,services,rulesets", "comma-separated list of objects to export. options are labels, iplists, services, and rulesets.")
+	TFExportCmd.Flags().StringVar(&importStyle, "import-style", "blocks", "how to import existing objects into terraform state. blocks writes import blocks (terraform 1.5+), commands writes a script of terraform import commands, and none skips imports.")
+	TFExportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	TFExportCmd.Flags().SortFlags = false
+}
+
+// TFExportCmd runs the tf-export command
+var TFExportCmd = &cobra.Command{
+	Use:   "tf-export",
+	Short: "Create Terraform configuration for the illumio-core provider from PCE labels, IP lists, services, and rulesets.",
+	Long: `
+Create Terraform configuration for the illumio-core provider from PCE labels, IP lists, services, and rulesets.
+
+Draft policy objects are exported. Each object is a resource with an import using its href so terraform plan shows no changes after the import. Rulesets are illumio-core_rule_set resources and their rules are illumio-core_security_rule resources.
+
+References to exported objects (e.g., a label in a ruleset scope) use the resource's href attribute so terraform manages the dependency. References to objects not exported (label groups, workloads, virtual services, and the default "Any" ip list and "All Services" service) use the href.
+
+The PCE host and org are written to the provider block. The api key is read from the ILLUMIO_API_KEY_USERNAME and ILLUMIO_API_KEY_SECRET environment variables.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if importStyle != "blocks" && importStyle != "commands" && importStyle != "none" {
+			utils.LogError("--import-style must be blocks, commands, or none")
+		}
+
+		tfExport()
+	},
+}
+
+// exporter holds the terraform output and the href to resource reference map
+type exporter struct {
+	hcl     hclWriter
+	imports []string
+	names   resourceNames
+	refs    map[string]string
+}
+
+// resource starts a resource block and records the import
+func (e *exporter) resource(resourceType, name, href string) {
+	e.hcl.open(`resource "%s" "%s"`, resourceType, name)
+	e.refs[href] = fmt.Sprintf("%s.%s.href", resourceType, name)
+	switch importStyle {
+	case "blocks":
+		e.imports = append(e.imports, fmt.Sprintf("import {\n  to = %s.%s\n  id = %s\n}\n", resourceType, name, hclString(href)))
+	case "commands":
+		e.imports = append(e.imports, fmt.Sprintf("terraform import '%s.%s' '%s'", resourceType, name, href))
+	}
+}
+
+// href writes an href attribute using the resource reference if the object is exported
+func (e *exporter) href(href string) {
+	if ref, ok := e.refs[href]; ok {
+		e.hcl.expr("href", ref)
+		return
+	}
+	e.hcl.attr("href", href)
+}
+
+func tfExport() {
+
+	// Log command execution
+	utils.LogStartCommand("tf-export")
+
+	objectMap := make(map[string]bool)
+	for _, o := range strings.Split(strings.ReplaceAll(objects, " ", ""), ",") {
+		if o != "labels" && o != "iplists" && o != "services" && o != "rulesets" {
+			utils.LogError(fmt.Sprintf("%s is not a valid object", o))
+		}
+		objectMap[o] = true
+	}
+
+	e := exporter{names: make(resourceNames), refs: make(map[string]string)}
+
+	// Terraform and provider blocks
+	e.hcl.open("terraform")
+	e.hcl.open("required_providers")
+	e.hcl.open("illumio-core =")
+	e.hcl.attr("source", "illumio/illumio-core")
+	e.hcl.close()
+	e.hcl.close()
+	e.hcl.close()
+	e.hcl.line("")
+	e.hcl.open(`provider "illumio-core"`)
+	e.hcl.attr("pce_host", fmt.Sprintf("https://%s:%d", utils.PCEFQDN(pce.FriendlyName), utils.PCEPort(pce.FriendlyName)))
+	e.hcl.expr("org_id", pce.Org)
+	e.hcl.close()
+	e.hcl.line("")
+
+	counts := make(map[string]int)
+
+	// Labels
+	if objectMap["labels"] {
+		labels, api, err := pce.GetLabels(nil)
+		utils.LogAPIResp("GetLabels", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, l := range labels {
+			if l.Deleted {
+				continue
+			}
+			e.resource("illumio-core_label", e.names.name("label", l.Key, l.Value), l.Href)
+			e.hcl.attr("key", l.Key)
+			e.hcl.attr("value", l.Value)
+			e.hcl.attr("external_data_set", l.ExternalDataSet)
+			e.hcl.attr("external_data_reference", l.ExternalDataReference)
+			e.hcl.close()
+			e.hcl.line("")
+			counts["labels"]++
+		}
+	}
+
+	// IP lists
+	if objectMap["iplists"] {
+		ipls, api, err := pce.GetIPLists(nil, "draft")
+		utils.LogAPIResp("GetIPLists", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, ipl := range ipls {
+			if ipl.Name == "Any (0.0.0.0/0 and ::/0)" {
+				continue
+			}
+			e.resource("illumio-core_ip_list", e.names.name("ipl", ipl.Name), ipl.Href)
+			e.hcl.attr("name", ipl.Name)
+			e.hcl.attr("description", ipl.Description)
+			e.hcl.attr("external_data_set", ipl.ExternalDataSet)
+			e.hcl.attr("external_data_reference", ipl.ExternalDataReference)
+			if ipl.IPRanges != nil {
+				for _, r := range *ipl.IPRanges {
+					e.hcl.open("ip_ranges")
+					e.hcl.attr("from_ip", r.FromIP)
+					e.hcl.attr("to_ip", r.ToIP)
+					e.hcl.attr("description", r.Description)
+					e.hcl.expr("exclusion", r.Exclusion)
+					e.hcl.close()
+				}
+			}
+			if ipl.FQDNs != nil {
+				for _, f := range *ipl.FQDNs {
+					e.hcl.open("fqdns")
+					e.hcl.attr("fqdn", f.FQDN)
+					e.hcl.close()
+				}
+			}
+			e.hcl.close()
+			e.hcl.line("")
+			counts["ip lists"]++
+		}
+	}
+
+	// Services
+	if objectMap["services"] {
+		services, api, err := pce.GetServices(nil, "draft")
+		utils.LogAPIResp("GetServices", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, s := range services {
+			if s.Name == "All Services" {
+				continue
+			}
+			e.resource("illumio-core_service", e.names.name("svc", s.Name), s.Href)
+			e.hcl.attr("name", s.Name)
+			e.hcl.attr("description", s.Description)
+			e.hcl.attr("process_name", s.ProcessName)
+			e.hcl.attr("external_data_set", s.ExternalDataSet)
+			e.hcl.attr("external_data_reference", s.ExternalDataReference)
+			for _, sp := range s.ServicePorts {
+				e.hcl.open("service_ports")
+				e.ports(sp.Protocol, sp.Port, sp.ToPort, sp.IcmpType, sp.IcmpCode)
+				e.hcl.close()
+			}
+			for _, ws := range s.WindowsServices {
+				e.hcl.open("windows_services")
+				e.ports(ws.Protocol, ws.Port, ws.ToPort, ws.IcmpType, ws.IcmpCode)
+				e.hcl.attr("process_name", ws.ProcessName)
+				e.hcl.attr("service_name", ws.ServiceName)
+				e.hcl.close()
+			}
+			e.hcl.close()
+			e.hcl.line("")
+			counts["services"]++
+		}
+	}
+
+	// Rulesets and rules
+	if objectMap["rulesets"] {
+		rulesets, api, err := pce.GetRulesets(nil, "draft")
+		utils.LogAPIResp("GetRulesets", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		for _, rs := range rulesets {
+			rsName := e.names.name("rs", rs.Name)
+			e.resource("illumio-core_rule_set", rsName, rs.Href)
+			e.hcl.attr("name", rs.Name)
+			e.hcl.attr("description", rs.Description)
+			if rs.Enabled != nil {
+				e.hcl.expr("enabled", *rs.Enabled)
+			}
+			e.hcl.attr("external_data_set", rs.ExternalDataSet)
+			e.hcl.attr("external_data_reference", rs.ExternalDataReference)
+			for _, scope := range rs.Scopes {
+				e.hcl.open("scopes")
+				for _, s := range scope {
+					if s.Label != nil {
+						e.hcl.open("label")
+						e.href(s.Label.Href)
+						e.hcl.close()
+					}
+					if s.LabelGroup != nil {
+						e.hcl.open("label_group")
+						e.href(s.LabelGroup.Href)
+						e.hcl.close()
+					}
+				}
+				e.hcl.close()
+			}
+			e.hcl.close()
+			e.hcl.line("")
+			counts["rulesets"]++
+
+			for i, r := range rs.Rules {
+				e.resource("illumio-core_security_rule", e.names.name(rsName, "rule", fmt.Sprintf("%d", i+1)), r.Href)
+				e.hcl.expr("rule_set_href", fmt.Sprintf("illumio-core_rule_set.%s.href", rsName))
+				e.hcl.attr("description", r.Description)
+				if r.Enabled != nil {
+					e.hcl.expr("enabled", *r.Enabled)
+				}
+				for _, b := range []struct {
+					name string
+					val  *bool
+				}{{"unscoped_consumers", r.UnscopedConsumers}, {"sec_connect", r.SecConnect}, {"stateless", r.Stateless}, {"machine_auth", r.MachineAuth}} {
+					if b.val != nil {
+						e.hcl.expr(b.name, *b.val)
+					}
+				}
+				e.hcl.attr("external_data_set", r.ExternalDataSet)
+				e.hcl.attr("external_data_reference", r.ExternalDataReference)
+				if r.ResolveLabelsAs != nil {
+					e.hcl.open("resolve_labels_as")
+					e.hcl.expr("providers", hclList(r.ResolveLabelsAs.Providers))
+					e.hcl.expr("consumers", hclList(r.ResolveLabelsAs.Consumers))
+					e.hcl.close()
+				}
+				for _, p := range r.Providers {
+					e.actor("providers", p.Actors, p.Label, p.LabelGroup, p.IPList, p.Workload, p.VirtualService)
+				}
+				for _, c := range r.Consumers {
+					e.actor("consumers", c.Actors, c.Label, c.LabelGroup, c.IPList, c.Workload, c.VirtualService)
+				}
+				if r.IngressServices != nil {
+					for _, s := range *r.IngressServices {
+						e.hcl.open("ingress_services")
+						if s.Href != nil {
+							e.href(*s.Href)
+						} else {
+							var proto, port, toPort int
+							if s.Protocol != nil {
+								proto = *s.Protocol
+							}
+							if s.Port != nil {
+								port = *s.Port
+							}
+							if s.ToPort != nil {
+								toPort = *s.ToPort
+							}
+							e.ports(proto, port, toPort, 0, 0)
+						}
+						e.hcl.close()
+					}
+				}
+				e.hcl.close()
+				e.hcl.line("")
+				counts["rules"]++
+			}
+		}
+	}
+
+	// Write the output files
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-tf-export-%s.tf", time.Now().Format("20060102_150405"))
+	}
+	tf := e.hcl.sb.String()
+	if importStyle == "blocks" {
+		tf = tf + strings.Join(e.imports, "\n")
+	}
+	if err := os.WriteFile(outputFileName, []byte(tf), 0644); err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("exported %d labels, %d ip lists, %d services, %d rulesets, and %d rules to %s", counts["labels"], counts["ip lists"], counts["services"], counts["rulesets"], counts["rules"], outputFileName), true)
+
+	if importStyle == "commands" {
+		importFile := strings.TrimSuffix(outputFileName, ".tf") + "-import.sh"
+		if err := os.WriteFile(importFile, []byte("#!/bin/sh\n"+strings.Join(e.imports, "\n")+"\n"), 0755); err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("terraform import commands written to %s", importFile), true)
+	}
+
+	utils.LogEndCommand("tf-export")
+}
+
+// ports writes protocol and port attributes. Zero values are skipped.
+func (e *exporter) ports(proto, port, toPort, icmpType, icmpCode int) {
+	for _, a := range []struct {
+		name string
+		val  int
+	}{{"proto", proto}, {"port", port}, {"to_port", toPort}, {"icmp_type", icmpType}, {"icmp_code", icmpCode}} {
+		if a.val != 0 {
+			e.hcl.expr(a.name, a.val)
+		}
+	}
+}
+
+// actor writes a provider or consumer block
+func (e *exporter) actor(block, actors string, label *illumioapi.Label, labelGroup *illumioapi.LabelGroup, ipList *illumioapi.IPList, wkld *illumioapi.Workload, vs *illumioapi.VirtualService) {
+	e.hcl.open(block)
+	e.hcl.attr("actors", actors)
+	hrefs := [][]string{}
+	if label != nil {
+		hrefs = append(hrefs, []string{"label", label.Href})
+	}
+	if labelGroup != nil {
+		hrefs = append(hrefs, []string{"label_group", labelGroup.Href})
+	}
+	if ipList != nil {
+		hrefs = append(hrefs, []string{"ip_list", ipList.Href})
+	}
+	if wkld != nil {
+		hrefs = append(hrefs, []string{"workload", wkld.Href})
+	}
+	if vs != nil {
+		hrefs = append(hrefs, []string{"virtual_service", vs.Href})
+	}
+	for _, h := range hrefs {
+		e.hcl.open(h[0])
+		e.href(h[1])
+		e.hcl.close()
+	}
+	e.hcl.close()
+}
+
+// hclList returns a list of strings in HCL
+func hclList(values []string) string {
+	quoted := []string{}
+	for _, v := range values {
+		quoted = append(quoted, hclString(v))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,174 @@
+package trafficanomaly
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// Severities by rank
+var severityRank = map[string]int{"low": 0, "medium": 1, "high": 2}
+
+// anomaly is a flow that is not in the baseline
+type anomaly struct {
+	f       *flow
+	score   int
+	reasons []string
+}
+
+// severity returns the severity of the score
+func (a anomaly) severity() string {
+	if a.score >= 6 {
+		return "high"
+	}
+	if a.score >= 3 {
+		return "medium"
+	}
+	return "low"
+}
+
+// score returns the anomaly of a flow or false if the flow is in the baseline
+func score(b baseline, f *flow) (anomaly, bool) {
+	src, dst := b.AppGroups[f.src], b.AppGroups[f.dst]
+	if f.dstType == peerAppGroup && dst != nil && dst.Inbound[f.src][f.service] > 0 {
+		return anomaly{}, false
+	}
+	if f.dstType != peerAppGroup && src != nil && src.Outbound[f.dst][f.service] > 0 {
+		return anomaly{}, false
+	}
+
+	a := anomaly{f: f}
+	reason := func(r string, points int) {
+		a.reasons = append(a.reasons, r)
+		a.score += points
+	}
+
+	// New port
+	if f.dstType == peerAppGroup && !hasService(dst, true, f.service) {
+		reason("new_port", 3)
+	}
+	if f.dstType != peerAppGroup && f.srcType == peerAppGroup && !hasService(src, false, f.service) {
+		reason("new_outbound_port", 2)
+	}
+
+	// New peer
+	known := false
+	if f.dstType == peerAppGroup && dst != nil {
+		known = len(dst.Inbound[f.src]) > 0
+	} else if src != nil {
+		known = len(src.Outbound[f.dst]) > 0
+	}
+	if !known {
+		reason("new_peer", 2)
+	}
+
+	// New app group
+	if (f.srcType == peerAppGroup && src == nil) || (f.dstType == peerAppGroup && dst == nil) {
+		reason("new_app_group", 1)
+	}
+
+	// Peer type
+	if f.srcType == peerIP || f.dstType == peerIP {
+		reason("ip_peer", 2)
+	} else if f.srcType == peerIPList || f.dstType == peerIPList {
+		reason("ip_list_peer", 1)
+	}
+
+	if sensitive[f.port] {
+		reason("sensitive_port", 2)
+	}
+	if f.decisions["allowed"]+f.decisions["potentially_blocked"] > 0 {
+		reason("not_blocked", 1)
+	}
+	return a, true
+}
+
+// hasService returns true if the app group has inbound or outbound traffic on the service with any peer
+func hasService(ag *appGroup, inbound bool, service string) bool {
+	if ag == nil {
+		return false
+	}
+	peers := ag.Outbound
+	if inbound {
+		peers = ag.Inbound
+	}
+	for _, services := range peers {
+		if services[service] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func trafficAnomaly() {
+	b, err := readBaseline(baselineFile)
+	if err != nil {
+		utils.LogErrorCode(utils.ExitValidation, err.Error())
+	}
+	if b.PCE != "" && b.PCE != pce.FriendlyName {
+		utils.LogWarning(fmt.Sprintf("the baseline is from %s, not %s", b.PCE, pce.FriendlyName), true)
+	}
+	utils.LogInfo(fmt.Sprintf("baseline of %d app groups from %s to %s", len(b.AppGroups), b.Start.Format("2006-01-02"), b.End.Format("2006-01-02")), true)
+
+	loadLabels()
+	flows := getFlows(days, b.AppGroupLoc)
+
+	// Score the flows that are not in the baseline
+	anomalies := []anomaly{}
+	counts := make(map[string]int)
+	for _, f := range flows {
+		a, ok := score(b, f)
+		if !ok || severityRank[a.severity()] < severityRank[strings.ToLower(minSeverity)] {
+			continue
+		}
+		anomalies = append(anomalies, a)
+		counts[a.severity()]++
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].score != anomalies[j].score {
+			return anomalies[i].score > anomalies[j].score
+		}
+		if anomalies[i].f.dst != anomalies[j].f.dst {
+			return anomalies[i].f.dst < anomalies[j].f.dst
+		}
+		if anomalies[i].f.src != anomalies[j].f.src {
+			return anomalies[i].f.src < anomalies[j].f.src
+		}
+		return anomalies[i].f.service < anomalies[j].f.service
+	})
+
+	data := [][]string{{"severity", "score", "src", "src_type", "dst", "dst_type", "service", "flows", "connections", "policy_decisions", "last_seen", "reasons"}}
+	for _, a := range anomalies {
+		decisions := []string{}
+		for d, n := range a.f.decisions {
+			decisions = append(decisions, fmt.Sprintf("%s:%d", d, n))
+		}
+		sort.Strings(decisions)
+		data = append(data, []string{a.severity(), strconv.Itoa(a.score), a.f.src, a.f.srcType, a.f.dst, a.f.dstType, a.f.service, strconv.Itoa(a.f.flows), strconv.Itoa(a.f.connections), strings.Join(decisions, ";"), a.f.lastSeen, strings.Join(a.reasons, ";")})
+		if a.severity() == "high" {
+			utils.LogWarning(fmt.Sprintf("high severity anomaly - %s to %s on %s - %s", a.f.src, a.f.dst, a.f.service, strings.Join(a.reasons, ";")), false)
+		}
+	}
+	utils.LogInfo(fmt.Sprintf("%d anomalies - %d high, %d medium, and %d low", len(anomalies), counts["high"], counts["medium"], counts["low"]), true)
+
+	if len(anomalies) > 0 {
+		if outputFileName == "" {
+			outputFileName = fmt.Sprintf("workloader-traffic-anomaly-%s.csv", time.Now().Format("20060102_150405"))
+		}
+		utils.WriteOutput(data, data, outputFileName)
+	}
+
+	// Add the flows to the baseline
+	if updateBaseline {
+		for _, f := range flows {
+			b.add(f)
+		}
+		b.End = time.Now()
+		writeBaseline(b, baselineFile)
+		utils.LogInfo(fmt.Sprintf("%d flows added to %s", len(flows), baselineFile), true)
+	}
+}
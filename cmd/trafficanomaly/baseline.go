@@ -0,0 +1,194 @@
+package trafficanomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Peer types
+const (
+	peerAppGroup = "app_group"
+	peerIP       = "ip"
+	peerIPList   = "ip_list"
+)
+
+// baseline is the traffic of each app group in the training window
+type baseline struct {
+	PCE         string               `json:"pce"`
+	Created     time.Time            `json:"created"`
+	Start       time.Time            `json:"start"`
+	End         time.Time            `json:"end"`
+	AppGroupLoc bool                 `json:"appgrp_loc"`
+	AppGroups   map[string]*appGroup `json:"app_groups"`
+}
+
+// appGroup is the inbound and outbound traffic of an app group. Inbound and outbound are keyed by peer and then service with the connections.
+type appGroup struct {
+	Inbound  map[string]map[string]int `json:"inbound"`
+	Outbound map[string]map[string]int `json:"outbound"`
+}
+
+// flow is the traffic between a source and destination on a service
+type flow struct {
+	src, dst, srcType, dstType, service string
+	port                                int
+	flows, connections                  int
+	decisions                           map[string]int
+	lastSeen                            string
+}
+
+// appGroup returns the app group or creates it
+func (b *baseline) appGroup(name string) *appGroup {
+	ag, ok := b.AppGroups[name]
+	if !ok {
+		ag = &appGroup{Inbound: make(map[string]map[string]int), Outbound: make(map[string]map[string]int)}
+		b.AppGroups[name] = ag
+	}
+	return ag
+}
+
+// add adds a flow to the baseline
+func (b *baseline) add(f *flow) {
+	if f.srcType == peerAppGroup {
+		ag := b.appGroup(f.src)
+		if ag.Outbound[f.dst] == nil {
+			ag.Outbound[f.dst] = make(map[string]int)
+		}
+		ag.Outbound[f.dst][f.service] += f.connections
+	}
+	if f.dstType == peerAppGroup {
+		ag := b.appGroup(f.dst)
+		if ag.Inbound[f.src] == nil {
+			ag.Inbound[f.src] = make(map[string]int)
+		}
+		ag.Inbound[f.src][f.service] += f.connections
+	}
+}
+
+// getFlows returns the traffic of the last number of days by source, destination, and service
+func getFlows(days int, groupLoc bool) []*flow {
+	utils.LogInfo(fmt.Sprintf("getting traffic for the last %d days", days), true)
+	pStatus := []string{"allowed", "potentially_blocked"}
+	if !exclBlocked {
+		pStatus = append(pStatus, "blocked")
+	}
+	traffic, a, err := pce.GetTrafficAnalysis(illumioapi.TrafficQuery{
+		StartTime:                       time.Now().AddDate(0, 0, -days),
+		EndTime:                         time.Now(),
+		PolicyStatuses:                  pStatus,
+		MaxFLows:                        maxResults,
+		TransmissionExcludes:            []string{"broadcast", "multicast"},
+		ExcludeWorkloadsFromIPListQuery: true})
+	utils.LogAPIResp("GetTrafficAnalysis", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d flows", len(traffic)), true)
+	if len(traffic) >= maxResults {
+		utils.LogWarning(fmt.Sprintf("the traffic query reached the maximum of %d results. the results may be incomplete.", maxResults), true)
+	}
+
+	flows := []*flow{}
+	flowMap := make(map[[3]string]*flow)
+	protocols := illumioapi.ProtocolList()
+	for _, t := range traffic {
+		if t.Src == nil || t.Dst == nil || t.ExpSrv == nil {
+			continue
+		}
+		src, srcType := peer(t.Src.IP, t.Src.Workload, t.Src.IPLists, groupLoc)
+		dst, dstType := peer(t.Dst.IP, t.Dst.Workload, t.Dst.IPLists, groupLoc)
+		service := protocols[t.ExpSrv.Proto]
+		if t.ExpSrv.Port != 0 {
+			service = fmt.Sprintf("%d %s", t.ExpSrv.Port, service)
+		}
+		f, ok := flowMap[[3]string{src, dst, service}]
+		if !ok {
+			f = &flow{src: src, dst: dst, srcType: srcType, dstType: dstType, service: service, port: t.ExpSrv.Port, decisions: make(map[string]int)}
+			flowMap[[3]string{src, dst, service}] = f
+			flows = append(flows, f)
+		}
+		f.flows++
+		f.connections += t.NumConnections
+		f.decisions[t.PolicyDecision]++
+		if t.TimestampRange != nil && t.TimestampRange.LastDetected > f.lastSeen {
+			f.lastSeen = t.TimestampRange.LastDetected
+		}
+	}
+	return flows
+}
+
+// peer returns the app group of a workload or the ip lists or ip address of an ip
+func peer(ip string, w *illumioapi.Workload, lists *[]*illumioapi.IPList, groupLoc bool) (string, string) {
+	if w != nil {
+		if groupLoc {
+			return w.GetAppGroupL(pce.Labels), peerAppGroup
+		}
+		return w.GetAppGroup(pce.Labels), peerAppGroup
+	}
+	if lists != nil {
+		names := []string{}
+		for _, l := range *lists {
+			if l != nil && l.Name != "" {
+				names = append(names, l.Name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			return strings.Join(names, "; "), peerIPList
+		}
+	}
+	return ip, peerIP
+}
+
+// loadLabels loads the labels for the app groups
+func loadLabels() {
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+}
+
+// readBaseline reads a baseline file
+func readBaseline(file string) (baseline, error) {
+	b := baseline{}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return b, fmt.Errorf("reading baseline - %s", err)
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("reading baseline %s - %s", file, err)
+	}
+	if b.AppGroups == nil {
+		b.AppGroups = make(map[string]*appGroup)
+	}
+	return b, nil
+}
+
+// writeBaseline writes a baseline file
+func writeBaseline(b baseline, file string) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		utils.LogError(fmt.Sprintf("creating baseline - %s", err))
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		utils.LogError(fmt.Sprintf("writing baseline - %s", err))
+	}
+}
+
+func trafficBaseline() {
+	loadLabels()
+	b := baseline{PCE: pce.FriendlyName, Created: time.Now(), End: time.Now(), Start: time.Now().AddDate(0, 0, -trainDays), AppGroupLoc: appGroupLoc, AppGroups: make(map[string]*appGroup)}
+	for _, f := range getFlows(trainDays, appGroupLoc) {
+		b.add(f)
+	}
+	writeBaseline(b, baselineFile)
+	utils.LogInfo(fmt.Sprintf("baseline of %d app groups written to %s", len(b.AppGroups), baselineFile), true)
+}
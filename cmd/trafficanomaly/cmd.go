@@ -0,0 +1,125 @@
+package trafficanomaly
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var baselineFile, outputFileName, sensitivePorts, minSeverity string
+var days, trainDays, maxResults int
+var appGroupLoc, exclBlocked, updateBaseline bool
+var sensitive map[int]bool
+
+func init() {
+	TrafficBaselineCmd.Flags().StringVar(&baselineFile, "baseline-file", "workloader-traffic-baseline.json", "local file to write the baseline to.")
+	TrafficBaselineCmd.Flags().IntVar(&trainDays, "days", 30, "days of traffic to train the baseline on.")
+	TrafficBaselineCmd.Flags().BoolVarP(&appGroupLoc, "appgrp-loc", "l", false, "use location in app group.")
+	TrafficBaselineCmd.Flags().BoolVar(&exclBlocked, "excl-blocked", false, "exclude blocked flows.")
+	TrafficBaselineCmd.Flags().IntVar(&maxResults, "max-results", 100000, "max results for the traffic query.")
+	TrafficBaselineCmd.Flags().SortFlags = false
+
+	TrafficAnomalyCmd.Flags().StringVar(&baselineFile, "baseline-file", "workloader-traffic-baseline.json", "baseline file from traffic-baseline.")
+	TrafficAnomalyCmd.Flags().IntVar(&days, "days", 1, "days of traffic to check against the baseline.")
+	TrafficAnomalyCmd.Flags().StringVar(&sensitivePorts, "sensitive-ports", "22,23,135,139,445,1433,3306,3389,5432,5985,5986", "comma-separated ports that add to the score of new flows.")
+	TrafficAnomalyCmd.Flags().StringVar(&minSeverity, "min-severity", "low", "minimum severity to report. options are low, medium, and high.")
+	TrafficAnomalyCmd.Flags().BoolVar(&exclBlocked, "excl-blocked", false, "exclude blocked flows.")
+	TrafficAnomalyCmd.Flags().BoolVar(&updateBaseline, "update-baseline", false, "add the flows in the window to the baseline after reporting so they are not reported again.")
+	TrafficAnomalyCmd.Flags().IntVar(&maxResults, "max-results", 100000, "max results for the traffic query.")
+	TrafficAnomalyCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	TrafficAnomalyCmd.Flags().SortFlags = false
+}
+
+// TrafficBaselineCmd builds a traffic baseline of each app group
+var TrafficBaselineCmd = &cobra.Command{
+	Use:   "traffic-baseline",
+	Short: "Build a local baseline of the peers and ports of each app group for traffic-anomaly.",
+	Long: `
+Build a local baseline of the peers and ports of each app group for traffic-anomaly.
+
+The baseline has the sources, destinations, and services of each app group (app | env, or app | env | loc with --appgrp-loc) from the last --days days of traffic. Peers that are not workloads are their ip lists or their ip address if they are not in an ip list. The baseline is a json file written to --baseline-file. It is not written to the output directory because traffic-anomaly reads it.
+
+Train the baseline on a window with normal traffic. Rebuild it or use traffic-anomaly --update-baseline as the environment changes.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Train a baseline on the last 30 days
+  workloader traffic-baseline --baseline-file baseline.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if trainDays <= 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--days must be positive")
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("traffic-baseline")
+		trafficBaseline()
+		utils.LogEndCommand("traffic-baseline")
+	},
+}
+
+// TrafficAnomalyCmd reports traffic that is not in the baseline
+var TrafficAnomalyCmd = &cobra.Command{
+	Use:   "traffic-anomaly",
+	Short: "Report new peers and ports of app groups compared to a traffic-baseline.",
+	Long: `
+Report new peers and ports of app groups compared to a traffic-baseline.
+
+Each source, destination, and service in the last --days days of traffic that is not in the baseline is an anomaly. The app groups use the --appgrp-loc setting of the baseline. Each anomaly is scored:
+- new_port (3): the destination app group has not received traffic on the service.
+- new_outbound_port (2): the destination is not a workload and the source app group has not sent traffic on the service.
+- new_peer (2): the source and destination have not communicated on any service.
+- new_app_group (1): the source or destination app group is not in the baseline.
+- ip_peer (2): the source or destination is an ip address that is not in an ip list. ip_list_peer (1): the source or destination is an ip list.
+- sensitive_port (2): the service is one of the --sensitive-ports.
+- not_blocked (1): the flow was allowed or potentially blocked.
+
+The severity is high for a score of 6 or more, medium for 3 to 5, and low for less than 3. The output is sorted by score. Each high severity anomaly is logged as a warning.
+
+Use --update-baseline to add the flows to the baseline after reporting so each anomaly is only reported once.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Report anomalies in the last day
+  workloader traffic-anomaly --baseline-file baseline.json
+
+  # Report medium and high anomalies in the last 7 days and add them to the baseline
+  workloader traffic-anomaly --baseline-file baseline.json --days 7 --min-severity medium --update-baseline`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if days <= 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--days must be positive")
+		}
+		if _, ok := severityRank[strings.ToLower(minSeverity)]; !ok {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a valid --min-severity. options are low, medium, and high", minSeverity))
+		}
+		sensitive = make(map[int]bool)
+		for _, p := range strings.Split(sensitivePorts, ",") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s is not a valid port in --sensitive-ports", p))
+			}
+			sensitive[port] = true
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("traffic-anomaly")
+		trafficAnomaly()
+		utils.LogEndCommand("traffic-anomaly")
+	},
+}
@@ -0,0 +1,241 @@
+package umwlcleanup
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// scanHost is an ip address from an nmap xml or ping sweep csv with its open ports (e.g., 443/tcp)
+type scanHost struct {
+	up    bool
+	ports []string
+}
+
+// nmapRun is the part of nmap xml output used for reconciliation
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   string `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// parseScanFile parses nmap xml (.xml) or a ping sweep csv into hosts by ip address.
+// The csv requires an ip header and can have a status header (up, alive, down, etc.) and a ports header (e.g., 22;443/tcp;53/udp).
+// Rows without a status are up.
+func parseScanFile(filename string) (map[string]scanHost, error) {
+	hosts := make(map[string]scanHost)
+	if strings.ToLower(filepath.Ext(filename)) == ".xml" {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var run nmapRun
+		if err := xml.Unmarshal(b, &run); err != nil {
+			return nil, fmt.Errorf("parsing nmap xml %s - %s", filename, err)
+		}
+		for _, h := range run.Hosts {
+			host := scanHost{up: h.Status.State == "up"}
+			for _, p := range h.Ports {
+				if p.State.State == "open" {
+					host.ports = append(host.ports, fmt.Sprintf("%s/%s", p.PortID, p.Protocol))
+				}
+			}
+			sort.Strings(host.ports)
+			for _, a := range h.Addresses {
+				if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+					hosts[a.Addr] = host
+				}
+			}
+		}
+		return hosts, nil
+	}
+
+	data, err := utils.ParseCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return hosts, nil
+	}
+	cols := map[string]int{"ip": -1, "status": -1, "ports": -1}
+	for i, h := range data[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "ip_address" || h == "address" {
+			h = "ip"
+		}
+		if h == "state" {
+			h = "status"
+		}
+		if _, ok := cols[h]; ok {
+			cols[h] = i
+		}
+	}
+	if cols["ip"] == -1 {
+		return nil, fmt.Errorf("%s requires an ip header", filename)
+	}
+	for i, row := range data[1:] {
+		ip := strings.TrimSpace(row[cols["ip"]])
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("%s line %d - %s is not a valid ip address", filename, i+2, ip)
+		}
+		host := scanHost{up: true}
+		if cols["status"] != -1 {
+			switch strings.ToLower(strings.TrimSpace(row[cols["status"]])) {
+			case "up", "alive", "true", "yes", "responding", "open":
+			default:
+				host.up = false
+			}
+		}
+		if cols["ports"] != -1 {
+			for _, p := range strings.FieldsFunc(row[cols["ports"]], func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+				if !strings.Contains(p, "/") {
+					p = p + "/tcp"
+				}
+				host.ports = append(host.ports, strings.ToLower(p))
+			}
+			sort.Strings(host.ports)
+		}
+		hosts[ip] = host
+	}
+	return hosts, nil
+}
+
+// diffPorts returns the ports in current that are not in baseline and the ports in baseline that are not in current
+func diffPorts(baseline, current []string) (added, removed []string) {
+	inBaseline, inCurrent := make(map[string]bool), make(map[string]bool)
+	for _, p := range baseline {
+		inBaseline[p] = true
+	}
+	for _, p := range current {
+		inCurrent[p] = true
+		if !inBaseline[p] {
+			added = append(added, p)
+		}
+	}
+	for _, p := range baseline {
+		if !inCurrent[p] {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// scanReconcile flags unmanaged workloads whose ip addresses no longer respond in the scan file or whose open ports changed from the baseline scan file.
+// It writes a csv of all unmanaged workloads and the optional quarantine and delete csvs of the flagged unmanaged workloads.
+func scanReconcile(wklds []illumioapi.Workload) {
+	scan, err := parseScanFile(scanFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d hosts in %s", len(scan), scanFile), true)
+	baseline := make(map[string]scanHost)
+	if baselineScanFile != "" {
+		if baseline, err = parseScanFile(baselineScanFile); err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("%d hosts in %s", len(baseline), baselineScanFile), true)
+	}
+
+	data := [][]string{{"umwl_hostname", "umwl_name", "umwl_href", "umwl_interfaces", "responding_ips", "not_responding_ips", "not_scanned_ips", "open_ports", "added_ports", "removed_ports", "status", "flagged", "role", "app", "env", "loc"}}
+	quarantineData := [][]string{{"href", quarantineKey}}
+	deleteData := [][]string{{"href"}}
+	flagged := 0
+	for _, w := range wklds {
+		if w.GetMode() != "unmanaged" || len(w.Interfaces) == 0 {
+			continue
+		}
+		interfaces, responding, notResponding, notScanned, openPorts, added, removed := []string{}, []string{}, []string{}, []string{}, []string{}, []string{}, []string{}
+		for _, i := range w.Interfaces {
+			interfaces = append(interfaces, fmt.Sprintf("%s:%s", i.Name, i.Address))
+			host, ok := scan[i.Address]
+			switch {
+			case !ok && !unlistedDown:
+				notScanned = append(notScanned, i.Address)
+				continue
+			case !ok || !host.up:
+				notResponding = append(notResponding, i.Address)
+				continue
+			}
+			responding = append(responding, i.Address)
+			for _, p := range host.ports {
+				openPorts = append(openPorts, fmt.Sprintf("%s:%s", i.Address, p))
+			}
+			if b, ok := baseline[i.Address]; ok && b.up {
+				a, r := diffPorts(b.ports, host.ports)
+				for _, p := range a {
+					added = append(added, fmt.Sprintf("%s:%s", i.Address, p))
+				}
+				for _, p := range r {
+					removed = append(removed, fmt.Sprintf("%s:%s", i.Address, p))
+				}
+			}
+		}
+
+		// Set the status. Unmanaged workloads are not responding when none of the scanned ips respond.
+		status := "ok"
+		switch {
+		case len(responding) == 0 && len(notResponding) == 0:
+			status = "not_scanned"
+		case len(responding) == 0:
+			status = "not_responding"
+		case len(added) > 0 || len(removed) > 0:
+			status = "ports_changed"
+		case len(notResponding) > 0:
+			status = "partially_responding"
+		}
+		isFlagged := status == "not_responding" || status == "ports_changed"
+		if isFlagged {
+			flagged++
+			if quarantineValue != "" {
+				quarantineData = append(quarantineData, []string{w.Href, quarantineValue})
+			}
+			if deleteOutput && status == "not_responding" {
+				deleteData = append(deleteData, []string{w.Href})
+			}
+		}
+		data = append(data, []string{w.Hostname, w.Name, w.Href, strings.Join(interfaces, ";"), strings.Join(responding, ";"), strings.Join(notResponding, ";"), strings.Join(notScanned, ";"), strings.Join(openPorts, ";"), strings.Join(added, ";"), strings.Join(removed, ";"), status, strconv.FormatBool(isFlagged), w.GetRole(pce.Labels).Value, w.GetApp(pce.Labels).Value, w.GetEnv(pce.Labels).Value, w.GetLoc(pce.Labels).Value})
+	}
+
+	// Write the output
+	if len(data) == 1 {
+		utils.LogInfo("no unmanaged workloads with interfaces", true)
+		return
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-umwl-cleanup-scan-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.LogInfo(fmt.Sprintf("%d of %d unmanaged workloads flagged", flagged, len(data)-1), true)
+	utils.WriteOutput(data, data, outputFileName)
+	if len(quarantineData) > 1 {
+		quarantineFile := fmt.Sprintf("%s-quarantine.csv", strings.TrimSuffix(outputFileName, ".csv"))
+		utils.WriteOutput(quarantineData, quarantineData, quarantineFile)
+		utils.LogInfo(fmt.Sprintf("use workloader wkld-import %s to label the flagged unmanaged workloads", quarantineFile), true)
+	}
+	if len(deleteData) > 1 {
+		deleteFile := fmt.Sprintf("%s-delete.csv", strings.TrimSuffix(outputFileName, ".csv"))
+		utils.WriteOutput(deleteData, deleteData, deleteFile)
+		utils.LogInfo(fmt.Sprintf("use workloader delete %s to delete the unmanaged workloads that are not responding", deleteFile), true)
+	}
+}
@@ -14,12 +14,19 @@ import (
 // Declare local global variables
 var pce illumioapi.PCE
 var err error
-var oneInterfaceMatch bool
-var outputFileName string
+var oneInterfaceMatch, unlistedDown, deleteOutput bool
+var outputFileName, scanFile, baselineScanFile, quarantineKey, quarantineValue string
 
 func init() {
 	UMWLCleanUpCmd.Flags().BoolVar(&oneInterfaceMatch, "one-interface-match", false, "consider a match if at least one interface matches. default requires all interfaces to match.")
+	UMWLCleanUpCmd.Flags().StringVar(&scanFile, "scan-file", "", "nmap xml (.xml) or ping sweep csv to flag unmanaged workloads that no longer respond. see description for csv format.")
+	UMWLCleanUpCmd.Flags().StringVar(&baselineScanFile, "baseline-scan-file", "", "earlier nmap xml or ping sweep csv to flag unmanaged workloads whose open ports changed. requires --scan-file.")
+	UMWLCleanUpCmd.Flags().BoolVar(&unlistedDown, "unlisted-down", false, "treat unmanaged workload ips that are not in the scan file as not responding. use when the scan covered all unmanaged workload ips.")
+	UMWLCleanUpCmd.Flags().StringVar(&quarantineKey, "quarantine-key", "env", "label key for the quarantine csv.")
+	UMWLCleanUpCmd.Flags().StringVar(&quarantineValue, "quarantine-value", "", "label value to write a wkld-import csv that labels the flagged unmanaged workloads. blank does not write the csv.")
+	UMWLCleanUpCmd.Flags().BoolVar(&deleteOutput, "delete-output", false, "write a delete csv of the flagged unmanaged workloads that are not responding.")
 	UMWLCleanUpCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	UMWLCleanUpCmd.Flags().SortFlags = false
 
 }
 
@@ -36,7 +43,15 @@ The unmanaged workload IP address is compared to managed workload's NIC with the
 
 To label the managed workloads with the same labels on the matched unmanaged workload, the output file can be directly passed into the wkld-import command.
 
-Additionally, the output can be passed into the delete command with the --header flag set to umwl_href to delete the no longer needed unmanaged workloads.`,
+Additionally, the output can be passed into the delete command with the --header flag set to umwl_href to delete the no longer needed unmanaged workloads.
+
+Use --scan-file to reconcile unmanaged workloads with network scan results instead. The scan file is nmap xml output (.xml) or a ping sweep csv with an ip header, an optional status header (up, alive, down, etc.), and an optional ports header (e.g., 22;443/tcp;53/udp). Rows without a status are up. Each unmanaged workload interface is responding, not responding, or not scanned. IPs that are not in the scan file are not scanned unless --unlisted-down is set, so run nmap with -v to include down hosts or use --unlisted-down when the scan covered every unmanaged workload ip.
+
+An unmanaged workload is flagged as not_responding when none of its scanned ips respond. With --baseline-scan-file, an unmanaged workload is flagged as ports_changed when the open ports of a responding ip changed from the baseline scan. The output has a row for every unmanaged workload with its status. Use --quarantine-value to write a wkld-import csv that labels the flagged unmanaged workloads and --delete-output to write a delete csv of the unmanaged workloads that are not responding.
+
+Examples:
+  workloader umwl-cleanup --scan-file nmap-scan.xml --quarantine-value quarantine
+  workloader umwl-cleanup --scan-file sweep.csv --baseline-scan-file last-month.xml --unlisted-down --delete-output`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// Get the PCE
@@ -45,6 +60,10 @@ Additionally, the output can be passed into the delete command with the --header
 			utils.LogError(err.Error())
 		}
 
+		if baselineScanFile != "" && scanFile == "" {
+			utils.LogErrorCode(utils.ExitValidation, "--baseline-scan-file requires --scan-file")
+		}
+
 		umwlCleanUp()
 	},
 }
@@ -61,6 +80,13 @@ func umwlCleanUp() {
 		utils.LogError(err.Error())
 	}
 
+	// Reconcile with the scan file instead of managed workloads
+	if scanFile != "" {
+		scanReconcile(wklds)
+		utils.LogEndCommand("umwl-cleanup")
+		return
+	}
+
 	// Create the maps
 	umwlDefaultIPMap := make(map[string]illumioapi.Workload)
 	managedDefaultIPMap := make(map[string]illumioapi.Workload)
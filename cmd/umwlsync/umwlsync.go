@@ -0,0 +1,194 @@
+package umwlsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Workload is an unmanaged workload discovered in an external source
+type Workload struct {
+	Hostname              string
+	Name                  string
+	Interfaces            []string // Format of wkld-import (e.g., eth0:10.0.0.1)
+	PublicIP              string
+	Description           string
+	DataCenter            string
+	Labels                map[string]string // Label key to label value
+	ExternalDataReference string
+}
+
+// Input is the data structure the Sync function expects
+type Input struct {
+	PCE             illumioapi.PCE
+	Command         string // Used for logging and output file names
+	ExternalDataSet string
+	Workloads       []Workload
+	Cleanup         bool // Delete unmanaged workloads in the external data set that are not in Workloads
+	UpdatePCE       bool
+	NoPrompt        bool
+	OutputFileName  string
+}
+
+// Sync creates, updates, and optionally deletes unmanaged workloads in the PCE so the external data set matches the provided workloads.
+// Creates and updates use wkld-import matching on external_data_set and external_data_reference.
+func Sync(input Input) {
+
+	// Get the unmanaged workloads in the external data set
+	existingUMWLs, api, err := input.PCE.GetWklds(map[string]string{"managed": "false", "external_data_set": input.ExternalDataSet})
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d unmanaged workloads in the %s external data set", len(existingUMWLs), input.ExternalDataSet), true)
+
+	// Get the label keys used
+	labelKeyMap := make(map[string]bool)
+	for _, w := range input.Workloads {
+		for k := range w.Labels {
+			labelKeyMap[k] = true
+		}
+	}
+	labelKeys := []string{}
+	for k := range labelKeyMap {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+
+	// Build the wkld-import data
+	importData := [][]string{append([]string{wkldexport.HeaderHostname, wkldexport.HeaderName, wkldexport.HeaderInterfaces, wkldexport.HeaderPublicIP, wkldexport.HeaderDescription, wkldexport.HeaderDataCenter, wkldexport.HeaderExternalDataSet, wkldexport.HeaderExternalDataReference}, labelKeys...)}
+	discoveredRefs := make(map[string]bool)
+	for _, w := range input.Workloads {
+		if w.ExternalDataReference == "" {
+			utils.LogWarning(fmt.Sprintf("%s does not have an external data reference. skipping.", w.Hostname), true)
+			continue
+		}
+		if discoveredRefs[w.ExternalDataReference] {
+			utils.LogWarning(fmt.Sprintf("%s is a duplicate external data reference. skipping.", w.ExternalDataReference), true)
+			continue
+		}
+		discoveredRefs[w.ExternalDataReference] = true
+		if len(w.Interfaces) == 0 {
+			utils.LogWarning(fmt.Sprintf("%s - %s does not have any ip addresses. skipping.", w.Hostname, w.ExternalDataReference), true)
+			continue
+		}
+		row := []string{w.Hostname, w.Name, strings.Join(w.Interfaces, ";"), w.PublicIP, w.Description, w.DataCenter, input.ExternalDataSet, w.ExternalDataReference}
+		for _, k := range labelKeys {
+			row = append(row, w.Labels[k])
+		}
+		importData = append(importData, row)
+	}
+
+	// Build the delete data
+	deleteData := [][]string{{"href", "hostname", "external_data_set", "external_data_reference"}}
+	if input.Cleanup {
+		for _, w := range existingUMWLs {
+			if utils.PtrToStr(w.ExternalDataSet) != input.ExternalDataSet {
+				continue
+			}
+			if !discoveredRefs[utils.PtrToStr(w.ExternalDataReference)] {
+				utils.LogInfo(fmt.Sprintf("%s - %s - %s no longer exists in source and will be deleted", w.Hostname, w.Href, utils.PtrToStr(w.ExternalDataReference)), false)
+				deleteData = append(deleteData, []string{w.Href, w.Hostname, utils.PtrToStr(w.ExternalDataSet), utils.PtrToStr(w.ExternalDataReference)})
+			}
+		}
+	}
+
+	// Write the output files
+	timeStamp := time.Now().Format("20060102_150405")
+	importFile := fmt.Sprintf("workloader-%s-wkld-import-%s.csv", input.Command, timeStamp)
+	deleteFile := fmt.Sprintf("workloader-%s-delete-%s.csv", input.Command, timeStamp)
+	if input.OutputFileName != "" {
+		importFile = "wkld-import-" + input.OutputFileName
+		deleteFile = "delete-" + input.OutputFileName
+	}
+	if len(importData) > 1 {
+		utils.WriteOutput(importData, importData, importFile)
+	}
+	if len(deleteData) > 1 {
+		utils.WriteOutput(deleteData, deleteData, deleteFile)
+	}
+	utils.LogInfo(fmt.Sprintf("%d workloads discovered to create or update. %d unmanaged workloads to delete.", len(importData)-1, len(deleteData)-1), true)
+
+	if len(importData) == 1 && len(deleteData) == 1 {
+		utils.LogInfo("nothing to be done", true)
+		return
+	}
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !input.UpdatePCE {
+		utils.LogInfo(fmt.Sprintf("see workloader.log and the output files for more details. the import file can be passed to wkld-import --match external_data --umwl to preview changes. to do the %s, run again using --update-pce flag.", input.Command), true)
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if input.UpdatePCE && !input.NoPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will create or update %d unmanaged workloads and delete %d unmanaged workloads in %s (%s). do you want to run the %s (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(importData)-1, len(deleteData)-1, input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName), input.Command)
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			return
+		}
+	}
+
+	// Run the import
+	if len(importData) > 1 {
+		wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+			PCE:             input.PCE,
+			Data:            importData,
+			MatchString:     "external_data",
+			Umwl:            true,
+			UpdatePCE:       true,
+			NoPrompt:        true,
+			UpdateWorkloads: true,
+		})
+	}
+
+	// Delete the unmanaged workloads
+	for _, row := range deleteData[1:] {
+		a, err := input.PCE.DeleteHref(row[0])
+		utils.LogAPIResp("DeleteHref", a)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("deleting %s - %s - %d status code", row[0], err, a.StatusCode), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("deleted %s - %s - %d", row[1], row[0], a.StatusCode), true)
+	}
+}
+
+// ParseMappingFile parses a two column CSV mapping a source attribute (e.g., a cloud tag) to a label key.
+// A header row is optional and skipped if the second column is "label_key".
+func ParseMappingFile(filename string) (map[string]string, error) {
+	data, err := utils.ParseCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+	mapping := make(map[string]string)
+	for i, row := range data {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("mapping file line %d - requires two columns", i+1)
+		}
+		if i == 0 && strings.ToLower(row[1]) == "label_key" {
+			continue
+		}
+		mapping[row[0]] = row[1]
+	}
+	return mapping, nil
+}
+
+// MapLabels returns the label key to value map for the provided source attributes using the mapping from ParseMappingFile.
+func MapLabels(attributes map[string]string, mapping map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for attribute, labelKey := range mapping {
+		if v, ok := attributes[attribute]; ok && v != "" {
+			labels[labelKey] = v
+		}
+	}
+	return labels
+}
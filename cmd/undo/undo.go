@@ -0,0 +1,261 @@
+package undo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Set global variables for flags
+var provision bool
+var outputFileName string
+
+// readOnlyFields are removed from deleted objects before they are created again
+var readOnlyFields = []string{"href", "created_at", "updated_at", "deleted_at", "created_by", "updated_by", "deleted_by", "update_type", "caps", "usage", "deleted", "agent", "ven", "services", "online", "os_type", "vulnerability_summary", "detected_vulnerabilities", "container_cluster"}
+
+func init() {
+	UndoCmd.Flags().BoolVar(&provision, "provision", false, "Provision the reverted policy objects.")
+	UndoCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	UndoCmd.Flags().SortFlags = false
+}
+
+// UndoCmd reverses the changes of a run
+var UndoCmd = &cobra.Command{
+	Use:         "undo [run id]",
+	Short:       "Reverse the changes recorded in the journal of a previous run.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Reverse the changes recorded in the journal of a previous run.
+
+When journaling is on, every change workloader makes with --update-pce is recorded in a journal file named for the run id. The run id is logged at the end of the command. Journals are stored in ~/.workloader/journal unless journal_dir is set in pce.yaml or WORKLOADER_JOURNAL_DIR is set. Journaling is off by default. Set journal: true in pce.yaml or WORKLOADER_JOURNAL=true to turn it on.
+
+Run without a run id to list the journals.
+
+Changes are reversed newest first so objects are removed before the objects they depend on (e.g., workloads before the labels created for them):
+- created objects are deleted.
+- updated objects have the changed fields set back to their previous values.
+- deleted objects are created again with a new href.
+
+Provisioning, unpairing, and other actions cannot be reversed and are reported. Use --provision to provision the reverted policy objects.
+
+When journaling is on, the undo is also recorded in a journal so it can be reversed.
+
+Recommended to run without --update-pce first to review the changes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if len(args) == 0 {
+			listRuns()
+			return
+		}
+
+		undoRun(args[0], viper.Get("update_pce").(bool), viper.Get("no_prompt").(bool))
+	},
+}
+
+// listRuns writes the runs in the journal directory
+func listRuns() {
+	runs, err := utils.JournalRuns()
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if len(runs) == 0 {
+		utils.LogInfo(fmt.Sprintf("no journals in %s", utils.JournalDir()), true)
+		return
+	}
+	data := [][]string{{"run_id", "command", "pce", "started", "changes"}}
+	for _, r := range runs {
+		data = append(data, []string{r.RunID, r.Command, r.PCE, r.Started, strconv.Itoa(r.Changes)})
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-undo-runs-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
+
+// step is one request that reverses a journal entry
+type step struct {
+	entry  utils.JournalEntry
+	method string
+	href   string
+	body   []byte
+	note   string
+}
+
+// planStep returns the request that reverses a journal entry. A blank method means the entry cannot be reversed.
+func planStep(e utils.JournalEntry) step {
+	s := step{entry: e}
+	switch {
+	case e.Method == "POST" && e.Href != "" && e.ObjectType != "sec_policy":
+		s.method, s.href = "DELETE", e.Href
+	case e.Method == "PUT" && e.Before != nil:
+		var before, after map[string]interface{}
+		if json.Unmarshal(e.Before, &before) != nil || json.Unmarshal(e.After, &after) != nil {
+			s.note = "journal entry is not a json object"
+			return s
+		}
+		restore := make(map[string]interface{})
+		for k := range after {
+			if k != "href" {
+				restore[k] = before[k]
+			}
+		}
+		s.method, s.href = "PUT", e.Href
+		s.body, _ = json.Marshal(restore)
+	case e.Method == "DELETE" && e.Before != nil:
+		var before map[string]interface{}
+		if json.Unmarshal(e.Before, &before) != nil {
+			s.note = "journal entry is not a json object"
+			return s
+		}
+		for _, f := range readOnlyFields {
+			delete(before, f)
+		}
+		s.method, s.href = "POST", e.Href[:strings.LastIndex(e.Href, "/")]
+		s.body, _ = json.Marshal(before)
+		s.note = "created again with a new href"
+	case e.ObjectType == "sec_policy":
+		s.note = "provisioning cannot be reversed. use --provision to provision the reverted objects."
+	case e.Method == "PUT" || e.Method == "DELETE":
+		s.note = "the object was not retrieved before the change"
+	default:
+		s.note = fmt.Sprintf("%s %s cannot be reversed", e.Method, e.Path)
+	}
+	return s
+}
+
+// undoRun reverses the changes of a run
+func undoRun(runID string, updatePCE, noPrompt bool) {
+
+	utils.LogStartCommand("undo")
+
+	entries, err := utils.ReadJournal(runID)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	runID = strings.TrimSuffix(filepath.Base(runID), ".jsonl")
+	if len(entries) == 0 {
+		utils.LogInfo(fmt.Sprintf("no changes in journal %s", runID), true)
+		utils.LogEndCommand("undo")
+		return
+	}
+
+	// Plan the steps newest first
+	steps := []step{}
+	reversible := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].PCE != entries[0].PCE {
+			utils.LogError(fmt.Sprintf("journal %s has changes for more than one pce", runID))
+		}
+		s := planStep(entries[i])
+		if s.method != "" {
+			reversible++
+		}
+		steps = append(steps, s)
+	}
+	name := entries[0].PCE
+
+	pce, err := utils.GetPCEbyName(name, false)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Log the plan
+	for _, s := range steps {
+		if s.method == "" {
+			utils.LogWarning(fmt.Sprintf("%s %s %s - %s", s.entry.Method, s.entry.ObjectType, utils.LogBlankValue(s.entry.Href), s.note), false)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("%s %s %s will be reversed with %s %s", s.entry.Method, s.entry.ObjectType, s.entry.Href, s.method, s.href), false)
+	}
+	utils.LogInfo(fmt.Sprintf("%s - journal %s has %d changes from %s. %d can be reversed.", name, runID, len(entries), entries[0].Command, reversible), true)
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !updatePCE || reversible == 0 {
+		writeSteps(runID, steps, nil)
+		if reversible > 0 {
+			utils.LogInfo("See the output file for the changes to reverse. To do the undo, run again using --update-pce flag.", true)
+		}
+		utils.LogEndCommand("undo")
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("%s [PROMPT] - workloader will reverse %d changes from %s in %s (%s). Do you want to run the undo (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), reversible, runID, name, utils.PCEFQDN(name))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied to run the undo.", true)
+			utils.LogEndCommand("undo")
+			return
+		}
+	}
+
+	// Reverse the changes. Failures are logged and the remaining changes are still reversed.
+	results := make([]string, len(steps))
+	provisionable := []string{}
+	reversed := 0
+	for i, s := range steps {
+		if s.method == "" {
+			results[i] = "skipped"
+			continue
+		}
+		api, err := utils.PCERequest(pce, s.method, s.href, s.body)
+		utils.LogAPIResp("Undo"+s.method, api)
+		if err != nil {
+			results[i] = fmt.Sprintf("failed - %d", api.StatusCode)
+			utils.LogWarning(fmt.Sprintf("reversing %s %s - %s", s.entry.Method, s.entry.Href, err), true)
+			continue
+		}
+		results[i] = fmt.Sprintf("reversed - %d", api.StatusCode)
+		reversed++
+		href := s.href
+		if s.method == "POST" {
+			var created struct {
+				Href string `json:"href"`
+			}
+			json.Unmarshal([]byte(api.RespBody), &created)
+			href = created.Href
+		}
+		if strings.Contains(href, "/sec_policy/draft/") {
+			provisionable = append(provisionable, href)
+		}
+	}
+	writeSteps(runID, steps, results)
+	utils.LogInfo(fmt.Sprintf("%s - reversed %d of %d changes", name, reversed, reversible), true)
+
+	// Provision
+	if provision && len(provisionable) > 0 {
+		a, err := pce.ProvisionHref(provisionable, "workloader undo "+runID)
+		utils.LogAPIResp("ProvisionHrefs", a)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("provisioning successful - status code %d", a.StatusCode), true)
+	}
+
+	utils.LogEndCommand("undo")
+}
+
+// writeSteps writes the steps and results to the output file
+func writeSteps(runID string, steps []step, results []string) {
+	data := [][]string{{"original_timestamp", "original_method", "object_type", "href", "undo_method", "undo_href", "note", "result"}}
+	for i, s := range steps {
+		result := "not run"
+		if results != nil {
+			result = results[i]
+		}
+		data = append(data, []string{s.entry.Timestamp, s.entry.Method, s.entry.ObjectType, s.entry.Href, s.method, s.href, s.note, result})
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-undo-%s-%s.csv", runID, time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+}
@@ -1,7 +1,10 @@
 package unpair
 
 import (
+	"encoding/csv"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,9 +15,10 @@ import (
 )
 
 // Set global variables for flags
-var hrefFile, role, app, env, loc, restore, outputFileName string
-var updatePCE, noPrompt, setLabelExcl, includeOnline, singleGetWkld, singleUnpair bool
-var hoursSinceLastHB int
+var hrefFile, role, app, env, loc, labelFile, restore, outputFileName, progressFile string
+var updatePCE, noPrompt, setLabelExcl, includeOnline, singleGetWkld, singleUnpair, confirmBatches bool
+var hoursSinceLastHB, daysSinceLastHB, batchSize int
+var batchDelay time.Duration
 var pce illumioapi.PCE
 var err error
 
@@ -28,9 +32,15 @@ func init() {
 	UnpairCmd.Flags().StringVarP(&env, "env", "e", "", "Environment Label. Blank means all environments.")
 	UnpairCmd.Flags().StringVarP(&loc, "loc", "l", "", "Location Label. Blank means all locations.")
 	UnpairCmd.Flags().BoolVarP(&setLabelExcl, "exclude-labels", "x", false, "Use provided label filters as excludes.")
+	UnpairCmd.Flags().StringVar(&labelFile, "label-file", "", "csv file with labels to filter query. the file should have 4 headers: role, app, env, and loc. The four columns in each row is an \"AND\" operation. Each row is an \"OR\" operation. the role, app, env, and loc flags are ignored with this flag.")
 	UnpairCmd.Flags().IntVar(&hoursSinceLastHB, "hours", 0, "Hours since last heartbeat. No value (i.e., 0) will ignore heartbeats.")
+	UnpairCmd.Flags().IntVar(&daysSinceLastHB, "days", 0, "Days since last heartbeat. Cannot be used with --hours. No value (i.e., 0) will ignore heartbeats.")
 	UnpairCmd.Flags().BoolVar(&includeOnline, "include-online", false, "Include workloads that are online. By default only offline workloads that meet criteria will be unpaired.")
 	UnpairCmd.Flags().BoolVar(&singleUnpair, "single-unpair", false, "One API call per unpair versus one API call per 1000 workloads. This will be significantly slower but provide more details in the PCE's syslog messages.")
+	UnpairCmd.Flags().IntVar(&batchSize, "batch-size", 0, "Number of workloads to unpair in each batch. No value (i.e., 0) will unpair all workloads in one batch.")
+	UnpairCmd.Flags().DurationVar(&batchDelay, "batch-delay", 0, "Time to wait between batches (e.g., 30s, 10m, or 1h).")
+	UnpairCmd.Flags().BoolVar(&confirmBatches, "confirm-batches", false, "Prompt before each batch after the first. Ignored with --no-prompt.")
+	UnpairCmd.Flags().StringVar(&progressFile, "progress-file", "", "File to record the hrefs of unpaired workloads. Workloads in the file are skipped, so a stopped unpair can be resumed by running it again with the same file.")
 	UnpairCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
 	UnpairCmd.Flags().SortFlags = false
@@ -38,13 +48,18 @@ func init() {
 
 // UnpairCmd runs the unpair
 var UnpairCmd = &cobra.Command{
-	Use:   "unpair",
-	Short: "Unpair workloads through an input file or by a combination of labels and hours since last heartbeat.",
+	Use:         "unpair",
+	Short:       "Unpair workloads through an input file or by a combination of labels and hours since last heartbeat.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true", utils.AnnotationCapabilities: utils.CapWorkloadWrite},
 
 	Long: `  
 Unpair workloads through an input file or by combination of labels and hours since last heartbeat.
 
-Default output is a CSV file with what would be unpaired.
+Workloads can be selected by an href file (--href), labels (--role, --app, --env, and --loc or a --label-file), and time since the last heartbeat (--hours or --days). The filters are combined. The label file first row must be "role", "app", "env", and "loc". The entries in each row are an "AND" operation and the rows are combined in "OR" operations.
+
+Large unpairs can be split into batches of --batch-size workloads with a --batch-delay between batches. Use --confirm-batches to be prompted before each batch. Use --progress-file to record the unpaired workloads so a stopped unpair (a denied batch prompt, an error, or a stopped command) can be resumed by running the same command again.
+
+Default output is a CSV file with what would be unpaired and the batch of each workload.
 Use the --update-pce command to run the unpair with a user prompt confirmation.
 Use --update-pce and --no-prompt to run unpair with no prompts.`,
 
@@ -56,6 +71,9 @@ Use --update-pce and --no-prompt to run unpair with no prompts.`,
 
   # See what workloads would unpair if we set the threshold for 24 hours for all labels:
   workloader unpair --hours 24 --restore saved
+
+  # Unpair workloads in a label file offline for 30 days in batches of 200 every 10 minutes with a prompt before each batch:
+  workloader unpair --label-file decom-labels.csv --days 30 --batch-size 200 --batch-delay 10m --confirm-batches --progress-file decom-progress.csv --update-pce
  `,
 	Run: func(cmd *cobra.Command, args []string) {
 		pce, err = utils.GetTargetPCE(true)
@@ -76,8 +94,19 @@ func unpair() {
 	utils.LogStartCommand("unpair")
 
 	// Check that we aren't unpairing the whole PCE
-	if app == "" && role == "" && env == "" && loc == "" && hoursSinceLastHB == 0 && hrefFile == "" {
-		utils.LogError("must provide labels, hours, or an input file.")
+	if app == "" && role == "" && env == "" && loc == "" && labelFile == "" && hoursSinceLastHB == 0 && daysSinceLastHB == 0 && hrefFile == "" && utils.HrefsFile() == "" {
+		utils.LogError("must provide labels, hours, days, or an input file.")
+	}
+
+	// Check the heartbeat and batch values
+	if hoursSinceLastHB > 0 && daysSinceLastHB > 0 {
+		utils.LogErrorCode(utils.ExitValidation, "--hours and --days cannot be used together.")
+	}
+	if daysSinceLastHB > 0 {
+		hoursSinceLastHB = daysSinceLastHB * 24
+	}
+	if batchSize < 0 || batchDelay < 0 {
+		utils.LogErrorCode(utils.ExitValidation, "--batch-size and --batch-delay cannot be negative.")
 	}
 
 	// Check the restore value
@@ -90,6 +119,9 @@ func unpair() {
 	if singleGetWkld && hrefFile == "" {
 		utils.LogError("single-get-wkld flag requires an href file")
 	}
+	if singleGetWkld && labelFile != "" {
+		utils.LogError("single-get-wkld flag cannot be used with a label file")
+	}
 
 	// If we have an hrefFile, process it
 	var hrefFileData [][]string
@@ -135,8 +167,19 @@ func unpair() {
 			csvWklds[row[0]] = true
 		}
 
-		// Get all managed workloads
-		allManagedWklds, a, err := pce.GetWklds(map[string]string{"managed": "true"})
+		// Get all managed workloads in the label file query
+		qp := map[string]string{"managed": "true"}
+		if labelFile != "" {
+			labelData, err := utils.ParseCSV(labelFile)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			qp["labels"], err = pce.WorkloadQueryLabelParameter(labelData)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+		}
+		allManagedWklds, a, err := pce.GetWklds(qp)
 		utils.LogAPIResp("GetAllWorkloads", a)
 		if err != nil {
 			utils.LogError(err.Error())
@@ -160,9 +203,12 @@ func unpair() {
 		}
 	}
 
+	// Get the workloads already unpaired in the progress file
+	unpaired := readProgress()
+
 	// Confirm it's not unmanaged and check the labels to find our matches.
 	for _, w := range wklds {
-		if w.GetMode() == "unmanaged" {
+		if w.GetMode() == "unmanaged" || !utils.WorkloadInHrefsFile(w) {
 			continue
 		}
 		if hoursSinceLastHB > 0 && w.HoursSinceLastHeartBeat() < float64(hoursSinceLastHB) {
@@ -171,6 +217,13 @@ func unpair() {
 		if w.Online && !includeOnline {
 			continue
 		}
+		if unpaired[w.Href] {
+			continue
+		}
+		if labelFile != "" {
+			targetWklds = append(targetWklds, w)
+			continue
+		}
 		roleCheck, appCheck, envCheck, locCheck := true, true, true, true
 		if app != "" && w.GetApp(pce.Labels).Value != app {
 			appCheck = false
@@ -200,22 +253,37 @@ func unpair() {
 		return
 	}
 
+	// Split the workloads into batches
+	batches := [][]illumioapi.Workload{targetWklds}
+	if batchSize > 0 {
+		batches = nil
+		for start := 0; start < len(targetWklds); start += batchSize {
+			end := start + batchSize
+			if end > len(targetWklds) {
+				end = len(targetWklds)
+			}
+			batches = append(batches, targetWklds[start:end])
+		}
+	}
+
 	// If there are more than 0 workloads, build the data slice for writing
-	data := [][]string{{"hostname", "href", "role", "app", "env", "loc", "policy_sync_status", "last_heartbeat", "hours_since_last_heartbeat"}}
-	for _, t := range targetWklds {
-		// Reset the time value
-		hoursSinceLastHB := ""
-		// Get the hours since last heartbeat
-		timeParsed, err := time.Parse(time.RFC3339, t.Agent.Status.LastHeartbeatOn)
-		if err != nil {
-			utils.LogWarning(fmt.Sprintf("%s - %s - agent.status.last_heartbeat_on: %s - error parsing time since last heartbeat - %s", t.Hostname, t.Href, t.Agent.Status.LastHeartbeatOn, err.Error()), true)
-			hoursSinceLastHB = "NA"
-		} else {
-			now := time.Now().UTC()
-			hoursSinceLastHB = fmt.Sprintf("%f", now.Sub(timeParsed).Hours())
+	data := [][]string{{"hostname", "href", "role", "app", "env", "loc", "policy_sync_status", "last_heartbeat", "hours_since_last_heartbeat", "batch"}}
+	for b, batch := range batches {
+		for _, t := range batch {
+			// Reset the time value
+			hoursSinceLastHB := ""
+			// Get the hours since last heartbeat
+			timeParsed, err := time.Parse(time.RFC3339, t.Agent.Status.LastHeartbeatOn)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("%s - %s - agent.status.last_heartbeat_on: %s - error parsing time since last heartbeat - %s", t.Hostname, t.Href, t.Agent.Status.LastHeartbeatOn, err.Error()), true)
+				hoursSinceLastHB = "NA"
+			} else {
+				now := time.Now().UTC()
+				hoursSinceLastHB = fmt.Sprintf("%f", now.Sub(timeParsed).Hours())
+			}
+			// Append to our data array
+			data = append(data, []string{t.Hostname, t.Href, t.GetRole(pce.Labels).Value, t.GetApp(pce.Labels).Value, t.GetEnv(pce.Labels).Value, t.GetLoc(pce.Labels).Value, t.Agent.Status.SecurityPolicySyncState, t.Agent.Status.LastHeartbeatOn, hoursSinceLastHB, strconv.Itoa(b + 1)})
 		}
-		// Append to our data array
-		data = append(data, []string{t.Hostname, t.Href, t.GetRole(pce.Labels).Value, t.GetApp(pce.Labels).Value, t.GetEnv(pce.Labels).Value, t.GetLoc(pce.Labels).Value, t.Agent.Status.SecurityPolicySyncState, t.Agent.Status.LastHeartbeatOn, hoursSinceLastHB})
 	}
 
 	// Write CSV data
@@ -226,7 +294,7 @@ func unpair() {
 
 	// If updatePCE is disabled, we are just going to alert the user what will happen and log
 	if !updatePCE {
-		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring unpairing. See %s for details. To do the unpair, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(targetWklds), outputFileName), true)
+		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads requiring unpairing in %d batches. See %s for details. To do the unpair, run again using --update-pce flag. The --no-prompt flag will bypass the prompt if used with --update-pce.", len(targetWklds), len(batches), outputFileName), true)
 		utils.LogEndCommand("unpair")
 		return
 	}
@@ -234,7 +302,7 @@ func unpair() {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("%s [PROMPT] - workloader identified %d workloads requiring unpairing in %s (%s). See %s for details. Do you want to run the unpair? (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(targetWklds), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string), outputFileName)
+		fmt.Printf("%s [PROMPT] - workloader identified %d workloads requiring unpairing in %d batches in %s (%s). See %s for details. Do you want to run the unpair? (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(targetWklds), len(batches), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName), outputFileName)
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo(fmt.Sprintf("prompt denied to unpair %d workloads.", len(targetWklds)), true)
@@ -243,34 +311,114 @@ func unpair() {
 		}
 	}
 
-	// If single
-	if singleUnpair {
-		// Create a slice of slices
-		singleTargetWklds := [][]illumioapi.Workload{}
-		for _, w := range targetWklds {
-			singleTargetWklds = append(singleTargetWklds, []illumioapi.Workload{w})
+	// Unpair each batch
+	unpairedCount := 0
+	for b, batch := range batches {
+		if b > 0 {
+			// Wait between batches
+			if batchDelay > 0 {
+				utils.LogInfo(fmt.Sprintf("waiting %s before batch %d of %d", batchDelay, b+1, len(batches)), true)
+				time.Sleep(batchDelay)
+			}
+
+			// Prompt before the batch
+			if confirmBatches && !noPrompt {
+				var prompt string
+				fmt.Printf("%s [PROMPT] - %d of %d workloads unpaired. Do you want to unpair batch %d of %d with %d workloads? (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), unpairedCount, len(targetWklds), b+1, len(batches), len(batch))
+				fmt.Scanln(&prompt)
+				if strings.ToLower(prompt) != "yes" {
+					msg := fmt.Sprintf("prompt denied to unpair batch %d of %d. %d of %d workloads unpaired.", b+1, len(batches), unpairedCount, len(targetWklds))
+					if progressFile != "" {
+						msg = fmt.Sprintf("%s run the same command to resume with the workloads not in %s.", msg, progressFile)
+					}
+					utils.LogInfo(msg, true)
+					utils.LogEndCommand("unpair")
+					return
+				}
+			}
 		}
 
-		// Iterate through those for unpairing
-		for i, w := range singleTargetWklds {
-			apiResps, err := pce.WorkloadsUnpair(w, restore)
+		utils.LogInfo(fmt.Sprintf("unpairing batch %d of %d - %d workloads", b+1, len(batches), len(batch)), true)
+		unpairBatch(batch)
+		unpairedCount += len(batch)
+		writeProgress(batch)
+	}
+
+	utils.LogEndCommand("unpair")
+}
+
+// unpairBatch unpairs a batch of workloads
+func unpairBatch(batch []illumioapi.Workload) {
+	// If single
+	if singleUnpair {
+		// Iterate through the workloads for unpairing
+		for i, w := range batch {
+			apiResps, err := pce.WorkloadsUnpair([]illumioapi.Workload{w}, restore)
 			utils.LogAPIResp("unpair workloads", apiResps[0])
 			if err != nil {
 				utils.LogError(err.Error())
 			}
 			// Update progress
-			utils.LogInfo(fmt.Sprintf("unpaired %d of %d - %s - status code %d", i+1, len(singleTargetWklds), w[0].Href, apiResps[0].StatusCode), true)
+			utils.LogInfo(fmt.Sprintf("unpaired %d of %d - %s - status code %d", i+1, len(batch), w.Href, apiResps[0].StatusCode), true)
 		}
-	} else {
-		// We will only get here if we have need to run the unpair
-		apiResps, err := pce.WorkloadsUnpair(targetWklds, restore)
-		for _, a := range apiResps {
-			utils.LogAPIResp("unpair workloads", a)
-		}
-		if err != nil {
-			utils.LogError(err.Error())
+		return
+	}
+
+	// We will only get here if we have need to run the unpair
+	apiResps, err := pce.WorkloadsUnpair(batch, restore)
+	for _, a := range apiResps {
+		utils.LogAPIResp("unpair workloads", a)
+	}
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+}
+
+// readProgress returns the hrefs of workloads in the progress file. A missing file is a new unpair.
+func readProgress() map[string]bool {
+	unpaired := make(map[string]bool)
+	if progressFile == "" {
+		return unpaired
+	}
+	if _, err := os.Stat(progressFile); os.IsNotExist(err) {
+		return unpaired
+	}
+	data, err := utils.ParseCSV(progressFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	for _, row := range data {
+		if len(row) > 0 && strings.Contains(row[0], "/orgs/") {
+			unpaired[row[0]] = true
 		}
 	}
+	if len(unpaired) > 0 {
+		utils.LogInfo(fmt.Sprintf("skipping %d workloads already unpaired in %s", len(unpaired), progressFile), true)
+	}
+	return unpaired
+}
 
-	utils.LogEndCommand("unpair")
+// writeProgress adds the hrefs of an unpaired batch to the progress file
+func writeProgress(batch []illumioapi.Workload) {
+	if progressFile == "" {
+		return
+	}
+	_, err := os.Stat(progressFile)
+	newFile := os.IsNotExist(err)
+	f, err := os.OpenFile(progressFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("writing progress file - %s", err))
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if newFile {
+		w.Write([]string{"href", "hostname", "unpaired_at"})
+	}
+	for _, wkld := range batch {
+		w.Write([]string{wkld.Href, wkld.Hostname, time.Now().Format(time.RFC3339)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		utils.LogError(fmt.Sprintf("writing progress file - %s", err))
+	}
 }
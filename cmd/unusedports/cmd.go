@@ -229,6 +229,7 @@ func unusedPorts() {
 	if !headerWritten {
 		utils.LogInfo("no unused ports identified", true)
 	}
+	utils.FinishLineOutput(outputFileName)
 
 	utils.LogEndCommand("unused-ports")
 }
@@ -10,9 +10,10 @@ import (
 
 var pce illumioapi.PCE
 var err error
-var start, end, exclServiceCSV, outputFileName string
+var start, end, exclServiceCSV, outputFileName, deleteFileName string
 var nonUni, includeAllUmwls bool
 var maxResults int
+var watch time.Duration
 
 func init() {
 	UnusedUmwlCmd.Flags().BoolVarP(&includeAllUmwls, "all", "a", false, "export all umwls with traffic count. default only exports umwl with 0 traffic.")
@@ -22,6 +23,8 @@ func init() {
 	UnusedUmwlCmd.Flags().BoolVarP(&nonUni, "incl-non-unicast", "n", false, "includes non-unicast (broadcast and multicast) flows in the output. Default is unicast only.")
 	UnusedUmwlCmd.Flags().StringVarP(&exclServiceCSV, "excl-svc-file", "x", "", "file location of csv with port/protocols to exclude. Port number in column 1 and IANA numeric protocol in Col 2. Headers optional.")
 	UnusedUmwlCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename. If iterating through labels, the labels will be appended to the provided name before the provided file extension. To name the files for the labels, use just an extension (--output-file .csv).")
+	UnusedUmwlCmd.Flags().StringVar(&deleteFileName, "delete-file", "", "optionally write the umwls with no traffic that are not referenced by rules to this file for the delete command.")
+	UnusedUmwlCmd.Flags().DurationVar(&watch, "watch", 0, "re-run the report on this interval (e.g., 1h) until stopped and write only the umwls that changed since the previous run.")
 	UnusedUmwlCmd.Flags().SortFlags = false
 
 }
@@ -33,14 +36,32 @@ var UnusedUmwlCmd = &cobra.Command{
 	Long: `
 	Create a report of unmanaged workloads with no traffic.
 
+Each unmanaged workload is checked for traffic between --start and --end. The output has the number of flows, the last time a flow was seen in that window, and the draft rules that reference the workload directly as a consumer or provider. Use --all to include unmanaged workloads with traffic.
+
+Use --delete-file to also write the unmanaged workloads with no traffic that are not referenced by rules. Review the file and use it as the input for the delete command (e.g., workloader delete umwls.csv --update-pce). Workloads referenced by rules are left out because the PCE does not delete them until the rules are changed.
+
 The update-pce and --no-prompt flags are ignored for this command.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
+		if watch > 0 && deleteFileName != "" {
+			utils.LogErrorCode(utils.ExitValidation, "--delete-file cannot be used with --watch")
+		}
+
 		pce, err = utils.GetTargetPCE(true)
 		if err != nil {
 			utils.LogError(err.Error())
 		}
 
-		unusedUmwl()
+		// Log start
+		utils.LogStartCommand("unused-umwl")
+
+		if watch > 0 {
+			utils.Watch("unused-umwl", watch, []string{"href"}, unusedUmwl)
+		} else {
+			unusedUmwl(true)
+		}
+
+		// Log End
+		utils.LogEndCommand("unused-umwl")
 	},
 }
@@ -10,10 +10,8 @@ import (
 	"github.com/brian1917/workloader/utils"
 )
 
-func unusedUmwl() {
-	// Log start
-	utils.LogStartCommand("unused-umwl")
-
+// unusedUmwl returns the report of unmanaged workloads. The output file is written when write is true.
+func unusedUmwl(write bool) [][]string {
 	// Get the unmanaged workloads
 	umwls, a, err := pce.GetWklds(map[string]string{"managed": "false"})
 	utils.LogAPIResp("GetAllWorkloadsQP", a)
@@ -21,6 +19,9 @@ func unusedUmwl() {
 		utils.LogError(err.Error())
 	}
 
+	// Get the rules that reference each umwl. The draft policy is used because the PCE does not delete workloads referenced by draft rules.
+	ruleRefs := umwlRuleRefs()
+
 	// Create the default query struct and an "or" operator
 	tq := illumioapi.TrafficQuery{QueryOperator: "or", PolicyStatuses: []string{}, ExcludeWorkloadsFromIPListQuery: true}
 
@@ -58,7 +59,9 @@ func unusedUmwl() {
 	}
 
 	// Start the CSV data
-	csvData := [][]string{{"hostname", "name", "href", "role", "app", "env", "loc", "interfaces", "traffic_count"}}
+	csvData := [][]string{{"hostname", "name", "href", "role", "app", "env", "loc", "interfaces", "traffic_count", "last_seen", "created_at", "referenced_by_rules"}}
+	deleteData := [][]string{{"href", "hostname", "name"}}
+	referenced := 0
 
 	// Iterate over UMWLs
 	for _, umwl := range umwls {
@@ -80,9 +83,31 @@ func unusedUmwl() {
 			interfaces = append(interfaces, ipAddress)
 		}
 
+		// Get the last time a flow was seen
+		var lastSeen time.Time
+		for _, t := range traffic {
+			if t.TimestampRange == nil {
+				continue
+			}
+			if last, err := time.Parse(time.RFC3339, t.TimestampRange.LastDetected); err == nil && last.After(lastSeen) {
+				lastSeen = last
+			}
+		}
+		lastSeenStr := ""
+		if !lastSeen.IsZero() {
+			lastSeenStr = lastSeen.Format(time.RFC3339)
+		}
+
 		// Append to the CSV
 		if len(traffic) == 0 || includeAllUmwls {
-			csvData = append(csvData, []string{umwl.Hostname, umwl.Name, umwl.Href, umwl.GetRole(pce.Labels).Value, umwl.GetApp(pce.Labels).Value, umwl.GetEnv(pce.Labels).Value, umwl.GetLoc(pce.Labels).Value, strings.Join(interfaces, ";"), strconv.Itoa(len(traffic))})
+			csvData = append(csvData, []string{umwl.Hostname, umwl.Name, umwl.Href, umwl.GetRole(pce.Labels).Value, umwl.GetApp(pce.Labels).Value, umwl.GetEnv(pce.Labels).Value, umwl.GetLoc(pce.Labels).Value, strings.Join(interfaces, ";"), strconv.Itoa(len(traffic)), lastSeenStr, umwl.CreatedAt, strings.Join(ruleRefs[umwl.Href], ";")})
+		}
+		if len(traffic) == 0 {
+			if len(ruleRefs[umwl.Href]) > 0 {
+				referenced++
+			} else {
+				deleteData = append(deleteData, []string{umwl.Href, umwl.Hostname, umwl.Name})
+			}
 		}
 
 		// Log iteration
@@ -94,6 +119,9 @@ func unusedUmwl() {
 	}
 
 	// Output the CSV Data
+	if !write {
+		return csvData
+	}
 	if len(csvData) > 1 {
 		if outputFileName == "" {
 			outputFileName = fmt.Sprintf("workloader-unused-umwl-%s.csv", time.Now().Format("20060102_150405"))
@@ -105,7 +133,50 @@ func unusedUmwl() {
 		utils.LogInfo("no records exported matching criteria", true)
 	}
 
-	// Log End
-	utils.LogEndCommand("unused-umwl")
+	// Output the delete CSV
+	if deleteFileName != "" {
+		if referenced > 0 {
+			utils.LogInfo(fmt.Sprintf("%d umwls with no traffic are referenced by rules and not in the delete file", referenced), true)
+		}
+		if len(deleteData) > 1 {
+			utils.WriteOutput(deleteData, deleteData, deleteFileName)
+			utils.LogInfo(fmt.Sprintf("%d umwls in the delete file", len(deleteData)-1), true)
+		} else {
+			utils.LogInfo("no umwls to delete", true)
+		}
+	}
+
+	return csvData
+}
 
+// umwlRuleRefs returns the hrefs of the draft rules that reference each workload as a consumer or provider
+func umwlRuleRefs() map[string][]string {
+	ruleRefs := make(map[string][]string)
+	ruleSets, a, err := pce.GetRulesets(nil, "draft")
+	utils.LogAPIResp("GetRulesets", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	for _, rs := range ruleSets {
+		for _, r := range rs.Rules {
+			if r == nil {
+				continue
+			}
+			hrefs := make(map[string]bool)
+			for _, c := range r.Consumers {
+				if c != nil && c.Workload != nil {
+					hrefs[c.Workload.Href] = true
+				}
+			}
+			for _, p := range r.Providers {
+				if p != nil && p.Workload != nil {
+					hrefs[p.Workload.Href] = true
+				}
+			}
+			for href := range hrefs {
+				ruleRefs[href] = append(ruleRefs[href], r.Href)
+			}
+		}
+	}
+	return ruleRefs
 }
@@ -0,0 +1,303 @@
+package upgrade
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// Schedule file headers. Other headers are label keys.
+const (
+	headerName     = "name"
+	headerDays     = "days"
+	headerStart    = "start"
+	headerEnd      = "end"
+	headerTimezone = "timezone"
+)
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// window is a weekly maintenance window. The window ends the next day if the end is not after the start.
+type window struct {
+	days       map[time.Weekday]bool
+	start, end int
+	loc        *time.Location
+	text       string
+}
+
+// group is a row of the schedule file with the vens that match its labels
+type group struct {
+	name     string
+	labels   map[string]string
+	window   window
+	queue    []illumioapi.VEN
+	nextWave time.Time
+}
+
+// wave is an upgrade api call for a group in its window
+type wave struct {
+	number  int
+	group   string
+	started time.Time
+	vens    int
+	errors  []illumioapi.VENUpgradeError
+	err     error
+}
+
+// parseClock returns the minutes after midnight of hh:mm
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a time in hh:mm format", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseDays returns the weekdays of a comma-separated list of days and day ranges (e.g., mon-fri,sun). Blank or * is every day.
+func parseDays(s string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	index := func(d string) (int, error) {
+		for i, w := range weekdays {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(d)), w) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%s is not a day", d)
+	}
+	if strings.TrimSpace(s) == "" || strings.TrimSpace(s) == "*" {
+		for i := range weekdays {
+			days[time.Weekday(i)] = true
+		}
+		return days, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := index(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = index(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		for i := first; ; i = (i + 1) % 7 {
+			days[time.Weekday(i)] = true
+			if i == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// contains returns true if the time is in the window
+func (w window) contains(t time.Time) bool {
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && m >= w.start && m < w.end
+	}
+	return (w.days[t.Weekday()] && m >= w.start) || (w.days[t.AddDate(0, 0, -1).Weekday()] && m < w.end)
+}
+
+// next returns the time or the next start of the window after it
+func (w window) next(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	local := t.In(w.loc)
+	for d := 0; d <= 7; d++ {
+		day := local.AddDate(0, 0, d)
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.start/60, w.start%60, 0, 0, w.loc)
+		if start.After(t) && w.days[start.Weekday()] {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// parseSchedule reads the schedule file. Each row is a group with labels and a window. Blank label values match any value.
+func parseSchedule(file string) ([]*group, error) {
+	data, err := utils.ParseCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("%s has no schedules", file)
+	}
+	cols := make(map[string]int)
+	for i, h := range data[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, h := range []string{headerStart, headerEnd} {
+		if _, ok := cols[h]; !ok {
+			return nil, fmt.Errorf("%s is a required header in the schedule file", h)
+		}
+	}
+	value := func(row []string, header string) string {
+		if i, ok := cols[header]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	groups := []*group{}
+	for i, row := range data[1:] {
+		line := i + 2
+		g := &group{name: value(row, headerName), labels: make(map[string]string)}
+		if g.name == "" {
+			g.name = fmt.Sprintf("schedule line %d", line)
+		}
+		for h := range cols {
+			if h != headerName && h != headerDays && h != headerStart && h != headerEnd && h != headerTimezone && value(row, h) != "" {
+				g.labels[h] = value(row, h)
+			}
+		}
+		if g.window.days, err = parseDays(value(row, headerDays)); err != nil {
+			return nil, fmt.Errorf("schedule line %d - %s", line, err)
+		}
+		if g.window.start, err = parseClock(value(row, headerStart)); err != nil {
+			return nil, fmt.Errorf("schedule line %d - %s", line, err)
+		}
+		if g.window.end, err = parseClock(value(row, headerEnd)); err != nil {
+			return nil, fmt.Errorf("schedule line %d - %s", line, err)
+		}
+		g.window.loc = time.Local
+		if tz := value(row, headerTimezone); tz != "" {
+			if g.window.loc, err = time.LoadLocation(tz); err != nil {
+				return nil, fmt.Errorf("schedule line %d - %s is not a valid timezone", line, tz)
+			}
+		}
+		days := value(row, headerDays)
+		if days == "" {
+			days = "*"
+		}
+		g.window.text = fmt.Sprintf("%s %s-%s %s", days, value(row, headerStart), value(row, headerEnd), g.window.loc)
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// scheduleGroup returns the first group with labels that match the workload or nil
+func scheduleGroup(groups []*group, w illumioapi.Workload) *group {
+	for _, g := range groups {
+		match := true
+		for key, value := range g.labels {
+			if w.GetLabelByKey(key, pce.Labels).Value != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			return g
+		}
+	}
+	return nil
+}
+
+// runSchedule upgrades each group's vens in waves of up to --wave-size vens in the group's window until every group is done or --max-wait
+func runSchedule(groups []*group) {
+	deadline := time.Now().Add(maxWait)
+	waves := []wave{}
+	pending := []*group{}
+	for _, g := range groups {
+		if len(g.queue) > 0 {
+			pending = append(pending, g)
+		}
+	}
+
+	for len(pending) > 0 {
+		now := time.Now()
+		for _, g := range pending {
+			if !g.window.contains(now) || now.Before(g.nextWave) {
+				continue
+			}
+			size := len(g.queue)
+			if waveSize > 0 && waveSize < size {
+				size = waveSize
+			}
+			w := wave{number: len(waves) + 1, group: g.name, started: now, vens: size}
+			utils.LogInfo(fmt.Sprintf("wave %d - %s - upgrading %d vens to %s. %d vens left in the group.", w.number, g.name, size, targetVersion, len(g.queue)-size), true)
+			resp, a, err := pce.UpgradeVENs(g.queue[:size], targetVersion)
+			utils.LogAPIResp("UpgradeVENs", a)
+			w.errors, w.err = resp.VENUpgradeErrors, err
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("wave %d - %s - %s", w.number, g.name, err), true)
+				utils.RecordFailure(fmt.Sprintf("wave %d - %s - %s", w.number, g.name, err))
+			}
+			for i, e := range resp.VENUpgradeErrors {
+				utils.LogWarning(fmt.Sprintf("wave %d - %s - error %d - token: %s; message: %s; hrefs: %s", w.number, g.name, i+1, e.Token, e.Message, strings.Join(e.Hrefs, ", ")), true)
+				utils.RecordFailure(fmt.Sprintf("wave %d - %s - %s", w.number, g.name, e.Message))
+			}
+			waves = append(waves, w)
+			g.queue = g.queue[size:]
+			g.nextWave = now.Add(waveDelay)
+		}
+
+		// Remove the finished groups
+		remaining := []*group{}
+		for _, g := range pending {
+			if len(g.queue) > 0 {
+				remaining = append(remaining, g)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			break
+		}
+
+		// Wait for the next window or wave
+		next := time.Time{}
+		for _, g := range pending {
+			t := g.window.next(g.nextWave)
+			if g.nextWave.Before(now) {
+				t = g.window.next(now)
+			}
+			if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+		if next.IsZero() || next.After(deadline) {
+			for _, g := range pending {
+				msg := fmt.Sprintf("%s - %d vens not upgraded. the window does not open before --max-wait.", g.name, len(g.queue))
+				utils.LogWarning(msg, true)
+				utils.RecordFailure(msg)
+			}
+			break
+		}
+		if wait := time.Until(next); wait > 0 {
+			utils.LogInfo(fmt.Sprintf("waiting until %s for the next window or wave. %d groups pending.", next.Format("2006-01-02 15:04:05 MST"), len(pending)), true)
+			time.Sleep(wait)
+		}
+	}
+
+	// Write the wave report
+	if len(waves) == 0 {
+		return
+	}
+	data := [][]string{{"wave", "group", "started", "vens", "errors", "error_hrefs", "status"}}
+	for _, w := range waves {
+		hrefs, messages := []string{}, []string{}
+		for _, e := range w.errors {
+			hrefs = append(hrefs, e.Hrefs...)
+			messages = append(messages, e.Message)
+		}
+		sort.Strings(hrefs)
+		status := "requested"
+		if w.err != nil {
+			status = "failed"
+			messages = append(messages, w.err.Error())
+		} else if len(w.errors) > 0 {
+			status = "partial"
+		}
+		data = append(data, []string{strconv.Itoa(w.number), w.group, w.started.Format(time.RFC3339), strconv.Itoa(w.vens), strings.Join(messages, "; "), strings.Join(hrefs, ";"), status})
+	}
+	utils.WriteOutput(data, data, fmt.Sprintf("%s-waves.csv", strings.TrimSuffix(outputFileName, ".csv")))
+}
@@ -12,8 +12,10 @@ import (
 )
 
 // Set global variables for flags
-var targetVersion, hostFile, loc, env, app, role, outputFileName string
+var targetVersion, hostFile, loc, env, app, role, outputFileName, scheduleFile string
 var singleAPI, updatePCE, noPrompt bool
+var waveSize int
+var waveDelay, maxWait time.Duration
 var pce illumioapi.PCE
 var err error
 
@@ -28,6 +30,10 @@ func init() {
 	UpgradeCmd.Flags().StringVarP(&env, "env", "e", "", "environment label. blank means all environments.")
 	UpgradeCmd.Flags().StringVarP(&app, "app", "a", "", "application label. blank means all applications.")
 	UpgradeCmd.Flags().StringVarP(&role, "role", "r", "", "role Label. blank means all roles.")
+	UpgradeCmd.Flags().StringVar(&scheduleFile, "schedule-file", "", "csv file mapping label sets to maintenance windows. see description for format.")
+	UpgradeCmd.Flags().IntVar(&waveSize, "wave-size", 0, "maximum vens per upgrade api call in a window. 0 is all of the group's vens. only used with --schedule-file.")
+	UpgradeCmd.Flags().DurationVar(&waveDelay, "wave-delay", 15*time.Minute, "time between waves of the same group (e.g., 30m). only used with --schedule-file.")
+	UpgradeCmd.Flags().DurationVar(&maxWait, "max-wait", 168*time.Hour, "maximum time to wait for windows before stopping. only used with --schedule-file.")
 	UpgradeCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
 
 	UpgradeCmd.Flags().SortFlags = false
@@ -36,8 +42,9 @@ func init() {
 
 // UpgradeCmd runs the hostname parser
 var UpgradeCmd = &cobra.Command{
-	Use:   "upgrade",
-	Short: "Upgrade the VEN installed on workloads by labels or an input hostname list.",
+	Use:         "upgrade",
+	Short:       "Upgrade the VEN installed on workloads by labels or an input hostname list.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true"},
 	Long: `
 Upgrade the VEN installed on workloads by labels or an input hostname list.
 
@@ -45,7 +52,27 @@ If a host file is used, the label flags are ignored.
 
 All workloads will be upgraded if there is no hostfile and no provided labels.
 
-Default output is a CSV file with what would be upgraded. Use the --update-pce command to run the upgrades with a user prompt confirmation. Use --update-pce and --no-prompt to run upgrade with no prompts.`,
+Default output is a CSV file with what would be upgraded. Use the --update-pce command to run the upgrades with a user prompt confirmation. Use --update-pce and --no-prompt to run upgrade with no prompts.
+
+The --schedule-file flag limits upgrades to maintenance windows. The schedule file is a CSV with the following headers:
+  - name: name of the group used in logging and output.
+  - days: comma-separated days and ranges (e.g., mon-fri or sat,sun). blank or * is every day.
+  - start: window start in hh:mm (24-hour) format.
+  - end: window end in hh:mm format. an end before the start ends the next day.
+  - timezone: IANA timezone (e.g., America/New_York). blank is the local timezone.
+  - any other header is a label key (e.g., env, loc). blank values match any label.
+
+Each workload uses the first row its labels match. Workloads that do not match a row are not upgraded. Without --update-pce, the output includes each workload's group and next window. With --update-pce, workloader stays running and upgrades each group in waves of --wave-size vens with --wave-delay between waves while the group's window is open. Progress is logged per wave and a waves CSV reports each wave's errors. VENs not upgraded before --max-wait are reported as failures.
+
+Example schedule file:
+  name,env,loc,days,start,end,timezone
+  prod-east,prod,east,sat-sun,01:00,05:00,America/New_York
+  prod-other,prod,,sat,22:00,02:00,UTC
+  non-prod,,,mon-fri,12:00,13:00,
+
+Examples:
+  workloader upgrade --version 23.2.10-300 --schedule-file schedule.csv
+  workloader upgrade --version 23.2.10-300 --schedule-file schedule.csv --wave-size 100 --wave-delay 30m --update-pce --no-prompt`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pce, err = utils.GetTargetPCE(true)
 		if err != nil {
@@ -129,6 +156,10 @@ func wkldUpgrade() {
 				utils.LogInfo(fmt.Sprintf("csv line %d - %s is already on %s. skipping.", i+1, row[0], targetVersion), true)
 				continue
 			}
+			// If the VEN is not in the hrefs file, skip
+			if !utils.VENInHrefsFile(ven) && !utils.WorkloadInHrefsFile(pce.Workloads[ven.Hostname]) {
+				continue
+			}
 
 			// Add to the VEN slice
 			targetVENs = append(targetVENs, ven)
@@ -168,6 +199,9 @@ func wkldUpgrade() {
 			if pce.VENs[w.VEN.Href].Status != "active" || !pce.Workloads[w.Href].Online {
 				continue
 			}
+			if !utils.WorkloadInHrefsFile(w) {
+				continue
+			}
 			targetVENs = append(targetVENs, pce.VENs[w.VEN.Href])
 			targetWorkloads = append(targetWorkloads, w)
 		}
@@ -190,12 +224,45 @@ func wkldUpgrade() {
 		utils.LogError("target vens exceed max length of 25,000")
 	}
 
+	// Assign the target vens to schedule groups
+	var groups []*group
+	venGroups := make(map[string]*group)
+	if scheduleFile != "" {
+		groups, err = parseSchedule(scheduleFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		scheduledVENs := []illumioapi.VEN{}
+		for _, t := range targetVENs {
+			g := scheduleGroup(groups, pce.Workloads[t.Hostname])
+			if g == nil {
+				utils.LogInfo(fmt.Sprintf("%s does not match a schedule in %s. skipping.", t.Hostname, scheduleFile), true)
+				continue
+			}
+			g.queue = append(g.queue, t)
+			venGroups[t.Href] = g
+			scheduledVENs = append(scheduledVENs, t)
+		}
+		targetVENs = scheduledVENs
+	}
+
 	// Build output data
 	if len(targetVENs) > 0 {
 		outputData := [][]string{{"hostname", "ven_href", "wkld_href", "role", "app", "env", "loc", "current_ven_version", "targeted_ven_version"}}
+		if scheduleFile != "" {
+			outputData[0] = append(outputData[0], "group", "window", "next_window")
+		}
 		for _, t := range targetVENs {
 			targetWkld := pce.Workloads[t.Hostname]
-			outputData = append(outputData, []string{t.Hostname, t.Href, targetWkld.Href, targetWkld.GetRole(pce.Labels).Value, targetWkld.GetApp(pce.Labels).Value, targetWkld.GetEnv(pce.Labels).Value, targetWkld.GetLoc(pce.Labels).Value, t.Version, targetVersion})
+			row := []string{t.Hostname, t.Href, targetWkld.Href, targetWkld.GetRole(pce.Labels).Value, targetWkld.GetApp(pce.Labels).Value, targetWkld.GetEnv(pce.Labels).Value, targetWkld.GetLoc(pce.Labels).Value, t.Version, targetVersion}
+			if g, ok := venGroups[t.Href]; ok {
+				next := ""
+				if n := g.window.next(time.Now()); !n.IsZero() {
+					next = n.Format(time.RFC3339)
+				}
+				row = append(row, g.name, g.window.text, next)
+			}
+			outputData = append(outputData, row)
 		}
 		if outputFileName == "" {
 			outputFileName = "workloader-upgrade-" + time.Now().Format("20060102_150405") + ".csv"
@@ -212,7 +279,7 @@ func wkldUpgrade() {
 		// If updatePCE is set, but not noPrompt, we will prompt the user.
 		if updatePCE && !noPrompt {
 			var prompt string
-			fmt.Printf("[PROMPT] - workloader identified %d workloads in %s (%s) requiring VEN updates. See %s for details. Do you want to run the upgrade? (yes/no)? ", len(targetVENs), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string), outputFileName)
+			fmt.Printf("[PROMPT] - workloader identified %d workloads in %s (%s) requiring VEN updates. See %s for details. Do you want to run the upgrade? (yes/no)? ", len(targetVENs), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName), outputFileName)
 			fmt.Scanln(&prompt)
 			if strings.ToLower(prompt) != "yes" {
 				utils.LogInfo(fmt.Sprintf("prompt denied to upgrade %d workloads", len(targetVENs)), true)
@@ -221,6 +288,13 @@ func wkldUpgrade() {
 			}
 		}
 
+		// With a schedule file, upgrade in each group's windows
+		if scheduleFile != "" {
+			runSchedule(groups)
+			utils.LogEndCommand("upgrade")
+			return
+		}
+
 		// Call the API
 		resp, a, err := pce.UpgradeVENs(targetVENs, targetVersion)
 		utils.LogAPIResp("UpgradeVENs", a)
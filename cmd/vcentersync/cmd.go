@@ -0,0 +1,79 @@
+package vcentersync
+
+import (
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var vc vCenter
+var vcUser, vcPassword, mappingFile, externalDataSet, outputFileName string
+var insecure, cleanup, noUMWL, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	VCenterSyncCmd.Flags().StringVarP(&vc.server, "vcenter", "v", "", "vcenter server fqdn or ip address.")
+	VCenterSyncCmd.Flags().StringVarP(&vcUser, "vcenter-user", "u", "", "vcenter user. default is the VCENTER_USER environment variable.")
+	VCenterSyncCmd.Flags().StringVarP(&vcPassword, "vcenter-pwd", "p", "", "vcenter password. default is the VCENTER_PASSWORD environment variable.")
+	VCenterSyncCmd.Flags().BoolVar(&insecure, "insecure", false, "do not validate the vcenter certificate.")
+	VCenterSyncCmd.Flags().StringVarP(&mappingFile, "mapping-file", "m", "", "csv file with two columns: vsphere tag category and illumio label key.")
+	VCenterSyncCmd.Flags().BoolVar(&noUMWL, "no-umwl", false, "only label matching managed workloads. do not create unmanaged workloads for vms without a ven.")
+	VCenterSyncCmd.Flags().StringVarP(&externalDataSet, "external-data-set", "e", "vcenter-sync", "external data set used to identify unmanaged workloads managed by vcenter-sync.")
+	VCenterSyncCmd.Flags().BoolVarP(&cleanup, "cleanup", "c", true, "delete unmanaged workloads in the external data set for vms that are powered off or no longer exist.")
+	VCenterSyncCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	VCenterSyncCmd.Flags().SortFlags = false
+}
+
+// VCenterSyncCmd runs the vcenter-sync command
+var VCenterSyncCmd = &cobra.Command{
+	Use:         "vcenter-sync",
+	Short:       "Label workloads from vSphere tags and create unmanaged workloads for VMs without a VEN.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Label workloads from vSphere tags and create unmanaged workloads for VMs without a VEN.
+
+The vCenter REST API (vSphere 7.0 or later) is used to read powered on VMs, their guest hostname and IP addresses (requires VMware Tools), and their attached tags.
+
+The mapping file is a csv with the vsphere tag category in the first column and the illumio label key in the second column. A header of category,label_key is optional. The pseudo category vcenter:guest-os maps the guest os name. Custom attributes are only available in the vSphere SOAP API and are not supported.
+
+Example mapping file:
++-----------------------+-----------+
+|       category        | label_key |
++-----------------------+-----------+
+| Application           | app       |
+| Environment           | env       |
+| Datacenter            | loc       |
++-----------------------+-----------+
+
+VMs are matched to managed workloads by guest hostname, then vm name (full or short name, case insensitive). Matching managed workloads are labeled using wkld-import. VMs that do not match are unmanaged workloads with the vm id as the external data reference unless --no-umwl is set.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if vc.server == "" {
+			utils.LogError("--vcenter is required")
+		}
+		if vcUser == "" {
+			vcUser = os.Getenv("VCENTER_USER")
+		}
+		if vcPassword == "" {
+			vcPassword = os.Getenv("VCENTER_PASSWORD")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		vcenterSync()
+	},
+}
@@ -0,0 +1,158 @@
+package vcentersync
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/cmd/umwlsync"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+)
+
+func vcenterSync() {
+
+	utils.LogStartCommand("vcenter-sync")
+
+	// Parse the mapping file
+	mapping := make(map[string]string)
+	if mappingFile != "" {
+		mapping, err = umwlsync.ParseMappingFile(mappingFile)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+	}
+
+	// Log in to vcenter
+	if err := vc.login(vcUser, vcPassword, insecure); err != nil {
+		utils.LogError(err.Error())
+	}
+	defer vc.logout()
+
+	// Get the powered on vms
+	vms := []vcenterVM{}
+	if err := vc.call("GET", "/api/vcenter/vm?power_states=POWERED_ON", nil, &vms); err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d powered on vms in %s", len(vms), vc.server), true)
+	vmIDs := []string{}
+	for _, vm := range vms {
+		vmIDs = append(vmIDs, vm.VM)
+	}
+
+	// Get the tags
+	vmTags, err := vc.vmTags(vmIDs)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the managed workloads
+	managedWklds, api, err := pce.GetWklds(map[string]string{"managed": "true"})
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	managedMap := make(map[string]string)
+	for _, w := range managedWklds {
+		managedMap[strings.ToLower(w.Hostname)] = w.Hostname
+		managedMap[strings.ToLower(strings.Split(w.Hostname, ".")[0])] = w.Hostname
+	}
+
+	// Get the label keys in the mapping for the wkld-import header
+	labelKeys := []string{}
+	for _, k := range mapping {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	labelData := [][]string{append([]string{wkldexport.HeaderHostname}, labelKeys...)}
+
+	workloads := []umwlsync.Workload{}
+	for _, vm := range vms {
+
+		// Guest identity and interfaces are not available without vmware tools
+		var identity guestIdentity
+		if err := vc.call("GET", fmt.Sprintf("/api/vcenter/vm/%s/guest/identity", vm.VM), nil, &identity); err != nil {
+			utils.LogInfo(fmt.Sprintf("%s - guest identity not available - %s", vm.Name, err), false)
+		}
+		var nics []guestInterface
+		if err := vc.call("GET", fmt.Sprintf("/api/vcenter/vm/%s/guest/networking/interfaces", vm.VM), nil, &nics); err != nil {
+			utils.LogInfo(fmt.Sprintf("%s - guest interfaces not available - %s", vm.Name, err), false)
+		}
+
+		attributes := map[string]string{"vcenter:guest-os": identity.FullName.DefaultMessage}
+		for category, tag := range vmTags[vm.VM] {
+			attributes[category] = tag
+		}
+		labels := umwlsync.MapLabels(attributes, mapping)
+
+		// Label the managed workload if it exists
+		hostname := identity.HostName
+		if hostname == "" {
+			hostname = vm.Name
+		}
+		pceHostname, managed := "", false
+		for _, h := range []string{hostname, strings.Split(hostname, ".")[0], vm.Name} {
+			if pceHostname, managed = managedMap[strings.ToLower(h)]; managed {
+				break
+			}
+		}
+		if managed {
+			row := []string{pceHostname}
+			for _, k := range labelKeys {
+				row = append(row, labels[k])
+			}
+			labelData = append(labelData, row)
+			continue
+		}
+
+		// Build the unmanaged workload
+		w := umwlsync.Workload{Hostname: hostname, Name: vm.Name, Description: fmt.Sprintf("vcenter vm %s - %s", vm.VM, identity.FullName.DefaultMessage), DataCenter: vc.server, Labels: labels, ExternalDataReference: fmt.Sprintf("%s/%s", vc.server, vm.VM)}
+		for n, nic := range nics {
+			for _, ip := range nic.IP.IPAddresses {
+				parsed := net.ParseIP(ip.IPAddress)
+				if parsed == nil || parsed.IsLinkLocalUnicast() || parsed.IsLoopback() {
+					continue
+				}
+				w.Interfaces = append(w.Interfaces, fmt.Sprintf("eth%d:%s/%d", n, ip.IPAddress, ip.PrefixLength))
+			}
+		}
+		if len(w.Interfaces) == 0 && identity.IPAddress != "" {
+			w.Interfaces = append(w.Interfaces, "eth0:"+identity.IPAddress)
+		}
+		workloads = append(workloads, w)
+	}
+
+	// Label the managed workloads
+	utils.LogInfo(fmt.Sprintf("%d vms match managed workloads", len(labelData)-1), true)
+	if len(labelData) > 1 && len(labelKeys) > 0 {
+		labelFile := fmt.Sprintf("workloader-vcenter-sync-managed-labels-%s.csv", time.Now().Format("20060102_150405"))
+		utils.WriteOutput(labelData, labelData, labelFile)
+		wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+			PCE:             pce,
+			Data:            labelData,
+			MatchString:     "hostname",
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			UpdateWorkloads: true,
+		})
+	}
+
+	// Sync the unmanaged workloads
+	if !noUMWL {
+		umwlsync.Sync(umwlsync.Input{
+			PCE:             pce,
+			Command:         "vcenter-sync",
+			ExternalDataSet: externalDataSet,
+			Workloads:       workloads,
+			Cleanup:         cleanup,
+			UpdatePCE:       updatePCE,
+			NoPrompt:        noPrompt,
+			OutputFileName:  outputFileName,
+		})
+	}
+
+	utils.LogEndCommand("vcenter-sync")
+}
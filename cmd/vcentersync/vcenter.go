@@ -0,0 +1,183 @@
+package vcentersync
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// vCenter calls the vCenter REST API (vSphere 7.0 and later)
+type vCenter struct {
+	server    string
+	sessionID string
+	client    *http.Client
+}
+
+// vcenterVM is a virtual machine from the vm list
+type vcenterVM struct {
+	VM         string `json:"vm"`
+	Name       string `json:"name"`
+	PowerState string `json:"power_state"`
+}
+
+// guestIdentity is the guest os identity reported by vmware tools
+type guestIdentity struct {
+	HostName  string `json:"host_name"`
+	IPAddress string `json:"ip_address"`
+	Family    string `json:"family"`
+	FullName  struct {
+		DefaultMessage string `json:"default_message"`
+	} `json:"full_name"`
+}
+
+// guestInterface is a guest nic reported by vmware tools
+type guestInterface struct {
+	MacAddress string `json:"mac_address"`
+	IP         struct {
+		IPAddresses []struct {
+			IPAddress    string `json:"ip_address"`
+			PrefixLength int    `json:"prefix_length"`
+			State        string `json:"state"`
+		} `json:"ip_addresses"`
+	} `json:"ip"`
+}
+
+type tagAssociation struct {
+	ObjectID struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"object_id"`
+	TagIDs []string `json:"tag_ids"`
+}
+
+type vcenterTag struct {
+	Name       string `json:"name"`
+	CategoryID string `json:"category_id"`
+}
+
+type vcenterCategory struct {
+	Name string `json:"name"`
+}
+
+// login creates a session
+func (v *vCenter) login(user, password string, insecure bool) error {
+	v.client = &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, Proxy: http.ProxyFromEnvironment}}
+	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s/api/session", v.server), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(user, password)
+	body, err := v.do(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, &v.sessionID)
+}
+
+// logout deletes the session
+func (v *vCenter) logout() {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("https://%s/api/session", v.server), nil)
+	if err != nil {
+		return
+	}
+	v.do(req)
+}
+
+// call sends a request to the api and unmarshals the response into result
+func (v *vCenter) call(method, path string, payload, result interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("https://%s%s", v.server, path), reqBody)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	body, err := v.do(req)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (v *vCenter) do(req *http.Request) ([]byte, error) {
+	if v.sessionID != "" {
+		req.Header.Set("vmware-api-session-id", v.sessionID)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s - %d - %s", req.Method, req.URL.Path, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// vmTags returns a map of vm id to a map of tag category to tag name
+func (v *vCenter) vmTags(vmIDs []string) (map[string]map[string]string, error) {
+	vmTags := make(map[string]map[string]string)
+	if len(vmIDs) == 0 {
+		return vmTags, nil
+	}
+
+	type objectID struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	objects := []objectID{}
+	for _, id := range vmIDs {
+		objects = append(objects, objectID{ID: id, Type: "VirtualMachine"})
+	}
+	associations := []tagAssociation{}
+	if err := v.call("POST", "/api/cis/tagging/tag-association?action=list-attached-tags-on-objects", map[string]interface{}{"object_ids": objects}, &associations); err != nil {
+		return nil, err
+	}
+
+	// Look up each tag and category once
+	tags := make(map[string]vcenterTag)
+	categories := make(map[string]string)
+	for _, a := range associations {
+		for _, tagID := range a.TagIDs {
+			tag, ok := tags[tagID]
+			if !ok {
+				if err := v.call("GET", "/api/cis/tagging/tag/"+tagID, nil, &tag); err != nil {
+					return nil, err
+				}
+				tags[tagID] = tag
+			}
+			if _, ok := categories[tag.CategoryID]; !ok {
+				var c vcenterCategory
+				if err := v.call("GET", "/api/cis/tagging/category/"+tag.CategoryID, nil, &c); err != nil {
+					return nil, err
+				}
+				categories[tag.CategoryID] = c.Name
+			}
+			if vmTags[a.ObjectID.ID] == nil {
+				vmTags[a.ObjectID.ID] = make(map[string]string)
+			}
+			vmTags[a.ObjectID.ID][categories[tag.CategoryID]] = tag.Name
+		}
+	}
+
+	return vmTags, nil
+}
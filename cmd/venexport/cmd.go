@@ -44,12 +44,26 @@ func exportVens() {
 	// Start the data slice with headers
 	csvData := [][]string{{HeaderName, HeaderHostname, HeaderDescription, HeaderVenType, HeaderStatus, HeaderHealth, HeaderVersion, HeaderActivationType, HeaderActivePceFqdn, HeaderTargetPceFqdn, HeaderWorkloads, HeaderContainerCluster, HeaderHref, HeaderUID}}
 
-	// Load the PCE
-	apiResps, err := pce.Load(illumioapi.LoadInput{Workloads: true, WorkloadsQueryParameters: map[string]string{"managed": "true"}, VENs: true, ContainerClusters: true, ContainerWorkloads: true})
-	utils.LogMultiAPIResp(apiResps)
+	// Load the PCE with each object type in parallel
+	inputs := []illumioapi.LoadInput{{Workloads: true, WorkloadsQueryParameters: map[string]string{"managed": "true"}}, {VENs: true}, {ContainerClusters: true}, {ContainerWorkloads: true}}
+	loaded, err := utils.GetPages(len(inputs), func(i int) (illumioapi.PCE, illumioapi.APIResponse, error) {
+		p := pce
+		apiResps, err := p.Load(inputs[i])
+		utils.LogMultiAPIResp(apiResps)
+		// Each input loads one object type so there is one response
+		var a illumioapi.APIResponse
+		for _, r := range apiResps {
+			a = r
+		}
+		return p, a, err
+	})
 	if err != nil {
 		utils.LogError(err.Error())
 	}
+	pce.Workloads, pce.WorkloadsSlice = loaded[0].Workloads, loaded[0].WorkloadsSlice
+	pce.VENs, pce.VENsSlice = loaded[1].VENs, loaded[1].VENsSlice
+	pce.ContainerClusters, pce.ContainerClustersSlice = loaded[2].ContainerClusters, loaded[2].ContainerClustersSlice
+	pce.ContainerWorkloads, pce.ContainerWorkloadsSlice = loaded[3].ContainerWorkloads, loaded[3].ContainerWorkloadsSlice
 
 	for _, v := range pce.VENsSlice {
 
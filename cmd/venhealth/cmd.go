@@ -2,6 +2,7 @@ package venhealth
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,10 +14,15 @@ import (
 )
 
 var pce illumioapi.PCE
+var hec utils.HECConfig
+var jira utils.JiraInput
 var err error
 var start, end, customEventList, outputFileName string
 var yesterday, lastWeek, lastMonth, includeEventList bool
 var maxResults int
+var watch time.Duration
+var limits thresholds
+var alertSeverities string
 var yesterdayStart, yesterdayEnd, lastWeekStart, lastWeekEnd, lastMonthStart, lastMonthEnd string
 
 var venHealthEvents []string = []string{
@@ -54,7 +60,22 @@ func init() {
 	VenHealthCmd.Flags().BoolVar(&includeEventList, "include-event-list", false, "include output of full event list with th summarized report.")
 	VenHealthCmd.Flags().StringVar(&customEventList, "custom-event-list", "", fmt.Sprintf("text file with events on separate lines to override the default %d events", len(venHealthEvents)))
 	VenHealthCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
-
+	VenHealthCmd.Flags().DurationVar(&watch, "watch", 0, "re-run the report on this interval (e.g., 15m) until stopped and write only the agents that changed since the previous run. without --end, each run ends at the time of the run.")
+
+	VenHealthCmd.Flags().DurationVar(&limits.heartbeatAge, "max-heartbeat-age", 0, "threshold for the time since a ven's last heartbeat (e.g., 1h).")
+	VenHealthCmd.Flags().DurationVar(&limits.policySyncAge, "max-policy-sync-age", 0, "threshold for the time a ven that is not in sync has gone since policy was last applied (e.g., 4h).")
+	VenHealthCmd.Flags().StringVar(&limits.minVersion, "min-ven-version", "", "threshold for the lowest ven version (e.g., 22.5.10).")
+	VenHealthCmd.Flags().StringVar(&alertSeverities, "alert-severities", "", "comma-separated ven health condition severities that breach a threshold (error, warning, info).")
+
+	VenHealthCmd.Flags().StringVar(&hec.URL, "splunk-hec-url", "", "splunk http event collector url (e.g., https://splunk.company.com:8088) to send each output row to as an event.")
+	VenHealthCmd.Flags().StringVar(&hec.Token, "splunk-hec-token", "", "splunk http event collector token. default is the SPLUNK_HEC_TOKEN environment variable.")
+	VenHealthCmd.Flags().StringVar(&hec.SourceType, "splunk-sourcetype", "workloader:ven-health", "sourcetype for splunk events.")
+	VenHealthCmd.Flags().StringVar(&hec.Index, "splunk-index", "", "splunk index for events. default is the index of the token.")
+	VenHealthCmd.Flags().BoolVar(&hec.Insecure, "splunk-insecure", false, "do not validate the splunk certificate.")
+	VenHealthCmd.Flags().BoolVar(&jira.Enabled, "jira", false, "create a jira issue for each finding. findings with an unresolved issue are skipped. requires jira_url and jira_token in pce.yaml or WORKLOADER_JIRA_ environment variables.")
+	VenHealthCmd.Flags().StringVar(&jira.Project, "jira-project", "", "jira project key. default is jira_project in pce.yaml or the WORKLOADER_JIRA_PROJECT environment variable.")
+	VenHealthCmd.Flags().StringVar(&jira.GroupBy, "jira-group-by", "", "output header to create one issue per group instead of per agent (e.g., events).")
+	VenHealthCmd.Flags().StringVar(&jira.Summary, "jira-summary", "", "go template for the issue summary using output headers as fields (e.g., {{.hostname}}). grouped issues also have {{.group}} and {{.count}}.")
 	VenHealthCmd.Flags().SortFlags = false
 }
 
@@ -65,6 +86,8 @@ var VenHealthCmd = &cobra.Command{
 	Long: `
 Create a CSV report of VEN health events for specific time period
 
+Thresholds check every managed workload's ven against limits so ven-health can drive monitoring. Set any of --max-heartbeat-age, --max-policy-sync-age, --min-ven-version, and --alert-severities. Each breach is written to workloader-ven-health-thresholds-<timestamp>.csv, logged as a warning, included in the --notify summary, and emitted to webhook subscriptions as a ` + EventThresholdBreached + ` event. The command exits with 3 (partial_failure) if any threshold is breached.
+
 The monitored events are listed below:` + "\r\n\r\n" + strings.Join(venHealthEvents, "\r\n"),
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -78,6 +101,10 @@ The monitored events are listed below:` + "\r\n\r\n" + strings.Join(venHealthEve
 			utils.LogError(err.Error())
 		}
 
+		if err := limits.validate(alertSeverities); err != nil {
+			utils.LogErrorCode(utils.ExitValidation, err.Error())
+		}
+
 		// Disable stdout
 		viper.Set("output_format", "csv")
 		if err := viper.WriteConfig(); err != nil {
@@ -96,11 +123,27 @@ The monitored events are listed below:` + "\r\n\r\n" + strings.Join(venHealthEve
 			}
 		}
 
-		eventMonitor(venHealthEvents)
+		if watch > 0 {
+			utils.Watch("ven-health", watch, []string{"agent_href"}, func(write bool) [][]string {
+				data := eventMonitor(venHealthEvents, write)
+				if limits.set() {
+					checkThresholds(true)
+				}
+				return data
+			})
+		} else {
+			eventMonitor(venHealthEvents, true)
+			if limits.set() {
+				checkThresholds(true)
+			}
+		}
+
+		utils.LogEndCommand("ven-health")
 	},
 }
 
-func eventMonitor(targetEvents []string) {
+// eventMonitor queries the events and returns the agent details. The report files are written when write is true.
+func eventMonitor(targetEvents []string, write bool) [][]string {
 
 	// Create the output slice
 	allEvents := []illumioapi.Event{}
@@ -131,6 +174,9 @@ func eventMonitor(targetEvents []string) {
 		utils.LogInfo("custom start and end used", false)
 		qp["timestamp[gte]"] = start
 		qp["timestamp[lte]"] = end
+		if watch > 0 && end == "" {
+			qp["timestamp[lte]"] = time.Now().Format(time.RFC3339)
+		}
 	}
 	utils.LogInfo(fmt.Sprintf("start: %s", qp["timestamp[gte]"]), true)
 	utils.LogInfo(fmt.Sprintf("end: %s", qp["timestamp[lte]"]), true)
@@ -169,6 +215,7 @@ func eventMonitor(targetEvents []string) {
 	}
 
 	// Output the CSV
+	jiraData := [][]string{{"agent_href", "agent_hostname", "events"}}
 	if len(agentMap) > 0 {
 		csvOut := [][]string{{"start:", qp["timestamp[gte]"], ""}, {"end:", qp["timestamp[lte]"], ""}, {"", "", ""}, {"summary", "", ""}}
 		for event, summary := range summaryMap {
@@ -186,16 +233,30 @@ func eventMonitor(targetEvents []string) {
 			for u := range unniqueEvents {
 				uniqueEventsSlice = append(uniqueEventsSlice, fmt.Sprintf("%s (%d)", u, agentCount[agent.Href+u]))
 			}
+			sort.Strings(uniqueEventsSlice)
 
 			csvOut = append(csvOut, []string{agent.Href, agent.Hostname, strings.Join(uniqueEventsSlice, "; ")})
+			jiraData = append(jiraData, []string{agent.Href, agent.Hostname, strings.Join(uniqueEventsSlice, "; ")})
 		}
 		if outputFileName == "" {
 			outputFileName = "workloader-ven-health-summary-report-" + time.Now().Format("20060102_150405") + ".csv"
 		}
-		utils.WriteOutput(csvOut, csvOut, outputFileName)
+		if write {
+			utils.WriteOutput(csvOut, csvOut, outputFileName)
+		}
+		if write && hec.URL != "" {
+			if err := utils.SendHEC(hec, "ven-health", csvOut); err != nil {
+				utils.LogWarning(fmt.Sprintf("sending to splunk hec - %s", err), true)
+			}
+		}
+		if jira.Enabled {
+			if err := utils.FileJiraIssues(jira, "ven-health", jiraData, []string{"agent_href"}, "ven health events on {{.agent_hostname}}", "{{.count}} vens with ven health events - {{.group}}"); err != nil {
+				utils.LogWarning(fmt.Sprintf("creating jira issues - %s", err), true)
+			}
+		}
 	}
 
-	if includeEventList && len(allEvents) > 0 {
+	if write && includeEventList && len(allEvents) > 0 {
 		csvOut := [][]string{[]string{"event_type", "timestamp", "created_by_href", "created_by_details"}}
 		for _, e := range allEvents {
 			csvOut = append(csvOut, []string{e.EventType, time.Time.String(e.Timestamp), e.EventCreatedBy.Href, e.EventCreatedBy.Name})
@@ -206,7 +267,12 @@ func eventMonitor(targetEvents []string) {
 			outputFileName = "full-event-list-" + outputFileName
 		}
 		utils.WriteOutput(csvOut, csvOut, outputFileName)
+		if hec.URL != "" {
+			if err := utils.SendHEC(hec, "ven-health", csvOut); err != nil {
+				utils.LogWarning(fmt.Sprintf("sending to splunk hec - %s", err), true)
+			}
+		}
 	}
 
-	utils.LogEndCommand("event-monitor")
+	return jiraData
 }
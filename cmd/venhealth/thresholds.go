@@ -0,0 +1,159 @@
+package venhealth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// EventThresholdBreached is emitted to webhook subscriptions for each VEN that breaches a threshold
+const EventThresholdBreached = "ven-health.threshold_breached"
+
+// thresholds are the limits VENs are checked against. A zero value is not checked.
+type thresholds struct {
+	heartbeatAge  time.Duration
+	policySyncAge time.Duration
+	minVersion    string
+	severities    []string
+}
+
+// set returns true if any threshold is set
+func (t thresholds) set() bool {
+	return t.heartbeatAge > 0 || t.policySyncAge > 0 || t.minVersion != "" || len(t.severities) > 0
+}
+
+// validate checks the threshold flags
+func (t *thresholds) validate(severities string) error {
+	if t.heartbeatAge < 0 || t.policySyncAge < 0 {
+		return fmt.Errorf("--max-heartbeat-age and --max-policy-sync-age must be positive")
+	}
+	if t.minVersion != "" && len(versionParts(t.minVersion)) == 0 {
+		return fmt.Errorf("%s is not a valid --min-ven-version", t.minVersion)
+	}
+	for _, s := range strings.Split(strings.ToLower(strings.ReplaceAll(severities, " ", "")), ",") {
+		if s == "" {
+			continue
+		}
+		if s != "error" && s != "warning" && s != "info" {
+			return fmt.Errorf("%s is not a valid severity - must be error, warning, or info", s)
+		}
+		t.severities = append(t.severities, s)
+	}
+	return nil
+}
+
+// checkThresholds checks the managed workloads against the thresholds. Each breach is a failure so the command exits with a
+// partial failure (3), is sent to --notify, and is emitted to webhook subscriptions. The breaches are written when write is true.
+func checkThresholds(write bool) [][]string {
+	utils.LogInfo("getting managed workloads to check thresholds", true)
+	wklds, a, err := pce.GetWklds(map[string]string{"managed": "true"})
+	utils.LogAPIResp("GetWklds", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	now := time.Now()
+	data := [][]string{{"hostname", "href", "ven_version", "threshold", "value", "limit"}}
+	vens := make(map[string]bool)
+	for _, w := range wklds {
+		for _, b := range breaches(w, now) {
+			data = append(data, append([]string{w.Hostname, w.Href, agentStatus(w).AgentVersion}, b...))
+			vens[w.Href] = true
+			msg := fmt.Sprintf("%s - %s threshold breached - %s (limit %s)", w.Hostname, b[0], b[1], b[2])
+			utils.LogWarning(msg, false)
+			utils.RecordFailure(msg)
+			utils.EmitEvent(EventThresholdBreached, msg, w.Href, map[string]interface{}{"hostname": w.Hostname, "threshold": b[0], "value": b[1], "limit": b[2]})
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("%d of %d vens breached thresholds with %d breaches", len(vens), len(wklds), len(data)-1), true)
+	if write && len(data) > 1 {
+		utils.WriteOutput(data, data, fmt.Sprintf("workloader-ven-health-thresholds-%s.csv", time.Now().Format("20060102_150405")))
+	}
+	return data
+}
+
+// breaches returns the threshold, value, and limit of each threshold a workload breaches
+func breaches(w illumioapi.Workload, now time.Time) [][]string {
+	b := [][]string{}
+	status := agentStatus(w)
+
+	if limits.heartbeatAge > 0 {
+		if hb, err := time.Parse(time.RFC3339, status.LastHeartbeatOn); err != nil {
+			b = append(b, []string{"heartbeat_age", "no heartbeat", limits.heartbeatAge.String()})
+		} else if age := now.Sub(hb); age > limits.heartbeatAge {
+			b = append(b, []string{"heartbeat_age", age.Round(time.Second).String(), limits.heartbeatAge.String()})
+		}
+	}
+
+	// A VEN that is in sync is not checked. Otherwise the age is the time since policy was last applied.
+	if limits.policySyncAge > 0 && status.SecurityPolicySyncState != "active" {
+		if applied, err := time.Parse(time.RFC3339, status.SecurityPolicyAppliedAt); err != nil {
+			b = append(b, []string{"policy_sync_age", fmt.Sprintf("%s with no policy applied", status.SecurityPolicySyncState), limits.policySyncAge.String()})
+		} else if age := now.Sub(applied); age > limits.policySyncAge {
+			b = append(b, []string{"policy_sync_age", fmt.Sprintf("%s for %s", status.SecurityPolicySyncState, age.Round(time.Second)), limits.policySyncAge.String()})
+		}
+	}
+
+	if limits.minVersion != "" {
+		version := status.AgentVersion
+		if w.VEN != nil && w.VEN.Version != "" {
+			version = w.VEN.Version
+		}
+		if versionLess(version, limits.minVersion) {
+			b = append(b, []string{"ven_version", version, limits.minVersion})
+		}
+	}
+
+	for _, s := range limits.severities {
+		for _, h := range status.AgentHealth {
+			if h != nil && strings.EqualFold(h.Severity, s) {
+				b = append(b, []string{"condition_severity", fmt.Sprintf("%s (%s)", h.Type, h.Severity), strings.Join(limits.severities, ";")})
+			}
+		}
+	}
+	return b
+}
+
+// agentStatus returns the agent status of a workload or an empty status
+func agentStatus(w illumioapi.Workload) illumioapi.Status {
+	if w.Agent == nil || w.Agent.Status == nil {
+		return illumioapi.Status{}
+	}
+	return *w.Agent.Status
+}
+
+// versionParts returns the numbers of a version (e.g., 22.5.10-1234 is 22, 5, 10, 1234)
+func versionParts(version string) []int {
+	parts := []int{}
+	for _, p := range strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' }) {
+		i, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, i)
+	}
+	return parts
+}
+
+// versionLess returns true if version is lower than min. A version that cannot be parsed is lower.
+func versionLess(version, min string) bool {
+	v, m := versionParts(version), versionParts(min)
+	if len(v) == 0 {
+		return true
+	}
+	for i := range m {
+		vi := 0
+		if i < len(v) {
+			vi = v[i]
+		}
+		if vi != m[i] {
+			return vi < m[i]
+		}
+	}
+	return false
+}
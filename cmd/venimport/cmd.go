@@ -2,7 +2,6 @@ package venimport
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -22,8 +21,9 @@ func init() {}
 
 // WkldImportCmd runs the upload command
 var VenImportCmd = &cobra.Command{
-	Use:   "ven-import [csv file to import]",
-	Short: "Update VENs from a CSV file.",
+	Use:         "ven-import [csv file to import]",
+	Short:       "Update VENs from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Update VENs from a CSV file.
 
@@ -49,7 +49,7 @@ Recommended to run without --update-pce first to log of what will change. If --u
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		importFile = args[0]
 
@@ -170,7 +170,7 @@ func importVens() {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if updatePCE && !noPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - %d vens requiring update in %s(%s). Do you want to run the import (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(vensToUpdate), pce.FriendlyName, viper.Get(pce.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n%s [PROMPT] - %d vens requiring update in %s(%s). Do you want to run the import (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(vensToUpdate), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo(fmt.Sprintf("prompt denied to update %d vens.", len(vensToUpdate)), true)
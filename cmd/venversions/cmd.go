@@ -0,0 +1,85 @@
+package venversions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+)
+
+var pce illumioapi.PCE
+var err error
+var groupBy, batchBy, batchOrder, targetVersion, eosFile, outputFileName, planFileName string
+var maxBatchSize int
+
+func init() {
+	VenVersionsCmd.Flags().StringVar(&groupBy, "group-by", "env,loc", "comma-separated label keys to summarize ven versions by.")
+	VenVersionsCmd.Flags().StringVar(&targetVersion, "target-version", "", "ven version for the upgrade plan. default is the highest version in the pce.")
+	VenVersionsCmd.Flags().StringVar(&eosFile, "eos-file", "", "csv file with ven releases (e.g., 21.2) in the first column and end-of-support dates (yyyy-mm-dd) in the second column. headers optional.")
+	VenVersionsCmd.Flags().StringVar(&batchBy, "batch-by", "env,loc", "comma-separated label keys to batch the upgrade plan by.")
+	VenVersionsCmd.Flags().StringVar(&batchOrder, "batch-order", "", "comma-separated label values of batches to upgrade first in order (e.g., DEV,TEST,PROD). other batches are after in alphabetical order.")
+	VenVersionsCmd.Flags().IntVar(&maxBatchSize, "max-batch-size", 0, "maximum vens in a batch. larger batches are split. 0 is no limit.")
+	VenVersionsCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the version summary output file location. default is current location with a timestamped filename.")
+	VenVersionsCmd.Flags().StringVar(&planFileName, "plan-file", "", "optionally specify the name of the upgrade plan output file location. batch files add the batch number to the name. default is current location with a timestamped filename.")
+	VenVersionsCmd.Flags().SortFlags = false
+}
+
+// VenVersionsCmd summarizes ven versions and creates an upgrade plan
+var VenVersionsCmd = &cobra.Command{
+	Use:   "ven-versions",
+	Short: "Summarize ven versions by labels and os and create a phased upgrade plan.",
+	Long: `
+Summarize the ven versions of managed workloads by labels and os and create a phased upgrade plan.
+
+The summary output has the number of vens for each --group-by label value, os, and ven version. Use --eos-file to flag releases past end of support. A release matches the versions that start with it (e.g., 21.2 matches 21.2.5-1234).
+
+The upgrade plan has each active ven below --target-version in a batch by the --batch-by labels. Batches are ordered by --batch-order and then alphabetically and split by --max-batch-size. The plan output has every ven with its batch for review, and a file for each batch has the vens of that batch with the ven href in the first column for the upgrade command's --host-file. For example:
+workloader upgrade --host-file workloader-ven-upgrade-plan-20240102_150405-batch-01.csv --version 22.5.10-1234
+
+Workloads without a label for a --group-by or --batch-by key have a blank value.
+
+The update-pce and --no-prompt flags are ignored for this command.`,
+	Example: `# Summarize ven versions and plan an upgrade to the highest version in the pce
+  workloader ven-versions
+
+  # Plan an upgrade batched by env with dev first and at most 100 vens per batch
+  workloader ven-versions --target-version 22.5.10-1234 --batch-by env --batch-order DEV,TEST,PROD --max-batch-size 100 --eos-file eos.csv`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if maxBatchSize < 0 {
+			utils.LogErrorCode(utils.ExitValidation, "--max-batch-size must be 0 or more")
+		}
+		if targetVersion != "" && len(versionParts(targetVersion)) == 0 {
+			utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("invalid --target-version %s. use the format 22.5.10-1234", targetVersion))
+		}
+		eos := []eosRelease{}
+		if eosFile != "" {
+			eos, err = parseEOSFile(eosFile)
+			if err != nil {
+				utils.LogErrorCode(utils.ExitValidation, err.Error())
+			}
+		}
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		utils.LogStartCommand("ven-versions")
+		venVersions(splitList(groupBy), splitList(batchBy), splitList(batchOrder), eos)
+		utils.LogEndCommand("ven-versions")
+	},
+}
+
+// splitList returns the values of a comma-separated flag
+func splitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
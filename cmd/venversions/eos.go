@@ -0,0 +1,53 @@
+package venversions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+// eosRelease is a ven release and its end-of-support date
+type eosRelease struct {
+	release string
+	date    time.Time
+}
+
+// parseEOSFile returns the releases in an end-of-support csv. A first row with a date that cannot be parsed is a header.
+func parseEOSFile(file string) ([]eosRelease, error) {
+	data, err := utils.ParseCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	releases := []eosRelease{}
+	for i, row := range data {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("%s - csv line %d - requires a release and an end-of-support date", file, i+1)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[1]))
+		if err != nil && i == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s - csv line %d - invalid end-of-support date %s. use yyyy-mm-dd", file, i+1, row[1])
+		}
+		releases = append(releases, eosRelease{release: strings.TrimSpace(row[0]), date: date})
+	}
+	return releases, nil
+}
+
+// endOfSupport returns the end-of-support date of the longest release that matches the version. A release matches the version if it is
+// the same or the version starts with the release and a dot or dash (e.g., 21.2 matches 21.2.5-1234 but not 21.20.1).
+func endOfSupport(version string, eos []eosRelease) (time.Time, bool) {
+	match := eosRelease{}
+	for _, e := range eos {
+		if version != e.release && !strings.HasPrefix(version, e.release+".") && !strings.HasPrefix(version, e.release+"-") {
+			continue
+		}
+		if len(e.release) > len(match.release) {
+			match = e
+		}
+	}
+	return match.date, match.release != ""
+}
@@ -0,0 +1,259 @@
+package venversions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// ven is a managed workload's ven
+type ven struct {
+	wkld            illumioapi.Workload
+	href, version   string
+	status, os      string
+	eos             time.Time
+	pastEOS, hasEOS bool
+}
+
+// batch is the vens of an upgrade batch
+type batch struct {
+	values []string
+	vens   []ven
+}
+
+func venVersions(groupKeys, batchKeys, order []string, eos []eosRelease) {
+
+	// Load the managed workloads
+	apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true, Workloads: true, WorkloadsQueryParameters: map[string]string{"managed": "true"}})
+	utils.LogMultiAPIResp(apiResps)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+
+	// Get the vens and the highest version
+	now := time.Now()
+	vens := []ven{}
+	highest := ""
+	for _, w := range pce.WorkloadsSlice {
+		if w.Deleted != nil && *w.Deleted {
+			continue
+		}
+		v := ven{wkld: w, os: "unknown"}
+		if w.Agent != nil && w.Agent.Status != nil {
+			v.version, v.status = w.Agent.Status.AgentVersion, w.Agent.Status.Status
+		}
+		if w.VEN != nil {
+			v.href = w.VEN.Href
+			if w.VEN.Version != "" {
+				v.version = w.VEN.Version
+			}
+			if w.VEN.Status != "" {
+				v.status = w.VEN.Status
+			}
+		}
+		if w.OsID != nil && *w.OsID != "" {
+			v.os = *w.OsID
+		}
+		v.eos, v.hasEOS = endOfSupport(v.version, eos)
+		v.pastEOS = v.hasEOS && v.eos.Before(now)
+		if len(versionParts(v.version)) > 0 && (highest == "" || versionLess(highest, v.version)) {
+			highest = v.version
+		}
+		vens = append(vens, v)
+	}
+	if targetVersion == "" {
+		targetVersion = highest
+	}
+	utils.LogInfo(fmt.Sprintf("%d managed workloads. target ven version is %s", len(vens), targetVersion), true)
+	if len(vens) == 0 {
+		return
+	}
+
+	// Summary output
+	type summary struct {
+		values  []string
+		os      string
+		version string
+		count   int
+		ven     ven
+	}
+	summaries := make(map[string]*summary)
+	for _, v := range vens {
+		values := labelValues(v.wkld, groupKeys)
+		k := strings.Join(append(append([]string{}, values...), v.os, v.version), "\x00")
+		if summaries[k] == nil {
+			summaries[k] = &summary{values: values, os: v.os, version: v.version, ven: v}
+		}
+		summaries[k].count++
+	}
+	sorted := []*summary{}
+	for _, s := range summaries {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		for x := range a.values {
+			if a.values[x] != b.values[x] {
+				return a.values[x] < b.values[x]
+			}
+		}
+		if a.os != b.os {
+			return a.os < b.os
+		}
+		return versionLess(a.version, b.version)
+	})
+	data := [][]string{append(append([]string{}, groupKeys...), "os", "ven_version", "vens", "past_end_of_support", "end_of_support")}
+	pastEOS := 0
+	for _, s := range sorted {
+		eosDate := ""
+		if s.ven.hasEOS {
+			eosDate = s.ven.eos.Format("2006-01-02")
+		}
+		if s.ven.pastEOS {
+			pastEOS += s.count
+		}
+		data = append(data, append(append([]string{}, s.values...), s.os, s.version, strconv.Itoa(s.count), strconv.FormatBool(s.ven.pastEOS), eosDate))
+	}
+	timestamp := time.Now().Format("20060102_150405")
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-ven-versions-%s.csv", timestamp)
+	}
+	utils.WriteOutput(data, data, outputFileName)
+	if len(eos) > 0 {
+		utils.LogInfo(fmt.Sprintf("%d vens are past end of support", pastEOS), true)
+	}
+
+	// Batch the active vens below the target version
+	if targetVersion == "" {
+		utils.LogInfo("no ven versions in the pce. no upgrade plan.", true)
+		return
+	}
+	batches := make(map[string]*batch)
+	skipped := 0
+	for _, v := range vens {
+		if !versionLess(v.version, targetVersion) {
+			continue
+		}
+		if v.status != "active" || v.href == "" {
+			skipped++
+			continue
+		}
+		values := labelValues(v.wkld, batchKeys)
+		k := strings.Join(values, "\x00")
+		if batches[k] == nil {
+			batches[k] = &batch{values: values}
+		}
+		batches[k].vens = append(batches[k].vens, v)
+	}
+	if skipped > 0 {
+		utils.LogInfo(fmt.Sprintf("%d vens below %s are not active and are not in the upgrade plan", skipped, targetVersion), true)
+	}
+	if len(batches) == 0 {
+		utils.LogInfo(fmt.Sprintf("no active vens are below %s", targetVersion), true)
+		return
+	}
+	plan := orderBatches(batches, order)
+
+	// Plan output and a host file for each batch
+	if planFileName == "" {
+		planFileName = fmt.Sprintf("workloader-ven-upgrade-plan-%s.csv", timestamp)
+	}
+	planData := [][]string{append(append([]string{"ven_href", "hostname", "wkld_href", "batch"}, batchKeys...), "os", "online", "current_ven_version", "target_ven_version", "past_end_of_support")}
+	upgrades := 0
+	for i, b := range plan {
+		batchData := [][]string{{"ven_href", "hostname", "current_ven_version"}}
+		for _, v := range b.vens {
+			planData = append(planData, append(append([]string{v.href, v.wkld.Hostname, v.wkld.Href, strconv.Itoa(i + 1)}, b.values...), v.os, strconv.FormatBool(v.wkld.Online), v.version, targetVersion, strconv.FormatBool(v.pastEOS)))
+			batchData = append(batchData, []string{v.href, v.wkld.Hostname, v.version})
+			upgrades++
+		}
+		utils.WriteOutput(batchData, batchData, fmt.Sprintf("%s-batch-%02d.csv", strings.TrimSuffix(planFileName, ".csv"), i+1))
+	}
+	utils.WriteOutput(planData, planData, planFileName)
+	utils.LogInfo(fmt.Sprintf("%d vens to upgrade to %s in %d batches", upgrades, targetVersion, len(plan)), true)
+}
+
+// orderBatches sorts the batches by the position of their earliest value in the order and then by their values. Vens in a batch are sorted
+// by hostname and batches larger than the max batch size are split.
+func orderBatches(batches map[string]*batch, order []string) []batch {
+	position := func(b *batch) int {
+		p := len(order)
+		for _, v := range b.values {
+			for i, o := range order {
+				if strings.EqualFold(v, o) && i < p {
+					p = i
+				}
+			}
+		}
+		return p
+	}
+	sorted := []*batch{}
+	for _, b := range batches {
+		sorted = append(sorted, b)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if pi, pj := position(sorted[i]), position(sorted[j]); pi != pj {
+			return pi < pj
+		}
+		return strings.Join(sorted[i].values, ",") < strings.Join(sorted[j].values, ",")
+	})
+
+	plan := []batch{}
+	for _, b := range sorted {
+		sort.Slice(b.vens, func(i, j int) bool { return b.vens[i].wkld.Hostname < b.vens[j].wkld.Hostname })
+		for len(b.vens) > 0 {
+			size := len(b.vens)
+			if maxBatchSize > 0 && size > maxBatchSize {
+				size = maxBatchSize
+			}
+			plan = append(plan, batch{values: b.values, vens: b.vens[:size]})
+			b.vens = b.vens[size:]
+		}
+	}
+	return plan
+}
+
+// labelValues returns the workload's label value for each key
+func labelValues(w illumioapi.Workload, keys []string) []string {
+	values := []string{}
+	for _, k := range keys {
+		values = append(values, w.GetLabelByKey(k, pce.Labels).Value)
+	}
+	return values
+}
+
+// versionParts returns the numbers of a version (e.g., 22.5.10-1234 is 22, 5, 10, 1234). It is nil if the version cannot be parsed.
+func versionParts(version string) []int {
+	parts := []int{}
+	for _, p := range strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' }) {
+		i, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, i)
+	}
+	return parts
+}
+
+// versionLess returns true if version is lower than min. A version that cannot be parsed is lower.
+func versionLess(version, min string) bool {
+	v, m := versionParts(version), versionParts(min)
+	if len(v) == 0 {
+		return true
+	}
+	for i := range m {
+		vi := 0
+		if i < len(v) {
+			vi = v[i]
+		}
+		if vi != m[i] {
+			return vi < m[i]
+		}
+	}
+	return false
+}
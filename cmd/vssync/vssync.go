@@ -0,0 +1,343 @@
+package vssync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+// VirtualService is a load balanced service discovered in an external source
+type VirtualService struct {
+	Name                  string
+	ExternalDataReference string
+	IPs                   []string
+	ServicePorts          []*illumioapi.ServicePort
+	Labels                map[string]string // Label key to label value
+	Backends              []Backend
+}
+
+// Backend is a real server behind a virtual service
+type Backend struct {
+	IP   string
+	Port int
+}
+
+// Input is the data structure the Sync function expects
+type Input struct {
+	PCE             illumioapi.PCE
+	Command         string // Used for logging and provisioning comments
+	ExternalDataSet string
+	VirtualServices []VirtualService
+	Cleanup         bool // Delete virtual services in the external data set that are not in VirtualServices
+	Bind            bool // Bind workloads matching backend IPs to the virtual services
+	UpdatePCE       bool
+	NoPrompt        bool
+}
+
+// Sync creates, updates, and optionally deletes virtual services in the external data set, provisions the changes, and optionally keeps the service bindings in sync with the backends.
+func Sync(input Input) {
+
+	pce := input.PCE
+
+	// Get the virtual services in the external data set
+	pceVirtualServices, api, err := pce.GetVirtualServices(map[string]string{"external_data_set": input.ExternalDataSet}, "draft")
+	utils.LogAPIResp("GetVirtualServices", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	existing := make(map[string]illumioapi.VirtualService)
+	for _, vs := range pceVirtualServices {
+		if vs.ExternalDataSet == input.ExternalDataSet {
+			existing[vs.ExternalDataReference] = vs
+		}
+	}
+
+	var createVS, updateVS, removeVS []illumioapi.VirtualService
+	desiredRefs := make(map[string]bool)
+	for _, d := range input.VirtualServices {
+		if desiredRefs[d.ExternalDataReference] {
+			utils.LogWarning(fmt.Sprintf("%s is a duplicate external data reference. skipping.", d.ExternalDataReference), true)
+			continue
+		}
+		desiredRefs[d.ExternalDataReference] = true
+		labels, err := labelHrefs(&pce, d.Labels, input.UpdatePCE)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		if vs, ok := existing[d.ExternalDataReference]; ok {
+			changes := []string{}
+			if strings.Join(vs.IPOverrides, ",") != strings.Join(d.IPs, ",") {
+				changes = append(changes, fmt.Sprintf("ip overrides from %s to %s", strings.Join(vs.IPOverrides, ";"), strings.Join(d.IPs, ";")))
+			}
+			if portString(vs.ServicePorts) != portString(d.ServicePorts) {
+				changes = append(changes, fmt.Sprintf("ports from %s to %s", portString(vs.ServicePorts), portString(d.ServicePorts)))
+			}
+			if labelString(vs.Labels) != labelString(labels) {
+				changes = append(changes, "labels")
+			}
+			if len(changes) > 0 {
+				utils.LogInfo(fmt.Sprintf("%s exists but requires updates - %s", vs.Name, strings.Join(changes, ". ")), true)
+				vs.IPOverrides, vs.ServicePorts, vs.Labels = d.IPs, d.ServicePorts, labels
+				updateVS = append(updateVS, vs)
+			}
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("%s to be created - ips: %s - ports: %s", d.Name, strings.Join(d.IPs, ";"), portString(d.ServicePorts)), true)
+		createVS = append(createVS, illumioapi.VirtualService{Name: d.Name, ApplyTo: "host_only", IPOverrides: d.IPs, ServicePorts: d.ServicePorts, Labels: labels, ExternalDataSet: input.ExternalDataSet, ExternalDataReference: d.ExternalDataReference})
+	}
+	if input.Cleanup {
+		for ref, vs := range existing {
+			if !desiredRefs[ref] {
+				utils.LogInfo(fmt.Sprintf("%s - %s - to be deleted", vs.Name, vs.Href), true)
+				removeVS = append(removeVS, vs)
+			}
+		}
+	}
+
+	if len(createVS)+len(updateVS)+len(removeVS) == 0 {
+		utils.LogInfo("no virtual service changes required", true)
+		if !input.Bind {
+			return
+		}
+		bindingChanges := syncBindings(&pce, input, false)
+		utils.LogInfo(fmt.Sprintf("%d service binding changes identified", bindingChanges), true)
+		if bindingChanges == 0 || !input.UpdatePCE {
+			return
+		}
+		if !input.NoPrompt {
+			var prompt string
+			fmt.Printf("\r\n%s [PROMPT] - workloader will make %d service binding changes in %s (%s). do you want to run the %s (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), bindingChanges, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName), input.Command)
+			fmt.Scanln(&prompt)
+			if strings.ToLower(prompt) != "yes" {
+				utils.LogInfo("prompt denied.", true)
+				return
+			}
+		}
+		utils.LogInfo(fmt.Sprintf("%d service binding changes made", syncBindings(&pce, input, true)), true)
+		return
+	}
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !input.UpdatePCE {
+		if input.Bind {
+			utils.LogInfo(fmt.Sprintf("%d service binding changes identified for existing virtual services. bindings for new virtual services are created after they are provisioned.", syncBindings(&pce, input, false)), true)
+		}
+		utils.LogInfo(fmt.Sprintf("workloader identified %d virtual services to create, %d to update, and %d to delete. see workloader.log for more details. to do the %s, run again using --update-pce flag.", len(createVS), len(updateVS), len(removeVS), input.Command), true)
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if input.UpdatePCE && !input.NoPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will create %d virtual services, update %d virtual services, and delete %d virtual services in %s (%s) and provision the changes. do you want to run the %s (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(createVS), len(updateVS), len(removeVS), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName), input.Command)
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied.", true)
+			return
+		}
+	}
+
+	provisionHrefs := []string{}
+	for _, vs := range createVS {
+		newVS, api, err := pce.CreateVirtualService(vs)
+		utils.LogAPIResp("CreateVirtualService", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("creating %s - %d status code - %s", vs.Name, api.StatusCode, api.RespBody), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("created %s - %s", newVS.Name, newVS.Href), true)
+		provisionHrefs = append(provisionHrefs, newVS.Href)
+	}
+	for _, vs := range updateVS {
+		api, err := pce.UpdateVirtualService(vs)
+		utils.LogAPIResp("UpdateVirtualService", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("updating %s - %d status code - %s", vs.Name, api.StatusCode, api.RespBody), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("updated %s - %s", vs.Name, vs.Href), true)
+		provisionHrefs = append(provisionHrefs, vs.Href)
+	}
+	for _, vs := range removeVS {
+		api, err := pce.DeleteHref(vs.Href)
+		utils.LogAPIResp("DeleteHref", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("deleting %s - %d status code - %s", vs.Name, api.StatusCode, api.RespBody), true)
+			continue
+		}
+		utils.LogInfo(fmt.Sprintf("deleted %s - %s", vs.Name, vs.Href), true)
+		provisionHrefs = append(provisionHrefs, vs.Href)
+	}
+
+	// Provision
+	if len(provisionHrefs) > 0 {
+		api, err := pce.ProvisionHref(provisionHrefs, "workloader "+input.Command)
+		utils.LogAPIResp("ProvisionHref", api)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+		utils.LogInfo(fmt.Sprintf("provisioned %d virtual services - %d", len(provisionHrefs), api.StatusCode), true)
+	}
+
+	// Bind the backends to the active virtual services
+	if input.Bind {
+		utils.LogInfo(fmt.Sprintf("%d service binding changes made", syncBindings(&pce, input, true)), true)
+	}
+}
+
+// syncBindings binds the workloads with an interface matching a backend ip to the active virtual service and removes bindings for workloads that are no longer backends.
+// If apply is false, the changes are only logged. The number of binding changes is returned.
+func syncBindings(pce *illumioapi.PCE, input Input, apply bool) int {
+
+	// Map the workload IPs
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	wkldIPMap := make(map[string]illumioapi.Workload)
+	for _, w := range wklds {
+		for _, i := range w.Interfaces {
+			wkldIPMap[i.Address] = w
+		}
+	}
+
+	// Get the active virtual services in the external data set
+	activeVS, api, err := pce.GetVirtualServices(map[string]string{"external_data_set": input.ExternalDataSet}, "active")
+	utils.LogAPIResp("GetVirtualServices", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	activeVSMap := make(map[string]illumioapi.VirtualService)
+	for _, vs := range activeVS {
+		if vs.ExternalDataSet == input.ExternalDataSet {
+			activeVSMap[vs.ExternalDataReference] = vs
+		}
+	}
+
+	changes := 0
+	for _, d := range input.VirtualServices {
+		vs, ok := activeVSMap[d.ExternalDataReference]
+		if !ok {
+			continue
+		}
+
+		// Build the desired bindings
+		desired := make(map[string]illumioapi.ServiceBinding)
+		for _, b := range d.Backends {
+			wkld, exists := wkldIPMap[b.IP]
+			if !exists {
+				utils.LogInfo(fmt.Sprintf("%s - backend %s is not a pce workload", d.Name, b.IP), false)
+				continue
+			}
+			sb := illumioapi.ServiceBinding{VirtualService: vs, Workload: illumioapi.Workload{Href: wkld.Href}}
+			if len(vs.ServicePorts) > 0 && b.Port != 0 && b.Port != vs.ServicePorts[0].Port {
+				sb.PortOverrides = []illumioapi.PortOverrides{{Port: vs.ServicePorts[0].Port, Proto: vs.ServicePorts[0].Protocol, NewPort: b.Port}}
+			}
+			desired[wkld.Href] = sb
+		}
+
+		// Get the existing bindings
+		existing, api, err := pce.GetServiceBindings(map[string]string{"virtual_service": vs.Href})
+		utils.LogAPIResp("GetServiceBindings", api)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("getting service bindings for %s - %s", d.Name, err), true)
+			continue
+		}
+		existingMap := make(map[string]illumioapi.ServiceBinding)
+		for _, sb := range existing {
+			existingMap[sb.Workload.Href] = sb
+		}
+
+		// Create the missing bindings
+		createBindings := []illumioapi.ServiceBinding{}
+		for href, sb := range desired {
+			if _, ok := existingMap[href]; !ok {
+				utils.LogInfo(fmt.Sprintf("%s - bind %s", d.Name, href), true)
+				createBindings = append(createBindings, sb)
+			}
+		}
+		changes = changes + len(createBindings)
+		if apply && len(createBindings) > 0 {
+			_, api, err := pce.CreateServiceBinding(createBindings)
+			utils.LogAPIResp("CreateServiceBinding", api)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("error creating bindings for %s - %d status code - %s", d.Name, api.StatusCode, api.RespBody), true)
+			}
+		}
+
+		// Remove the bindings that are no longer backends
+		for href, sb := range existingMap {
+			if _, ok := desired[href]; ok {
+				continue
+			}
+			utils.LogInfo(fmt.Sprintf("%s - unbind %s", d.Name, href), true)
+			changes++
+			if !apply {
+				continue
+			}
+			api, err := pce.DeleteHref(sb.Href)
+			utils.LogAPIResp("DeleteHref", api)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("error deleting binding %s - %d status code - %s", sb.Href, api.StatusCode, api.RespBody), true)
+			}
+		}
+	}
+
+	return changes
+}
+
+// labelHrefs returns the labels for the key/value map, creating labels that do not exist when updating the pce
+func labelHrefs(pce *illumioapi.PCE, labels map[string]string, create bool) ([]*illumioapi.Label, error) {
+	if pce.Labels == nil {
+		pce.Labels = make(map[string]illumioapi.Label)
+	}
+	hrefLabels := []*illumioapi.Label{}
+	for key, value := range labels {
+		label, ok := pce.Labels[key+value]
+		if !ok {
+			l, api, err := pce.GetLabelByKeyValue(key, value)
+			utils.LogAPIResp("GetLabelByKeyValue", api)
+			if err != nil {
+				return nil, err
+			}
+			if l.Href == "" && create {
+				l, api, err = pce.CreateLabel(illumioapi.Label{Key: key, Value: value})
+				utils.LogAPIResp("CreateLabel", api)
+				if err != nil {
+					return nil, err
+				}
+				utils.LogInfo(fmt.Sprintf("created label %s:%s - %s", key, value, l.Href), true)
+			}
+			label = l
+			pce.Labels[key+value] = label
+		}
+		if label.Href == "" {
+			label.Href = fmt.Sprintf("%s:%s", key, value)
+		}
+		hrefLabels = append(hrefLabels, &illumioapi.Label{Href: label.Href})
+	}
+	return hrefLabels, nil
+}
+
+func portString(ports []*illumioapi.ServicePort) string {
+	s := []string{}
+	for _, p := range ports {
+		s = append(s, fmt.Sprintf("%d/%d", p.Port, p.Protocol))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ";")
+}
+
+func labelString(labels []*illumioapi.Label) string {
+	s := []string{}
+	for _, l := range labels {
+		s = append(s, l.Href)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ";")
+}
@@ -0,0 +1,306 @@
+package vulnimport
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// finding is a vulnerability on a host normalized from the scanner export
+type finding struct {
+	ip          string
+	hostname    string
+	id          string
+	name        string
+	description string
+	score       int
+	port        int
+	proto       int
+	cves        []string
+}
+
+// parseFile parses a qualys csv, tenable csv, or nessus xml export. The source is returned when format is auto.
+func parseFile(filename, format string) ([]finding, string, error) {
+	if format == "nessus" || (format == "auto" && strings.ToLower(filepath.Ext(filename)) == ".nessus") {
+		f, err := parseNessus(filename)
+		return f, "nessus", err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Qualys reports have summary rows before the header row so find the header row
+	for i, row := range rows {
+		headers := make(map[string]int)
+		for c, h := range row {
+			headers[strings.ToLower(strings.TrimSpace(h))] = c
+		}
+		_, qid := headers["qid"]
+		_, pluginID := headers["plugin id"]
+		_, plugin := headers["plugin"]
+		if qid && (format == "auto" || format == "qualys") {
+			return parseRows(rows[i+1:], headers, qualysColumns, qualysSeverity), "qualys", nil
+		}
+		if (pluginID || plugin) && (format == "auto" || format == "tenable") {
+			return parseRows(rows[i+1:], headers, tenableColumns, tenableSeverity), "tenable", nil
+		}
+	}
+	return nil, "", fmt.Errorf("%s is not a recognized qualys or tenable csv export", filename)
+}
+
+// column names for each field in order of preference
+type columns struct {
+	host, dns, id, name, description, severity, port, proto, cve []string
+	cvss                                                         []string
+}
+
+var qualysColumns = columns{
+	host:        []string{"ip"},
+	dns:         []string{"dns", "netbios"},
+	id:          []string{"qid"},
+	name:        []string{"title"},
+	description: []string{"threat"},
+	severity:    []string{"severity"},
+	port:        []string{"port"},
+	proto:       []string{"protocol"},
+	cve:         []string{"cve id"},
+	cvss:        []string{"cvss3.1 base", "cvss3 base", "cvss base"},
+}
+
+var tenableColumns = columns{
+	host:        []string{"ip address", "host"},
+	dns:         []string{"dns name", "netbios name"},
+	id:          []string{"plugin id", "plugin"},
+	name:        []string{"name", "plugin name"},
+	description: []string{"synopsis", "description"},
+	severity:    []string{"risk", "severity"},
+	port:        []string{"port"},
+	proto:       []string{"protocol"},
+	cve:         []string{"cve"},
+	cvss:        []string{"cvss v3.0 base score", "cvss v2.0 base score"},
+}
+
+// qualysSeverity converts a qualys severity (1-5) to a score
+func qualysSeverity(s string) int {
+	switch strings.TrimSpace(s) {
+	case "5":
+		return 95
+	case "4":
+		return 75
+	case "3":
+		return 50
+	case "2":
+		return 25
+	}
+	return 0
+}
+
+// tenableSeverity converts a tenable risk (none, low, medium, high, critical) to a score
+func tenableSeverity(s string) int {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical", "4":
+		return 95
+	case "high", "3":
+		return 75
+	case "medium", "2":
+		return 50
+	case "low", "1":
+		return 25
+	}
+	return 0
+}
+
+// parseRows converts csv rows to findings. Rows for the same host, port, and id are combined.
+func parseRows(rows [][]string, headers map[string]int, cols columns, severity func(string) int) []finding {
+	get := func(row []string, names []string) string {
+		for _, n := range names {
+			if c, ok := headers[n]; ok && c < len(row) && strings.TrimSpace(row[c]) != "" {
+				return strings.TrimSpace(row[c])
+			}
+		}
+		return ""
+	}
+
+	findings := []finding{}
+	index := make(map[string]int)
+	for _, row := range rows {
+		f := finding{
+			id:          get(row, cols.id),
+			name:        get(row, cols.name),
+			description: get(row, cols.description),
+			port:        parsePort(get(row, cols.port)),
+			proto:       parseProto(get(row, cols.proto)),
+		}
+		if f.id == "" {
+			continue
+		}
+		host := get(row, cols.host)
+		if net.ParseIP(host) != nil {
+			f.ip = host
+			f.hostname = get(row, cols.dns)
+		} else {
+			f.hostname = host
+			if f.hostname == "" {
+				f.hostname = get(row, cols.dns)
+			}
+		}
+		f.score = parseCVSS(get(row, cols.cvss))
+		if f.score == 0 {
+			f.score = severity(get(row, cols.severity))
+		}
+		f.cves = parseCVEs(get(row, cols.cve))
+
+		key := fmt.Sprintf("%s|%s|%d|%d|%s", f.ip, f.hostname, f.port, f.proto, f.id)
+		if i, ok := index[key]; ok {
+			findings[i].cves = appendUnique(findings[i].cves, f.cves...)
+			continue
+		}
+		index[key] = len(findings)
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// nessusReport is the subset of a .nessus file used by vuln-import
+type nessusReport struct {
+	Hosts []struct {
+		Name       string `xml:"name,attr"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"HostProperties>tag"`
+		Items []struct {
+			Port       string   `xml:"port,attr"`
+			Protocol   string   `xml:"protocol,attr"`
+			Severity   string   `xml:"severity,attr"`
+			PluginID   string   `xml:"pluginID,attr"`
+			PluginName string   `xml:"pluginName,attr"`
+			Synopsis   string   `xml:"synopsis"`
+			CVEs       []string `xml:"cve"`
+			CVSS3      string   `xml:"cvss3_base_score"`
+			CVSS       string   `xml:"cvss_base_score"`
+		} `xml:"ReportItem"`
+	} `xml:"Report>ReportHost"`
+}
+
+// parseNessus parses a nessus v2 xml file
+func parseNessus(filename string) ([]finding, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	var report nessusReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing %s - %s", filename, err)
+	}
+
+	findings := []finding{}
+	for _, h := range report.Hosts {
+		ip, hostname := "", ""
+		for _, p := range h.Properties {
+			switch p.Name {
+			case "host-ip":
+				ip = p.Value
+			case "host-fqdn":
+				hostname = p.Value
+			}
+		}
+		if ip == "" && net.ParseIP(h.Name) != nil {
+			ip = h.Name
+		} else if hostname == "" {
+			hostname = h.Name
+		}
+		for _, i := range h.Items {
+			f := finding{ip: ip, hostname: hostname, id: i.PluginID, name: i.PluginName, description: strings.TrimSpace(i.Synopsis), port: parsePort(i.Port), proto: parseProto(i.Protocol), cves: i.CVEs}
+			f.score = parseCVSS(i.CVSS3)
+			if f.score == 0 {
+				f.score = parseCVSS(i.CVSS)
+			}
+			if f.score == 0 {
+				f.score = tenableSeverity(i.Severity)
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// parseCVSS converts a cvss score (e.g., 7.5 or "7.5 (AV:N/AC:L...)") to the PCE score of 0-100
+func parseCVSS(s string) int {
+	s = strings.TrimSpace(strings.Split(strings.TrimSpace(s), " ")[0])
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(v*10 + 0.5)
+}
+
+// parsePort returns 0 for general findings that are not on a port
+func parsePort(s string) int {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// parseProto converts a protocol name to the protocol number
+func parseProto(s string) int {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "tcp":
+		return 6
+	case "udp":
+		return 17
+	case "icmp":
+		return 1
+	}
+	return 0
+}
+
+// parseCVEs splits a cve column that may have several cves separated by commas, semicolons, or spaces
+func parseCVEs(s string) []string {
+	cves := []string{}
+	for _, c := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' || r == '\n' }) {
+		if strings.HasPrefix(strings.ToUpper(c), "CVE-") {
+			cves = append(cves, strings.ToUpper(c))
+		}
+	}
+	return cves
+}
+
+func appendUnique(s []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, e := range s {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
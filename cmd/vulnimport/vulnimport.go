@@ -0,0 +1,322 @@
+package vulnimport
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/cmd/wkldexport"
+	"github.com/brian1917/workloader/cmd/wkldimport"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Global variables
+var pce illumioapi.PCE
+var format, mode, labelKey, reportName, outputFileName string
+var minCVSS float64
+var authoritative, updatePCE, noPrompt bool
+var err error
+
+func init() {
+	VulnImportCmd.Flags().StringVar(&format, "format", "auto", "export format: auto, qualys, tenable, or nessus. auto uses the .nessus extension or the csv headers.")
+	VulnImportCmd.Flags().StringVar(&mode, "mode", "upload", "upload to send findings to the pce vulnerability api, label to apply a severity label, or both.")
+	VulnImportCmd.Flags().StringVar(&labelKey, "label-key", "vuln", "label key for the severity label in label mode.")
+	VulnImportCmd.Flags().Float64Var(&minCVSS, "min-cvss", 0, "ignore findings with a cvss score below this value. informational findings are always ignored.")
+	VulnImportCmd.Flags().StringVar(&reportName, "report-name", "", "name and reference id of the vulnerability report. default is the file name. uploading a report with the same name replaces it.")
+	VulnImportCmd.Flags().BoolVar(&authoritative, "authoritative", true, "mark the report as authoritative so it replaces previous vulnerability data for the scanned ips.")
+	VulnImportCmd.Flags().StringVar(&outputFileName, "output-file", "", "optionally specify the name of the output file location. default is current location with a timestamped filename.")
+	VulnImportCmd.Flags().SortFlags = false
+}
+
+// VulnImportCmd runs the vuln-import command
+var VulnImportCmd = &cobra.Command{
+	Use:         "vuln-import [qualys csv, tenable csv, or nessus file]",
+	Short:       "Import Qualys or Tenable vulnerability findings into the PCE or apply severity labels.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
+	Long: `
+Import Qualys or Tenable vulnerability findings into the PCE or apply severity labels.
+
+Supported files are Qualys scan report csv exports, Tenable.io and Tenable.sc vulnerability csv exports, and .nessus (v2) files. Informational findings are ignored.
+
+Findings are matched to workloads by ip address first and then hostname (full or short name, case insensitive). Findings that do not match a workload are logged and skipped.
+
+With --mode upload (default), each unique finding is created or updated as a vulnerability in the PCE with a reference id of the source and id (e.g., qualys-38170 or tenable-51192) and a vulnerability report is uploaded with the detected vulnerabilities. The PCE uses the report for vulnerability maps and the vulnerability data in wkld-export and rule reporting.
+
+With --mode label, each matched workload is labeled with its highest severity using the --label-key: critical (cvss 9.0+), high (7.0+), medium (4.0+), or low. The labels are applied with wkld-import.
+
+The output file has each matched finding.
+
+Recommended to run without --update-pce first to log of what will change.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		if len(args) != 1 {
+			fmt.Println("Command requires 1 argument for the vulnerability export file. See usage help.")
+			return
+		}
+		if format != "auto" && format != "qualys" && format != "tenable" && format != "nessus" {
+			utils.LogError("--format must be auto, qualys, tenable, or nessus")
+		}
+		if mode != "upload" && mode != "label" && mode != "both" {
+			utils.LogError("--mode must be upload, label, or both")
+		}
+
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		vulnImport(args[0])
+	},
+}
+
+// matchedFinding is a finding with its workload
+type matchedFinding struct {
+	finding
+	wkld illumioapi.Workload
+}
+
+func vulnImport(filename string) {
+
+	utils.LogStartCommand("vuln-import")
+
+	findings, source, err := parseFile(filename, format)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	utils.LogInfo(fmt.Sprintf("%d findings parsed from %s %s export", len(findings), filename, source), true)
+
+	// Get the workloads
+	wklds, api, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", api)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	nameMap := make(map[string]illumioapi.Workload)
+	ipMap := make(map[string]illumioapi.Workload)
+	dupeIPs := make(map[string]bool)
+	for _, w := range wklds {
+		if w.Hostname != "" {
+			nameMap[strings.ToLower(w.Hostname)] = w
+			nameMap[strings.ToLower(strings.Split(w.Hostname, ".")[0])] = w
+		}
+		for _, i := range w.Interfaces {
+			if _, ok := ipMap[i.Address]; ok {
+				dupeIPs[i.Address] = true
+			}
+			ipMap[i.Address] = w
+		}
+	}
+
+	// Match the findings
+	matched := []matchedFinding{}
+	unmatchedHosts := make(map[string]bool)
+	for _, f := range findings {
+		if f.score == 0 || float64(f.score) < minCVSS*10 {
+			continue
+		}
+		w, ok := ipMap[f.ip]
+		if !ok || dupeIPs[f.ip] {
+			w, ok = nameMap[strings.ToLower(f.hostname)]
+			if !ok {
+				w, ok = nameMap[strings.ToLower(strings.Split(f.hostname, ".")[0])]
+			}
+		}
+		if !ok || f.hostname == "" && f.ip == "" {
+			unmatchedHosts[f.ip+" "+f.hostname] = true
+			continue
+		}
+		matched = append(matched, matchedFinding{finding: f, wkld: w})
+	}
+	for h := range unmatchedHosts {
+		utils.LogInfo(fmt.Sprintf("%s does not match a workload", strings.TrimSpace(h)), false)
+	}
+	utils.LogInfo(fmt.Sprintf("%d findings match workloads. %d hosts do not match a workload.", len(matched), len(unmatchedHosts)), true)
+	if len(matched) == 0 {
+		utils.LogEndCommand("vuln-import")
+		return
+	}
+
+	// Write the output
+	data := [][]string{{"ip", "scanned_hostname", wkldexport.HeaderHostname, wkldexport.HeaderHref, "reference_id", "name", "cvss", "port", "proto", "cves"}}
+	for _, m := range matched {
+		data = append(data, []string{m.ip, m.hostname, m.wkld.Hostname, m.wkld.Href, referenceID(source, m.id), m.name, strconv.FormatFloat(float64(m.score)/10, 'f', 1, 64), strconv.Itoa(m.port), strconv.Itoa(m.proto), strings.Join(m.cves, ";")})
+	}
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-vuln-import-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	utils.WriteOutput(data, data, outputFileName)
+
+	if mode == "label" || mode == "both" {
+		applyLabels(matched)
+	}
+	if mode == "upload" || mode == "both" {
+		upload(matched, source, filename)
+	}
+
+	utils.LogEndCommand("vuln-import")
+}
+
+// referenceID returns the PCE reference id for a finding
+func referenceID(source, id string) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s", source, id))
+}
+
+// severity returns the severity label value for a score
+func severity(score int) string {
+	switch {
+	case score >= 90:
+		return "critical"
+	case score >= 70:
+		return "high"
+	case score >= 40:
+		return "medium"
+	}
+	return "low"
+}
+
+// applyLabels labels each workload with its highest severity
+func applyLabels(matched []matchedFinding) {
+	maxScore := make(map[string]int)
+	for _, m := range matched {
+		if m.score > maxScore[m.wkld.Href] {
+			maxScore[m.wkld.Href] = m.score
+		}
+	}
+	hrefs := []string{}
+	for h := range maxScore {
+		hrefs = append(hrefs, h)
+	}
+	sort.Strings(hrefs)
+	labelData := [][]string{{wkldexport.HeaderHref, labelKey}}
+	for _, h := range hrefs {
+		labelData = append(labelData, []string{h, severity(maxScore[h])})
+	}
+	labelFile := fmt.Sprintf("workloader-vuln-import-labels-%s.csv", time.Now().Format("20060102_150405"))
+	utils.WriteOutput(labelData, labelData, labelFile)
+	wkldimport.ImportWkldsFromCSV(wkldimport.Input{
+		PCE:             pce,
+		Data:            labelData,
+		MatchString:     wkldexport.HeaderHref,
+		UpdatePCE:       updatePCE,
+		NoPrompt:        noPrompt,
+		UpdateWorkloads: true,
+	})
+}
+
+// pceVuln is a vulnerability for the PCE vulnerabilities api
+type pceVuln struct {
+	Href        string   `json:"href,omitempty"`
+	Name        string   `json:"name"`
+	Score       int      `json:"score"`
+	Description string   `json:"description,omitempty"`
+	CveIds      []string `json:"cve_ids,omitempty"`
+}
+
+// pceVulnReport is a vulnerability report for the PCE vulnerability reports api
+type pceVulnReport struct {
+	Href                    string            `json:"href,omitempty"`
+	Name                    string            `json:"name"`
+	ReportType              string            `json:"report_type"`
+	Authoritative           bool              `json:"authoritative"`
+	ScannedIPs              []string          `json:"scanned_ips"`
+	DetectedVulnerabilities []detectedFinding `json:"detected_vulnerabilities"`
+}
+
+// hrefObj is an object reference in a PCE payload
+type hrefObj struct {
+	Href string `json:"href"`
+}
+
+// detectedFinding is a detected vulnerability in a vulnerability report
+type detectedFinding struct {
+	IPAddress     string   `json:"ip_address,omitempty"`
+	Port          int      `json:"port,omitempty"`
+	Proto         int      `json:"proto,omitempty"`
+	Workload      *hrefObj `json:"workload"`
+	Vulnerability *hrefObj `json:"vulnerability"`
+}
+
+// upload creates or updates the vulnerabilities and uploads the report
+func upload(matched []matchedFinding, source, filename string) {
+
+	// Build the unique vulnerabilities
+	vulns := make(map[string]*pceVuln)
+	refs := []string{}
+	for _, m := range matched {
+		ref := referenceID(source, m.id)
+		if v, ok := vulns[ref]; ok {
+			v.CveIds = appendUnique(v.CveIds, m.cves...)
+			continue
+		}
+		vulns[ref] = &pceVuln{Name: m.name, Score: m.score, Description: m.description, CveIds: m.cves}
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	if reportName == "" {
+		reportName = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	report := pceVulnReport{Name: reportName, ReportType: source, Authoritative: authoritative}
+	scanned := make(map[string]bool)
+	for _, m := range matched {
+		ip := m.ip
+		if ip == "" {
+			ip = m.wkld.GetIPWithDefaultGW()
+		}
+		if ip != "" && !scanned[ip] {
+			scanned[ip] = true
+			report.ScannedIPs = append(report.ScannedIPs, ip)
+		}
+		report.DetectedVulnerabilities = append(report.DetectedVulnerabilities, detectedFinding{
+			IPAddress:     ip,
+			Port:          m.port,
+			Proto:         m.proto,
+			Workload:      &hrefObj{Href: m.wkld.Href},
+			Vulnerability: &hrefObj{Href: fmt.Sprintf("/orgs/%d/vulnerabilities/%s", pce.Org, referenceID(source, m.id))},
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("workloader will create or update %d vulnerabilities and upload report %s with %d detected vulnerabilities on %d ips.", len(refs), reportName, len(report.DetectedVulnerabilities), len(report.ScannedIPs)), true)
+	if !updatePCE {
+		utils.LogInfo("see workloader.log for more details. to do the upload, run again using --update-pce flag.", true)
+		return
+	}
+	if !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will upload %d vulnerabilities and report %s to %s (%s). do you want to run the upload (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(refs), reportName, pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo("prompt denied", true)
+			return
+		}
+	}
+
+	for _, ref := range refs {
+		v := vulns[ref]
+		v.Href = fmt.Sprintf("/orgs/%d/vulnerabilities/%s", pce.Org, ref)
+		api, err := pce.Put(v)
+		utils.LogAPIResp("PutVulnerability", api)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("putting vulnerability %s - %s", ref, err))
+		}
+	}
+	utils.LogInfo(fmt.Sprintf("%d vulnerabilities created or updated", len(refs)), true)
+
+	report.Href = fmt.Sprintf("/orgs/%d/vulnerability_reports/%s", pce.Org, referenceID(source, reportName))
+	api, err := pce.Put(&report)
+	utils.LogAPIResp("PutVulnerabilityReport", api)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("putting vulnerability report %s - %s", reportName, err))
+	}
+	utils.LogInfo(fmt.Sprintf("uploaded vulnerability report %s", reportName), true)
+}
@@ -36,8 +36,9 @@ func init() {
 
 // WkldExportCmd runs the workload identifier
 var WkldExportCmd = &cobra.Command{
-	Use:   "wkld-export",
-	Short: "Create a CSV export of all workloads in the PCE.",
+	Use:         "wkld-export",
+	Short:       "Create a CSV export of all workloads in the PCE.",
+	Annotations: map[string]string{utils.AnnotationHrefsFile: "true"},
 	Long: `
 Create a CSV export of all workloads in the PCE.
 
@@ -73,11 +74,40 @@ func exportWorkloads() {
 	if onlineOnly {
 		qp["online"] = "true"
 	}
-	wklds, a, err := pce.GetWklds(qp)
-	utils.LogAPIResp("GetWklds", a)
+
+	// Get managed and unmanaged workloads in parallel unless only one is requested
+	queries := []map[string]string{qp}
+	if !managedOnly && !unmanagedOnly {
+		queries = []map[string]string{{"managed": "true"}, {"managed": "false"}}
+		for _, q := range queries {
+			for k, v := range qp {
+				q[k] = v
+			}
+		}
+	}
+	spinner := utils.NewProgress("getting workloads from the pce", 0)
+	pages, err := utils.GetPages(len(queries), func(i int) ([]illumioapi.Workload, illumioapi.APIResponse, error) {
+		p := pce
+		w, a, err := p.GetWklds(queries[i])
+		utils.LogAPIResp("GetWklds", a)
+		return w, a, err
+	})
+	spinner.Done()
 	if err != nil {
 		utils.LogError(fmt.Sprintf("getting all workloads - %s", err))
 	}
+	wklds := utils.MergePages(pages)
+
+	// Limit to the workloads in the hrefs file
+	if utils.HrefsFile() != "" {
+		hrefsWklds := []illumioapi.Workload{}
+		for _, w := range wklds {
+			if utils.WorkloadInHrefsFile(w) {
+				hrefsWklds = append(hrefsWklds, w)
+			}
+		}
+		wklds = hrefsWklds
+	}
 
 	// Get the labels that are in use by the workloads
 	labelsKeyMap := make(map[string]bool)
@@ -93,8 +123,7 @@ func exportWorkloads() {
 	// Sort the slice of label keys
 	sort.Strings(labelsKeySlice)
 
-	// Start the outputdata
-	outputData := [][]string{}
+	// Start the output headers
 	headerRow := []string{}
 	// If no user headers provided, get all the headers
 	if exportHeaders == "" {
@@ -105,11 +134,16 @@ func exportWorkloads() {
 				headerRow = append(headerRow, labelsKeySlice...)
 			}
 		}
-		outputData = append(outputData, headerRow)
 	} else {
-		outputData = append(outputData, strings.Split(strings.Replace(exportHeaders, " ", "", -1), ","))
+		headerRow = strings.Split(strings.Replace(exportHeaders, " ", "", -1), ",")
 	}
 
+	// Stream the rows to the output file as they are built
+	if outputFileName == "" {
+		outputFileName = fmt.Sprintf("workloader-wkld-export-%s.csv", time.Now().Format("20060102_150405"))
+	}
+	stream := utils.NewOutputStream(outputFileName, headerRow)
+
 	// Iterate through each workload
 	for _, w := range wklds {
 		csvRow := make(map[string]string)
@@ -231,19 +265,17 @@ func exportWorkloads() {
 		}
 
 		newRow := []string{}
-		for _, header := range outputData[0] {
+		for _, header := range headerRow {
 			newRow = append(newRow, csvRow[header])
 		}
-		outputData = append(outputData, newRow)
+		stream.Write(newRow)
 	}
 
-	if len(outputData) > 1 {
-		if outputFileName == "" {
-			outputFileName = fmt.Sprintf("workloader-wkld-export-%s.csv", time.Now().Format("20060102_150405"))
-		}
-		utils.WriteOutput(outputData, outputData, outputFileName)
-		utils.LogInfo(fmt.Sprintf("%d workloads exported", len(outputData)-1), true)
+	if stream.Rows() > 0 {
+		stream.Close()
+		utils.LogInfo(fmt.Sprintf("%d workloads exported", stream.Rows()), true)
 	} else {
+		stream.Discard()
 		// Log command execution for 0 results
 		utils.LogInfo("no workloads in PCE.", true)
 	}
@@ -0,0 +1,48 @@
+package wkldexport
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+)
+
+func TestExportWorkloads(t *testing.T) {
+	s := mockpce.Start(t, "")
+	dir := s.Configure(t)
+	outputFile := filepath.Join(dir, "wklds.csv")
+	if err := WkldExportCmd.Flags().Set("output-file", outputFile); err != nil {
+		t.Fatal(err)
+	}
+	WkldExportCmd.Run(WkldExportCmd, nil)
+
+	data, err := utils.ParseCSV(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Fatalf("got %d rows, want 3 workloads and a header", len(data)-1)
+	}
+	col := make(map[string]int)
+	for i, h := range data[0] {
+		col[h] = i
+	}
+	want := map[string]map[string]string{
+		"web1.example.com":    {"role": "WEB", "app": "ORDERING", "env": "PROD", "loc": "AWS", HeaderManaged: "true", HeaderActivePceFqdn: "localhost"},
+		"db1.example.com":     {"role": "DB", "app": "ORDERING", "env": "PROD", "loc": "AWS", HeaderManaged: "true", HeaderActivePceFqdn: "localhost"},
+		"legacy1.example.com": {"env": "DEV", HeaderManaged: "false", HeaderActivePceFqdn: "unmanaged"},
+	}
+	for _, row := range data[1:] {
+		fields, ok := want[row[col[HeaderHostname]]]
+		if !ok {
+			t.Errorf("unexpected workload %s", row[col[HeaderHostname]])
+			continue
+		}
+		for h, v := range fields {
+			if got := row[col[h]]; got != v {
+				t.Errorf("%s %s = %q, want %q", row[col[HeaderHostname]], h, got, v)
+			}
+		}
+	}
+}
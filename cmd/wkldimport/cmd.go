@@ -2,7 +2,6 @@ package wkldimport
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/brian1917/illumioapi"
 	"github.com/brian1917/workloader/cmd/wkldexport"
@@ -15,6 +14,7 @@ import (
 type Input struct {
 	PCE                                         illumioapi.PCE
 	ImportFile                                  string
+	Data                                        [][]string // rows to import instead of reading ImportFile (e.g., from a sync command)
 	RemoveValue                                 string
 	RolePrefix, AppPrefix, EnvPrefix, LocPrefix string
 	Headers                                     map[string]int
@@ -32,7 +32,14 @@ type importWkld struct {
 	compareString string
 	csvLine       []string
 	csvLineNum    int
-	change        bool
+	fields        []utils.FieldChange
+}
+
+// recordChange records field changes when an existing workload is being updated
+func (w *importWkld) recordChange(input Input, fields ...utils.FieldChange) {
+	if w.wkld.Href != "" && input.UpdateWorkloads {
+		w.fields = append(w.fields, fields...)
+	}
 }
 
 // input is a global variable for the wkld-import command's instance of Input
@@ -71,8 +78,9 @@ func init() {
 
 // WkldImportCmd runs the upload command
 var WkldImportCmd = &cobra.Command{
-	Use:   "wkld-import [csv file to import]",
-	Short: "Create and assign labels to existing workloads and/or create unmanaged workloads (using --umwl) from a CSV file.",
+	Use:         "wkld-import [csv file to import]",
+	Short:       "Create and assign labels to existing workloads and/or create unmanaged workloads (using --umwl) from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationCapabilities: utils.CapWorkloadWrite},
 	Long: `
 Create and assign labels to existing workloads and/or create unmanaged workloads (using --umwl) from a CSV file.
 
@@ -114,7 +122,7 @@ Recommended to run without --update-pce first to log what will change.`,
 		// Set the CSV file
 		if len(args) != 1 {
 			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
-			os.Exit(0)
+			utils.Exit(cmd.Name(), utils.ExitValidation)
 		}
 		input.ImportFile = args[0]
 
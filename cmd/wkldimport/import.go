@@ -10,7 +10,6 @@ import (
 	"github.com/brian1917/illumioapi"
 
 	"github.com/brian1917/workloader/utils"
-	"github.com/spf13/viper"
 )
 
 // ImportWkldsFromCSV imports a CSV to label unmanaged workloads and create unmanaged workloads
@@ -22,10 +21,13 @@ func ImportWkldsFromCSV(input Input) {
 	// Create a newLabels slice
 	var newLabels []illumioapi.Label
 
-	// Parse the CSV File
-	data, err := utils.ParseCSV(input.ImportFile)
-	if err != nil {
-		utils.LogError(err.Error())
+	// Parse the CSV File unless the rows are provided
+	data := input.Data
+	var err error
+	if data == nil {
+		if data, err = utils.ParseCSV(input.ImportFile); err != nil {
+			utils.LogError(err.Error())
+		}
 	}
 
 	// Process the headers and log in the input
@@ -94,11 +96,11 @@ func ImportWkldsFromCSV(input Input) {
 	}
 	utils.LogInfo(fmt.Sprintf("label keys map: %v", labelKeysMap), false)
 
-	// Create slices to hold the workloads we will update and create
-	updatedWklds := []illumioapi.Workload{}
-	newUMWLs := []illumioapi.Workload{}
+	// Create the change set to hold the workloads we will update and create
+	changes := utils.NewChangeSet[illumioapi.Workload]("workloads")
 
 	// Iterate through CSV entries
+	progress := utils.NewProgress("processing csv rows", len(data)-1)
 	for i, line := range data {
 
 		// Increment the counter
@@ -108,6 +110,7 @@ func ImportWkldsFromCSV(input Input) {
 		if csvLine == 1 {
 			continue
 		}
+		progress.Increment()
 
 		// SHOULD BE REMOVED WHEN PREFIX FLAGS ARE REMOVED - Process the prefixes to labels
 		prefixes := []string{input.RolePrefix, input.AppPrefix, input.EnvPrefix, input.LocPrefix}
@@ -183,39 +186,40 @@ func ImportWkldsFromCSV(input Input) {
 			if index, ok := input.Headers[header]; ok {
 				//&& utils.PtrToStr(*targetUpdates[i]) != ""
 				if w.csvLine[index] == input.RemoveValue && targetUpdates[i] != nil && utils.PtrToStr(*targetUpdates[i]) != "" {
-					if w.wkld.Href != "" {
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s - %s to be removed", w.csvLineNum, w.compareString, header), false)
-						w.change = true
-					}
+					w.recordChange(input, utils.FieldChange{Field: header, Old: utils.PtrToStr(*targetUpdates[i])})
 					**targetUpdates[i] = ""
 				} else if w.csvLine[index] != utils.PtrToStr(*targetUpdates[i]) && w.csvLine[index] != "" {
 					// The values don't equal each other and not using the remove value
-					if w.wkld.Href != "" {
-						logValue := utils.PtrToStr(*targetUpdates[i])
-						if logValue == "" {
-							logValue = "<empty>"
-						}
-						utils.LogInfo(fmt.Sprintf("csv line %d - %s - %s - %s to be changed from \"%s\" to \"%s\"", w.csvLineNum, w.wkld.Hostname, w.wkld.Href, header, logValue, w.csvLine[index]), false)
-						w.change = true
-					}
+					w.recordChange(input, utils.FieldChange{Field: header, Old: utils.PtrToStr(*targetUpdates[i]), New: w.csvLine[index]})
 					*targetUpdates[i] = &w.csvLine[index]
 				}
 
 			}
 		}
 
-		// Put into right slices
+		// Add to the change set
 		if w.wkld.Href == "" && input.Umwl {
-			newUMWLs = append(newUMWLs, *w.wkld)
-			utils.LogInfo(fmt.Sprintf("csv line %d - %s to be created", w.csvLineNum, w.compareString), false)
+			changes.Create(*w.wkld, w.compareString, w.csvLineNum)
 		}
-		if w.wkld.Href != "" && w.change && input.UpdateWorkloads {
-			updatedWklds = append(updatedWklds, *w.wkld)
+		if w.wkld.Href != "" && input.UpdateWorkloads {
+			changes.Update(*w.wkld, w.compareString, w.wkld.Href, w.fields, w.csvLineNum)
 		}
 	}
+	progress.Done()
+	changes.Log()
+
+	// Create slices of the workloads to update and create
+	updatedWklds := []illumioapi.Workload{}
+	for _, c := range changes.Updates {
+		updatedWklds = append(updatedWklds, c.Object)
+	}
+	newUMWLs := []illumioapi.Workload{}
+	for _, c := range changes.Creates {
+		newUMWLs = append(newUMWLs, c.Object)
+	}
 
 	// End run if we have nothing to do
-	if len(updatedWklds) == 0 && len(newUMWLs) == 0 {
+	if changes.Empty() {
 		utils.LogInfo("nothing to be done", true)
 		utils.LogEndCommand("wkld-import")
 		return
@@ -237,7 +241,7 @@ func ImportWkldsFromCSV(input Input) {
 	// If updatePCE is set, but not noPrompt, we will prompt the user.
 	if input.UpdatePCE && !input.NoPrompt {
 		var prompt string
-		fmt.Printf("\r\n%s [PROMPT] - Do you want to run the import to %s (%s) (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), input.PCE.FriendlyName, viper.Get(input.PCE.FriendlyName+".fqdn").(string))
+		fmt.Printf("\r\n%s [PROMPT] - Do you want to run the import to %s (%s) (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), input.PCE.FriendlyName, utils.PCEFQDN(input.PCE.FriendlyName))
 		fmt.Scanln(&prompt)
 		if strings.ToLower(prompt) != "yes" {
 			utils.LogInfo("prompt denied", true)
@@ -292,7 +296,9 @@ func ImportWkldsFromCSV(input Input) {
 	}
 
 	if len(updatedWklds) > 0 {
+		spinner := utils.NewProgress(fmt.Sprintf("bulk updating %d workloads", len(updatedWklds)), 0)
 		api, err := input.PCE.BulkWorkload(updatedWklds, "update", true)
+		spinner.Done()
 		for _, a := range api {
 			utils.LogAPIResp("BulkWorkloadUpdate", a)
 		}
@@ -304,7 +310,9 @@ func ImportWkldsFromCSV(input Input) {
 
 	// Bulk create if we have new workloads
 	if len(newUMWLs) > 0 {
+		spinner := utils.NewProgress(fmt.Sprintf("bulk creating %d unmanaged workloads", len(newUMWLs)), 0)
 		api, err := input.PCE.BulkWorkload(newUMWLs, "create", true)
+		spinner.Done()
 		for _, a := range api {
 			utils.LogAPIResp("BulkWorkloadCreate", a)
 
@@ -0,0 +1,78 @@
+package wkldimport
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+// TestImportWkldsChanges checks only workloads with changed values are sent to the pce
+func TestImportWkldsChanges(t *testing.T) {
+	s := mockpce.Start(t, "")
+	dir := s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	csvFile := filepath.Join(dir, "wklds.csv")
+	csv := "hostname,role,app,env,loc,interfaces\n" +
+		"web1.example.com,WEB,ORDERING,PROD,AWS,\n" +
+		"db1.example.com,WEB,ORDERING,PROD,AWS,\n" +
+		"new1.example.com,DB,ORDERING,DEV,AWS,eth0:10.0.0.99\n"
+	if err := os.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pce, err := utils.GetTargetPCE(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ImportWkldsFromCSV(Input{PCE: pce, ImportFile: csvFile, Umwl: true, UpdateWorkloads: true, UpdatePCE: true, NoPrompt: true})
+
+	writes := []string{}
+	hrefs := []string{}
+	for _, r := range s.Writes() {
+		writes = append(writes, r.Method+" "+r.Path)
+		if r.Path == "/orgs/1/workloads/bulk_update" {
+			var wklds []map[string]interface{}
+			json.Unmarshal([]byte(r.Body), &wklds)
+			for _, w := range wklds {
+				hrefs = append(hrefs, w["href"].(string))
+			}
+		}
+	}
+	want := []string{"PUT /orgs/1/workloads/bulk_update", "PUT /orgs/1/workloads/bulk_create"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("writes are %v, want %v", writes, want)
+	}
+	if !reflect.DeepEqual(hrefs, []string{"/orgs/1/workloads/2"}) {
+		t.Errorf("updated workloads are %v, want /orgs/1/workloads/2", hrefs)
+	}
+}
+
+// TestImportWkldsData checks rows passed in memory are imported without an import file
+func TestImportWkldsData(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	pce, err := utils.GetTargetPCE(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := [][]string{{"hostname", "role", "app", "env", "loc", "interfaces"}, {"new1.example.com", "DB", "ORDERING", "DEV", "AWS", "eth0:10.0.0.99"}}
+	ImportWkldsFromCSV(Input{PCE: pce, Data: data, Umwl: true, UpdateWorkloads: true, UpdatePCE: true, NoPrompt: true})
+
+	writes := []string{}
+	for _, r := range s.Writes() {
+		writes = append(writes, r.Method+" "+r.Path)
+	}
+	if !reflect.DeepEqual(writes, []string{"PUT /orgs/1/workloads/bulk_create"}) {
+		t.Errorf("writes are %v, want PUT /orgs/1/workloads/bulk_create", writes)
+	}
+}
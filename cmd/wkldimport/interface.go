@@ -145,10 +145,7 @@ func (w *importWkld) interfaces(input Input) {
 			}
 			if !userMap[iFace.Address+cidrText+iFace.Name] {
 				updateInterfaces = true
-				if w.wkld.Href != "" && input.UpdateWorkloads {
-					w.change = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - %s - interface not in csv and will be removed - ip: %s, cidr: %s, name: %s", w.csvLineNum, w.compareString, iFace.Address, cidrText, iFace.Name), false)
-				}
+				w.recordChange(input, utils.FieldChange{Field: "interfaces", Removed: []string{fmt.Sprintf("ip: %s, cidr: %s, name: %s", iFace.Address, cidrText, iFace.Name)}})
 			}
 		}
 
@@ -160,10 +157,7 @@ func (w *importWkld) interfaces(input Input) {
 			}
 			if !wkldIntMap[u.Address+cidrText+u.Name] {
 				updateInterfaces = true
-				if w.wkld.Href != "" && input.UpdateWorkloads {
-					w.change = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - %s - interface not in pce and will be added - ip: %s, cidr: %s, name: %s", w.csvLineNum, w.compareString, u.Address, cidrText, u.Name), false)
-				}
+				w.recordChange(input, utils.FieldChange{Field: "interfaces", Added: []string{fmt.Sprintf("ip: %s, cidr: %s, name: %s", u.Address, cidrText, u.Name)}})
 			}
 		}
 
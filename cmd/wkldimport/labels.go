@@ -74,11 +74,8 @@ func (w *importWkld) labels(input Input, newLabels []illumioapi.Label, labelKeys
 
 		// If the value is the delete value, the value is not blank, and the current label is not already blank, log a change without putting any label in.
 		if w.csvLine[index] == input.RemoveValue && w.csvLine[index] != "" && currentLabel.Href != "" {
-			// Log if updating
-			if w.wkld.Href != "" && input.UpdateWorkloads {
-				w.change = true
-				utils.LogInfo(fmt.Sprintf("csv line %d - %-s - %s label of %s to be removed.", w.csvLineNum, w.compareString, currentLabel.Key, currentLabel.Value), false)
-			}
+			// Record the change if updating
+			w.recordChange(input, utils.FieldChange{Field: currentLabel.Key + " label", Old: currentLabel.Value})
 			// Stop processing this label
 			continue
 		}
@@ -90,16 +87,8 @@ func (w *importWkld) labels(input Input, newLabels []illumioapi.Label, labelKeys
 			retrievedLabel, newLabels = checkLabel(input.PCE, illumioapi.Label{Key: headerValue, Value: w.csvLine[index]}, newLabels)
 			*w.wkld.Labels = append(*w.wkld.Labels, &illumioapi.Label{Href: retrievedLabel.Href})
 
-			// Log if updating
-			if w.wkld.Href != "" && input.UpdateWorkloads {
-				w.change = true
-				// Log change required
-				currentlLabelLogValue := currentLabel.Value
-				if currentLabel.Value == "" {
-					currentlLabelLogValue = "<empty>"
-				}
-				utils.LogInfo(fmt.Sprintf("csv line %d - %s - %s label to be changed from %s to %s.", w.csvLineNum, w.compareString, headerValue, currentlLabelLogValue, w.csvLine[index]), false)
-			}
+			// Record the change if updating
+			w.recordChange(input, utils.FieldChange{Field: headerValue + " label", Old: currentLabel.Value, New: w.csvLine[index]})
 		}
 	}
 	// Add the unprocessed labels if they were cleared
@@ -18,10 +18,7 @@ func (w *importWkld) enforcement(input Input) {
 				return
 			}
 			if w.wkld.EnforcementMode != m {
-				if w.wkld.Href != "" && input.UpdateWorkloads {
-					w.change = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - %s enforcement to be changed from %s to %s", w.csvLineNum, w.compareString, w.wkld.EnforcementMode, w.csvLine[index]), false)
-				}
+				w.recordChange(input, utils.FieldChange{Field: "enforcement", Old: w.wkld.EnforcementMode, New: m})
 				w.wkld.EnforcementMode = m
 			}
 		}
@@ -37,10 +34,7 @@ func (w *importWkld) visibility(input Input) {
 				return
 			}
 			if w.wkld.GetVisibilityLevel() != v {
-				if w.wkld.Href != "" && input.UpdateWorkloads {
-					w.change = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - %s visibility to be changed from %s to %s", w.csvLineNum, w.compareString, w.wkld.VisibilityLevel, w.csvLine[index]), false)
-				}
+				w.recordChange(input, utils.FieldChange{Field: "visibility", Old: w.wkld.GetVisibilityLevel(), New: v})
 				w.wkld.SetVisibilityLevel(v)
 			}
 		}
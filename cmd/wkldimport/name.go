@@ -1,8 +1,6 @@
 package wkldimport
 
 import (
-	"fmt"
-
 	"github.com/brian1917/workloader/cmd/wkldexport"
 	"github.com/brian1917/workloader/utils"
 )
@@ -12,10 +10,7 @@ func (w *importWkld) name(input Input) {
 		// It has to either be a new workload or not matching on name
 		if w.wkld.Name == "" || (input.MatchString != wkldexport.HeaderName) {
 			if w.wkld.Name != w.csvLine[index] {
-				if w.wkld.Href != "" && input.UpdateWorkloads {
-					w.change = true
-					utils.LogInfo(fmt.Sprintf("csv line %d - %s - name to be changed from %s to %s", w.csvLineNum, w.compareString, utils.LogBlankValue(w.wkld.Name), w.csvLine[index]), false)
-				}
+				w.recordChange(input, utils.FieldChange{Field: "name", Old: w.wkld.Name, New: w.csvLine[index]})
 				w.wkld.Name = w.csvLine[index]
 			}
 		}
@@ -14,10 +14,7 @@ func (w *importWkld) publcIP(input Input) {
 			if !publicIPIsValid(w.csvLine[index]) {
 				utils.LogError(fmt.Sprintf("csv line %d - invalid Public IP address format.", w.csvLineNum))
 			}
-			if w.wkld.Href != "" && input.UpdateWorkloads {
-				w.change = true
-				utils.LogInfo(fmt.Sprintf("csv line %d - %s- public ip to be changed from %s to %s", w.csvLineNum, w.compareString, utils.LogBlankValue(w.wkld.PublicIP), w.csvLine[index]), false)
-			}
+			w.recordChange(input, utils.FieldChange{Field: "public ip", Old: w.wkld.PublicIP, New: w.csvLine[index]})
 			w.wkld.PublicIP = w.csvLine[index]
 		}
 	}
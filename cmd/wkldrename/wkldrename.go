@@ -0,0 +1,315 @@
+package wkldrename
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Headers of the input file
+const (
+	HeaderHref        = "href"
+	HeaderOldHostname = "old_hostname"
+	HeaderOldName     = "old_name"
+	HeaderNewHostname = "new_hostname"
+	HeaderNewName     = "new_name"
+)
+
+// Global variables
+var csvFile string
+var updatePCE, noPrompt bool
+var pce illumioapi.PCE
+var err error
+
+// WkldRenameCmd renames workloads from a csv
+var WkldRenameCmd = &cobra.Command{
+	Use:         "wkld-rename [csv file with renames]",
+	Short:       "Rename the hostname and name of workloads from a CSV file.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true", utils.AnnotationHrefsFile: "true"},
+	Long: `
+Rename the hostname and name of workloads from a CSV file.
+
+The input file requires headers. The following headers are used (other headers are ignored):
+- ` + HeaderHref + `: workload href to rename.
+- ` + HeaderOldHostname + `: current hostname of the workload to rename. used when the href is blank or not a header.
+- ` + HeaderOldName + `: current name of the workload to rename. used when the href and old hostname are blank or not headers.
+- ` + HeaderNewHostname + `: new hostname. blank does not change the hostname.
+- ` + HeaderNewName + `: new name. blank does not change the name.
+
+Old hostnames and names are not case sensitive and must match one workload.
+
+The hostname of a managed workload is reported by the VEN and cannot be changed. A new hostname for a managed workload is skipped with a warning, and the new name is still used.
+
+Renames are checked for collisions after all renames in the file are applied. A rename is skipped if the new hostname or name would be the same as another workload's hostname or name (not case sensitive), including other renamed workloads. Workloads can swap names in the same file. A workload can only be in the file once.
+
+Recommended to run without --update-pce first to log of what will change. If --update-pce is used, wkld-rename will rename the workloads with a user prompt confirmation, unless --no-prompt is used.
+
+Example input:
+  href,old_hostname,new_hostname,new_name
+  /orgs/1/workloads/4b5c...,,,web01-prod
+  ,db-old.example.com,db01.example.com,db01
+
+Examples:
+  workloader wkld-rename renames.csv
+  workloader wkld-rename renames.csv --update-pce --no-prompt`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pce, err = utils.GetTargetPCE(true)
+		if err != nil {
+			utils.LogError(err.Error())
+		}
+
+		// Set the CSV file
+		if len(args) != 1 {
+			fmt.Println("Command requires 1 argument for the csv file. See usage help.")
+			utils.Exit(cmd.Name(), utils.ExitValidation)
+		}
+		csvFile = args[0]
+
+		// Get the debug value from viper
+		updatePCE = viper.Get("update_pce").(bool)
+		noPrompt = viper.Get("no_prompt").(bool)
+
+		wkldRename()
+	},
+}
+
+// rename is a row of the input file for a workload
+type rename struct {
+	line        int
+	wkld        illumioapi.Workload
+	newHostname string
+	newName     string
+}
+
+func wkldRename() {
+	// Log start of command
+	utils.LogStartCommand("wkld-rename")
+
+	// Parse the CSV
+	csvData, err := utils.ParseCSV(csvFile)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	if len(csvData) < 2 {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s has no renames", csvFile))
+	}
+
+	// Process the headers
+	headers := make(map[string]int)
+	for c, h := range csvData[0] {
+		headers[strings.ToLower(strings.TrimSpace(h))] = c
+	}
+	_, href := headers[HeaderHref]
+	_, oldHostname := headers[HeaderOldHostname]
+	_, oldName := headers[HeaderOldName]
+	_, newHostname := headers[HeaderNewHostname]
+	_, newName := headers[HeaderNewName]
+	if !href && !oldHostname && !oldName {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s, %s, or %s is a required header", HeaderHref, HeaderOldHostname, HeaderOldName))
+	}
+	if !newHostname && !newName {
+		utils.LogErrorCode(utils.ExitValidation, fmt.Sprintf("%s or %s is a required header", HeaderNewHostname, HeaderNewName))
+	}
+	value := func(line []string, header string) string {
+		if c, ok := headers[header]; ok && c < len(line) {
+			return strings.TrimSpace(line[c])
+		}
+		return ""
+	}
+
+	// Get all workloads
+	wklds, a, err := pce.GetWklds(nil)
+	utils.LogAPIResp("GetWklds", a)
+	if err != nil {
+		utils.LogError(err.Error())
+	}
+	hrefMap := make(map[string]illumioapi.Workload)
+	hostnameMap := make(map[string][]illumioapi.Workload)
+	nameMap := make(map[string][]illumioapi.Workload)
+	for _, w := range wklds {
+		hrefMap[w.Href] = w
+		if w.Hostname != "" {
+			hostnameMap[strings.ToLower(w.Hostname)] = append(hostnameMap[strings.ToLower(w.Hostname)], w)
+		}
+		if w.Name != "" {
+			nameMap[strings.ToLower(w.Name)] = append(nameMap[strings.ToLower(w.Name)], w)
+		}
+	}
+
+	// Find the workload for each row
+	renames := []*rename{}
+	renamed := make(map[string]int)
+	for i, line := range csvData[1:] {
+		lineNum := i + 2
+		var matches []illumioapi.Workload
+		var identifier string
+		switch {
+		case value(line, HeaderHref) != "":
+			identifier = value(line, HeaderHref)
+			if w, ok := hrefMap[identifier]; ok {
+				matches = append(matches, w)
+			}
+		case value(line, HeaderOldHostname) != "":
+			identifier = value(line, HeaderOldHostname)
+			matches = hostnameMap[strings.ToLower(identifier)]
+		case value(line, HeaderOldName) != "":
+			identifier = value(line, HeaderOldName)
+			matches = nameMap[strings.ToLower(identifier)]
+		default:
+			utils.LogWarning(fmt.Sprintf("csv line %d - no href, old hostname, or old name. skipping.", lineNum), true)
+			continue
+		}
+		if len(matches) == 0 {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s does not exist as a workload. skipping.", lineNum, identifier), true)
+			utils.RecordFailure(fmt.Sprintf("csv line %d - %s does not exist", lineNum, identifier))
+			continue
+		}
+		if len(matches) > 1 {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s matches %d workloads. use the href. skipping.", lineNum, identifier, len(matches)), true)
+			utils.RecordFailure(fmt.Sprintf("csv line %d - %s matches %d workloads", lineNum, identifier, len(matches)))
+			continue
+		}
+		w := matches[0]
+		if !utils.WorkloadInHrefsFile(w) {
+			continue
+		}
+		if prev, ok := renamed[w.Href]; ok {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s is also on csv line %d. skipping.", lineNum, identifier, prev), true)
+			utils.RecordFailure(fmt.Sprintf("csv line %d - %s is also on csv line %d", lineNum, identifier, prev))
+			continue
+		}
+		renamed[w.Href] = lineNum
+
+		r := &rename{line: lineNum, wkld: w, newHostname: value(line, HeaderNewHostname), newName: value(line, HeaderNewName)}
+		if r.newHostname != "" && w.GetMode() != "unmanaged" && !strings.EqualFold(r.newHostname, w.Hostname) {
+			utils.LogWarning(fmt.Sprintf("csv line %d - %s is a managed workload. the hostname is reported by the ven and is not changed.", lineNum, identifier), true)
+			r.newHostname = ""
+		}
+		if r.newHostname == w.Hostname {
+			r.newHostname = ""
+		}
+		if r.newName == w.Name {
+			r.newName = ""
+		}
+		if r.newHostname == "" && r.newName == "" {
+			continue
+		}
+		renames = append(renames, r)
+	}
+
+	// Skip renames that collide after all renames are applied. Skipping a rename can cause another collision so repeat until there are none.
+	for {
+		finalHostnames := make(map[string][]string)
+		finalNames := make(map[string][]string)
+		renameMap := make(map[string]*rename)
+		for _, r := range renames {
+			renameMap[r.wkld.Href] = r
+		}
+		for _, w := range wklds {
+			hostname, name := w.Hostname, w.Name
+			if r, ok := renameMap[w.Href]; ok {
+				if r.newHostname != "" {
+					hostname = r.newHostname
+				}
+				if r.newName != "" {
+					name = r.newName
+				}
+			}
+			if hostname != "" {
+				finalHostnames[strings.ToLower(hostname)] = append(finalHostnames[strings.ToLower(hostname)], w.Href)
+			}
+			if name != "" {
+				finalNames[strings.ToLower(name)] = append(finalNames[strings.ToLower(name)], w.Href)
+			}
+		}
+
+		remaining := []*rename{}
+		for _, r := range renames {
+			collision := ""
+			if r.newHostname != "" && len(finalHostnames[strings.ToLower(r.newHostname)]) > 1 {
+				collision = fmt.Sprintf("hostname %s", r.newHostname)
+			} else if r.newName != "" && len(finalNames[strings.ToLower(r.newName)]) > 1 {
+				collision = fmt.Sprintf("name %s", r.newName)
+			}
+			if collision != "" {
+				utils.LogWarning(fmt.Sprintf("csv line %d - %s - %s would be the same as another workload. skipping.", r.line, r.wkld.Href, collision), true)
+				utils.RecordFailure(fmt.Sprintf("csv line %d - %s collides with another workload", r.line, collision))
+				continue
+			}
+			remaining = append(remaining, r)
+		}
+		if len(remaining) == len(renames) {
+			break
+		}
+		renames = remaining
+	}
+
+	// Build the changes
+	changes := utils.NewChangeSet[illumioapi.Workload]("workloads")
+	for _, r := range renames {
+		w := r.wkld
+		var fields []utils.FieldChange
+		if r.newHostname != "" {
+			fields = append(fields, utils.DiffValue("hostname", w.Hostname, r.newHostname)...)
+			w.Hostname = r.newHostname
+		}
+		if r.newName != "" {
+			fields = append(fields, utils.DiffValue("name", w.Name, r.newName)...)
+			w.Name = r.newName
+		}
+		identifier := r.wkld.Hostname
+		if identifier == "" {
+			identifier = r.wkld.Name
+		}
+		changes.Update(w, identifier, w.Href, fields, r.line)
+	}
+	changes.Log()
+
+	// End run if we have nothing to do
+	if changes.Empty() {
+		utils.LogInfo("nothing to be done.", true)
+		utils.LogEndCommand("wkld-rename")
+		return
+	}
+
+	// If updatePCE is disabled, we are just going to alert the user what will happen and log
+	if !updatePCE {
+		utils.LogInfo(fmt.Sprintf("workloader identified %d workloads to rename. See workloader.log for all identified changes. To do the rename, run again using --update-pce flag", len(changes.Updates)), true)
+		utils.LogEndCommand("wkld-rename")
+		return
+	}
+
+	// If updatePCE is set, but not noPrompt, we will prompt the user.
+	if updatePCE && !noPrompt {
+		var prompt string
+		fmt.Printf("\r\n%s [PROMPT] - workloader will rename %d workloads in %s (%s). Do you want to run the rename (yes/no)? ", time.Now().Format("2006-01-02 15:04:05 "), len(changes.Updates), pce.FriendlyName, utils.PCEFQDN(pce.FriendlyName))
+		fmt.Scanln(&prompt)
+		if strings.ToLower(prompt) != "yes" {
+			utils.LogInfo(fmt.Sprintf("prompt denied for renaming %d workloads.", len(changes.Updates)), true)
+			utils.LogEndCommand("wkld-rename")
+			return
+		}
+	}
+
+	// Rename the workloads
+	updatedWklds := []illumioapi.Workload{}
+	for _, c := range changes.Updates {
+		updatedWklds = append(updatedWklds, c.Object)
+	}
+	api, err := pce.BulkWorkload(updatedWklds, "update", true)
+	for _, a := range api {
+		utils.LogAPIResp("BulkWorkloadUpdate", a)
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("bulk updating workloads - %s", err))
+	}
+	utils.LogInfo(fmt.Sprintf("bulk update workload successful for %d workloads - status code %d", len(updatedWklds), api[0].StatusCode), true)
+	utils.LogEndCommand("wkld-rename")
+}
@@ -24,8 +24,9 @@ func init() {
 
 // WkldReplicate runs the wkld-replicate command
 var WkldReplicate = &cobra.Command{
-	Use:   "wkld-replicate",
-	Short: "Replicate workloads between multiple PCEs.",
+	Use:         "wkld-replicate",
+	Short:       "Replicate workloads between multiple PCEs.",
+	Annotations: map[string]string{utils.AnnotationSimulate: "true"},
 	Long: `
 Replicate workloads between multiple PCEs.
 
@@ -279,10 +280,10 @@ func wkldReplicate() {
 	// Run the actions against PCEs
 	for _, p := range pces {
 		if len(wkldImportCsvData) > 1 {
-			utils.LogInfo(fmt.Sprintf("running wkld-import for %s (%s) with %s", p.FriendlyName, p.FQDN, wkldCsvFileName), true)
+			utils.LogInfo(fmt.Sprintf("running wkld-import for %s (%s) with %d workloads", p.FriendlyName, p.FQDN, len(wkldImportCsvData)-1), true)
 			wkldimport.ImportWkldsFromCSV(wkldimport.Input{
 				PCE:             p,
-				ImportFile:      wkldCsvFileName,
+				Data:            wkldImportCsvData,
 				RemoveValue:     "wkld-replicate-remove",
 				Umwl:            true,
 				UpdatePCE:       true,
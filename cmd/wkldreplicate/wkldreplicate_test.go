@@ -0,0 +1,70 @@
+package wkldreplicate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/spf13/viper"
+)
+
+// TestReplicateTwoPCEs checks workloads are tracked by the configured fqdn of each pce. A replicated workload that is orphaned on the
+// second pce has the same href as a workload on the first pce and must only be deleted on the second pce.
+func TestReplicateTwoPCEs(t *testing.T) {
+	primary := mockpce.Start(t, "")
+	drFixtures := t.TempDir()
+	dimensions, err := os.ReadFile(filepath.Join("..", "..", "internal", "mockpce", "fixtures", "label_dimensions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(drFixtures, "label_dimensions.json"), dimensions, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dr := mockpce.Start(t, drFixtures)
+	dr.Add("workloads", map[string]interface{}{
+		"href":                    "/orgs/1/workloads/3",
+		"hostname":                "retired.example.com",
+		"interfaces":              []interface{}{map[string]interface{}{"name": "umw0", "address": "10.9.9.9"}},
+		"labels":                  []interface{}{},
+		"external_data_set":       "wkld-replicate",
+		"external_data_reference": "localhost-managed-wkld-/orgs/1/workloads/9",
+	})
+	primary.Configure(t)
+	dr.ConfigureAs(t, "dr", "127.0.0.1")
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+
+	pceList = mockpce.Name + ",dr"
+	WkldReplicate.Run(WkldReplicate, nil)
+
+	for _, r := range primary.Writes() {
+		if r.Method == "DELETE" || r.Path == "/orgs/1/workloads/bulk_delete" {
+			t.Errorf("primary pce got %s %s", r.Method, r.Path)
+		}
+	}
+	if _, ok := primary.Object("/orgs/1/workloads/3"); !ok {
+		t.Error("primary pce workload /orgs/1/workloads/3 was deleted")
+	}
+	if _, ok := dr.Object("/orgs/1/workloads/3"); ok {
+		t.Error("orphaned workload on the dr pce was not deleted")
+	}
+
+	// The dr pce gets an unmanaged copy of each primary workload that references the primary fqdn
+	want := map[string]string{
+		"web1.example.com":    "localhost-managed-wkld-/orgs/1/workloads/1",
+		"db1.example.com":     "localhost-managed-wkld-/orgs/1/workloads/2",
+		"legacy1.example.com": "localhost-unmanaged-wkld-/orgs/1/workloads/3",
+	}
+	got := make(map[string]string)
+	for _, w := range dr.Objects("workloads") {
+		hostname, _ := w["hostname"].(string)
+		ref, _ := w["external_data_reference"].(string)
+		got[hostname] = ref
+	}
+	for hostname, ref := range want {
+		if got[hostname] != ref {
+			t.Errorf("dr pce %s external data reference = %q, want %q", hostname, got[hostname], ref)
+		}
+	}
+}
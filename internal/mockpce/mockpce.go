@@ -0,0 +1,685 @@
+// Package mockpce is an in-memory PCE for tests. It serves workloads, labels, IP lists, services, rulesets, and traffic from
+// json fixture files so commands can be tested end to end without a live PCE.
+//
+// A fixture file is a json array of objects named for the API collection (e.g., workloads.json or labels.json).
+// Policy objects (ip_lists, services, rule_sets, label_groups, virtual_services, and enforcement_boundaries) are loaded as draft
+// and copied to active. traffic.json is returned by traffic queries. The embedded default fixtures are used when no directory is given.
+package mockpce
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+//go:embed fixtures/*.json
+var defaultFixtures embed.FS
+
+// Name is the PCE name used in the config written by Configure
+const Name = "mock"
+
+// policyObjects are the collections loaded under sec_policy/draft and copied to sec_policy/active
+var policyObjects = map[string]bool{"ip_lists": true, "services": true, "rule_sets": true, "label_groups": true, "virtual_services": true, "enforcement_boundaries": true}
+
+// TB is the part of testing.TB used by the mock PCE. It keeps the testing package out of non-test code.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+	TempDir() string
+}
+
+// Request is a request received by the mock PCE. Path does not include /api/v2.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   string
+}
+
+// Server is a mock PCE
+type Server struct {
+	*httptest.Server
+	Org   int
+	User  string
+	Key   string
+	Token string // bearer token accepted instead of the user and key
+	Login string // href of the user returned by /users/login. blank returns not found.
+
+	mu        sync.Mutex
+	objects   map[string]map[string]interface{}
+	order     []string
+	traffic   []interface{}
+	queries   []map[string]interface{}
+	datafiles map[string][]byte
+	requests  []Request
+	next      int
+}
+
+// New starts a mock PCE with the fixtures in dir. A blank dir uses the default fixtures.
+func New(dir string) (*Server, error) {
+	s := &Server{Org: 1, User: "api_mock", Key: "mock-key", Token: "mock-token", objects: make(map[string]map[string]interface{}), datafiles: make(map[string][]byte), next: 1000}
+	var fixtures fs.FS = os.DirFS(dir)
+	if dir == "" {
+		sub, err := fs.Sub(defaultFixtures, "fixtures")
+		if err != nil {
+			return nil, err
+		}
+		fixtures = sub
+	}
+	files, err := fs.Glob(fixtures, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fixtures, file)
+		if err != nil {
+			return nil, err
+		}
+		var objects []interface{}
+		if err := json.Unmarshal(data, &objects); err != nil {
+			return nil, fmt.Errorf("parsing fixture %s - %s", file, err)
+		}
+		collection := strings.TrimSuffix(file, ".json")
+		if collection == "traffic" {
+			s.traffic = objects
+			continue
+		}
+		if policyObjects[collection] {
+			collection = "sec_policy/draft/" + collection
+		}
+		for i, o := range objects {
+			object, ok := o.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("fixture %s item %d is not an object", file, i)
+			}
+			if _, ok := object["href"].(string); !ok {
+				object["href"] = fmt.Sprintf("%s/%d", s.orgPath(collection), i+1)
+			}
+			s.add(object)
+		}
+	}
+	s.provision()
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s, nil
+}
+
+// Start starts a mock PCE for a test and closes it when the test ends. A blank dir uses the default fixtures.
+func Start(tb TB, dir string) *Server {
+	tb.Helper()
+	s, err := New(dir)
+	if err != nil {
+		tb.Fatalf("starting mock pce - %s", err)
+	}
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// PCE returns an illumioapi.PCE for the mock PCE. The fqdn is localhost so tests can tell it from the loopback forwarder.
+func (s *Server) PCE() illumioapi.PCE {
+	u, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return illumioapi.PCE{FriendlyName: Name, FQDN: "localhost", Port: port, Org: s.Org, User: s.User, Key: s.Key, DisableTLSChecking: true}
+}
+
+// Configure writes a pce.yaml in a temporary directory with the mock PCE as the default PCE and loads it in viper so
+// utils.GetTargetPCE and command Run functions use the mock PCE. Output files, workloader.log, and the run journal are written to the
+// temporary directory, which is returned.
+func (s *Server) Configure(tb TB) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	pce := s.PCE()
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(filepath.Join(dir, "pce.yaml"))
+	viper.Set("default_pce_name", Name)
+	s.setPCE(Name, pce.FQDN)
+	viper.Set("max_entries_for_stdout", 100)
+	viper.Set("output_dir", dir)
+	viper.Set("log_file", filepath.Join(dir, "workloader.log"))
+	viper.Set("journal_dir", filepath.Join(dir, "journal"))
+	if err := viper.WriteConfig(); err != nil {
+		tb.Fatalf("writing mock pce config - %s", err)
+	}
+
+	// Global flag settings that are set by the root command
+	viper.Set("target_pce", "")
+	viper.Set("update_pce", false)
+	viper.Set("simulate", true)
+	viper.Set("no_prompt", true)
+	viper.Set("debug", false)
+	viper.Set("verbose", false)
+	viper.Set("notify", false)
+	viper.Set("email_to", "")
+	viper.Set("output_format", "csv")
+	viper.Set("file_format", "csv")
+	utils.ConfigureLog()
+	return dir
+}
+
+// ConfigureAs adds the mock PCE to the config written by another mock PCE's Configure with a different name and fqdn so a command
+// can use more than one PCE. The fqdn must reach the loopback address (e.g., 127.0.0.1).
+func (s *Server) ConfigureAs(tb TB, name, fqdn string) {
+	tb.Helper()
+	s.setPCE(name, fqdn)
+	if err := viper.WriteConfig(); err != nil {
+		tb.Fatalf("writing mock pce config - %s", err)
+	}
+}
+
+// setPCE sets the config of the mock PCE with a name and fqdn
+func (s *Server) setPCE(name, fqdn string) {
+	pce := s.PCE()
+	viper.Set(name+".fqdn", fqdn)
+	viper.Set(name+".port", pce.Port)
+	viper.Set(name+".org", pce.Org)
+	viper.Set(name+".user", pce.User)
+	viper.Set(name+".key", pce.Key)
+	viper.Set(name+".disableTLSChecking", true)
+}
+
+// Requests returns the requests received by the mock PCE
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Writes returns the requests received by the mock PCE that change objects. Traffic queries are not included.
+func (s *Server) Writes() []Request {
+	writes := []Request{}
+	for _, r := range s.Requests() {
+		if r.Method != "GET" && !strings.Contains(r.Path, "/traffic_flows/") {
+			writes = append(writes, r)
+		}
+	}
+	return writes
+}
+
+// Objects returns the objects in a collection relative to the org (e.g., workloads or sec_policy/draft/rule_sets)
+func (s *Server) Objects(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collection(s.orgPath(collection))
+}
+
+// Object returns the object with an href and false if it does not exist
+func (s *Server) Object(href string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[href]
+	if !ok {
+		return nil, false
+	}
+	return s.expand(o), true
+}
+
+// Add adds objects to a collection relative to the org. Objects without an href get one.
+func (s *Server) Add(collection string, objects ...map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range objects {
+		if _, ok := o["href"].(string); !ok {
+			o["href"] = s.newHref(s.orgPath(collection))
+		}
+		s.add(o)
+	}
+}
+
+// SetTraffic sets the flows returned by traffic queries
+func (s *Server) SetTraffic(flows []illumioapi.TrafficAnalysis) {
+	data, _ := json.Marshal(flows)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traffic = nil
+	json.Unmarshal(data, &s.traffic)
+}
+
+// orgPath returns the href prefix of a collection in the org
+func (s *Server) orgPath(collection string) string {
+	return fmt.Sprintf("/orgs/%d/%s", s.Org, strings.Trim(collection, "/"))
+}
+
+// newHref returns an unused href in a collection
+func (s *Server) newHref(collection string) string {
+	s.next++
+	return fmt.Sprintf("%s/%d", collection, s.next)
+}
+
+// add stores an object. Rules in rulesets are stored as their own objects so they can be changed by href.
+func (s *Server) add(o map[string]interface{}) {
+	href := o["href"].(string)
+	if rules, ok := o["rules"].([]interface{}); ok {
+		for _, r := range rules {
+			if rule, ok := r.(map[string]interface{}); ok {
+				if _, ok := rule["href"].(string); !ok {
+					rule["href"] = s.newHref(href + "/sec_rules")
+				}
+				s.add(rule)
+			}
+		}
+		delete(o, "rules")
+	}
+	if _, ok := s.objects[href]; !ok {
+		s.order = append(s.order, href)
+	}
+	s.objects[href] = o
+}
+
+// remove deletes an object and the objects under it
+func (s *Server) remove(href string) bool {
+	if _, ok := s.objects[href]; !ok {
+		return false
+	}
+	order := []string{}
+	for _, h := range s.order {
+		if h == href || strings.HasPrefix(h, href+"/") {
+			delete(s.objects, h)
+			continue
+		}
+		order = append(order, h)
+	}
+	s.order = order
+	return true
+}
+
+// collection returns the objects directly under a path in the order they were added
+func (s *Server) collection(path string) []map[string]interface{} {
+	objects := []map[string]interface{}{}
+	for _, h := range s.order {
+		if rest := strings.TrimPrefix(h, path+"/"); rest != h && !strings.Contains(rest, "/") {
+			objects = append(objects, s.expand(s.objects[h]))
+		}
+	}
+	return objects
+}
+
+// expand returns a copy of an object with the rules of a ruleset
+func (s *Server) expand(o map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{})
+	for k, v := range o {
+		c[k] = v
+	}
+	href := o["href"].(string)
+	if strings.Contains(href, "/sec_policy/") && strings.Contains(href, "/rule_sets/") && !strings.Contains(href, "/sec_rules/") {
+		rules := []interface{}{}
+		for _, r := range s.collection(href + "/sec_rules") {
+			rules = append(rules, r)
+		}
+		c["rules"] = rules
+	}
+	return c
+}
+
+// usage returns the label usage of an object like the PCE returns with usage=true. Only workload, ruleset, and label group usage are checked.
+func (s *Server) usage(href string) map[string]bool {
+	usage := map[string]bool{"workload": false, "ruleset": false, "label_group": false}
+	quoted := `"` + href + `"`
+	for _, h := range s.order {
+		data, _ := json.Marshal(s.objects[h])
+		if !strings.Contains(string(data), quoted) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(h, s.orgPath("workloads")+"/"):
+			usage["workload"] = true
+		case strings.Contains(h, "/rule_sets/"):
+			usage["ruleset"] = true
+		case strings.Contains(h, "/label_groups/"):
+			usage["label_group"] = true
+		}
+	}
+	return usage
+}
+
+// provision replaces the active policy objects with the draft policy objects
+func (s *Server) provision() {
+	draft, active := s.orgPath("sec_policy/draft"), s.orgPath("sec_policy/active")
+	for _, h := range append([]string{}, s.order...) {
+		if strings.HasPrefix(h, active+"/") {
+			s.remove(h)
+		}
+	}
+	for _, h := range append([]string{}, s.order...) {
+		if !strings.HasPrefix(h, draft+"/") {
+			continue
+		}
+		data, _ := json.Marshal(s.objects[h])
+		var o map[string]interface{}
+		json.Unmarshal([]byte(strings.ReplaceAll(string(data), draft+"/", active+"/")), &o)
+		s.add(o)
+	}
+}
+
+// serveHTTP records the request and routes it
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2"), "/")
+	// Some illumioapi calls have a double slash after the org (e.g., /orgs/1//sec_policy/draft/services) that the PCE accepts
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Query: r.URL.Query(), Body: string(body)})
+
+	if user, key, ok := r.BasicAuth(); r.Header.Get("Authorization") != "Bearer "+s.Token && (!ok || user != s.User || key != s.Key) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+		return
+	}
+
+	org := fmt.Sprintf("/orgs/%d", s.Org)
+	switch {
+	case path == "/product_version":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": "23.2.0", "build": 1, "long_display": "23.2.0-1", "short_display": "23.2.0"})
+	case path == "/users/login" && r.Method == "GET":
+		if o, ok := s.objects[s.Login]; ok {
+			writeJSON(w, http.StatusOK, o)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	case path == "/health":
+		writeJSON(w, http.StatusOK, []map[string]string{{"fqdn": r.Host, "type": "standalone", "status": "normal"}})
+	case strings.HasPrefix(path, org+"/jobs/"):
+		id := strings.TrimPrefix(path, org+"/jobs/")
+		writeJSON(w, http.StatusOK, map[string]interface{}{"href": path, "status": "done", "result": map[string]string{"href": org + "/datafiles/" + id}})
+	case strings.HasPrefix(path, org+"/datafiles/"):
+		data, ok := s.datafiles[path]
+		if !ok {
+			notFound(w, path)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case strings.HasPrefix(path, org+"/traffic_flows/"):
+		s.serveTraffic(w, r, path, body)
+	case path == org+"/sec_policy" && r.Method == "POST":
+		s.provision()
+		writeJSON(w, http.StatusCreated, map[string]string{"href": s.newHref(org + "/sec_policy")})
+	case strings.HasPrefix(path, org+"/workloads/bulk_") && r.Method == "PUT":
+		s.serveBulk(w, strings.TrimPrefix(path, org+"/workloads/bulk_"), body)
+	case path == org+"/workloads/unpair" && r.Method == "PUT":
+		s.serveUnpair(w, body)
+	case path == org+"/vens/upgrade" && r.Method == "PUT":
+		s.serveUpgrade(w, body)
+	case strings.HasSuffix(path, "/pairing_key") && r.Method == "POST":
+		s.next++
+		writeJSON(w, http.StatusCreated, map[string]string{"activation_code": fmt.Sprintf("mockactivationcode%04d", s.next)})
+	default:
+		s.serveObjects(w, r, path, body)
+	}
+}
+
+// serveObjects handles collections and objects by href
+func (s *Server) serveObjects(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	o, isObject := s.objects[path]
+	switch {
+	case r.Method == "GET" && isObject:
+		writeJSON(w, http.StatusOK, s.expand(o))
+	case r.Method == "GET":
+		objects := filter(s.collection(path), r.URL.Query())
+		if r.URL.Query().Get("usage") == "true" {
+			for _, o := range objects {
+				o["usage"] = s.usage(o["href"].(string))
+			}
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(objects)))
+		// Large collections are requested with respond-async and returned as a job and datafile
+		if r.Header.Get("Prefer") == "respond-async" {
+			job := s.newHref(fmt.Sprintf("/orgs/%d/jobs", s.Org))
+			s.datafiles[strings.Replace(job, "/jobs/", "/datafiles/", 1)], _ = json.Marshal(objects)
+			w.Header().Set("Location", job)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeJSON(w, http.StatusOK, objects)
+	case r.Method == "POST":
+		var created map[string]interface{}
+		if err := json.Unmarshal(body, &created); err != nil {
+			writeJSON(w, http.StatusNotAcceptable, []map[string]string{{"token": "invalid_json", "message": err.Error()}})
+			return
+		}
+		created["href"] = s.newHref(path)
+		s.add(created)
+		writeJSON(w, http.StatusCreated, s.expand(created))
+	case r.Method == "PUT" && isObject:
+		var update map[string]interface{}
+		if err := json.Unmarshal(body, &update); err != nil {
+			writeJSON(w, http.StatusNotAcceptable, []map[string]string{{"token": "invalid_json", "message": err.Error()}})
+			return
+		}
+		for k, v := range update {
+			if k != "href" {
+				o[k] = v
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE" && s.remove(path):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound(w, path)
+	}
+}
+
+// serveBulk handles workload bulk_create, bulk_update, and bulk_delete
+func (s *Server) serveBulk(w http.ResponseWriter, action string, body []byte) {
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(body, &objects); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, []map[string]string{{"token": "invalid_json", "message": err.Error()}})
+		return
+	}
+	results := []map[string]interface{}{}
+	for _, o := range objects {
+		href, _ := o["href"].(string)
+		existing, ok := s.objects[href]
+		switch {
+		case action == "create":
+			href = s.newHref(s.orgPath("workloads"))
+			o["href"] = href
+			if _, ok := o["deleted"]; !ok {
+				o["deleted"] = false
+			}
+			s.add(o)
+			results = append(results, map[string]interface{}{"href": href, "status": "created"})
+		case !ok:
+			results = append(results, map[string]interface{}{"href": href, "status": "validation_failure", "errors": []map[string]string{{"token": "not_found", "message": "workload not found"}}})
+		case action == "update":
+			for k, v := range o {
+				existing[k] = v
+			}
+			results = append(results, map[string]interface{}{"href": href, "status": "updated"})
+		case action == "delete":
+			s.remove(href)
+			results = append(results, map[string]interface{}{"href": href, "status": "deleted"})
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// serveUnpair handles workload unpair by removing the workloads
+func (s *Server) serveUnpair(w http.ResponseWriter, body []byte) {
+	var unpair struct {
+		Workloads []struct {
+			Href string `json:"href"`
+		} `json:"workloads"`
+	}
+	if err := json.Unmarshal(body, &unpair); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, []map[string]string{{"token": "invalid_json", "message": err.Error()}})
+		return
+	}
+	for _, wkld := range unpair.Workloads {
+		s.remove(wkld.Href)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveUpgrade handles ven upgrades by setting the version of the vens. Vens that do not exist are returned as errors.
+func (s *Server) serveUpgrade(w http.ResponseWriter, body []byte) {
+	var upgrade struct {
+		Release string `json:"release"`
+		VENs    []struct {
+			Href string `json:"href"`
+		} `json:"vens"`
+	}
+	if err := json.Unmarshal(body, &upgrade); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, []map[string]string{{"token": "invalid_json", "message": err.Error()}})
+		return
+	}
+	missing := []string{}
+	for _, ven := range upgrade.VENs {
+		o, ok := s.objects[ven.Href]
+		if !ok {
+			missing = append(missing, ven.Href)
+			continue
+		}
+		o["version"] = upgrade.Release
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []map[string]interface{}{{"token": "invalid_ven", "message": "ven does not exist", "hrefs": missing}}})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []interface{}{}})
+}
+
+// serveTraffic handles traffic analysis and async traffic queries. Every query returns all the traffic fixture flows.
+func (s *Server) serveTraffic(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	queries := s.orgPath("traffic_flows/async_queries")
+	switch {
+	case path == s.orgPath("traffic_flows/traffic_analysis_queries") && r.Method == "POST":
+		writeJSON(w, http.StatusOK, s.traffic)
+	case path == queries && r.Method == "POST":
+		var params interface{}
+		json.Unmarshal(body, &params)
+		href := s.newHref(queries)
+		query := map[string]interface{}{"href": href, "status": "completed", "result": href + "/download", "query_parameters": params, "flows_count": len(s.traffic), "matches_count": len(s.traffic)}
+		s.queries = append(s.queries, query)
+		writeJSON(w, http.StatusAccepted, query)
+	case path == queries && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.queries)
+	case strings.HasPrefix(path, queries+"/") && strings.HasSuffix(path, "/download") && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.traffic)
+	case strings.HasPrefix(path, queries+"/") && r.Method == "GET":
+		for _, q := range s.queries {
+			if q["href"] == path {
+				writeJSON(w, http.StatusOK, q)
+				return
+			}
+		}
+		notFound(w, path)
+	case strings.HasPrefix(path, queries+"/") && strings.HasSuffix(path, "/update_rules") && r.Method == "PUT":
+		for _, q := range s.queries {
+			if q["href"] == strings.TrimSuffix(path, "/update_rules") {
+				q["rules"] = "completed"
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		notFound(w, path)
+	case strings.HasPrefix(path, queries+"/") && r.Method == "DELETE":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound(w, path)
+	}
+}
+
+// filter returns the objects matching the query parameters. String fields match on a case-insensitive substring like the PCE and
+// bool fields and object references match exactly on the value or href. managed matches workloads with a VEN. labels matches the json list of label href lists. Other parameters are ignored.
+func filter(objects []map[string]interface{}, query url.Values) []map[string]interface{} {
+	filtered := []map[string]interface{}{}
+	for _, o := range objects {
+		if matches(o, query) {
+			filtered = append(filtered, o)
+		}
+	}
+	if max, err := strconv.Atoi(query.Get("max_results")); err == nil && max < len(filtered) {
+		filtered = filtered[:max]
+	}
+	return filtered
+}
+
+// matches returns true if an object matches the query parameters
+func matches(o map[string]interface{}, query url.Values) bool {
+	for key := range query {
+		value := query.Get(key)
+		if key == "labels" {
+			var labelSets [][]string
+			if json.Unmarshal([]byte(value), &labelSets) != nil {
+				continue
+			}
+			if !hasLabels(o, labelSets) {
+				return false
+			}
+			continue
+		}
+		field := o[key]
+		if key == "managed" {
+			field = o["ven"] != nil
+		}
+		switch f := field.(type) {
+		case string:
+			if !strings.Contains(strings.ToLower(f), strings.ToLower(value)) {
+				return false
+			}
+		case bool:
+			if b, err := strconv.ParseBool(value); err == nil && b != f {
+				return false
+			}
+		case map[string]interface{}:
+			if href, ok := f["href"].(string); ok && href != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasLabels returns true if an object has all the labels of any of the label sets
+func hasLabels(o map[string]interface{}, labelSets [][]string) bool {
+	hrefs := make(map[string]bool)
+	labels, _ := o["labels"].([]interface{})
+	for _, l := range labels {
+		if label, ok := l.(map[string]interface{}); ok {
+			if href, ok := label["href"].(string); ok {
+				hrefs[href] = true
+			}
+		}
+	}
+	for _, set := range labelSets {
+		all := true
+		for _, href := range set {
+			all = all && hrefs[href]
+		}
+		if all {
+			return true
+		}
+	}
+	return len(labelSets) == 0
+}
+
+// writeJSON writes a json response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// notFound writes a PCE style not found response
+func notFound(w http.ResponseWriter, path string) {
+	writeJSON(w, http.StatusNotFound, []map[string]string{{"token": "not_found", "message": path + " not found"}})
+}
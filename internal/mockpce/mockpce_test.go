@@ -0,0 +1,51 @@
+package mockpce
+
+import (
+	"testing"
+)
+
+// TestDefaultFixtures reads the default fixtures with the illumioapi client to check they match the api shapes
+func TestDefaultFixtures(t *testing.T) {
+	s := Start(t, "")
+	pce := s.PCE()
+
+	wklds, _, err := pce.GetWklds(nil)
+	if err != nil || len(wklds) != 3 {
+		t.Fatalf("GetWklds returned %d workloads - %v", len(wklds), err)
+	}
+	managed := 0
+	for _, w := range wklds {
+		if w.GetMode() != "unmanaged" {
+			managed++
+		}
+		if w.Labels == nil || len(*w.Labels) == 0 {
+			t.Errorf("%s has no labels", w.Hostname)
+		}
+	}
+	if managed != 2 {
+		t.Errorf("got %d managed workloads, want 2", managed)
+	}
+
+	tests := []struct {
+		name string
+		get  func() (int, error)
+		want int
+	}{
+		{"labels", func() (int, error) { l, _, err := pce.GetLabels(nil); return len(l), err }, 6},
+		{"label dimensions", func() (int, error) { l, _, err := pce.GetLabelDimensions(nil); return len(l), err }, 4},
+		{"draft ip lists", func() (int, error) { l, _, err := pce.GetIPLists(nil, "draft"); return len(l), err }, 2},
+		{"active ip lists", func() (int, error) { l, _, err := pce.GetIPLists(nil, "active"); return len(l), err }, 2},
+		{"draft services", func() (int, error) { l, _, err := pce.GetServices(nil, "draft"); return len(l), err }, 3},
+		{"draft rulesets", func() (int, error) { l, _, err := pce.GetRulesets(nil, "draft"); return len(l), err }, 1},
+	}
+	for _, tt := range tests {
+		got, err := tt.get()
+		if err != nil {
+			t.Errorf("%s - %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("got %d %s, want %d", got, tt.name, tt.want)
+		}
+	}
+}
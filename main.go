@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/brian1917/workloader/cmd"
@@ -56,6 +57,35 @@ func main() {
 			}
 			return
 		}
+
+		// Process all-orgs
+		if os.Args[1] == "all-orgs" && os.Args[2] != "-h" && os.Args[2] != "--help" {
+			pceName := utils.DefaultPCEName()
+			for i, a := range os.Args {
+				if a == "--pce" && i+1 < len(os.Args) {
+					pceName = os.Args[i+1]
+				} else if strings.HasPrefix(a, "--pce=") {
+					pceName = strings.TrimPrefix(a, "--pce=")
+				}
+			}
+			orgs, err := utils.GetPCEOrgs(pceName)
+			if err != nil {
+				utils.LogError(err.Error())
+			}
+			if len(orgs) == 0 {
+				utils.LogError(fmt.Sprintf("%s does not have any orgs", pceName))
+			}
+			for _, o := range orgs {
+				utils.LogInfo(fmt.Sprintf("running %s", strings.Join(append(os.Args[2:], "--org", strconv.Itoa(o.Org)), " ")), true)
+				command := exec.Command(os.Args[0], append(os.Args[2:], "--org", strconv.Itoa(o.Org))...)
+				stdout, err := command.Output()
+				if err != nil {
+					utils.LogError(err.Error())
+				}
+				fmt.Println(string(stdout))
+			}
+			return
+		}
 	}
 
 	// Run command for all other scenarios
@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// cachePath is the path of the synthetic async jobs and results the forwarder serves for cached async collections
+const cachePath = "/workloader-cache/"
+
+// apiCacheMeta is the metadata of a cached response
+type apiCacheMeta struct {
+	Created time.Time         `json:"created"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Async   bool              `json:"async"`
+	Status  int               `json:"status"`
+	Header  map[string]string `json:"header"`
+}
+
+// apiCache caches GET responses from a PCE in files. Async collections are cached by following the job to its result.
+type apiCache struct {
+	dir     string
+	ttl     time.Duration
+	mu      sync.Mutex
+	jobs    map[string]string // async job href to cache key
+	results map[string]string // async result href to cache key
+}
+
+// APICacheTTL returns how long GET responses are cached. --no-cache turns off the cache. The --cache-ttl flag takes precedence over
+// WORKLOADER_CACHE_TTL, which takes precedence over cache_ttl in pce.yaml. The cache is off by default.
+func APICacheTTL() time.Duration {
+	if viper.GetBool("no_cache_flag") {
+		return 0
+	}
+	if viper.GetDuration("cache_ttl_flag") > 0 {
+		return viper.GetDuration("cache_ttl_flag")
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKLOADER_CACHE_TTL")); err == nil {
+		return d
+	}
+	return viper.GetDuration("cache_ttl")
+}
+
+// newAPICache returns the cache for a PCE in the cache directory
+func newAPICache(name string, ttl time.Duration) *apiCache {
+	return &apiCache{dir: filepath.Join(CacheDir(), "api", outputNameToken(name)), ttl: ttl, jobs: make(map[string]string), results: make(map[string]string)}
+}
+
+// cacheable returns true for GET requests that can be cached. Async job status, traffic queries, and health checks are always sent to the PCE.
+func cacheable(r *http.Request) bool {
+	if r.Method != "GET" {
+		return false
+	}
+	for _, p := range []string{"/jobs/", "/datafiles/", "/traffic_flows/", "/health", "/product_version", "/users/", cachePath} {
+		if strings.Contains(r.URL.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// key returns the cache key of a request from the upstream, path, query, and headers that change the response
+func (c *apiCache) key(upstream string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{upstream, r.URL.RequestURI(), r.Header.Get("Accept"), r.Header.Get("Prefer")}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// files returns the metadata and body files of a key
+func (c *apiCache) files(key string) (string, string) {
+	return filepath.Join(c.dir, key+".meta.json"), filepath.Join(c.dir, key+".body")
+}
+
+// get returns the metadata of a cached response that has not expired
+func (c *apiCache) get(key string) (apiCacheMeta, bool) {
+	var meta apiCacheMeta
+	metaFile, bodyFile := c.files(key)
+	data, err := os.ReadFile(metaFile)
+	if err != nil || json.Unmarshal(data, &meta) != nil || time.Since(meta.Created) > c.ttl {
+		return meta, false
+	}
+	if _, err := os.Stat(bodyFile); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
+// serve responds from the cache and returns true if the request was handled. Cached async collections are served as a finished job
+// so the illumioapi client gets the result the same way it does from the PCE.
+func (c *apiCache) serve(w http.ResponseWriter, r *http.Request, f *pceForwarder) bool {
+	// Synthetic async jobs and results for cached async collections
+	if i := strings.Index(r.URL.Path, cachePath); i >= 0 {
+		key := strings.TrimPrefix(r.URL.Path[i:], cachePath)
+		if strings.HasSuffix(key, "/result") {
+			c.writeBody(w, strings.TrimSuffix(key, "/result"))
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"href": cachePath + key, "status": "done", "result": map[string]string{"href": cachePath + key + "/result"}})
+		return true
+	}
+	if !cacheable(r) {
+		return false
+	}
+	key := c.key(f.route(r.Method), r)
+	meta, ok := c.get(key)
+	if !ok {
+		return false
+	}
+	LogInfo(fmt.Sprintf("%s - using cached %s %s from %s", f.name, r.Method, r.URL.RequestURI(), meta.Created.Format("2006-01-02 15:04:05")), false)
+	if meta.Async {
+		w.Header().Set("Location", cachePath+key)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusAccepted)
+		return true
+	}
+	c.writeBody(w, key)
+	return true
+}
+
+// writeBody writes a cached body
+func (c *apiCache) writeBody(w http.ResponseWriter, key string) {
+	meta, ok := c.get(key)
+	_, bodyFile := c.files(key)
+	body, err := os.Open(bodyFile)
+	if !ok || err != nil {
+		http.Error(w, "cached response expired", http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+	for k, v := range meta.Header {
+		w.Header().Set(k, v)
+	}
+	status := meta.Status
+	if status == 0 || meta.Async {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	io.Copy(w, body)
+}
+
+// track records async jobs and results so the result of an async collection is cached with the request that started it.
+// location is the job href from a 202 response and body is the job status when the request polls a job.
+func (c *apiCache) track(upstream string, r *http.Request, status int, location string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case status == http.StatusAccepted && cacheable(r) && location != "":
+		c.jobs[strings.TrimPrefix(location, "/api/v2")] = c.key(upstream, r)
+	case strings.Contains(r.URL.Path, "/jobs/") && status == http.StatusOK:
+		key, ok := c.jobs[strings.TrimPrefix(r.URL.Path, "/api/v2")]
+		if !ok {
+			return
+		}
+		var job struct {
+			Status string `json:"status"`
+			Result struct {
+				Href string `json:"href"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(body, &job) == nil && job.Status == "done" && job.Result.Href != "" {
+			c.results[strings.TrimPrefix(job.Result.Href, "/api/v2")] = key
+		}
+	}
+}
+
+// resultKey returns the cache key of an async result request and false if the request is not an async result
+func (c *apiCache) resultKey(r *http.Request) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.results[strings.TrimPrefix(r.URL.Path, "/api/v2")]
+	return key, ok
+}
+
+// store copies a response to w and saves it in the cache under key. The response is written as it is read so large collections are not held in memory.
+func (c *apiCache) store(w io.Writer, key string, r *http.Request, resp *http.Response, async bool) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		_, copyErr := io.Copy(w, resp.Body)
+		return copyErr
+	}
+	metaFile, bodyFile := c.files(key)
+	tmp, err := os.CreateTemp(c.dir, key+"-*.tmp")
+	if err != nil {
+		_, copyErr := io.Copy(w, resp.Body)
+		return copyErr
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(w, io.TeeReader(resp.Body, tmp))
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	meta := apiCacheMeta{Created: time.Now(), Method: r.Method, URL: r.URL.RequestURI(), Async: async, Status: resp.StatusCode, Header: make(map[string]string)}
+	for _, h := range []string{"Content-Type", "X-Total-Count"} {
+		if v := resp.Header.Get(h); v != "" {
+			meta.Header[h] = v
+		}
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), bodyFile); err != nil {
+		return err
+	}
+	return os.WriteFile(metaFile, data, 0600)
+}
+
+// clear removes the cached responses of the PCE after a change
+func (c *apiCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.RemoveAll(c.dir); err != nil {
+		LogWarning(fmt.Sprintf("clearing api cache %s - %s", c.dir, err), false)
+	}
+	c.jobs, c.results = make(map[string]string), make(map[string]string)
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Approval is the --approval-file written by a review run. It has the changes the command will make and a hash of them.
+// A run with --update-pce and the approval file only makes the changes if the hash of the changes it plans still matches.
+type Approval struct {
+	Command string          `json:"command"`
+	PCE     string          `json:"pce"`
+	Created string          `json:"created"`
+	Hash    string          `json:"hash"`
+	Changes []PlannedChange `json:"changes"`
+}
+
+// ApprovalFile returns the --approval-file
+func ApprovalFile() string {
+	return viper.GetString("approval_file_flag")
+}
+
+// ApprovalReview returns true if the command is a review run that writes the approval file. A review run takes the --update-pce path
+// of the command without a prompt and every change is simulated.
+func ApprovalReview() bool {
+	return viper.GetBool("approval_review")
+}
+
+// PlannedChangesHash returns the hash of planned changes. The changes are sorted and json bodies are normalized so the hash does not
+// depend on the order the command made the requests.
+func PlannedChangesHash(changes []PlannedChange) string {
+	lines := []string{}
+	for _, c := range changes {
+		body := c.Body
+		var v interface{}
+		if json.Unmarshal([]byte(c.Body), &v) == nil {
+			if b, err := json.Marshal(v); err == nil {
+				body = string(b)
+			}
+		}
+		lines = append(lines, strings.Join([]string{c.Method, c.Path, body}, " "))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeApprovalFile writes the planned changes of a review run to the approval file
+func writeApprovalFile(commandName string, changes []PlannedChange) {
+	if changes == nil {
+		changes = []PlannedChange{}
+	}
+	a := Approval{Command: commandName, PCE: logPCE(), Created: time.Now().Format(time.RFC3339), Hash: PlannedChangesHash(changes), Changes: changes}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(a); err != nil {
+		LogError(fmt.Sprintf("writing approval file - %s", err))
+	}
+	file := ApprovalFile()
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			LogError(fmt.Sprintf("creating approval file directory %s - %s", dir, err))
+		}
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		LogError(fmt.Sprintf("writing approval file %s - %s", file, err))
+	}
+	LogInfo(fmt.Sprintf("approval file: %s - %d changes - %s. after review, run the same command with --update-pce --approval-file %s to make the changes.", file, len(changes), a.Hash, file), true)
+}
+
+// ReadApprovalFile reads an approval file
+func ReadApprovalFile(file string) (Approval, error) {
+	var a Approval
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return a, fmt.Errorf("reading approval file - %s", err)
+	}
+	if err := json.Unmarshal(data, &a); err != nil {
+		return a, fmt.Errorf("%s is not a valid approval file - %s", file, err)
+	}
+	if a.Command == "" || a.Hash == "" {
+		return a, fmt.Errorf("%s is not a valid approval file - missing command or hash", file)
+	}
+	return a, nil
+}
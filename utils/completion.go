@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// completionCacheTTL is how long values from the PCE are cached for shell completion
+const completionCacheTTL = 10 * time.Minute
+
+// completionCache is the cached values of one kind for a PCE
+type completionCache struct {
+	Updated time.Time `json:"updated"`
+	Values  []string  `json:"values"`
+}
+
+// CacheDir returns the directory for cached PCE data. The WORKLOADER_CACHE_DIR environment variable takes precedence over ~/.workloader/cache.
+func CacheDir() string {
+	if os.Getenv("WORKLOADER_CACHE_DIR") != "" {
+		return os.Getenv("WORKLOADER_CACHE_DIR")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".workloader", "cache")
+	}
+	return filepath.Join(home, ".workloader", "cache")
+}
+
+// CompletionPCENames returns the names of the PCEs in pce.yaml and environment variables
+func CompletionPCENames() []string {
+	names := EnvPCENames()
+	for k := range viper.AllSettings() {
+		if viper.IsSet(k + ".fqdn") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompletionLabels returns the values of the labels with a key in a PCE. A blank PCE name uses the default PCE.
+func CompletionLabels(pceName, key string) []string {
+	values := []string{}
+	for _, l := range completionValues(pceName, "labels", func(pce illumioapi.PCE) ([]string, error) {
+		labels, _, err := pce.GetLabels(nil)
+		keyValues := []string{}
+		for _, l := range labels {
+			keyValues = append(keyValues, l.Key+"="+l.Value)
+		}
+		return keyValues, err
+	}) {
+		if k, v, ok := strings.Cut(l, "="); ok && k == key {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// CompletionLabelKeys returns the label keys in a PCE. A blank PCE name uses the default PCE.
+func CompletionLabelKeys(pceName string) []string {
+	return completionValues(pceName, "label_keys", func(pce illumioapi.PCE) ([]string, error) {
+		dimensions, _, err := pce.GetLabelDimensions(nil)
+		keys := []string{}
+		for _, d := range dimensions {
+			keys = append(keys, d.Key)
+		}
+		return keys, err
+	})
+}
+
+// CompletionRulesetNames returns the draft ruleset names in a PCE. A blank PCE name uses the default PCE.
+func CompletionRulesetNames(pceName string) []string {
+	return completionValues(pceName, "rulesets", func(pce illumioapi.PCE) ([]string, error) {
+		rulesets, _, err := pce.GetRulesets(nil, "draft")
+		names := []string{}
+		for _, rs := range rulesets {
+			names = append(names, rs.Name)
+		}
+		return names, err
+	})
+}
+
+// completionValues returns cached values or gets them from the PCE and caches them. Nothing is returned on errors so completion is never interrupted.
+// The PCE is not queried when pce.yaml needs a passphrase that is not in WORKLOADER_KEY because completion cannot prompt.
+func completionValues(pceName, kind string, get func(pce illumioapi.PCE) ([]string, error)) []string {
+	if pceName == "" {
+		pceName = DefaultPCEName()
+	}
+	if pceName == "" {
+		return nil
+	}
+	cacheFile := filepath.Join(CacheDir(), "completion", pceName+"-"+kind+".json")
+	var cache completionCache
+	if data, err := os.ReadFile(cacheFile); err == nil && json.Unmarshal(data, &cache) == nil && time.Since(cache.Updated) < completionCacheTTL {
+		return cache.Values
+	}
+	if viper.GetString("encryption.mode") == EncryptionPassphrase && os.Getenv("WORKLOADER_KEY") == "" {
+		return cache.Values
+	}
+	pce, err := GetPCEbyName(pceName, false)
+	if err != nil {
+		return cache.Values
+	}
+	values, err := get(pce)
+	if err != nil {
+		LogDebug("getting completion values for " + kind + " - " + err.Error())
+		return cache.Values
+	}
+	sort.Strings(values)
+	if data, err := json.Marshal(completionCache{Updated: time.Now(), Values: values}); err == nil {
+		if os.MkdirAll(filepath.Dir(cacheFile), 0700) == nil {
+			os.WriteFile(cacheFile, data, 0600)
+		}
+	}
+	return values
+}
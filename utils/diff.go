@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change actions
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// FieldChange is a change to one field of an object. Single value fields use Old and New. Multi-value fields use Added and Removed.
+type FieldChange struct {
+	Field   string
+	Old     string
+	New     string
+	Added   []string
+	Removed []string
+}
+
+// String describes the field change for logs
+func (f FieldChange) String() string {
+	if f.Added == nil && f.Removed == nil {
+		return fmt.Sprintf("%s from %s to %s", f.Field, LogBlankValue(f.Old), LogBlankValue(f.New))
+	}
+	parts := []string{}
+	if len(f.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("add %s", strings.Join(f.Added, "; ")))
+	}
+	if len(f.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("remove %s", strings.Join(f.Removed, "; ")))
+	}
+	return fmt.Sprintf("%s %s", f.Field, strings.Join(parts, " and "))
+}
+
+// DiffValue returns a field change if the current and desired values are different
+func DiffValue(field, current, desired string) []FieldChange {
+	if current == desired {
+		return nil
+	}
+	return []FieldChange{{Field: field, Old: current, New: desired}}
+}
+
+// DiffSet returns a field change with the values to add and remove if the current and desired values are different.
+// Order and duplicates are ignored.
+func DiffSet(field string, current, desired []string) []FieldChange {
+	currentMap, desiredMap := make(map[string]bool), make(map[string]bool)
+	for _, c := range current {
+		currentMap[c] = true
+	}
+	for _, d := range desired {
+		desiredMap[d] = true
+	}
+	f := FieldChange{Field: field, Added: []string{}, Removed: []string{}}
+	for d := range desiredMap {
+		if !currentMap[d] {
+			f.Added = append(f.Added, d)
+		}
+	}
+	for c := range currentMap {
+		if !desiredMap[c] {
+			f.Removed = append(f.Removed, c)
+		}
+	}
+	if len(f.Added) == 0 && len(f.Removed) == 0 {
+		return nil
+	}
+	sort.Strings(f.Added)
+	sort.Strings(f.Removed)
+	return []FieldChange{f}
+}
+
+// Change is a create, update, or delete of one object. Object is the desired object for creates and updates and the current object for deletes.
+type Change[T any] struct {
+	Action   string
+	Object   T
+	Name     string
+	Href     string
+	CSVLines []int
+	Fields   []FieldChange
+}
+
+// String describes the change for logs
+func (c Change[T]) String() string {
+	lines := []string{}
+	for _, l := range c.CSVLines {
+		lines = append(lines, strconv.Itoa(l))
+	}
+	s := c.Name
+	if c.Href != "" {
+		s = fmt.Sprintf("%s (%s)", c.Name, c.Href)
+	}
+	if len(lines) > 0 {
+		s = fmt.Sprintf("csv line(s) %s - %s", strings.Join(lines, ", "), s)
+	}
+	s = fmt.Sprintf("%s - to be %sd", s, c.Action)
+	fields := []string{}
+	for _, f := range c.Fields {
+		fields = append(fields, f.String())
+	}
+	if len(fields) > 0 {
+		s = fmt.Sprintf("%s - %s", s, strings.Join(fields, ", "))
+	}
+	return s
+}
+
+// ChangeSet is the result of comparing desired objects from an input file against the current objects in the PCE
+type ChangeSet[T any] struct {
+	ObjectType string
+	Creates    []Change[T]
+	Updates    []Change[T]
+	Deletes    []Change[T]
+}
+
+// NewChangeSet creates an empty change set. The object type is plural and used in summaries (e.g., ip lists).
+func NewChangeSet[T any](objectType string) *ChangeSet[T] {
+	return &ChangeSet[T]{ObjectType: objectType}
+}
+
+// Create adds an object to create
+func (cs *ChangeSet[T]) Create(object T, name string, csvLines ...int) {
+	cs.Creates = append(cs.Creates, Change[T]{Action: ChangeCreate, Object: object, Name: name, CSVLines: csvLines})
+}
+
+// Update adds an object to update if there are field changes. It returns true if the update was added.
+func (cs *ChangeSet[T]) Update(object T, name, href string, fields []FieldChange, csvLines ...int) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	cs.Updates = append(cs.Updates, Change[T]{Action: ChangeUpdate, Object: object, Name: name, Href: href, Fields: fields, CSVLines: csvLines})
+	return true
+}
+
+// Delete adds an object to delete
+func (cs *ChangeSet[T]) Delete(object T, name, href string, csvLines ...int) {
+	cs.Deletes = append(cs.Deletes, Change[T]{Action: ChangeDelete, Object: object, Name: name, Href: href, CSVLines: csvLines})
+}
+
+// Empty returns true if there is nothing to change
+func (cs *ChangeSet[T]) Empty() bool {
+	return len(cs.Creates) == 0 && len(cs.Updates) == 0 && len(cs.Deletes) == 0
+}
+
+// Changes returns the creates, updates, and deletes in that order
+func (cs *ChangeSet[T]) Changes() []Change[T] {
+	changes := append([]Change[T]{}, cs.Creates...)
+	changes = append(changes, cs.Updates...)
+	return append(changes, cs.Deletes...)
+}
+
+// Summary returns the number of creates, updates, and deletes
+func (cs *ChangeSet[T]) Summary() string {
+	s := fmt.Sprintf("%d %s to create and %d %s to update", len(cs.Creates), cs.ObjectType, len(cs.Updates), cs.ObjectType)
+	if len(cs.Deletes) > 0 {
+		s = fmt.Sprintf("%d %s to create, %d %s to update, and %d %s to delete", len(cs.Creates), cs.ObjectType, len(cs.Updates), cs.ObjectType, len(cs.Deletes), cs.ObjectType)
+	}
+	return s
+}
+
+// Log writes each change to workloader.log
+func (cs *ChangeSet[T]) Log() {
+	for _, c := range cs.Changes() {
+		LogInfoFields(c.String(), Fields{"action": c.Action, "object_type": cs.ObjectType, "href": c.Href}, false)
+	}
+}
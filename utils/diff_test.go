@@ -0,0 +1,99 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/workloader/utils"
+)
+
+func TestDiffValue(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired string
+		want             []utils.FieldChange
+	}{
+		{"no change", "web", "web", nil},
+		{"update", "web", "db", []utils.FieldChange{{Field: "description", Old: "web", New: "db"}}},
+		{"set from blank", "", "db", []utils.FieldChange{{Field: "description", Old: "", New: "db"}}},
+		{"clear", "web", "", []utils.FieldChange{{Field: "description", Old: "web", New: ""}}},
+	}
+	for _, tc := range tests {
+		if got := utils.DiffValue("description", tc.current, tc.desired); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDiffSet(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired []string
+		want             []utils.FieldChange
+	}{
+		{"no change", []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"order ignored", []string{"a", "b", "c"}, []string{"c", "a", "b"}, nil},
+		{"duplicates ignored", []string{"a", "b"}, []string{"b", "a", "a"}, nil},
+		{"both empty", nil, []string{}, nil},
+		{"create", nil, []string{"b", "a"}, []utils.FieldChange{{Field: "labels", Added: []string{"a", "b"}, Removed: []string{}}}},
+		{"remove all", []string{"b", "a"}, nil, []utils.FieldChange{{Field: "labels", Added: []string{}, Removed: []string{"a", "b"}}}},
+		{"add and remove sorted", []string{"z", "a", "m"}, []string{"m", "y", "b"}, []utils.FieldChange{{Field: "labels", Added: []string{"b", "y"}, Removed: []string{"a", "z"}}}},
+	}
+	for _, tc := range tests {
+		if got := utils.DiffSet("labels", tc.current, tc.desired); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFieldChangeString(t *testing.T) {
+	tests := []struct {
+		change utils.FieldChange
+		want   string
+	}{
+		{utils.FieldChange{Field: "name", Old: "a", New: "b"}, "name from a to b"},
+		{utils.FieldChange{Field: "labels", Added: []string{"a", "b"}, Removed: []string{}}, "labels add a; b"},
+		{utils.FieldChange{Field: "labels", Added: []string{"a"}, Removed: []string{"c"}}, "labels add a and remove c"},
+	}
+	for _, tc := range tests {
+		if got := tc.change.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestChangeSet(t *testing.T) {
+	cs := utils.NewChangeSet[string]("ip lists")
+	if !cs.Empty() {
+		t.Fatal("new change set is not empty")
+	}
+	if cs.Update("unchanged", "unchanged", "/orgs/1/sec_policy/draft/ip_lists/1", nil, 2) {
+		t.Error("update without field changes was added")
+	}
+	if !cs.Empty() {
+		t.Fatal("change set with only an unchanged object is not empty")
+	}
+
+	cs.Delete("old", "old", "/orgs/1/sec_policy/draft/ip_lists/3")
+	if !cs.Update("changed", "changed", "/orgs/1/sec_policy/draft/ip_lists/2", utils.DiffValue("description", "a", "b"), 3) {
+		t.Error("update with field changes was not added")
+	}
+	cs.Create("new", "new", 4, 5)
+
+	actions := []string{}
+	for _, c := range cs.Changes() {
+		actions = append(actions, c.Action+" "+c.Object)
+	}
+	if want := []string{"create new", "update changed", "delete old"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("changes are %v, want %v", actions, want)
+	}
+	if want := "1 ip lists to create, 1 ip lists to update, and 1 ip lists to delete"; cs.Summary() != want {
+		t.Errorf("summary is %q, want %q", cs.Summary(), want)
+	}
+	if want := "csv line(s) 4, 5 - new - to be created"; cs.Creates[0].String() != want {
+		t.Errorf("create is %q, want %q", cs.Creates[0].String(), want)
+	}
+	if want := "csv line(s) 3 - changed (/orgs/1/sec_policy/draft/ip_lists/2) - to be updated - description from a to b"; cs.Updates[0].String() != want {
+		t.Errorf("update is %q, want %q", cs.Updates[0].String(), want)
+	}
+}
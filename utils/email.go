@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// SMTPConfig holds the settings used to send email
+type SMTPConfig struct {
+	Server   string
+	Port     int
+	User     string
+	Password string
+	From     string
+	TLS      string
+}
+
+// GetSMTPConfig returns the SMTP settings. Environment variables (WORKLOADER_SMTP_SERVER, WORKLOADER_SMTP_PORT, WORKLOADER_SMTP_USER,
+// WORKLOADER_SMTP_PASSWORD, WORKLOADER_SMTP_FROM, WORKLOADER_SMTP_TLS) take precedence over the smtp_server, smtp_port, smtp_user, smtp_password, smtp_from, and smtp_tls keys in pce.yaml.
+// smtp_tls is auto (default), implicit, starttls, or none. auto uses implicit TLS on port 465 and STARTTLS on other ports when the server supports it.
+func GetSMTPConfig() (SMTPConfig, error) {
+	c := SMTPConfig{Port: 25, TLS: "auto"}
+	values := []*string{&c.Server, &c.User, &c.Password, &c.From, &c.TLS}
+	for i, key := range []string{"smtp_server", "smtp_user", "smtp_password", "smtp_from", "smtp_tls"} {
+		if env := os.Getenv("WORKLOADER_" + strings.ToUpper(key)); env != "" {
+			*values[i] = env
+		} else if viper.IsSet(key) {
+			*values[i] = viper.GetString(key)
+		}
+	}
+	if env := os.Getenv("WORKLOADER_SMTP_PORT"); env != "" {
+		port, err := strconv.Atoi(env)
+		if err != nil {
+			return c, fmt.Errorf("%s is not a valid WORKLOADER_SMTP_PORT", env)
+		}
+		c.Port = port
+	} else if viper.IsSet("smtp_port") {
+		c.Port = viper.GetInt("smtp_port")
+	}
+
+	if c.Server == "" {
+		return c, fmt.Errorf("smtp server is not set. set smtp_server in pce.yaml or the WORKLOADER_SMTP_SERVER environment variable")
+	}
+	if c.From == "" {
+		c.From = c.User
+	}
+	c.TLS = strings.ToLower(c.TLS)
+	if c.TLS != "auto" && c.TLS != "implicit" && c.TLS != "starttls" && c.TLS != "none" {
+		return c, fmt.Errorf("%s is not a valid smtp_tls. must be auto, implicit, starttls, or none", c.TLS)
+	}
+	if c.From == "" {
+		return c, fmt.Errorf("smtp from address is not set. set smtp_from in pce.yaml or the WORKLOADER_SMTP_FROM environment variable")
+	}
+
+	return c, nil
+}
+
+// SendEmail sends an HTML email with optional file attachments using the SMTP settings from GetSMTPConfig.
+func SendEmail(to []string, subject, htmlBody string, attachments []string) error {
+
+	c, err := GetSMTPConfig()
+	if err != nil {
+		return err
+	}
+
+	msg, err := buildEmail(c.From, to, subject, htmlBody, attachments)
+	if err != nil {
+		return err
+	}
+
+	// Connect with implicit TLS or plain text
+	addr := net.JoinHostPort(c.Server, strconv.Itoa(c.Port))
+	tlsConfig := &tls.Config{ServerName: c.Server}
+	var conn net.Conn
+	if c.TLS == "implicit" || (c.TLS == "auto" && c.Port == 465) {
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, c.Server)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Upgrade with STARTTLS
+	if _, isTLS := conn.(*tls.Conn); !isTLS && c.TLS != "none" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if c.TLS == "starttls" {
+			return fmt.Errorf("%s does not support STARTTLS", c.Server)
+		}
+	}
+
+	// Authenticate. The standard library only sends credentials over TLS or to localhost.
+	if c.User != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.User, c.Password, c.Server)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, t := range to {
+		if err := client.Rcpt(t); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail creates a multipart MIME message
+func buildEmail(from string, to []string, subject, htmlBody string, attachments []string) ([]byte, error) {
+	boundary := fmt.Sprintf("workloader-%d", time.Now().UnixNano())
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ", ")))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	buf.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary))
+
+	// Body
+	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	buf.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	buf.WriteString(htmlBody)
+	buf.WriteString("\r\n")
+
+	// Attachments
+	for _, a := range attachments {
+		data, err := os.ReadFile(a)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment %s - %s", a, err)
+		}
+		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		buf.WriteString(fmt.Sprintf("Content-Type: application/octet-stream; name=%q\r\n", filepath.Base(a)))
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		buf.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=%q\r\n\r\n", filepath.Base(a)))
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// emailAttachmentLimit is the maximum total size of attachments. Larger files are listed in the email but not attached.
+const emailAttachmentLimit = 20 * 1024 * 1024
+
+// emailOutput tracks the output files of the running command for the --email-to flag
+var emailOutput struct {
+	command string
+	start   time.Time
+	files   []string
+}
+
+// emailRecipients returns the recipients from the --email-to flag
+func emailRecipients() []string {
+	recipients := []string{}
+	for _, r := range strings.Split(viper.GetString("email_to"), ",") {
+		if strings.TrimSpace(r) != "" {
+			recipients = append(recipients, strings.TrimSpace(r))
+		}
+	}
+	return recipients
+}
+
+// emailStart starts tracking output files. Commands started by another command (e.g., wkld-import run by a sync command) are ignored.
+func emailStart(command string) {
+	if len(emailRecipients()) == 0 || emailOutput.command != "" {
+		return
+	}
+	emailOutput.command = command
+	emailOutput.start = time.Now()
+	emailOutput.files = nil
+}
+
+// AddEmailAttachment adds a file to the --email-to email and the --result-file. The shared output writers call it for csv files. Commands that write other files (e.g., html reports) call it directly.
+func AddEmailAttachment(file string) {
+	resultOutputFile(file)
+	if emailOutput.command == "" {
+		return
+	}
+	for _, f := range emailOutput.files {
+		if f == file {
+			return
+		}
+	}
+	emailOutput.files = append(emailOutput.files, file)
+}
+
+// emailEnd sends the output files to the --email-to recipients
+func emailEnd(command string) {
+	if emailOutput.command != command {
+		return
+	}
+	emailOutput.command = ""
+	recipients := emailRecipients()
+
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("<h3>workloader %s - %s</h3>", html.EscapeString(command), html.EscapeString(notifyPCE())))
+	body.WriteString(fmt.Sprintf("<p>completed in %s</p>", time.Since(emailOutput.start).Round(time.Second)))
+
+	attachments := []string{}
+	var total int64
+	listed := []string{}
+	for _, f := range emailOutput.files {
+		if IsRemoteOutput(f) {
+			listed = append(listed, f)
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if total+info.Size() > emailAttachmentLimit {
+			LogWarning(fmt.Sprintf("%s is too large to attach to the email", f), true)
+			listed = append(listed, f)
+			continue
+		}
+		total = total + info.Size()
+		attachments = append(attachments, f)
+	}
+	if len(attachments) == 0 && len(listed) == 0 {
+		body.WriteString("<p>no output files.</p>")
+	}
+	if len(listed) > 0 {
+		body.WriteString("<p>output files not attached:</p><ul>")
+		for _, l := range listed {
+			body.WriteString(fmt.Sprintf("<li>%s</li>", html.EscapeString(l)))
+		}
+		body.WriteString("</ul>")
+	}
+
+	subject := fmt.Sprintf("workloader %s - %s - %s", command, notifyPCE(), time.Now().Format("2006-01-02"))
+	if err := SendEmail(recipients, subject, body.String(), attachments); err != nil {
+		LogWarning(fmt.Sprintf("sending email - %s", err), true)
+		return
+	}
+	LogInfo(fmt.Sprintf("emailed %d output files to %s", len(attachments), strings.Join(recipients, ", ")), true)
+}
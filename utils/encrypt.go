@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Encryption modes for the encryption.mode key in pce.yaml
+const (
+	EncryptionPassphrase = "passphrase"
+	EncryptionOSKey      = "os"
+)
+
+// encPrefix marks an encrypted value in pce.yaml
+const encPrefix = "enc:"
+
+// encCheck is encrypted into encryption.check to verify the passphrase
+const encCheck = "workloader"
+
+// encOSKeyAccount is the credential store account for the os-bound encryption key
+const encOSKeyAccount = "encryption-key"
+
+// encKey is the encryption key for this run. It is derived once so the passphrase is only prompted for once.
+var encKey []byte
+
+// EncryptionEnabled returns true if the secrets in pce.yaml are encrypted
+func EncryptionEnabled() bool {
+	return viper.GetString("encryption.mode") != ""
+}
+
+// readPassphrase reads the passphrase from WORKLOADER_KEY or prompts for it if stdin is a terminal
+func readPassphrase(prompt string) (string, error) {
+	if os.Getenv("WORKLOADER_KEY") != "" {
+		return os.Getenv("WORKLOADER_KEY"), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("pce.yaml secrets are encrypted. set the WORKLOADER_KEY environment variable")
+	}
+	fmt.Print(prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println("")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// passphraseKey derives a 256-bit key from a passphrase with scrypt
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// encryptionKey returns the key for the encryption settings in pce.yaml and verifies it against encryption.check
+func encryptionKey() ([]byte, error) {
+	if encKey != nil {
+		return encKey, nil
+	}
+	var key []byte
+	switch viper.GetString("encryption.mode") {
+	case EncryptionOSKey:
+		encoded, err := keychainGet(keychainService, encOSKeyAccount)
+		if err != nil {
+			return nil, fmt.Errorf("getting encryption key from the os credential store - %s", err)
+		}
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("decoding encryption key - %s", err)
+		}
+	case EncryptionPassphrase:
+		salt, err := base64.StdEncoding.DecodeString(viper.GetString("encryption.salt"))
+		if err != nil {
+			return nil, fmt.Errorf("decoding encryption.salt - %s", err)
+		}
+		passphrase, err := readPassphrase("pce.yaml passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if key, err = passphraseKey(passphrase, salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a valid encryption.mode. must be %s or %s", viper.GetString("encryption.mode"), EncryptionPassphrase, EncryptionOSKey)
+	}
+	check, err := decryptWithKey(key, viper.GetString("encryption.check"))
+	if err != nil || check != encCheck {
+		return nil, fmt.Errorf("incorrect passphrase or encryption key for pce.yaml")
+	}
+	encKey = key
+	return encKey, nil
+}
+
+// encryptWithKey encrypts a value with AES-256-GCM and returns it with the enc: prefix
+func encryptWithKey(key []byte, plain string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// decryptWithKey decrypts a value with the enc: prefix
+func decryptWithKey(key []byte, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// EncryptSecret encrypts a value with the pce.yaml encryption key. Blank and already encrypted values are returned unchanged.
+func EncryptSecret(value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, encPrefix) {
+		return value, nil
+	}
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return encryptWithKey(key, value)
+}
+
+// DecryptSecret decrypts a value from pce.yaml. Values without the enc: prefix are returned unchanged.
+func DecryptSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, encPrefix) {
+		return value, nil
+	}
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	plain, err := decryptWithKey(key, value)
+	if err != nil {
+		return "", fmt.Errorf("decrypting pce.yaml secret - %s", err)
+	}
+	return plain, nil
+}
+
+// EnableEncryption sets up encryption in pce.yaml with a passphrase or a random key stored in the OS credential store. The caller writes the config.
+func EnableEncryption(mode, passphrase string) error {
+	var key []byte
+	var err error
+	switch mode {
+	case EncryptionOSKey:
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		if err := keychainSet(keychainService, encOSKeyAccount, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return fmt.Errorf("storing encryption key in the os credential store - %s", err)
+		}
+		viper.Set("encryption.salt", "")
+	case EncryptionPassphrase:
+		if passphrase == "" {
+			return fmt.Errorf("passphrase cannot be blank")
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if key, err = passphraseKey(passphrase, salt); err != nil {
+			return err
+		}
+		viper.Set("encryption.salt", base64.StdEncoding.EncodeToString(salt))
+	default:
+		return fmt.Errorf("%s is not a valid encryption mode", mode)
+	}
+	check, err := encryptWithKey(key, encCheck)
+	if err != nil {
+		return err
+	}
+	viper.Set("encryption.mode", mode)
+	viper.Set("encryption.check", check)
+	encKey = key
+	return nil
+}
+
+// DisableEncryption removes the encryption settings from pce.yaml. Secrets must be decrypted first. The caller writes the config.
+func DisableEncryption() {
+	if viper.GetString("encryption.mode") == EncryptionOSKey {
+		keychainDelete(keychainService, encOSKeyAccount)
+	}
+	viper.Set("encryption.mode", "")
+	viper.Set("encryption.salt", "")
+	viper.Set("encryption.check", "")
+	encKey = nil
+}
+
+// convertPCESecrets applies f to the secrets of a PCE entry, including the keys in the orgs list. The caller writes the config.
+func convertPCESecrets(name string, f func(string) (string, error)) error {
+	for _, field := range KeychainSecrets {
+		if !viper.IsSet(name + "." + field) {
+			continue
+		}
+		value, err := f(viper.GetString(name + "." + field))
+		if err != nil {
+			return fmt.Errorf("%s %s - %s", name, field, err)
+		}
+		viper.Set(name+"."+field, value)
+	}
+	if orgs, ok := viper.Get(name + ".orgs").([]interface{}); ok {
+		for i, o := range orgs {
+			org, ok := o.(map[string]interface{})
+			if !ok || org["key"] == nil {
+				continue
+			}
+			value, err := f(fmt.Sprintf("%v", org["key"]))
+			if err != nil {
+				return fmt.Errorf("%s orgs %d - %s", name, i, err)
+			}
+			org["key"] = value
+		}
+		viper.Set(name+".orgs", orgs)
+	}
+	return nil
+}
+
+// EncryptPCESecrets encrypts the plaintext secrets of a PCE entry. The caller writes the config.
+func EncryptPCESecrets(name string) error {
+	return convertPCESecrets(name, EncryptSecret)
+}
+
+// DecryptPCESecrets decrypts the secrets of a PCE entry. The caller writes the config.
+func DecryptPCESecrets(name string) error {
+	return convertPCESecrets(name, DecryptSecret)
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	_ "golang.org/x/crypto/ripemd160" // the default hash for public keys that do not list preferred hashes
+	"golang.org/x/term"
+)
+
+// EncryptOutputPassphrase is the --encrypt-output value to encrypt output files with a passphrase instead of public keys
+const EncryptOutputPassphrase = "passphrase"
+
+// encryptedOutputExt is added to the name of encrypted output files
+const encryptedOutputExt = ".gpg"
+
+// outputEncryption is the recipients or passphrase for output files. It is loaded once so the passphrase is only prompted for once.
+var outputEncryption struct {
+	loaded     bool
+	recipients openpgp.EntityList
+	passphrase []byte
+}
+
+// EncryptOutput returns the --encrypt-output setting. The flag takes precedence over WORKLOADER_ENCRYPT_OUTPUT, which takes precedence over
+// encrypt_output in pce.yaml. The value is passphrase or a comma-separated list of OpenPGP public key files.
+func EncryptOutput() string {
+	if viper.GetString("encrypt_output_flag") != "" {
+		return viper.GetString("encrypt_output_flag")
+	}
+	if os.Getenv("WORKLOADER_ENCRYPT_OUTPUT") != "" {
+		return os.Getenv("WORKLOADER_ENCRYPT_OUTPUT")
+	}
+	return viper.GetString("encrypt_output")
+}
+
+// EncryptingOutput returns true if output files are encrypted
+func EncryptingOutput() bool {
+	return EncryptOutput() != ""
+}
+
+// LoadOutputEncryption reads the public keys or passphrase for encrypted output. It is called before the command runs so a bad key
+// file or missing passphrase stops the command before it does any work.
+func LoadOutputEncryption() error {
+	if outputEncryption.loaded || !EncryptingOutput() {
+		return nil
+	}
+	if strings.EqualFold(EncryptOutput(), EncryptOutputPassphrase) {
+		passphrase, err := readOutputPassphrase()
+		if err != nil {
+			return err
+		}
+		outputEncryption.passphrase = []byte(passphrase)
+		outputEncryption.loaded = true
+		return nil
+	}
+	for _, keyFile := range strings.Split(EncryptOutput(), ",") {
+		keyFile = strings.TrimSpace(keyFile)
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("reading encrypt-output public key - %s", err)
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			if entities, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+				return fmt.Errorf("%s is not a valid OpenPGP public key - %s", keyFile, err)
+			}
+		}
+		outputEncryption.recipients = append(outputEncryption.recipients, entities...)
+	}
+	outputEncryption.loaded = true
+	return nil
+}
+
+// OutputEncryptionEnv returns the environment for a workloader command run as a separate process to use the passphrase that was already read
+func OutputEncryptionEnv() []string {
+	if outputEncryption.passphrase == nil {
+		return nil
+	}
+	return []string{"WORKLOADER_OUTPUT_PASSPHRASE=" + string(outputEncryption.passphrase)}
+}
+
+// readOutputPassphrase reads the output passphrase from WORKLOADER_OUTPUT_PASSPHRASE or prompts for it twice if stdin is a terminal
+func readOutputPassphrase() (string, error) {
+	if os.Getenv("WORKLOADER_OUTPUT_PASSPHRASE") != "" {
+		return os.Getenv("WORKLOADER_OUTPUT_PASSPHRASE"), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("--encrypt-output passphrase requires the WORKLOADER_OUTPUT_PASSPHRASE environment variable when not run in a terminal")
+	}
+	fmt.Print("output file passphrase: ")
+	first, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println("")
+	if err != nil {
+		return "", err
+	}
+	fmt.Print("confirm output file passphrase: ")
+	second, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println("")
+	if err != nil {
+		return "", err
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("output file passphrase cannot be blank")
+	}
+	if string(first) != string(second) {
+		return "", fmt.Errorf("output file passphrases do not match")
+	}
+	return string(first), nil
+}
+
+// encryptedOutputName adds the encrypted extension to an output file name when output is encrypted
+func encryptedOutputName(fileName string) string {
+	if !EncryptingOutput() || strings.HasSuffix(fileName, encryptedOutputExt) {
+		return fileName
+	}
+	return fileName + encryptedOutputExt
+}
+
+// newOutputEncrypter returns a writer that encrypts to w. fileName is the encrypted file and the name without the encrypted extension is
+// stored in the file for gpg --use-embedded-filename. The writer must be closed to finish the file.
+func newOutputEncrypter(w io.Writer, fileName string) (io.WriteCloser, error) {
+	if err := LoadOutputEncryption(); err != nil {
+		return nil, err
+	}
+	hints := &openpgp.FileHints{FileName: strings.TrimSuffix(filepath.Base(fileName), encryptedOutputExt)}
+	config := &packet.Config{DefaultCipher: packet.CipherAES256}
+	if outputEncryption.passphrase != nil {
+		return openpgp.SymmetricallyEncrypt(w, outputEncryption.passphrase, hints, config)
+	}
+	return openpgp.Encrypt(w, outputEncryption.recipients, nil, hints, config)
+}
+
+// stagingFile returns the local file for plaintext rows that are converted to the --format or encrypted when the output is finished.
+// Plaintext for encrypted output is staged in the temp directory so it is not written next to the encrypted file.
+func stagingFile(fileName string) string {
+	if !EncryptingOutput() {
+		return fileName + ".partial.csv"
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("workloader-%d-%s.partial.csv", os.Getpid(), filepath.Base(fileName)))
+}
+
+// createStagingFile creates a staging file that only the user can read
+func createStagingFile(fileName string) (*os.File, error) {
+	return os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+}
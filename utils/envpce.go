@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// EnvPCEName is the name of the PCE defined by the unprefixed WORKLOADER_FQDN environment variables
+const EnvPCEName = "env"
+
+// envPCEVar returns the environment variable for a field of a PCE defined by environment variables.
+// The env PCE uses WORKLOADER_<FIELD> and other PCEs use WORKLOADER_<NAME>_<FIELD>.
+func envPCEVar(name, field string) string {
+	if name == EnvPCEName {
+		return "WORKLOADER_" + field
+	}
+	return "WORKLOADER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + field
+}
+
+// EnvPCENames returns the names of the PCEs defined by environment variables.
+// WORKLOADER_FQDN defines the env PCE and WORKLOADER_<NAME>_FQDN defines a PCE named <name> in lower case.
+func EnvPCENames() []string {
+	names := []string{}
+	for _, e := range os.Environ() {
+		k := strings.SplitN(e, "=", 2)[0]
+		if k == "WORKLOADER_FQDN" {
+			names = append(names, EnvPCEName)
+		} else if strings.HasPrefix(k, "WORKLOADER_") && strings.HasSuffix(k, "_FQDN") && len(k) > len("WORKLOADER__FQDN") {
+			names = append(names, strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(k, "WORKLOADER_"), "_FQDN")))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsEnvPCE returns true if the PCE is defined by environment variables
+func IsEnvPCE(name string) bool {
+	return name != "" && os.Getenv(envPCEVar(name, "FQDN")) != ""
+}
+
+// GetEnvPCE builds a PCE from the FQDN, PORT, ORG, KEY, SECRET, PROXY, and DISABLE_TLS environment variables.
+// KEY is the api key username and SECRET is the api key secret. TOKEN can be used instead for a service account bearer token.
+func GetEnvPCE(name string) (illumioapi.PCE, PCEAuth, error) {
+	get := func(field string) string { return os.Getenv(envPCEVar(name, field)) }
+	pce := illumioapi.PCE{FriendlyName: name, FQDN: get("FQDN"), Port: 443, User: get("KEY"), Key: get("SECRET"), Proxy: get("PROXY")}
+	auth := PCEAuth{Type: PCEAuthAPIKey}
+	var err error
+	if get("PORT") != "" {
+		if pce.Port, err = strconv.Atoi(get("PORT")); err != nil {
+			return pce, auth, fmt.Errorf("%s is not a valid port - %s", envPCEVar(name, "PORT"), err)
+		}
+	}
+	if get("ORG") != "" {
+		if pce.Org, err = strconv.Atoi(get("ORG")); err != nil {
+			return pce, auth, fmt.Errorf("%s is not a valid org - %s", envPCEVar(name, "ORG"), err)
+		}
+	}
+	if get("DISABLE_TLS") != "" {
+		if pce.DisableTLSChecking, err = strconv.ParseBool(get("DISABLE_TLS")); err != nil {
+			return pce, auth, fmt.Errorf("%s must be true or false", envPCEVar(name, "DISABLE_TLS"))
+		}
+	}
+	if get("TOKEN") != "" {
+		auth = PCEAuth{Type: PCEAuthToken, Token: get("TOKEN")}
+	} else if pce.User == "" || pce.Key == "" {
+		return pce, auth, fmt.Errorf("%s requires %s and %s", name, envPCEVar(name, "KEY"), envPCEVar(name, "SECRET"))
+	}
+	return pce, auth, nil
+}
+
+// DefaultPCEName returns the default PCE from WORKLOADER_DEFAULT_PCE_NAME, default_pce_name in pce.yaml, or the PCE defined by environment variables if there is only one
+func DefaultPCEName() string {
+	if os.Getenv("WORKLOADER_DEFAULT_PCE_NAME") != "" {
+		return os.Getenv("WORKLOADER_DEFAULT_PCE_NAME")
+	}
+	if viper.GetString("default_pce_name") != "" {
+		return viper.GetString("default_pce_name")
+	}
+	if names := EnvPCENames(); len(names) == 1 {
+		return names[0]
+	}
+	return ""
+}
+
+// WriteConfig writes the config file unless workloader is running only from environment variables with no config file on disk
+func WriteConfig() error {
+	if _, err := os.Stat(viper.ConfigFileUsed()); os.IsNotExist(err) && len(EnvPCENames()) > 0 {
+		return nil
+	}
+	return viper.WriteConfig()
+}
+
+// PCEFQDN returns the fqdn configured for a PCE in pce.yaml or by environment variables.
+// Use it instead of pce.FQDN when showing or connecting to the PCE outside of the illumioapi requests.
+func PCEFQDN(name string) string {
+	if IsEnvPCE(name) {
+		return os.Getenv(envPCEVar(name, "FQDN"))
+	}
+	return viper.GetString(name + ".fqdn")
+}
+
+// PCEPort returns the port configured for a PCE in pce.yaml or by environment variables
+func PCEPort(name string) int {
+	if IsEnvPCE(name) {
+		if port, err := strconv.Atoi(os.Getenv(envPCEVar(name, "PORT"))); err == nil {
+			return port
+		}
+		return 443
+	}
+	return viper.GetInt(name + ".port")
+}
@@ -0,0 +1,34 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+// TestPCEFQDN checks the configured fqdn and port are returned for yaml and env PCEs
+func TestPCEFQDN(t *testing.T) {
+	viper.Set("yaml-pce.fqdn", "yaml.example.com")
+	viper.Set("yaml-pce.port", 8443)
+	t.Cleanup(func() { viper.Set("yaml-pce", nil) })
+	t.Setenv("WORKLOADER_ENV_PCE_FQDN", "env.example.com")
+	t.Setenv("WORKLOADER_FQDN", "default.example.com")
+	t.Setenv("WORKLOADER_PORT", "9443")
+
+	tests := []struct {
+		name string
+		fqdn string
+		port int
+	}{
+		{"yaml-pce", "yaml.example.com", 8443},
+		{"env-pce", "env.example.com", 443},
+		{utils.EnvPCEName, "default.example.com", 9443},
+		{"missing", "", 0},
+	}
+	for _, tc := range tests {
+		if fqdn, port := utils.PCEFQDN(tc.name), utils.PCEPort(tc.name); fqdn != tc.fqdn || port != tc.port {
+			t.Errorf("%s is %s:%d, want %s:%d", tc.name, fqdn, port, tc.fqdn, tc.port)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// Event types
+const (
+	EventCommandStarted = "command.started"
+	EventObjectCreated  = "object.created"
+	EventObjectUpdated  = "object.updated"
+	EventObjectDeleted  = "object.deleted"
+	EventWarning        = "warning"
+	EventError          = "error"
+	EventSummary        = "summary"
+)
+
+// Event is sent to webhook subscriptions. It is the template data for subscriptions with a payload template.
+type Event struct {
+	Type    string                 `json:"type"`
+	Command string                 `json:"command"`
+	PCE     string                 `json:"pce"`
+	Time    string                 `json:"time"`
+	Message string                 `json:"message,omitempty"`
+	Href    string                 `json:"href,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// WebhookSubscription is an entry in the webhooks list in pce.yaml. For example:
+//
+//	webhooks:
+//	  - url: https://hooks.example.com/workloader
+//	    events: [command.started, object.*, error, summary]
+//	    commands: [wkld-import, label-import]
+//	    headers: {Authorization: Bearer abc123}
+//	    secret: signing-secret
+//	    template: '{"text": "{{.Command}} {{.Type}} {{.Message}} {{.Href}}"}'
+//
+// Events and commands are optional and support wildcards. All events for all commands are sent if they are blank.
+// The body is the json event unless a template is provided. If a secret is provided, the X-Workloader-Signature header
+// is the hex hmac-sha256 of the body.
+type WebhookSubscription struct {
+	URL         string            `mapstructure:"url"`
+	Events      []string          `mapstructure:"events"`
+	Commands    []string          `mapstructure:"commands"`
+	Headers     map[string]string `mapstructure:"headers"`
+	Secret      string            `mapstructure:"secret"`
+	Template    string            `mapstructure:"template"`
+	ContentType string            `mapstructure:"content_type"`
+	tmpl        *template.Template
+}
+
+// events tracks the running command and webhook deliveries
+var events struct {
+	loaded        bool
+	subscriptions []WebhookSubscription
+	command       string
+	start         time.Time
+	warnings      int
+	objects       map[string]int
+	messages      []string
+	wg            sync.WaitGroup
+	sem           chan bool
+}
+
+// loadWebhooks parses the webhooks in pce.yaml once. Invalid subscriptions are logged and skipped.
+func loadWebhooks() {
+	if events.loaded {
+		return
+	}
+	events.loaded = true
+	events.sem = make(chan bool, 4)
+	if !viper.IsSet("webhooks") {
+		return
+	}
+	subs := []WebhookSubscription{}
+	if err := viper.UnmarshalKey("webhooks", &subs); err != nil {
+		Logger.Printf("[WARNING] - parsing webhooks in pce.yaml - %s\r\n", err)
+		return
+	}
+	for _, s := range subs {
+		if s.URL == "" {
+			Logger.Printf("[WARNING] - webhook subscription without a url in pce.yaml. skipping.\r\n")
+			continue
+		}
+		if s.Template != "" {
+			t, err := template.New(s.URL).Funcs(template.FuncMap{"json": func(v interface{}) string { b, _ := json.Marshal(v); return string(b) }}).Parse(s.Template)
+			if err != nil {
+				Logger.Printf("[WARNING] - parsing webhook template for %s - %s. skipping.\r\n", s.URL, err)
+				continue
+			}
+			s.tmpl = t
+		}
+		if s.ContentType == "" {
+			s.ContentType = "application/json"
+		}
+		events.subscriptions = append(events.subscriptions, s)
+	}
+}
+
+// EmitEvent sends an event to the matching webhook subscriptions. Commands can emit their own events in addition to the events
+// emitted by the logging functions. Delivery is asynchronous and failures are written to workloader.log only.
+func EmitEvent(eventType, message, href string, data map[string]interface{}) {
+	loadWebhooks()
+	if len(events.subscriptions) == 0 {
+		return
+	}
+	e := Event{Type: eventType, Command: events.command, PCE: notifyPCE(), Time: time.Now().UTC().Format(time.RFC3339), Message: message, Href: href, Data: data}
+	for _, s := range events.subscriptions {
+		if !webhookMatch(s.Events, e.Type) || !webhookMatch(s.Commands, e.Command) {
+			continue
+		}
+		events.wg.Add(1)
+		events.sem <- true
+		go func(s WebhookSubscription) {
+			defer func() { <-events.sem; events.wg.Done() }()
+			if err := sendWebhook(s, e); err != nil {
+				Logger.Printf("[WARNING] - sending %s event to %s - %s\r\n", e.Type, s.URL, err)
+			}
+		}(s)
+	}
+}
+
+// webhookMatch returns true if the value matches a pattern or there are no patterns
+func webhookMatch(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sendWebhook creates the body and posts it
+func sendWebhook(s WebhookSubscription, e Event) error {
+	var body []byte
+	if s.tmpl != nil {
+		var b bytes.Buffer
+		if err := s.tmpl.Execute(&b, e); err != nil {
+			return err
+		}
+		body = b.Bytes()
+	} else {
+		var err error
+		if body, err = json.Marshal(e); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.ContentType)
+	req.Header.Set("X-Workloader-Event", e.Type)
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.Secret != "" {
+		h := hmac.New(sha256.New, []byte(s.Secret))
+		h.Write(body)
+		req.Header.Set("X-Workloader-Signature", "sha256="+hex.EncodeToString(h.Sum(nil)))
+	}
+	client := &http.Client{Timeout: 15 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// flushEvents waits up to 30 seconds for deliveries to finish
+func flushEvents() {
+	done := make(chan bool)
+	go func() { events.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		Logger.Printf("[WARNING] - timed out waiting for webhook deliveries\r\n")
+	}
+}
+
+// eventStart emits the started event. Commands started by another command (e.g., wkld-import run by a sync command) are ignored.
+func eventStart(command string) {
+	if events.command != "" {
+		return
+	}
+	events.command = command
+	events.start = time.Now()
+	events.warnings = 0
+	events.objects = make(map[string]int)
+	events.messages = nil
+	EmitEvent(EventCommandStarted, fmt.Sprintf("workloader %s started", command), "", nil)
+}
+
+// eventMessage keeps the last 10 messages printed to stdout for the summary event
+func eventMessage(msg string) {
+	if events.command == "" {
+		return
+	}
+	events.messages = append(events.messages, msg)
+	if len(events.messages) > 10 {
+		events.messages = events.messages[1:]
+	}
+}
+
+// eventWarning emits a warning event
+func eventWarning(msg string) {
+	events.warnings++
+	EmitEvent(EventWarning, msg, "", nil)
+}
+
+// eventAPIResp emits an object event for successful create, update, and delete api calls
+func eventAPIResp(callType string, apiResp illumioapi.APIResponse) {
+	if apiResp.Request == nil || apiResp.StatusCode < 200 || apiResp.StatusCode > 299 {
+		return
+	}
+	eventType := ""
+	switch apiResp.Request.Method {
+	case "POST":
+		eventType = EventObjectCreated
+	case "PUT":
+		eventType = EventObjectUpdated
+	case "DELETE":
+		eventType = EventObjectDeleted
+	default:
+		return
+	}
+
+	// Use the href in the response for creates and the url for updates and deletes
+	href := strings.TrimPrefix(apiResp.Request.URL.Path, "/api/v2")
+	if eventType == EventObjectCreated {
+		var created struct {
+			Href string `json:"href"`
+		}
+		if json.Unmarshal([]byte(apiResp.RespBody), &created) == nil && created.Href != "" {
+			href = created.Href
+		}
+	}
+	if events.objects != nil {
+		events.objects[eventType]++
+	}
+	EmitEvent(eventType, callType, href, nil)
+}
+
+// eventEnd emits the summary event
+func eventEnd(command string) {
+	if events.command != command {
+		return
+	}
+	data := map[string]interface{}{"duration_seconds": int(time.Since(events.start).Seconds()), "warnings": events.warnings, "messages": events.messages, "objects": events.objects}
+	EmitEvent(EventSummary, fmt.Sprintf("workloader %s completed in %s with %d warnings", command, time.Since(events.start).Round(time.Second), events.warnings), "", data)
+	events.command = ""
+	flushEvents()
+}
+
+// eventError emits the error event and waits for deliveries before the command exits
+func eventError(msg string) {
+	EmitEvent(EventError, msg, "", nil)
+	flushEvents()
+}
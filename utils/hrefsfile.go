@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// AnnotationHrefsFile is the cobra annotation for commands that limit the workloads they target to the --hrefs-file
+const AnnotationHrefsFile = "hrefs_file"
+
+// hrefsFile is the hrefs in the --hrefs-file. It is loaded once before the command runs.
+var hrefsFile struct {
+	loaded bool
+	hrefs  []string
+	set    map[string]bool
+}
+
+// HrefsFile returns the --hrefs-file
+func HrefsFile() string {
+	return viper.GetString("hrefs_file_flag")
+}
+
+// LoadHrefsFile reads the --hrefs-file. The file has one href per line. The output of a report can also be used - if the first row
+// is a header, the href column is used. Blank lines are skipped.
+func LoadHrefsFile() error {
+	if hrefsFile.loaded || HrefsFile() == "" {
+		return nil
+	}
+	data, err := ParseCSV(HrefsFile())
+	if err != nil {
+		return fmt.Errorf("reading hrefs file - %s", err)
+	}
+
+	// Find the href column from the header
+	col, start := 0, 0
+	if len(data) > 0 && !strings.Contains(strings.Join(data[0], ","), "/orgs/") {
+		col, start = hrefsFileColumn(data[0]), 1
+	}
+
+	hrefsFile.set = make(map[string]bool)
+	for i, row := range data {
+		if i < start || len(row) <= col || strings.TrimSpace(row[col]) == "" {
+			continue
+		}
+		href := strings.TrimSpace(row[col])
+		if !strings.HasPrefix(href, "/orgs/") {
+			return fmt.Errorf("%s line %d - %s is not an href", HrefsFile(), i+1, href)
+		}
+		if !hrefsFile.set[href] {
+			hrefsFile.hrefs = append(hrefsFile.hrefs, href)
+			hrefsFile.set[href] = true
+		}
+	}
+	if len(hrefsFile.hrefs) == 0 {
+		return fmt.Errorf("%s has no hrefs", HrefsFile())
+	}
+	hrefsFile.loaded = true
+	LogInfo(fmt.Sprintf("limiting to %d hrefs in %s", len(hrefsFile.hrefs), HrefsFile()), false)
+	return nil
+}
+
+// hrefsFileColumn returns the href column of a header. href is used first and then the first column ending in href (e.g., wkld_href).
+func hrefsFileColumn(header []string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), "href") {
+			return i
+		}
+	}
+	for i, h := range header {
+		if strings.HasSuffix(strings.ToLower(strings.TrimSpace(h)), "href") {
+			return i
+		}
+	}
+	return 0
+}
+
+// HrefsFileHrefs returns the hrefs in the --hrefs-file in the order of the file
+func HrefsFileHrefs() []string {
+	return hrefsFile.hrefs
+}
+
+// InHrefsFile returns true if any of the hrefs is in the --hrefs-file or if there is no --hrefs-file.
+// Pass the workload and VEN hrefs so a file of either works.
+func InHrefsFile(hrefs ...string) bool {
+	if HrefsFile() == "" {
+		return true
+	}
+	for _, href := range hrefs {
+		if href != "" && hrefsFile.set[href] {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkloadInHrefsFile returns true if the workload or its VEN is in the --hrefs-file or if there is no --hrefs-file
+func WorkloadInHrefsFile(w illumioapi.Workload) bool {
+	if w.VEN != nil {
+		return InHrefsFile(w.Href, w.VEN.Href)
+	}
+	return InHrefsFile(w.Href)
+}
+
+// VENInHrefsFile returns true if the VEN or one of its workloads is in the --hrefs-file or if there is no --hrefs-file
+func VENInHrefsFile(v illumioapi.VEN) bool {
+	hrefs := []string{v.Href}
+	if v.Workloads != nil {
+		for _, w := range *v.Workloads {
+			if w != nil {
+				hrefs = append(hrefs, w.Href)
+			}
+		}
+	}
+	return InHrefsFile(hrefs...)
+}
@@ -0,0 +1,19 @@
+package utils
+
+import "github.com/brian1917/illumioapi"
+
+// AnyIP returns true if the ip list includes every IPv4 or IPv6 address
+func AnyIP(ipl illumioapi.IPList) bool {
+	if ipl.IPRanges == nil {
+		return false
+	}
+	for _, r := range *ipl.IPRanges {
+		if r == nil || r.Exclusion {
+			continue
+		}
+		if r.FromIP == "0.0.0.0/0" || r.FromIP == "::/0" || r.FromIP == "0.0.0.0" && r.ToIP == "255.255.255.255" {
+			return true
+		}
+	}
+	return false
+}
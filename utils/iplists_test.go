@@ -0,0 +1,28 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/utils"
+)
+
+func TestAnyIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges *[]*illumioapi.IPRange
+		want   bool
+	}{
+		{"no ranges", nil, false},
+		{"ipv4 cidr", &[]*illumioapi.IPRange{{FromIP: "0.0.0.0/0"}}, true},
+		{"ipv6 cidr", &[]*illumioapi.IPRange{{FromIP: "10.0.0.0/8"}, {FromIP: "::/0"}}, true},
+		{"ipv4 range", &[]*illumioapi.IPRange{{FromIP: "0.0.0.0", ToIP: "255.255.255.255"}}, true},
+		{"exclusion", &[]*illumioapi.IPRange{{FromIP: "0.0.0.0/0", Exclusion: true}}, false},
+		{"private", &[]*illumioapi.IPRange{{FromIP: "10.0.0.0/8"}, nil}, false},
+	}
+	for _, tc := range tests {
+		if got := utils.AnyIP(illumioapi.IPList{IPRanges: tc.ranges}); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// JiraConfig holds the settings used to create Jira issues
+type JiraConfig struct {
+	URL       string // e.g., https://company.atlassian.net
+	User      string // blank for a data center personal access token
+	Token     string
+	Project   string
+	IssueType string
+}
+
+// JiraInput holds the per-command settings for filing findings as Jira issues
+type JiraInput struct {
+	Enabled bool
+	Project string // overrides jira_project
+	GroupBy string // header to group findings by. blank is one issue per finding.
+	Summary string // text/template for the summary. row headers are fields and groups also have .group and .count.
+}
+
+// GetJiraConfig returns the Jira settings. Environment variables (WORKLOADER_JIRA_URL, WORKLOADER_JIRA_USER, WORKLOADER_JIRA_TOKEN,
+// WORKLOADER_JIRA_PROJECT, WORKLOADER_JIRA_ISSUE_TYPE) take precedence over the jira_url, jira_user, jira_token, jira_project, and jira_issue_type keys in pce.yaml.
+func GetJiraConfig() (JiraConfig, error) {
+	c := JiraConfig{IssueType: "Task"}
+	values := []*string{&c.URL, &c.User, &c.Token, &c.Project, &c.IssueType}
+	for i, key := range []string{"jira_url", "jira_user", "jira_token", "jira_project", "jira_issue_type"} {
+		if env := os.Getenv("WORKLOADER_" + strings.ToUpper(key)); env != "" {
+			*values[i] = env
+		} else if viper.IsSet(key) {
+			*values[i] = viper.GetString(key)
+		}
+	}
+	if c.URL == "" || c.Token == "" {
+		return c, fmt.Errorf("jira url and token are not set. set jira_url and jira_token in pce.yaml or the WORKLOADER_JIRA_URL and WORKLOADER_JIRA_TOKEN environment variables")
+	}
+	if !strings.HasPrefix(c.URL, "http") {
+		c.URL = "https://" + c.URL
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	return c, nil
+}
+
+// call sends a request to the Jira rest api
+func (c JiraConfig) call(method, path string, payload, result interface{}) (int, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, c.URL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("jira %s %s - %d - %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if result == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(respBody, result)
+}
+
+// jiraFinding is an issue to file
+type jiraFinding struct {
+	fingerprint string
+	summary     string
+	rows        [][]string
+}
+
+// FileJiraIssues creates a Jira issue for each row of csv data after the header row or, if GroupBy is set, for each group of rows.
+// Each issue has a workloader-<fingerprint> label created from the command and the keyHeaders values (or the group value).
+// An issue is not created if an unresolved issue in the project already has the label.
+func FileJiraIssues(input JiraInput, command string, data [][]string, keyHeaders []string, defaultSummary, defaultGroupSummary string) error {
+	if len(data) < 2 {
+		return nil
+	}
+	c, err := GetJiraConfig()
+	if err != nil {
+		return err
+	}
+	if input.Project != "" {
+		c.Project = input.Project
+	}
+	if c.Project == "" {
+		return fmt.Errorf("jira project is not set. set jira_project in pce.yaml, the WORKLOADER_JIRA_PROJECT environment variable, or the command flag")
+	}
+
+	headers := make(map[string]int)
+	for i, h := range data[0] {
+		headers[h] = i
+	}
+	for _, k := range append(keyHeaders, input.GroupBy) {
+		if _, ok := headers[k]; !ok && k != "" {
+			return fmt.Errorf("%s is not a header in the %s output", k, command)
+		}
+	}
+	rowMap := func(row []string) map[string]string {
+		m := make(map[string]string)
+		for h, i := range headers {
+			if i < len(row) {
+				m[h] = row[i]
+			}
+		}
+		return m
+	}
+
+	summary := input.Summary
+	if summary == "" && input.GroupBy != "" {
+		summary = defaultGroupSummary
+	} else if summary == "" {
+		summary = defaultSummary
+	}
+	tmpl, err := template.New("summary").Option("missingkey=zero").Parse(summary)
+	if err != nil {
+		return fmt.Errorf("parsing jira summary template - %s", err)
+	}
+	render := func(fields map[string]string) (string, error) {
+		var b strings.Builder
+		err := tmpl.Execute(&b, fields)
+		return strings.TrimSpace(b.String()), err
+	}
+
+	// Build the findings
+	findings := []jiraFinding{}
+	if input.GroupBy == "" {
+		for _, row := range data[1:] {
+			key := []string{command}
+			for _, k := range keyHeaders {
+				key = append(key, row[headers[k]])
+			}
+			s, err := render(rowMap(row))
+			if err != nil {
+				return err
+			}
+			findings = append(findings, jiraFinding{fingerprint: jiraFingerprint(key), summary: s, rows: [][]string{row}})
+		}
+	} else {
+		groups := make(map[string][][]string)
+		for _, row := range data[1:] {
+			groups[row[headers[input.GroupBy]]] = append(groups[row[headers[input.GroupBy]]], row)
+		}
+		groupNames := []string{}
+		for g := range groups {
+			groupNames = append(groupNames, g)
+		}
+		sort.Strings(groupNames)
+		for _, g := range groupNames {
+			fields := rowMap(groups[g][0])
+			fields["group"] = g
+			fields["count"] = fmt.Sprintf("%d", len(groups[g]))
+			s, err := render(fields)
+			if err != nil {
+				return err
+			}
+			findings = append(findings, jiraFinding{fingerprint: jiraFingerprint([]string{command, "group", input.GroupBy, g}), summary: s, rows: groups[g]})
+		}
+	}
+
+	// Find the fingerprints with unresolved issues
+	existing, err := c.openFingerprints(findings)
+	if err != nil {
+		return err
+	}
+
+	// Create the issues
+	created, skipped := 0, 0
+	for _, f := range findings {
+		if existing[f.fingerprint] != "" {
+			LogInfo(fmt.Sprintf("jira - %s already filed as %s. skipping.", f.summary, existing[f.fingerprint]), false)
+			skipped++
+			continue
+		}
+		payload := map[string]interface{}{"fields": map[string]interface{}{
+			"project":     map[string]string{"key": c.Project},
+			"issuetype":   map[string]string{"name": c.IssueType},
+			"summary":     f.summary,
+			"description": jiraDescription(command, data[0], f.rows),
+			"labels":      []string{"workloader", "workloader-" + command, f.fingerprint},
+		}}
+		var issue struct {
+			Key string `json:"key"`
+		}
+		if _, err := c.call("POST", "/rest/api/2/issue", payload, &issue); err != nil {
+			return err
+		}
+		LogInfo(fmt.Sprintf("jira - created %s - %s", issue.Key, f.summary), false)
+		created++
+	}
+	LogInfo(fmt.Sprintf("created %d jira issues in %s. %d findings already have unresolved issues.", created, c.Project, skipped), true)
+	return nil
+}
+
+// openFingerprints returns the issue key for each fingerprint label with an unresolved issue in the project
+func (c JiraConfig) openFingerprints(findings []jiraFinding) (map[string]string, error) {
+	existing := make(map[string]string)
+	for start := 0; start < len(findings); start = start + 50 {
+		end := start + 50
+		if end > len(findings) {
+			end = len(findings)
+		}
+		labels := []string{}
+		for _, f := range findings[start:end] {
+			labels = append(labels, fmt.Sprintf("%q", f.fingerprint))
+		}
+		jql := fmt.Sprintf("project = %q AND labels in (%s) AND resolution = Unresolved", c.Project, strings.Join(labels, ","))
+
+		var result struct {
+			Issues []struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Labels []string `json:"labels"`
+				} `json:"fields"`
+			} `json:"issues"`
+		}
+
+		// Jira cloud replaced the search endpoint. Use the new endpoint if the old one is gone.
+		status, err := c.call("POST", "/rest/api/2/search", map[string]interface{}{"jql": jql, "fields": []string{"labels"}, "maxResults": 100}, &result)
+		if status == http.StatusGone || status == http.StatusNotFound {
+			_, err = c.call("GET", "/rest/api/3/search/jql?"+url.Values{"jql": {jql}, "fields": {"labels"}, "maxResults": {"100"}}.Encode(), nil, &result)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range result.Issues {
+			for _, l := range i.Fields.Labels {
+				existing[l] = i.Key
+			}
+		}
+	}
+	return existing, nil
+}
+
+// jiraFingerprint is a stable label for a finding
+func jiraFingerprint(key []string) string {
+	h := sha256.Sum256([]byte(strings.Join(key, "|")))
+	return "workloader-" + hex.EncodeToString(h[:6])
+}
+
+// jiraDescription creates a wiki markup table of the rows
+func jiraDescription(command string, headers []string, rows [][]string) string {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("workloader %s found the following on %s:\n\n", command, notifyPCE()))
+	b.WriteString("||" + strings.Join(headers, "||") + "||\n")
+	for _, row := range rows {
+		cells := []string{}
+		for _, r := range row {
+			if r == "" {
+				r = " "
+			}
+			cells = append(cells, escape.Replace(r))
+		}
+		b.WriteString("|" + strings.Join(cells, "|") + "|\n")
+	}
+	return b.String()
+}
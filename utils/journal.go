@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// JournalEntry is one change made to the PCE. Bulk requests are recorded as one entry per workload.
+// Before is the object before a PUT or DELETE. After is the request body of a PUT or the response of a POST.
+type JournalEntry struct {
+	RunID      string          `json:"run_id"`
+	Timestamp  string          `json:"timestamp"`
+	Command    string          `json:"command"`
+	PCE        string          `json:"pce"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	ObjectType string          `json:"object_type"`
+	Href       string          `json:"href,omitempty"`
+	StatusCode int             `json:"status_code"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+// JournalRun summarizes a journal file
+type JournalRun struct {
+	RunID   string
+	Command string
+	PCE     string
+	Started string
+	Changes int
+}
+
+// journal is the journal file for this run. It is created on the first write.
+var journal struct {
+	sync.Mutex
+	runID string
+	file  *os.File
+}
+
+// JournalEnabled returns true if writes should be recorded. Writes are recorded under --update-pce when journal is true in pce.yaml or WORKLOADER_JOURNAL is true.
+// Recording is opt-in because each update or delete first gets the object it changes. Simulated writes in an approval review are not recorded.
+func JournalEnabled() bool {
+	if !(strings.ToLower(os.Getenv("WORKLOADER_JOURNAL")) == "true" || viper.GetBool("journal")) || Simulating() {
+		return false
+	}
+	return viper.GetBool("update_pce")
+}
+
+// JournalDir returns the journal directory from WORKLOADER_JOURNAL_DIR, journal_dir in pce.yaml, or ~/.workloader/journal
+func JournalDir() string {
+	if d := os.Getenv("WORKLOADER_JOURNAL_DIR"); d != "" {
+		return d
+	}
+	if d := viper.GetString("journal_dir"); d != "" {
+		return d
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "workloader-journal"
+	}
+	return filepath.Join(home, ".workloader", "journal")
+}
+
+// JournalRunID returns the run id of the journal for this run or blank if nothing was written
+func JournalRunID() string {
+	journal.Lock()
+	defer journal.Unlock()
+	return journal.runID
+}
+
+// writeJournal appends entries to the journal for this run
+func writeJournal(entries []JournalEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	journal.Lock()
+	defer journal.Unlock()
+	if journal.file == nil {
+		b := make([]byte, 3)
+		rand.Read(b)
+		runID := fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(b))
+		if err := os.MkdirAll(JournalDir(), 0700); err != nil {
+			LogWarning(fmt.Sprintf("creating journal directory - %s. changes are not being recorded.", err), true)
+			return
+		}
+		f, err := os.OpenFile(filepath.Join(JournalDir(), runID+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			LogWarning(fmt.Sprintf("creating journal file - %s. changes are not being recorded.", err), true)
+			return
+		}
+		journal.runID, journal.file = runID, f
+		LogInfo(fmt.Sprintf("recording changes in journal %s", f.Name()), false)
+	}
+	for _, e := range entries {
+		e.RunID = journal.runID
+		e.Command = currentCommand
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		journal.file.Write(append(line, '\n'))
+	}
+}
+
+// ReadJournal reads the entries of a run. The run id can also be the path to a journal file.
+func ReadJournal(runID string) ([]JournalEntry, error) {
+	path := runID
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(JournalDir(), runID+".jsonl")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal for %s - %s", runID, err)
+	}
+	defer f.Close()
+	entries := []JournalEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("reading journal %s - %s", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// JournalRuns returns the runs in the journal directory with the newest first
+func JournalRuns() ([]JournalRun, error) {
+	files, err := filepath.Glob(filepath.Join(JournalDir(), "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	runs := []JournalRun{}
+	for _, file := range files {
+		entries, err := ReadJournal(file)
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+		runs = append(runs, JournalRun{RunID: strings.TrimSuffix(filepath.Base(file), ".jsonl"), Command: entries[0].Command, PCE: entries[0].PCE, Started: entries[0].Timestamp, Changes: len(entries)})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].RunID > runs[j].RunID })
+	return runs, nil
+}
+
+// journalHref returns the href for an api path by removing the /api/v2 prefix
+func journalHref(path string) string {
+	return strings.TrimPrefix(path, "/api/v2")
+}
+
+// journalObjectType returns the object type of an href (e.g., ip_lists for /orgs/1/sec_policy/draft/ip_lists/1)
+func journalObjectType(href string) string {
+	parts := strings.Split(strings.Trim(href, "/"), "/")
+	if len(parts) >= 2 {
+		return parts[len(parts)-2]
+	}
+	return parts[len(parts)-1]
+}
+
+// journalBulkAction returns the bulk action of a workload bulk request or blank
+func journalBulkAction(path string) string {
+	for _, a := range []string{"bulk_create", "bulk_update", "bulk_delete"} {
+		if strings.HasSuffix(path, "/workloads/"+a) {
+			return a
+		}
+	}
+	return ""
+}
+
+// journalHrefs returns the hrefs in a json array of objects
+func journalHrefs(body []byte) []string {
+	var objects []struct {
+		Href string `json:"href"`
+	}
+	json.Unmarshal(body, &objects)
+	hrefs := []string{}
+	for _, o := range objects {
+		if o.Href != "" {
+			hrefs = append(hrefs, o.Href)
+		}
+	}
+	return hrefs
+}
+
+// journalSecret returns true if the response of a path has credentials that should not be recorded (e.g., api keys and pairing keys)
+func journalSecret(path string) bool {
+	return strings.Contains(path, "/api_keys") || strings.HasSuffix(path, "/pairing_key")
+}
+
+// journalGet returns the current json of an href or nil if it cannot be retrieved
+func (f *pceForwarder) journalGet(r *http.Request, href string) json.RawMessage {
+	req, err := http.NewRequest("GET", "/api/v2"+href, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Length")
+	resp, err := f.do(req, nil)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || !json.Valid(data) {
+		return nil
+	}
+	return data
+}
+
+// journalBefore gets the objects a request will change or delete
+func (f *pceForwarder) journalBefore(r *http.Request, body []byte) map[string]json.RawMessage {
+	before := make(map[string]json.RawMessage)
+	hrefs := []string{}
+	switch {
+	case r.Method == "PUT" || r.Method == "DELETE":
+		hrefs = append(hrefs, journalHref(r.URL.Path))
+	case r.Method == "POST" && (journalBulkAction(r.URL.Path) == "bulk_update" || journalBulkAction(r.URL.Path) == "bulk_delete"):
+		hrefs = journalHrefs(body)
+	}
+	for _, h := range hrefs {
+		before[h] = f.journalGet(r, h)
+	}
+	return before
+}
+
+// journalEntries builds the journal entries for a successful write
+func (f *pceForwarder) journalEntries(r *http.Request, body []byte, before map[string]json.RawMessage, status int, respBody []byte) []JournalEntry {
+	if status < 200 || status > 299 {
+		return nil
+	}
+	now := time.Now().Format(time.RFC3339)
+	path := journalHref(r.URL.Path)
+	entry := func(method, href string, b, a json.RawMessage) JournalEntry {
+		objectType := journalObjectType(href)
+		if href == "" {
+			objectType = journalObjectType(path + "/x")
+		}
+		return JournalEntry{Timestamp: now, PCE: f.name, Method: method, Path: path, ObjectType: objectType, Href: href, StatusCode: status, Before: b, After: a}
+	}
+
+	entries := []JournalEntry{}
+	switch bulk := journalBulkAction(r.URL.Path); {
+	case bulk == "bulk_update":
+		var objects []json.RawMessage
+		json.Unmarshal(body, &objects)
+		for _, o := range objects {
+			if h := journalHrefs([]byte("[" + string(o) + "]")); len(h) == 1 {
+				entries = append(entries, entry("PUT", h[0], before[h[0]], o))
+			}
+		}
+	case bulk == "bulk_delete":
+		for _, h := range journalHrefs(body) {
+			entries = append(entries, entry("DELETE", h, before[h], nil))
+		}
+	case bulk == "bulk_create":
+		for _, h := range journalHrefs(respBody) {
+			entries = append(entries, entry("POST", h, nil, json.RawMessage(fmt.Sprintf(`{"href":%q}`, h))))
+		}
+	case r.Method == "PUT":
+		entries = append(entries, entry("PUT", path, before[path], json.RawMessage(body)))
+	case r.Method == "DELETE":
+		entries = append(entries, entry("DELETE", path, before[path], nil))
+	default:
+		var created struct {
+			Href string `json:"href"`
+		}
+		var after json.RawMessage
+		if json.Valid(respBody) {
+			json.Unmarshal(respBody, &created)
+			after = respBody
+			if journalSecret(path) {
+				after = nil
+				if created.Href != "" {
+					after = json.RawMessage(fmt.Sprintf(`{"href":%q}`, created.Href))
+				}
+			}
+		}
+		entries = append(entries, entry(r.Method, created.Href, nil, after))
+	}
+	for i := range entries {
+		if !json.Valid(entries[i].After) {
+			entries[i].After = nil
+		}
+	}
+	return entries
+}
+
+// PCERequest sends a request with a raw json body to an href in the PCE
+func PCERequest(pce illumioapi.PCE, method, href string, body []byte) (illumioapi.APIResponse, error) {
+	client, err := pceUpstreamClient(pce)
+	if err != nil {
+		return illumioapi.APIResponse{}, err
+	}
+	return pceLoginReq(client, method, fmt.Sprintf("https://%s:%d/api/v2%s", pce.FQDN, pce.Port, href), body, func(r *http.Request) { r.SetBasicAuth(pce.User, pce.Key) })
+}
@@ -0,0 +1,73 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+// TestJournalOptIn checks writes are only recorded when the journal is turned on
+func TestJournalOptIn(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+	if utils.JournalEnabled() {
+		t.Fatal("journal is on by default")
+	}
+	viper.Set("journal", true)
+	if !utils.JournalEnabled() {
+		t.Fatal("journal is off with journal set to true")
+	}
+	t.Setenv("WORKLOADER_JOURNAL", "true")
+	viper.Set("journal", false)
+	if !utils.JournalEnabled() {
+		t.Fatal("journal is off with WORKLOADER_JOURNAL set to true")
+	}
+}
+
+// TestJournalRedactsSecrets checks api key and pairing key responses are not recorded
+func TestJournalRedactsSecrets(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	viper.Set("update_pce", true)
+	viper.Set("simulate", false)
+	viper.Set("journal", true)
+
+	pce, err := utils.GetTargetPCE(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pce.Post("users/1/api_keys", map[string]string{"name": "rotated", "secret": "mocksecret"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pce.CreatePairingKey(illumioapi.PairingProfile{Href: "/orgs/1/pairing_profiles/1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pce.Post("labels", map[string]string{"key": "app", "value": "ORDERING"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := utils.ReadJournal(utils.JournalRunID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("journal has %d entries, want 3", len(entries))
+	}
+	for _, e := range entries[:2] {
+		if strings.Contains(string(e.After), "mocksecret") || strings.Contains(string(e.After), "mockactivationcode") {
+			t.Errorf("journal recorded the secret for %s - %s", e.Path, e.After)
+		}
+	}
+	if entries[0].Href == "" || string(entries[0].After) != `{"href":"`+entries[0].Href+`"}` {
+		t.Errorf("api key entry is %s, want only the href", entries[0].After)
+	}
+	if !strings.Contains(string(entries[2].After), "ORDERING") {
+		t.Errorf("label entry is %s, want the created label", entries[2].After)
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// keychainService is the service name for credentials in the OS credential store
+const keychainService = "workloader"
+
+// CredentialStoreKeychain is the <name>.credential_store value for PCEs with secrets in the OS credential store
+const CredentialStoreKeychain = "keychain"
+
+// KeychainSecrets are the pce.yaml keys that are stored in the OS credential store
+var KeychainSecrets = []string{"key", "client_secret", "token"}
+
+// keychainAccount is the account name for a PCE secret
+func keychainAccount(pceName, field string) string {
+	return fmt.Sprintf("%s.%s", pceName, field)
+}
+
+// UsesKeychain returns true if the PCE secrets are in the OS credential store
+func UsesKeychain(pceName string) bool {
+	return viper.GetString(pceName+".credential_store") == CredentialStoreKeychain
+}
+
+// KeychainGet gets a PCE secret from the OS credential store (macOS Keychain, Windows Credential Manager, or libsecret)
+func KeychainGet(pceName, field string) (string, error) {
+	secret, err := keychainGet(keychainService, keychainAccount(pceName, field))
+	if err != nil {
+		return "", fmt.Errorf("getting %s %s from the os credential store - %s", pceName, field, err)
+	}
+	return secret, nil
+}
+
+// KeychainSet stores a PCE secret in the OS credential store. An existing secret is replaced.
+func KeychainSet(pceName, field, secret string) error {
+	if err := keychainSet(keychainService, keychainAccount(pceName, field), secret); err != nil {
+		return fmt.Errorf("storing %s %s in the os credential store - %s", pceName, field, err)
+	}
+	return nil
+}
+
+// KeychainDelete removes a PCE secret from the OS credential store
+func KeychainDelete(pceName, field string) error {
+	return keychainDelete(keychainService, keychainAccount(pceName, field))
+}
+
+// keychainValue returns the decrypted pce.yaml value or, if it is blank and the PCE uses the OS credential store, the stored secret
+func keychainValue(pceName, field string) (string, error) {
+	value, err := DecryptSecret(viper.GetString(pceName + "." + field))
+	if err != nil || value != "" || !UsesKeychain(pceName) {
+		return value, err
+	}
+	return KeychainGet(pceName, field)
+}
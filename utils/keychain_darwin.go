@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainGet reads a generic password from the login keychain with the security command
+func keychainGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("security find-generic-password - %s", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// keychainSet adds or updates a generic password in the login keychain
+func keychainSet(service, account, secret string) error {
+	out, err := exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-l", "workloader "+account, "-w", secret).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("security add-generic-password - %s - %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keychainDelete removes a generic password from the login keychain
+func keychainDelete(service, account string) error {
+	out, err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("security delete-generic-password - %s - %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
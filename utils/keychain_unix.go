@@ -0,0 +1,41 @@
+//go:build !darwin && !windows
+
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainGet looks up a secret from the secret service (libsecret) with secret-tool
+func keychainGet(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		return "", fmt.Errorf("secret-tool lookup - %s", err)
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("secret not found")
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// keychainSet stores a secret in the secret service. The secret is passed on stdin.
+func keychainSet(service, account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=workloader "+account, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("secret-tool store - %s - %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keychainDelete removes a secret from the secret service
+func keychainDelete(service, account string) error {
+	out, err := exec.Command("secret-tool", "clear", "service", service, "account", account).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("secret-tool clear - %s - %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
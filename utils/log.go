@@ -3,8 +3,6 @@ package utils
 import (
 	"fmt"
 	"log"
-	"os"
-	"time"
 
 	"github.com/brian1917/illumioapi"
 	"github.com/spf13/viper"
@@ -15,50 +13,36 @@ var Logger log.Logger
 
 func init() {
 
-	f, err := os.OpenFile("workloader.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatal(err)
-	}
-	Logger.SetOutput(f)
+	// The log file is opened on the first entry so --log-file and the rotation settings are used
+	Logger.SetOutput(logOutput)
 
 }
 
-// LogError writes the error the workloader.log and always prints an error to stdout.
+// LogError writes the error the workloader.log and always prints an error to stdout. The exit code is classified from the message
+// and whether an api call failed. Use LogErrorCode when the type of error is known.
 func LogError(msg string) {
-	Logger.SetPrefix(time.Now().Format("2006-01-02 15:04:05 "))
-	fmt.Printf("%s [ERROR] - %s see workloader.log for detailed information if error is from an illumio api call.\r\n", time.Now().Format("2006-01-02 15:04:05 "), msg)
-	Logger.Fatalf("[ERROR] - %s\r\n", msg)
+	LogErrorFields(msg, nil)
+}
+
+// LogErrorCode logs an error like LogError and exits with the code (e.g., ExitValidation).
+func LogErrorCode(code int, msg string) {
+	logError(msg, nil, code)
 }
 
 // LogWarning writes the log to workloader.log and optionally prints msg to stdout.
 func LogWarning(msg string, stdout bool) {
-	Logger.SetPrefix(time.Now().Format("2006-01-02 15:04:05 "))
-	if stdout {
-		fmt.Printf("%s [WARNING] - %s\r\n", time.Now().Format("2006-01-02 15:04:05 "), msg)
-	}
-	Logger.Printf("[WARNING] - %s\r\n", msg)
+	LogWarningFields(msg, nil, stdout)
 }
 
 // LogInfo writes the log to workloader.log and never prints to stdout.
 func LogInfo(msg string, stdout bool) {
-	Logger.SetPrefix(time.Now().Format("2006-01-02 15:04:05 "))
-	if stdout {
-		fmt.Printf("%s [INFO] - %s\r\n", time.Now().Format("2006-01-02 15:04:05 "), msg)
-	}
-	Logger.Printf("[INFO] - %s\r\n", msg)
+	LogInfoFields(msg, nil, stdout)
 }
 
 // LogDebug writes the log to workloader.log only if debug flag is set and never prints to stdout.
 // Debug logic is not required in code.
 func LogDebug(msg string) {
-
-	// Get the debug value from viper
-	debug := viper.Get("debug").(bool)
-
-	if debug {
-		Logger.SetPrefix(time.Now().Format("2006-01-02 15:04:05 "))
-		Logger.Printf("[DEBUG] - %s\r\n", msg)
-	}
+	LogDebugFields(msg, nil)
 }
 
 // LogAPIResp will log the HTTP Requset, Request Header, Response Status Code, and Response Body
@@ -70,20 +54,25 @@ func LogAPIResp(callType string, apiResp illumioapi.APIResponse) {
 	// If we have a bad API response, set the debug to true
 	if apiResp.StatusCode > 299 {
 		viper.Set("debug", true)
+		result.apiError = true
 	}
 
+	fields := Fields{"call_type": callType, "status_code": apiResp.StatusCode}
 	if apiResp.Request != nil {
-		LogDebug(fmt.Sprintf("%s HTTP Request: %s %v", callType, apiResp.Request.Method, apiResp.Request.URL))
-		LogDebug(fmt.Sprintf("%s Request Body: %s", callType, apiResp.ReqBody))
+		fields["method"], fields["url"] = apiResp.Request.Method, apiResp.Request.URL.String()
+		LogDebugFields(fmt.Sprintf("%s HTTP Request: %s %v", callType, apiResp.Request.Method, apiResp.Request.URL), fields)
+		LogDebugFields(fmt.Sprintf("%s Request Body: %s", callType, apiResp.ReqBody), fields)
 	}
-	LogDebug(fmt.Sprintf("%s Response Status Code: %d", callType, apiResp.StatusCode))
+	LogDebugFields(fmt.Sprintf("%s Response Status Code: %d", callType, apiResp.StatusCode), fields)
 	if viper.Get("verbose").(bool) || apiResp.StatusCode > 299 {
-		LogDebug(fmt.Sprintf("%s Response Body: %s", callType, apiResp.RespBody))
+		LogDebugFields(fmt.Sprintf("%s Response Body: %s", callType, apiResp.RespBody), fields)
 	}
 
 	for _, w := range apiResp.Warnings {
 		LogWarning(w, true)
 	}
+
+	eventAPIResp(callType, apiResp)
 }
 
 func LogMultiAPIResp(APIResps map[string]illumioapi.APIResponse) {
@@ -94,8 +83,16 @@ func LogMultiAPIResp(APIResps map[string]illumioapi.APIResponse) {
 
 // LogStartCommand is used at the beginning of each command
 func LogStartCommand(commandName string) {
-	Logger.Println("-----------------------------------------------------------------------------")
+	currentCommand = commandName
+	if logFormat() == LogFormatText {
+		Logger.SetPrefix("")
+		Logger.Println("-----------------------------------------------------------------------------")
+	}
 	LogInfo(fmt.Sprintf("workloader version %s - started %s", GetVersion(), commandName), false)
+	StartResult(commandName)
+	notifyStart(commandName)
+	emailStart(commandName)
+	eventStart(commandName)
 	if viper.IsSet("target_pce") && viper.Get("target_pce") != nil && viper.Get("target_pce").(string) != "" {
 		LogInfo(fmt.Sprintf("using %s pce - %s", viper.Get("target_pce").(string), viper.Get(viper.Get("target_pce").(string)+".pce_version")), false)
 	} else {
@@ -107,7 +104,14 @@ func LogStartCommand(commandName string) {
 
 // LogEndCommand is used at the end of each command
 func LogEndCommand(commandName string) {
+	writePlannedChanges(commandName)
+	if runID := JournalRunID(); runID != "" {
+		LogInfo(fmt.Sprintf("changes recorded in journal %s. to reverse them, run workloader undo %s", runID, runID), true)
+	}
 	LogInfo(fmt.Sprintf("%s completed", commandName), true)
+	notifyEnd(commandName)
+	emailEnd(commandName)
+	eventEnd(commandName)
 }
 
 // Replaces a blank string with <empty>
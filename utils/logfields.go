@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Log formats for --log-format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Fields are contextual key/value pairs added to a log entry
+type Fields map[string]interface{}
+
+// currentCommand is the command name from LogStartCommand for structured log entries
+var currentCommand string
+
+// logFormat returns the log format. The --log-format flag takes precedence over WORKLOADER_LOG_FORMAT and log_format in pce.yaml.
+func logFormat() string {
+	format := viper.GetString("log_format_flag")
+	if format == "" {
+		format = os.Getenv("WORKLOADER_LOG_FORMAT")
+	}
+	if format == "" {
+		format = viper.GetString("log_format")
+	}
+	if strings.ToLower(format) == LogFormatJSON {
+		return LogFormatJSON
+	}
+	return LogFormatText
+}
+
+// logPCE returns the pce name for structured log entries
+func logPCE() string {
+	if viper.GetString("target_pce") != "" {
+		return viper.GetString("target_pce")
+	}
+	return viper.GetString("default_pce_name")
+}
+
+// formatLog returns a log entry. JSON entries include the timestamp, level, command, pce, message, and fields.
+// Text entries are the level and message with the fields appended as key=value pairs. The caller adds the timestamp to text entries.
+func formatLog(now time.Time, level, msg string, fields Fields) string {
+	if logFormat() == LogFormatJSON {
+		entry := make(map[string]interface{})
+		for k, v := range fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry[k] = v
+		}
+		entry["timestamp"] = now.Format(time.RFC3339)
+		entry["level"] = strings.ToLower(level)
+		entry["command"] = currentCommand
+		entry["pce"] = logPCE()
+		entry["message"] = msg
+		b, err := json.Marshal(entry)
+		if err != nil {
+			b, _ = json.Marshal(map[string]string{"timestamp": now.Format(time.RFC3339), "level": strings.ToLower(level), "message": msg, "log_error": err.Error()})
+		}
+		return string(b)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		msg = fmt.Sprintf("%s %s=%v", msg, k, fields[k])
+	}
+	return fmt.Sprintf("[%s] - %s", level, msg)
+}
+
+// writeLog writes an entry to workloader.log
+func writeLog(level, msg string, fields Fields) {
+	now := time.Now()
+	Logger.SetPrefix("")
+	if logFormat() == LogFormatText {
+		Logger.SetPrefix(now.Format("2006-01-02 15:04:05 "))
+	}
+	Logger.Print(formatLog(now, level, msg, fields) + "\r\n")
+}
+
+// printLog prints an entry to stdout
+func printLog(level, msg string, fields Fields) {
+	clearProgressLine()
+	now := time.Now()
+	if logFormat() == LogFormatText {
+		fmt.Printf("%s %s\r\n", now.Format("2006-01-02 15:04:05 "), formatLog(now, level, msg, fields))
+		return
+	}
+	fmt.Print(formatLog(now, level, msg, fields) + "\r\n")
+}
+
+// LogErrorFields logs an error with contextual fields and exits like LogError.
+func LogErrorFields(msg string, fields Fields) {
+	logError(msg, fields, 0)
+}
+
+// logError logs an error and exits with the code. A code of 0 is classified from the message and the api responses of the command.
+func logError(msg string, fields Fields, code int) {
+	stdoutMsg := msg
+	if logFormat() == LogFormatText {
+		stdoutMsg = msg + " see workloader.log for detailed information if error is from an illumio api call."
+	}
+	printLog("ERROR", stdoutMsg, fields)
+	notifyFailure(msg)
+	eventError(msg)
+	writeLog("ERROR", msg, fields)
+	exitError(msg, code)
+}
+
+// LogWarningFields logs a warning with contextual fields and optionally prints it to stdout.
+func LogWarningFields(msg string, fields Fields, stdout bool) {
+	if stdout {
+		printLog("WARNING", msg, fields)
+	}
+	notification.warnings++
+	eventWarning(msg)
+	writeLog("WARNING", msg, fields)
+}
+
+// LogInfoFields logs a message with contextual fields and optionally prints it to stdout.
+func LogInfoFields(msg string, fields Fields, stdout bool) {
+	if stdout {
+		printLog("INFO", msg, fields)
+		notifyMessage(msg)
+		eventMessage(msg)
+	}
+	writeLog("INFO", msg, fields)
+}
+
+// LogDebugFields logs a message with contextual fields only if the debug flag is set.
+func LogDebugFields(msg string, fields Fields) {
+	if viper.GetBool("debug") {
+		writeLog("DEBUG", msg, fields)
+	}
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultLogFile is the log file in the working directory when no log file is set
+const defaultLogFile = "workloader.log"
+
+// LogRotation is the rotation and retention settings for the log file. Zero values turn off each setting.
+type LogRotation struct {
+	MaxSize    int64         // Rotate when the log is larger than MaxSize bytes
+	Interval   time.Duration // Rotate on the first write in a new interval (e.g., 24h rotates daily at midnight UTC)
+	MaxBackups int           // Keep the newest MaxBackups rotated logs
+	MaxAge     time.Duration // Remove rotated logs older than MaxAge
+	Compress   bool          // Compress rotated logs with gzip
+}
+
+// rotatingLog is the output of Logger. The file is opened on the first write so the log settings from flags and pce.yaml are used.
+type rotatingLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	path     string
+	rotation LogRotation
+	size     int64
+	modified time.Time
+}
+
+// logOutput is the log file written by Logger
+var logOutput = &rotatingLog{}
+
+// LogFile returns the log file. The --log-file flag takes precedence over WORKLOADER_LOG_FILE, which takes precedence over
+// log_file in pce.yaml. The default is workloader.log in the working directory.
+func LogFile() string {
+	if viper.GetString("log_file_flag") != "" {
+		return viper.GetString("log_file_flag")
+	}
+	if os.Getenv("WORKLOADER_LOG_FILE") != "" {
+		return os.Getenv("WORKLOADER_LOG_FILE")
+	}
+	if viper.GetString("log_file") != "" {
+		return viper.GetString("log_file")
+	}
+	return defaultLogFile
+}
+
+// GetLogRotation returns the log rotation settings. The --log-max-size, --log-max-backups, and --log-max-age flags take precedence over
+// WORKLOADER_LOG_ environment variables, which take precedence over log_max_size (MB), log_rotate_interval, log_max_backups, log_max_age,
+// and log_compress in pce.yaml.
+func GetLogRotation() LogRotation {
+	r := LogRotation{
+		MaxSize:    int64(logSettingInt("log_max_size")) * 1024 * 1024,
+		Interval:   logSettingDuration("log_rotate_interval"),
+		MaxBackups: logSettingInt("log_max_backups"),
+		MaxAge:     logSettingDuration("log_max_age"),
+		Compress:   viper.GetBool("log_compress"),
+	}
+	if b, err := strconv.ParseBool(os.Getenv("WORKLOADER_LOG_COMPRESS")); err == nil {
+		r.Compress = b
+	}
+	return r
+}
+
+// logSettingInt returns an int log setting from the flag, environment variable, or pce.yaml
+func logSettingInt(key string) int {
+	if viper.GetInt(key+"_flag") > 0 {
+		return viper.GetInt(key + "_flag")
+	}
+	if v, err := strconv.Atoi(os.Getenv("WORKLOADER_" + strings.ToUpper(key))); err == nil {
+		return v
+	}
+	return viper.GetInt(key)
+}
+
+// logSettingDuration returns a duration log setting from the flag, environment variable, or pce.yaml
+func logSettingDuration(key string) time.Duration {
+	if viper.GetDuration(key+"_flag") > 0 {
+		return viper.GetDuration(key + "_flag")
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKLOADER_" + strings.ToUpper(key))); err == nil {
+		return d
+	}
+	return viper.GetDuration(key)
+}
+
+// ConfigureLog closes the log file so the next entry is written with the current log file and rotation settings.
+// It is called after the flags and config file are read.
+func ConfigureLog() {
+	logOutput.mu.Lock()
+	defer logOutput.mu.Unlock()
+	if logOutput.file != nil {
+		logOutput.file.Close()
+		logOutput.file = nil
+	}
+}
+
+// Write writes a log entry and rotates the log first when it is due
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return 0, err
+		}
+	}
+	now := time.Now()
+	if l.size > 0 && l.due(int64(len(p)), now) {
+		l.rotate(now)
+		if l.file == nil {
+			return 0, fmt.Errorf("log file is not open")
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	l.modified = now
+	return n, err
+}
+
+// open opens the log file for appending with the current settings
+func (l *rotatingLog) open() error {
+	l.path, l.rotation = LogFile(), GetLogRotation()
+	if dir := filepath.Dir(l.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "creating log directory %s - %s\n", dir, err)
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opening log file %s - %s\n", l.path, err)
+		return err
+	}
+	l.file, l.size, l.modified = f, 0, time.Now()
+	if info, err := f.Stat(); err == nil {
+		l.size = info.Size()
+		if l.size > 0 {
+			l.modified = info.ModTime()
+		}
+	}
+	return nil
+}
+
+// due returns true if writing n more bytes should rotate the log first
+func (l *rotatingLog) due(n int64, now time.Time) bool {
+	if l.rotation.MaxSize > 0 && l.size+n > l.rotation.MaxSize {
+		return true
+	}
+	return l.rotation.Interval > 0 && !l.modified.Truncate(l.rotation.Interval).Equal(now.Truncate(l.rotation.Interval))
+}
+
+// rotate renames the log with a timestamp, compresses it, removes old rotated logs, and opens a new log
+func (l *rotatingLog) rotate(now time.Time) {
+	l.file.Close()
+	l.file = nil
+	ext := filepath.Ext(l.path)
+	stem := strings.TrimSuffix(l.path, ext)
+	backup := fmt.Sprintf("%s-%s%s", stem, now.Format("20060102_150405"), ext)
+	for i := 1; fileExists(backup) || fileExists(backup+".gz"); i++ {
+		backup = fmt.Sprintf("%s-%s-%d%s", stem, now.Format("20060102_150405"), i, ext)
+	}
+	if err := os.Rename(l.path, backup); err != nil {
+		fmt.Fprintf(os.Stderr, "rotating log file %s - %s\n", l.path, err)
+	} else if l.rotation.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "compressing log file %s - %s\n", backup, err)
+		}
+	}
+	l.prune(now)
+	l.open()
+}
+
+// prune removes the rotated logs beyond the maximum backups or older than the maximum age
+func (l *rotatingLog) prune(now time.Time) {
+	if l.rotation.MaxBackups <= 0 && l.rotation.MaxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(l.path)
+	stem := filepath.Base(strings.TrimSuffix(l.path, ext))
+	rotated := regexp.MustCompile(`^` + regexp.QuoteMeta(stem) + `-\d{8}_\d{6}(-\d+)?` + regexp.QuoteMeta(ext) + `(\.gz)?$`)
+	entries, err := os.ReadDir(filepath.Dir(l.path))
+	if err != nil {
+		return
+	}
+	backups := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && rotated.MatchString(e.Name()) {
+			backups = append(backups, e.Name())
+		}
+	}
+	// Timestamps sort oldest first so the newest are at the end
+	sort.Strings(backups)
+	for i, b := range backups {
+		path := filepath.Join(filepath.Dir(l.path), b)
+		info, err := os.Stat(path)
+		tooMany := l.rotation.MaxBackups > 0 && len(backups)-i > l.rotation.MaxBackups
+		tooOld := l.rotation.MaxAge > 0 && err == nil && now.Sub(info.ModTime()) > l.rotation.MaxAge
+		if tooMany || tooOld {
+			os.Remove(path)
+		}
+	}
+}
+
+// gzipFile compresses a file to file.gz and removes the original
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		zw.Close()
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
+
+// fileExists returns true if a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
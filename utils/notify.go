@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// notification tracks the running command for the start, finish, and failure notifications
+var notification struct {
+	command  string
+	start    time.Time
+	warnings int
+	messages []string
+}
+
+// notifyWebhooks returns the Slack and Teams webhook urls. Environment variables (WORKLOADER_NOTIFY_SLACK_WEBHOOK and WORKLOADER_NOTIFY_TEAMS_WEBHOOK)
+// take precedence over the notify_slack_webhook and notify_teams_webhook keys in pce.yaml.
+func notifyWebhooks() (slack, teams string) {
+	values := []*string{&slack, &teams}
+	for i, key := range []string{"notify_slack_webhook", "notify_teams_webhook"} {
+		if env := os.Getenv("WORKLOADER_" + strings.ToUpper(key)); env != "" {
+			*values[i] = env
+		} else if viper.IsSet(key) {
+			*values[i] = viper.GetString(key)
+		}
+	}
+	return slack, teams
+}
+
+// notifyEnabled returns true if the --notify flag is set
+func notifyEnabled() bool {
+	return viper.GetBool("notify")
+}
+
+// notifyStart sends the start notification. Commands started by another command (e.g., wkld-import run by a sync command) are ignored.
+func notifyStart(command string) {
+	if !notifyEnabled() || notification.command != "" {
+		return
+	}
+	notification.command = command
+	notification.start = time.Now()
+	notification.warnings = 0
+	notification.messages = nil
+	sendNotification(fmt.Sprintf("workloader %s started on %s", command, notifyPCE()), "")
+}
+
+// notifyMessage keeps the last 10 messages printed to stdout for the finish and failure summaries. These include the counts most commands print.
+func notifyMessage(msg string) {
+	if !notifyEnabled() || notification.command == "" {
+		return
+	}
+	notification.messages = append(notification.messages, msg)
+	if len(notification.messages) > 10 {
+		notification.messages = notification.messages[1:]
+	}
+}
+
+// notifyEnd sends the finish notification
+func notifyEnd(command string) {
+	if !notifyEnabled() || notification.command != command {
+		return
+	}
+	sendNotification(fmt.Sprintf("workloader %s completed on %s in %s with %d warnings", command, notifyPCE(), time.Since(notification.start).Round(time.Second), notification.warnings), strings.Join(notification.messages, "\n"))
+	notification.command = ""
+}
+
+// notifyFailure sends the failure notification
+func notifyFailure(msg string) {
+	if !notifyEnabled() {
+		return
+	}
+	command := notification.command
+	if command == "" {
+		command = strings.Join(os.Args[1:2], "")
+	}
+	sendNotification(fmt.Sprintf("workloader %s failed on %s - %s", command, notifyPCE(), msg), strings.Join(notification.messages, "\n"))
+}
+
+// notifyPCE returns the name of the pce used by the command
+func notifyPCE() string {
+	if viper.GetString("target_pce") != "" {
+		return viper.GetString("target_pce")
+	}
+	if viper.GetString("default_pce_name") != "" {
+		return viper.GetString("default_pce_name")
+	}
+	return "pce"
+}
+
+// sendNotification posts the title and details to the configured Slack and Teams webhooks.
+// Failures are written to workloader.log only so a notification problem does not stop a command.
+func sendNotification(title, details string) {
+	slack, teams := notifyWebhooks()
+	if slack == "" && teams == "" {
+		Logger.Printf("[WARNING] - --notify is set but notify_slack_webhook and notify_teams_webhook are not configured\r\n")
+		return
+	}
+	text := title
+	if details != "" {
+		text = fmt.Sprintf("%s\n```\n%s\n```", title, details)
+	}
+	payloads := map[string]interface{}{}
+	if slack != "" {
+		payloads[slack] = map[string]string{"text": text}
+	}
+	if teams != "" {
+		payloads[teams] = map[string]string{"@type": "MessageCard", "@context": "http://schema.org/extensions", "summary": title, "title": title, "text": strings.ReplaceAll(details, "\n", "<br>")}
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	for url, payload := range payloads {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			Logger.Printf("[WARNING] - creating notification - %s\r\n", err)
+			continue
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			Logger.Printf("[WARNING] - sending notification - %s\r\n", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode > 299 {
+			Logger.Printf("[WARNING] - sending notification - status code %d\r\n", resp.StatusCode)
+		}
+	}
+}
@@ -3,64 +3,143 @@ package utils
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/viper"
 )
 
-// WriteOutput will write the CSV and/or stdout data based on the viper configuration
-func WriteOutput(csvData, stdOutData [][]string, csvFileName string) {
+// WriteOutput will write the CSV and/or stdout data based on the viper configuration. Use NewOutputStream for outputs too large to hold in memory.
+// It returns the file that was written after the output directory, template, format, and encryption are applied or blank if only stdout was written.
+func WriteOutput(csvData, stdOutData [][]string, csvFileName string) string {
 
 	// Get the output format
 	outFormat := viper.Get("output_format").(string)
+	csvFileName = OutputPath(csvFileName)
 
 	// Write stdout if output format dictates it
 	if outFormat == "stdout" || outFormat == "both" {
 		if len(stdOutData) < viper.Get("max_entries_for_stdout").(int) {
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader(stdOutData[0])
-			for i := 1; i <= len(stdOutData)-1; i++ {
-				table.Append(stdOutData[i])
-			}
-			table.SetAlignment(tablewriter.ALIGN_LEFT)
-			table.SetRowLine(true)
-			table.Render()
+			writeStdoutTable(stdOutData)
 		}
 	}
 
-	// Write CSV data if output format dictates it
+	// Write the output file in the --format if output format dictates it
 	if outFormat == "csv" || outFormat == "both" {
+		fileName := OutputFileName(csvFileName)
+		writeOutputFile(csvData, fileName)
+		return fileName
+	}
+	return ""
+}
 
-		// Create CSV
-		outFile, err := os.Create(csvFileName)
-		if err != nil {
-			LogError(fmt.Sprintf("creating csv - %s\n", err))
+// writeStdoutTable prints data with headers as a table
+func writeStdoutTable(data [][]string) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(data[0])
+	for i := 1; i <= len(data)-1; i++ {
+		table.Append(data[i])
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.Render()
+}
+
+// WriteFileOutput writes a file the command formatted itself (e.g., an html report) to the output directory or remote destination.
+// The --format is not applied. The file is encrypted with --encrypt-output and attached to --email-to like other output files.
+func WriteFileOutput(data []byte, fileName string) {
+	writeFile(encryptedOutputName(OutputPath(fileName)), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeOutputFile writes data to a file with the writer for the --format
+func writeOutputFile(data [][]string, fileName string) {
+	writeFile(fileName, func(w io.Writer) error { return outputWriters[OutputFormat()].Write(w, data) })
+}
+
+// writeFile creates an output file and writes it with write. Remote destinations are written locally first and then uploaded.
+func writeFile(fileName string, write func(w io.Writer) error) {
+	localFileName := fileName
+	if IsRemoteOutput(fileName) {
+		localFileName = localStagingFile(fileName)
+	}
+
+	// Create the file
+	outFile, err := os.Create(localFileName)
+	if err != nil {
+		LogError(fmt.Sprintf("creating %s - %s\n", fileName, err))
+	}
+
+	// Write the data, encrypting it if --encrypt-output is set
+	var w io.WriteCloser = outFile
+	if EncryptingOutput() {
+		if w, err = newOutputEncrypter(outFile, fileName); err != nil {
+			outFile.Close()
+			os.Remove(localFileName)
+			LogErrorCode(ExitValidation, fmt.Sprintf("encrypting %s - %s", fileName, err))
 		}
+	}
+	if err := write(w); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s\n", fileName, err))
+	}
+	if err := w.Close(); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", fileName, err))
+	}
+	outFile.Close()
 
-		// Write CSV data
-		writer := csv.NewWriter(outFile)
-		writer.WriteAll(csvData)
-		if err := writer.Error(); err != nil {
-			LogError(fmt.Sprintf("writing csv - %s\n", err))
+	// Upload to the remote destination
+	if IsRemoteOutput(fileName) {
+		defer os.Remove(localFileName)
+		if err := UploadOutput(localFileName, fileName); err != nil {
+			LogError(fmt.Sprintf("uploading output to %s - %s", fileName, err))
 		}
-		// Log
-		LogInfo(fmt.Sprintf("output file: %s", outFile.Name()), true)
+		LogInfo(fmt.Sprintf("output file: %s", fileName), true)
+		AddEmailAttachment(fileName)
+		return
 	}
+
+	// Log
+	LogInfo(fmt.Sprintf("output file: %s", outFile.Name()), true)
+	AddEmailAttachment(outFile.Name())
 }
 
-// WriteLineOutput will write the CSV one line at a time
+// lineStagingFile returns the local csv file that WriteLineOutput appends to. Formats other than csv are staged and converted by FinishLineOutput.
+func lineStagingFile(fileName string) string {
+	staging := fileName
+	if IsRemoteOutput(fileName) {
+		staging = localStagingFile(fileName)
+	}
+	if OutputFormat() != "csv" || EncryptingOutput() {
+		staging = stagingFile(staging)
+	}
+	return staging
+}
+
+// WriteLineOutput will write the CSV one line at a time. Remote destinations are written locally until FinishLineOutput is called.
 func WriteLineOutput(csvLine []string, csvFileName string) {
 
 	var outFile *os.File
+	fileName := OutputFileName(OutputPath(csvFileName))
+	staged := IsRemoteOutput(fileName) || OutputFormat() != "csv" || EncryptingOutput()
+	csvFileName = lineStagingFile(fileName)
 
 	// Create CSV if it doesn't exist
 	if _, err := os.Stat(csvFileName); err != nil {
-		outFile, err = os.Create(csvFileName)
+		if staged {
+			outFile, err = createStagingFile(csvFileName)
+		} else {
+			outFile, err = os.Create(csvFileName)
+		}
 		if err != nil {
 			LogError(fmt.Sprintf("creating csv - %s\n", err))
 		}
-		LogInfo(fmt.Sprintf("output file started: %s", outFile.Name()), true)
+		LogInfo(fmt.Sprintf("output file started: %s", fileName), true)
+		if !staged {
+			AddEmailAttachment(outFile.Name())
+		}
 
 	} else {
 		outFile, err = os.OpenFile(csvFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
@@ -79,3 +158,39 @@ func WriteLineOutput(csvLine []string, csvFileName string) {
 		LogError(fmt.Sprintf("error writing csv line - %s", err))
 	}
 }
+
+// FinishLineOutput converts the output written by WriteLineOutput to the --format and uploads it if the destination is remote
+func FinishLineOutput(csvFileName string) {
+	fileName := OutputFileName(OutputPath(csvFileName))
+	if !IsRemoteOutput(fileName) && OutputFormat() == "csv" && !EncryptingOutput() {
+		return
+	}
+	localFileName := lineStagingFile(fileName)
+	if _, err := os.Stat(localFileName); err != nil {
+		return
+	}
+	defer os.Remove(localFileName)
+
+	// Convert the staged csv to the --format and encrypt it
+	if OutputFormat() != "csv" || EncryptingOutput() {
+		f, err := os.Open(localFileName)
+		if err != nil {
+			LogError(fmt.Sprintf("opening %s - %s", localFileName, err))
+		}
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		data, err := reader.ReadAll()
+		f.Close()
+		if err != nil {
+			LogError(fmt.Sprintf("reading %s - %s", localFileName, err))
+		}
+		writeOutputFile(data, fileName)
+		return
+	}
+
+	if err := UploadOutput(localFileName, fileName); err != nil {
+		LogError(fmt.Sprintf("uploading output to %s - %s", fileName, err))
+	}
+	LogInfo(fmt.Sprintf("output file: %s", fileName), true)
+	AddEmailAttachment(fileName)
+}
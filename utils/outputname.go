@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultTimestampLayout is the timestamp in the default output file names
+const defaultTimestampLayout = "20060102_150405"
+
+// defaultOutputName matches the default output file names (e.g., workloader-wkld-export-20240101_120000.csv)
+var defaultOutputName = regexp.MustCompile(`^workloader-(.+)-(\d{8}_\d{6})(\.[A-Za-z0-9]+)$`)
+
+// OutputTemplate returns the output file name template. The --output-template flag takes precedence over output_template in pce.yaml.
+// A blank template keeps the default names.
+func OutputTemplate() string {
+	if viper.GetString("output_template_flag") != "" {
+		return viper.GetString("output_template_flag")
+	}
+	return viper.GetString("output_template")
+}
+
+// OutputTimestampFormat returns the Go time layout for {timestamp} in output file name templates from output_timestamp_format in pce.yaml.
+// The default is 20060102_150405.
+func OutputTimestampFormat() string {
+	if viper.GetString("output_timestamp_format") != "" {
+		return viper.GetString("output_timestamp_format")
+	}
+	return defaultTimestampLayout
+}
+
+// OutputDir returns the directory for output files without a path. The --output-dir flag takes precedence over output_dir in pce.yaml.
+func OutputDir() string {
+	if viper.GetString("output_dir_flag") != "" {
+		return viper.GetString("output_dir_flag")
+	}
+	return viper.GetString("output_dir")
+}
+
+// templateOutputName applies the output file name template to a default output file name. Other names, such as names from
+// --output-file, are returned unchanged. Tokens are {command}, {detail} (the rest of the default name after the command, such
+// as an ip list name), {name} (the command and detail), {pce}, {org}, {timestamp}, {date}, and {ext}. When the template does not
+// use {detail} or {name}, the detail is added before the extension so commands that write more than one file do not overwrite them.
+func templateOutputName(fileName string) string {
+	template := OutputTemplate()
+	m := defaultOutputName.FindStringSubmatch(fileName)
+	if template == "" || m == nil {
+		return fileName
+	}
+	name, ext := m[1], m[3]
+	ts, err := time.ParseInLocation(defaultTimestampLayout, m[2], time.Local)
+	if err != nil {
+		return fileName
+	}
+
+	// Split the default name into the command and the detail
+	command, detail := name, ""
+	if currentCommand != "" && strings.HasPrefix(name, currentCommand+"-") {
+		command, detail = currentCommand, strings.TrimPrefix(name, currentCommand+"-")
+	}
+	pce := viper.GetString("target_pce")
+	if pce == "" {
+		pce = DefaultPCEName()
+	}
+
+	r := strings.NewReplacer(
+		"{command}", outputNameToken(command),
+		"{detail}", outputNameToken(detail),
+		"{name}", outputNameToken(name),
+		"{pce}", outputNameToken(pce),
+		"{org}", outputNameToken(GetTargetOrg()),
+		"{timestamp}", outputNameToken(ts.Format(OutputTimestampFormat())),
+		"{date}", ts.Format("2006-01-02"),
+		"{ext}", strings.TrimPrefix(ext, "."),
+	)
+	templated := r.Replace(template)
+	if filepath.Ext(template) == "" {
+		templated = templated + ext
+	}
+	if detail != "" && !strings.Contains(template, "{detail}") && !strings.Contains(template, "{name}") {
+		templated = strings.TrimSuffix(templated, filepath.Ext(templated)) + "-" + outputNameToken(detail) + filepath.Ext(templated)
+	}
+	return templated
+}
+
+// outputNameToken replaces characters that are not valid in file names so a token value cannot add directories
+func outputNameToken(value string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_", string(os.PathSeparator), "_").Replace(value)
+}
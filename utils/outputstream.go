@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Streamed rows are flushed to the file every streamFlushRows rows or streamFlushInterval, whichever comes first
+const (
+	streamFlushRows     = 1000
+	streamFlushInterval = 5 * time.Second
+)
+
+// RowWriter writes output rows one at a time
+type RowWriter interface {
+	WriteRow(row []string) error
+	Flush() error
+	Close() error
+}
+
+// StreamingOutputWriter is an OutputWriter that can write rows as they are produced. Formats without a streaming writer are staged as csv
+// and converted when the stream is closed.
+type StreamingOutputWriter interface {
+	OutputWriter
+	NewRowWriter(w io.Writer, headers []string) (RowWriter, error)
+}
+
+// OutputStream writes output rows as they are produced so commands with very large outputs do not hold every row in memory.
+// The file is created on the first row. Rows for stdout are kept only while they are under max_entries_for_stdout.
+type OutputStream struct {
+	fileName  string
+	localName string
+	headers   []string
+	staged    bool
+	file      *os.File
+	enc       io.WriteCloser
+	buf       *bufio.Writer
+	rows      RowWriter
+	toFile    bool
+	stdout    [][]string
+	count     int
+	lastFlush time.Time
+	closed    bool
+}
+
+// NewOutputStream returns a stream for an output file. The name is the same as the name passed to WriteOutput.
+func NewOutputStream(csvFileName string, headers []string) *OutputStream {
+	outFormat := viper.GetString("output_format")
+	s := &OutputStream{fileName: OutputFileName(OutputPath(csvFileName)), headers: headers, toFile: outFormat == "csv" || outFormat == "both"}
+	if outFormat == "stdout" || outFormat == "both" {
+		s.stdout = [][]string{headers}
+	}
+	s.localName = s.fileName
+	if IsRemoteOutput(s.fileName) {
+		s.localName = localStagingFile(s.fileName)
+	}
+	if _, ok := outputWriters[OutputFormat()].(StreamingOutputWriter); !ok {
+		s.staged = true
+		s.localName = stagingFile(s.localName)
+	}
+	return s
+}
+
+// Rows returns the number of rows written
+func (s *OutputStream) Rows() int {
+	return s.count
+}
+
+// FileName returns the file the stream writes after the output directory, template, format, and encryption are applied or blank if only stdout is written
+func (s *OutputStream) FileName() string {
+	if !s.toFile {
+		return ""
+	}
+	return s.fileName
+}
+
+// Write writes a row. Errors are logged as fatal like WriteOutput.
+func (s *OutputStream) Write(row []string) {
+	if s.closed {
+		return
+	}
+	s.count++
+	if s.stdout != nil {
+		if len(s.stdout)+1 < viper.GetInt("max_entries_for_stdout") {
+			s.stdout = append(s.stdout, row)
+		} else {
+			s.stdout = nil
+		}
+	}
+	if !s.toFile {
+		return
+	}
+	if s.file == nil {
+		s.open()
+	}
+	if err := s.rows.WriteRow(row); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	if s.count%streamFlushRows == 0 || time.Since(s.lastFlush) > streamFlushInterval {
+		s.flush()
+	}
+}
+
+// open creates the file and writes the headers. Rows are encrypted as they are written if --encrypt-output is set and the file is not staged.
+func (s *OutputStream) open() {
+	var err error
+	if s.staged {
+		s.file, err = createStagingFile(s.localName)
+	} else {
+		s.file, err = os.Create(s.localName)
+	}
+	if err != nil {
+		LogError(fmt.Sprintf("creating %s - %s", OutputFormat(), err))
+	}
+	var w io.Writer = s.file
+	writer := StreamingOutputWriter(csvOutputWriter{})
+	if !s.staged {
+		writer = outputWriters[OutputFormat()].(StreamingOutputWriter)
+		if EncryptingOutput() {
+			if s.enc, err = newOutputEncrypter(s.file, s.fileName); err != nil {
+				s.file.Close()
+				os.Remove(s.localName)
+				LogErrorCode(ExitValidation, fmt.Sprintf("encrypting %s - %s", s.fileName, err))
+			}
+			w = s.enc
+		}
+	}
+	s.buf = bufio.NewWriter(w)
+	if s.rows, err = writer.NewRowWriter(s.buf, s.headers); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	s.lastFlush = time.Now()
+	LogInfo(fmt.Sprintf("output file started: %s", s.fileName), true)
+}
+
+// flush writes the buffered rows to the file
+func (s *OutputStream) flush() {
+	if err := s.rows.Flush(); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	if err := s.buf.Flush(); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	s.lastFlush = time.Now()
+}
+
+// Close finishes the file, converts staged output to the --format, uploads remote output, and prints the stdout table.
+// A file with only the headers is written when there are no rows, the same as WriteOutput.
+func (s *OutputStream) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.stdout != nil {
+		writeStdoutTable(s.stdout)
+	}
+	if !s.toFile {
+		return
+	}
+	if s.file == nil {
+		s.open()
+	}
+	if err := s.rows.Close(); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	if err := s.buf.Flush(); err != nil {
+		LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+	}
+	if s.enc != nil {
+		if err := s.enc.Close(); err != nil {
+			LogError(fmt.Sprintf("writing %s - %s", s.localName, err))
+		}
+	}
+	s.file.Close()
+
+	// Convert the staged csv to the --format
+	if s.staged {
+		defer os.Remove(s.localName)
+		f, err := os.Open(s.localName)
+		if err != nil {
+			LogError(fmt.Sprintf("opening %s - %s", s.localName, err))
+		}
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		data, err := reader.ReadAll()
+		f.Close()
+		if err != nil {
+			LogError(fmt.Sprintf("reading %s - %s", s.localName, err))
+		}
+		writeOutputFile(data, s.fileName)
+		return
+	}
+
+	if IsRemoteOutput(s.fileName) {
+		defer os.Remove(s.localName)
+		if err := UploadOutput(s.localName, s.fileName); err != nil {
+			LogError(fmt.Sprintf("uploading output to %s - %s", s.fileName, err))
+		}
+	}
+	LogInfo(fmt.Sprintf("output file: %s", s.fileName), true)
+	AddEmailAttachment(s.fileName)
+}
+
+// Discard stops the stream without writing a file for a stream with no rows
+func (s *OutputStream) Discard() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.localName)
+	}
+}
+
+// csvRowWriter writes csv rows
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (csvOutputWriter) NewRowWriter(w io.Writer, headers []string) (RowWriter, error) {
+	r := csvRowWriter{writer: csv.NewWriter(w)}
+	return r, r.WriteRow(headers)
+}
+
+func (r csvRowWriter) WriteRow(row []string) error {
+	return r.writer.Write(row)
+}
+
+func (r csvRowWriter) Flush() error {
+	r.writer.Flush()
+	return r.writer.Error()
+}
+
+func (r csvRowWriter) Close() error {
+	return r.Flush()
+}
+
+// jsonRowWriter writes rows as json objects. The objects are written as one array or, for jsonl, one object per line.
+type jsonRowWriter struct {
+	w       io.Writer
+	headers []string
+	lines   bool
+	count   int
+}
+
+func (jsonOutputWriter) NewRowWriter(w io.Writer, headers []string) (RowWriter, error) {
+	return &jsonRowWriter{w: w, headers: headers}, nil
+}
+
+func (jsonlOutputWriter) NewRowWriter(w io.Writer, headers []string) (RowWriter, error) {
+	return &jsonRowWriter{w: w, headers: headers, lines: true}, nil
+}
+
+func (r *jsonRowWriter) WriteRow(row []string) error {
+	obj, err := outputRow{headers: r.headers, values: row}.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if r.lines {
+		_, err = fmt.Fprintf(r.w, "%s\n", obj)
+		return err
+	}
+	sep := ",\n  "
+	if r.count == 0 {
+		sep = "[\n  "
+	}
+	r.count++
+	_, err = fmt.Fprintf(r.w, "%s%s", sep, obj)
+	return err
+}
+
+func (r *jsonRowWriter) Flush() error {
+	return nil
+}
+
+func (r *jsonRowWriter) Close() error {
+	if r.lines {
+		return nil
+	}
+	if r.count == 0 {
+		_, err := io.WriteString(r.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(r.w, "\n]\n")
+	return err
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputWriter writes tabular output to a file. The first row of data is the headers.
+type OutputWriter interface {
+	Write(w io.Writer, data [][]string) error
+	Extension() string
+}
+
+// outputWriters are the writers for the --format flag
+var outputWriters = map[string]OutputWriter{
+	"csv":   csvOutputWriter{},
+	"json":  jsonOutputWriter{},
+	"jsonl": jsonlOutputWriter{},
+	"yaml":  yamlOutputWriter{},
+	"xlsx":  xlsxOutputWriter{},
+	"html":  htmlOutputWriter{},
+}
+
+// RegisterOutputWriter adds or replaces the writer for a format
+func RegisterOutputWriter(format string, w OutputWriter) {
+	outputWriters[strings.ToLower(format)] = w
+}
+
+// OutputFormats returns the formats supported by --format
+func OutputFormats() []string {
+	formats := []string{}
+	for f := range outputWriters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ValidOutputFormat returns true if a writer is registered for the format
+func ValidOutputFormat(format string) bool {
+	_, ok := outputWriters[strings.ToLower(format)]
+	return ok
+}
+
+// OutputFormat returns the file format from the --format flag. The default is csv.
+func OutputFormat() string {
+	format := strings.ToLower(viper.GetString("file_format"))
+	if _, ok := outputWriters[format]; !ok {
+		return "csv"
+	}
+	return format
+}
+
+// OutputFileName replaces the .csv extension of an output file with the extension of the file format and adds .gpg when output is encrypted
+func OutputFileName(csvFileName string) string {
+	ext := outputWriters[OutputFormat()].Extension()
+	if strings.EqualFold(filepath.Ext(csvFileName), ".csv") {
+		return encryptedOutputName(strings.TrimSuffix(csvFileName, filepath.Ext(csvFileName)) + ext)
+	}
+	return encryptedOutputName(csvFileName)
+}
+
+// outputRow is a row with the headers as keys in column order
+type outputRow struct {
+	headers []string
+	values  []string
+}
+
+// outputRows converts data with headers into rows
+func outputRows(data [][]string) []outputRow {
+	if len(data) == 0 {
+		return nil
+	}
+	rows := []outputRow{}
+	for _, d := range data[1:] {
+		rows = append(rows, outputRow{headers: data[0], values: d})
+	}
+	return rows
+}
+
+// MarshalJSON writes the row as an object with the keys in column order
+func (r outputRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, h := range r.headers {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(jsonString(h))
+		buf.WriteString(":")
+		buf.Write(jsonString(r.value(i)))
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// jsonString encodes a string without escaping html characters
+func jsonString(s string) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// MarshalYAML writes the row as a mapping with the keys in column order
+func (r outputRow) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i, h := range r.headers {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: h}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: r.value(i)})
+	}
+	return node, nil
+}
+
+// value returns the value for a column or blank if the row is short
+func (r outputRow) value(i int) string {
+	if i < len(r.values) {
+		return r.values[i]
+	}
+	return ""
+}
+
+type csvOutputWriter struct{}
+
+func (csvOutputWriter) Extension() string { return ".csv" }
+
+func (csvOutputWriter) Write(w io.Writer, data [][]string) error {
+	writer := csv.NewWriter(w)
+	writer.WriteAll(data)
+	return writer.Error()
+}
+
+type jsonOutputWriter struct{}
+
+func (jsonOutputWriter) Extension() string { return ".json" }
+
+func (jsonOutputWriter) Write(w io.Writer, data [][]string) error {
+	rows := outputRows(data)
+	if rows == nil {
+		rows = []outputRow{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+type jsonlOutputWriter struct{}
+
+func (jsonlOutputWriter) Extension() string { return ".jsonl" }
+
+func (jsonlOutputWriter) Write(w io.Writer, data [][]string) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, r := range outputRows(data) {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type yamlOutputWriter struct{}
+
+func (yamlOutputWriter) Extension() string { return ".yaml" }
+
+func (yamlOutputWriter) Write(w io.Writer, data [][]string) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(outputRows(data)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// xlsxOutputWriter writes a workbook with one worksheet. Cells are written as inline strings.
+type xlsxOutputWriter struct{}
+
+func (xlsxOutputWriter) Extension() string { return ".xlsx" }
+
+func (xlsxOutputWriter) Write(w io.Writer, data [][]string) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range data {
+		fmt.Fprintf(&sheet, `<row r="%d">`, r+1)
+		for c, v := range row {
+			fmt.Fprintf(&sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumn(c), r+1)
+			xml.EscapeText(&sheet, []byte(v))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="workloader" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// htmlOutputWriter writes a standalone page with one table for reports that are shared or kept as evidence. Cells with pass or fail are highlighted.
+type htmlOutputWriter struct{}
+
+func (htmlOutputWriter) Extension() string { return ".html" }
+
+func (htmlOutputWriter) Write(w io.Writer, data [][]string) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>workloader</title>
+<style>body{font-family:sans-serif;font-size:13px}table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}th{background:#eee}.pass{background:#dff0d8}.fail{background:#f2dede}</style>
+</head><body>
+<table>
+`)
+	for r, row := range data {
+		buf.WriteString("<tr>")
+		for _, v := range row {
+			cell := "td"
+			if r == 0 {
+				cell = "th"
+			}
+			class := ""
+			if r > 0 && (strings.EqualFold(v, "pass") || strings.EqualFold(v, "fail")) {
+				class = fmt.Sprintf(` class="%s"`, strings.ToLower(v))
+			}
+			fmt.Fprintf(&buf, "<%s%s>%s</%s>", cell, class, html.EscapeString(v), cell)
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n</body></html>\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// xlsxColumn returns the column letters for a zero-based column index (0 is A, 26 is AA)
+func xlsxColumn(i int) string {
+	col := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		col = string(rune('A'+(i-1)%26)) + col
+	}
+	return col
+}
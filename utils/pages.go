@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// defaultPageWorkers is the number of concurrent requests when page workers are not configured
+const defaultPageWorkers = 4
+
+// maxPageRetries is how many times a throttled page is retried when the retry forwarder is not enabled
+const maxPageRetries = 3
+
+// PageWorkers returns the number of concurrent page requests. The --page-workers flag takes precedence over
+// WORKLOADER_PAGE_WORKERS, which takes precedence over page_workers in pce.yaml. The default is 4.
+func PageWorkers() int {
+	workers := viper.GetInt("page_workers")
+	if v, err := strconv.Atoi(os.Getenv("WORKLOADER_PAGE_WORKERS")); err == nil {
+		workers = v
+	}
+	if viper.GetInt("page_workers_flag") > 0 {
+		workers = viper.GetInt("page_workers_flag")
+	}
+	if workers <= 0 {
+		return defaultPageWorkers
+	}
+	return workers
+}
+
+// GetPages calls get for pages 0 to n-1 with up to PageWorkers concurrent requests and returns the results in page order.
+// All workers pause when a page is throttled (429 or 503). Throttled pages are retried here only when the retry
+// forwarder is not enabled because the forwarder already retries them and spaces requests for --rps.
+// No new pages start after a page fails and the error of the first failed page is returned.
+func GetPages[T any](n int, get func(i int) (T, illumioapi.APIResponse, error)) ([]T, error) {
+	results := make([]T, n)
+	errs := make([]error, n)
+	limiter := newRateLimiter(0)
+	retry := !GetAPIRetryConfig().Enabled()
+
+	workers := PageWorkers()
+	if workers > n {
+		workers = n
+	}
+	pages := make(chan int)
+	var failed bool
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pages {
+				for attempt := 0; ; attempt++ {
+					limiter.wait()
+					result, api, err := get(i)
+					if api.StatusCode == http.StatusTooManyRequests || api.StatusCode == http.StatusServiceUnavailable {
+						limiter.throttled(retryWait(api.Header, attempt))
+						if retry && attempt < maxPageRetries {
+							LogDebug(fmt.Sprintf("page %d of %d throttled with status %d. retrying.", i+1, n, api.StatusCode))
+							continue
+						}
+					}
+					results[i], errs[i] = result, err
+					if err != nil {
+						mu.Lock()
+						failed = true
+						mu.Unlock()
+					}
+					break
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			break
+		}
+		pages <- i
+	}
+	close(pages)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return results, fmt.Errorf("page %d of %d - %w", i+1, n, err)
+		}
+	}
+	return results, nil
+}
+
+// MergePages flattens pages of slices into one slice in page order
+func MergePages[T any](pages [][]T) []T {
+	merged := []T{}
+	for _, p := range pages {
+		merged = append(merged, p...)
+	}
+	return merged
+}
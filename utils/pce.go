@@ -16,14 +16,14 @@ func GetTargetPCE(GetLabelMaps bool) (illumioapi.PCE, error) {
 	var name string
 	if viper.Get("target_pce") != nil && viper.Get("target_pce").(string) != "" {
 		name = viper.Get("target_pce").(string)
-	} else if viper.Get("default_pce_name") != nil && viper.Get("default_pce_name").(string) != "" {
-		name = viper.Get("default_pce_name").(string)
+	} else if DefaultPCEName() != "" {
+		name = DefaultPCEName()
 	} else {
-		LogError("there is no pce set using the --pce flag and there is no default pce. either run workloader pce-add to add your first pce or workloader set-default to set an existing PCE as default.")
+		LogErrorCode(ExitConfig, "there is no pce set using the --pce flag and there is no default pce. either run workloader pce-add to add your first pce, workloader set-default to set an existing PCE as default, or set the WORKLOADER_FQDN, WORKLOADER_KEY, and WORKLOADER_SECRET environment variables.")
 	}
 
-	// Get the PCE
-	pce, err := GetPCEbyName(name, GetLabelMaps)
+	// Get the PCE in the org from the --org flag and the supercluster member from the --member flag
+	pce, err := getPCE(name, GetTargetOrg(), GetTargetMember(), GetLabelMaps)
 	if err != nil {
 		return illumioapi.PCE{}, err
 	}
@@ -54,34 +54,146 @@ func GetTargetPCE(GetLabelMaps bool) (illumioapi.PCE, error) {
 		}
 	}
 
+	// Check the api user can make the changes before the command starts
+	checkRequiredCapabilities(pce)
+
 	return pce, nil
 }
 
 // GetPCEbyName gets a PCE by it's provided name
 func GetPCEbyName(name string, GetLabelMaps bool) (illumioapi.PCE, error) {
+	return getPCE(name, "", "", GetLabelMaps)
+}
+
+// getPCE gets a PCE by name. If org is not blank, the PCE uses that org from the entry's orgs list.
+// If member is not blank, reads go to that supercluster member. PCEs defined by environment variables take precedence over pce.yaml.
+func getPCE(name, org, member string, GetLabelMaps bool) (illumioapi.PCE, error) {
 	var pce illumioapi.PCE
-	if viper.IsSet(name + ".fqdn") {
+	var auth PCEAuth
+	var err error
+	if IsEnvPCE(name) {
+		if pce, auth, err = GetEnvPCE(name); err != nil {
+			return illumioapi.PCE{}, err
+		}
+		if org != "" {
+			if pce.Org, err = strconv.Atoi(org); err != nil {
+				return illumioapi.PCE{}, fmt.Errorf("%s is not a valid org for %s", org, name)
+			}
+		}
+	} else if viper.IsSet(name + ".fqdn") {
 		pce = illumioapi.PCE{FriendlyName: name, FQDN: viper.Get(name + ".fqdn").(string), Port: viper.Get(name + ".port").(int), Org: viper.Get(name + ".org").(int), User: viper.Get(name + ".user").(string), Key: viper.Get(name + ".key").(string), DisableTLSChecking: viper.Get(name + ".disableTLSChecking").(bool)}
 		if viper.Get(name+".proxy") != nil {
 			pce.Proxy = viper.Get(name + ".proxy").(string)
 		}
-		if GetLabelMaps {
-			apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true})
-			LogMultiAPIResp(apiResps)
+		key, err := DecryptSecret(pce.Key)
+		if err != nil {
+			return illumioapi.PCE{}, err
+		}
+		pce.Key = key
+		if pce.Key == "" && UsesKeychain(name) {
+			key, err := KeychainGet(name, "key")
 			if err != nil {
-				LogError(err.Error())
+				return illumioapi.PCE{}, err
+			}
+			pce.Key = key
+		}
+		if org != "" {
+			if err := applyPCEOrg(&pce, name, org); err != nil {
+				return illumioapi.PCE{}, err
+			}
+		}
+		auth = GetPCEAuth(name)
+	} else {
+		return illumioapi.PCE{}, fmt.Errorf("could not retrieve %s PCE information", name)
+	}
+
+	// Start the forwarder for token authentication, retries, timeouts, and custom CA or client certificates
+	fqdn, port := pce.FQDN, pce.Port
+	retry := GetAPIRetryConfig()
+	var f *pceForwarder
+	if auth.Type != PCEAuthAPIKey {
+		if f, err = startAuthForwarder(&pce, auth); err != nil {
+			return illumioapi.PCE{}, err
+		}
+	} else if retry.Enabled() || GetPCETLS(name).Enabled() || GetPCETimeouts(name).Enabled() {
+		if f, err = startRetryForwarder(&pce, retry); err != nil {
+			return illumioapi.PCE{}, err
+		}
+	}
+
+	// Send writes to the supercluster leader and reads to the selected member. Set supercluster to false in pce.yaml to skip detection.
+	if !viper.IsSet(name+".supercluster") || viper.GetBool(name+".supercluster") {
+		read, leader, err := superclusterRoute(pce, fqdn, member)
+		if err != nil {
+			return illumioapi.PCE{}, err
+		}
+		if leader != "" && (leader != fqdn || read != fqdn) {
+			if f == nil {
+				if f, err = startRetryForwarder(&pce, retry); err != nil {
+					return illumioapi.PCE{}, err
+				}
+			}
+			f.upstream = fmt.Sprintf("https://%s:%d", read, port)
+			f.leader = fmt.Sprintf("https://%s:%d", leader, port)
+			LogInfo(fmt.Sprintf("%s - supercluster reads from %s and writes to leader %s", name, read, leader), false)
+		}
+	}
+
+	// Refuse requests that change the PCE in read-only mode
+	if ReadOnly() {
+		if f == nil {
+			if f, err = startRetryForwarder(&pce, retry); err != nil {
+				return illumioapi.PCE{}, err
+			}
+		}
+		f.readOnly = true
+	}
+
+	// Simulate requests that change the PCE without --update-pce
+	if Simulating() {
+		if f == nil {
+			if f, err = startRetryForwarder(&pce, retry); err != nil {
+				return illumioapi.PCE{}, err
+			}
+		}
+		f.simulate = true
+	}
+
+	// Record changes in the run journal under --update-pce
+	if JournalEnabled() {
+		if f == nil {
+			if f, err = startRetryForwarder(&pce, retry); err != nil {
+				return illumioapi.PCE{}, err
 			}
 		}
-		_, api, err := pce.GetVersion()
+		f.journal = true
+	}
+
+	// Cache GET responses when a cache ttl is set
+	if ttl := APICacheTTL(); ttl > 0 {
+		if f == nil {
+			if f, err = startRetryForwarder(&pce, retry); err != nil {
+				return illumioapi.PCE{}, err
+			}
+		}
+		f.cache = newAPICache(pce.FriendlyName, ttl)
+	}
+	if GetLabelMaps {
+		apiResps, err := pce.Load(illumioapi.LoadInput{Labels: true})
+		LogMultiAPIResp(apiResps)
 		if err != nil {
-			return illumioapi.PCE{}, fmt.Errorf("error getting pce version - %s - %s - %d", err, api.RespBody, api.StatusCode)
+			LogError(err.Error())
 		}
+	}
+	_, api, err := pce.GetVersion()
+	if err != nil {
+		return illumioapi.PCE{}, fmt.Errorf("error getting pce version - %s - %s - %d", err, api.RespBody, api.StatusCode)
+	}
+	if !IsEnvPCE(name) {
 		viper.Set(name+".pce_version", fmt.Sprintf("%d.%d.%d-%d", pce.Version.Major, pce.Version.Minor, pce.Version.Patch, pce.Version.Build))
-		if err := viper.WriteConfig(); err != nil {
+		if err := WriteConfig(); err != nil {
 			LogError(err.Error())
 		}
-		return pce, nil
 	}
-
-	return illumioapi.PCE{}, fmt.Errorf("could not retrieve %s PCE information", name)
+	return pce, nil
 }
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// PCE authentication types
+const (
+	PCEAuthAPIKey = "api_key"
+	PCEAuthOAuth2 = "oauth2"
+	PCEAuthToken  = "token"
+)
+
+// PCEAuth holds the settings for PCEs that authenticate with an OAuth2 client credentials grant or a service account bearer token instead of an api key
+type PCEAuth struct {
+	Type         string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	Token        string
+}
+
+// GetPCEAuth returns the authentication settings for a PCE from the <name>.auth_type, <name>.token_url, <name>.client_id, <name>.client_secret, <name>.scope, and <name>.token keys in pce.yaml.
+// The WORKLOADER_CLIENT_SECRET and WORKLOADER_API_TOKEN environment variables take precedence over the client_secret and token keys.
+// Blank secrets are read from the OS credential store if the PCE uses it.
+func GetPCEAuth(name string) PCEAuth {
+	a := PCEAuth{Type: viper.GetString(name + ".auth_type"), TokenURL: viper.GetString(name + ".token_url"), ClientID: viper.GetString(name + ".client_id"), ClientSecret: viper.GetString(name + ".client_secret"), Scope: viper.GetString(name + ".scope"), Token: viper.GetString(name + ".token")}
+	if a.Type == "" {
+		a.Type = PCEAuthAPIKey
+	}
+	if a.Type != PCEAuthAPIKey && UsesKeychain(name) {
+		if a.Type == PCEAuthOAuth2 && a.ClientSecret == "" {
+			a.ClientSecret, _ = keychainValue(name, "client_secret")
+		}
+		if a.Type == PCEAuthToken && a.Token == "" {
+			a.Token, _ = keychainValue(name, "token")
+		}
+	}
+	if os.Getenv("WORKLOADER_CLIENT_SECRET") != "" {
+		a.ClientSecret = os.Getenv("WORKLOADER_CLIENT_SECRET")
+	}
+	if os.Getenv("WORKLOADER_API_TOKEN") != "" {
+		a.Token = os.Getenv("WORKLOADER_API_TOKEN")
+	}
+	return a
+}
+
+// pceTokenSource gets and caches bearer tokens
+type pceTokenSource struct {
+	auth   PCEAuth
+	client *http.Client
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// get returns the cached token or requests a new one if it expires in the next minute
+func (s *pceTokenSource) get() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auth.Type == PCEAuthToken {
+		return s.auth.Token, nil
+	}
+	if s.token != "" && time.Now().Add(time.Minute).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if s.auth.Scope != "" {
+		form.Set("scope", s.auth.Scope)
+	}
+	req, err := http.NewRequest("POST", s.auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(url.QueryEscape(s.auth.ClientID), url.QueryEscape(s.auth.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting oauth2 token - %d - %s", resp.StatusCode, string(body))
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return "", err
+	}
+	if t.ExpiresIn == 0 {
+		t.ExpiresIn = 3600
+	}
+	s.token = t.AccessToken
+	s.expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	LogDebug(fmt.Sprintf("oauth2 token refreshed. expires %s", s.expiry.Format(time.RFC3339)))
+	return s.token, nil
+}
+
+// invalidate clears the cached token so the next request gets a new one
+func (s *pceTokenSource) invalidate() {
+	s.mu.Lock()
+	s.token = ""
+	s.mu.Unlock()
+}
+
+// StartPCEAuthProxy starts a loopback forwarder that sends requests to the PCE with a bearer token and points the PCE at it.
+// The illumioapi client only supports basic authentication so this lets every command use OAuth2 and service account tokens.
+// Tokens are refreshed before they expire and a request that returns 401 is retried once with a new token.
+func StartPCEAuthProxy(pce *illumioapi.PCE, auth PCEAuth) error {
+	_, err := startAuthForwarder(pce, auth)
+	return err
+}
+
+// startAuthForwarder starts the token forwarder and returns it so the routes can be changed
+func startAuthForwarder(pce *illumioapi.PCE, auth PCEAuth) (*pceForwarder, error) {
+	if auth.Type == PCEAuthOAuth2 && (auth.TokenURL == "" || auth.ClientID == "" || auth.ClientSecret == "") {
+		return nil, fmt.Errorf("%s uses oauth2 and requires token_url, client_id, and client_secret", pce.FriendlyName)
+	}
+	if auth.Type == PCEAuthToken && auth.Token == "" {
+		return nil, fmt.Errorf("%s uses a token and requires token in pce.yaml or the WORKLOADER_API_TOKEN environment variable", pce.FriendlyName)
+	}
+
+	// Upstream client uses the pce tls and proxy settings
+	client, err := pceUpstreamClient(*pce)
+	if err != nil {
+		return nil, err
+	}
+	tokens := &pceTokenSource{auth: auth, client: client}
+	if _, err := tokens.get(); err != nil {
+		return nil, err
+	}
+	retry := GetAPIRetryConfig()
+	f := &pceForwarder{name: pce.FriendlyName, upstream: fmt.Sprintf("https://%s:%d", pce.FQDN, pce.Port), client: client, tokens: tokens, retry: retry, limiter: newRateLimiter(retry.RPS), timeouts: GetPCETimeouts(pce.FriendlyName)}
+	if err := startPCEForwarder(pce, f); err != nil {
+		return nil, err
+	}
+
+	LogDebug(fmt.Sprintf("%s - forwarding api requests to %s with %s authentication", f.name, f.upstream, auth.Type))
+
+	// The illumioapi client authenticates to the forwarder with the per-run credentials. The forwarder replaces them with the token.
+	pce.User, pce.Key = f.user, f.key
+	return f, nil
+}
+
+// loopbackCert creates a self-signed certificate for 127.0.0.1
+func loopbackCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "workloader"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
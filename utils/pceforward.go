@@ -0,0 +1,543 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// maxBackoff is the longest wait between retries when the PCE does not send Retry-After
+const maxBackoff = 60 * time.Second
+
+// APIRetryConfig is the retry and rate limit configuration for PCE api calls
+type APIRetryConfig struct {
+	MaxRetries int
+	RPS        float64
+}
+
+// Enabled returns true if api calls need to go through the retry forwarder
+func (c APIRetryConfig) Enabled() bool {
+	return c.MaxRetries > 0 || c.RPS > 0
+}
+
+// GetAPIRetryConfig returns the retry configuration. The --max-retries and --rps flags take precedence over the
+// WORKLOADER_API_MAX_RETRIES and WORKLOADER_API_RPS environment variables, which take precedence over api_max_retries and api_rps in pce.yaml.
+func GetAPIRetryConfig() APIRetryConfig {
+	c := APIRetryConfig{MaxRetries: viper.GetInt("api_max_retries"), RPS: viper.GetFloat64("api_rps")}
+	if v, err := strconv.Atoi(os.Getenv("WORKLOADER_API_MAX_RETRIES")); err == nil {
+		c.MaxRetries = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("WORKLOADER_API_RPS"), 64); err == nil {
+		c.RPS = v
+	}
+	if viper.GetInt("max_retries") > 0 {
+		c.MaxRetries = viper.GetInt("max_retries")
+	}
+	if viper.GetFloat64("rps") > 0 {
+		c.RPS = viper.GetFloat64("rps")
+	}
+	return c
+}
+
+// rateLimiter spaces requests to stay under the configured requests per second.
+// The rate is halved when the PCE throttles and recovers gradually after successful requests.
+// All requests pause when the PCE sends Retry-After or reports no remaining requests.
+type rateLimiter struct {
+	mu         sync.Mutex
+	maxRPS     float64
+	rps        float64
+	next       time.Time
+	pauseUntil time.Time
+	successes  int
+}
+
+// newRateLimiter creates a limiter. A maxRPS of 0 only applies pauses from the PCE.
+func newRateLimiter(maxRPS float64) *rateLimiter {
+	return &rateLimiter{maxRPS: maxRPS, rps: maxRPS}
+}
+
+// wait blocks until the next request can be sent
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	t := now
+	if l.next.After(t) {
+		t = l.next
+	}
+	if l.pauseUntil.After(t) {
+		t = l.pauseUntil
+	}
+	if l.rps > 0 {
+		l.next = t.Add(time.Duration(float64(time.Second) / l.rps))
+	}
+	l.mu.Unlock()
+	time.Sleep(time.Until(t))
+}
+
+// throttled pauses all requests and slows the rate after a 429 or 503
+func (l *rateLimiter) throttled(pause time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(pause); until.After(l.pauseUntil) {
+		l.pauseUntil = until
+	}
+	if l.rps > 0 {
+		l.rps = l.rps / 2
+		if l.rps < 0.5 {
+			l.rps = 0.5
+		}
+		LogDebug(fmt.Sprintf("pce throttled. reducing api rate to %.2f requests per second", l.rps))
+	}
+	l.successes = 0
+}
+
+// succeeded increases the rate back toward the configured rate after every 10 successful requests
+func (l *rateLimiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rps == 0 || l.rps >= l.maxRPS {
+		return
+	}
+	l.successes++
+	if l.successes >= 10 {
+		l.rps = l.rps + l.maxRPS/10
+		if l.rps > l.maxRPS {
+			l.rps = l.maxRPS
+		}
+		l.successes = 0
+	}
+}
+
+// pauseUntilReset pauses requests when the PCE reports no remaining requests in the current window
+func (l *rateLimiter) pauseUntilReset(h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	if reset := rateLimitReset(h); reset > 0 {
+		l.mu.Lock()
+		if until := time.Now().Add(reset); until.After(l.pauseUntil) {
+			l.pauseUntil = until
+		}
+		l.mu.Unlock()
+	}
+}
+
+// rateLimitReset parses X-RateLimit-Reset as seconds until the reset or a unix timestamp
+func rateLimitReset(h http.Header) time.Duration {
+	v, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	if v > 1000000000 {
+		return time.Until(time.Unix(v, 0))
+	}
+	return time.Duration(v) * time.Second
+}
+
+// retryWait returns how long to wait before a retry from Retry-After, X-RateLimit-Reset, or exponential backoff with jitter
+func retryWait(h http.Header, attempt int) time.Duration {
+	if h != nil {
+		if v := h.Get("Retry-After"); v != "" {
+			if s, err := strconv.Atoi(v); err == nil {
+				return time.Duration(s) * time.Second
+			}
+			if t, err := http.ParseTime(v); err == nil {
+				return time.Until(t)
+			}
+		}
+		if reset := rateLimitReset(h); reset > 0 {
+			return reset
+		}
+	}
+	backoff := time.Second << attempt
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// retryableStatus returns true for status codes that mean the PCE did not process the request
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable || code == http.StatusBadGateway || code == http.StatusGatewayTimeout
+}
+
+// idempotent returns true for methods that are safe to retry after a connection error
+func idempotent(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "PUT" || method == "DELETE" || method == "OPTIONS"
+}
+
+// pceForwarder forwards api requests from the loopback listener to the PCE
+type pceForwarder struct {
+	name     string
+	upstream string
+	leader   string // supercluster leader for write requests. blank sends all requests to upstream.
+	client   *http.Client
+	tokens   *pceTokenSource // nil for basic authentication, which is passed through
+	retry    APIRetryConfig
+	limiter  *rateLimiter
+	readOnly bool      // refuse requests that change the PCE
+	journal  bool      // record requests that change the PCE in the run journal
+	simulate bool      // record requests that change the PCE as planned changes instead of sending them
+	cache    *apiCache // cache GET responses. nil sends all requests to the PCE.
+	timeouts PCETimeouts
+	user     string // random per-run credentials for the loopback proxy and, with token authentication, the illumioapi client
+	key      string
+}
+
+// pceUpstreamClient returns an http client with the pce tls, client certificate, connect timeout, and proxy settings that does not follow redirects
+func pceUpstreamClient(pce illumioapi.PCE) (*http.Client, error) {
+	tlsConfig, err := pceTLSConfig(pce)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if connect := GetPCETimeouts(pce.FriendlyName).Connect; connect > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: connect}).DialContext
+		transport.TLSHandshakeTimeout = connect
+	}
+	if pce.Proxy != "" {
+		proxyURL, err := url.Parse(pce.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}, nil
+}
+
+// route returns the upstream for a request. Writes go to the supercluster leader if one is set.
+func (f *pceForwarder) route(method string) string {
+	if f.leader != "" && method != "GET" && method != "HEAD" && method != "OPTIONS" {
+		return f.leader
+	}
+	return f.upstream
+}
+
+// do sends a request to the PCE with the token and retry settings
+func (f *pceForwarder) do(r *http.Request, body []byte) (*http.Response, error) {
+	tokenRetried := false
+	for attempt := 0; ; attempt++ {
+		f.limiter.wait()
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if timeout := f.timeouts.requestTimeout(r); timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		req, err := http.NewRequestWithContext(ctx, r.Method, f.route(r.Method)+r.URL.RequestURI(), bytes.NewReader(body))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		for k, v := range r.Header {
+			req.Header[k] = v
+		}
+		if f.tokens != nil {
+			token, err := f.tokens.get()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("%s %s timed out after %s. increase it with --read-timeout or --long-poll-timeout - %w", r.Method, r.URL.Path, f.timeouts.requestTimeout(r), err)
+			}
+			if attempt >= f.retry.MaxRetries || !idempotent(r.Method) {
+				return nil, err
+			}
+			wait := retryWait(nil, attempt)
+			LogWarning(fmt.Sprintf("%s - %s %s - %s. retrying in %s (%d of %d).", f.name, r.Method, r.URL.Path, err, wait.Round(time.Millisecond), attempt+1, f.retry.MaxRetries), false)
+			time.Sleep(wait)
+			continue
+		}
+		resp.Body = cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+		// Refresh an expired oauth2 token once without counting it as a retry
+		if resp.StatusCode == http.StatusUnauthorized && f.tokens != nil && f.tokens.auth.Type == PCEAuthOAuth2 && !tokenRetried {
+			resp.Body.Close()
+			f.tokens.invalidate()
+			tokenRetried = true
+			attempt--
+			continue
+		}
+
+		if !retryableStatus(resp.StatusCode) {
+			f.limiter.pauseUntilReset(resp.Header)
+			f.limiter.succeeded()
+			return resp, nil
+		}
+		wait := retryWait(resp.Header, attempt)
+		f.limiter.throttled(wait)
+		if attempt >= f.retry.MaxRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+		LogWarning(fmt.Sprintf("%s - %s %s returned %d. retrying in %s (%d of %d).", f.name, r.Method, r.URL.Path, resp.StatusCode, wait.Round(time.Millisecond), attempt+1, f.retry.MaxRetries), false)
+	}
+}
+
+// ServeHTTP forwards a request and copies the response back to the illumioapi client
+func (f *pceForwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.tokens != nil {
+		user, key, ok := r.BasicAuth()
+		if !ok || !loopbackCredentialsMatch(user, key, f.user, f.key) {
+			LogWarning(fmt.Sprintf("%s - rejected %s %s to the loopback forwarder with invalid credentials", f.name, r.Method, r.URL.Path), false)
+			http.Error(w, "invalid credentials for the workloader pce forwarder", http.StatusUnauthorized)
+			return
+		}
+	}
+	if f.readOnly && readOnlyBlocked(r) {
+		refuseReadOnly(w, r, f.name)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if f.simulate && readOnlyBlocked(r) {
+		f.simulateWrite(w, r, body)
+		return
+	}
+	if f.cache != nil && f.cache.serve(w, r, f) {
+		return
+	}
+	journalWrite := f.journal && readOnlyBlocked(r)
+	var before map[string]json.RawMessage
+	if journalWrite {
+		before = f.journalBefore(r, body)
+	}
+	resp, err := f.do(r, body)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+			LogWarning(fmt.Sprintf("%s - %s", f.name, err), false)
+		}
+		if f.tokens != nil && strings.Contains(err.Error(), "token") {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	if l := resp.Header.Get("Location"); strings.HasPrefix(l, f.route(r.Method)) {
+		w.Header().Set("Location", strings.TrimPrefix(l, f.route(r.Method)))
+	}
+	if journalWrite {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJournal(f.journalEntries(r, body, before, resp.StatusCode, respBody))
+		f.clearCache(r, resp.StatusCode)
+		w.WriteHeader(resp.StatusCode)
+		w.Write(respBody)
+		return
+	}
+	if f.cache != nil {
+		f.cacheResponse(w, r, resp)
+		return
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// clearCache clears the cache after a request changes the PCE
+func (f *pceForwarder) clearCache(r *http.Request, status int) {
+	if f.cache != nil && readOnlyBlocked(r) && status >= 200 && status < 300 {
+		f.cache.clear()
+	}
+}
+
+// cacheResponse writes a response and saves cacheable GET responses and async collection results in the cache
+func (f *pceForwarder) cacheResponse(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	f.clearCache(r, resp.StatusCode)
+	w.WriteHeader(resp.StatusCode)
+	if strings.Contains(r.URL.Path, "/jobs/") && resp.StatusCode == http.StatusOK {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return
+		}
+		f.cache.track(f.route(r.Method), r, resp.StatusCode, "", respBody)
+		w.Write(respBody)
+		return
+	}
+	f.cache.track(f.route(r.Method), r, resp.StatusCode, w.Header().Get("Location"), nil)
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(w, resp.Body)
+		return
+	}
+	if key, ok := f.cache.resultKey(r); ok {
+		f.cache.store(w, key, r, resp, true)
+		return
+	}
+	if cacheable(r) && r.Header.Get("Prefer") == "" {
+		f.cache.store(w, f.cache.key(f.route(r.Method), r), r, resp, false)
+		return
+	}
+	io.Copy(w, resp.Body)
+}
+
+// tunnelListener accepts the connections tunneled through the loopback proxy
+type tunnelListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Accept returns the next tunneled connection
+func (l *tunnelListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting tunneled connections
+func (l *tunnelListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr returns the address of the loopback proxy
+func (l *tunnelListener) Addr() net.Addr {
+	return l.addr
+}
+
+// loopbackProxy is an http proxy that accepts CONNECT requests from the illumioapi client and passes the tunnels to the forwarder
+type loopbackProxy struct {
+	tunnels *tunnelListener
+	user    string
+	key     string
+}
+
+// loopbackCredentials returns a random user and key for a forwarder so other local processes cannot use it
+func loopbackCredentials() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", "", err
+	}
+	return "workloader-" + hex.EncodeToString(b[:8]), hex.EncodeToString(b[8:]), nil
+}
+
+// loopbackCredentialsMatch compares credentials in constant time
+func loopbackCredentialsMatch(user, key, wantUser, wantKey string) bool {
+	userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser))
+	keyMatch := subtle.ConstantTimeCompare([]byte(key), []byte(wantKey))
+	return userMatch&keyMatch == 1
+}
+
+// proxyBasicAuth returns the credentials in the Proxy-Authorization header
+func proxyBasicAuth(r *http.Request) (string, string, bool) {
+	auth := r.Header.Get("Proxy-Authorization")
+	if auth == "" {
+		return "", "", false
+	}
+	req := &http.Request{Header: http.Header{"Authorization": []string{auth}}}
+	return req.BasicAuth()
+}
+
+// ServeHTTP accepts a CONNECT request with the forwarder's credentials and hands the connection to the forwarder's tls listener
+func (p *loopbackProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "workloader pce proxy only accepts CONNECT requests", http.StatusMethodNotAllowed)
+		return
+	}
+	if user, key, ok := proxyBasicAuth(r); !ok || !loopbackCredentialsMatch(user, key, p.user, p.key) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="workloader"`)
+		http.Error(w, "invalid credentials for the workloader pce proxy", http.StatusProxyAuthRequired)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be tunneled", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+	select {
+	case p.tunnels.conns <- conn:
+	case <-p.tunnels.closed:
+		conn.Close()
+	}
+}
+
+// startPCEForwarder starts a loopback proxy for the forwarder and sets it as the PCE's proxy. The PCE keeps its fqdn and port so
+// commands still see the configured PCE. The illumioapi client tunnels each connection through the proxy, the tunnel is terminated
+// with a self-signed loopback certificate, and the requests are handled by the forwarder. The proxy requires random per-run credentials.
+func startPCEForwarder(pce *illumioapi.PCE, f *pceForwarder) error {
+	cert, err := loopbackCert()
+	if err != nil {
+		return err
+	}
+	if f.user, f.key, err = loopbackCredentials(); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	tunnels := &tunnelListener{addr: listener.Addr(), conns: make(chan net.Conn), closed: make(chan struct{})}
+	go http.Serve(tls.NewListener(tunnels, &tls.Config{Certificates: []tls.Certificate{cert}}), f)
+	go http.Serve(listener, &loopbackProxy{tunnels: tunnels, user: f.user, key: f.key})
+
+	pce.Proxy = (&url.URL{Scheme: "http", User: url.UserPassword(f.user, f.key), Host: listener.Addr().String()}).String()
+	pce.DisableTLSChecking = true
+	return nil
+}
+
+// StartPCERetryProxy routes the api calls for a PCE that uses an api key through a loopback forwarder that retries throttled and
+// failed requests and limits the request rate. Basic authentication is passed through to the PCE.
+func StartPCERetryProxy(pce *illumioapi.PCE, retry APIRetryConfig) error {
+	_, err := startRetryForwarder(pce, retry)
+	return err
+}
+
+// startRetryForwarder starts the basic authentication forwarder and returns it so the routes can be changed
+func startRetryForwarder(pce *illumioapi.PCE, retry APIRetryConfig) (*pceForwarder, error) {
+	client, err := pceUpstreamClient(*pce)
+	if err != nil {
+		return nil, err
+	}
+	f := &pceForwarder{name: pce.FriendlyName, upstream: fmt.Sprintf("https://%s:%d", pce.FQDN, pce.Port), client: client, retry: retry, limiter: newRateLimiter(retry.RPS), timeouts: GetPCETimeouts(pce.FriendlyName)}
+	if err := startPCEForwarder(pce, f); err != nil {
+		return nil, err
+	}
+	LogDebug(fmt.Sprintf("%s - forwarding api requests to %s with %d max retries and %.2f requests per second", f.name, f.upstream, retry.MaxRetries, retry.RPS))
+	return f, nil
+}
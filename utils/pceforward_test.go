@@ -0,0 +1,47 @@
+package utils_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+)
+
+// TestAuthForwarderCredentials checks the token forwarder keeps the pce fqdn and only accepts its per-run credentials
+func TestAuthForwarderCredentials(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	pce := s.PCE()
+	pce.User, pce.Key = "", ""
+	if err := utils.StartPCEAuthProxy(&pce, utils.PCEAuth{Type: utils.PCEAuthToken, Token: s.Token}); err != nil {
+		t.Fatal(err)
+	}
+	if pce.FQDN != s.PCE().FQDN || pce.Port != s.PCE().Port {
+		t.Errorf("pce is %s:%d, want the configured %s:%d", pce.FQDN, pce.Port, s.PCE().FQDN, s.PCE().Port)
+	}
+	if pce.User == "" || pce.User == utils.PCEAuthToken || pce.Key == "" || pce.Key == utils.PCEAuthToken {
+		t.Fatalf("pce user and key are %q and %q, want random credentials", pce.User, pce.Key)
+	}
+
+	if _, api, err := pce.GetVersion(); err != nil || api.StatusCode != 200 {
+		t.Fatalf("request with the forwarder credentials returned %d - %v", api.StatusCode, err)
+	}
+
+	wrongKey := pce
+	wrongKey.Key = utils.PCEAuthToken
+	if _, api, _ := wrongKey.GetVersion(); api.StatusCode != 401 {
+		t.Errorf("request with the wrong key returned %d, want 401", api.StatusCode)
+	}
+
+	noProxyAuth := pce
+	u, err := url.Parse(pce.Proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.User = nil
+	noProxyAuth.Proxy = u.String()
+	if _, api, err := noProxyAuth.GetVersion(); err == nil && api.StatusCode == 200 {
+		t.Error("request through the proxy without credentials was forwarded")
+	}
+}
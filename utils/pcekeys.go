@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brian1917/illumioapi"
+)
+
+// PCEAPIKey is an api key with the expiration from PCEs that enforce api key lifetimes
+type PCEAPIKey struct {
+	illumioapi.APIKey
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// Expiry returns when the api key expires. The bool is false if the key does not expire.
+func (k PCEAPIKey) Expiry() (time.Time, bool) {
+	if k.ExpiresAt == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, k.ExpiresAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// GetPCEAPIKeys returns the href of the api user and its api keys
+func GetPCEAPIKeys(pce illumioapi.PCE) (string, []PCEAPIKey, error) {
+	var login illumioapi.UserLogin
+	api, err := pce.GetHref("/users/login", &login)
+	LogAPIResp("GetUserLogin", api)
+	if err != nil || login.Href == "" {
+		return "", nil, fmt.Errorf("getting api user - %d - %v", api.StatusCode, err)
+	}
+	var keys []PCEAPIKey
+	api, err = pce.GetHref(login.Href+"/api_keys", &keys)
+	LogAPIResp("GetAPIKeys", api)
+	if err != nil || api.StatusCode != 200 {
+		return login.Href, nil, fmt.Errorf("getting api keys for %s - %d - %v", login.Href, api.StatusCode, err)
+	}
+	return login.Href, keys, nil
+}
+
+// CreatePCEAPIKey creates an api key for the user with the current credentials.
+// The request uses the same tls, client certificate, and proxy settings as other api calls.
+func CreatePCEAPIKey(pce illumioapi.PCE, userHref, name, description string) (illumioapi.APIKey, illumioapi.APIResponse, error) {
+	var apiKey illumioapi.APIKey
+	client, err := pceUpstreamClient(pce)
+	if err != nil {
+		return apiKey, illumioapi.APIResponse{}, err
+	}
+	body, err := json.Marshal(illumioapi.APIKey{Name: name, Description: description})
+	if err != nil {
+		return apiKey, illumioapi.APIResponse{}, err
+	}
+	api, err := pceLoginReq(client, "POST", fmt.Sprintf("https://%s:%d/api/v2%s/api_keys", pce.FQDN, pce.Port, userHref), body, func(r *http.Request) { r.SetBasicAuth(pce.User, pce.Key) })
+	if err != nil {
+		return apiKey, api, fmt.Errorf("creating api key - %s", err)
+	}
+	if err := json.Unmarshal([]byte(api.RespBody), &apiKey); err != nil || apiKey.Secret == "" {
+		return apiKey, api, fmt.Errorf("creating api key - no secret in the response")
+	}
+	return apiKey, api, nil
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+)
+
+// PCELoginInput is the input for an interactive login that creates an api key
+type PCELoginInput struct {
+	User           string
+	Password       string
+	OTP            string                      // one-time code. If blank and the login server requires one, PromptOTP is called.
+	PromptOTP      func() string               // called when the login server requires a one-time code
+	Org            int                         // org for the api key. If 0 and the user has more than one org, SelectOrg is called.
+	SelectOrg      func([]*illumioapi.Org) int // returns the org id to use
+	KeyName        string                      // name of the api key
+	KeyDescription string                      // description of the api key
+}
+
+// pceLoginServer returns the login server for a PCE. SaaS PCEs use login.illum.io. The ILLUMIO_LOGIN_SERVER environment variable takes precedence.
+func pceLoginServer(pce illumioapi.PCE) string {
+	fqdn := strings.TrimPrefix(strings.TrimPrefix(pce.FQDN, "https://"), "http://")
+	if strings.Contains(fqdn, "illum.io") && !strings.Contains(fqdn, "demo") {
+		fqdn = "login.illum.io"
+	}
+	if os.Getenv("ILLUMIO_LOGIN_SERVER") != "" {
+		fqdn = os.Getenv("ILLUMIO_LOGIN_SERVER")
+	}
+	return fqdn
+}
+
+// mfaRequired checks if a failed authenticate response is asking for a one-time code
+func mfaRequired(resp illumioapi.APIResponse) bool {
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	body := strings.ToLower(resp.RespBody)
+	for _, s := range []string{"mfa", "otp", "two_factor", "two-factor", "totp"} {
+		if strings.Contains(body, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// pceLoginReq makes a login request and returns the response in an APIResponse so it can be logged
+func pceLoginReq(client *http.Client, method, apiURL string, body []byte, setAuth func(*http.Request)) (illumioapi.APIResponse, error) {
+	var api illumioapi.APIResponse
+	req, err := http.NewRequest(method, apiURL, bytes.NewReader(body))
+	if err != nil {
+		return api, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setAuth(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return api, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return api, err
+	}
+	api = illumioapi.APIResponse{RespBody: string(data), StatusCode: resp.StatusCode, Header: resp.Header, Request: resp.Request, ReqBody: string(body)}
+	if resp.StatusCode > 299 {
+		return api, fmt.Errorf("%s %s returned a status code of %d", method, req.URL.Path, resp.StatusCode)
+	}
+	return api, nil
+}
+
+// PCELoginAPIKey authenticates to the login server with a username and password, prompting for a one-time code if the login server requires it.
+// It then creates an api key in the selected org and populates the User, Key, and Org fields in the PCE instance.
+// The session used to create the api key is temporary and expires after 10 minutes of inactivity.
+func PCELoginAPIKey(pce *illumioapi.PCE, input PCELoginInput) (illumioapi.UserLogin, []illumioapi.APIResponse, error) {
+	var login illumioapi.UserLogin
+	var apiResps []illumioapi.APIResponse
+
+	tlsConfig, err := pceTLSConfig(*pce)
+	if err != nil {
+		return login, apiResps, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if pce.Proxy != "" {
+		proxyURL, err := url.Parse(pce.Proxy)
+		if err != nil {
+			return login, apiResps, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client := &http.Client{Transport: transport}
+
+	// Authenticate to the login server. The one-time code is sent in the request body.
+	authURL := fmt.Sprintf("https://%s:%d/api/v2/login_users/authenticate?pce_fqdn=%s", pceLoginServer(*pce), pce.Port, url.QueryEscape(pce.FQDN))
+	otp := input.OTP
+	var api illumioapi.APIResponse
+	for attempt := 0; attempt < 2; attempt++ {
+		var body []byte
+		if otp != "" {
+			body, _ = json.Marshal(map[string]string{"otp": otp})
+		}
+		api, err = pceLoginReq(client, "POST", authURL, body, func(r *http.Request) { r.SetBasicAuth(input.User, input.Password) })
+		apiResps = append(apiResps, api)
+		if err == nil || otp != "" || !mfaRequired(api) || input.PromptOTP == nil {
+			break
+		}
+		otp = input.PromptOTP()
+		if otp == "" {
+			break
+		}
+	}
+	if err != nil {
+		if mfaRequired(api) {
+			return login, apiResps, fmt.Errorf("authenticating - the login server requires a valid one-time code - %s", err)
+		}
+		return login, apiResps, fmt.Errorf("authenticating - %s", err)
+	}
+	var auth illumioapi.Authentication
+	if err := json.Unmarshal([]byte(api.RespBody), &auth); err != nil || auth.AuthToken == "" {
+		return login, apiResps, fmt.Errorf("authenticating - no auth token in the login server response")
+	}
+
+	// Get the session
+	api, err = pceLoginReq(client, "GET", fmt.Sprintf("https://%s:%d/api/v2/users/login", pce.FQDN, pce.Port), nil, func(r *http.Request) { r.Header.Set("Authorization", "Token token="+auth.AuthToken) })
+	apiResps = append(apiResps, api)
+	if err != nil {
+		return login, apiResps, fmt.Errorf("logging in - %s", err)
+	}
+	if err := json.Unmarshal([]byte(api.RespBody), &login); err != nil {
+		return login, apiResps, fmt.Errorf("logging in - %s", err)
+	}
+	if len(login.Orgs) == 0 {
+		return login, apiResps, fmt.Errorf("logging in - %s is not a member of any org", input.User)
+	}
+
+	// Select the org
+	org := input.Org
+	if org == 0 && len(login.Orgs) > 1 && input.SelectOrg != nil {
+		org = input.SelectOrg(login.Orgs)
+	}
+	if org == 0 {
+		org = login.Orgs[0].ID
+	}
+	validOrg := false
+	for _, o := range login.Orgs {
+		if o.ID == org {
+			validOrg = true
+		}
+	}
+	if !validOrg {
+		return login, apiResps, fmt.Errorf("%s is not a member of org %d", input.User, org)
+	}
+
+	// Create the api key with the session
+	postJSON, err := json.Marshal(illumioapi.APIKey{Name: input.KeyName, Description: input.KeyDescription})
+	if err != nil {
+		return login, apiResps, err
+	}
+	api, err = pceLoginReq(client, "POST", fmt.Sprintf("https://%s:%d/api/v2%s/api_keys", pce.FQDN, pce.Port, login.Href), postJSON, func(r *http.Request) { r.SetBasicAuth(login.AuthUsername, login.SessionToken) })
+	apiResps = append(apiResps, api)
+	if err != nil {
+		return login, apiResps, fmt.Errorf("creating api key - %s", err)
+	}
+	var apiKey illumioapi.APIKey
+	if err := json.Unmarshal([]byte(api.RespBody), &apiKey); err != nil || apiKey.Secret == "" {
+		return login, apiResps, fmt.Errorf("creating api key - no secret in the response")
+	}
+
+	pce.User = apiKey.AuthUsername
+	pce.Key = apiKey.Secret
+	pce.Org = org
+
+	return login, apiResps, nil
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// PCEOrg is an org in the <name>.orgs list of a pce.yaml entry. A blank user or key uses the user and key of the entry.
+type PCEOrg struct {
+	Org  int    `mapstructure:"org"`
+	Name string `mapstructure:"name"`
+	User string `mapstructure:"user"`
+	Key  string `mapstructure:"key"`
+}
+
+// GetPCEOrgs returns the orgs for a PCE. If the entry has no orgs list, the entry's org is returned.
+func GetPCEOrgs(name string) ([]PCEOrg, error) {
+	orgs := []PCEOrg{}
+	if viper.IsSet(name + ".orgs") {
+		if err := viper.UnmarshalKey(name+".orgs", &orgs); err != nil {
+			return nil, fmt.Errorf("%s orgs - %s", name, err)
+		}
+	}
+	if len(orgs) == 0 && viper.GetInt(name+".org") != 0 {
+		orgs = append(orgs, PCEOrg{Org: viper.GetInt(name + ".org")})
+	}
+	return orgs, nil
+}
+
+// GetTargetOrg returns the org selector from the --org flag
+func GetTargetOrg() string {
+	return viper.GetString("target_org")
+}
+
+// applyPCEOrg sets the org and credentials of a PCE from an org selector. The selector is an org id or name from the orgs list.
+// An org id that is not in the list uses the entry's credentials.
+func applyPCEOrg(pce *illumioapi.PCE, name, selector string) error {
+	orgs, err := GetPCEOrgs(name)
+	if err != nil {
+		return err
+	}
+	for _, o := range orgs {
+		if o.Name == selector || strconv.Itoa(o.Org) == selector {
+			pce.Org = o.Org
+			if o.User != "" {
+				pce.User = o.User
+			}
+			if o.Key != "" {
+				if pce.Key, err = DecryptSecret(o.Key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	org, err := strconv.Atoi(selector)
+	if err != nil {
+		return fmt.Errorf("%s is not an org in %s", selector, name)
+	}
+	pce.Org = org
+	return nil
+}
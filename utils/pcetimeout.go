@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// PCETimeouts are the timeouts for PCE api calls. A timeout of 0 has no limit.
+type PCETimeouts struct {
+	Connect  time.Duration // establishing the connection and tls handshake
+	Read     time.Duration // each api request, including reading the response
+	LongPoll time.Duration // async jobs and traffic queries, including the wait for results
+}
+
+// Enabled returns true if any timeout is set
+func (t PCETimeouts) Enabled() bool {
+	return t.Connect > 0 || t.Read > 0 || t.LongPoll > 0
+}
+
+// parseTimeout parses a timeout from pce.yaml or an environment variable. Numbers are seconds and strings use Go durations (e.g., 90s or 10m).
+func parseTimeout(v interface{}) (time.Duration, error) {
+	switch t := v.(type) {
+	case int:
+		return time.Duration(t) * time.Second, nil
+	case float64:
+		return time.Duration(t * float64(time.Second)), nil
+	case string:
+		if s, err := strconv.ParseFloat(t, 64); err == nil {
+			return time.Duration(s * float64(time.Second)), nil
+		}
+		return time.ParseDuration(t)
+	}
+	return 0, fmt.Errorf("%v is not a valid timeout", v)
+}
+
+// GetPCETimeouts returns the timeouts for a PCE. The --connect-timeout, --read-timeout, and --long-poll-timeout flags take precedence over
+// the WORKLOADER_CONNECT_TIMEOUT, WORKLOADER_READ_TIMEOUT, and WORKLOADER_LONG_POLL_TIMEOUT environment variables, which take precedence over
+// connect_timeout, read_timeout, and long_poll_timeout in the PCE entry and then at the top level of pce.yaml.
+func GetPCETimeouts(name string) PCETimeouts {
+	get := func(key string) time.Duration {
+		var sources []interface{}
+		if viper.GetDuration(key+"_flag") > 0 {
+			return viper.GetDuration(key + "_flag")
+		}
+		if e := os.Getenv("WORKLOADER_" + strings.ToUpper(key)); e != "" {
+			sources = append(sources, e)
+		}
+		if name != "" && !IsEnvPCE(name) && viper.IsSet(name+"."+key) {
+			sources = append(sources, viper.Get(name+"."+key))
+		}
+		if viper.IsSet(key) {
+			sources = append(sources, viper.Get(key))
+		}
+		for _, s := range sources {
+			d, err := parseTimeout(s)
+			if err != nil {
+				LogWarning(fmt.Sprintf("%s - %s. ignoring it.", key, err), true)
+				continue
+			}
+			return d
+		}
+		return 0
+	}
+	return PCETimeouts{Connect: get("connect_timeout"), Read: get("read_timeout"), LongPoll: get("long_poll_timeout")}
+}
+
+// longPoll returns true for requests that start or wait on async jobs and traffic queries
+func longPoll(r *http.Request) bool {
+	if r.Header.Get("Prefer") == "respond-async" {
+		return true
+	}
+	for _, p := range []string{"/jobs/", "/traffic_flows/async_queries", "/traffic_flows/traffic_analysis_queries"} {
+		if strings.Contains(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTimeout returns the timeout for a request
+func (t PCETimeouts) requestTimeout(r *http.Request) time.Duration {
+	if longPoll(r) {
+		return t.LongPoll
+	}
+	return t.Read
+}
+
+// cancelBody cancels the request context when the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// PollDeadline returns when to stop waiting for an async job or traffic query on a PCE. The zero time has no limit.
+func PollDeadline(name string) time.Time {
+	if t := GetPCETimeouts(name).LongPoll; t > 0 {
+		return time.Now().Add(t)
+	}
+	return time.Time{}
+}
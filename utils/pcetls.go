@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// PCETLS is the custom CA bundle and client certificate for a PCE
+type PCETLS struct {
+	CAFile     string `mapstructure:"ca_file"`
+	ClientCert string `mapstructure:"client_cert"`
+	ClientKey  string `mapstructure:"client_key"`
+}
+
+// Enabled returns true if the PCE uses a custom CA bundle or a client certificate
+func (t PCETLS) Enabled() bool {
+	return t.CAFile != "" || t.ClientCert != "" || t.ClientKey != ""
+}
+
+// GetPCETLS returns the ca_file, client_cert, and client_key for a PCE in pce.yaml.
+// PCEs from environment variables use WORKLOADER_CA_FILE, WORKLOADER_CLIENT_CERT, and WORKLOADER_CLIENT_KEY.
+func GetPCETLS(name string) PCETLS {
+	if IsEnvPCE(name) {
+		return PCETLS{CAFile: os.Getenv(envPCEVar(name, "CA_FILE")), ClientCert: os.Getenv(envPCEVar(name, "CLIENT_CERT")), ClientKey: os.Getenv(envPCEVar(name, "CLIENT_KEY"))}
+	}
+	return PCETLS{CAFile: viper.GetString(name + ".ca_file"), ClientCert: viper.GetString(name + ".client_cert"), ClientKey: viper.GetString(name + ".client_key")}
+}
+
+// pceTLSConfig returns the tls config for connections to a PCE. The CA bundle is added to the system roots.
+func pceTLSConfig(pce illumioapi.PCE) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: pce.DisableTLSChecking}
+	t := GetPCETLS(pce.FriendlyName)
+	if t.CAFile != "" {
+		caPEM, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file for %s - %s", pce.FriendlyName, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s for %s", t.CAFile, pce.FriendlyName)
+		}
+		config.RootCAs = pool
+	}
+	if t.ClientCert != "" || t.ClientKey != "" {
+		if t.ClientCert == "" || t.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must both be set for %s", pce.FriendlyName)
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate for %s - %s", pce.FriendlyName, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// Capabilities checked before a command updates the PCE
+const (
+	CapWorkloadWrite = "workload write"
+	CapObjectWrite   = "label, service, ip list, and label group write"
+	CapRulesetWrite  = "ruleset write"
+	CapProvision     = "provisioning"
+)
+
+// AnnotationCapabilities is the cobra annotation for the capabilities a command needs to update the PCE. Multiple capabilities are separated by semicolons.
+// The capabilities are checked once when the command gets the target PCE with --update-pce.
+const AnnotationCapabilities = "capabilities"
+
+// capabilityRoles are the roles that grant each capability
+var capabilityRoles = map[string][]string{
+	CapWorkloadWrite: {"owner", "admin", "workload_manager"},
+	CapObjectWrite:   {"owner", "admin"},
+	CapRulesetWrite:  {"owner", "admin", "ruleset_manager", "limited_ruleset_manager"},
+	CapProvision:     {"owner", "admin", "global_object_provisioner", "ruleset_provisioner"},
+}
+
+// pcePermission is a permission from the permissions api
+type pcePermission struct {
+	Role struct {
+		Href string `json:"href"`
+	} `json:"role"`
+}
+
+// GetPCERoles returns the role names granted to the api user in the PCE org directly and through its external groups.
+// The user is found with /users/login and its permissions are read from the auth security principals of the user and of the groups in its effective_groups.
+// Resolved is false when the group memberships of an external user cannot be read so roles granted through groups may be missing.
+func GetPCERoles(pce illumioapi.PCE) (roles []string, resolved bool, err error) {
+	var login illumioapi.UserLogin
+	api, err := pce.GetHref("/users/login", &login)
+	LogAPIResp("GetUserLogin", api)
+	if err != nil || login.AuthUsername == "" {
+		return nil, false, fmt.Errorf("getting api user - %d - %v", api.StatusCode, err)
+	}
+
+	// Local users are not in external groups
+	names := []string{login.AuthUsername}
+	resolved = login.Type == "local"
+	if !resolved && login.Href != "" {
+		var user struct {
+			EffectiveGroups *[]string `json:"effective_groups"`
+		}
+		api, err := pce.GetHref(login.Href, &user)
+		LogAPIResp("GetUser", api)
+		if err == nil && api.StatusCode == 200 && user.EffectiveGroups != nil {
+			names = append(names, *user.EffectiveGroups...)
+			resolved = true
+		}
+	}
+
+	principalHrefs := []string{}
+	for _, name := range names {
+		var principals []struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		}
+		api, err := pce.GetCollection("auth_security_principals", false, map[string]string{"name": name}, &principals)
+		LogAPIResp("GetAuthSecurityPrincipals", api)
+		if err != nil || api.StatusCode != 200 {
+			return nil, false, fmt.Errorf("getting auth security principal for %s - %d - %v", name, api.StatusCode, err)
+		}
+		for _, p := range principals {
+			if strings.EqualFold(p.Name, name) {
+				principalHrefs = append(principalHrefs, p.Href)
+			}
+		}
+	}
+	if len(principalHrefs) == 0 && resolved {
+		return nil, false, fmt.Errorf("no auth security principal for %s or its groups", login.AuthUsername)
+	}
+
+	roles = []string{}
+	for _, principalHref := range principalHrefs {
+		var permissions []pcePermission
+		api, err := pce.GetCollection("permissions", false, map[string]string{"auth_security_principal": principalHref}, &permissions)
+		LogAPIResp("GetPermissions", api)
+		if err != nil || api.StatusCode != 200 {
+			return nil, false, fmt.Errorf("getting permissions for %s - %d - %v", login.AuthUsername, api.StatusCode, err)
+		}
+		for _, p := range permissions {
+			roles = append(roles, path.Base(p.Role.Href))
+		}
+	}
+	return roles, resolved, nil
+}
+
+// CheckPCECapabilities verifies the api user has a role for each capability before a command updates the PCE and stops the command if one is missing.
+// If the roles cannot be determined or the group memberships of an external user cannot be read, a warning is logged and the command continues.
+// Set skip_permission_check to true in pce.yaml or WORKLOADER_SKIP_PERMISSION_CHECK to true to skip the check.
+func CheckPCECapabilities(pce illumioapi.PCE, capabilities ...string) {
+	if viper.GetBool("skip_permission_check") || strings.ToLower(os.Getenv("WORKLOADER_SKIP_PERMISSION_CHECK")) == "true" || len(capabilities) == 0 {
+		return
+	}
+	roles, resolved, err := GetPCERoles(pce)
+	if err != nil {
+		LogWarning(fmt.Sprintf("could not determine api permissions. skipping permission check - %s", err), true)
+		return
+	}
+	hasRole := make(map[string]bool)
+	for _, r := range roles {
+		hasRole[r] = true
+	}
+	missing := []string{}
+	for _, c := range capabilities {
+		granted := false
+		for _, r := range capabilityRoles[c] {
+			if hasRole[r] {
+				granted = true
+			}
+		}
+		if !granted {
+			missing = append(missing, fmt.Sprintf("%s (requires %s)", c, strings.Join(capabilityRoles[c], ", ")))
+		}
+	}
+	if len(missing) > 0 && !resolved {
+		LogWarning(fmt.Sprintf("the api user may not have the permissions for this command: %s. current roles: %s. the user's group memberships could not be read so group roles are not included.", strings.Join(missing, "; "), strings.Join(roles, ", ")), true)
+		return
+	}
+	if len(missing) > 0 {
+		LogError(fmt.Sprintf("the api user does not have the permissions for this command: %s. current roles: %s. set skip_permission_check in pce.yaml or WORKLOADER_SKIP_PERMISSION_CHECK=true to skip this check.", strings.Join(missing, "; "), strings.Join(roles, ", ")))
+	}
+	LogInfo(fmt.Sprintf("permission check passed for %s", strings.Join(capabilities, ", ")), false)
+}
+
+// SetRequiredCapabilities sets the capabilities GetTargetPCE checks from the command annotation. Provisioning is added when the command provisions its changes.
+func SetRequiredCapabilities(annotation string, provision bool) {
+	capabilities := []string{}
+	for _, c := range strings.Split(annotation, ";") {
+		if c != "" {
+			capabilities = append(capabilities, c)
+		}
+	}
+	if len(capabilities) > 0 && provision {
+		capabilities = append(capabilities, CapProvision)
+	}
+	viper.Set("required_capabilities", capabilities)
+}
+
+// checkedCapabilities is the PCEs the required capabilities have been checked for
+var checkedCapabilities = make(map[string]bool)
+
+// checkRequiredCapabilities checks the capabilities set by SetRequiredCapabilities once per PCE when the command updates the PCE
+func checkRequiredCapabilities(pce illumioapi.PCE) {
+	capabilities := viper.GetStringSlice("required_capabilities")
+	if !viper.GetBool("update_pce") || len(capabilities) == 0 || checkedCapabilities[pce.FriendlyName] {
+		return
+	}
+	checkedCapabilities[pce.FriendlyName] = true
+	CheckPCECapabilities(pce, capabilities...)
+}
@@ -0,0 +1,90 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brian1917/workloader/internal/mockpce"
+	"github.com/brian1917/workloader/utils"
+	"github.com/spf13/viper"
+)
+
+// TestRequiredCapabilitiesCheckedOnce checks GetTargetPCE checks the annotated capabilities once and only with --update-pce
+func TestRequiredCapabilitiesCheckedOnce(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	logins := func() int {
+		count := 0
+		for _, r := range s.Requests() {
+			if r.Path == "/users/login" {
+				count++
+			}
+		}
+		return count
+	}
+
+	utils.SetRequiredCapabilities(utils.CapObjectWrite+";"+utils.CapRulesetWrite, true)
+	if want := []string{utils.CapObjectWrite, utils.CapRulesetWrite, utils.CapProvision}; !reflect.DeepEqual(viper.GetStringSlice("required_capabilities"), want) {
+		t.Fatalf("required capabilities are %v, want %v", viper.GetStringSlice("required_capabilities"), want)
+	}
+	if _, err := utils.GetTargetPCE(false); err != nil {
+		t.Fatal(err)
+	}
+	if logins() != 0 {
+		t.Error("capabilities were checked without --update-pce")
+	}
+
+	viper.Set("update_pce", true)
+	for i := 0; i < 2; i++ {
+		if _, err := utils.GetTargetPCE(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins() != 1 {
+		t.Errorf("capabilities were checked %d times, want 1", logins())
+	}
+
+	utils.SetRequiredCapabilities("", true)
+	if len(viper.GetStringSlice("required_capabilities")) != 0 {
+		t.Errorf("command without the annotation requires %v", viper.GetStringSlice("required_capabilities"))
+	}
+}
+
+// TestPCERolesIncludeGroups checks roles granted to the external groups of the api user are included
+func TestPCERolesIncludeGroups(t *testing.T) {
+	s := mockpce.Start(t, "")
+	s.Configure(t)
+	s.Add("auth_security_principals",
+		map[string]interface{}{"href": "/orgs/1/auth_security_principals/a", "name": "api_mock", "type": "user"},
+		map[string]interface{}{"href": "/orgs/1/auth_security_principals/b", "name": "ops", "type": "group"},
+		map[string]interface{}{"href": "/orgs/1/auth_security_principals/c", "name": "ops-admins", "type": "group"})
+	s.Add("permissions",
+		map[string]interface{}{"role": map[string]interface{}{"href": "/orgs/1/roles/read_only"}, "auth_security_principal": map[string]interface{}{"href": "/orgs/1/auth_security_principals/a"}},
+		map[string]interface{}{"role": map[string]interface{}{"href": "/orgs/1/roles/ruleset_manager"}, "auth_security_principal": map[string]interface{}{"href": "/orgs/1/auth_security_principals/b"}},
+		map[string]interface{}{"role": map[string]interface{}{"href": "/orgs/1/roles/owner"}, "auth_security_principal": map[string]interface{}{"href": "/orgs/1/auth_security_principals/c"}})
+	pce, err := utils.GetTargetPCE(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the effective groups of the user the group memberships cannot be resolved
+	s.Add("", map[string]interface{}{"href": "/users/5", "auth_username": "api_mock", "type": "external"})
+	s.Login = "/users/5"
+	roles, resolved, err := utils.GetPCERoles(pce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved || !reflect.DeepEqual(roles, []string{"read_only"}) {
+		t.Errorf("roles are %v resolved %t, want [read_only] unresolved", roles, resolved)
+	}
+	utils.CheckPCECapabilities(pce, utils.CapRulesetWrite)
+
+	s.Add("", map[string]interface{}{"href": "/users/5", "auth_username": "api_mock", "type": "external", "effective_groups": []string{"ops"}})
+	roles, resolved, err = utils.GetPCERoles(pce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resolved || !reflect.DeepEqual(roles, []string{"read_only", "ruleset_manager"}) {
+		t.Errorf("roles are %v resolved %t, want [read_only ruleset_manager] resolved", roles, resolved)
+	}
+}
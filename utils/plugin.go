@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// PluginPrefix is the prefix of plugin executables. workloader-foo is run as workloader foo.
+const PluginPrefix = "workloader-"
+
+// Plugin is an external command. The optional manifest is a yaml file next to the executable with the same name (e.g., workloader-foo.yaml)
+// with short, long, and usage keys for the help text.
+type Plugin struct {
+	Name     string
+	Path     string
+	Manifest string
+	Short    string
+	Long     string
+	Usage    string
+}
+
+// pluginSettings are the global settings passed to plugins in WORKLOADER_PLUGIN_ environment variables and the type they are stored as.
+var pluginSettings = map[string]string{
+	"target_pce":             "string",
+	"target_org":             "string",
+	"target_member":          "string",
+	"update_pce":             "bool",
+	"simulate":               "bool",
+	"no_prompt":              "bool",
+	"debug":                  "bool",
+	"verbose":                "bool",
+	"notify":                 "bool",
+	"email_to":               "string",
+	"output_format":          "string",
+	"file_format":            "string",
+	"output_template_flag":   "string",
+	"output_dir_flag":        "string",
+	"encrypt_output_flag":    "string",
+	"log_format_flag":        "string",
+	"log_file_flag":          "string",
+	"log_max_size_flag":      "int",
+	"log_max_backups_flag":   "int",
+	"log_max_age_flag":       "duration",
+	"progress_flag":          "string",
+	"page_workers_flag":      "int",
+	"read_only_flag":         "bool",
+	"max_retries":            "int",
+	"rps":                    "float",
+	"connect_timeout_flag":   "duration",
+	"read_timeout_flag":      "duration",
+	"long_poll_timeout_flag": "duration",
+	"cache_ttl_flag":         "duration",
+	"no_cache_flag":          "bool",
+}
+
+// PluginsDir returns the plugin directory that is searched before PATH. The WORKLOADER_PLUGINS_DIR environment variable takes precedence over ~/.workloader/plugins.
+func PluginsDir() string {
+	if os.Getenv("WORKLOADER_PLUGINS_DIR") != "" {
+		return os.Getenv("WORKLOADER_PLUGINS_DIR")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".workloader", "plugins")
+	}
+	return filepath.Join(home, ".workloader", "plugins")
+}
+
+// FindPlugins returns the plugins in the plugin directory and PATH sorted by name. The first plugin found with a name is used.
+func FindPlugins() []Plugin {
+	dirs := append([]string{PluginsDir()}, filepath.SplitList(os.Getenv("PATH"))...)
+	found := make(map[string]Plugin)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := pluginName(e.Name())
+			if !ok {
+				continue
+			}
+			if _, ok := found[name]; ok {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if info, err := os.Stat(path); err != nil || info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+				continue
+			}
+			found[name] = readPluginManifest(Plugin{Name: name, Path: path})
+		}
+	}
+	plugins := []Plugin{}
+	for _, p := range found {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the command name for a plugin file name and false if the file is not a plugin
+func pluginName(fileName string) (string, bool) {
+	if !strings.HasPrefix(fileName, PluginPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(fileName, PluginPrefix)
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".yaml" || ext == ".yml":
+		return "", false
+	case runtime.GOOS == "windows" && ext != ".exe":
+		return "", false
+	case runtime.GOOS == "windows":
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+// readPluginManifest sets the help text from the manifest next to the plugin if there is one
+func readPluginManifest(p Plugin) Plugin {
+	base := strings.TrimSuffix(p.Path, filepath.Ext(p.Path))
+	if runtime.GOOS != "windows" {
+		base = p.Path
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		if _, err := os.Stat(base + ext); err != nil {
+			continue
+		}
+		v := viper.New()
+		v.SetConfigFile(base + ext)
+		if err := v.ReadInConfig(); err != nil {
+			LogDebug(fmt.Sprintf("reading plugin manifest %s - %s", base+ext, err))
+			break
+		}
+		p.Manifest, p.Short, p.Long, p.Usage = base+ext, v.GetString("short"), v.GetString("long"), v.GetString("usage")
+		break
+	}
+	if p.Short == "" {
+		p.Short = fmt.Sprintf("Plugin command (%s).", p.Path)
+	}
+	return p
+}
+
+// pluginSettingEnv returns the environment variable for a global setting passed to plugins
+func pluginSettingEnv(key string) string {
+	return "WORKLOADER_PLUGIN_" + strings.ToUpper(key)
+}
+
+// PluginEnv returns the environment for a plugin. It includes the config file, the workloader executable, and the global settings
+// so plugins use the same PCEs, logging, and output options as the command that ran them.
+func PluginEnv(p Plugin) []string {
+	env := os.Environ()
+	config := viper.ConfigFileUsed()
+	if abs, err := filepath.Abs(config); err == nil && config != "" {
+		config = abs
+	}
+	env = append(env, "ILLUMIO_CONFIG="+config, "WORKLOADER_PROFILE=", "WORKLOADER_PLUGIN="+p.Name)
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, "WORKLOADER_EXECUTABLE="+exe)
+	}
+	for key := range pluginSettings {
+		env = append(env, fmt.Sprintf("%s=%s", pluginSettingEnv(key), viper.GetString(key)))
+	}
+	return env
+}
+
+// PluginInit loads the config file and global settings passed by workloader so a plugin written in Go can use
+// GetTargetPCE, the log functions, and WriteOutput like a built-in command. It returns false when not run as a plugin.
+func PluginInit() bool {
+	if os.Getenv("WORKLOADER_PLUGIN") == "" {
+		return false
+	}
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(os.Getenv("ILLUMIO_CONFIG"))
+	viper.ReadInConfig()
+	for key, kind := range pluginSettings {
+		value, ok := os.LookupEnv(pluginSettingEnv(key))
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "bool":
+			viper.Set(key, value == "true")
+		case "int":
+			i, _ := strconv.Atoi(value)
+			viper.Set(key, i)
+		case "float":
+			f, _ := strconv.ParseFloat(value, 64)
+			viper.Set(key, f)
+		case "duration":
+			d, _ := time.ParseDuration(value)
+			viper.Set(key, d)
+		default:
+			viper.Set(key, value)
+		}
+	}
+	ConfigureLog()
+	return true
+}
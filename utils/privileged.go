@@ -0,0 +1,27 @@
+package utils
+
+import "strings"
+
+// IsPrivilegedUser returns true if the user is root or a Windows admin/system account
+func IsPrivilegedUser(user string) bool {
+	u := strings.ToLower(strings.TrimSpace(user))
+	switch u {
+	case "root", "system", "localsystem", "nt authority\\system", "administrator":
+		return true
+	}
+	return strings.HasSuffix(u, "\\administrator") || strings.HasSuffix(u, "\\system")
+}
+
+// ListensOnAllInterfaces returns true if the listening address is a wildcard address (e.g., 0.0.0.0 or ::)
+func ListensOnAllInterfaces(address string) bool {
+	switch strings.TrimSpace(address) {
+	case "0.0.0.0", "::", "[::]", "*":
+		return true
+	}
+	return false
+}
+
+// PrivilegedExposure returns true if a process is running as a privileged user and listening on all interfaces
+func PrivilegedExposure(user, address string) bool {
+	return IsPrivilegedUser(user) && ListensOnAllInterfaces(address)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ActiveProfile is the profile selected with --profile or WORKLOADER_PROFILE. It is blank when no profile is used.
+var ActiveProfile string
+
+// ProfilesDir returns the directory for named profiles. The WORKLOADER_PROFILES_DIR environment variable takes precedence over ~/.workloader/profiles.
+func ProfilesDir() string {
+	if os.Getenv("WORKLOADER_PROFILES_DIR") != "" {
+		return os.Getenv("WORKLOADER_PROFILES_DIR")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".workloader", "profiles")
+	}
+	return filepath.Join(home, ".workloader", "profiles")
+}
+
+// profileFromArgs returns the value of the --profile flag. The config file is set before cobra parses flags so the args are checked directly.
+func profileFromArgs(args []string) string {
+	return FlagFromArgs(args, "--profile")
+}
+
+// FlagFromArgs returns the value of a flag in args before the flags are parsed
+func FlagFromArgs(args []string, flag string) string {
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(a, flag+"=") {
+			return strings.TrimPrefix(a, flag+"=")
+		}
+	}
+	return ""
+}
+
+// ProfileConfigFile returns the config file for a profile. A profile is a yaml file, a directory with a pce.yaml, or the name of a directory in the profiles directory.
+// The directory for a named profile is created if it does not exist so pce-add can create the first PCE in it.
+func ProfileConfigFile(profile string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(profile))
+	if ext == ".yaml" || ext == ".yml" {
+		return profile, nil
+	}
+	if info, err := os.Stat(profile); err == nil && info.IsDir() {
+		return filepath.Join(profile, "pce.yaml"), nil
+	}
+	if strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("profile %s does not exist", profile)
+	}
+	dir := filepath.Join(ProfilesDir(), profile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating profile %s - %s", profile, err)
+	}
+	return filepath.Join(dir, "pce.yaml"), nil
+}
+
+// ConfigFile returns the config file to use. The --profile flag takes precedence over WORKLOADER_PROFILE, ILLUMIO_CONFIG, and ./pce.yaml.
+func ConfigFile(args []string) (string, error) {
+	ActiveProfile = profileFromArgs(args)
+	if ActiveProfile == "" {
+		ActiveProfile = os.Getenv("WORKLOADER_PROFILE")
+	}
+	if ActiveProfile != "" {
+		return ProfileConfigFile(ActiveProfile)
+	}
+	if os.Getenv("ILLUMIO_CONFIG") != "" {
+		return os.Getenv("ILLUMIO_CONFIG"), nil
+	}
+	return "./pce.yaml", nil
+}
+
+// GetProfiles returns the names of the profiles in the profiles directory
+func GetProfiles() ([]string, error) {
+	entries, err := os.ReadDir(ProfilesDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	profiles := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			if _, err := os.Stat(filepath.Join(ProfilesDir(), e.Name(), "pce.yaml")); err == nil {
+				profiles = append(profiles, e.Name())
+			}
+		}
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// OutputPath returns the path for an output file. Default file names are changed by the output template. Relative local file names
+// are placed in the --output-dir or the output_dir from the config file if it is set.
+func OutputPath(fileName string) string {
+	if IsRemoteOutput(fileName) || filepath.IsAbs(fileName) || strings.ContainsAny(fileName, `/\`) {
+		return fileName
+	}
+	fileName = templateOutputName(fileName)
+	if IsRemoteOutput(fileName) {
+		return fileName
+	}
+	if dir := OutputDir(); dir != "" && !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(dir, fileName)
+	}
+	if dir := filepath.Dir(fileName); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			LogError(fmt.Sprintf("creating output directory %s - %s", dir, err))
+		}
+	}
+	return fileName
+}
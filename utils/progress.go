@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// Progress modes for --progress
+const (
+	ProgressAuto  = "auto"
+	ProgressBar   = "bar"
+	ProgressPlain = "plain"
+	ProgressOff   = "off"
+)
+
+// progressBarWidth is the number of characters in the bar
+const progressBarWidth = 30
+
+// progressPlainInterval is how often plain mode reports a spinner or a slow bar
+const progressPlainInterval = 30 * time.Second
+
+// progressFrames are the spinner frames
+var progressFrames = []string{"|", "/", "-", "\\"}
+
+// progressLine tracks whether a bar is drawn on the current terminal line so log lines can clear it first
+var progressLine struct {
+	sync.Mutex
+	active bool
+}
+
+// clearProgressLine clears a bar from the terminal line before a log line is printed. The bar redraws on its next tick.
+func clearProgressLine() {
+	progressLine.Lock()
+	defer progressLine.Unlock()
+	if progressLine.active {
+		fmt.Print("\r\033[K")
+		progressLine.active = false
+	}
+}
+
+// ProgressMode returns the progress mode. The --progress flag takes precedence over WORKLOADER_PROGRESS and progress in pce.yaml.
+// Auto uses a bar on a terminal and plain log lines when stdout is redirected, in CI, or with --log-format json.
+func ProgressMode() string {
+	mode := strings.ToLower(viper.GetString("progress_flag"))
+	if mode == "" || mode == ProgressAuto {
+		mode = strings.ToLower(os.Getenv("WORKLOADER_PROGRESS"))
+	}
+	if mode == "" || mode == ProgressAuto {
+		mode = strings.ToLower(viper.GetString("progress"))
+	}
+	switch mode {
+	case ProgressBar, ProgressPlain, ProgressOff:
+		return mode
+	}
+	if os.Getenv("CI") != "" || !term.IsTerminal(int(os.Stdout.Fd())) || logFormat() == LogFormatJSON {
+		return ProgressPlain
+	}
+	return ProgressBar
+}
+
+// Progress reports progress of a long-running task. A total of 0 shows a spinner.
+// Bar mode redraws one line on the terminal. Plain mode prints a log line every 10% or 30 seconds. Off prints nothing.
+// The summary is always written to workloader.log when Done is called.
+type Progress struct {
+	mu        sync.Mutex
+	label     string
+	total     int
+	count     int
+	mode      string
+	start     time.Time
+	lastDraw  time.Time
+	lastPlain time.Time
+	plainPct  int
+	frame     int
+	stop      chan struct{}
+	done      bool
+}
+
+// NewProgress starts reporting progress for a task. Call Done when the task is finished.
+func NewProgress(label string, total int) *Progress {
+	p := &Progress{label: label, total: total, mode: ProgressMode(), start: time.Now(), lastPlain: time.Now(), stop: make(chan struct{})}
+	if p.mode == ProgressOff {
+		return p
+	}
+	p.mu.Lock()
+	p.draw(true)
+	p.mu.Unlock()
+	go p.tick()
+	return p
+}
+
+// tick redraws spinners and keeps the rate and eta current when the count does not change
+func (p *Progress) tick() {
+	interval := 200 * time.Millisecond
+	if p.mode == ProgressPlain {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.draw(false)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Increment adds one to the count
+func (p *Progress) Increment() {
+	p.Add(1)
+}
+
+// Add adds n to the count
+func (p *Progress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count += n
+	if p.mode != ProgressOff && time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.draw(false)
+	}
+}
+
+// rate returns the items per second
+func (p *Progress) rate() float64 {
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed == 0 {
+		return 0
+	}
+	return float64(p.count) / elapsed
+}
+
+// status returns the count, percent, rate, and eta
+func (p *Progress) status() string {
+	elapsed := time.Since(p.start).Round(time.Second)
+	if p.total <= 0 {
+		if p.count > 0 {
+			return fmt.Sprintf("%d done %.1f/s %s", p.count, p.rate(), elapsed)
+		}
+		return elapsed.String()
+	}
+	s := fmt.Sprintf("%d/%d %d%%", p.count, p.total, p.count*100/p.total)
+	if r := p.rate(); r > 0 {
+		eta := time.Duration(float64(p.total-p.count) / r * float64(time.Second)).Round(time.Second)
+		s = fmt.Sprintf("%s %.1f/s eta %s", s, r, eta)
+	}
+	return s
+}
+
+// draw renders the progress. The caller holds the lock.
+func (p *Progress) draw(force bool) {
+	if p.done {
+		return
+	}
+	p.lastDraw = time.Now()
+	switch p.mode {
+	case ProgressBar:
+		line := ""
+		if p.total > 0 {
+			filled := p.count * progressBarWidth / p.total
+			if filled > progressBarWidth {
+				filled = progressBarWidth
+			}
+			line = fmt.Sprintf("%s [%s%s] %s", p.label, strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), p.status())
+		} else {
+			line = fmt.Sprintf("%s %s %s", p.label, progressFrames[p.frame%len(progressFrames)], p.status())
+		}
+		progressLine.Lock()
+		fmt.Printf("\r%s [INFO] - %s\033[K", time.Now().Format("2006-01-02 15:04:05 "), line)
+		progressLine.active = true
+		progressLine.Unlock()
+	case ProgressPlain:
+		pct := -1
+		if p.total > 0 {
+			pct = p.count * 10 / p.total
+		}
+		if force || (pct > p.plainPct) || time.Since(p.lastPlain) >= progressPlainInterval {
+			if pct > p.plainPct {
+				p.plainPct = pct
+			}
+			p.lastPlain = time.Now()
+			printLog("INFO", fmt.Sprintf("%s - %s", p.label, p.status()), nil)
+		}
+	}
+}
+
+// Done stops the progress and writes the summary
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	close(p.stop)
+	summary := fmt.Sprintf("%s - completed %d in %s", p.label, p.count, time.Since(p.start).Round(time.Millisecond))
+	if p.total <= 0 && p.count == 0 {
+		summary = fmt.Sprintf("%s - completed in %s", p.label, time.Since(p.start).Round(time.Millisecond))
+	}
+	switch p.mode {
+	case ProgressBar:
+		if p.total > 0 {
+			p.count = p.total
+		}
+		p.draw(true)
+		progressLine.Lock()
+		fmt.Print("\r\n")
+		progressLine.active = false
+		progressLine.Unlock()
+	case ProgressPlain:
+		printLog("INFO", summary, nil)
+	}
+	p.done = true
+	writeLog("INFO", summary, nil)
+}
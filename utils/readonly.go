@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// readOnlyAllowedPosts are POST endpoints that query data without changing the PCE
+var readOnlyAllowedPosts = []string{"/traffic_flows/async_queries", "/traffic_flows/traffic_analysis_queries"}
+
+// readOnlyAllowedPuts are PUT endpoints that query data without changing the PCE. update_rules re-evaluates an async traffic query against draft policy.
+var readOnlyAllowedPuts = []string{"/update_rules"}
+
+// ReadOnly returns true if the --read-only flag, read_only in pce.yaml, or WORKLOADER_READ_ONLY is set
+func ReadOnly() bool {
+	return viper.GetBool("read_only_flag") || viper.GetBool("read_only") || strings.ToLower(os.Getenv("WORKLOADER_READ_ONLY")) == "true"
+}
+
+// readOnlyBlocked returns true if a request changes the PCE. Traffic queries and their draft policy updates are allowed.
+func readOnlyBlocked(r *http.Request) bool {
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+		return false
+	}
+	if r.Method == "POST" {
+		for _, p := range readOnlyAllowedPosts {
+			if strings.HasSuffix(r.URL.Path, p) {
+				return false
+			}
+		}
+	}
+	if r.Method == "PUT" && strings.Contains(r.URL.Path, "/traffic_flows/async_queries/") {
+		for _, p := range readOnlyAllowedPuts {
+			if strings.HasSuffix(r.URL.Path, p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// refuseReadOnly writes the response for a request blocked in read-only mode
+func refuseReadOnly(w http.ResponseWriter, r *http.Request, name string) {
+	msg := fmt.Sprintf("read-only mode - %s %s to %s was not sent", r.Method, r.URL.Path, name)
+	LogWarning(msg, false)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(w, `[{"token":"workloader_read_only","message":%q}]`, msg)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteSchemes are the supported remote output destinations
+var remoteSchemes = []string{"s3://", "gs://", "az://", "sftp://"}
+
+// multipartSize is the part size used for multipart and chunked uploads
+const multipartSize = 64 * 1024 * 1024
+
+// remoteClient is used for all remote output requests
+var remoteClient = &http.Client{Timeout: 10 * time.Minute}
+
+// IsRemoteOutput returns true if the output file is an object storage or sftp url
+func IsRemoteOutput(fileName string) bool {
+	for _, s := range remoteSchemes {
+		if strings.HasPrefix(strings.ToLower(fileName), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadOutput uploads a local file to an s3://bucket/key, gs://bucket/object, az://account/container/blob, or sftp://[user@]host[:port]/path url.
+// Credentials are from the standard chain of each provider.
+func UploadOutput(localFile, dest string) error {
+	f, err := os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	scheme, location := splitRemote(dest)
+	switch scheme {
+	case "s3":
+		return uploadS3(f, info.Size(), location)
+	case "gs":
+		return uploadGCS(f, info.Size(), location)
+	case "az":
+		return uploadAzure(f, info.Size(), location)
+	case "sftp":
+		return uploadSFTP(f, dest)
+	}
+	return fmt.Errorf("unsupported output destination %s", dest)
+}
+
+// localStagingFile returns the local file used to build output for a remote destination
+func localStagingFile(dest string) string {
+	_, location := splitRemote(dest)
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "@", "_")
+	return filepath.Join(os.TempDir(), fmt.Sprintf("workloader-%d-%s", os.Getpid(), replacer.Replace(location)))
+}
+
+// splitRemote splits the scheme from the rest of a remote url
+func splitRemote(dest string) (scheme, location string) {
+	x := strings.SplitN(dest, "://", 2)
+	if len(x) != 2 {
+		return "", dest
+	}
+	return strings.ToLower(x[0]), x[1]
+}
+
+// splitBucket splits the first path element from the remainder
+func splitBucket(location string) (bucket, key string, err error) {
+	x := strings.SplitN(location, "/", 2)
+	if len(x) != 2 || x[0] == "" || x[1] == "" {
+		return "", "", fmt.Errorf("%s must include a bucket and object name", location)
+	}
+	return x[0], x[1], nil
+}
+
+// readPart reads up to multipartSize bytes
+func readPart(r io.Reader) ([]byte, error) {
+	buf := make([]byte, multipartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// doRemote sends a request and returns the body and headers. Any non-2xx response is an error except the codes in allowed.
+func doRemote(req *http.Request, allowed ...int) ([]byte, *http.Response, error) {
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		for _, a := range allowed {
+			if resp.StatusCode == a {
+				return body, resp, nil
+			}
+		}
+		return body, resp, fmt.Errorf("%s %s - %d - %s", req.Method, req.URL.Host+req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, resp, nil
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const azureStorageResource = "https://storage.azure.com/"
+
+// uploadAzure uploads to blob storage. Files larger than the part size are uploaded as blocks and committed with a block list.
+func uploadAzure(r io.Reader, size int64, location string) error {
+	x := strings.SplitN(location, "/", 3)
+	if len(x) != 3 || x[0] == "" || x[1] == "" || x[2] == "" {
+		return fmt.Errorf("az://%s must be in the format az://account/container/blob", location)
+	}
+	blobURL := fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", x[0], url.PathEscape(x[1]), awsURIEncode(x[2], false))
+
+	// Use a sas token if provided, otherwise get a bearer token
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	token := ""
+	if sas == "" {
+		var err error
+		if token, err = azureStorageToken(); err != nil {
+			return err
+		}
+	}
+	send := func(query url.Values, headers map[string]string, data []byte) error {
+		reqURL := blobURL
+		q := query.Encode()
+		if sas != "" {
+			q = strings.TrimPrefix(q+"&"+sas, "&")
+		}
+		if q != "" {
+			reqURL = reqURL + "?" + q
+		}
+		req, err := http.NewRequest("PUT", reqURL, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-ms-version", "2021-08-06")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		_, _, err = doRemote(req)
+		return err
+	}
+
+	if size <= multipartSize {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return send(url.Values{}, map[string]string{"x-ms-blob-type": "BlockBlob", "x-ms-blob-content-type": "text/csv"}, data)
+	}
+
+	// Upload the blocks. Block ids must be the same length.
+	blockList := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{}
+	for i := 0; ; i++ {
+		data, err := readPart(r)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			break
+		}
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%06d", i)))
+		if err := send(url.Values{"comp": {"block"}, "blockid": {id}}, nil, data); err != nil {
+			return err
+		}
+		blockList.Latest = append(blockList.Latest, id)
+		LogInfo(fmt.Sprintf("uploaded block %d of az://%s", i+1, location), false)
+	}
+	body, err := xml.Marshal(blockList)
+	if err != nil {
+		return err
+	}
+	return send(url.Values{"comp": {"blocklist"}}, map[string]string{"x-ms-blob-content-type": "text/csv"}, body)
+}
+
+// azureStorageToken gets a token from a service principal, workload identity, or managed identity in that order
+func azureStorageToken() (string, error) {
+	tenantID, clientID := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID")
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("scope", azureStorageResource+".default")
+	switch {
+	case tenantID != "" && clientID != "" && os.Getenv("AZURE_CLIENT_SECRET") != "":
+		form.Set("client_secret", os.Getenv("AZURE_CLIENT_SECRET"))
+	case tenantID != "" && clientID != "" && os.Getenv("AZURE_FEDERATED_TOKEN_FILE") != "":
+		assertion, err := os.ReadFile(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+		if err != nil {
+			return "", err
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	default:
+		// Managed identity
+		q := url.Values{"api-version": {"2018-02-01"}, "resource": {azureStorageResource}}
+		if clientID != "" {
+			q.Set("client_id", clientID)
+		}
+		req, err := http.NewRequest("GET", "http://169.254.169.254/metadata/identity/oauth2/token?"+q.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata", "true")
+		token, err := bearerToken(req)
+		if err != nil {
+			return "", fmt.Errorf("no azure credentials in environment variables and managed identity is not available - %s", err)
+		}
+		return token, nil
+	}
+
+	authority := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authority == "" {
+		authority = "https://login.microsoftonline.com/"
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authority, "/"), url.PathEscape(tenantID)), bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return bearerToken(req)
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsCredentials is the subset of a service account or authorized user credentials file
+type gcsCredentials struct {
+	Type         string `json:"type"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// uploadGCS uploads to cloud storage. Files larger than the part size use a resumable upload.
+func uploadGCS(r io.Reader, size int64, location string) error {
+	bucket, object, err := splitBucket(location)
+	if err != nil {
+		return err
+	}
+	token, err := gcsToken()
+	if err != nil {
+		return err
+	}
+	uploadURL := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?name=%s", url.PathEscape(bucket), url.QueryEscape(object))
+
+	if size <= multipartSize {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest("POST", uploadURL+"&uploadType=media", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/csv")
+		_, _, err = doRemote(req)
+		return err
+	}
+
+	// Start the resumable session
+	req, err := http.NewRequest("POST", uploadURL+"&uploadType=resumable", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Upload-Content-Type", "text/csv")
+	req.Header.Set("X-Upload-Content-Length", fmt.Sprintf("%d", size))
+	_, resp, err := doRemote(req)
+	if err != nil {
+		return err
+	}
+	session := resp.Header.Get("Location")
+
+	// Upload the chunks. The part size is a multiple of 256 KiB as required. 308 means the chunk was accepted.
+	var offset int64
+	for offset < size {
+		data, err := readPart(r)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("gs://%s - file ended at %d of %d bytes", location, offset, size)
+		}
+		req, err := http.NewRequest("PUT", session, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(data))-1, size))
+		if _, _, err := doRemote(req, 308); err != nil {
+			return err
+		}
+		offset = offset + int64(len(data))
+		LogInfo(fmt.Sprintf("uploaded %d of %d bytes to gs://%s", offset, size, location), false)
+	}
+	return nil
+}
+
+// gcsToken gets a token from GOOGLE_APPLICATION_CREDENTIALS, the gcloud application default credentials, or the metadata server in that order
+func gcsToken() (string, error) {
+	credsFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if credsFile == "" {
+		dir := os.Getenv("CLOUDSDK_CONFIG")
+		if dir == "" && runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if dir == "" {
+			home, _ := os.UserHomeDir()
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "application_default_credentials.json")); err == nil {
+			credsFile = filepath.Join(dir, "application_default_credentials.json")
+		}
+	}
+	if credsFile == "" {
+		return gcsMetadataToken()
+	}
+
+	data, err := os.ReadFile(credsFile)
+	if err != nil {
+		return "", err
+	}
+	var creds gcsCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("parsing %s - %s", credsFile, err)
+	}
+	form := url.Values{}
+	tokenURI := "https://oauth2.googleapis.com/token"
+	switch creds.Type {
+	case "service_account":
+		if creds.TokenURI != "" {
+			tokenURI = creds.TokenURI
+		}
+		assertion, err := gcsSignJWT(creds, tokenURI)
+		if err != nil {
+			return "", err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	default:
+		return "", fmt.Errorf("%s is type %s. only service_account and authorized_user credentials are supported", credsFile, creds.Type)
+	}
+	req, err := http.NewRequest("POST", tokenURI, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return bearerToken(req)
+}
+
+// gcsMetadataToken gets a token for the attached service account
+func gcsMetadataToken() (string, error) {
+	req, err := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(gcsScope), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	token, err := bearerToken(req)
+	if err != nil {
+		return "", fmt.Errorf("no google credentials file found and metadata server is not reachable - %s", err)
+	}
+	return token, nil
+}
+
+// gcsSignJWT creates the signed assertion for the service account
+func gcsSignJWT(creds gcsCredentials, aud string) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid private key in service account key file")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	rsaKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account private key is not rsa")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": creds.ClientEmail, "scope": gcsScope, "aud": aud, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// bearerToken sends a token request and returns the access_token from the response
+func bearerToken(req *http.Request) (string, error) {
+	body, _, err := doRemote(req)
+	if err != nil {
+		return "", err
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return "", err
+	}
+	return t.AccessToken, nil
+}
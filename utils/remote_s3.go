@@ -0,0 +1,395 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3Creds are the credentials used to sign s3 requests
+type s3Creds struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// uploadS3 uploads to s3. Files larger than the part size use a multipart upload.
+func uploadS3(r io.Reader, size int64, location string) error {
+	bucket, key, err := splitBucket(location)
+	if err != nil {
+		return err
+	}
+	creds, err := s3CredentialChain()
+	if err != nil {
+		return err
+	}
+	region, err := s3BucketRegion(bucket)
+	if err != nil {
+		return err
+	}
+
+	if size <= multipartSize {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, _, err = creds.s3Request("PUT", region, bucket, key, nil, data)
+		return err
+	}
+
+	// Start the multipart upload
+	body, _, err := creds.s3Request("POST", region, bucket, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	var initResp struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &initResp); err != nil {
+		return err
+	}
+	abort := func(e error) error {
+		creds.s3Request("DELETE", region, bucket, key, url.Values{"uploadId": {initResp.UploadID}}, nil)
+		return e
+	}
+
+	// Upload the parts
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	complete := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for partNumber := 1; ; partNumber++ {
+		data, err := readPart(r)
+		if err != nil {
+			return abort(err)
+		}
+		if len(data) == 0 {
+			break
+		}
+		_, resp, err := creds.s3Request("PUT", region, bucket, key, url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {initResp.UploadID}}, data)
+		if err != nil {
+			return abort(err)
+		}
+		complete.Parts = append(complete.Parts, part{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+		LogInfo(fmt.Sprintf("uploaded part %d of s3://%s/%s", partNumber, bucket, key), false)
+	}
+
+	// Complete the upload. Errors can be returned with a 200 status code.
+	completeBody, err := xml.Marshal(complete)
+	if err != nil {
+		return abort(err)
+	}
+	body, _, err = creds.s3Request("POST", region, bucket, key, url.Values{"uploadId": {initResp.UploadID}}, completeBody)
+	if err != nil {
+		return abort(err)
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		return abort(fmt.Errorf("completing multipart upload - %s", string(body)))
+	}
+	return nil
+}
+
+// s3Request sends a signature version 4 signed request to s3
+func (c s3Creds) s3Request(method, region, bucket, key string, query url.Values, payload []byte) ([]byte, *http.Response, error) {
+
+	// Use virtual hosted style unless the bucket has a period or a custom endpoint is used
+	scheme, host, path := "https", fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region), "/"+key
+	if strings.Contains(bucket, ".") {
+		host, path = fmt.Sprintf("s3.%s.amazonaws.com", region), "/"+bucket+"/"+key
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_S3"); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		scheme, host, path = u.Scheme, u.Host, "/"+bucket+"/"+key
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	canonicalURI := awsURIEncode(path, false)
+	canonicalQuery := awsCanonicalQuery(query)
+	headerNames := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headerValues := map[string]string{"host": host, "x-amz-content-sha256": payloadHash, "x-amz-date": amzDate}
+	if c.SessionToken != "" {
+		headerNames = append(headerNames, "x-amz-security-token")
+		headerValues["x-amz-security-token"] = c.SessionToken
+	}
+	canonicalHeaders := ""
+	for _, h := range headerNames {
+		canonicalHeaders = canonicalHeaders + h + ":" + headerValues[h] + "\n"
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{method, canonicalURI, canonicalQuery, canonicalHeaders, signedHeaders, payloadHash}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	reqURL := fmt.Sprintf("%s://%s%s", scheme, host, canonicalURI)
+	if canonicalQuery != "" {
+		reqURL = reqURL + "?" + canonicalQuery
+	}
+	req, err := http.NewRequest(method, reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, h := range headerNames[1:] {
+		req.Header.Set(h, headerValues[h])
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.AccessKeyID, scope, signedHeaders, signature))
+	return doRemote(req)
+}
+
+// s3BucketRegion gets the region from the environment or the bucket location header
+func s3BucketRegion(bucket string) (string, error) {
+	for _, e := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if os.Getenv(e) != "" {
+			return os.Getenv(e), nil
+		}
+	}
+	if os.Getenv("AWS_ENDPOINT_URL_S3") != "" {
+		return "us-east-1", nil
+	}
+	req, err := http.NewRequest("HEAD", fmt.Sprintf("https://s3.amazonaws.com/%s", url.PathEscape(bucket)), nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 30 * time.Second, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if region := resp.Header.Get("x-amz-bucket-region"); region != "" {
+		return region, nil
+	}
+	return "", fmt.Errorf("cannot determine region of s3 bucket %s. set the AWS_REGION environment variable", bucket)
+}
+
+// s3CredentialChain gets credentials from the environment, web identity, shared credentials file, container, or instance metadata in that order
+func s3CredentialChain() (s3Creds, error) {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return s3Creds{AccessKeyID: os.Getenv("AWS_ACCESS_KEY_ID"), SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "" {
+		return s3WebIdentityCreds()
+	}
+	if creds, err := s3SharedCreds(); err == nil {
+		return creds, nil
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return s3EndpointCreds("http://169.254.170.2"+uri, nil)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return s3EndpointCreds(uri, map[string]string{"Authorization": os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")})
+	}
+	creds, err := s3InstanceCreds()
+	if err != nil {
+		return creds, fmt.Errorf("no aws credentials found in environment variables, shared credentials file, container, or instance metadata - %s", err)
+	}
+	return creds, nil
+}
+
+// s3SharedCreds reads the profile from the shared credentials file
+func s3SharedCreds() (s3Creds, error) {
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	credsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return s3Creds{}, err
+		}
+		credsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(credsFile)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	defer f.Close()
+
+	creds := s3Creds{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if section != profile || len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(kv[1])
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(kv[1])
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(kv[1])
+		}
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return s3Creds{}, fmt.Errorf("profile %s not found in %s", profile, credsFile)
+	}
+	return creds, nil
+}
+
+// s3WebIdentityCreds exchanges the web identity token for role credentials
+func s3WebIdentityCreds() (s3Creds, error) {
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return s3Creds{}, err
+	}
+	params := url.Values{}
+	params.Set("Action", "AssumeRoleWithWebIdentity")
+	params.Set("Version", "2011-06-15")
+	params.Set("RoleArn", os.Getenv("AWS_ROLE_ARN"))
+	params.Set("RoleSessionName", fmt.Sprintf("workloader-%d", time.Now().Unix()))
+	params.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	req, err := http.NewRequest("GET", "https://sts.amazonaws.com/?"+params.Encode(), nil)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	body, _, err := doRemote(req)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	var resp struct {
+		AccessKeyID     string `xml:"AssumeRoleWithWebIdentityResult>Credentials>AccessKeyId"`
+		SecretAccessKey string `xml:"AssumeRoleWithWebIdentityResult>Credentials>SecretAccessKey"`
+		SessionToken    string `xml:"AssumeRoleWithWebIdentityResult>Credentials>SessionToken"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return s3Creds{}, err
+	}
+	return s3Creds{AccessKeyID: resp.AccessKeyID, SecretAccessKey: resp.SecretAccessKey, SessionToken: resp.SessionToken}, nil
+}
+
+// s3EndpointCreds gets credentials from the container credentials endpoint
+func s3EndpointCreds(endpoint string, headers map[string]string) (s3Creds, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	body, _, err := doRemote(req)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	var creds s3Creds
+	err = json.Unmarshal(body, &creds)
+	return creds, err
+}
+
+// s3InstanceCreds gets the instance profile credentials from the instance metadata service (IMDSv2)
+func s3InstanceCreds() (s3Creds, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	req, err := http.NewRequest("PUT", "http://169.254.169.254/latest/api/token", nil)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	resp, err := client.Do(req)
+	if err != nil {
+		return s3Creds{}, err
+	}
+	token, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return s3Creds{}, err
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest("GET", "http://169.254.169.254/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		body, _, err := doRemote(req)
+		return body, err
+	}
+	role, err := get("")
+	if err != nil {
+		return s3Creds{}, err
+	}
+	body, err := get(strings.TrimSpace(strings.Split(string(role), "\n")[0]))
+	if err != nil {
+		return s3Creds{}, err
+	}
+	var creds s3Creds
+	err = json.Unmarshal(body, &creds)
+	return creds, err
+}
+
+// awsCanonicalQuery sorts and encodes query parameters for signing
+func awsCanonicalQuery(query url.Values) string {
+	keys := []string{}
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := []string{}
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters and, if encodeSlash is false, slashes
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
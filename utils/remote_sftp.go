@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftp packet types and flags (draft-ietf-secsh-filexfer-02, version 3)
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpWrite   = 6
+	sftpStatus  = 101
+	sftpHandle  = 102
+
+	sftpWriteFlags = 0x02 | 0x08 | 0x10 // write, create, truncate
+	sftpChunkSize  = 32 * 1024
+)
+
+// sftpConn is an sftp session over an ssh subsystem
+type sftpConn struct {
+	w  io.WriteCloser
+	r  io.Reader
+	id uint32
+}
+
+// uploadSFTP uploads to sftp://[user[:password]@]host[:port]/path. Paths are absolute. Use /~/path for a path relative to the home directory.
+// Authentication tries the ssh agent, the default private keys in ~/.ssh, and the password from the url or SFTP_PASSWORD environment variable. The host key must be in ~/.ssh/known_hosts.
+func uploadSFTP(r io.Reader, dest string) error {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return err
+	}
+	remotePath := strings.TrimPrefix(u.Path, "/~/")
+	if u.Path == "" || strings.HasSuffix(remotePath, "/") {
+		return fmt.Errorf("%s must include a file name", dest)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	config, err := sftpClientConfig(u)
+	if err != nil {
+		return err
+	}
+	client, err := ssh.Dial("tcp", host, config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	rd, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return err
+	}
+	conn := &sftpConn{w: w, r: rd}
+
+	// Initialize
+	if err := conn.send(sftpInit, []byte{0, 0, 0, 3}); err != nil {
+		return err
+	}
+	if t, _, err := conn.recv(); err != nil {
+		return err
+	} else if t != sftpVersion {
+		return fmt.Errorf("unexpected sftp response type %d to init", t)
+	}
+
+	// Open the file
+	payload := conn.nextID()
+	payload = appendString(payload, []byte(remotePath))
+	payload = appendUint32(payload, sftpWriteFlags)
+	payload = appendUint32(payload, 0)
+	if err := conn.send(sftpOpen, payload); err != nil {
+		return err
+	}
+	t, data, err := conn.recv()
+	if err != nil {
+		return err
+	}
+	if t != sftpHandle {
+		return fmt.Errorf("opening %s - %s", remotePath, sftpStatusError(data))
+	}
+	handle := data[8 : 8+binary.BigEndian.Uint32(data[4:8])]
+
+	// Write the chunks
+	buf := make([]byte, sftpChunkSize)
+	var offset uint64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			payload := conn.nextID()
+			payload = appendString(payload, handle)
+			payload = appendUint64(payload, offset)
+			payload = appendString(payload, buf[:n])
+			if err := conn.send(sftpWrite, payload); err != nil {
+				return err
+			}
+			if err := conn.status(); err != nil {
+				return fmt.Errorf("writing %s - %s", remotePath, err)
+			}
+			offset = offset + uint64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	// Close the file
+	if err := conn.send(sftpClose, appendString(conn.nextID(), handle)); err != nil {
+		return err
+	}
+	return conn.status()
+}
+
+// sftpClientConfig builds the ssh client config with the available authentication methods
+func sftpClientConfig(u *url.URL) (*ssh.ClientConfig, error) {
+	username := u.User.Username()
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		username = current.Username
+	}
+
+	auth := []ssh.AuthMethod{}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	signers := []ssh.Signer{}
+	for _, k := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", k))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		password = os.Getenv("SFTP_PASSWORD")
+	}
+	if password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts - %s. add the sftp server host key with ssh-keyscan", err)
+	}
+
+	return &ssh.ClientConfig{User: username, Auth: auth, HostKeyCallback: hostKeyCallback, Timeout: 30 * time.Second}, nil
+}
+
+// nextID increments the request id and returns it as the start of a payload
+func (c *sftpConn) nextID() []byte {
+	c.id++
+	return appendUint32(nil, c.id)
+}
+
+// send writes a packet
+func (c *sftpConn) send(packetType byte, payload []byte) error {
+	packet := appendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, packetType)
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+// recv reads a packet and returns the type and payload
+func (c *sftpConn) recv() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:4])-1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// status reads a status packet and returns an error if it is not ok
+func (c *sftpConn) status() error {
+	t, data, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if t != sftpStatus {
+		return fmt.Errorf("unexpected sftp response type %d", t)
+	}
+	if binary.BigEndian.Uint32(data[4:8]) != 0 {
+		return fmt.Errorf("%s", sftpStatusError(data))
+	}
+	return nil
+}
+
+// sftpStatusError returns the message from a status packet
+func sftpStatusError(data []byte) string {
+	if len(data) < 12 {
+		return "invalid sftp status"
+	}
+	code := binary.BigEndian.Uint32(data[4:8])
+	msgLen := binary.BigEndian.Uint32(data[8:12])
+	if uint32(len(data)) < 12+msgLen {
+		return fmt.Sprintf("sftp status %d", code)
+	}
+	return fmt.Sprintf("sftp status %d - %s", code, string(data[12:12+msgLen]))
+}
+
+// appendString appends an sftp length-prefixed string
+func appendString(b, s []byte) []byte {
+	b = appendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendUint32 appends a big endian uint32
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendUint64 appends a big endian uint64
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
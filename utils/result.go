@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Exit codes. Every command exits with one of these so pipelines can branch on the outcome.
+const (
+	ExitSuccess    = 0 // the command completed
+	ExitError      = 1 // an error that is not one of the types below
+	ExitValidation = 2 // invalid flags, arguments, or input files
+	ExitPartial    = 3 // the command completed but some items or PCEs failed
+	ExitAPI        = 4 // PCE api, connection, or authentication failure
+	ExitConfig     = 5 // missing or invalid pce.yaml or environment settings
+)
+
+// exitStatus is the status in the result file for each exit code
+var exitStatus = map[int]string{
+	ExitSuccess:    "success",
+	ExitError:      "error",
+	ExitValidation: "validation_error",
+	ExitPartial:    "partial_failure",
+	ExitAPI:        "api_error",
+	ExitConfig:     "config_error",
+}
+
+// Errors logged with LogError without a code are classified by their message
+var (
+	apiErrorPattern        = regexp.MustCompile(`(?i)status code|unauthorized|authenticat|forbidden|x509|connection refused|connection reset|no such host|i/o timeout|deadline exceeded`)
+	configErrorPattern     = regexp.MustCompile(`(?i)pce\.yaml|no pce set|default pce|could not retrieve .* pce|env variable`)
+	validationErrorPattern = regexp.MustCompile(`(?i)\binvalid\b|must be|cannot be used|is required|are required|not a valid|unknown (flag|command|header)|accepts? \d+ arg`)
+)
+
+// Result is written to the --result-file when the command exits
+type Result struct {
+	Command         string         `json:"command"`
+	PCE             string         `json:"pce"`
+	Status          string         `json:"status"`
+	ExitCode        int            `json:"exit_code"`
+	Started         string         `json:"started"`
+	Finished        string         `json:"finished"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	UpdatePCE       bool           `json:"update_pce"`
+	OutputFiles     []string       `json:"output_files"`
+	Warnings        int            `json:"warnings"`
+	Failures        int            `json:"failures"`
+	Errors          []string       `json:"errors"`
+	Objects         map[string]int `json:"objects"`
+	JournalRunID    string         `json:"journal_run_id,omitempty"`
+}
+
+// result tracks the running command for the exit code and --result-file
+var result struct {
+	command  string
+	start    time.Time
+	code     int
+	apiError bool
+	failures int
+	errors   []string
+	files    []string
+	written  bool
+}
+
+// ResultFile returns the result file from the --result-file flag or WORKLOADER_RESULT_FILE
+func ResultFile() string {
+	if viper.GetString("result_file_flag") != "" {
+		return viper.GetString("result_file_flag")
+	}
+	return os.Getenv("WORKLOADER_RESULT_FILE")
+}
+
+// StartResult starts tracking the command for the result file. It is called before the command runs and by LogStartCommand.
+// Commands started by another command (e.g., wkld-import run by a sync command) are ignored.
+func StartResult(command string) {
+	if result.command != "" {
+		return
+	}
+	result.command = command
+	result.start = time.Now()
+}
+
+// resultOutputFile adds an output file to the result
+func resultOutputFile(file string) {
+	for _, f := range result.files {
+		if f == file {
+			return
+		}
+	}
+	result.files = append(result.files, file)
+}
+
+// SetExitCode sets the exit code of a command that completes. A higher code is kept over a lower one.
+func SetExitCode(code int) {
+	if code > result.code {
+		result.code = code
+	}
+}
+
+// RecordFailure records an item that failed in a command that continues with the other items. The command exits with ExitPartial
+// and the message is in the errors of the result file. Callers still log the failure.
+func RecordFailure(msg string) {
+	result.failures++
+	result.errors = append(result.errors, msg)
+	SetExitCode(ExitPartial)
+}
+
+// ExitCode returns the exit code of the command
+func ExitCode() int {
+	return result.code
+}
+
+// errorExitCode returns the exit code for an error logged without a code
+func errorExitCode(msg string) int {
+	switch {
+	case apiErrorPattern.MatchString(msg):
+		return ExitAPI
+	case configErrorPattern.MatchString(msg):
+		return ExitConfig
+	case validationErrorPattern.MatchString(msg):
+		return ExitValidation
+	case result.apiError:
+		return ExitAPI
+	}
+	return ExitError
+}
+
+// WriteResultFile writes the result of the command to the --result-file. It is written once, when the command exits.
+func WriteResultFile(command string) {
+	file := ResultFile()
+	if file == "" || result.written {
+		return
+	}
+	result.written = true
+	if result.command != "" {
+		command = result.command
+	}
+	start := result.start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	r := Result{
+		Command:         command,
+		PCE:             logPCE(),
+		Status:          exitStatus[result.code],
+		ExitCode:        result.code,
+		Started:         start.Format(time.RFC3339),
+		Finished:        time.Now().Format(time.RFC3339),
+		DurationSeconds: time.Since(start).Round(time.Millisecond).Seconds(),
+		UpdatePCE:       viper.GetBool("update_pce"),
+		OutputFiles:     result.files,
+		Warnings:        notification.warnings,
+		Failures:        result.failures,
+		Errors:          result.errors,
+		Objects:         make(map[string]int),
+		JournalRunID:    JournalRunID(),
+	}
+	if r.OutputFiles == nil {
+		r.OutputFiles = []string{}
+	}
+	if r.Errors == nil {
+		r.Errors = []string{}
+	}
+	for k, v := range events.objects {
+		r.Objects[strings.TrimPrefix(k, "object.")] = v
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		writeLog("WARNING", "writing result file - "+err.Error(), nil)
+		return
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		writeLog("WARNING", "writing result file "+file+" - "+err.Error(), nil)
+	}
+}
+
+// Exit writes the result file and exits. A code of ExitSuccess exits with the code of the command (e.g., ExitPartial after RecordFailure).
+func Exit(command string, code int) {
+	if code != ExitSuccess {
+		result.code = code
+	}
+	WriteResultFile(command)
+	os.Exit(result.code)
+}
+
+// exitError writes the result file and exits for an error logged with LogError
+func exitError(msg string, code int) {
+	if code == 0 {
+		code = errorExitCode(msg)
+	}
+	result.code = code
+	result.errors = append(result.errors, msg)
+	WriteResultFile(currentCommand)
+	os.Exit(code)
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ServiceNowConfig holds the settings used to create ServiceNow records
+type ServiceNowConfig struct {
+	Instance        string // e.g., company.service-now.com
+	User            string
+	Password        string
+	AssignmentGroup string
+}
+
+// GetServiceNowConfig returns the ServiceNow settings. Environment variables (WORKLOADER_SERVICENOW_INSTANCE, WORKLOADER_SERVICENOW_USER,
+// WORKLOADER_SERVICENOW_PASSWORD, WORKLOADER_SERVICENOW_ASSIGNMENT_GROUP) take precedence over the servicenow_instance, servicenow_user,
+// servicenow_password, and servicenow_assignment_group keys in pce.yaml.
+func GetServiceNowConfig() (ServiceNowConfig, error) {
+	c := ServiceNowConfig{}
+	values := []*string{&c.Instance, &c.User, &c.Password, &c.AssignmentGroup}
+	for i, key := range []string{"servicenow_instance", "servicenow_user", "servicenow_password", "servicenow_assignment_group"} {
+		if env := os.Getenv("WORKLOADER_" + strings.ToUpper(key)); env != "" {
+			*values[i] = env
+		} else if viper.IsSet(key) {
+			*values[i] = viper.GetString(key)
+		}
+	}
+	if c.Instance == "" || c.User == "" {
+		return c, fmt.Errorf("servicenow instance and user are not set. set servicenow_instance and servicenow_user in pce.yaml or the WORKLOADER_SERVICENOW_INSTANCE and WORKLOADER_SERVICENOW_USER environment variables")
+	}
+	c.Instance = strings.TrimSuffix(strings.TrimPrefix(c.Instance, "https://"), "/")
+	return c, nil
+}
+
+// ServiceNowRecord is a change request or incident
+type ServiceNowRecord struct {
+	Table    string `json:"-"`
+	SysID    string `json:"sys_id"`
+	Number   string `json:"number"`
+	State    string `json:"state"`
+	Approval string `json:"approval"`
+}
+
+// call sends a request to the ServiceNow table api
+func (c ServiceNowConfig) call(method, path string, payload interface{}, record *ServiceNowRecord) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("https://%s/api/now/table/%s", c.Instance, path), body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.User, c.Password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("servicenow %s %s - %d - %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if record == nil {
+		return nil
+	}
+	var result struct {
+		Result ServiceNowRecord `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+	table := record.Table
+	*record = result.Result
+	record.Table = table
+	return nil
+}
+
+// CreateServiceNowRecord creates a change request (table change_request) or incident (table incident).
+func CreateServiceNowRecord(table, shortDescription, description string) (ServiceNowRecord, error) {
+	c, err := GetServiceNowConfig()
+	if err != nil {
+		return ServiceNowRecord{}, err
+	}
+	payload := map[string]string{"short_description": shortDescription, "description": description}
+	if c.AssignmentGroup != "" {
+		payload["assignment_group"] = c.AssignmentGroup
+	}
+	if table == "change_request" {
+		payload["type"] = "normal"
+	}
+	record := ServiceNowRecord{Table: table}
+	err = c.call("POST", table, payload, &record)
+	return record, err
+}
+
+// AddServiceNowWorkNote adds a work note to a record
+func AddServiceNowWorkNote(record ServiceNowRecord, note string) error {
+	c, err := GetServiceNowConfig()
+	if err != nil {
+		return err
+	}
+	return c.call("PATCH", fmt.Sprintf("%s/%s", record.Table, record.SysID), map[string]string{"work_notes": note}, nil)
+}
+
+// WaitForServiceNowApproval polls a change request until the approval is approved, rejected, or the timeout is reached.
+// An error is returned if the change is rejected or the timeout is reached.
+func WaitForServiceNowApproval(record ServiceNowRecord, pollInterval, timeout time.Duration) error {
+	c, err := GetServiceNowConfig()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		current := ServiceNowRecord{Table: record.Table}
+		if err := c.call("GET", fmt.Sprintf("%s/%s?sysparm_fields=sys_id,number,state,approval", record.Table, record.SysID), nil, &current); err != nil {
+			return err
+		}
+		switch strings.ToLower(current.Approval) {
+		case "approved":
+			LogInfo(fmt.Sprintf("%s is approved", record.Number), true)
+			return nil
+		case "rejected":
+			return fmt.Errorf("%s was rejected", record.Number)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s was not approved within %s - approval state is %s", record.Number, timeout, current.Approval)
+		}
+		LogInfo(fmt.Sprintf("%s approval state is %s. checking again in %s.", record.Number, current.Approval, pollInterval), true)
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// AnnotationSimulate is the cobra annotation for commands that only change the PCE with --update-pce. Their writes are simulated when
+// --update-pce is not set so dry runs never change the PCE. Commands without it (e.g., get-pk creates a pairing key and pce-remove revokes api keys) send their writes.
+const AnnotationSimulate = "simulate"
+
+// PlannedChange is a write that was simulated instead of sent to the PCE
+type PlannedChange struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	ObjectType string `json:"object_type"`
+	Href       string `json:"href"`
+	Body       string `json:"body"`
+}
+
+// planned is the writes simulated in this run
+var planned struct {
+	sync.Mutex
+	changes []PlannedChange
+}
+
+// Simulating returns true if writes are simulated. Writes are simulated when --update-pce is not set so dry runs never change the PCE.
+// Read-only mode takes precedence and refuses writes instead.
+func Simulating() bool {
+	return viper.GetBool("simulate") && !ReadOnly()
+}
+
+// PlannedChanges returns the writes simulated in this run
+func PlannedChanges() []PlannedChange {
+	planned.Lock()
+	defer planned.Unlock()
+	return append([]PlannedChange{}, planned.changes...)
+}
+
+// simulateWrite records a write as a planned change and responds as the PCE would so the command continues. Created objects get a simulated href.
+func (f *pceForwarder) simulateWrite(w http.ResponseWriter, r *http.Request, body []byte) {
+	path := journalHref(r.URL.Path)
+	href, objectType := "", journalObjectType(path+"/x")
+	switch {
+	case r.Method == "PUT" || r.Method == "DELETE":
+		href, objectType = path, journalObjectType(path)
+	case journalBulkAction(r.URL.Path) != "":
+		objectType = "workloads"
+	}
+	planned.Lock()
+	planned.changes = append(planned.changes, PlannedChange{Method: r.Method, Path: path, ObjectType: objectType, Href: href, Body: string(body)})
+	n := len(planned.changes)
+	planned.Unlock()
+	LogInfoFields(fmt.Sprintf("%s - simulated %s %s. the request was not sent because --update-pce is not set.", f.name, r.Method, path), Fields{"method": r.Method, "path": path, "simulated": "true"}, false)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Workloader-Simulated", "true")
+	switch {
+	case r.Method != "POST":
+		w.WriteHeader(http.StatusNoContent)
+	case journalBulkAction(r.URL.Path) != "":
+		// Bulk requests return a status for each workload
+		var objects []map[string]interface{}
+		json.Unmarshal(body, &objects)
+		status := strings.TrimPrefix(journalBulkAction(r.URL.Path), "bulk_") + "d"
+		results := []map[string]string{}
+		for i, o := range objects {
+			h, _ := o["href"].(string)
+			if h == "" {
+				h = fmt.Sprintf("%s/simulated-%d-%d", strings.TrimSuffix(path, "/"+journalBulkAction(r.URL.Path)), n, i)
+			}
+			results = append(results, map[string]string{"href": h, "status": status})
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(results)
+	default:
+		// Other posts return the request with a simulated href
+		object := make(map[string]interface{})
+		json.Unmarshal(body, &object)
+		object["href"] = fmt.Sprintf("%s/simulated-%d", path, n)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(object)
+	}
+}
+
+// writePlannedChanges writes the simulated writes of the command to an output file and to the approval file in a review run
+func writePlannedChanges(commandName string) {
+	changes := PlannedChanges()
+	if ApprovalReview() {
+		writeApprovalFile(commandName, changes)
+	}
+	if len(changes) == 0 {
+		return
+	}
+	data := [][]string{{"method", "object_type", "href", "path", "body"}}
+	for _, c := range changes {
+		data = append(data, []string{c.Method, c.ObjectType, c.Href, c.Path, c.Body})
+	}
+	if !ApprovalReview() {
+		LogInfo(fmt.Sprintf("%d planned changes were simulated and not sent to the pce. run with --update-pce to make them.", len(changes)), true)
+	}
+	WriteOutput(data, data, fmt.Sprintf("workloader-%s-planned-changes-%s.csv", commandName, time.Now().Format("20060102_150405")))
+}
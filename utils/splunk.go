@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// HECConfig holds the settings used to send events to a Splunk HTTP Event Collector
+type HECConfig struct {
+	URL        string
+	Token      string
+	SourceType string
+	Index      string
+	Insecure   bool
+}
+
+// hecEvent is the HEC event format
+type hecEvent struct {
+	Time       int64             `json:"time"`
+	Source     string            `json:"source"`
+	SourceType string            `json:"sourcetype,omitempty"`
+	Index      string            `json:"index,omitempty"`
+	Event      map[string]string `json:"event"`
+}
+
+// SendHEC sends each row of csv data after the header row as an event to a Splunk HTTP Event Collector.
+// The headers are the event field names. The token defaults to the SPLUNK_HEC_TOKEN environment variable.
+// The source is workloader and each event includes the command. Events are sent in batches of 500.
+func SendHEC(c HECConfig, command string, data [][]string) error {
+	if len(data) < 2 {
+		return nil
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("SPLUNK_HEC_TOKEN")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("splunk hec token is not set. use the flag or the SPLUNK_HEC_TOKEN environment variable")
+	}
+	url := strings.TrimSuffix(c.URL, "/")
+	if !strings.Contains(url, "/services/collector") {
+		url = url + "/services/collector/event"
+	}
+	client := &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}, Proxy: http.ProxyFromEnvironment}}
+
+	now := time.Now().Unix()
+	var body bytes.Buffer
+	sent := 0
+	for i, row := range data[1:] {
+		event := hecEvent{Time: now, Source: "workloader", SourceType: c.SourceType, Index: c.Index, Event: make(map[string]string)}
+		event.Event["workloader_command"] = command
+		for n, header := range data[0] {
+			if n < len(row) {
+				event.Event[header] = row[n]
+			}
+		}
+		e, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		body.Write(e)
+		body.WriteString("\n")
+
+		if (i+1)%500 != 0 && i != len(data)-2 {
+			continue
+		}
+		req, err := http.NewRequest("POST", url, &body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Splunk "+c.Token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return fmt.Errorf("splunk hec returned %d after %d events - %s", resp.StatusCode, sent, string(respBody))
+		}
+		sent = i + 1
+		body.Reset()
+	}
+	LogInfo(fmt.Sprintf("sent %d events to splunk hec", sent), true)
+	return nil
+}
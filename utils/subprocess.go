@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// RunWorkloader runs a workloader command as a separate process in dir and returns the combined output. The command uses a copy of the config file
+// in dir with settings added (e.g., output_dir) so it can update the config without changing the caller's config. The copy is removed when the
+// command finishes. workloader.log is written in dir even if a log file is set.
+func RunWorkloader(ctx context.Context, dir string, settings map[string]interface{}, args ...string) ([]byte, error) {
+	c, cleanup, err := WorkloaderCommand(ctx, dir, settings, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return c.CombinedOutput()
+}
+
+// PartialFailure returns true if a workloader command run as a separate process exited with ExitPartial
+func PartialFailure(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == ExitPartial
+}
+
+// WorkloaderCommand returns a workloader command to run as a separate process with a copy of the config file in dir with settings added.
+// The command runs in dir, writes workloader.log in dir, and does not write the caller's result file. Callers can change the working directory and outputs before running it and
+// must call cleanup when the command finishes to remove the config copy.
+func WorkloaderCommand(ctx context.Context, dir string, settings map[string]interface{}, args ...string) (c *exec.Cmd, cleanup func(), err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Copy the config file with the settings
+	config := filepath.Join(dir, "pce.yaml")
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if data, err := os.ReadFile(viper.ConfigFileUsed()); err == nil {
+		if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+			return nil, nil, fmt.Errorf("reading config - %s", err)
+		}
+	}
+	for k, s := range settings {
+		v.Set(k, s)
+	}
+	if err := os.WriteFile(config, nil, 0600); err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.Remove(config) }
+	if err := v.WriteConfigAs(config); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	c = exec.CommandContext(ctx, exe, args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), "ILLUMIO_CONFIG="+config, "WORKLOADER_PROFILE=", "WORKLOADER_LOG_FILE="+filepath.Join(dir, "workloader.log"), "WORKLOADER_RESULT_FILE=")
+	return c, cleanup, nil
+}
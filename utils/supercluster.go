@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brian1917/illumioapi"
+	"github.com/spf13/viper"
+)
+
+// pceCluster is a cluster in the health api response. Superclusters return a leader and members.
+type pceCluster struct {
+	FQDN   string `json:"fqdn"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// GetTargetMember returns the supercluster member from the --member flag
+func GetTargetMember() string {
+	return viper.GetString("target_member")
+}
+
+// superclusterRoute returns the fqdn for read requests and the leader fqdn for write requests.
+// The leader is blank for standalone PCEs. An error is only returned if a member was requested and cannot be used.
+func superclusterRoute(pce illumioapi.PCE, fqdn, member string) (read, leader string, err error) {
+	var clusters []pceCluster
+	api, err := pce.GetHref("/health", &clusters)
+	LogAPIResp("GetHealth", api)
+	if err != nil || api.StatusCode != 200 {
+		if member != "" {
+			return "", "", fmt.Errorf("getting supercluster members for --member - %d - %v", api.StatusCode, err)
+		}
+		LogDebug(fmt.Sprintf("skipping supercluster detection - %d - %v", api.StatusCode, err))
+		return "", "", nil
+	}
+	for _, c := range clusters {
+		if c.Type == "leader" {
+			leader = c.FQDN
+		}
+	}
+	if leader == "" {
+		if member != "" {
+			return "", "", fmt.Errorf("%s is not a supercluster. --member cannot be used", fqdn)
+		}
+		return "", "", nil
+	}
+
+	read = fqdn
+	if member != "" {
+		read = ""
+		for _, c := range clusters {
+			if strings.EqualFold(c.FQDN, member) || strings.EqualFold(strings.Split(c.FQDN, ".")[0], member) || (strings.EqualFold(member, "leader") && c.Type == "leader") {
+				read = c.FQDN
+			}
+		}
+		if read == "" {
+			fqdns := []string{}
+			for _, c := range clusters {
+				fqdns = append(fqdns, fmt.Sprintf("%s (%s)", c.FQDN, c.Type))
+			}
+			return "", "", fmt.Errorf("%s is not a member of the supercluster. options: %s", member, strings.Join(fqdns, ", "))
+		}
+	}
+	return read, leader, nil
+}
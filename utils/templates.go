@@ -5,28 +5,31 @@ func RootTemplate() string {
 	return `  Usage:{{if .Runnable}}
 	{{.CommandPath}} [command]
 
-  PCE Management Commands:{{range .Commands}}{{if (or (eq .Name "all-pces") (eq .Name "target-pces") (eq .Name "set-proxy") (eq .Name "clear-proxy") (eq .Name "pce-remove") (eq .Name "pce-add") (eq .Name "get-default") (eq .Name "set-default") (eq .Name "pce-list"))}}
+  PCE Management Commands:{{range .Commands}}{{if (or (eq .Name "all-pces") (eq .Name "target-pces") (eq .Name "all-orgs") (eq .Name "set-proxy") (eq .Name "clear-proxy") (eq .Name "pce-remove") (eq .Name "pce-keychain") (eq .Name "pce-encrypt") (eq .Name "pce-rotate-key") (eq .Name "pce-add") (eq .Name "get-default") (eq .Name "set-default") (eq .Name "pce-list") (eq .Name "profile-list"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Import/Export Commands:{{range .Commands}}{{if (or (eq .Name "wkld-export") (eq .Name "wkld-import") (eq .Name "ven-export") (eq .Name "ven-import") (eq .Name "ipl-export") (eq .Name "ipl-import") (eq .Name "ipl-replace") (eq .Name "label-export") (eq .Name "label-import") (eq .Name "svc-export") (eq .Name "svc-import") (eq .Name "rule-export") (eq .Name "rule-import") (eq .Name "ruleset-export") (eq .Name "ruleset-import") (eq .Name "labelgroup-export") (eq .Name "labelgroup-import") (eq .Name "cwp-export") (eq .Name "cwp-import") (eq .Name "flow-import"))}}
+  Import/Export Commands:{{range .Commands}}{{if (or (eq .Name "wkld-export") (eq .Name "wkld-import") (eq .Name "ven-export") (eq .Name "ven-import") (eq .Name "ipl-export") (eq .Name "ipl-import") (eq .Name "ipl-replace") (eq .Name "label-export") (eq .Name "label-import") (eq .Name "svc-export") (eq .Name "svc-import") (eq .Name "rule-export") (eq .Name "rule-import") (eq .Name "ruleset-export") (eq .Name "ruleset-import") (eq .Name "labelgroup-export") (eq .Name "labelgroup-import") (eq .Name "pairing-profile-import") (eq .Name "cwp-export") (eq .Name "cwp-import") (eq .Name "flow-import") (eq .Name "tf-export") (eq .Name "ansible-inventory") (eq .Name "vuln-import") (eq .Name "edr-import") (eq .Name "dns-import") (eq .Name "dhcp-import"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 	  
-  Automation Commands:{{range .Commands}}{{if (or (eq .Name "traffic") (eq .Name "subnet") (eq .Name "hostparse") (eq .Name "dag-sync"))}}
+  Automation Commands:{{range .Commands}}{{if (or (eq .Name "traffic") (eq .Name "subnet") (eq .Name "hostparse") (eq .Name "dag-sync") (eq .Name "aws-sync") (eq .Name "azure-sync") (eq .Name "gcp-sync") (eq .Name "k8s-sync") (eq .Name "vcenter-sync") (eq .Name "f5-sync") (eq .Name "infoblox-sync") (eq .Name "ad-sync") (eq .Name "nsx-sync") (eq .Name "idp-sync") (eq .Name "consul-sync"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Workload Management Commands:{{range .Commands}}{{if (or (eq .Name "compatibility") (eq .Name "mode") (eq .Name "upgrade") (eq .Name "unpair") (eq .Name "get-pk") (eq .Name "umwl-cleanup") (eq .Name "nic-manage") (eq .Name "containment-switch") (eq .Name "increase-ven-rate") (eq .Name "wkld-replicate"))}}
+  Workload Management Commands:{{range .Commands}}{{if (or (eq .Name "compatibility") (eq .Name "mode") (eq .Name "upgrade") (eq .Name "unpair") (eq .Name "get-pk") (eq .Name "umwl-cleanup") (eq .Name "nic-manage") (eq .Name "wkld-rename") (eq .Name "containment-switch") (eq .Name "increase-ven-rate") (eq .Name "wkld-replicate"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Label Management Commands:{{range .Commands}}{{if (or (eq .Name "labels-delete-unused") (eq .Name "label-rename"))}}
+  Label Management Commands:{{range .Commands}}{{if (or (eq .Name "labels-delete-unused") (eq .Name "label-rename") (eq .Name "label-writeback"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Reporting Commands:{{range .Commands}}{{if (or (eq .Name "rule-usage") (eq .Name "unused-ports") (eq .Name "mislabel") (eq .Name "dupecheck") (eq .Name "flowsummary") (eq .Name "explorer") (eq .Name "nic-export") (eq .Name "service-finder") (eq .Name "process-export") (eq .Name "wkld-ipl-mapping") (eq .Name "ven-health") (eq .Name "unused-umwl"))}}
+  Reporting Commands:{{range .Commands}}{{if (or (eq .Name "rule-usage") (eq .Name "rule-usage-trend") (eq .Name "unused-ports") (eq .Name "mislabel") (eq .Name "dupecheck") (eq .Name "flowsummary") (eq .Name "explorer") (eq .Name "nic-export") (eq .Name "service-finder") (eq .Name "process-export") (eq .Name "wkld-ipl-mapping") (eq .Name "ven-health") (eq .Name "unused-umwl") (eq .Name "compliance-report") (eq .Name "policy-diff") (eq .Name "report") (eq .Name "drift-report") (eq .Name "label-coverage") (eq .Name "rule-complexity") (eq .Name "enforcement-readiness") (eq .Name "dependency-map") (eq .Name "exposure") (eq .Name "rule-analysis") (eq .Name "broad-rules") (eq .Name "ven-versions") (eq .Name "change-report") (eq .Name "rbac-audit") (eq .Name "cert-expiry") (eq .Name "pairing-profile-audit") (eq .Name "traffic-baseline") (eq .Name "traffic-anomaly") (eq .Name "serve-metrics") (eq .Name "server"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Template Commands:{{range .Commands}}{{if (or (eq .Name "template-list") (eq .Name "template-import") (eq .Name "template-create"))}}
+  Template Commands:{{range .Commands}}{{if (or (eq .Name "template-list") (eq .Name "template-import") (eq .Name "template-create") (eq .Name "apply"))}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
-  Other Commands:{{range .Commands}}{{if (or (eq .Name "delete") (eq .Name "netscaler-sync"))}}
+  Other Commands:{{range .Commands}}{{if (or (eq .Name "delete") (eq .Name "undo") (eq .Name "plugin-list") (eq .Name "scheduler") (eq .Name "completion") (eq .Name "netscaler-sync"))}}
+	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
+
+  Plugin Commands:{{range .Commands}}{{if (index .Annotations "plugin")}}
 	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
   Version Command:{{range .Commands}}{{if (or (eq .Name "version") (eq .Name "check-version"))}}
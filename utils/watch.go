@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Watch changes
+const (
+	WatchAdded   = "added"
+	WatchRemoved = "removed"
+	WatchChanged = "changed"
+)
+
+// Watch runs a report on an interval until it is stopped with ctrl-c. The first run is the baseline and writes the full report.
+// Each run after that writes only the rows that were added, removed, or changed since the previous run.
+// run returns the report with the headers in the first row. The write argument is true for the baseline run.
+// keys are the headers that identify a row. A row is identified by all of its values if keys is empty.
+func Watch(command string, interval time.Duration, keys []string, run func(write bool) [][]string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var previous [][]string
+	for i := 1; ; i++ {
+		current := run(previous == nil)
+		if len(current) == 0 {
+			current = [][]string{{}}
+		}
+		if previous == nil {
+			LogInfo(fmt.Sprintf("watch run %d - baseline of %d rows", i, len(current)-1), true)
+		} else {
+			delta := WatchDelta(previous, current, keys)
+			LogInfo(fmt.Sprintf("watch run %d - %s", i, watchSummary(delta)), true)
+			if len(delta) > 1 {
+				WriteOutput(delta, delta, fmt.Sprintf("workloader-%s-watch-delta-%s.csv", command, time.Now().Format("20060102_150405")))
+			}
+		}
+		previous = current
+
+		LogInfo(fmt.Sprintf("next %s run at %s. press ctrl-c to stop.", command, time.Now().Add(interval).Format("2006-01-02 15:04:05")), true)
+		select {
+		case <-ctx.Done():
+			LogInfo(fmt.Sprintf("%s watch stopped after %d runs", command, i), true)
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WatchDelta returns the rows of current that were added or changed and the rows of previous that were removed. The first row of both is the headers.
+// The delta has a change column and a changed_fields column before the report columns.
+func WatchDelta(previous, current [][]string, keys []string) [][]string {
+	headers := current[0]
+	if len(headers) == 0 && len(previous) > 0 {
+		headers = previous[0]
+	}
+	delta := [][]string{append([]string{"change", "changed_fields"}, headers...)}
+
+	previousRows := watchRows(previous, keys)
+	currentRows := watchRows(current, keys)
+
+	for _, key := range currentRows.order {
+		row := currentRows.rows[key]
+		old, ok := previousRows.rows[key]
+		if !ok {
+			delta = append(delta, append([]string{WatchAdded, ""}, row...))
+			continue
+		}
+		changes := []string{}
+		for c, h := range headers {
+			oldValue, newValue := watchValue(old, c), watchValue(row, c)
+			if oldValue != newValue {
+				changes = append(changes, DiffValue(h, oldValue, newValue)[0].String())
+			}
+		}
+		if len(changes) > 0 {
+			delta = append(delta, append([]string{WatchChanged, strings.Join(changes, "; ")}, row...))
+		}
+	}
+	for _, key := range previousRows.order {
+		if _, ok := currentRows.rows[key]; !ok {
+			delta = append(delta, append([]string{WatchRemoved, ""}, previousRows.rows[key]...))
+		}
+	}
+	return delta
+}
+
+// watchTable is the rows of a report by key in the order of the report
+type watchTable struct {
+	rows  map[string][]string
+	order []string
+}
+
+// watchRows returns the rows of a report by key
+func watchRows(data [][]string, keys []string) watchTable {
+	t := watchTable{rows: make(map[string][]string)}
+	if len(data) == 0 {
+		return t
+	}
+	cols := []int{}
+	for _, k := range keys {
+		for c, h := range data[0] {
+			if h == k {
+				cols = append(cols, c)
+			}
+		}
+	}
+	for _, row := range data[1:] {
+		values := row
+		if len(cols) > 0 {
+			values = []string{}
+			for _, c := range cols {
+				values = append(values, watchValue(row, c))
+			}
+		}
+		key := strings.Join(values, "\x00")
+		if _, ok := t.rows[key]; !ok {
+			t.order = append(t.order, key)
+		}
+		t.rows[key] = row
+	}
+	return t
+}
+
+// watchValue returns the value of a column or blank if the row is short
+func watchValue(row []string, c int) string {
+	if c < len(row) {
+		return row[c]
+	}
+	return ""
+}
+
+// watchSummary returns the number of added, removed, and changed rows in a delta
+func watchSummary(delta [][]string) string {
+	counts := make(map[string]int)
+	for _, row := range delta[1:] {
+		counts[row[0]]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d changed", counts[WatchAdded], counts[WatchRemoved], counts[WatchChanged])
+}